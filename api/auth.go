@@ -0,0 +1,172 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"proxy_pool/core"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// apiKeyRateWindow API密钥限流的时间窗口
+const apiKeyRateWindow = time.Minute
+
+// apiKeyNameKey 鉴权通过后请求上下文中保存密钥名称的键
+const apiKeyNameKey = "api_key_name"
+
+// defaultAPIKeyName SetAPIKey 设置的密钥在启用带权限范围的密钥后使用的名称，拥有 admin 权限
+const defaultAPIKeyName = "default"
+
+// routeScopes 启用带权限范围的API密钥后各接口需要的权限范围，键为 "方法 路由"，未列出的接口需要 admin
+var routeScopes = map[string]core.APIScope{
+	"GET /api/proxy":               core.ScopeRead,
+	"GET /api/proxies":             core.ScopeRead,
+	"GET /api/export/subscription": core.ScopeRead,
+	"POST /api/proxy/:id/status":   core.ScopeReport,
+	"POST /api/proxies/status":     core.ScopeReport,
+}
+
+// publicRoutes 启用带权限范围的API密钥后仍不需要密钥的接口，供健康检查使用
+var publicRoutes = map[string]bool{
+	"GET /api/ready": true,
+}
+
+// routeScope 获取接口需要的权限范围
+func routeScope(route string) core.APIScope {
+	if scope, ok := routeScopes[route]; ok {
+		return scope
+	}
+	return core.ScopeAdmin
+}
+
+// missingScope 密钥缺少接口需要的权限范围
+func missingScope(name string, required core.APIScope) *APIError {
+	return newAPIError(http.StatusForbidden, CodeForbidden,
+		fmt.Errorf("api key %q lacks scope %q", name, required),
+		gin.H{"required_scope": required})
+}
+
+// SetAPIKeys 设置带权限范围的API密钥，需在 Run 之前调用
+// 设置后 /api 下除就绪检查外的所有接口和 /metrics 都需要密钥，SetAPIKey 设置的密钥视为 admin 权限；
+// 未设置时只有导出和订阅导出需要 SetAPIKey 设置的密钥
+func (s *Server) SetAPIKeys(keys []core.APIKey) {
+	s.apiKeys = append([]core.APIKey(nil), keys...)
+}
+
+// requestAPIKey 获取请求头 X-API-Key 或查询参数 api_key
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.Query("api_key")
+}
+
+// lookupAPIKey 查找与 key 匹配的带权限范围的密钥，逐个按常量时间比较，不匹配时返回nil
+func (s *Server) lookupAPIKey(key string) *core.APIKey {
+	if key == "" {
+		return nil
+	}
+	for i := range s.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKeys[i].Key)) == 1 {
+			return &s.apiKeys[i]
+		}
+	}
+	if s.apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKey)) == 1 {
+		return &core.APIKey{Name: defaultAPIKeyName, Key: s.apiKey, Scopes: []core.APIScope{core.ScopeAdmin}}
+	}
+	return nil
+}
+
+// authorize 启用带权限范围的API密钥后校验请求的密钥、权限范围和请求频率
+// 缺少或错误的密钥返回401，权限不足返回403并给出需要的权限范围，超过频率限制返回429
+func (s *Server) authorize(c *gin.Context) {
+	route := c.Request.Method + " " + c.FullPath()
+	if len(s.apiKeys) == 0 || publicRoutes[route] {
+		c.Next()
+		return
+	}
+
+	key := s.lookupAPIKey(requestAPIKey(c))
+	if key == nil {
+		respondError(c, errInvalidAPIKey)
+		return
+	}
+	if required := routeScope(route); !key.HasScope(required) {
+		s.proxyPool.Logger().Warn("API密钥权限不足",
+			zap.String("密钥", key.Name),
+			zap.String("接口", route),
+			zap.String("需要的权限", string(required)),
+		)
+		respondError(c, missingScope(key.Name, required))
+		return
+	}
+	if key.RequestsPerMinute > 0 {
+		if ok, retryAfter := s.keyLimiter.allow(key.Name, key.RequestsPerMinute, time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.999)))
+			respondError(c, newAPIError(http.StatusTooManyRequests, CodeRateLimited,
+				fmt.Errorf("api key %q exceeded %d requests per minute", key.Name, key.RequestsPerMinute), nil))
+			return
+		}
+	}
+
+	c.Set(apiKeyNameKey, key.Name)
+	c.Next()
+}
+
+// requireAPIKey 校验请求头 X-API-Key 或查询参数 api_key，订阅客户端通常只能配置URL
+// 未设置API密钥时拒绝所有请求，避免未配置时公开接口；启用带权限范围的密钥后已由 authorize 校验
+func (s *Server) requireAPIKey(c *gin.Context) {
+	if len(s.apiKeys) > 0 {
+		c.Next()
+		return
+	}
+	if s.apiKey == "" {
+		respondError(c, errAPIKeyUnconfigured)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(requestAPIKey(c)), []byte(s.apiKey)) != 1 {
+		respondError(c, errInvalidAPIKey)
+		return
+	}
+	c.Next()
+}
+
+// keyLimiter 按密钥名称的固定窗口请求限流
+type keyLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*keyWindow
+}
+
+// keyWindow 密钥在当前窗口内的请求数
+type keyWindow struct {
+	start time.Time
+	count int
+}
+
+// newKeyLimiter 创建密钥限流器
+func newKeyLimiter() *keyLimiter {
+	return &keyLimiter{windows: make(map[string]*keyWindow)}
+}
+
+// allow 记录一次请求，超过每分钟 limit 次时返回false和距窗口结束的时间
+func (l *keyLimiter) allow(name string, limit int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[name]
+	if !ok || now.Sub(w.start) >= apiKeyRateWindow {
+		w = &keyWindow{start: now}
+		l.windows[name] = w
+	}
+	if w.count >= limit {
+		return false, apiKeyRateWindow - now.Sub(w.start)
+	}
+	w.count++
+	return true, 0
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"proxy_pool/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportRequiresAPIKey(t *testing.T) {
+	unconfigured := newTestServer(t).engine()
+	for _, path := range []string{"/api/export", "/api/export/subscription"} {
+		if rec := serve(t, unconfigured, http.MethodGet, path, nil); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s without configured key status = %d, want %d", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	s := newTestServer(t)
+	s.SetAPIKey("secret")
+	handler := s.engine()
+
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		status int
+	}{
+		{"export without key", "/api/export", nil, http.StatusUnauthorized},
+		{"export wrong key", "/api/export", http.Header{"X-Api-Key": {"wrong"}}, http.StatusUnauthorized},
+		{"export header key", "/api/export", http.Header{"X-Api-Key": {"secret"}}, http.StatusOK},
+		{"export query key", "/api/export?format=csv&api_key=secret", nil, http.StatusOK},
+		{"subscription without key", "/api/export/subscription", nil, http.StatusUnauthorized},
+		{"subscription query key", "/api/export/subscription?format=plain&api_key=secret", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, handler, http.MethodGet, tt.path, tt.header); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAccessLogRedactsAPIKey(t *testing.T) {
+	line := accessLogFormatter(gin.LogFormatterParams{
+		Method:     http.MethodGet,
+		Path:       "/api/export/subscription?format=clash&api_key=secret",
+		StatusCode: http.StatusOK,
+	})
+	if strings.Contains(line, "secret") {
+		t.Errorf("access log leaks api key: %s", line)
+	}
+	if !strings.Contains(line, "format=clash") {
+		t.Errorf("access log lost other query params: %s", line)
+	}
+}
+
+func TestScopeMatrix(t *testing.T) {
+	s := newTestServer(t)
+	s.SetAPIKeys([]core.APIKey{
+		{Name: "reader", Key: "read-key", Scopes: []core.APIScope{core.ScopeRead}},
+		{Name: "reporter", Key: "report-key", Scopes: []core.APIScope{core.ScopeReport}},
+		{Name: "admin", Key: "admin-key", Scopes: []core.APIScope{core.ScopeAdmin}},
+	})
+	handler := s.engine()
+
+	endpoints := []struct {
+		method string
+		path   string
+		scope  core.APIScope // 为空表示公开接口
+	}{
+		{http.MethodGet, "/api/ready", ""},
+		{http.MethodGet, "/api/proxy", core.ScopeRead},
+		{http.MethodGet, "/api/proxies", core.ScopeRead},
+		{http.MethodGet, "/api/export/subscription?format=plain", core.ScopeRead},
+		{http.MethodPost, "/api/proxy/1/status", core.ScopeReport},
+		{http.MethodPost, "/api/proxies/status", core.ScopeReport},
+		{http.MethodGet, "/api/proxies/stream", core.ScopeAdmin},
+		{http.MethodGet, "/api/export", core.ScopeAdmin},
+		{http.MethodGet, "/api/stats", core.ScopeAdmin},
+		{http.MethodGet, "/metrics", core.ScopeAdmin},
+	}
+	keys := []struct {
+		key   string
+		scope core.APIScope
+	}{
+		{"read-key", core.ScopeRead},
+		{"report-key", core.ScopeReport},
+		{"admin-key", core.ScopeAdmin},
+	}
+
+	for _, ep := range endpoints {
+		rec := serve(t, handler, ep.method, ep.path, nil)
+		if ep.scope == "" {
+			if rec.Code == http.StatusUnauthorized {
+				t.Errorf("%s %s without key = 401, want public", ep.method, ep.path)
+			}
+			continue
+		}
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without key = %d, want 401", ep.method, ep.path, rec.Code)
+		}
+
+		for _, k := range keys {
+			rec := serve(t, handler, ep.method, ep.path, http.Header{"X-Api-Key": {k.key}})
+			allowed := k.scope.Includes(ep.scope)
+			switch {
+			case allowed && (rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden):
+				t.Errorf("%s %s with %s key = %d, want allowed", ep.method, ep.path, k.scope, rec.Code)
+			case !allowed && rec.Code != http.StatusForbidden:
+				t.Errorf("%s %s with %s key = %d, want 403", ep.method, ep.path, k.scope, rec.Code)
+			case !allowed && !strings.Contains(rec.Body.String(), string(ep.scope)):
+				t.Errorf("%s %s 403 body does not name scope %s: %s", ep.method, ep.path, ep.scope, rec.Body.String())
+			}
+		}
+	}
+}
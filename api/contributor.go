@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http/pprof"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RouteContributor 路由注册者，用于在不修改 Server 的情况下扩展 /api 下的路由
+type RouteContributor interface {
+	RegisterRoutes(rg *gin.RouterGroup)
+}
+
+// RouteContributorFunc 将普通函数适配为 RouteContributor
+type RouteContributorFunc func(rg *gin.RouterGroup)
+
+// RegisterRoutes 调用函数本身
+func (f RouteContributorFunc) RegisterRoutes(rg *gin.RouterGroup) {
+	f(rg)
+}
+
+// MiddlewareContributor 同时实现该接口的注册者在内置路由注册前为 /api 路由组添加中间件
+// gin的中间件只对之后注册的路由生效，在 RegisterRoutes 中添加的中间件不作用于内置路由
+type MiddlewareContributor interface {
+	RegisterMiddleware(rg *gin.RouterGroup)
+}
+
+// RootRouteContributor 同时实现该接口的注册者在根路径而不是 /api 下注册路由，路由组带有与 /api 相同的鉴权
+type RootRouteContributor interface {
+	RegisterRootRoutes(rg *gin.RouterGroup)
+}
+
+// RecoveryContributor 为 /api 下的所有路由注册panic恢复中间件，返回JSON格式的500错误
+type RecoveryContributor struct {
+	Logger *zap.Logger
+}
+
+// RegisterRoutes 不注册路由，中间件由 RegisterMiddleware 添加
+func (RecoveryContributor) RegisterRoutes(rg *gin.RouterGroup) {}
+
+// RegisterMiddleware 注册panic恢复中间件
+func (rc RecoveryContributor) RegisterMiddleware(rg *gin.RouterGroup) {
+	logger := rc.Logger
+	if logger == nil {
+		logger = zap.L()
+	}
+
+	rg.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		logger.Error("请求处理发生panic",
+			zap.String("路径", c.Request.URL.Path),
+			zap.Any("错误", recovered),
+		)
+		respondError(c, errInternal)
+	}))
+}
+
+// PProfContributor 在根路径的 /debug/pprof 下挂载 net/http/pprof 处理器
+type PProfContributor struct{}
+
+// RegisterRoutes 不在 /api 下注册路由，pprof 路由由 RegisterRootRoutes 注册
+func (PProfContributor) RegisterRoutes(rg *gin.RouterGroup) {}
+
+// RegisterRootRoutes 注册pprof路由
+func (PProfContributor) RegisterRootRoutes(rg *gin.RouterGroup) {
+	debug := rg.Group("/debug/pprof")
+	{
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		debug.GET("/:name", func(c *gin.Context) {
+			pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+	"proxy_pool/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// panicScheduler 调度时panic的调度器
+type panicScheduler struct {
+	testutil.MockScheduler
+}
+
+func (*panicScheduler) ScheduleProxy(ctx context.Context, task *core.Task) (*models.Proxy, error) {
+	panic("scheduler exploded")
+}
+
+func TestRouteContributors(t *testing.T) {
+	s := newTestServer(t, core.WithScheduler(&panicScheduler{}))
+	s.AddContributor(RouteContributorFunc(func(rg *gin.RouterGroup) {
+		rg.GET("/custom", func(c *gin.Context) { c.String(http.StatusOK, "custom") })
+	}))
+	s.AddContributor(PProfContributor{})
+	s.AddContributor(RecoveryContributor{})
+	handler := s.engine()
+
+	rec := serve(t, handler, http.MethodGet, "/api/custom", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "custom" {
+		t.Errorf("custom route = %d %q, want 200 custom", rec.Code, rec.Body.String())
+	}
+
+	// pprof 挂载在根路径
+	if rec := serve(t, handler, http.MethodGet, "/debug/pprof/", nil); rec.Code != http.StatusOK {
+		t.Errorf("/debug/pprof/ status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(t, handler, http.MethodGet, "/api/debug/pprof/", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("/api/debug/pprof/ status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	// 后添加的恢复中间件同样作用于内置路由
+	errorWriter := gin.DefaultErrorWriter
+	gin.DefaultErrorWriter = io.Discard
+	t.Cleanup(func() { gin.DefaultErrorWriter = errorWriter })
+	rec = serve(t, handler, http.MethodGet, "/api/proxy", nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("panicking handler status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Errorf("panic response is not JSON: %q", rec.Body.String())
+	}
+}
+
+func TestPProfRequiresAdminScope(t *testing.T) {
+	s := newTestServer(t)
+	s.SetAPIKeys([]core.APIKey{
+		{Name: "reader", Key: "read-key", Scopes: []core.APIScope{core.ScopeRead}},
+		{Name: "admin", Key: "admin-key", Scopes: []core.APIScope{core.ScopeAdmin}},
+	})
+	s.AddContributor(PProfContributor{})
+	handler := s.engine()
+
+	tests := []struct {
+		key    string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"read-key", http.StatusForbidden},
+		{"admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := serve(t, handler, http.MethodGet, "/debug/pprof/", http.Header{"X-Api-Key": {tt.key}})
+		if rec.Code != tt.status {
+			t.Errorf("key %q status = %d, want %d", tt.key, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"proxy_pool/core"
+	"proxy_pool/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// ErrorCode API错误码，客户端应根据错误码而不是错误信息判断错误类型
+type ErrorCode string
+
+const (
+	CodeProxyNotFound    ErrorCode = "PROXY_NOT_FOUND"     // 代理不存在
+	CodeSourceNotFound   ErrorCode = "SOURCE_NOT_FOUND"    // 代理源不存在
+	CodeSiteNotFound     ErrorCode = "SITE_NOT_FOUND"      // 域名没有注册站点配置
+	CodeGroupNotFound    ErrorCode = "GROUP_NOT_FOUND"     // 代理分组不存在
+	CodeNoProxyAvailable ErrorCode = "NO_PROXY_AVAILABLE"  // 没有符合条件的可用代理
+	CodeDuplicateProxy   ErrorCode = "DUPLICATE_PROXY"     // 代理已存在
+	CodeDuplicateGroup   ErrorCode = "DUPLICATE_GROUP"     // 代理分组已存在
+	CodeValidationFailed ErrorCode = "VALIDATION_FAILED"   // 参数格式正确但内容不合法，如私有IP、非法标签
+	CodeRateLimited      ErrorCode = "RATE_LIMITED"        // 请求过于频繁
+	CodeBadRequest       ErrorCode = "BAD_REQUEST"         // 请求参数格式错误
+	CodeUnauthorized     ErrorCode = "UNAUTHORIZED"        // 缺少或错误的API密钥
+	CodeForbidden        ErrorCode = "FORBIDDEN"           // API密钥缺少接口需要的权限范围
+	CodePayloadTooLarge  ErrorCode = "PAYLOAD_TOO_LARGE"   // 批量请求条数超过上限
+	CodeJobRunning       ErrorCode = "JOB_RUNNING"         // 后台任务正在进行
+	CodeTimeout          ErrorCode = "TIMEOUT"             // 处理超时
+	CodeVerifyFailed     ErrorCode = "VERIFY_FAILED"       // 尝试的代理均未通过发放前验证
+	CodeUnavailable      ErrorCode = "SERVICE_UNAVAILABLE" // 依赖的服务未启用
+	CodeInternal         ErrorCode = "INTERNAL"            // 服务器内部错误
+)
+
+// mysqlDuplicateEntry MySQL唯一键冲突错误号
+const mysqlDuplicateEntry = 1062
+
+var (
+	errInternal              = errors.New("internal server error")
+	errValidationUnavailable = &APIError{
+		Status:  http.StatusServiceUnavailable,
+		Code:    CodeUnavailable,
+		Message: "validation service not configured",
+	}
+	errFetcherUnavailable = &APIError{
+		Status:  http.StatusServiceUnavailable,
+		Code:    CodeUnavailable,
+		Message: "proxy fetcher not configured",
+	}
+	errCronJobsUnavailable = &APIError{
+		Status:  http.StatusServiceUnavailable,
+		Code:    CodeUnavailable,
+		Message: "cron jobs not configured",
+	}
+	errAPIKeyUnconfigured = &APIError{
+		Status:  http.StatusServiceUnavailable,
+		Code:    CodeUnavailable,
+		Message: "api key not configured",
+	}
+	errInvalidAPIKey = &APIError{
+		Status:  http.StatusUnauthorized,
+		Code:    CodeUnauthorized,
+		Message: "missing or invalid api key",
+	}
+)
+
+// APIError API错误响应
+type APIError struct {
+	Status  int         `json:"-"`
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newAPIError 创建API错误
+func newAPIError(status int, code ErrorCode, err error, details interface{}) *APIError {
+	return &APIError{
+		Status:  status,
+		Code:    code,
+		Message: err.Error(),
+		Details: details,
+	}
+}
+
+// badRequest 请求参数格式错误
+func badRequest(err error) *APIError {
+	return newAPIError(http.StatusBadRequest, CodeBadRequest, err, nil)
+}
+
+// validationFailed 请求参数内容不合法
+func validationFailed(err error) *APIError {
+	return newAPIError(http.StatusUnprocessableEntity, CodeValidationFailed, err, nil)
+}
+
+// toAPIError 将错误映射为API错误，未识别的错误视为内部错误
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var noProxy *core.NoProxyError
+	if errors.As(err, &noProxy) {
+		return newAPIError(http.StatusNotFound, CodeNoProxyAvailable, err, gin.H{"filters": noProxy.Filters})
+	}
+
+	var timeout *core.SchedulingTimeoutError
+	if errors.As(err, &timeout) {
+		return newAPIError(http.StatusGatewayTimeout, CodeTimeout, err, gin.H{
+			"elapsed_ms": timeout.Elapsed.Milliseconds(),
+			"timeout_ms": timeout.Timeout.Milliseconds(),
+		})
+	}
+
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case errors.Is(err, core.ErrNoProxyAvailable), errors.Is(err, core.ErrNoQualifiedProxy):
+		return newAPIError(http.StatusNotFound, CodeNoProxyAvailable, err, nil)
+	case errors.Is(err, models.ErrSourceNotFound):
+		return newAPIError(http.StatusNotFound, CodeSourceNotFound, err, nil)
+	case errors.Is(err, models.ErrGroupNotFound):
+		return newAPIError(http.StatusNotFound, CodeGroupNotFound, err, nil)
+	case errors.Is(err, models.ErrGroupExists):
+		return newAPIError(http.StatusConflict, CodeDuplicateGroup, err, nil)
+	case errors.Is(err, core.ErrSiteNotRegistered):
+		return newAPIError(http.StatusNotFound, CodeSiteNotFound, err, nil)
+	case errors.Is(err, core.ErrProxyRateLimited):
+		return newAPIError(http.StatusTooManyRequests, CodeRateLimited, err, nil)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return newAPIError(http.StatusNotFound, CodeProxyNotFound, err, nil)
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, core.ErrProxyExists),
+		errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry:
+		return newAPIError(http.StatusConflict, CodeDuplicateProxy, err, nil)
+	case errors.Is(err, models.ErrInvalidIP), errors.Is(err, models.ErrLoopbackIP),
+		errors.Is(err, models.ErrLinkLocalIP), errors.Is(err, models.ErrPrivateIP),
+		errors.Is(err, models.ErrInvalidTag), errors.Is(err, models.ErrHostnameNotAllowed),
+		errors.Is(err, core.ErrInvalidCallbackURL), errors.Is(err, models.ErrInvalidGroup),
+		errors.Is(err, core.ErrProtocolUndetected), errors.Is(err, core.ErrVerifyTargetNotAllowed),
+		errors.Is(err, core.ErrHandoutMismatch):
+		return validationFailed(err)
+	case errors.Is(err, core.ErrVerifyFailed):
+		return newAPIError(http.StatusBadGateway, CodeVerifyFailed, err, nil)
+	case errors.Is(err, models.ErrInvalidProxy):
+		var validationErr *models.ProxyValidationError
+		if errors.As(err, &validationErr) {
+			return newAPIError(http.StatusUnprocessableEntity, CodeValidationFailed, err,
+				gin.H{"violations": validationErr.Violations})
+		}
+		return validationFailed(err)
+	case errors.Is(err, models.ErrEmptyIPRange), errors.Is(err, models.ErrFullTextUnsupported),
+		errors.Is(err, gorm.ErrMissingWhereClause), errors.Is(err, models.ErrInvalidFilter),
+		errors.Is(err, core.ErrRestartRequired), errors.Is(err, core.ErrUnknownConfigField),
+		errors.Is(err, core.ErrInvalidMigrateFormat):
+		return badRequest(err)
+	case errors.Is(err, core.ErrInvalidRuntimeConfig):
+		return validationFailed(err)
+	case errors.Is(err, core.ErrWebhooksDisabled), errors.Is(err, core.ErrRedisDegraded):
+		return newAPIError(http.StatusServiceUnavailable, CodeUnavailable, err, nil)
+	case errors.Is(err, core.ErrValidationRunning), errors.Is(err, core.ErrMigrationRunning):
+		return newAPIError(http.StatusConflict, CodeJobRunning, err, nil)
+	}
+
+	return newAPIError(http.StatusInternalServerError, CodeInternal, err, nil)
+}
+
+// respondError 输出错误响应：{"code": ..., "message": ..., "details": ...}
+func respondError(c *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		c.Error(err)
+	}
+	c.AbortWithStatusJSON(apiErr.Status, apiErr)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+
+	"gorm.io/gorm"
+)
+
+func TestToAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   ErrorCode
+	}{
+		{"api error", errInvalidAPIKey, http.StatusUnauthorized, CodeUnauthorized},
+		{"no proxy", fmt.Errorf("schedule: %w", core.ErrNoProxyAvailable), http.StatusNotFound, CodeNoProxyAvailable},
+		{"no proxy with filters", &core.NoProxyError{Err: core.ErrNoQualifiedProxy}, http.StatusNotFound, CodeNoProxyAvailable},
+		{"scheduling timeout", &core.SchedulingTimeoutError{Timeout: time.Second}, http.StatusGatewayTimeout, CodeTimeout},
+		{"record not found", gorm.ErrRecordNotFound, http.StatusNotFound, CodeProxyNotFound},
+		{"source not found", models.ErrSourceNotFound, http.StatusNotFound, CodeSourceNotFound},
+		{"duplicate", core.ErrProxyExists, http.StatusConflict, CodeDuplicateProxy},
+		{"private ip", fmt.Errorf("%w: 10.0.0.1", models.ErrPrivateIP), http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"validation running", core.ErrValidationRunning, http.StatusConflict, CodeJobRunning},
+		{"rate limited", core.ErrProxyRateLimited, http.StatusTooManyRequests, CodeRateLimited},
+		{"site not registered", fmt.Errorf("%w: shop.test", core.ErrSiteNotRegistered), http.StatusNotFound, CodeSiteNotFound},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		got := toAPIError(tt.err)
+		if got.Status != tt.status || got.Code != tt.code {
+			t.Errorf("%s: toAPIError = %d %s, want %d %s", tt.name, got.Status, got.Code, tt.status, tt.code)
+		}
+	}
+}
+
+func TestHandlersReturnErrorCodes(t *testing.T) {
+	handler := newTestServer(t).engine()
+
+	tests := []struct {
+		method, target string
+		status         int
+		code           ErrorCode
+	}{
+		{http.MethodGet, "/api/proxy/abc", http.StatusBadRequest, CodeBadRequest},
+		{http.MethodGet, "/api/proxy/999", http.StatusNotFound, CodeProxyNotFound},
+		{http.MethodDelete, "/api/proxy/abc", http.StatusBadRequest, CodeBadRequest},
+		{http.MethodPut, "/api/proxy/abc", http.StatusBadRequest, CodeBadRequest},
+		{http.MethodGet, "/api/proxy", http.StatusNotFound, CodeNoProxyAvailable},
+		{http.MethodGet, "/api/proxy?strategy=unknown", http.StatusBadRequest, CodeBadRequest},
+	}
+	for _, tt := range tests {
+		rec := serve(t, handler, tt.method, tt.target, nil)
+		var body APIError
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", tt.method, tt.target, rec.Body, err)
+		}
+		if rec.Code != tt.status || body.Code != tt.code || body.Message == "" {
+			t.Errorf("%s %s = %d %s, want %d %s", tt.method, tt.target, rec.Code, rec.Body, tt.status, tt.code)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"proxy_pool/models"
+	"strconv"
+	"strings"
+)
+
+// exampleTargetURL 连接示例中未指定 target_url 时使用的目标地址
+const exampleTargetURL = "https://example.com"
+
+// connectionExamples 代理的连接示例，可直接复制使用
+type connectionExamples struct {
+	Curl           string `json:"curl"`            // curl -x 调用
+	Env            string `json:"env"`             // HTTP_PROXY/HTTPS_PROXY 环境变量
+	PythonRequests string `json:"python_requests"` // Python requests 的 proxies 参数
+}
+
+// newConnectionExamples 按代理的协议、IP和端口生成连接示例，targetURL 为空时使用 exampleTargetURL
+// 代理不保存认证信息，示例中不包含用户名和密码；socks4/socks5 代理在 Python requests 中需要安装 requests[socks]
+func newConnectionExamples(proxy *models.Proxy, targetURL string) *connectionExamples {
+	if targetURL == "" {
+		targetURL = exampleTargetURL
+	}
+	proxyURL := proxy.Protocol + "://" + net.JoinHostPort(proxy.IP, strconv.Itoa(proxy.Port))
+
+	return &connectionExamples{
+		Curl:           fmt.Sprintf("curl -x %s %s", proxyURL, shellQuote(targetURL)),
+		Env:            fmt.Sprintf("export HTTP_PROXY=%s HTTPS_PROXY=%s", proxyURL, proxyURL),
+		PythonRequests: fmt.Sprintf("proxies = {%q: %q, %q: %q}", "http", proxyURL, "https", proxyURL),
+	}
+}
+
+// shellQuote 用单引号包裹参数，避免目标地址中的 & ? 等字符被 shell 解释
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestNewConnectionExamples(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxy     *models.Proxy
+		targetURL string
+		want      connectionExamples
+	}{
+		{"http", &models.Proxy{Protocol: "http", IP: "1.2.3.4", Port: 8080}, "", connectionExamples{
+			Curl:           "curl -x http://1.2.3.4:8080 'https://example.com'",
+			Env:            "export HTTP_PROXY=http://1.2.3.4:8080 HTTPS_PROXY=http://1.2.3.4:8080",
+			PythonRequests: `proxies = {"http": "http://1.2.3.4:8080", "https": "http://1.2.3.4:8080"}`,
+		}},
+		{"socks5 with target", &models.Proxy{Protocol: "socks5", IP: "5.6.7.8", Port: 1080}, "https://shop.test/a?b=1&c='x'", connectionExamples{
+			Curl:           `curl -x socks5://5.6.7.8:1080 'https://shop.test/a?b=1&c='\''x'\'''`,
+			Env:            "export HTTP_PROXY=socks5://5.6.7.8:1080 HTTPS_PROXY=socks5://5.6.7.8:1080",
+			PythonRequests: `proxies = {"http": "socks5://5.6.7.8:1080", "https": "socks5://5.6.7.8:1080"}`,
+		}},
+		{"ipv6", &models.Proxy{Protocol: "https", IP: "2001:db8::1", Port: 443}, "", connectionExamples{
+			Curl:           "curl -x https://[2001:db8::1]:443 'https://example.com'",
+			Env:            "export HTTP_PROXY=https://[2001:db8::1]:443 HTTPS_PROXY=https://[2001:db8::1]:443",
+			PythonRequests: `proxies = {"http": "https://[2001:db8::1]:443", "https": "https://[2001:db8::1]:443"}`,
+		}},
+	}
+	for _, tt := range tests {
+		if got := newConnectionExamples(tt.proxy, tt.targetURL); *got != tt.want {
+			t.Errorf("%s: examples = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestGetProxyExamples(t *testing.T) {
+	s := newTestServer(t)
+	createTestProxy(t, s.proxyPool.DB(), "1.1.1.1")
+	handler := s.engine()
+
+	get := func(target string) map[string]json.RawMessage {
+		t.Helper()
+		rec := serve(t, handler, http.MethodGet, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body
+	}
+
+	if body := get("/api/proxy"); body["examples"] != nil {
+		t.Errorf("examples returned without examples=true: %s", body["examples"])
+	}
+	var examples connectionExamples
+	if err := json.Unmarshal(get("/api/proxy?examples=true")["examples"], &examples); err != nil {
+		t.Fatalf("decode examples: %v", err)
+	}
+	if examples.Curl != "curl -x http://1.1.1.1:8080 'https://example.com'" {
+		t.Errorf("curl example = %q", examples.Curl)
+	}
+	if rec := serve(t, handler, http.MethodGet, "/api/proxy?examples=maybe", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid examples status = %d, want 400", rec.Code)
+	}
+}
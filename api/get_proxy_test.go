@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+	"proxy_pool/testutil"
+
+	"gorm.io/gorm"
+)
+
+// createTestProxy 创建一个可用的代理并写入数据库，modify 可在写入前修改字段
+func createTestProxy(t *testing.T, db *gorm.DB, ip string, modify ...func(*models.Proxy)) *models.Proxy {
+	t.Helper()
+
+	p := &models.Proxy{
+		IP: ip, Port: 8080, Type: models.ProxyTypeTemp, Protocol: "http",
+		Region: models.ProxyRegionOther, Source: "test", Available: true,
+		Success: 9, Failure: 1, Score: 80,
+	}
+	for _, fn := range modify {
+		fn(p)
+	}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatalf("create proxy %s: %v", ip, err)
+	}
+	return p
+}
+
+// deadlineScheduler 记录调度时 ctx 剩余的时间
+type deadlineScheduler struct {
+	*testutil.MockScheduler
+
+	mu        sync.Mutex
+	remaining []time.Duration
+}
+
+func (s *deadlineScheduler) ScheduleProxy(ctx context.Context, task *core.Task) (*models.Proxy, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.mu.Lock()
+		s.remaining = append(s.remaining, time.Until(deadline))
+		s.mu.Unlock()
+	}
+	return s.MockScheduler.ScheduleProxy(ctx, task)
+}
+
+func TestGetProxyParsesTaskParams(t *testing.T) {
+	mock := &testutil.MockScheduler{Proxy: &models.Proxy{IP: "1.1.1.1", Port: 8080, Protocol: "http", Type: models.ProxyTypeTemp}}
+	handler := newTestServer(t, core.WithScheduler(mock)).engine()
+
+	rec := serve(t, handler, http.MethodGet, "/api/proxy?type=long&strategy=random&min_speed=800&timeout=5&retry_count=2&require_anon=true&region=cn", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get proxy status = %d: %s", rec.Code, rec.Body.String())
+	}
+	tasks := mock.Tasks()
+	if len(tasks) != 1 {
+		t.Fatalf("scheduled tasks = %d, want 1", len(tasks))
+	}
+	task := tasks[0]
+	if task.ProxyType != models.ProxyTypeLong || task.Strategy != core.StrategyRandom || task.MinSpeed != 800 ||
+		task.Timeout != 5*time.Second || task.RetryCount != 2 || !task.RequireAnon || task.Region != models.ProxyRegionCN {
+		t.Errorf("parsed task = %+v", task)
+	}
+
+	// 未指定时使用默认值
+	if rec := serve(t, handler, http.MethodGet, "/api/proxy", nil); rec.Code != http.StatusOK {
+		t.Fatalf("get proxy with defaults status = %d: %s", rec.Code, rec.Body.String())
+	}
+	task = mock.Tasks()[1]
+	if task.ProxyType != models.ProxyTypeTemp || task.Strategy != core.StrategyWeighted || task.Timeout != 10*time.Second || task.MinSpeed != 0 {
+		t.Errorf("default task = %+v", task)
+	}
+}
+
+func TestGetProxyRejectsInvalidTaskParams(t *testing.T) {
+	mock := &testutil.MockScheduler{Proxy: &models.Proxy{IP: "1.1.1.1", Port: 8080}}
+	handler := newTestServer(t, core.WithScheduler(mock)).engine()
+
+	for _, query := range []string{
+		"type=forever",
+		"strategy=fastest",
+		"min_speed=fast",
+		"timeout=10s",
+		"timeout=-1",
+		"retry_count=many",
+		"region=mars",
+		"exclude_recent=1h",
+		"verify=maybe",
+	} {
+		rec := serve(t, handler, http.MethodGet, "/api/proxy?"+query, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+	if tasks := mock.Tasks(); len(tasks) != 0 {
+		t.Errorf("scheduled %d tasks for invalid requests, want 0", len(tasks))
+	}
+}
+
+func TestGetProxyClampsTimeout(t *testing.T) {
+	scheduler := &deadlineScheduler{MockScheduler: &testutil.MockScheduler{Proxy: &models.Proxy{IP: "1.1.1.1", Port: 8080}}}
+	handler := newTestServer(t, core.WithScheduler(scheduler)).engine()
+
+	for _, query := range []string{"timeout=120", "timeout=3600", "timeout=30"} {
+		if rec := serve(t, handler, http.MethodGet, "/api/proxy?"+query, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", query, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := serve(t, handler, http.MethodGet, "/api/proxy?timeout=2", nil); rec.Code != http.StatusOK {
+		t.Fatalf("timeout=2: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if len(scheduler.remaining) != 4 {
+		t.Fatalf("scheduled with deadline %d times, want 4", len(scheduler.remaining))
+	}
+	// 超过上限的超时按 MaxSchedulingTimeout 调度
+	for i, remaining := range scheduler.remaining[:3] {
+		if remaining > core.MaxSchedulingTimeout || remaining < core.MaxSchedulingTimeout-5*time.Second {
+			t.Errorf("request %d: scheduling deadline in %s, want about %s", i, remaining, core.MaxSchedulingTimeout)
+		}
+	}
+	if remaining := scheduler.remaining[3]; remaining > 2*time.Second {
+		t.Errorf("timeout=2: scheduling deadline in %s, want at most 2s", remaining)
+	}
+}
+
+func TestGetProxyFiltersByRegionProtocolSourceAndScore(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	createTestProxy(t, db, "1.1.1.1", func(p *models.Proxy) { p.Score = 95 })
+	createTestProxy(t, db, "2.2.2.2", func(p *models.Proxy) { p.Region = models.ProxyRegionCN; p.Score = 60 })
+	createTestProxy(t, db, "3.3.3.3", func(p *models.Proxy) { p.Protocol = "socks5"; p.Source = "geonode"; p.Score = 70 })
+	handler := s.engine()
+
+	tests := []struct {
+		query string
+		ip    string
+	}{
+		{"region=cn", "2.2.2.2"},
+		{"protocol=socks5", "3.3.3.3"},
+		{"source=geonode", "3.3.3.3"},
+		{"min_score=90", "1.1.1.1"},
+		{"region=other&protocol=http", "1.1.1.1"},
+	}
+	for _, tt := range tests {
+		rec := serve(t, handler, http.MethodGet, "/api/proxy?"+tt.query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.query, rec.Code, rec.Body)
+		}
+		var got proxyResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: decode: %v", tt.query, err)
+		}
+		if got.IP != tt.ip {
+			t.Errorf("%s: got proxy %s, want %s", tt.query, got.IP, tt.ip)
+		}
+	}
+
+	// 没有满足条件的代理时返回404，并附带调度时使用的过滤条件
+	rec := serve(t, handler, http.MethodGet, "/api/proxy?region=cn&source=geonode&min_score=50", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unmatched filters: status = %d, want 404: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Code    ErrorCode `json:"code"`
+		Details struct {
+			Filters models.ProxyFilter `json:"filters"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	filters := body.Details.Filters
+	if body.Code != CodeNoProxyAvailable || filters.Region != models.ProxyRegionCN || filters.Source != "geonode" || filters.MinScore != 50 {
+		t.Errorf("404 body = %s, want the requested filters", rec.Body)
+	}
+}
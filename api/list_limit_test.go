@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestListEndpointLimits(t *testing.T) {
+	s := newTestServer(t)
+	s.SetMaxListLimit(3)
+	db := s.proxyPool.DB()
+
+	// 5个检查多次且即将过期的临时代理
+	for i := 1; i <= 5; i++ {
+		p := &models.Proxy{
+			IP: fmt.Sprintf("1.1.1.%d", i), Port: 8080, Type: models.ProxyTypeTemp, Protocol: "http",
+			Region: models.ProxyRegionOther, Source: "test", Available: true,
+			Success: 10, Failure: 0, Score: 80, MaxConcurrent: 10,
+		}
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("create proxy: %v", err)
+		}
+	}
+	// 创建时最后检查时间设为当前时间，之后再改为25分钟前
+	db.Model(&models.Proxy{}).Where("1 = 1").UpdateColumn("last_check", time.Now().Add(-25*time.Minute))
+	handler := s.engine()
+
+	tests := []struct {
+		target string
+		status int
+		count  int
+	}{
+		{"/api/proxies/candidates", http.StatusOK, 3},
+		{"/api/proxies/candidates?n=2", http.StatusOK, 2},
+		{"/api/proxies/candidates?n=4", http.StatusBadRequest, 0},
+		{"/api/proxies/candidates?n=x", http.StatusBadRequest, 0},
+		{"/api/proxies/near-expiry?window=10m", http.StatusOK, 3},
+		{"/api/proxies/near-expiry?window=10m&limit=1", http.StatusOK, 1},
+		{"/api/proxies/near-expiry?window=10m&limit=4", http.StatusBadRequest, 0},
+		{"/api/proxies/ha", http.StatusOK, 3},
+		{"/api/proxies/ha?limit=2", http.StatusOK, 2},
+		{"/api/proxies/ha?limit=4", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(t, handler, http.MethodGet, tt.target, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var items []json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(items) != tt.count {
+				t.Errorf("items = %d, want %d", len(items), tt.count)
+			}
+		})
+	}
+}
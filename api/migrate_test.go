@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"proxy_pool/core"
+)
+
+func TestMigrateFromRedisRunsInBackground(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.engine()
+
+	if rec := serveJSON(t, handler, http.MethodPost, "/api/admin/migrate/redis", `{"key": "legacy", "format": "list"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid format status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+
+	// 键不存在时迁移立即结束
+	rec := serveJSON(t, handler, http.MethodPost, "/api/admin/migrate/redis", `{"key": "legacy"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+	var status core.MigrateStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode start response: %v", err)
+	}
+	if status.Key != "legacy" || status.Format != core.MigrateFormatSet {
+		t.Errorf("start response = %+v, want key legacy with default format set", status)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		rec = serve(t, handler, http.MethodGet, "/api/admin/migrate/redis", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		if !status.Running {
+			break
+		}
+	}
+	if status.Running || status.FinishedAt.IsZero() || status.Scanned != 0 {
+		t.Errorf("final status = %+v, want finished with nothing scanned", status)
+	}
+
+	if rec := serve(t, handler, http.MethodDelete, "/api/admin/migrate/redis", nil); rec.Code != http.StatusOK {
+		t.Errorf("cancel idle migration status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+	"proxy_pool/testutil"
+)
+
+func TestGetProxyWithMockScheduler(t *testing.T) {
+	mock := &testutil.MockScheduler{Proxy: &models.Proxy{IP: "1.1.1.1", Port: 8080, Protocol: "http", Type: models.ProxyTypeTemp}}
+	mock.Proxy.ID = 42
+	s := newTestServer(t, core.WithScheduler(mock))
+	if s.proxyPool.Scheduler() != core.Scheduler(mock) {
+		t.Fatal("WithScheduler did not replace the scheduler")
+	}
+	handler := s.engine()
+
+	// 数据库中没有代理，返回调度器给出的代理
+	rec := serve(t, handler, http.MethodGet, "/api/proxy?strategy=leastused&min_score=50", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get proxy status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		ID uint   `json:"id"`
+		IP string `json:"ip"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ID != 42 || got.IP != "1.1.1.1" {
+		t.Errorf("get proxy = %+v, want proxy 42 at 1.1.1.1", got)
+	}
+	tasks := mock.Tasks()
+	if len(tasks) != 1 || tasks[0].Strategy != core.StrategyLeastUsed || tasks[0].MinScore != 50 {
+		t.Errorf("scheduled tasks = %+v, want one leastused task with min score 50", tasks)
+	}
+
+	rec = serveJSON(t, handler, http.MethodPost, "/api/proxy/"+strconv.Itoa(42)+"/status", `{"success": false, "speed": 300, "error_msg": "timeout"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("report status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if reports := mock.Reports(); len(reports) != 1 || reports[0].ProxyID != 42 || reports[0].Success || reports[0].ErrorMsg != "timeout" {
+		t.Errorf("reports = %+v, want one failure for proxy 42", reports)
+	}
+
+	// 调度器没有代理时按无代理处理
+	mock.Proxy = nil
+	if rec := serve(t, handler, http.MethodGet, "/api/proxy", nil); rec.Code == http.StatusOK {
+		t.Errorf("get proxy without scheduled proxy status = %d, want an error", rec.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestSweepOrphansEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	proxy := createTestProxy(t, db, "1.1.1.1")
+	if err := models.AddProxyTags(db, proxy.ID, []string{"a", "b"}); err != nil {
+		t.Fatalf("add tags: %v", err)
+	}
+	db.Delete(proxy)
+	handler := s.engine()
+
+	sweep := func(target string) models.OrphanSweepResult {
+		t.Helper()
+		rec := serve(t, handler, http.MethodPost, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var result models.OrphanSweepResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return result
+	}
+
+	if result := sweep("/api/jobs/sweep-orphans?dry_run=true"); !result.DryRun || result.Tables["proxy_tags"] != 2 {
+		t.Errorf("dry run = %+v, want 2 orphan tags counted", result)
+	}
+	if result := sweep("/api/jobs/sweep-orphans"); result.DryRun || result.Total != 2 {
+		t.Errorf("sweep = %+v, want 2 orphan tags deleted", result)
+	}
+	if result := sweep("/api/jobs/sweep-orphans?dry_run=true"); result.Total != 0 {
+		t.Errorf("dry run after sweep = %+v, want none left", result)
+	}
+	if rec := serve(t, handler, http.MethodPost, "/api/jobs/sweep-orphans?dry_run=maybe", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid dry_run status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestDeleteProxiesSkipsPinned(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	plain := createTestProxy(t, db, "1.1.1.1")
+	pinned := createTestProxy(t, db, "1.1.1.2")
+	handler := s.engine()
+
+	rec := serveJSON(t, handler, http.MethodPatch, "/api/proxy/"+strconv.Itoa(int(pinned.ID)), `{"pinned": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch status = %d: %s", rec.Code, rec.Body)
+	}
+	var patched proxyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &patched); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !patched.Pinned {
+		t.Error("patched proxy is not pinned")
+	}
+
+	type deleteResult struct {
+		Deleted       int `json:"deleted"`
+		SkippedPinned int `json:"skipped_pinned"`
+	}
+	remove := func(target string) deleteResult {
+		t.Helper()
+		rec := serve(t, handler, http.MethodDelete, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var got deleteResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return got
+	}
+
+	if got := remove("/api/proxies?cidr=1.1.1.0/24"); got != (deleteResult{Deleted: 1, SkippedPinned: 1}) {
+		t.Errorf("delete = %+v, want 1 deleted and 1 pinned skipped", got)
+	}
+	var count int64
+	db.Model(&models.Proxy{}).Where("id = ?", plain.ID).Count(&count)
+	if count != 0 {
+		t.Error("unpinned proxy still present")
+	}
+	if got := remove("/api/proxies?cidr=1.1.1.0/24&include_pinned=true"); got != (deleteResult{Deleted: 1}) {
+		t.Errorf("delete with include_pinned = %+v, want the pinned proxy deleted", got)
+	}
+	if rec := serve(t, handler, http.MethodDelete, "/api/proxies?cidr=1.1.1.0/24&include_pinned=maybe", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid include_pinned status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestProxyAgeInResponsesAndFilter(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	fresh := createTestProxy(t, db, "1.1.1.1")
+	old := createTestProxy(t, db, "2.2.2.2")
+	db.Model(old).UpdateColumn("created_at", time.Now().Add(-48*time.Hour))
+	handler := s.engine()
+
+	list := func(target string) map[string]float64 {
+		t.Helper()
+		rec := serve(t, handler, http.MethodGet, target, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var items []proxyResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+			t.Fatalf("%s: decode: %v", target, err)
+		}
+		ages := make(map[string]float64, len(items))
+		for _, item := range items {
+			ages[item.IP] = item.AgeHours
+		}
+		return ages
+	}
+
+	ages := list("/api/proxies")
+	if len(ages) != 2 || ages[fresh.IP] > 0.1 || math.Abs(ages[old.IP]-48) > 0.1 {
+		t.Errorf("age_hours = %v, want about 0 and 48", ages)
+	}
+	if ages := list("/api/proxies?max_age_hours=24"); len(ages) != 1 || ages[fresh.IP] > 0.1 {
+		t.Errorf("max_age_hours=24 returned %v, want only %s", ages, fresh.IP)
+	}
+	if rec := serve(t, handler, http.MethodGet, "/api/proxies?max_age_hours=-1", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative max_age_hours status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"proxy_pool/core"
+	siteconfig "proxy_pool/core/config"
+)
+
+func TestGetProxyRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	proxy := createTestProxy(t, s.proxyPool.DB(), "1.1.1.1")
+	s.proxyPool.RegisterSiteConfig("shop.test", &siteconfig.SiteConfig{
+		Name:           "shop",
+		ShortTermLimit: 5,
+		ShortTermTTL:   time.Minute,
+		LongTermLimit:  50,
+		LongTermTTL:    time.Hour,
+	})
+	if _, err := s.proxyPool.RateLimitProxy(context.Background(), proxy.ID, "shop.test"); err != nil {
+		t.Fatalf("RateLimitProxy: %v", err)
+	}
+	handler := s.engine()
+	path := "/api/proxy/" + strconv.Itoa(int(proxy.ID)) + "/ratelimit/"
+
+	rec := serve(t, handler, http.MethodGet, path+"shop.test", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var quota core.RateLimitQuota
+	if err := json.Unmarshal(rec.Body.Bytes(), &quota); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if quota.ProxyID != proxy.ID || quota.Site != "shop" || quota.ShortTerm.Remaining != 4 || quota.LongTerm.Remaining != 49 {
+		t.Errorf("quota = %+v, want one use counted in both windows", quota)
+	}
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{path + "other.test", http.StatusNotFound},
+		{"/api/proxy/999/ratelimit/shop.test", http.StatusNotFound},
+		{"/api/proxy/abc/ratelimit/shop.test", http.StatusBadRequest},
+	} {
+		if rec := serve(t, handler, http.MethodGet, tt.path, nil); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestReportProxyStatusRecordsTarget(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	proxy := createTestProxy(t, db, "1.1.1.1")
+	handler := s.engine()
+	path := "/api/proxy/" + strconv.Itoa(int(proxy.ID)) + "/status"
+
+	rec := serveJSON(t, handler, http.MethodPost, path, `{"success": false, "speed": 1200, "target_url": "https://Example.com/a?b=1", "error_msg": "403"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("report status = %d: %s", rec.Code, rec.Body)
+	}
+	var usage models.ProxyUsage
+	if err := db.Where("proxy_id = ?", proxy.ID).First(&usage).Error; err != nil {
+		t.Fatalf("load usage: %v", err)
+	}
+	if usage.Success || usage.Speed != 1200 || usage.TargetURL != "https://Example.com/a?b=1" || usage.Domain != "example.com" || usage.ErrorMsg != "403" {
+		t.Errorf("usage = %+v, want the reported failure on example.com", usage)
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		status     int
+	}{
+		{path, `{"success": true, "speed": -1}`, http.StatusUnprocessableEntity},
+		{path, `{"success": "yes"}`, http.StatusBadRequest},
+		{"/api/proxy/abc/status", `{"success": true}`, http.StatusBadRequest},
+	} {
+		if rec := serveJSON(t, handler, http.MethodPost, tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.path, tt.body, rec.Code, tt.status)
+		}
+	}
+	var count int64
+	db.Model(&models.ProxyUsage{}).Where("proxy_id = ?", proxy.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("usage records = %d, want only the valid report", count)
+	}
+}
+
+func TestReportedStreaksInProxyDetail(t *testing.T) {
+	s := newTestServer(t)
+	proxy := createTestProxy(t, s.proxyPool.DB(), "1.1.1.1")
+	handler := s.engine()
+	path := "/api/proxy/" + strconv.Itoa(int(proxy.ID))
+
+	streak := func() (int, int) {
+		t.Helper()
+		rec := serve(t, handler, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("detail status = %d: %s", rec.Code, rec.Body)
+		}
+		var detail proxyResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return detail.ConsecutiveSuccess, detail.ConsecutiveFailure
+	}
+
+	for _, body := range []string{`{"success": true}`, `{"success": true}`} {
+		serveJSON(t, handler, http.MethodPost, path+"/status", body)
+	}
+	if succ, fail := streak(); succ != 2 || fail != 0 {
+		t.Errorf("after two successes streak = %d/%d, want 2/0", succ, fail)
+	}
+	serveJSON(t, handler, http.MethodPost, path+"/status", `{"success": false}`)
+	if succ, fail := streak(); succ != 0 || fail != 1 {
+		t.Errorf("after a failure streak = %d/%d, want 0/1", succ, fail)
+	}
+
+	if rec := serve(t, handler, http.MethodGet, "/api/proxy/999", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown proxy status = %d, want 404", rec.Code)
+	}
+}
+
+func TestReportedErrorFilterAndClear(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	failing := createTestProxy(t, db, "1.1.1.1")
+	createTestProxy(t, db, "2.2.2.2")
+	handler := s.engine()
+	path := "/api/proxy/" + strconv.Itoa(int(failing.ID))
+
+	withError := func() []proxyResponse {
+		t.Helper()
+		rec := serve(t, handler, http.MethodGet, "/api/proxies?has_error=true", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list status = %d: %s", rec.Code, rec.Body)
+		}
+		var proxies []proxyResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &proxies); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return proxies
+	}
+
+	serveJSON(t, handler, http.MethodPost, path+"/status", `{"success": false, "error_msg": "connection reset"}`)
+	if proxies := withError(); len(proxies) != 1 || proxies[0].ID != failing.ID ||
+		proxies[0].LastError != "connection reset" || proxies[0].LastErrorAt == nil {
+		t.Errorf("has_error=true = %+v, want the failed proxy with its reason", proxies)
+	}
+
+	// 成功上报后清空失败原因
+	serveJSON(t, handler, http.MethodPost, path+"/status", `{"success": true}`)
+	if proxies := withError(); len(proxies) != 0 {
+		t.Errorf("has_error=true after success = %d proxies, want none", len(proxies))
+	}
+	if rec := serve(t, handler, http.MethodGet, "/api/proxies?has_error=maybe", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("has_error=maybe status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+)
+
+func TestRuntimeConfigEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.engine()
+
+	rec := serveJSON(t, handler, http.MethodPut, "/api/config", `{"max_fail_count": 6}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, handler, http.MethodGet, "/api/config", nil)
+	var got core.RuntimeConfig
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.MaxFailCount != 6 || got.ValidatorTimeoutSeconds != core.DefaultValidatorTimeout.Seconds() {
+		t.Errorf("config = %+v, want max_fail_count 6 and the other fields unchanged", got)
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"db_dsn": "other"}`, http.StatusBadRequest},
+		{`{"unknown": 1}`, http.StatusBadRequest},
+		{`{"max_fail_count": -1}`, http.StatusUnprocessableEntity},
+	} {
+		if rec := serveJSON(t, handler, http.MethodPut, "/api/config", tt.body); rec.Code != tt.status {
+			t.Errorf("PUT %s status = %d, want %d", tt.body, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestProxyDetailReportsMaxFailCount(t *testing.T) {
+	s := newTestServer(t)
+	temp := createTestProxy(t, s.proxyPool.DB(), "1.1.1.1")
+	long := createTestProxy(t, s.proxyPool.DB(), "1.1.1.2", func(p *models.Proxy) { p.Type = models.ProxyTypeLong })
+	handler := s.engine()
+	if rec := serveJSON(t, handler, http.MethodPut, "/api/config", `{"max_fail_count": 5, "type_max_fail_count": {"temp": 2}}`); rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		proxy *models.Proxy
+		want  int
+	}{{temp, 2}, {long, 5}} {
+		rec := serve(t, handler, http.MethodGet, "/api/proxy/"+strconv.Itoa(int(tt.proxy.ID)), nil)
+		var detail struct {
+			MaxFailCount int `json:"max_fail_count"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if detail.MaxFailCount != tt.want {
+			t.Errorf("%s proxy max_fail_count = %d, want %d", tt.proxy.Type, detail.MaxFailCount, tt.want)
+		}
+	}
+}
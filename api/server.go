@@ -1,162 +1,2058 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"proxy_pool/core"
+	"proxy_pool/core/redact"
 	"proxy_pool/models"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// readyTimeout 就绪检查中数据库Ping的超时时间
+const readyTimeout = time.Second
+
+const (
+	defaultFetchSyncTimeout = 60 * time.Second // 同步获取代理的默认等待时间
+	maxFetchSyncTimeout     = 5 * time.Minute  // 同步获取代理的最长等待时间
+)
+
+const (
+	DefaultMaxListLimit   = 500 // 列表接口 limit 参数的默认上限，更多代理通过 /api/proxies/stream 导出
+	defaultProxyListLimit = 10  // 代理列表未指定 limit 时返回的代理数
 )
 
 // Server API服务器
 type Server struct {
-	proxyPool *core.ProxyPool
+	proxyPool    *core.ProxyPool
+	contributors []RouteContributor // 外部注册的路由
+	apiKey       string             // 需要鉴权的接口使用的API密钥，为空时这些接口不可用
+	apiKeys      []core.APIKey      // 带权限范围的API密钥，设置后 /api 下的接口按权限范围鉴权
+	keyLimiter   *keyLimiter        // 带权限范围的API密钥的请求限流
+
+	readHeaderTimeout time.Duration // 读取请求头的超时时间，0 表示不限制
+	writeTimeout      time.Duration // 写响应的超时时间，0 表示不限制
+	maxListLimit      int           // 列表接口 limit 参数的上限
+
+	mu        sync.Mutex
+	servers   []*http.Server // Start、RunMulti、RunWithTLSConfig 和 RedirectToHTTPS 启动的监听，Stop 时关闭
+	serveErrs chan error     // Start 启动的监听意外退出时的错误，只保留第一个
 }
 
 // NewServer 创建新的API服务器
-func NewServer(proxyPool *core.ProxyPool) *Server {
+func NewServer(proxyPool *core.ProxyPool, contributors ...RouteContributor) *Server {
 	return &Server{
-		proxyPool: proxyPool,
+		proxyPool:    proxyPool,
+		contributors: contributors,
+		keyLimiter:   newKeyLimiter(),
+		maxListLimit: DefaultMaxListLimit,
+		serveErrs:    make(chan error, 1),
+	}
+}
+
+// SetMaxListLimit 设置列表接口 limit 参数的上限，非正值表示使用默认值，需在启动之前调用
+func (s *Server) SetMaxListLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultMaxListLimit
+	}
+	s.maxListLimit = limit
+}
+
+// SetAPIKey 设置需要鉴权的接口使用的API密钥，需在 Run 之前调用
+func (s *Server) SetAPIKey(key string) {
+	s.apiKey = key
+}
+
+// SetTimeouts 设置读取请求头和写响应的超时时间，0 表示不限制，需在启动之前调用
+// 写超时需大于最长的请求处理时间，如同步获取代理最长等待5分钟
+func (s *Server) SetTimeouts(readHeader, write time.Duration) {
+	s.readHeaderTimeout = readHeader
+	s.writeTimeout = write
+}
+
+// newHTTPServer 创建使用服务器超时配置的 http.Server
+func (s *Server) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		WriteTimeout:      s.writeTimeout,
 	}
 }
 
+// AddContributor 添加路由注册者，需在 Run 之前调用
+func (s *Server) AddContributor(rc RouteContributor) {
+	s.contributors = append(s.contributors, rc)
+}
+
 // Run 启动API服务器
 func (s *Server) Run(addr string) error {
-	r := gin.Default()
+	return s.engine().Run(addr)
+}
+
+// RunTLS 以HTTPS启动API服务器
+func (s *Server) RunTLS(addr, certFile, keyFile string) error {
+	return s.engine().RunTLS(addr, certFile, keyFile)
+}
+
+// RunWithTLSConfig 使用自定义TLS配置以HTTPS启动API服务器，如客户端证书认证、指定加密套件
+// tlsConfig 中需包含证书；经 Stop 关闭时返回nil
+func (s *Server) RunWithTLSConfig(addr string, tlsConfig *tls.Config) error {
+	srv := s.newHTTPServer(addr, s.engine())
+	srv.TLSConfig = tlsConfig
+	s.mu.Lock()
+	s.servers = append(s.servers, srv)
+	s.mu.Unlock()
+	if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Start 在 addr 上启动API服务器后立即返回，监听失败时返回错误，可多次调用以监听多个地址
+// 通过 Stop 优雅关闭；启动后服务意外退出的错误通过 Err 获取
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+
+	s.serve(addr, ln)
+	return nil
+}
+
+// serve 在后台通过 ln 提供服务，意外退出的错误发送到 serveErrs
+func (s *Server) serve(addr string, ln net.Listener) {
+	srv := s.newHTTPServer(addr, s.engine())
+	s.mu.Lock()
+	s.servers = append(s.servers, srv)
+	s.mu.Unlock()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			select {
+			case s.serveErrs <- fmt.Errorf("serve %s: %w", addr, err):
+			default:
+			}
+		}
+	}()
+}
+
+// Err 返回 Start 启动的监听意外退出时的错误，Stop 正常关闭时不会收到错误
+func (s *Server) Err() <-chan error {
+	return s.serveErrs
+}
+
+// Stop 优雅关闭 Start、RunMulti、RunWithTLSConfig 和 RedirectToHTTPS 启动的所有监听：不再接受新连接，等待处理中的请求完成或 ctx 到期
+func (s *Server) Stop(ctx context.Context) error {
+	return s.Shutdown(ctx)
+}
+
+// RunMulti 在多个地址上同时启动API服务器，如分别监听 192.168.1.10:8080 和 [2001:db8::10]:8080
+// 先监听全部地址，有地址监听失败时关闭已建立的监听并返回所有监听错误；
+// 运行中任一监听出错时关闭其余监听，返回所有错误；全部监听经 Shutdown 关闭后返回nil
+func (s *Server) RunMulti(addrs ...string) error {
+	return s.runMulti(addrs, func(srv *http.Server, ln net.Listener) error {
+		return srv.Serve(ln)
+	})
+}
+
+// RunMultiTLS 在多个地址上同时以HTTPS启动API服务器
+func (s *Server) RunMultiTLS(certFile, keyFile string, addrs ...string) error {
+	return s.runMulti(addrs, func(srv *http.Server, ln net.Listener) error {
+		return srv.ServeTLS(ln, certFile, keyFile)
+	})
+}
+
+// runMulti 每个地址一个 http.Server，共用同一个路由
+func (s *Server) runMulti(addrs []string, serve func(*http.Server, net.Listener) error) error {
+	if len(addrs) == 0 {
+		return errors.New("no listen address")
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	var failed []error
+	for _, addr := range addrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("listen %s: %w", addr, err))
+			continue
+		}
+		listeners = append(listeners, ln)
+	}
+	if len(failed) > 0 {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		return errors.Join(failed...)
+	}
+
+	handler := s.engine()
+	errs := make(chan error, len(addrs))
+	servers := make([]*http.Server, 0, len(addrs))
+
+	s.mu.Lock()
+	for i, addr := range addrs {
+		srv := s.newHTTPServer(addr, handler)
+		s.servers = append(s.servers, srv)
+		servers = append(servers, srv)
+		ln := listeners[i]
+		go func() {
+			if err := serve(srv, ln); err != nil && err != http.ErrServerClosed {
+				errs <- fmt.Errorf("serve %s: %w", srv.Addr, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	s.mu.Unlock()
+
+	for range addrs {
+		err := <-errs
+		if err == nil {
+			continue
+		}
+		// 第一个错误出现时关闭其余监听，不留下只监听部分地址的服务
+		if len(failed) == 0 {
+			for _, srv := range servers {
+				srv.Close()
+			}
+		}
+		failed = append(failed, err)
+	}
+	return errors.Join(failed...)
+}
+
+// Shutdown 优雅关闭 Start、RunMulti、RunWithTLSConfig 和 RedirectToHTTPS 启动的所有监听，等待处理中的请求完成或 ctx 到期
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	servers := s.servers
+	s.servers = nil
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// RedirectToHTTPS 在 addr 上监听HTTP请求，并重定向到 httpsAddr 端口上的HTTPS地址
+// 阻塞直到监听出错或经 Stop 关闭，经 Stop 关闭时返回nil
+func (s *Server) RedirectToHTTPS(addr, httpsAddr string) error {
+	_, httpsPort, err := net.SplitHostPort(httpsAddr)
+	if err != nil {
+		return err
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+
+	srv := s.newHTTPServer(addr, handler)
+	s.mu.Lock()
+	s.servers = append(s.servers, srv)
+	s.mu.Unlock()
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("serve %s: %w", addr, err)
+	}
+	return nil
+}
 
-	// 注册路由
+// engine 创建并注册路由
+func (s *Server) engine() *gin.Engine {
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(accessLogFormatter), gin.Recovery())
 	s.registerRoutes(r)
+	return r
+}
 
-	return r.Run(addr)
+// accessLogFormatter 访问日志格式，与 gin 默认格式一致，但隐藏URL中的 api_key 等查询参数
+// 订阅客户端只能在URL中携带密钥，不能原样写入日志
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redact.URL(param.Path),
+		param.ErrorMessage,
+	)
 }
 
 // registerRoutes 注册路由
 func (s *Server) registerRoutes(r *gin.Engine) {
+	// Prometheus 指标，启用带权限范围的API密钥后需要 admin 权限
+	r.GET("/metrics", s.authorize, gin.WrapH(promhttp.Handler()))
+
 	api := r.Group("/api")
+	// 外部中间件在鉴权和内置路由之前注册，对所有 /api 路由生效
+	for _, rc := range s.contributors {
+		if mc, ok := rc.(MiddlewareContributor); ok {
+			mc.RegisterMiddleware(api)
+		}
+	}
+	api.Use(s.authorize)
 	{
 		// 获取代理
 		api.GET("/proxy", s.getProxy)
+		api.GET("/proxy/:id", s.getProxyDetail)
+		api.GET("/proxy/:id/ttl", s.getProxyTTL)
+		api.GET("/proxy/:id/ratelimit/:domain", s.getProxyRateLimit)
 		api.GET("/proxies", s.getProxies)
+		api.GET("/proxies/stream", s.streamProxies)
+		api.GET("/proxies/search", s.searchProxies)
+		api.GET("/proxies/candidates", s.getCandidates)
+		api.GET("/proxies/ha", s.getHighAvailabilityProxies)
+		api.GET("/proxies/near-expiry", s.getNearExpiryProxies)
+		api.GET("/leaderboard", s.getLeaderboard)
 
 		// 代理管理
 		api.POST("/proxy", s.addProxy)
+		api.POST("/proxies/import", s.importProxies)
 		api.PUT("/proxy/:id", s.updateProxy)
+		api.PATCH("/proxy/:id", s.patchProxy)
 		api.DELETE("/proxy/:id", s.deleteProxy)
+		api.DELETE("/proxies", s.deleteProxies)
 		api.POST("/proxy/:id/status", s.reportProxyStatus)
+		api.POST("/proxies/status", s.reportProxyStatuses)
+
+		// 代理信誉
+		api.GET("/proxy/:id/reputation", s.getReputation)
+		api.POST("/proxy/:id/reputation", s.reportReputation)
+
+		// 评分历史
+		api.GET("/proxy/:id/history", s.getProxyHistory)
+		api.GET("/proxy/:id/score-history", s.getScoreHistory)
+		api.GET("/proxy/:id/score-prediction", s.getScorePrediction)
+		api.POST("/proxy/:id/score-history/export", s.exportScoreHistory)
+		api.GET("/export", s.requireAPIKey, s.exportProxies)
+		api.GET("/export/subscription", s.requireAPIKey, s.exportSubscription)
+
+		// 就绪检查
+		api.GET("/ready", s.getReady)
 
 		// 代理池状态
 		api.GET("/stats", s.getStats)
+		api.GET("/status", s.getStatus)
+		api.GET("/status/live", s.getLiveStatus)
+		api.GET("/stats/realtime", s.getRealtimeStats)
+		api.GET("/stats/scores", s.getScoreDistribution)
+
+		// 调度审计
+		api.GET("/scheduler/decisions", s.getSchedulingDecisions)
+		api.GET("/scheduler/fairness", s.getSchedulerFairness)
+		api.GET("/scheduler/stats", s.getSchedulerStats)
+
+		// 标签
+		api.GET("/tags", s.getTags)
+		api.DELETE("/tags/:tag", s.deleteTag)
+		api.GET("/proxy/:id/tags", s.getProxyTags)
+		api.POST("/proxy/:id/tags", s.addProxyTags)
+		api.DELETE("/proxy/:id/tags/:tag", s.removeProxyTag)
+
+		// 代理分组
+		api.GET("/groups", s.getGroups)
+		api.POST("/groups", s.createGroup)
+		api.POST("/groups/:name/members", s.addGroupMembers)
+		api.DELETE("/groups/:name/members/:id", s.removeGroupMember)
+
+		// 后台任务
+		jobs := api.Group("/jobs")
+		{
+			jobs.GET("/cron", s.getCronJobs)
+			jobs.GET("/validate", s.getValidationJob)
+			jobs.GET("/fetch", s.getFetchJob)
+			jobs.POST("/validate", s.triggerValidationJob)
+			jobs.POST("/optimize", s.optimizePool)
+			jobs.POST("/sweep-orphans", s.sweepOrphans)
+		}
+
+		// 同步获取代理
+		api.POST("/fetch/sync", s.fetchSync)
+
+		// 代理源管理
+		api.GET("/sources", s.getSources)
+		api.GET("/sources/:name", s.getSource)
+		api.PUT("/sources/:name", s.updateSource)
+		api.DELETE("/sources/:name", s.disableSource)
+		api.GET("/sources/:name/protocols", s.getSourceProtocols)
+		api.GET("/conflicts", s.getConflicts)
+		api.DELETE("/conflicts", s.purgeConflicts)
+
+		// 运行时配置
+		api.GET("/config", s.getRuntimeConfig)
+		api.PUT("/config", s.updateRuntimeConfig)
+
+		// 管理接口
+		admin := api.Group("/admin")
+		{
+			admin.GET("/stale-count", s.getStaleCount)
+			admin.GET("/validator/config", s.getValidatorConfig)
+			admin.PUT("/validator/config", s.updateValidatorConfig)
+			admin.POST("/max-concurrent", s.bulkUpdateMaxConcurrent)
+			admin.POST("/migrate/redis", s.migrateFromRedis)
+			admin.GET("/migrate/redis", s.getRedisMigration)
+			admin.DELETE("/migrate/redis", s.cancelRedisMigration)
+		}
+	}
+
+	// 外部路由在内置路由之后注册，根路径下的路由与 /api 使用相同的鉴权
+	root := r.Group("/", s.authorize)
+	for _, rc := range s.contributors {
+		rc.RegisterRoutes(api)
+		if rrc, ok := rc.(RootRouteContributor); ok {
+			rrc.RegisterRootRoutes(root)
+		}
 	}
 }
 
 // getProxy 获取单个代理
+// 查询参数：
+//   - type: 代理类型，默认 temp
+//   - strategy: 调度策略，默认 weighted；random 不考虑评分均匀随机选择；roundrobin_cached 从内存缓存轮询，仅按 type 过滤
+//   - min_speed: 响应时间上限(毫秒)，0表示不限
+//   - timeout: 任务超时时间(秒)，默认10秒
+//   - retry_count: 重试次数
+//   - region/protocol/source: 地区、协议、来源过滤
+//   - min_score: 最低评分
+//   - min_success_rate: 最低成功率(百分比)
+//   - min_checks: 最少检查次数(成功+失败)
+//   - tag: 代理标签，只返回带有该标签的代理
+//   - group: 代理分组名称，只在分组成员中调度，评分下限取分组与 min_score 中较高的，分组设置了调度策略时覆盖 strategy
+//   - look_ahead: predictive 策略的预测时长，如 30m，默认30分钟
+//   - exclude: 排除的代理ID，逗号分隔
+//   - exclude_recent: 排除本客户端在该时长内获取过的代理，如 30s，最长10分钟；
+//     客户端由 X-Client-ID 请求头标识，未设置时使用客户端IP
+//   - verify: 为 true 时发放前通过代理访问 target_url 确认可用，失败时换下一个代理，最多尝试 retry_count+1 个；
+//     target_url 的域名需注册了站点配置或在白名单中，响应附带 verify_latency_ms 和 verify_attempts
+//   - fast: 为 true 时优先从每秒刷新的候选代理缓冲区获取，只检查排除、冷却和并发；
+//     只适用于 weighted 策略且未指定 tag、min_checks、high_reliability，缓冲区中没有合适的代理时按正常流程调度
+//   - high_reliability: 为 true 时优先调度检查次数和成功率达到高可用标准的代理（同 /api/proxies/ha 的默认条件），
+//     没有时按其他条件调度
+//   - examples: 为 true 时响应附带 curl、环境变量和 Python requests 的连接示例，目标地址为 target_url，未指定时为 https://example.com
 func (s *Server) getProxy(c *gin.Context) {
+	task, err := parseTask(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	verify, err := queryBool(c, "verify")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	fast, err := queryBool(c, "fast")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	task.Fast = fast != nil && *fast
+	highReliability, err := queryBool(c, "high_reliability")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	task.HighReliability = highReliability != nil && *highReliability
+	examples, err := queryBool(c, "examples")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	withExamples := examples != nil && *examples
+	group := c.Query("group")
+
+	if verify != nil && *verify {
+		if task.TargetURL == "" {
+			respondError(c, badRequest(errors.New("target_url is required when verify=true")))
+			return
+		}
+		task.Group = group
+		proxy, result, err := s.proxyPool.GetVerifiedProxyForTask(c.Request.Context(), task)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		resp := verifiedProxyResponse{
+			proxyResponse:   newProxyResponse(proxy),
+			VerifyLatencyMs: result.Latency.Milliseconds(),
+			VerifyAttempts:  result.Attempts,
+		}
+		resp.HandoutID = task.HandoutID
+		if withExamples {
+			resp.Examples = newConnectionExamples(proxy, task.TargetURL)
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var proxy *models.Proxy
+	if group != "" {
+		proxy, err = s.proxyPool.GetProxyFromGroup(c.Request.Context(), group, task)
+	} else {
+		proxy, err = s.proxyPool.GetProxyForTask(c.Request.Context(), task)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	resp := newProxyResponse(proxy)
+	resp.HandoutID = task.HandoutID
+	if withExamples {
+		resp.Examples = newConnectionExamples(proxy, task.TargetURL)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// verifiedProxyResponse 经发放前验证的代理响应
+type verifiedProxyResponse struct {
+	proxyResponse
+	VerifyLatencyMs int64 `json:"verify_latency_ms"`
+	VerifyAttempts  int   `json:"verify_attempts"`
+}
+
+// parseTask 从查询参数解析调度任务，获取代理和预览候选代理共用
+func parseTask(c *gin.Context) (*core.Task, error) {
+	proxyType := models.ProxyType(c.DefaultQuery("type", string(models.ProxyTypeTemp)))
+	if !proxyType.IsValid() {
+		return nil, fmt.Errorf("invalid type: %q", proxyType)
+	}
+
+	strategy := core.ScheduleStrategy(c.DefaultQuery("strategy", string(core.StrategyWeighted)))
+	if !strategy.IsValid() {
+		return nil, fmt.Errorf("invalid strategy: %q", strategy)
+	}
+
+	minSpeed, err := queryInt(c, "min_speed", 0)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := queryInt(c, "timeout", 10)
+	if err != nil {
+		return nil, err
+	}
+	retryCount, err := queryInt(c, "retry_count", 0)
+	if err != nil {
+		return nil, err
+	}
+	minScore, err := queryFloat(c, "min_score", 0)
+	if err != nil {
+		return nil, err
+	}
+	minSuccessRate, err := queryFloat(c, "min_success_rate", 0)
+	if err != nil {
+		return nil, err
+	}
+	minChecks, err := queryInt(c, "min_checks", 0)
+	if err != nil {
+		return nil, err
+	}
+	lookAhead, err := queryDuration(c, "look_ahead", 0)
+	if err != nil {
+		return nil, err
+	}
+	excludeIDs, err := queryIDs(c, "exclude")
+	if err != nil {
+		return nil, err
+	}
+	excludeRecent, err := queryDuration(c, "exclude_recent", 0)
+	if err != nil {
+		return nil, err
+	}
+	if excludeRecent > core.MaxExcludeRecentWindow {
+		return nil, fmt.Errorf("exclude_recent must not exceed %s", core.MaxExcludeRecentWindow)
+	}
+
+	region := models.ProxyRegion(c.Query("region"))
+	if region != "" && !region.IsValid() {
+		return nil, fmt.Errorf("invalid region: %q", region)
+	}
+
 	// 解析任务参数
 	task := &core.Task{
-		ProxyType:   models.ProxyType(c.DefaultQuery("type", string(models.ProxyTypeTemp))),
-		Strategy:    core.ScheduleStrategy(c.DefaultQuery("strategy", string(core.StrategyWeighted))),
-		RequireAnon: c.DefaultQuery("require_anon", "false") == "true",
-		MaxFailures: 3,
-		MinSpeed:    int64(c.GetInt("min_speed")),
-		TargetURL:   c.Query("target_url"),
-		Domain:      extractDomain(c.Query("target_url")), // 从目标URL中提取域名
-		RetryCount:  c.GetInt("retry_count"),
-	}
-
-	if timeout := c.GetInt("timeout"); timeout > 0 {
-		task.Timeout = time.Duration(timeout) * time.Second
-	} else {
+		ProxyType:         proxyType,
+		Strategy:          strategy,
+		RequireAnon:       c.DefaultQuery("require_anon", "false") == "true",
+		MaxFailures:       3,
+		MinSpeed:          int64(minSpeed),
+		TargetURL:         c.Query("target_url"),
+		Domain:            extractDomain(c.Query("target_url")), // 从目标URL中提取域名
+		RetryCount:        retryCount,
+		Timeout:           time.Duration(timeout) * time.Second,
+		Region:            region,
+		Protocol:          c.Query("protocol"),
+		Source:            c.Query("source"),
+		MinScore:          minScore,
+		MinSuccessRate:    minSuccessRate,
+		MinChecks:         minChecks,
+		LookAheadDuration: lookAhead,
+		Tag:               c.Query("tag"),
+		ExcludeIDs:        excludeIDs,
+		ClientID:          clientID(c),
+		ExcludeRecent:     excludeRecent,
+	}
+	if task.Timeout == 0 {
 		task.Timeout = 10 * time.Second
 	}
+	return task, nil
+}
+
+// proxyPageResponse 按ID游标分页的代理列表
+type proxyPageResponse struct {
+	Proxies    []proxyResponse `json:"proxies"`
+	NextCursor uint            `json:"next_cursor"` // 本页最后一个代理的ID，作为下一页的 cursor；没有下一页时为0
+}
+
+// getProxies 获取多个代理
+// 支持的过滤参数见 parseProxyFilter，tag 含 * 时按模式搜索，如 tag=us-*
+// 按标签模式或 tag_search 搜索时同样使用 type=temp、available=true 的默认值，查询长效或不可用代理需显式传入
+// limit 默认10，不大于0时使用默认值，超过上限时返回400
+// 传入 cursor 时按ID游标分页：按ID升序返回ID大于 cursor 的 limit 个代理及 next_cursor，cursor=0 从头开始；
+// 游标分页只支持 order=id
+func (s *Server) getProxies(c *gin.Context) {
+	filter, err := parseProxyFilter(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if filter.Limit, err = queryLimit(c, defaultProxyListLimit, s.maxListLimit); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if _, ok := c.GetQuery("cursor"); ok {
+		s.getProxyPage(c, filter)
+		return
+	}
+
+	proxies, err := s.proxyPool.ListProxies(filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	result := make([]proxyResponse, len(proxies))
+	for i := range proxies {
+		result[i] = newProxyResponse(&proxies[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// getProxyPage 按ID游标获取一页代理
+func (s *Server) getProxyPage(c *gin.Context, filter models.ProxyFilter) {
+	cursor, err := queryInt(c, "cursor", 0)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if order := c.Query("order"); order != "" && models.ProxyOrder(order) != models.OrderByID {
+		respondError(c, badRequest(fmt.Errorf("cursor pagination requires order=id, got %q", order)))
+		return
+	}
+
+	filter.Order = models.OrderByID
+	filter.AfterID = uint(cursor)
+	proxies, err := s.proxyPool.ListProxies(filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	page := proxyPageResponse{Proxies: make([]proxyResponse, len(proxies))}
+	for i := range proxies {
+		page.Proxies[i] = newProxyResponse(&proxies[i])
+	}
+	if len(proxies) == filter.Limit {
+		page.NextCursor = proxies[len(proxies)-1].ID
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// streamProxies 以换行分隔的JSON逐行输出所有符合条件的可用代理，不分页
+// 过滤参数同 /api/export
+func (s *Server) streamProxies(c *gin.Context) {
+	filter, err := parseListFilter(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	if err := s.proxyPool.ExportNDJSON(c.Writer, filter); err != nil {
+		// 响应头已发送，只能中断连接
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// candidateResponse 候选代理响应，附带调度权重
+type candidateResponse struct {
+	proxyResponse
+	CandidateWeight float64 `json:"candidate_weight"`
+}
+
+// getCandidates 按调度策略预览前 n 个候选代理，不占用代理，调用方自行挑选
+// 查询参数同 /api/proxy，n 默认5，最多50且不超过列表接口上限，超过时返回400
+func (s *Server) getCandidates(c *gin.Context) {
+	task, err := parseTask(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	n, err := queryLimitParam(c, "n", 5, min(s.maxListLimit, core.MaxScheduleCandidates))
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	candidates, err := s.proxyPool.Scheduler().RankCandidates(task, n)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	result := make([]candidateResponse, len(candidates))
+	for i, candidate := range candidates {
+		result[i] = candidateResponse{
+			proxyResponse:   newProxyResponse(candidate.Proxy),
+			CandidateWeight: candidate.Weight,
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// getNearExpiryProxies 获取剩余有效时长不足 window 但尚未过期的可用代理，按剩余有效时长升序
+// 查询参数：
+//   - window: 即将过期的判断时长，如 5m，默认5分钟
+//   - limit: 返回数量，默认100，超过上限时返回400
+func (s *Server) getNearExpiryProxies(c *gin.Context) {
+	window, err := queryDuration(c, "window", core.DefaultNearExpiryWindow)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	limit, err := queryLimit(c, 100, s.maxListLimit)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxies, err := models.GetProxiesNearExpiryLimit(s.proxyPool.DB(), window, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newProxyResponses(proxies))
+}
+
+// getHighAvailabilityProxies 获取经多次检查确认可用的代理，按评分降序
+// 查询参数：
+//   - min_checks: 最少检查次数(成功+失败)，默认5
+//   - min_success_rate: 最低成功率(百分比)，默认80
+//   - limit: 返回数量，默认100，超过上限时返回400
+func (s *Server) getHighAvailabilityProxies(c *gin.Context) {
+	minChecks, err := queryInt(c, "min_checks", models.DefaultHAMinChecks)
+	if err != nil || minChecks < 1 {
+		respondError(c, badRequest(fmt.Errorf("invalid min_checks: %q, must be positive", c.Query("min_checks"))))
+		return
+	}
+	minSuccessRate, err := queryFloat(c, "min_success_rate", models.DefaultHAMinSuccessRate)
+	if err != nil || minSuccessRate < 0 || minSuccessRate > 100 {
+		respondError(c, badRequest(fmt.Errorf("invalid min_success_rate: %q, must be within [0, 100]", c.Query("min_success_rate"))))
+		return
+	}
+
+	limit, err := queryLimit(c, 100, s.maxListLimit)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxies, err := models.GetHighAvailabilityProxies(s.proxyPool.DB(), minChecks, minSuccessRate, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newProxyResponses(proxies))
+}
+
+// getLeaderboard 获取评分最高的代理，过滤参数同 getProxies，排序固定为评分
+func (s *Server) getLeaderboard(c *gin.Context) {
+	filter, err := parseProxyFilter(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	filter.Order = models.OrderByScore
+	if filter.Limit, err = queryLimit(c, defaultProxyListLimit, s.maxListLimit); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxies, err := s.proxyPool.ListProxies(filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	result := make([]proxyResponse, len(proxies))
+	for i := range proxies {
+		result[i] = newProxyResponse(&proxies[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// searchProxies 按网段或IP前缀查找代理
+// 查询参数：cidr(如 1.2.3.0/24) 或 ip_prefix(如 1.2.3.)，limit 默认100，超过上限时返回400
+func (s *Server) searchProxies(c *gin.Context) {
+	r, err := models.ParseIPRange(c.Query("cidr"), c.Query("ip_prefix"))
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	limit, err := queryLimit(c, 100, s.maxListLimit)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxies, err := s.proxyPool.SearchByIPRange(r, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newProxyResponses(proxies))
+}
+
+// deleteProxies 批量删除代理，必须指定 cidr 或 ip_prefix
+// 默认跳过固定代理，include_pinned=true 时一并删除
+func (s *Server) deleteProxies(c *gin.Context) {
+	r, err := models.ParseIPRange(c.Query("cidr"), c.Query("ip_prefix"))
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	includePinned, err := queryBool(c, "include_pinned")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	deleted, skipped, err := s.proxyPool.RemoveProxiesInRange(r, includePinned != nil && *includePinned)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted, "skipped_pinned": skipped})
+}
+
+// getProxyDetail 获取代理详情，包含连续成功/失败次数和最近一次验证通过的测试网站、状态码、耗时
+func (s *Server) getProxyDetail(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxy, err := models.FindByID(s.proxyPool.DB(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, proxyDetailResponse{
+		proxyResponse: newProxyResponse(proxy),
+		MaxFailCount:  s.proxyPool.FailPolicy().MaxFailCountForProxy(proxy),
+	})
+}
+
+// proxyDetailResponse 代理详情响应，附带代理实际使用的最大失败次数
+type proxyDetailResponse struct {
+	proxyResponse
+	MaxFailCount int `json:"max_fail_count"` // 按代理源清理策略、代理类型和默认值依次确定
+}
+
+// proxyResponse 代理响应，附带代理年龄和剩余有效时长
+type proxyResponse struct {
+	*models.Proxy
+	AgeHours   float64             `json:"age_hours"`
+	TTLSeconds float64             `json:"ttl_seconds"`          // 需要重新验证前的剩余有效时长(秒)
+	Examples   *connectionExamples `json:"examples,omitempty"`   // 连接示例，获取代理时指定 examples=true 才返回
+	HandoutID  string              `json:"handout_id,omitempty"` // 发放ID，上报使用结果时带上以关联本次获取
+}
+
+func newProxyResponse(proxy *models.Proxy) proxyResponse {
+	return proxyResponse{
+		Proxy:      proxy,
+		AgeHours:   proxy.AgeHours(),
+		TTLSeconds: proxy.EstimatedTTL().Seconds(),
+	}
+}
+
+// newProxyResponses 转换代理列表
+func newProxyResponses(proxies []*models.Proxy) []proxyResponse {
+	result := make([]proxyResponse, len(proxies))
+	for i, proxy := range proxies {
+		result[i] = newProxyResponse(proxy)
+	}
+	return result
+}
+
+// getProxyTTL 获取代理需要重新验证前的剩余有效时长，不返回代理详情
+func (s *Server) getProxyTTL(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxy, err := models.FindByID(s.proxyPool.DB(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"proxy_id":    proxy.ID,
+		"ttl_seconds": proxy.EstimatedTTL().Seconds(),
+		"expires_at":  proxy.ExpiresAt,
+	})
+}
+
+// getProxyRateLimit 获取代理在域名上短期和长期窗口的剩余使用次数
+func (s *Server) getProxyRateLimit(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxy, err := models.FindByID(s.proxyPool.DB(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	quota, err := s.proxyPool.RateLimitQuota(proxy.ID, c.Param("domain"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, quota)
+}
+
+// parseProxyFilter 从查询参数解析代理过滤条件
+// 支持 type、region、protocol、source、tag、tag_search、min_score、min_success_rate、min_checks、max_speed、max_age_hours、anonymous、
+// verified_https、has_error、available(默认true)、exclude(逗号分隔的ID)、order，limit 由调用方按上限解析
+// tag 含 * 时按前缀模式匹配，如 tag=us-*；tag_search 按全文索引搜索标签，仅MySQL支持
+func parseProxyFilter(c *gin.Context) (models.ProxyFilter, error) {
+	filter := models.ProxyFilter{
+		Type:      models.ProxyType(c.DefaultQuery("type", string(models.ProxyTypeTemp))),
+		Region:    models.ProxyRegion(c.Query("region")),
+		Protocol:  c.Query("protocol"),
+		Source:    c.Query("source"),
+		Tag:       c.Query("tag"),
+		TagSearch: c.Query("tag_search"),
+		Order:     models.ProxyOrder(c.DefaultQuery("order", string(models.OrderByScore))),
+	}
+	if strings.Contains(filter.Tag, "*") {
+		filter.TagPattern, filter.Tag = filter.Tag, ""
+	}
+
+	if !filter.Type.IsValid() {
+		return filter, fmt.Errorf("invalid type: %q", filter.Type)
+	}
+	if filter.Region != "" && !filter.Region.IsValid() {
+		return filter, fmt.Errorf("invalid region: %q", filter.Region)
+	}
+	if !filter.Order.IsValid() {
+		return filter, fmt.Errorf("invalid order: %q", filter.Order)
+	}
+
+	var err error
+	if filter.MinScore, err = queryFloat(c, "min_score", 0); err != nil {
+		return filter, err
+	}
+	if filter.MinSuccessRate, err = queryFloat(c, "min_success_rate", 0); err != nil {
+		return filter, err
+	}
+	if filter.MinChecks, err = queryInt(c, "min_checks", 0); err != nil {
+		return filter, err
+	}
+	maxSpeed, err := queryInt(c, "max_speed", 0)
+	if err != nil {
+		return filter, err
+	}
+	filter.MaxSpeed = int64(maxSpeed)
+	maxAgeHours, err := queryInt(c, "max_age_hours", 0)
+	if err != nil {
+		return filter, err
+	}
+	filter.MaxAge = time.Duration(maxAgeHours) * time.Hour
+
+	if filter.Anonymous, err = queryBool(c, "anonymous"); err != nil {
+		return filter, err
+	}
+	if filter.VerifiedHTTPS, err = queryBool(c, "verified_https"); err != nil {
+		return filter, err
+	}
+	if filter.HasError, err = queryBool(c, "has_error"); err != nil {
+		return filter, err
+	}
+	if filter.Available, err = queryBool(c, "available"); err != nil {
+		return filter, err
+	}
+	if filter.Available == nil {
+		filter.Available = models.Bool(true)
+	}
+
+	if filter.ExcludeIDs, err = queryIDs(c, "exclude"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// addProxyResponse 添加代理的响应
+type addProxyResponse struct {
+	proxyResponse
+	Created    bool             `json:"created"`              // 是否新建，false 表示更新了已有代理
+	Validation *proxyValidation `json:"validation,omitempty"` // validate=true 时的验证结果
+}
+
+// proxyValidation 单次验证的结果
+type proxyValidation struct {
+	Available  bool   `json:"available"`
+	Speed      int64  `json:"speed"`
+	ErrorClass string `json:"error_class,omitempty"`
+	SuccessURL string `json:"success_url,omitempty"`
+	StatusCode int    `json:"status_code"`
+}
+
+// addProxy 添加代理
+// 请求体可带 callback_url，代理首次验证完成后向该地址回调验证结果；未指定协议或协议为 auto 时先检测协议
+// 同IP端口的代理已存在时按重复策略返回409或更新已有代理(200)
+// 查询参数 validate=true 时同步验证代理，并在响应的 validation 中返回结果
+func (s *Server) addProxy(c *gin.Context) {
+	var req struct {
+		models.Proxy
+		CallbackURL string `json:"callback_url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	validate, err := queryBool(c, "validate")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if req.CallbackURL != "" {
+		if err := core.ValidateCallbackURL(req.CallbackURL); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	proxy, created, err := s.proxyPool.AddProxy(&req.Proxy)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if req.CallbackURL != "" {
+		if err := s.proxyPool.RegisterCallback(proxy.ID, req.CallbackURL); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	var validation *proxyValidation
+	if validate != nil && *validate {
+		if err := s.proxyPool.Validator().ValidateProxy(proxy); err != nil {
+			respondError(c, err)
+			return
+		}
+		validation = &proxyValidation{
+			Available:  proxy.Available,
+			Speed:      proxy.Speed,
+			ErrorClass: proxy.LastErrorClass,
+			SuccessURL: proxy.LastSuccessURL,
+			StatusCode: proxy.LastStatusCode,
+		}
+	}
+
+	// 验证会更新检查时间，在验证后计算剩余有效时长
+	resp := addProxyResponse{proxyResponse: newProxyResponse(proxy), Created: created, Validation: validation}
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	c.JSON(status, resp)
+}
+
+// updateProxy 更新代理
+func (s *Server) updateProxy(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	var proxy models.Proxy
+	proxy.ID = id
+
+	if err := c.ShouldBindJSON(&proxy); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if err := s.proxyPool.UpdateProxyStatus(&proxy, proxy.Available, proxy.Speed); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newProxyResponse(&proxy))
+}
+
+// importProxies 批量导入代理
+// 请求体：{"proxies": [{"ip": "1.2.3.4", "port": 8080, "tags": ["project-a"]}], "tags": ["imported"], "callback_url": "https://..."}
+// 外层 tags 追加到每个代理上，已存在的代理只追加标签；外层 callback_url 用于未单独指定回调地址的代理
+func (s *Server) importProxies(c *gin.Context) {
+	var req struct {
+		Proxies     []core.ImportProxy `json:"proxies" binding:"required,dive"`
+		Tags        []string           `json:"tags"`
+		CallbackURL string             `json:"callback_url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	result, err := s.proxyPool.ImportProxies(req.Proxies, req.Tags, req.CallbackURL)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// patchProxy 部分更新代理，支持 tags(替换全部标签)、whitelisted 和 pinned
+func (s *Server) patchProxy(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	var req struct {
+		Tags        *[]string `json:"tags"`
+		Whitelisted *bool     `json:"whitelisted"`
+		Pinned      *bool     `json:"pinned"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	db := s.proxyPool.DB()
+	proxy, err := models.FindByID(db, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if req.Tags != nil {
+		tags, err := models.NormalizeTags(*req.Tags)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if err := models.SetProxyTags(db, id, tags); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	if req.Whitelisted != nil {
+		if err := db.Model(proxy).UpdateColumn("whitelisted", *req.Whitelisted).Error; err != nil {
+			respondError(c, err)
+			return
+		}
+		proxy.Whitelisted = *req.Whitelisted
+	}
+
+	if req.Pinned != nil {
+		if err := db.Model(proxy).UpdateColumn("pinned", *req.Pinned).Error; err != nil {
+			respondError(c, err)
+			return
+		}
+		proxy.Pinned = *req.Pinned
+	}
+
+	c.JSON(http.StatusOK, newProxyResponse(proxy))
+}
+
+// deleteProxy 删除代理
+func (s *Server) deleteProxy(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if err := s.proxyPool.RemoveProxy(id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// reportProxyStatus 报告代理状态
+// 请求体：{"success": false, "speed": 1200, "target_url": "https://example.com/a", "error_msg": "403"}
+// target_url 用于按域名统计代理的使用结果
+func (s *Server) reportProxyStatus(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	var req struct {
+		Success   bool   `json:"success"`
+		Speed     int64  `json:"speed"`
+		TargetURL string `json:"target_url"`
+		ErrorMsg  string `json:"error_msg"`
+		HandoutID string `json:"handout_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if req.Speed < 0 {
+		respondError(c, validationFailed(fmt.Errorf("invalid speed: %d", req.Speed)))
+		return
+	}
+	if req.Success && req.ErrorMsg != "" {
+		s.proxyPool.Logger().Warn("代理使用报告为成功但带有错误信息",
+			zap.Uint("代理ID", id),
+			zap.String("错误信息", req.ErrorMsg),
+		)
+	}
+
+	err = s.proxyPool.ReportProxyStatus(id, core.StatusReport{
+		Success:   req.Success,
+		Speed:     req.Speed,
+		TargetURL: req.TargetURL,
+		Domain:    models.NormalizeDomain(extractDomain(req.TargetURL)),
+		ErrorMsg:  req.ErrorMsg,
+		HandoutID: req.HandoutID,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// reportProxyStatuses 批量报告代理使用状态
+// 请求体为数组：[{"proxy_id": 1, "success": true, "speed": 120, "target_url": "...", "error_msg": "", "handout_id": "..."}]
+// 单条不合法或代理不存在不影响其他条目，结果按请求顺序返回
+func (s *Server) reportProxyStatuses(c *gin.Context) {
+	var req []struct {
+		ProxyID   uint   `json:"proxy_id"`
+		Success   bool   `json:"success"`
+		Speed     int64  `json:"speed"`
+		TargetURL string `json:"target_url"`
+		ErrorMsg  string `json:"error_msg"`
+		HandoutID string `json:"handout_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if len(req) > core.MaxStatusReportBatch {
+		respondError(c, &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodePayloadTooLarge,
+			Message: fmt.Sprintf("too many reports: %d, max %d", len(req), core.MaxStatusReportBatch),
+		})
+		return
+	}
+
+	results := make([]core.StatusReportResult, len(req))
+	var reports []core.ProxyStatusReport
+	var indexes []int
+	for i, item := range req {
+		var err error
+		switch {
+		case item.ProxyID == 0:
+			err = errors.New("missing proxy_id")
+		case item.Speed < 0:
+			err = fmt.Errorf("invalid speed: %d", item.Speed)
+		}
+		if err != nil {
+			results[i] = core.StatusReportResult{ProxyID: item.ProxyID, Status: core.ReportInvalid, Error: err.Error()}
+			continue
+		}
+
+		reports = append(reports, core.ProxyStatusReport{
+			ProxyID: item.ProxyID,
+			StatusReport: core.StatusReport{
+				Success:   item.Success,
+				Speed:     item.Speed,
+				TargetURL: item.TargetURL,
+				Domain:    models.NormalizeDomain(extractDomain(item.TargetURL)),
+				ErrorMsg:  item.ErrorMsg,
+				HandoutID: item.HandoutID,
+			},
+		})
+		indexes = append(indexes, i)
+	}
+
+	applied, err := s.proxyPool.ReportProxyStatuses(reports)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for j, result := range applied {
+		results[indexes[j]] = result
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// getReady 就绪检查，数据库不可用或启动预热未结束时返回503
+// Redis不可用时服务以降级模式运行，仍视为就绪，状态在 redis 字段中体现
+func (s *Server) getReady(c *gin.Context) {
+	warmup := s.proxyPool.WarmupStatus()
+	readiness := gin.H{
+		"status":   "ready",
+		"database": "ok",
+		"redis":    s.proxyPool.RedisGuard().Status(),
+		"warmup":   warmup,
+	}
+
+	if !warmup.Ready() {
+		readiness["status"] = core.WarmupWarming
+		readiness["progress"] = fmt.Sprintf("%d/%d validated", warmup.Validated, warmup.Total)
+		respondError(c, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeUnavailable,
+			Message: "warming up",
+			Details: readiness,
+		})
+		return
+	}
+
+	if err := s.pingDB(c.Request.Context()); err != nil {
+		readiness["database"] = err.Error()
+		respondError(c, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeUnavailable,
+			Message: "database unavailable",
+			Details: readiness,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, readiness)
+}
+
+// pingDB 检查数据库连接
+func (s *Server) pingDB(ctx context.Context) error {
+	sqlDB, err := s.proxyPool.DB().DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// getStatus 获取代理池完整状态，结果有短暂缓存
+func (s *Server) getStatus(c *gin.Context) {
+	status, err := s.proxyPool.GetFullStatus()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// getLiveStatus 获取代理池完整状态，不使用缓存
+func (s *Server) getLiveStatus(c *gin.Context) {
+	status, err := s.proxyPool.GetFullStatusLive()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// getRealtimeStats 获取实时统计，数据来自Redis，不查询数据库
+func (s *Server) getRealtimeStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.proxyPool.RealtimeStats().Snapshot())
+}
+
+// getSchedulingDecisions 获取最近的调度记录，按时间倒序
+// 查询参数 limit 默认500，最多为内存中保留的记录数
+func (s *Server) getSchedulingDecisions(c *gin.Context) {
+	limit, err := queryInt(c, "limit", 500)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, s.proxyPool.DecisionLog().Recent(limit))
+}
+
+// getSchedulerStats 获取调度器当前的失败阈值、冷却设置和冷却中的代理数，设置可通过 PUT /api/config 的 scheduler 修改
+func (s *Server) getSchedulerStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.proxyPool.SchedulerStats())
+}
+
+// getSchedulerFairness 统计窗口内各代理的发放次数和基尼系数，用于发现被调度策略冷落的代理
+// 查询参数 window 为统计窗口，默认1小时，只统计内存中保留的记录
+func (s *Server) getSchedulerFairness(c *gin.Context) {
+	window, err := queryDuration(c, "window", core.DefaultFairnessWindow)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	report, err := s.proxyPool.SchedulerFairness(window)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// getReputation 获取代理的封禁上报记录，按上报时间倒序
+func (s *Server) getReputation(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	reports, err := models.ListReputation(s.proxyPool.DB(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// reportReputation 上报代理在某个域名上的封禁情况
+// 请求体：{"domain": "example.com", "banned": true, "reported_by": "crawler-1"}，reported_by 默认为客户端IP
+func (s *Server) reportReputation(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	var req struct {
+		Domain     string `json:"domain" binding:"required,max=255"`
+		Banned     *bool  `json:"banned" binding:"required"`
+		ReportedBy string `json:"reported_by" binding:"max=64"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if req.ReportedBy == "" {
+		req.ReportedBy = c.ClientIP()
+	}
+
+	if _, err := models.FindByID(s.proxyPool.DB(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	report := &models.ProxyReputation{
+		ProxyID:     id,
+		Domain:      req.Domain,
+		BanReported: *req.Banned,
+		ReportedBy:  req.ReportedBy,
+	}
+	if err := models.ReportReputation(s.proxyPool.DB(), report); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// getScorePrediction 根据评分历史预测代理在 horizon 之后的评分
+// 查询参数：horizon 预测时长，如 30m，默认30分钟
+func (s *Server) getScorePrediction(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	horizon, err := queryDuration(c, "horizon", core.DefaultLookAheadDuration)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	proxy, err := models.FindByID(s.proxyPool.DB(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"proxy_id":        id,
+		"current_score":   proxy.Score,
+		"horizon":         horizon.String(),
+		"predicted_score": s.proxyPool.PredictProxyScore(id, horizon),
+	})
+}
+
+// getProxyHistory 获取代理使用历史，按时间降序返回最近 limit 条，默认50条，超过上限时返回400
+func (s *Server) getProxyHistory(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	limit, err := queryLimit(c, 50, min(s.maxListLimit, models.MaxProxyHistory))
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if _, err := models.FindByID(s.proxyPool.DB(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	history, err := models.GetProxyHistory(s.proxyPool.DB(), id, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// getScoreHistory 获取代理评分历史，按时间升序返回最近 limit 条，默认50条，超过保留条数或上限时返回400
+func (s *Server) getScoreHistory(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	limit, err := queryLimit(c, 50, min(s.maxListLimit, models.MaxScoreHistory))
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	history, err := models.ListScoreHistory(s.proxyPool.DB(), id, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// exportScoreHistory 以CSV格式流式导出代理的全部评分历史
+func (s *Server) exportScoreHistory(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		respondError(c, badRequest(fmt.Errorf("unsupported format: %q", format)))
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=proxy_%d_score_history.csv", id))
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write([]string{"proxy_id", "score", "recorded_at"}); err != nil {
+		return
+	}
+
+	err = models.EachScoreHistory(s.proxyPool.DB(), id, func(h *models.ProxyScoreHistory) error {
+		w.Write([]string{
+			strconv.FormatUint(uint64(h.ProxyID), 10),
+			strconv.FormatFloat(h.Score, 'f', 2, 64),
+			h.RecordedAt.Format(time.RFC3339),
+		})
+		w.Flush()
+		return w.Error()
+	})
+	w.Flush()
+	if err != nil {
+		// 响应头已发送，只能中断连接
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// getScoreDistribution 获取代理评分分布，按可用性和来源拆分，用于调整最低评分
+// 查询参数 bucket 为分桶宽度，默认10分，不小于1分
+func (s *Server) getScoreDistribution(c *gin.Context) {
+	bucket, err := queryFloat(c, "bucket", models.DefaultScoreBucketSize)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	dist, err := s.proxyPool.ScoreDistribution(bucket)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dist)
+}
+
+// exportProxies 流式导出可用代理，format 为 json(默认) 或 csv，与订阅导出一样需要API密钥
+// 支持 type、region、protocol、min_score、min_success_rate、max_speed 过滤
+func (s *Server) exportProxies(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respondError(c, badRequest(fmt.Errorf("unsupported format: %q", format)))
+		return
+	}
+
+	filter, err := parseListFilter(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=proxies."+format)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = s.proxyPool.ExportCSV(c.Writer, filter)
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err = s.proxyPool.ExportJSON(c.Writer, filter)
+	}
+	if err != nil {
+		// 响应头已发送，只能中断连接
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// exportSubscription 将可用代理导出为订阅，供Clash、V2Ray等客户端直接使用，需要API密钥
+// 查询参数 format 为 clash、v2ray 或 plain，默认 clash；过滤参数同 /api/export
+func (s *Server) exportSubscription(c *gin.Context) {
+	format := core.SubscriptionFormat(c.DefaultQuery("format", string(core.SubscriptionClash)))
+	if !format.IsValid() {
+		respondError(c, badRequest(fmt.Errorf("unsupported format: %q", format)))
+		return
+	}
+
+	filter, err := parseListFilter(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// 订阅需要完整渲染后才能编码，先写入缓冲区，出错时仍可返回错误响应
+	var buf bytes.Buffer
+	if err := s.proxyPool.ExportSubscription(&buf, format, filter); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// parseListFilter 从查询参数解析可用代理查询条件
+func parseListFilter(c *gin.Context) (models.ListFilter, error) {
+	filter := models.ListFilter{
+		Type:     models.ProxyType(c.Query("type")),
+		Region:   models.ProxyRegion(c.Query("region")),
+		Protocol: c.Query("protocol"),
+	}
+
+	var err error
+	if filter.MinScore, err = queryFloat(c, "min_score", 0); err != nil {
+		return filter, err
+	}
+	if filter.MinSuccessRate, err = queryFloat(c, "min_success_rate", 0); err != nil {
+		return filter, err
+	}
+	maxSpeed, err := queryInt(c, "max_speed", 0)
+	if err != nil {
+		return filter, err
+	}
+	filter.MaxSpeed = int64(maxSpeed)
+	return filter, nil
+}
+
+// getCronJobs 获取所有定时任务的运行状态，包括最近一次panic
+func (s *Server) getCronJobs(c *gin.Context) {
+	jobs := s.proxyPool.CronJobs()
+	if jobs == nil {
+		respondError(c, errCronJobsUnavailable)
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs.Statuses())
+}
+
+// getValidationJob 获取验证任务状态
+func (s *Server) getValidationJob(c *gin.Context) {
+	service := s.proxyPool.ValidationService()
+	if service == nil {
+		respondError(c, errValidationUnavailable)
+		return
+	}
+
+	c.JSON(http.StatusOK, service.Status())
+}
+
+// getFetchJob 获取最近一次获取付费代理和免费代理的统计，从未获取时为 null
+func (s *Server) getFetchJob(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
+		return
+	}
+
+	paid, free := fetcher.LastFetchResults()
+	c.JSON(http.StatusOK, gin.H{
+		"paid": paid,
+		"free": free,
+	})
+}
+
+// triggerValidationJob 手动触发一轮验证，已有验证进行中时返回409及当前状态
+// 查询参数 type 指定代理类型，多个类型用逗号分隔，默认验证所有类型
+func (s *Server) triggerValidationJob(c *gin.Context) {
+	service := s.proxyPool.ValidationService()
+	if service == nil {
+		respondError(c, errValidationUnavailable)
+		return
+	}
+
+	var types []models.ProxyType
+	if raw := c.Query("type"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			t := models.ProxyType(strings.TrimSpace(part))
+			if !t.IsValid() {
+				respondError(c, badRequest(fmt.Errorf("invalid type: %q", t)))
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	status, err := service.Enqueue(types...)
+	if err != nil {
+		apiErr := toAPIError(err)
+		apiErr.Details = gin.H{"status": status}
+		respondError(c, apiErr)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, status)
+}
+
+// optimizePool 立即优化代理池，返回删除、评分变化和提高并发数的代理数
+func (s *Server) optimizePool(c *gin.Context) {
+	result, err := s.proxyPool.OptimizePool()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// sweepOrphans 立即清理已删除代理遗留的子表记录，返回各表的记录数
+// 查询参数：
+//   - dry_run: 为 true 时只统计不删除
+func (s *Server) sweepOrphans(c *gin.Context) {
+	dryRun, err := queryBool(c, "dry_run")
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	result, err := s.proxyPool.SweepOrphans(dryRun != nil && *dryRun)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// fetchSync 获取代理并等待新代理通过验证
+// 查询参数：
+//   - min_proxies: 需要的新增可用代理数，默认1
+//   - timeout: 最长等待时间，如 60s，默认60秒，最长5分钟
+//
+// 超时时仍返回200，timed_out 为 true；fetch 为本次获取的统计，可用代理已足够而未获取时为 null
+func (s *Server) fetchSync(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
+		return
+	}
+
+	minProxies, err := strconv.Atoi(c.DefaultQuery("min_proxies", "1"))
+	if err != nil || minProxies < 1 {
+		respondError(c, badRequest(fmt.Errorf("invalid min_proxies: %q", c.Query("min_proxies"))))
+		return
+	}
+
+	timeout := defaultFetchSyncTimeout
+	if raw := c.Query("timeout"); raw != "" {
+		timeout, err = time.ParseDuration(raw)
+		if err != nil || timeout <= 0 || timeout > maxFetchSyncTimeout {
+			respondError(c, badRequest(fmt.Errorf("invalid timeout: %q, must be within (0, %s]", raw, maxFetchSyncTimeout)))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	added, result, err := fetcher.FetchAndWait(ctx, minProxies)
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	if err != nil && !timedOut {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"new_proxies": added,
+		"min_proxies": minProxies,
+		"timed_out":   timedOut,
+		"fetch":       result,
+	})
+}
+
+// getSources 获取所有代理源的启用状态及健康状态
+func (s *Server) getSources(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
+		return
+	}
+
+	sources, err := fetcher.Sources()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sources)
+}
+
+// getSource 获取代理源记录，包含类型、启用状态、获取统计和健康状态
+func (s *Server) getSource(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
+		return
+	}
 
-	proxy, err := s.proxyPool.GetProxyForTask(task)
+	source, err := fetcher.Source(c.Param("name"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, proxy)
+	c.JSON(http.StatusOK, source)
 }
 
-// getProxies 获取多个代理
-func (s *Server) getProxies(c *gin.Context) {
-	proxyType := models.ProxyType(c.DefaultQuery("type", string(models.ProxyTypeTemp)))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+// disableSource 停用代理源，保留代理源记录和获取统计，已有代理不受影响
+func (s *Server) disableSource(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
+		return
+	}
 
-	proxies, err := s.proxyPool.GetProxies(proxyType, limit)
+	source, err := fetcher.SetSourceEnabled(c.Param("name"), false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, proxies)
+	c.JSON(http.StatusOK, source)
 }
 
-// addProxy 添加代理
-func (s *Server) addProxy(c *gin.Context) {
-	var proxy models.Proxy
-	if err := c.ShouldBindJSON(&proxy); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// getSourceProtocols 获取代理源可提供的代理协议
+func (s *Server) getSourceProtocols(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
 		return
 	}
 
-	if err := s.proxyPool.AddProxy(&proxy); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	name := c.Param("name")
+	protocols, err := fetcher.SourceProtocols(name)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, proxy)
+	c.JSON(http.StatusOK, gin.H{"source": name, "protocols": protocols})
 }
 
-// updateProxy 更新代理
-func (s *Server) updateProxy(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	var proxy models.Proxy
-	proxy.ID = uint(id)
-
-	if err := c.ShouldBindJSON(&proxy); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// updateSource 启用或停用代理源，下一次获取时生效
+// 请求体：{"enabled": false}
+// 查询参数：
+//   - purge: 停用时对已有代理的处理，true 标记为不可用，delete 删除，默认保留
+func (s *Server) updateSource(c *gin.Context) {
+	fetcher := s.proxyPool.Fetcher()
+	if fetcher == nil {
+		respondError(c, errFetcherUnavailable)
 		return
 	}
 
-	if err := s.proxyPool.UpdateProxyStatus(&proxy, proxy.Available, proxy.Speed); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if req.Enabled == nil {
+		respondError(c, badRequest(errors.New("enabled is required")))
 		return
 	}
 
-	c.JSON(http.StatusOK, proxy)
-}
+	var purge core.SourcePurge
+	switch raw := c.Query("purge"); raw {
+	case "", "false":
+	case "true":
+		purge = core.SourcePurgeDisable
+	case "delete":
+		purge = core.SourcePurgeDelete
+	default:
+		respondError(c, badRequest(fmt.Errorf("invalid purge: %q, must be true, false or delete", raw)))
+		return
+	}
+	if purge != core.SourcePurgeNone && *req.Enabled {
+		respondError(c, badRequest(errors.New("purge is only allowed when disabling a source")))
+		return
+	}
 
-// deleteProxy 删除代理
-func (s *Server) deleteProxy(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	source, err := fetcher.SetSourceEnabled(c.Param("name"), *req.Enabled)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-	if err := s.proxyPool.RemoveProxy(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	purged, err := s.proxyPool.PurgeSource(source.Name, purge)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, gin.H{
+		"source": source,
+		"purged": purged,
+	})
 }
 
-// reportProxyStatus 报告代理状态
-func (s *Server) reportProxyStatus(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
-	var report struct {
-		Success bool  `json:"success"`
-		Speed   int64 `json:"speed"`
+// getConflicts 获取最近的代理源冲突记录，按发现时间倒序
+// 查询参数：limit 返回数量上限，默认100，超过上限时返回400
+func (s *Server) getConflicts(c *gin.Context) {
+	limit, err := queryLimit(c, 100, s.maxListLimit)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
 	}
 
-	if err := c.ShouldBindJSON(&report); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	conflicts, err := models.ListConflicts(s.proxyPool.DB(), limit)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	s.proxyPool.ReportProxyStatus(uint(id), report.Success, report.Speed)
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, conflicts)
+}
+
+// purgeConflicts 删除7天前发现的代理源冲突记录
+func (s *Server) purgeConflicts(c *gin.Context) {
+	deleted, err := models.PurgeConflicts(s.proxyPool.DB(), models.ConflictRetention)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
 }
 
 // getStats 获取代理池状态
@@ -164,6 +2060,7 @@ func (s *Server) getStats(c *gin.Context) {
 	var stats struct {
 		TotalProxies     int     `json:"total_proxies"`
 		AvailableProxies int     `json:"available_proxies"`
+		PinnedProxies    int     `json:"pinned_proxies"`
 		SuccessRate      float64 `json:"success_rate"`
 		ProxyTypes       struct {
 			Temporary int `json:"temporary"`
@@ -171,12 +2068,8 @@ func (s *Server) getStats(c *gin.Context) {
 			Anonymous int `json:"anonymous"`
 			HighAnon  int `json:"high_anon"`
 		} `json:"proxy_types"`
-		SourceStats []struct {
-			Source    string `json:"source"`
-			Count     int    `json:"count"`
-			Available int    `json:"available"`
-		} `json:"source_stats"`
-		SpeedStats struct {
+		SourceStats []sourceStat `json:"source_stats"`
+		SpeedStats  struct {
 			Fast   int `json:"fast"`   // <1s
 			Medium int `json:"medium"` // 1-3s
 			Slow   int `json:"slow"`   // >3s
@@ -185,15 +2078,18 @@ func (s *Server) getStats(c *gin.Context) {
 			Country string `json:"country"`
 			Count   int    `json:"count"`
 		} `json:"country_stats"`
-		UpdateTime time.Time `json:"update_time"`
+		RetentionMode models.RetentionMode `json:"retention_mode"` // 当前保留模式，observe 下不删除代理
+		UpdateTime    time.Time            `json:"update_time"`
 	}
 
-	// 获取总代理数和可用代理数
-	var totalCount, availableCount int64
+	// 获取总代理数、可用代理数和固定代理数
+	var totalCount, availableCount, pinnedCount int64
 	s.proxyPool.DB().Model(&models.Proxy{}).Count(&totalCount)
 	s.proxyPool.DB().Model(&models.Proxy{}).Where("available = ?", true).Count(&availableCount)
+	s.proxyPool.DB().Model(&models.Proxy{}).Where("pinned = ?", true).Count(&pinnedCount)
 	stats.TotalProxies = int(totalCount)
 	stats.AvailableProxies = int(availableCount)
+	stats.PinnedProxies = int(pinnedCount)
 
 	// 计算成功率
 	var totalSuccessRate float64
@@ -210,26 +2106,28 @@ func (s *Server) getStats(c *gin.Context) {
 	s.proxyPool.DB().Model(&models.Proxy{}).Where("type = ?", models.ProxyTypeHighAnon).Count(&totalCount)
 	stats.ProxyTypes.HighAnon = int(totalCount)
 
-	// 统计各来源代理数量
+	// 统计各来源代理数量，附带代理源的最近获取时间和启用状态
 	var sourceStats []struct {
-		Source    string
-		Count     int64
-		Available int64
+		Source      string
+		Count       int64
+		Available   int64
+		LastFetchAt *time.Time
+		Enabled     *bool
 	}
 	s.proxyPool.DB().Model(&models.Proxy{}).
-		Select("source, COUNT(*) as count, SUM(CASE WHEN available THEN 1 ELSE 0 END) as available").
-		Group("source").
+		Select("proxies.source, COUNT(*) as count, SUM(CASE WHEN proxies.available THEN 1 ELSE 0 END) as available, " +
+			"sources.last_fetch_at, sources.enabled").
+		Joins("LEFT JOIN sources ON sources.name = proxies.source").
+		Group("proxies.source, sources.last_fetch_at, sources.enabled").
 		Scan(&sourceStats)
 
 	for _, stat := range sourceStats {
-		stats.SourceStats = append(stats.SourceStats, struct {
-			Source    string `json:"source"`
-			Count     int    `json:"count"`
-			Available int    `json:"available"`
-		}{
-			Source:    stat.Source,
-			Count:     int(stat.Count),
-			Available: int(stat.Available),
+		stats.SourceStats = append(stats.SourceStats, sourceStat{
+			Source:      stat.Source,
+			Count:       int(stat.Count),
+			Available:   int(stat.Available),
+			LastFetchAt: stat.LastFetchAt,
+			Enabled:     stat.Enabled == nil || *stat.Enabled, // 没有代理源记录的来源视为启用
 		})
 	}
 
@@ -241,12 +2139,513 @@ func (s *Server) getStats(c *gin.Context) {
 	s.proxyPool.DB().Model(&models.Proxy{}).Where("speed >= 3000").Count(&totalCount)
 	stats.SpeedStats.Slow = int(totalCount)
 
+	stats.RetentionMode = s.proxyPool.RuntimeConfig().Get().RetentionMode
+
 	// 更新时间
 	stats.UpdateTime = time.Now()
 
 	c.JSON(http.StatusOK, stats)
 }
 
+// sourceStat 单个来源的代理统计
+type sourceStat struct {
+	Source      string     `json:"source"`
+	Count       int        `json:"count"`
+	Available   int        `json:"available"`
+	LastFetchAt *time.Time `json:"last_fetch_at"` // 代理源最近一次获取的时间，从未获取时为空
+	Enabled     bool       `json:"enabled"`       // 代理源是否启用
+}
+
+// getTags 获取所有标签及出现次数
+func (s *Server) getTags(c *gin.Context) {
+	counts, err := models.ListTagCounts(s.proxyPool.DB())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+// deleteTag 从所有代理上移除标签
+func (s *Server) deleteTag(c *gin.Context) {
+	removed, err := models.DeleteTag(s.proxyPool.DB(), c.Param("tag"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+// getProxyTags 获取代理的标签
+func (s *Server) getProxyTags(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	tags, err := models.GetProxyTags(s.proxyPool.DB(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// addProxyTags 为代理追加标签
+func (s *Server) addProxyTags(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	var req struct {
+		Tags []string `json:"tags" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if _, err := models.FindByID(s.proxyPool.DB(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := models.AddProxyTags(s.proxyPool.DB(), id, tags); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.getProxyTags(c)
+}
+
+// removeProxyTag 移除代理的标签
+func (s *Server) removeProxyTag(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if err := models.RemoveProxyTag(s.proxyPool.DB(), id, c.Param("tag")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getGroups 获取所有代理分组及其成员数
+func (s *Server) getGroups(c *gin.Context) {
+	groups, err := models.ListGroups(s.proxyPool.DB())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// createGroup 创建代理分组，strategy 为空时组内使用请求中的调度策略
+func (s *Server) createGroup(c *gin.Context) {
+	var req struct {
+		Name        string  `json:"name" binding:"required"`
+		Description string  `json:"description"`
+		MinScore    float64 `json:"min_score"`
+		Strategy    string  `json:"strategy"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	group := &models.ProxyGroup{
+		Name:        req.Name,
+		Description: req.Description,
+		MinScore:    req.MinScore,
+		Strategy:    req.Strategy,
+	}
+	if err := s.proxyPool.CreateGroup(group); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.GroupSummary{ProxyGroup: *group})
+}
+
+// addGroupMembers 将代理加入分组，返回分组及其成员数
+func (s *Server) addGroupMembers(c *gin.Context) {
+	var req struct {
+		ProxyIDs []uint `json:"proxy_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if len(req.ProxyIDs) > models.MaxGroupMemberBatch {
+		respondError(c, &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodePayloadTooLarge,
+			Message: fmt.Sprintf("too many proxy ids: %d, max %d", len(req.ProxyIDs), models.MaxGroupMemberBatch),
+		})
+		return
+	}
+
+	name := c.Param("name")
+	if err := models.AddGroupMembers(s.proxyPool.DB(), name, req.ProxyIDs); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	summary, err := models.GetGroupSummary(s.proxyPool.DB(), name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// removeGroupMember 将代理移出分组
+func (s *Server) removeGroupMember(c *gin.Context) {
+	id, err := paramID(c)
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if err := models.RemoveGroupMember(s.proxyPool.DB(), c.Param("name"), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getStaleCount 预览老化清理将删除的代理数量，age 支持 7d、36h 等格式
+func (s *Server) getStaleCount(c *gin.Context) {
+	age, err := parseAge(c.DefaultQuery("age", "7d"))
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	count, err := models.CountOldProxies(s.proxyPool.DB(), age)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"age":   age.String(),
+		"count": count,
+	})
+}
+
+// validatorConfig 验证器配置
+type validatorConfig struct {
+	TestURLs       []string `json:"test_urls"`
+	TimeoutSeconds float64  `json:"timeout_seconds"`
+}
+
+// getValidatorConfig 获取验证器当前配置
+func (s *Server) getValidatorConfig(c *gin.Context) {
+	validator := s.proxyPool.Validator()
+	c.JSON(http.StatusOK, validatorConfig{
+		TestURLs:       validator.TestURLs(),
+		TimeoutSeconds: validator.Timeout().Seconds(),
+	})
+}
+
+// updateValidatorConfig 运行时修改验证器配置，未传入的字段保持不变
+// 请求体：{"test_urls": ["http://www.baidu.com"], "timeout_seconds": 5}
+func (s *Server) updateValidatorConfig(c *gin.Context) {
+	var req struct {
+		TestURLs       []string `json:"test_urls"`
+		TimeoutSeconds *float64 `json:"timeout_seconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	if req.TestURLs != nil {
+		if len(req.TestURLs) == 0 {
+			respondError(c, validationFailed(errors.New("test_urls must not be empty")))
+			return
+		}
+		for _, raw := range req.TestURLs {
+			u, err := url.Parse(raw)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				respondError(c, validationFailed(fmt.Errorf("invalid test url: %q", raw)))
+				return
+			}
+		}
+	}
+	if req.TimeoutSeconds != nil && *req.TimeoutSeconds <= 0 {
+		respondError(c, validationFailed(fmt.Errorf("invalid timeout_seconds: %v", *req.TimeoutSeconds)))
+		return
+	}
+
+	validator := s.proxyPool.Validator()
+	if req.TestURLs != nil {
+		validator.SetTestURLs(req.TestURLs)
+	}
+	if req.TimeoutSeconds != nil {
+		validator.SetTimeout(time.Duration(*req.TimeoutSeconds * float64(time.Second)))
+	}
+
+	s.getValidatorConfig(c)
+}
+
+// getRuntimeConfig 获取当前运行时配置
+func (s *Server) getRuntimeConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, s.proxyPool.RuntimeConfig().Get())
+}
+
+// updateRuntimeConfig 修改运行时配置，未传入的配置项保持不变，对之后的操作生效
+// 请求体：{"max_fail_count": 5, "scoring_weights": {"speed": 0.4}}
+// 数据库连接、监听地址等结构性配置只能重启后生效，修改时返回400
+func (s *Server) updateRuntimeConfig(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+
+	config, err := s.proxyPool.RuntimeConfig().Update(body)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, config)
+}
+
+// migrateFromRedis 在后台从旧代理池的Redis SET 或 ZSET 中导入代理，验证通过的代理入库，返回202及迁移进度
+// 请求体：{"key": "legacy_proxies", "format": "set"}，format 为 set 或 zset，默认 set
+// 已有迁移在执行时返回409及该迁移的进度，进度通过 GET /api/admin/migrate/redis 查询
+func (s *Server) migrateFromRedis(c *gin.Context) {
+	var req struct {
+		Key    string             `json:"key" binding:"required"`
+		Format core.MigrateFormat `json:"format"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if req.Format == "" {
+		req.Format = core.MigrateFormatSet
+	}
+
+	status, err := s.proxyPool.StartRedisMigration(req.Key, req.Format)
+	if err != nil {
+		apiErr := toAPIError(err)
+		if errors.Is(err, core.ErrMigrationRunning) {
+			apiErr.Details = gin.H{"status": status}
+		}
+		respondError(c, apiErr)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, status)
+}
+
+// getRedisMigration 获取最近一次Redis迁移的进度
+func (s *Server) getRedisMigration(c *gin.Context) {
+	c.JSON(http.StatusOK, s.proxyPool.RedisMigrationStatus())
+}
+
+// cancelRedisMigration 取消正在执行的Redis迁移，已迁移的代理保留，返回当前进度
+func (s *Server) cancelRedisMigration(c *gin.Context) {
+	c.JSON(http.StatusOK, s.proxyPool.CancelRedisMigration())
+}
+
+// bulkUpdateMaxConcurrent 批量修改符合条件的代理的最大并发数
+// 请求体：{"max_concurrent": 100, "filter": {"source": "kuaidaili"}}，filter 不能为空
+func (s *Server) bulkUpdateMaxConcurrent(c *gin.Context) {
+	var req struct {
+		MaxConcurrent int                `json:"max_concurrent" binding:"required"`
+		Filter        models.ProxyFilter `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, badRequest(err))
+		return
+	}
+	if req.MaxConcurrent < 1 {
+		respondError(c, validationFailed(fmt.Errorf("invalid max_concurrent: %d", req.MaxConcurrent)))
+		return
+	}
+	if req.Filter.Type != "" && !req.Filter.Type.IsValid() {
+		respondError(c, badRequest(fmt.Errorf("invalid type: %q", req.Filter.Type)))
+		return
+	}
+	if req.Filter.Region != "" && !req.Filter.Region.IsValid() {
+		respondError(c, badRequest(fmt.Errorf("invalid region: %q", req.Filter.Region)))
+		return
+	}
+
+	updated, err := models.BulkUpdateMaxConcurrent(s.proxyPool.DB(), req.Filter, req.MaxConcurrent)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.proxyPool.Logger().Info("批量修改代理最大并发数",
+		zap.Int("最大并发数", req.MaxConcurrent),
+		zap.Any("过滤条件", req.Filter),
+		zap.Int64("修改数量", updated),
+	)
+	c.JSON(http.StatusOK, gin.H{
+		"updated":        updated,
+		"max_concurrent": req.MaxConcurrent,
+	})
+}
+
+// parseAge 解析时长，在 time.ParseDuration 基础上支持以天为单位的 d 后缀
+func parseAge(raw string) (time.Duration, error) {
+	if strings.HasSuffix(raw, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid age: %q", raw)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(raw)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid age: %q", raw)
+	}
+	return age, nil
+}
+
+// paramID 解析路径中的代理ID
+func paramID(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id: %q", c.Param("id"))
+	}
+	return uint(id), nil
+}
+
+// queryInt 解析非负整数查询参数，未传入时返回默认值
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return value, nil
+}
+
+// queryLimit 解析列表接口的 limit 参数，未传入或不大于0时返回默认值，超过 max 时返回错误
+func queryLimit(c *gin.Context, def, max int) (int, error) {
+	return queryLimitParam(c, "limit", def, max)
+}
+
+// queryLimitParam 解析名为 key 的数量参数，规则同 queryLimit，默认值超过 max 时按 max
+func queryLimitParam(c *gin.Context, key string, def, max int) (int, error) {
+	if def > max {
+		def = max
+	}
+	raw := c.Query(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	case limit <= 0:
+		return def, nil
+	case limit > max:
+		return 0, fmt.Errorf("%s %d exceeds maximum %d, use GET /api/proxies/stream to export more proxies", key, limit, max)
+	}
+	return limit, nil
+}
+
+// queryFloat 解析非负浮点数查询参数，未传入时返回默认值
+func queryFloat(c *gin.Context, key string, def float64) (float64, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return value, nil
+}
+
+// queryDuration 解析时长查询参数，如 30m，必须为正数
+func queryDuration(c *gin.Context, key string, def time.Duration) (time.Duration, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return value, nil
+}
+
+// queryIDs 解析逗号分隔的代理ID查询参数，未传入时返回nil
+func queryIDs(c *gin.Context, key string) ([]uint, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// clientID 获取请求方标识，优先使用 X-Client-ID 请求头，否则使用客户端IP
+func clientID(c *gin.Context) string {
+	if id := strings.TrimSpace(c.GetHeader("X-Client-ID")); id != "" {
+		return id
+	}
+	return c.ClientIP()
+}
+
+// queryBool 解析布尔查询参数，未传入时返回nil
+func queryBool(c *gin.Context, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return &value, nil
+}
+
 // extractDomain 从URL中提取域名
 func extractDomain(urlStr string) string {
 	if urlStr == "" {
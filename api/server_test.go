@@ -0,0 +1,131 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+}
+
+// newTestServer 创建使用内存SQLite和 miniredis 的API服务器，opts 传给代理池
+func newTestServer(t *testing.T, opts ...core.PoolOption) *Server {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// 内存数据库每个连接各自独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := models.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewServer(core.NewProxyPool(db, client, zap.NewNop(), opts...))
+}
+
+// serve 向服务器发送请求并返回响应，header 为额外的请求头
+func serve(t *testing.T, handler http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// serveJSON 发送带JSON请求体的请求并返回响应
+func serveJSON(t *testing.T, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAddProxyDuplicatePolicies(t *testing.T) {
+	const body = `{"ip": "1.1.1.1", "port": 8080, "protocol": "http", "type": "long", "region": "cn"}`
+	const partial = `{"ip": "1.1.1.1", "port": 8080, "protocol": "http", "source": "manual"}`
+
+	tests := []struct {
+		policy     core.DuplicatePolicy
+		wantStatus int
+	}{
+		{core.DuplicateReject, http.StatusConflict},
+		{core.DuplicateUpsert, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := newTestServer(t)
+			s.proxyPool.SetDuplicatePolicy(tt.policy)
+			handler := s.engine()
+
+			if rec := serveJSON(t, handler, http.MethodPost, "/api/proxy", body); rec.Code != http.StatusCreated {
+				t.Fatalf("first add status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+			}
+			rec := serveJSON(t, handler, http.MethodPost, "/api/proxy", partial)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("duplicate add status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var stored models.Proxy
+			s.proxyPool.DB().First(&stored)
+			if stored.Type != models.ProxyTypeLong || stored.Region != models.ProxyRegionCN {
+				t.Errorf("stored type = %s, region = %s, want long, cn", stored.Type, stored.Region)
+			}
+		})
+	}
+}
+
+func TestScoreDistributionBucketBounds(t *testing.T) {
+	handler := newTestServer(t).engine()
+
+	tests := []struct {
+		bucket string
+		status int
+	}{
+		{"10", http.StatusOK},
+		{"1", http.StatusOK},
+		{"100", http.StatusOK},
+		{"0.0001", http.StatusBadRequest},
+		{"0", http.StatusBadRequest},
+		{"-5", http.StatusBadRequest},
+		{"101", http.StatusBadRequest},
+		{"abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := serve(t, handler, http.MethodGet, "/api/stats/scores?bucket="+tt.bucket, nil)
+		if rec.Code != tt.status {
+			t.Errorf("bucket=%s status = %d, want %d: %s", tt.bucket, rec.Code, tt.status, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+func TestDisableSourceKeepsRow(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	s.proxyPool.SetFetcher(core.NewProxyFetcher(db, zap.NewNop(), &core.Config{}))
+	if err := models.RecordSourceFetch(db, "kuaidaili_paid", models.SourceTypePaid, 5, time.Now()); err != nil {
+		t.Fatalf("RecordSourceFetch: %v", err)
+	}
+	handler := s.engine()
+
+	rec := serve(t, handler, http.MethodDelete, "/api/sources/kuaidaili_paid", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body)
+	}
+	var body models.ProxySource
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Enabled {
+		t.Error("response source still enabled")
+	}
+
+	// 停用只修改启用状态，保留记录和获取统计
+	source, err := models.FindSource(db, "kuaidaili_paid")
+	if err != nil {
+		t.Fatalf("FindSource after delete: %v", err)
+	}
+	if source.Enabled || source.TotalFetched != 5 || source.LastFetchAt == nil {
+		t.Errorf("source after delete = %+v, want disabled with statistics kept", source)
+	}
+
+	if rec := serve(t, handler, http.MethodDelete, "/api/sources/unknown", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("delete unknown source status = %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body)
+	}
+}
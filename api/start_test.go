@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingListener 接受连接时返回错误的监听
+type failingListener struct {
+	net.Listener
+	err error
+}
+
+func (l failingListener) Accept() (net.Conn, error) {
+	return nil, l.err
+}
+
+func TestStartReportsServeErrors(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := s.Start("256.0.0.1:0"); err == nil {
+		t.Error("Start on an invalid address succeeded")
+	}
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { inner.Close() })
+	accept := errors.New("accept failed")
+	s.serve("broken", failingListener{Listener: inner, err: accept})
+
+	select {
+	case err := <-s.Err():
+		if !errors.Is(err, accept) || !strings.Contains(err.Error(), "broken") {
+			t.Errorf("Err() = %v, want accept error for broken", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve error not reported")
+	}
+
+	// 正常关闭不算错误
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Stop: %v", err)
+	}
+	select {
+	case err := <-s.Err():
+		t.Errorf("Err() after Stop = %v, want nothing", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunMultiJoinsListenerErrors(t *testing.T) {
+	s := newTestServer(t)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunMulti("127.0.0.1:0", "256.0.0.1:0", "127.0.0.1:-1")
+	}()
+
+	select {
+	case err := <-done:
+		// 两个错误都返回，正常的监听随之关闭
+		if err == nil || !strings.Contains(err.Error(), "256.0.0.1:0") || !strings.Contains(err.Error(), "127.0.0.1:-1") {
+			t.Errorf("RunMulti error = %v, want both listener errors", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RunMulti kept running after a listener failed")
+	}
+}
+
+func TestStopShutsDownHTTPSRedirect(t *testing.T) {
+	s := newTestServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RedirectToHTTPS(addr, "example.com:8443")
+	}()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	var resp *http.Response
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		if resp, err = client.Get("http://" + addr + "/api/proxy?type=long"); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("redirect request: %v", err)
+	}
+	resp.Body.Close()
+	host, _, _ := net.SplitHostPort(addr)
+	want := "https://" + net.JoinHostPort(host, "8443") + "/api/proxy?type=long"
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != want {
+		t.Errorf("redirect = %d %q, want 301 %q", resp.StatusCode, resp.Header.Get("Location"), want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RedirectToHTTPS after Stop = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("redirect server kept running after Stop")
+	}
+	if _, err := client.Get("http://" + addr + "/"); err == nil {
+		t.Error("redirect listener still accepts requests after Stop")
+	}
+}
+
+// selfSignedCert 生成 127.0.0.1 的自签名证书
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestRunWithTLSConfig(t *testing.T) {
+	s := newTestServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunWithTLSConfig(addr, &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}, MinVersion: tls.VersionTLS12})
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	var resp *http.Response
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		if resp, err = client.Get("https://" + addr + "/api/tags"); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("https request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("https response = %d (tls %+v), want 200 over TLS 1.2+", resp.StatusCode, resp.TLS)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunWithTLSConfig after Stop = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("TLS server kept running after Stop")
+	}
+}
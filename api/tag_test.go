@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestGetProxiesTagPattern(t *testing.T) {
+	s := newTestServer(t)
+	db := s.proxyPool.DB()
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		p := &models.Proxy{
+			IP: ip, Port: 8080, Type: models.ProxyTypeTemp, Protocol: "http",
+			Region: models.ProxyRegionOther, Source: "test", Available: true,
+		}
+		if err := db.Create(p).Error; err != nil {
+			t.Fatalf("create proxy: %v", err)
+		}
+		// 可用字段默认为 true，创建后再标记第二个代理不可用
+		if i == 1 {
+			db.Model(p).UpdateColumn("available", false)
+		}
+		if err := models.AddProxyTags(db, p.ID, []string{[]string{"us-east", "us-west", "eu-west"}[i]}); err != nil {
+			t.Fatalf("add tag: %v", err)
+		}
+	}
+	// 长效代理同样带 us- 标签，标签模式搜索不会绕过 type 的默认值
+	long := &models.Proxy{
+		IP: "4.4.4.4", Port: 8080, Type: models.ProxyTypeLong, Protocol: "http",
+		Region: models.ProxyRegionOther, Source: "test", Available: true,
+	}
+	if err := db.Create(long).Error; err != nil {
+		t.Fatalf("create long proxy: %v", err)
+	}
+	if err := models.AddProxyTags(db, long.ID, []string{"us-long"}); err != nil {
+		t.Fatalf("add tag: %v", err)
+	}
+	handler := s.engine()
+
+	tests := []struct {
+		target string
+		status int
+		ips    []string
+	}{
+		{"/api/proxies?tag=us-*", http.StatusOK, []string{"1.1.1.1"}},
+		{"/api/proxies?tag=us-*&available=false", http.StatusOK, []string{"2.2.2.2"}},
+		{"/api/proxies?tag=*west&cursor=0", http.StatusOK, []string{"3.3.3.3"}},
+		{"/api/proxies?tag=us-*&type=long", http.StatusOK, []string{"4.4.4.4"}},
+		{"/api/proxies?tag=*west&available=false", http.StatusOK, []string{"2.2.2.2"}},
+		{"/api/proxies?tag_search=west", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(t, handler, http.MethodGet, tt.target, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var items []proxyResponse
+			body := rec.Body.Bytes()
+			var page proxyPageResponse
+			if json.Unmarshal(body, &page) == nil && page.Proxies != nil {
+				items = page.Proxies
+			} else if err := json.Unmarshal(body, &items); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var ips []string
+			for _, item := range items {
+				ips = append(ips, item.IP)
+			}
+			if len(ips) != len(tt.ips) || (len(ips) > 0 && ips[0] != tt.ips[0]) {
+				t.Errorf("ips = %v, want %v", ips, tt.ips)
+			}
+		})
+	}
+}
+
+func TestImportAndManageProxyTags(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.engine()
+
+	rec := serveJSON(t, handler, http.MethodPost, "/api/proxies/import", `{
+		"proxies": [
+			{"ip": "1.1.1.1", "port": 8080, "tags": ["residential"]},
+			{"ip": "2.2.2.2", "port": 8080},
+			{"ip": "10.0.0.1", "port": 8080}
+		],
+		"tags": ["batch-1"]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import status = %d: %s", rec.Code, rec.Body)
+	}
+	var result struct {
+		Imported int `json:"imported"`
+		Rejected []struct {
+			IP string `json:"ip"`
+		} `json:"rejected"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if result.Imported != 2 || len(result.Rejected) != 1 || result.Rejected[0].IP != "10.0.0.1" {
+		t.Fatalf("import result = %s, want two imported and the private IP rejected", rec.Body)
+	}
+
+	tagged, err := models.FindByIP(s.proxyPool.DB(), "1.1.1.1", 8080)
+	if err != nil {
+		t.Fatalf("find imported proxy: %v", err)
+	}
+	path := "/api/proxy/" + strconv.Itoa(int(tagged.ID)) + "/tags"
+	proxyTags := func() []string {
+		t.Helper()
+		rec := serve(t, handler, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get tags status = %d: %s", rec.Code, rec.Body)
+		}
+		var tags []string
+		if err := json.Unmarshal(rec.Body.Bytes(), &tags); err != nil {
+			t.Fatalf("decode tags: %v", err)
+		}
+		sort.Strings(tags)
+		return tags
+	}
+	if got := proxyTags(); !reflect.DeepEqual(got, []string{"batch-1", "residential"}) {
+		t.Errorf("imported tags = %v, want item and request tags", got)
+	}
+
+	// 按标签调度只返回带有该标签的代理
+	rec = serve(t, handler, http.MethodGet, "/api/proxy?tag=residential", nil)
+	var got proxyResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &got) != nil || got.IP != "1.1.1.1" {
+		t.Errorf("get proxy by tag = %d %s, want 1.1.1.1", rec.Code, rec.Body)
+	}
+
+	if rec := serveJSON(t, handler, http.MethodPost, path, `{"tags": ["fast"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("add tags status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, handler, http.MethodDelete, path+"/residential", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("remove tag status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := proxyTags(); !reflect.DeepEqual(got, []string{"batch-1", "fast"}) {
+		t.Errorf("tags after add and remove = %v, want [batch-1 fast]", got)
+	}
+	if rec := serve(t, handler, http.MethodGet, "/api/proxy?tag=residential", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("get proxy by removed tag status = %d, want 404", rec.Code)
+	}
+
+	// 删除标签时从所有代理上移除
+	rec = serve(t, handler, http.MethodDelete, "/api/tags/batch-1", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"removed":2`) {
+		t.Errorf("delete tag = %d %s, want 2 removed", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestValidatorConfigRuntimeUpdate(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.engine()
+
+	rec := serveJSON(t, handler, http.MethodPut, "/api/admin/validator/config", `{"test_urls": ["http://check.example/a", "https://check.example/b"], "timeout_seconds": 2.5}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body)
+	}
+	var got validatorConfig
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := validatorConfig{TestURLs: []string{"http://check.example/a", "https://check.example/b"}, TimeoutSeconds: 2.5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("updated config = %+v, want %+v", got, want)
+	}
+
+	// 修改作用于代理池共享的验证器，未传入的字段保持不变
+	if rec := serveJSON(t, handler, http.MethodPut, "/api/admin/validator/config", `{"timeout_seconds": 4}`); rec.Code != http.StatusOK {
+		t.Fatalf("partial update status = %d: %s", rec.Code, rec.Body)
+	}
+	validator := s.proxyPool.Validator()
+	if urls := validator.TestURLs(); !reflect.DeepEqual(urls, want.TestURLs) || validator.Timeout().Seconds() != 4 {
+		t.Errorf("validator config = %v %v, want previous urls and 4s", urls, validator.Timeout())
+	}
+
+	for _, body := range []string{
+		`{"test_urls": []}`,
+		`{"test_urls": ["ftp://check.example"]}`,
+		`{"test_urls": ["not a url"]}`,
+		`{"timeout_seconds": 0}`,
+	} {
+		if rec := serveJSON(t, handler, http.MethodPut, "/api/admin/validator/config", body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status = %d, want 422", body, rec.Code)
+		}
+	}
+	rec = serve(t, handler, http.MethodGet, "/api/admin/validator/config", nil)
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &got) != nil || got.TimeoutSeconds != 4 {
+		t.Errorf("get config after rejected updates = %d %s, want timeout 4", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,46 @@
+package core
+
+// APIScope API密钥的权限范围，级别高的范围包含级别低的范围的全部权限
+type APIScope string
+
+const (
+	ScopeRead   APIScope = "read"   // 获取代理、代理列表和订阅导出
+	ScopeReport APIScope = "report" // 另外可上报代理使用状态
+	ScopeAdmin  APIScope = "admin"  // 全部接口
+)
+
+// scopeLevels 各权限范围的级别
+var scopeLevels = map[APIScope]int{
+	ScopeRead:   1,
+	ScopeReport: 2,
+	ScopeAdmin:  3,
+}
+
+// IsValid 是否为支持的权限范围
+func (s APIScope) IsValid() bool {
+	_, ok := scopeLevels[s]
+	return ok
+}
+
+// Includes 是否包含 required 范围的权限
+func (s APIScope) Includes(required APIScope) bool {
+	return s.IsValid() && scopeLevels[s] >= scopeLevels[required]
+}
+
+// APIKey 带权限范围的API密钥，用于只向合作方开放部分接口
+type APIKey struct {
+	Name              string     `validate:"required"`                              // 密钥名称，用于日志和限流，不能重复
+	Key               string     `validate:"required"`                              // 密钥，请求头 X-API-Key 或查询参数 api_key 传入
+	Scopes            []APIScope `validate:"required,dive,oneof=read report admin"` // 权限范围，至少一个
+	RequestsPerMinute int        `validate:"min=0"`                                 // 每分钟最多请求数，0表示不限
+}
+
+// HasScope 密钥是否有 required 范围的权限
+func (k *APIKey) HasScope(required APIScope) bool {
+	for _, scope := range k.Scopes {
+		if scope.Includes(required) {
+			return true
+		}
+	}
+	return false
+}
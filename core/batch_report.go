@@ -0,0 +1,59 @@
+package core
+
+import "proxy_pool/models"
+
+// MaxStatusReportBatch 单次批量上报的最大条数
+const MaxStatusReportBatch = 500
+
+// 批量上报单条结果
+const (
+	ReportApplied  = "ok"        // 已处理
+	ReportNotFound = "not_found" // 代理不存在
+	ReportInvalid  = "invalid"   // 上报内容不合法，由调用方校验
+)
+
+// ProxyStatusReport 单个代理的使用结果
+type ProxyStatusReport struct {
+	ProxyID uint
+	StatusReport
+}
+
+// StatusReportResult 批量上报中单条的处理结果
+type StatusReportResult struct {
+	ProxyID uint   `json:"proxy_id"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReportProxyStatuses 批量报告代理使用状态，结果与 reports 一一对应
+// 每条按 ReportProxyStatus 处理，释放并发计数、更新统计和指标与单条上报一致；不存在的代理不影响其他上报
+func (s *ProxyScheduler) ReportProxyStatuses(reports []ProxyStatusReport) ([]StatusReportResult, error) {
+	results := make([]StatusReportResult, len(reports))
+	if len(reports) == 0 {
+		return results, nil
+	}
+
+	ids := make([]uint, 0, len(reports))
+	for _, r := range reports {
+		ids = append(ids, r.ProxyID)
+	}
+	var found []uint
+	if err := s.pool.DB().Model(&models.Proxy{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	exists := make(map[uint]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	for i, r := range reports {
+		// 代理可能已被删除，仍然释放并发计数
+		s.ReportProxyStatus(r.ProxyID, r.StatusReport)
+		status := ReportApplied
+		if !exists[r.ProxyID] {
+			status = ReportNotFound
+		}
+		results[i] = StatusReportResult{ProxyID: r.ProxyID, Status: status}
+	}
+	return results, nil
+}
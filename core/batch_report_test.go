@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestReportProxyStatusesMatchesSingleReport(t *testing.T) {
+	pool, _ := newTestPool(t)
+	ok := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Score = 90 })
+	bad := newTestProxy(t, pool.DB(), "2.2.2.2")
+	for _, p := range []*models.Proxy{ok, bad} {
+		task := &Task{Strategy: StrategyWeighted, ExcludeIDs: []uint{ok.ID + bad.ID - p.ID}}
+		if got, err := pool.Scheduler().ScheduleProxy(context.Background(), task); err != nil || got.ID != p.ID {
+			t.Fatalf("schedule = %v, %v, want proxy %d", got, err, p.ID)
+		}
+	}
+
+	results, err := pool.ReportProxyStatuses([]ProxyStatusReport{
+		{ProxyID: ok.ID, StatusReport: StatusReport{Success: true, Speed: 100, Domain: "example.test"}},
+		{ProxyID: bad.ID, StatusReport: StatusReport{Success: false, ErrorMsg: "timeout", Domain: "example.test"}},
+		{ProxyID: 9999, StatusReport: StatusReport{Success: true}},
+	})
+	if err != nil {
+		t.Fatalf("ReportProxyStatuses: %v", err)
+	}
+	want := []string{ReportApplied, ReportApplied, ReportNotFound}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("result %d = %+v, want %s", i, r, want[i])
+		}
+	}
+
+	// 与单条上报一样归还并发占用，记录使用、连续次数和失败原因
+	for _, p := range []*models.Proxy{ok, bad} {
+		if n := pool.GetLiveConcurrentUse(p.ID); n != 0 {
+			t.Errorf("proxy %d live concurrent use = %d, want 0", p.ID, n)
+		}
+	}
+	var usages int64
+	pool.DB().Model(&models.ProxyUsage{}).Where("proxy_id IN ?", []uint{ok.ID, bad.ID}).Count(&usages)
+	if usages != 2 {
+		t.Errorf("usages = %d, want 2", usages)
+	}
+	var stored models.Proxy
+	if err := pool.DB().First(&stored, bad.ID).Error; err != nil {
+		t.Fatalf("load proxy: %v", err)
+	}
+	if stored.ConsecutiveFailure != 1 || stored.LastError != "timeout" {
+		t.Errorf("failed proxy consecutive failures = %d, last error = %q, want 1, timeout", stored.ConsecutiveFailure, stored.LastError)
+	}
+	if n := pool.RealtimeStats().Snapshot().Failures; n != 1 {
+		t.Errorf("realtime failures = %d, want 1", n)
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"proxy_pool/models"
+	"sort"
+	"time"
+)
+
+// MaxScheduleCandidates 预览候选代理的最大数量
+const MaxScheduleCandidates = 50
+
+// ScheduleCandidate 候选代理及其在调度策略中的权重，权重越高越优先
+type ScheduleCandidate struct {
+	Proxy  *models.Proxy
+	Weight float64
+}
+
+// GetTopCandidates 按任务的调度策略返回前 n 个符合条件的代理，不占用代理也不更新调度统计
+// 供需要自行从多个代理中挑选的调用方使用，如并行请求需要不同的代理
+func (s *ProxyScheduler) GetTopCandidates(task *Task, n int) ([]*models.Proxy, error) {
+	candidates, err := s.RankCandidates(task, n)
+	if err != nil {
+		return nil, err
+	}
+
+	proxies := make([]*models.Proxy, len(candidates))
+	for i, candidate := range candidates {
+		proxies[i] = candidate.Proxy
+	}
+	return proxies, nil
+}
+
+// RankCandidates 按任务的调度策略排序符合条件的代理，返回前 n 个及其权重
+// 与调度时一样排除冷却中、满载及在目标域名上失败过多的代理，但不随机选择，也不修改调度器状态
+func (s *ProxyScheduler) RankCandidates(task *Task, n int) ([]ScheduleCandidate, error) {
+	if n <= 0 || n > MaxScheduleCandidates {
+		n = MaxScheduleCandidates
+	}
+
+	filter := task.Filter()
+	domain := models.NormalizeDomain(task.Domain)
+	siteAdaptive := task.Strategy == StrategySiteAdaptive && domain != ""
+	if siteAdaptive {
+		filter.ExcludeIDs = append(filter.ExcludeIDs, s.bannedProxies(context.Background(), task.Domain)...)
+	}
+	proxies, err := s.pool.ListProxies(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxies) == 0 {
+		return nil, &NoProxyError{Err: ErrNoProxyAvailable, Filters: filter}
+	}
+
+	var predictions map[uint]float64
+	if task.Strategy == StrategyPredictive {
+		horizon := task.LookAheadDuration
+		if horizon <= 0 {
+			horizon = DefaultLookAheadDuration
+		}
+		predictions = s.predictScores(context.Background(), proxies, horizon)
+	}
+
+	// statsFor 会清除过期的冷却，需要独占锁
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var candidates []ScheduleCandidate
+	var adaptive []adaptiveProxy
+	for i := range proxies {
+		proxy := &proxies[i]
+		if !s.isProxyQualified(proxy, task) {
+			continue
+		}
+		if siteAdaptive {
+			if task.MaxFailures > 0 && s.domainFails[domain][proxy.ID] >= task.MaxFailures {
+				continue
+			}
+			adaptive = append(adaptive, adaptiveProxy{
+				proxy:    proxy,
+				useCount: s.useCount[proxy.ID],
+				lastUsed: s.lastUsed[proxy.ID],
+				score:    proxy.Score,
+			})
+		}
+		candidates = append(candidates, ScheduleCandidate{
+			Proxy:  proxy,
+			Weight: s.candidateWeight(proxy, task.Strategy, predictions),
+		})
+	}
+
+	if len(candidates) == 0 {
+		return nil, &NoProxyError{Err: ErrNoQualifiedProxy, Filters: filter}
+	}
+
+	if siteAdaptive {
+		sort.SliceStable(adaptive, func(i, j int) bool {
+			return adaptiveLess(adaptive[i], adaptive[j])
+		})
+		for i, a := range adaptive {
+			candidates[i] = ScheduleCandidate{Proxy: a.proxy, Weight: a.score}
+		}
+	} else {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Weight > candidates[j].Weight
+		})
+	}
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
+
+// candidateWeight 代理在调度策略中的权重，与各策略的选择依据一致，调用方需持有 s.mu
+//   - weighted 及默认策略：调度权重
+//   - roundrobin：距上次使用的秒数
+//   - leastused：1/(1+使用次数)
+//   - failover：1/(1+失败次数)
+//   - predictive：预测评分
+//   - random：均为1，各代理被选中的概率相同
+//   - site_adaptive：代理评分，排序另按 adaptiveLess
+func (s *ProxyScheduler) candidateWeight(proxy *models.Proxy, strategy ScheduleStrategy, predictions map[uint]float64) float64 {
+	switch strategy {
+	case StrategyRoundRobin:
+		return time.Since(s.lastUsed[proxy.ID]).Seconds()
+	case StrategyLeastUsed:
+		return 1 / float64(1+s.useCount[proxy.ID])
+	case StrategyFailover:
+		return 1 / float64(1+s.failCount[proxy.ID])
+	case StrategyPredictive:
+		return predictions[proxy.ID]
+	case StrategySiteAdaptive:
+		return proxy.Score
+	case StrategyRandom:
+		return 1
+	default:
+		// 与 weightedSchedule 一致，但不写入权重缓存
+		if weight := s.weights[proxy.ID]; weight != 0 {
+			return weight
+		}
+		return s.calculateScore(proxy)
+	}
+}
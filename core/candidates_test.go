@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestGetTopCandidates(t *testing.T) {
+	pool, _ := newTestPool(t)
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+	newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Score = 90 })
+	newTestProxy(t, pool.DB(), "1.1.1.2", func(p *models.Proxy) { p.Score = 60 })
+	newTestProxy(t, pool.DB(), "1.1.1.3", func(p *models.Proxy) { p.Score = 30 })
+	cooling := newTestProxy(t, pool.DB(), "1.1.1.4", func(p *models.Proxy) { p.Score = 95 })
+	scheduler.mu.Lock()
+	scheduler.cooldown[cooling.ID] = time.Now().Add(time.Hour)
+	scheduler.mu.Unlock()
+
+	task := &Task{ProxyType: models.ProxyTypeTemp, Strategy: StrategyLeastUsed}
+	ranked, err := scheduler.RankCandidates(task, 2)
+	if err != nil {
+		t.Fatalf("RankCandidates: %v", err)
+	}
+	top, err := scheduler.GetTopCandidates(task, 2)
+	if err != nil {
+		t.Fatalf("GetTopCandidates: %v", err)
+	}
+	// 与 RankCandidates 的顺序一致，只返回代理
+	if len(top) != len(ranked) || len(top) != 2 {
+		t.Fatalf("top candidates = %d, ranked = %d, want 2", len(top), len(ranked))
+	}
+	for i := range top {
+		if top[i].ID != ranked[i].Proxy.ID {
+			t.Errorf("candidate %d = proxy %d, want %d", i, top[i].ID, ranked[i].Proxy.ID)
+		}
+	}
+
+	// n 不大于0时返回全部符合条件的代理，冷却中的代理除外
+	all, err := scheduler.GetTopCandidates(task, 0)
+	if err != nil {
+		t.Fatalf("GetTopCandidates(0): %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("all candidates = %d, want 3", len(all))
+	}
+	for _, p := range all {
+		if p.ID == cooling.ID {
+			t.Error("proxy in cooldown returned as a candidate")
+		}
+		// 不占用代理
+		if n := pool.GetLiveConcurrentUse(p.ID); n != 0 {
+			t.Errorf("proxy %d live concurrent use = %d, want 0", p.ID, n)
+		}
+	}
+
+	var noProxy *NoProxyError
+	if _, err := scheduler.GetTopCandidates(&Task{ProxyType: models.ProxyTypeLong}, 5); !errors.As(err, &noProxy) {
+		t.Errorf("no matching proxies error = %v, want a NoProxyError", err)
+	}
+}
@@ -19,8 +19,10 @@ type SiteConfig struct {
 	RetryDelay time.Duration `json:"retry_delay"` // 重试间隔
 
 	// 代理配置
-	ProxyType    string        `json:"proxy_type"`    // 代理类型(http/https/socks5)
+	ProxyType    string        `json:"proxy_type"`    // 代理类型(temp/long/anon/high_anon)或协议(http/https/socks5)
 	ProxyTimeout time.Duration `json:"proxy_timeout"` // 代理超时时间
+	VerifyMethod string        `json:"verify_method"` // 发放代理前验证使用的请求方法(HEAD/GET)，为空时使用HEAD
+	RequireAnon  bool          `json:"require_anon"`  // 是否需要匿名代理
 
 	// 频率限制
 	ShortTermLimit int           `json:"short_term_limit"` // 短期限制(每秒)
@@ -75,6 +77,9 @@ func (c *SiteConfig) Validate() error {
 	if c.Timeout <= 0 {
 		return errors.New("timeout must be positive")
 	}
+	if c.VerifyMethod != "" && c.VerifyMethod != "HEAD" && c.VerifyMethod != "GET" {
+		return fmt.Errorf("verify method must be HEAD or GET, got %q", c.VerifyMethod)
+	}
 	if c.MaxRetries < 0 {
 		return errors.New("max retries cannot be negative")
 	}
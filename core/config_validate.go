@@ -0,0 +1,134 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"proxy_pool/core/redact"
+	"proxy_pool/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser 与 main 中 cron.WithSeconds() 使用的解析器一致
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// configValidator 检查 Config 字段上 validate 标签声明的约束
+var configValidator = newConfigValidator()
+
+func newConfigValidator() *validator.Validate {
+	v := validator.New()
+	if err := v.RegisterValidation("positiveduration", isPositiveDuration); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// isPositiveDuration positiveduration 标签：time.Duration 字段必须大于0
+func isPositiveDuration(fl validator.FieldLevel) bool {
+	d, ok := fl.Field().Interface().(time.Duration)
+	return ok && d > 0
+}
+
+// Validate 检查配置，返回所有不合法的配置项，启动时调用以便尽早发现配置错误
+// 先检查字段上 validate 标签声明的约束，再检查标签无法表达的定时任务表达式、字段间约束和协议
+// 只检查会被使用的定时任务表达式，如未配置付费代理源时不检查 PaidInterval
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := configValidator.Struct(c); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return err
+		}
+		for _, fe := range fieldErrs {
+			errs = append(errs, fieldError(fe))
+		}
+	}
+
+	// 必填的定时任务表达式由 required 标签检查，这里只检查已配置的表达式
+	checkCron := func(field, spec string) {
+		if spec == "" {
+			return
+		}
+		if _, err := cronParser.Parse(spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid cron expression %q: %w", field, spec, err))
+		}
+	}
+	if c.KuaidailiURL != "" || c.WandouURL != "" {
+		checkCron("PaidInterval", c.PaidInterval)
+	}
+	if c.UseFreeAPI {
+		checkCron("FreeInterval", c.FreeInterval)
+	}
+	checkCron("ValidateInterval", c.ValidateInterval)
+	checkCron("CleanupInterval", c.CleanupInterval)
+	checkCron("OptimizeInterval", c.OptimizeInterval)
+	checkCron("AgeCleanupInterval", c.AgeCleanupInterval)
+	checkCron("OrphanSweepInterval", c.OrphanSweepInterval)
+	checkCron("MaintenanceInterval", c.MaintenanceInterval)
+	checkCron("ReputationCleanupInterval", c.ReputationCleanupInterval)
+	checkCron("DecisionFlushInterval", c.DecisionFlushInterval)
+
+	// 按类型排序，错误信息顺序稳定
+	types := make([]string, 0, len(c.ValidateIntervals))
+	for t := range c.ValidateIntervals {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		checkCron("ValidateIntervals["+t+"]", c.ValidateIntervals[models.ProxyType(t)])
+	}
+
+	if c.DBMaxOpenConns > 0 && c.DBMaxOpenConns < c.DBMaxIdleConns {
+		errs = append(errs, fmt.Errorf("DBMaxOpenConns: %d is less than DBMaxIdleConns %d", c.DBMaxOpenConns, c.DBMaxIdleConns))
+	}
+
+	for _, protocol := range c.RequiredProtocols {
+		if !models.IsSupportedProtocol(protocol) || protocol == models.ProtocolAuto {
+			errs = append(errs, fmt.Errorf("RequiredProtocols: unsupported protocol %q", protocol))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fieldError 将 validate 标签的检查失败转换为可读的错误信息，格式与手动检查的错误一致
+func fieldError(fe validator.FieldError) error {
+	// 嵌套字段使用完整路径，如 APIKeys[0].Name
+	field, value := strings.TrimPrefix(fe.Namespace(), "Config."), fe.Value()
+	switch fe.Tag() {
+	case "required":
+		return fmt.Errorf("%s: is required", field)
+	case "required_if", "required_with":
+		return fmt.Errorf("%s: is required when %s is set", field, requiredCondition(fe))
+	case "url", "http_url":
+		raw, _ := value.(string)
+		return fmt.Errorf("%s: invalid http(s) url %q", field, redact.URL(raw))
+	case "positiveduration":
+		return fmt.Errorf("%s: must be positive, got %v", field, value)
+	case "min":
+		return fmt.Errorf("%s: must be at least %s, got %v", field, fe.Param(), value)
+	case "max":
+		return fmt.Errorf("%s: must be at most %s, got %v", field, fe.Param(), value)
+	case "oneof":
+		return fmt.Errorf("%s: must be one of [%s], got %q", field, fe.Param(), value)
+	case "unique":
+		return fmt.Errorf("%s: duplicate %s", field, fe.Param())
+	}
+	return fmt.Errorf("%s: failed %q validation, got %v", field, fe.Tag(), value)
+}
+
+// requiredCondition 获取 required_if/required_with 依赖的字段
+// required_if 的参数为 "字段 值"，required_with 的参数为空格分隔的字段列表
+func requiredCondition(fe validator.FieldError) string {
+	if fe.Tag() == "required_if" {
+		if field, _, ok := strings.Cut(fe.Param(), " "); ok {
+			return field
+		}
+	}
+	return strings.Join(strings.Fields(fe.Param()), " or ")
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+// validConfig 返回能通过检查的最小配置
+func validConfig() *Config {
+	return &Config{
+		ValidateInterval:          "@every 1m",
+		CleanupInterval:           "0 0 * * * *",
+		OptimizeInterval:          "@hourly",
+		AgeCleanupInterval:        "@daily",
+		OrphanSweepInterval:       "@daily",
+		ReputationCleanupInterval: "@daily",
+		MaxFailCount:              3,
+		HighScoreMaxConcurrent:    20,
+		ReputationBanTTL:          time.Hour,
+		BalancerRefreshInterval:   time.Minute,
+		ListenAddrs:               []string{":8080"},
+	}
+}
+
+// assertConfigErrors 检查 Validate 的错误包含 want 中的每一项，want 为空时要求通过检查
+func assertConfigErrors(t *testing.T, name string, c *Config, want ...string) {
+	t.Helper()
+	err := c.Validate()
+	if len(want) == 0 {
+		if err != nil {
+			t.Errorf("%s: Validate() = %v, want nil", name, err)
+		}
+		return
+	}
+	if err == nil {
+		t.Errorf("%s: Validate() = nil, want errors for %v", name, want)
+		return
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("%s: Validate() = %q, want it to mention %q", name, err, w)
+		}
+	}
+}
+
+func TestConfigValidateCronAndLimits(t *testing.T) {
+	assertConfigErrors(t, "valid", validConfig())
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string
+	}{
+		{"bad validate interval", func(c *Config) { c.ValidateInterval = "every minute" },
+			[]string{`ValidateInterval: invalid cron expression "every minute"`}},
+		// 定时任务使用带秒的解析器，5段表达式不合法
+		{"five field cron", func(c *Config) { c.CleanupInterval = "0 * * * *" },
+			[]string{"CleanupInterval: invalid cron expression"}},
+		{"bad per type interval", func(c *Config) {
+			c.ValidateIntervals = map[models.ProxyType]string{models.ProxyTypeLong: "@every 30m", models.ProxyTypeTemp: "bad"}
+		}, []string{`ValidateIntervals[temp]: invalid cron expression "bad"`}},
+		{"bad optional interval", func(c *Config) { c.DecisionFlushInterval = "* *" },
+			[]string{"DecisionFlushInterval: invalid cron expression"}},
+		{"paid interval ignored without paid sources", func(c *Config) { c.PaidInterval = "bad" }, nil},
+		{"paid interval checked with paid sources", func(c *Config) {
+			c.KuaidailiURL, c.PaidInterval = "https://example.com/api", "bad"
+		}, []string{`PaidInterval: invalid cron expression "bad"`}},
+		{"free interval checked with free api", func(c *Config) { c.UseFreeAPI, c.FreeInterval = true, "bad" },
+			[]string{`FreeInterval: invalid cron expression "bad"`}},
+		{"idle above open conns", func(c *Config) { c.DBMaxOpenConns, c.DBMaxIdleConns = 5, 10 },
+			[]string{"DBMaxOpenConns: 5 is less than DBMaxIdleConns 10"}},
+		{"unsupported protocol", func(c *Config) { c.RequiredProtocols = []string{"http", "ftp"} },
+			[]string{`RequiredProtocols: unsupported protocol "ftp"`}},
+		{"all problems reported together", func(c *Config) {
+			c.ValidateInterval, c.OptimizeInterval, c.DBMaxOpenConns, c.DBMaxIdleConns = "x", "y", 1, 2
+		}, []string{"ValidateInterval:", "OptimizeInterval:", "DBMaxOpenConns:"}},
+	}
+	for _, tt := range tests {
+		c := validConfig()
+		tt.modify(c)
+		assertConfigErrors(t, tt.name, c, tt.want...)
+	}
+}
+
+func TestConfigValidateFieldTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string
+	}{
+		{"required interval", func(c *Config) { c.ValidateInterval = "" }, []string{"ValidateInterval: is required"}},
+		{"no listen addrs", func(c *Config) { c.ListenAddrs = nil }, []string{"ListenAddrs: is required"}},
+		{"empty listen addr", func(c *Config) { c.ListenAddrs = []string{":8080", ""} }, []string{"ListenAddrs[1]: is required"}},
+		{"max fail count", func(c *Config) { c.MaxFailCount = 0 }, []string{"MaxFailCount: must be at least 1, got 0"}},
+		{"per type max fail count", func(c *Config) {
+			c.TypeMaxFailCount = map[models.ProxyType]int{models.ProxyTypeLong: -1}
+		}, []string{"TypeMaxFailCount[long]: must be at least 0, got -1"}},
+		{"threshold range", func(c *Config) { c.HighScoreThreshold = 120 }, []string{"HighScoreThreshold: must be at most 100, got 120"}},
+		{"negative duration", func(c *Config) { c.MaxProxyAge = -time.Hour }, []string{"MaxProxyAge: must be positive, got -1h0m0s"}},
+		// 为0时使用默认值的时长不报错，直接使用的时长必须为正
+		{"zero defaulted duration", func(c *Config) { c.WarmupTimeout = 0 }, nil},
+		{"zero ban ttl", func(c *Config) { c.ReputationBanTTL = 0 }, []string{"ReputationBanTTL: must be positive, got 0s"}},
+		{"enum", func(c *Config) { c.ProbeType = "icmp" }, []string{`ProbeType: must be one of [tcp head], got "icmp"`}},
+		{"paid url", func(c *Config) {
+			c.KuaidailiURL, c.PaidInterval = "ftp://dps.example.com/api?secret_id=abc&signature=xyz", "@hourly"
+		}, []string{`KuaidailiURL: invalid http(s) url "ftp://dps.example.com/api?secret_id=***&signature=***"`}},
+		{"required with paid source", func(c *Config) { c.WandouURL = "https://api.example.com/get" },
+			[]string{"PaidInterval: is required when KuaidailiURL or WandouURL is set"}},
+		{"required if free api", func(c *Config) { c.UseFreeAPI = true }, []string{"FreeInterval: is required when UseFreeAPI is set"}},
+		{"required if tls", func(c *Config) { c.TLSEnabled, c.TLSCertFile = true, "cert.pem" },
+			[]string{"TLSKeyFile: is required when TLSEnabled is set"}},
+		{"api key fields", func(c *Config) {
+			c.APIKeys = []APIKey{{Name: "ops", Key: "k1", Scopes: []APIScope{"root"}}, {Name: "", Key: "k2", Scopes: []APIScope{ScopeRead}}}
+		}, []string{`APIKeys[0].Scopes[0]: must be one of [read report admin], got "root"`, "APIKeys[1].Name: is required"}},
+		{"duplicate api key names", func(c *Config) {
+			c.APIKeys = []APIKey{{Name: "ops", Key: "k1", Scopes: []APIScope{ScopeRead}}, {Name: "ops", Key: "k2", Scopes: []APIScope{ScopeRead}}}
+		}, []string{"APIKeys: duplicate Name"}},
+	}
+	for _, tt := range tests {
+		c := validConfig()
+		tt.modify(c)
+		assertConfigErrors(t, tt.name, c, tt.want...)
+	}
+
+	// 错误信息中不包含密钥
+	c := validConfig()
+	c.KuaidailiURL, c.PaidInterval = "ftp://dps.example.com/api?secret_id=abc", "@hourly"
+	if err := c.Validate(); err == nil || strings.Contains(err.Error(), "abc") {
+		t.Errorf("Validate() = %v, want the url reported without its secret", err)
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"proxy_pool/metrics"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// CronJobStatus 定时任务的运行状态
+type CronJobStatus struct {
+	Name         string     `json:"name"`
+	Spec         string     `json:"spec"`
+	Running      bool       `json:"running"`
+	Runs         int        `json:"runs"`          // 运行次数，包括发生panic的运行
+	Panics       int        `json:"panics"`        // 发生panic的次数
+	LastRun      *time.Time `json:"last_run"`      // 最近一次开始运行的时间
+	LastDuration string     `json:"last_duration"` // 最近一次运行耗时
+	LastPanic    string     `json:"last_panic,omitempty"`
+	LastPanicAt  *time.Time `json:"last_panic_at,omitempty"`
+}
+
+// CronJobs 定时任务管理器
+// 每个任务在 recover 中运行，任务panic时记录堆栈和状态并计入指标，不影响进程和下一次运行；
+// 注册任务时的表达式错误会累积，由 Err 一次性返回
+type CronJobs struct {
+	cron   *cron.Cron
+	logger *zap.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*CronJobStatus
+	errs []error
+}
+
+// NewCronJobs 创建定时任务管理器，表达式包含秒，上一次运行未结束时跳过本次
+func NewCronJobs(logger *zap.Logger) *CronJobs {
+	return &CronJobs{
+		cron: cron.New(cron.WithSeconds(), cron.WithChain(
+			cron.SkipIfStillRunning(cron.DefaultLogger),
+		)),
+		logger: logger,
+		jobs:   make(map[string]*CronJobStatus),
+	}
+}
+
+// Add 注册定时任务，name 需唯一；表达式错误或名称重复时记录错误，由 Err 返回
+func (j *CronJobs) Add(name, spec string, fn func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.jobs[name]; ok {
+		j.errs = append(j.errs, fmt.Errorf("cron job %q: duplicate name", name))
+		return
+	}
+	if _, err := j.cron.AddFunc(spec, func() { j.run(name, fn) }); err != nil {
+		j.errs = append(j.errs, fmt.Errorf("cron job %q: invalid spec %q: %w", name, spec, err))
+		return
+	}
+	j.jobs[name] = &CronJobStatus{Name: name, Spec: spec}
+}
+
+// Err 返回注册任务时的所有错误
+func (j *CronJobs) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return errors.Join(j.errs...)
+}
+
+// Start 启动定时任务
+func (j *CronJobs) Start() {
+	j.cron.Start()
+}
+
+// Stop 停止调度新的运行，返回的 ctx 在运行中的任务都结束后完成
+func (j *CronJobs) Stop() context.Context {
+	return j.cron.Stop()
+}
+
+// Statuses 获取所有定时任务的运行状态，按名称排序
+func (j *CronJobs) Statuses() []CronJobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	statuses := make([]CronJobStatus, 0, len(j.jobs))
+	for _, status := range j.jobs {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(a, b int) bool {
+		return statuses[a].Name < statuses[b].Name
+	})
+	return statuses
+}
+
+// run 运行一次任务，恢复任务中的panic
+func (j *CronJobs) run(name string, fn func()) {
+	start := time.Now()
+	j.mu.Lock()
+	status := j.jobs[name]
+	status.Running = true
+	status.Runs++
+	status.LastRun = &start
+	j.mu.Unlock()
+
+	defer func() {
+		r := recover()
+
+		j.mu.Lock()
+		status.Running = false
+		status.LastDuration = time.Since(start).String()
+		if r != nil {
+			now := time.Now()
+			status.Panics++
+			status.LastPanic = fmt.Sprint(r)
+			status.LastPanicAt = &now
+		}
+		j.mu.Unlock()
+
+		if r != nil {
+			metrics.CronJobPanics.WithLabelValues(name).Inc()
+			j.logger.Error("定时任务发生panic，等待下一次运行",
+				zap.String("任务", name),
+				zap.Any("panic", r),
+				zap.ByteString("堆栈", debug.Stack()),
+			)
+		}
+	}()
+
+	fn()
+}
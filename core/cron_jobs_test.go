@@ -0,0 +1,60 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"proxy_pool/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func TestCronJobsRecoverPanics(t *testing.T) {
+	jobs := NewCronJobs(zap.NewNop())
+	jobs.Add("flaky", "@every 1h", func() {})
+	if err := jobs.Err(); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	panics := metrics.CronJobPanics.WithLabelValues("flaky")
+	before := testutil.ToFloat64(panics)
+
+	// panic 被恢复，下一次运行照常执行
+	jobs.run("flaky", func() { panic("boom") })
+	jobs.run("flaky", func() {})
+
+	statuses := jobs.Statuses()
+	if len(statuses) != 1 {
+		t.Fatalf("statuses = %+v, want one job", statuses)
+	}
+	status := statuses[0]
+	if status.Runs != 2 || status.Panics != 1 || status.Running || status.LastPanic != "boom" ||
+		status.LastPanicAt == nil || status.LastRun == nil || status.Spec != "@every 1h" {
+		t.Errorf("status = %+v, want 2 runs with one recorded panic", status)
+	}
+	if got := testutil.ToFloat64(panics) - before; got != 1 {
+		t.Errorf("cron_job_panics_total{job=flaky} increased by %v, want 1", got)
+	}
+}
+
+func TestCronJobsCollectRegistrationErrors(t *testing.T) {
+	jobs := NewCronJobs(zap.NewNop())
+	jobs.Add("validate", "@every 1m", func() {})
+	jobs.Add("validate", "@every 5m", func() {})
+	jobs.Add("cleanup", "not a spec", func() {})
+	jobs.Add("optimize", "0 * * * *", func() {})
+
+	err := jobs.Err()
+	if err == nil {
+		t.Fatal("Err() = nil, want the registration errors")
+	}
+	for _, want := range []string{`"validate": duplicate name`, `"cleanup": invalid spec`, `"optimize": invalid spec`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Err() = %q, want it to mention %q", err, want)
+		}
+	}
+	// 注册失败的任务不出现在状态中
+	if statuses := jobs.Statuses(); len(statuses) != 1 || statuses[0].Name != "validate" || statuses[0].Spec != "@every 1m" {
+		t.Errorf("statuses = %+v, want only the first validate job", statuses)
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultDecisionLogSize = 10000         // 默认保留的调度记录数
+	DefaultFairnessWindow  = 1 * time.Hour // 默认公平性统计窗口
+
+	strategyDefault  = "default" // 未指定调度策略时记录的策略名
+	maxDecisionFlush = 1000      // 单次写入Redis的最大记录数
+)
+
+// SchedulingDecision 一次代理发放记录
+type SchedulingDecision struct {
+	ProxyID   uint      `json:"proxy_id"`
+	Strategy  string    `json:"strategy"`
+	Domain    string    `json:"domain,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ProxyHandoutCount 代理在统计窗口内的发放次数
+type ProxyHandoutCount struct {
+	ProxyID uint  `json:"proxy_id"`
+	Count   int64 `json:"count"`
+}
+
+// FairnessReport 发放公平性统计
+type FairnessReport struct {
+	Window    string              `json:"window"`
+	Total     int64               `json:"total"`     // 窗口内的发放次数
+	Proxies   int                 `json:"proxies"`   // 参与统计的代理数，含未发放的可用代理
+	Starved   int                 `json:"starved"`   // 窗口内未发放过的可用代理数
+	Gini      float64             `json:"gini"`      // 基尼系数，0为完全平均，越接近1越集中
+	Truncated bool                `json:"truncated"` // 缓冲区已覆盖窗口内较早的记录，统计不完整
+	Counts    []ProxyHandoutCount `json:"counts"`    // 按发放次数降序
+}
+
+// DecisionLog 调度记录环形缓冲区，用于审计调度策略的公平性
+// 写入只在短暂持锁时覆盖一个槽位，不影响发放代理；可定期写入Redis，Redis不可用时跳过
+type DecisionLog struct {
+	redis  *RedisGuard
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	entries []SchedulingDecision
+	next    uint64 // 已记录的总数，下一条写入 entries[next%len(entries)]
+	flushed uint64 // 已写入Redis的记录数
+}
+
+// NewDecisionLog 创建调度记录
+func NewDecisionLog(guard *RedisGuard, logger *zap.Logger) *DecisionLog {
+	return &DecisionLog{
+		redis:   guard,
+		logger:  logger,
+		entries: make([]SchedulingDecision, DefaultDecisionLogSize),
+	}
+}
+
+// SetSize 设置保留的记录数，非正值表示保持不变，修改后清空已有记录
+func (l *DecisionLog) SetSize(size int) {
+	if size <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size == len(l.entries) {
+		return
+	}
+	l.entries = make([]SchedulingDecision, size)
+	l.next = 0
+	l.flushed = 0
+}
+
+// Record 记录一次代理发放，未指定调度策略时记为 default
+func (l *DecisionLog) Record(proxyID uint, strategy ScheduleStrategy, domain string) {
+	decision := SchedulingDecision{
+		ProxyID:   proxyID,
+		Strategy:  string(strategy),
+		Domain:    domain,
+		Timestamp: time.Now(),
+	}
+	if decision.Strategy == "" {
+		decision.Strategy = strategyDefault
+	}
+
+	l.mu.Lock()
+	l.entries[l.next%uint64(len(l.entries))] = decision
+	l.next++
+	l.mu.Unlock()
+}
+
+// since 按时间顺序复制序号不小于 seq 的记录，已被覆盖的记录跳过，调用方需持有 l.mu
+// 返回第一条记录的序号，大于 seq 时表示有记录已被覆盖
+func (l *DecisionLog) since(seq uint64) ([]SchedulingDecision, uint64) {
+	size := uint64(len(l.entries))
+	if l.next > size && seq < l.next-size {
+		seq = l.next - size
+	}
+	if seq >= l.next {
+		return nil, seq
+	}
+
+	decisions := make([]SchedulingDecision, 0, l.next-seq)
+	for i := seq; i < l.next; i++ {
+		decisions = append(decisions, l.entries[i%size])
+	}
+	return decisions, seq
+}
+
+// Recent 获取最近的 limit 条记录，按时间倒序
+func (l *DecisionLog) Recent(limit int) []SchedulingDecision {
+	l.mu.Lock()
+	var start uint64
+	if limit > 0 && l.next > uint64(limit) {
+		start = l.next - uint64(limit)
+	}
+	decisions, _ := l.since(start)
+	l.mu.Unlock()
+
+	for i, j := 0, len(decisions)-1; i < j; i, j = i+1, j-1 {
+		decisions[i], decisions[j] = decisions[j], decisions[i]
+	}
+	return decisions
+}
+
+// Fairness 统计 window 内各代理的发放次数和集中程度
+// candidates 为当前可用的代理，未发放过的也计入统计，用于发现长期得不到发放的代理
+func (l *DecisionLog) Fairness(window time.Duration, candidates []uint) FairnessReport {
+	cutoff := time.Now().Add(-window)
+
+	l.mu.Lock()
+	decisions, start := l.since(0)
+	l.mu.Unlock()
+
+	counts := make(map[uint]int64, len(candidates))
+	for _, id := range candidates {
+		counts[id] = 0
+	}
+	report := FairnessReport{Window: window.String()}
+	for _, d := range decisions {
+		if d.Timestamp.Before(cutoff) {
+			continue
+		}
+		counts[d.ProxyID]++
+		report.Total++
+	}
+	// 被覆盖的记录只有在最早保留的记录仍处于窗口内时才影响统计
+	report.Truncated = start > 0 && len(decisions) > 0 && !decisions[0].Timestamp.Before(cutoff)
+
+	report.Counts = make([]ProxyHandoutCount, 0, len(counts))
+	for id, count := range counts {
+		report.Counts = append(report.Counts, ProxyHandoutCount{ProxyID: id, Count: count})
+		if count == 0 {
+			report.Starved++
+		}
+	}
+	sort.Slice(report.Counts, func(i, j int) bool {
+		if report.Counts[i].Count != report.Counts[j].Count {
+			return report.Counts[i].Count > report.Counts[j].Count
+		}
+		return report.Counts[i].ProxyID < report.Counts[j].ProxyID
+	})
+	report.Proxies = len(report.Counts)
+	report.Gini = giniCoefficient(report.Counts)
+	return report
+}
+
+// giniCoefficient 计算发放次数的基尼系数，counts 需按次数降序
+// 代理少于2个或没有发放时返回0
+func giniCoefficient(counts []ProxyHandoutCount) float64 {
+	n := len(counts)
+	if n < 2 {
+		return 0
+	}
+
+	// 升序排列时 G = 2*Σ(i*x_i)/(n*Σx_i) - (n+1)/n，i 从1开始
+	var sum, weighted float64
+	for i, c := range counts {
+		rank := float64(n - i)
+		sum += float64(c.Count)
+		weighted += rank * float64(c.Count)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return 2*weighted/(float64(n)*sum) - float64(n+1)/float64(n)
+}
+
+// Flush 将上次写入后的新记录追加到Redis列表，列表只保留最近的 len(entries) 条
+// Redis不可用时保留进度，下次再写入；期间被覆盖的记录不再写入
+func (l *DecisionLog) Flush() {
+	l.mu.Lock()
+	decisions, start := l.since(l.flushed)
+	if len(decisions) > maxDecisionFlush {
+		decisions = decisions[:maxDecisionFlush]
+	}
+	end := start + uint64(len(decisions))
+	size := int64(len(l.entries))
+	l.mu.Unlock()
+
+	if len(decisions) == 0 {
+		return
+	}
+
+	values := make([]interface{}, 0, len(decisions))
+	for _, d := range decisions {
+		data, err := json.Marshal(d)
+		if err != nil {
+			continue
+		}
+		values = append(values, data)
+	}
+
+	key := l.redis.Key("scheduler", "decisions")
+	err := l.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		pipe := client.TxPipeline()
+		pipe.RPush(ctx, key, values...)
+		pipe.LTrim(ctx, key, -size, -1)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		if err != ErrRedisDegraded {
+			l.logger.Warn("调度记录写入Redis失败",
+				zap.Int("记录数", len(values)),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	l.mu.Lock()
+	// 写入期间修改了缓冲区大小时进度已重置
+	if end > l.flushed && end <= l.next {
+		l.flushed = end
+	}
+	l.mu.Unlock()
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// decisionIDs 取出调度记录中的代理ID
+func decisionIDs(decisions []SchedulingDecision) []uint {
+	ids := make([]uint, len(decisions))
+	for i, d := range decisions {
+		ids[i] = d.ProxyID
+	}
+	return ids
+}
+
+func TestDecisionLogRingBuffer(t *testing.T) {
+	pool, _ := newTestPool(t)
+	log := NewDecisionLog(pool.RedisGuard(), zap.NewNop())
+	log.SetSize(3)
+
+	for id := uint(1); id <= 5; id++ {
+		log.Record(id, "", "example.com")
+	}
+	if got := decisionIDs(log.Recent(10)); !reflect.DeepEqual(got, []uint{5, 4, 3}) {
+		t.Errorf("Recent(10) = %v, want the newest 3 first", got)
+	}
+	recent := log.Recent(2)
+	if got := decisionIDs(recent); !reflect.DeepEqual(got, []uint{5, 4}) {
+		t.Errorf("Recent(2) = %v, want [5 4]", got)
+	}
+	if recent[0].Strategy != strategyDefault || recent[0].Domain != "example.com" {
+		t.Errorf("decision = %+v, want default strategy and the task domain", recent[0])
+	}
+}
+
+func TestDecisionLogFairness(t *testing.T) {
+	pool, _ := newTestPool(t)
+	log := NewDecisionLog(pool.RedisGuard(), zap.NewNop())
+
+	// 两个代理平均分配，没有未发放的代理
+	for i := 0; i < 4; i++ {
+		log.Record(uint(i%2+1), StrategyRoundRobin, "")
+	}
+	report := log.Fairness(time.Hour, []uint{1, 2})
+	if report.Total != 4 || report.Proxies != 2 || report.Starved != 0 || report.Gini != 0 || report.Truncated {
+		t.Errorf("even report = %+v, want 4 handouts over 2 proxies with gini 0", report)
+	}
+
+	// 可用但从未发放的代理计入统计
+	for i := 0; i < 4; i++ {
+		log.Record(1, StrategyRoundRobin, "")
+	}
+	report = log.Fairness(time.Hour, []uint{1, 2, 3})
+	want := []ProxyHandoutCount{{1, 6}, {2, 2}, {3, 0}}
+	if !reflect.DeepEqual(report.Counts, want) || report.Starved != 1 {
+		t.Errorf("counts = %v, starved = %d, want %v and 1 starved", report.Counts, report.Starved, want)
+	}
+	// 升序 0,2,6：G = 2*(1*0+2*2+3*6)/(3*8) - 4/3 = 0.5
+	if math.Abs(report.Gini-0.5) > 1e-9 {
+		t.Errorf("gini = %v, want 0.5", report.Gini)
+	}
+
+	// 缓冲区覆盖了窗口内的记录时标记为不完整
+	log.SetSize(2)
+	for i := 0; i < 3; i++ {
+		log.Record(1, StrategyRoundRobin, "")
+	}
+	if report := log.Fairness(time.Hour, nil); !report.Truncated || report.Total != 2 {
+		t.Errorf("report = %+v, want 2 handouts and truncated", report)
+	}
+}
+
+func TestDecisionLogFlush(t *testing.T) {
+	pool, mr := newTestPool(t)
+	log := NewDecisionLog(pool.RedisGuard(), zap.NewNop())
+	log.SetSize(3)
+	key := pool.RedisGuard().Key("scheduler", "decisions")
+
+	flushed := func() []uint {
+		t.Helper()
+		values, err := mr.List(key)
+		if err != nil {
+			t.Fatalf("list %s: %v", key, err)
+		}
+		ids := make([]uint, len(values))
+		for i, v := range values {
+			var d SchedulingDecision
+			if err := json.Unmarshal([]byte(v), &d); err != nil {
+				t.Fatalf("decode %q: %v", v, err)
+			}
+			ids[i] = d.ProxyID
+		}
+		return ids
+	}
+
+	log.Record(1, "", "")
+	log.Record(2, "", "")
+	log.Flush()
+	// 只追加上次写入后的新记录，列表保留最近的 size 条
+	log.Record(3, "", "")
+	log.Record(4, "", "")
+	log.Flush()
+	log.Flush()
+	if got := flushed(); !reflect.DeepEqual(got, []uint{2, 3, 4}) {
+		t.Errorf("redis decisions = %v, want [2 3 4]", got)
+	}
+}
+
+func TestGetProxyForTaskRecordsDecision(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+	// Available 默认值为 true，创建后再标记为不可用
+	unavailable := newTestProxy(t, pool.DB(), "1.1.1.2")
+	pool.DB().Model(unavailable).UpdateColumn("available", false)
+
+	task := &Task{ProxyType: models.ProxyTypeTemp, Strategy: StrategyLeastUsed, Domain: "example.com"}
+	got, err := pool.GetProxyForTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("GetProxyForTask: %v", err)
+	}
+	pool.releaseProxy(task, got.ID)
+
+	decisions := pool.DecisionLog().Recent(0)
+	if len(decisions) != 1 || decisions[0].ProxyID != proxy.ID || decisions[0].Strategy != string(StrategyLeastUsed) {
+		t.Errorf("decisions = %+v, want one leastused decision for proxy %d", decisions, proxy.ID)
+	}
+	report, err := pool.SchedulerFairness(time.Hour)
+	if err != nil {
+		t.Fatalf("SchedulerFairness: %v", err)
+	}
+	if report.Total != 1 || report.Proxies != 1 {
+		t.Errorf("fairness = %+v, want only the available proxy counted", report)
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"proxy_pool/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventType 代理池事件类型
+type EventType string
+
+const (
+	EventProxyAdded    EventType = "proxy_added"    // 新增代理
+	EventProxyRemoved  EventType = "proxy_removed"  // 删除代理
+	EventProxyDown     EventType = "proxy_down"     // 存活检查连接失败，代理标记为不可用
+	EventProxyUp       EventType = "proxy_up"       // 存活检查标记为不可用的代理恢复连接
+	EventProxyRetained EventType = "proxy_retained" // 观察模式下本应清理的代理，只标记为不可用，Action 为本应执行的操作
+)
+
+// Event 代理池事件
+type Event struct {
+	Type      EventType        `json:"type"`
+	ProxyID   uint             `json:"proxy_id"`
+	ProxyType models.ProxyType `json:"proxy_type,omitempty"` // 为空表示类型未知
+	Score     float64          `json:"score"`
+	Action    string           `json:"action,omitempty"` // proxy_retained 事件中本应执行的清理操作
+	Time      time.Time        `json:"time"`
+}
+
+// NewProxyEvent 根据代理生成事件
+func NewProxyEvent(eventType EventType, proxy *models.Proxy) Event {
+	return Event{
+		Type:      eventType,
+		ProxyID:   proxy.ID,
+		ProxyType: proxy.Type,
+		Score:     proxy.Score,
+		Time:      time.Now(),
+	}
+}
+
+// subscription 事件订阅
+type subscription struct {
+	ch    chan Event
+	types map[EventType]bool // 为空表示订阅所有事件
+}
+
+// EventBus 进程内事件总线
+// 发布不会阻塞，订阅者缓冲区已满时丢弃事件
+type EventBus struct {
+	logger *zap.Logger
+
+	mu     sync.RWMutex
+	subs   map[int]*subscription
+	nextID int
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus(logger *zap.Logger) *EventBus {
+	return &EventBus{
+		logger: logger,
+		subs:   make(map[int]*subscription),
+	}
+}
+
+// Subscribe 订阅指定类型的事件，未指定类型时订阅所有事件
+// 返回事件通道和取消订阅函数，取消后通道会被关闭
+func (b *EventBus) Subscribe(buffer int, types ...EventType) (<-chan Event, func()) {
+	sub := &subscription{
+		ch:    make(chan Event, buffer),
+		types: make(map[EventType]bool, len(types)),
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, unsubscribe
+}
+
+// Publish 发布事件
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if len(sub.types) > 0 && !sub.types[event.Type] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.logger.Debug("事件订阅者缓冲区已满，丢弃事件",
+				zap.String("事件类型", string(event.Type)),
+				zap.Uint("代理ID", event.ProxyID),
+			)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"proxy_pool/models"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// exportProgressInterval 导出时每多少个代理输出一次进度
+const exportProgressInterval = 500
+
+// exportCSVHeader CSV导出的表头
+var exportCSVHeader = []string{
+	"id", "ip", "port", "protocol", "type", "region", "source", "anonymous",
+	"score", "speed", "success_rate", "available", "last_check",
+}
+
+// ExportJSON 将符合条件的可用代理以JSON数组流式写入 w，不会一次性加载全部代理
+func (p *ProxyPool) ExportJSON(w io.Writer, filter models.ListFilter) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	count := 0
+	err := models.EachAvailable(p.db, filter, func(proxy *models.Proxy) error {
+		if count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(proxy.Clone()); err != nil {
+			return err
+		}
+		count++
+		p.logExportProgress("json", count)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "]\n"); err != nil {
+		return err
+	}
+	p.logger.Info("代理导出完成", zap.String("格式", "json"), zap.Int("代理数", count))
+	return nil
+}
+
+// ExportCSV 将符合条件的可用代理以CSV流式写入 w，第一行为表头
+func (p *ProxyPool) ExportCSV(w io.Writer, filter models.ListFilter) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return err
+	}
+
+	count := 0
+	err := models.EachAvailable(p.db, filter, func(proxy *models.Proxy) error {
+		proxy = proxy.Clone()
+		cw.Write([]string{
+			strconv.FormatUint(uint64(proxy.ID), 10),
+			proxy.IP,
+			strconv.Itoa(proxy.Port),
+			proxy.Protocol,
+			string(proxy.Type),
+			string(proxy.Region),
+			proxy.Source,
+			strconv.FormatBool(proxy.Anonymous),
+			strconv.FormatFloat(proxy.Score, 'f', 2, 64),
+			strconv.FormatInt(proxy.Speed, 10),
+			strconv.FormatFloat(proxy.GetSuccessRate(), 'f', 2, 64),
+			strconv.FormatBool(proxy.Available),
+			proxy.LastCheck.Format(time.RFC3339),
+		})
+		count++
+		p.logExportProgress("csv", count)
+
+		// 每批刷新一次，避免在内存中积累
+		if count%exportProgressInterval == 0 {
+			cw.Flush()
+		}
+		return cw.Error()
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	p.logger.Info("代理导出完成", zap.String("格式", "csv"), zap.Int("代理数", count))
+	return nil
+}
+
+// ExportNDJSON 将符合条件的可用代理按ID升序逐行写入 w，每行一个JSON对象
+// 每批按ID游标查询 exportProgressInterval 个代理，不长时间占用数据库连接；w 支持 Flush 时每批写完后刷新
+func (p *ProxyPool) ExportNDJSON(w io.Writer, filter models.ListFilter) error {
+	flusher, _ := w.(interface{ Flush() })
+	enc := json.NewEncoder(w)
+
+	var cursor uint
+	count := 0
+	for {
+		proxies, err := models.ListAvailableAfterCursor(p.db, filter, cursor, exportProgressInterval)
+		if err != nil {
+			return err
+		}
+		for _, proxy := range proxies {
+			if err := enc.Encode(proxy.Clone()); err != nil {
+				return err
+			}
+		}
+		count += len(proxies)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(proxies) < exportProgressInterval {
+			break
+		}
+		cursor = proxies[len(proxies)-1].ID
+		p.logExportProgress("ndjson", count)
+	}
+
+	p.logger.Info("代理导出完成", zap.String("格式", "ndjson"), zap.Int("代理数", count))
+	return nil
+}
+
+// logExportProgress 每导出 exportProgressInterval 个代理输出一次进度
+func (p *ProxyPool) logExportProgress(format string, count int) {
+	if count%exportProgressInterval == 0 {
+		p.logger.Info("代理导出中", zap.String("格式", format), zap.Int("已导出", count))
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestFailPolicyMaxFailCount(t *testing.T) {
+	models.SetCleanupPolicies(map[string]models.CleanupPolicy{"paid": {MaxFailCount: 10}})
+	t.Cleanup(func() { models.SetCleanupPolicies(nil) })
+
+	policy := FailPolicy{
+		MaxFailCount:     3,
+		TypeMaxFailCount: map[models.ProxyType]int{models.ProxyTypeTemp: 2, models.ProxyTypeLong: 0},
+	}
+	tests := []struct {
+		proxy *models.Proxy
+		want  int
+	}{
+		{&models.Proxy{Type: models.ProxyTypeTemp, Source: "free"}, 2},
+		// 设为0的类型使用默认值
+		{&models.Proxy{Type: models.ProxyTypeLong, Source: "free"}, 3},
+		{&models.Proxy{Type: models.ProxyTypeAnon, Source: "free"}, 3},
+		// 代理源清理策略优先于按类型的配置
+		{&models.Proxy{Type: models.ProxyTypeTemp, Source: "paid"}, 10},
+	}
+	for _, tt := range tests {
+		if got := policy.MaxFailCountForProxy(tt.proxy); got != tt.want {
+			t.Errorf("%s proxy from %s: max fail count = %d, want %d", tt.proxy.Type, tt.proxy.Source, got, tt.want)
+		}
+	}
+}
+
+func TestRuntimeConfigUpdatesTypeMaxFailCount(t *testing.T) {
+	pool, _ := newTestPool(t)
+	if err := pool.SetFailPolicy(FailPolicy{
+		MaxFailCount:     3,
+		TypeMaxFailCount: map[models.ProxyType]int{models.ProxyTypeTemp: 2, models.ProxyTypeLong: 6},
+	}); err != nil {
+		t.Fatalf("SetFailPolicy: %v", err)
+	}
+	before := pool.FailPolicy()
+
+	// 只修改传入的类型，设为0时恢复使用默认值
+	if _, err := pool.RuntimeConfig().Update([]byte(`{"type_max_fail_count": {"temp": 0, "anon": 4}}`)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	policy := pool.FailPolicy()
+	for proxyType, want := range map[models.ProxyType]int{
+		models.ProxyTypeTemp: 3, models.ProxyTypeLong: 6, models.ProxyTypeAnon: 4, models.ProxyTypeHighAnon: 3,
+	} {
+		if got := policy.MaxFailCountFor(proxyType); got != want {
+			t.Errorf("%s max fail count = %d, want %d", proxyType, got, want)
+		}
+	}
+	// 之前的快照不受修改影响
+	if got := before.MaxFailCountFor(models.ProxyTypeTemp); got != 2 {
+		t.Errorf("earlier snapshot temp max fail count = %d, want 2", got)
+	}
+
+	for _, patch := range []string{
+		`{"type_max_fail_count": {"vip": 2}}`,
+		`{"type_max_fail_count": {"temp": -1}}`,
+	} {
+		if _, err := pool.RuntimeConfig().Update([]byte(patch)); !errors.Is(err, ErrInvalidRuntimeConfig) {
+			t.Errorf("Update(%s) error = %v, want %v", patch, err, ErrInvalidRuntimeConfig)
+		}
+	}
+	if err := pool.SetFailPolicy(FailPolicy{MaxFailCount: 0}); !errors.Is(err, ErrInvalidRuntimeConfig) {
+		t.Errorf("SetFailPolicy with no default error = %v, want %v", err, ErrInvalidRuntimeConfig)
+	}
+	if got := pool.FailPolicy().MaxFailCount; got != 3 {
+		t.Errorf("max fail count after rejected policy = %d, want 3", got)
+	}
+}
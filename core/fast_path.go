@@ -0,0 +1,191 @@
+package core
+
+import (
+	"errors"
+	"proxy_pool/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultFastPathRefreshInterval = time.Second // 默认候选缓冲区刷新间隔
+	DefaultFastPathBufferSize      = 32          // 默认每种代理类型预选的候选代理数
+)
+
+// candidateAcquirer 可占用预选候选代理的调度器
+type candidateAcquirer interface {
+	AcquireCandidate(proxy *models.Proxy, task *Task) bool
+}
+
+// AcquireCandidate 占用预选的候选代理
+// 代理仍满足任务要求（未被排除、未冷却、未满载）时与调度一样更新使用统计和并发计数并返回 true
+func (s *ProxyScheduler) AcquireCandidate(proxy *models.Proxy, task *Task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isProxyQualified(proxy, task) {
+		return false
+	}
+	s.recordHandout(proxy)
+	s.acquireLease(proxy.ID, StrategyWeighted)
+	s.pool.realtime.RecordHandout()
+	return true
+}
+
+// fastPathEligible 任务是否可以走快速通道
+// 缓冲区按 weighted 策略预选，标签、分组和检查次数无法在内存中判断，其他条件在占用时检查
+func (t *Task) fastPathEligible() bool {
+	return (t.Strategy == "" || t.Strategy == StrategyWeighted) && t.Tag == "" && t.Group == "" && t.MinChecks == 0 && !t.HighReliability
+}
+
+// CandidateBuffer 按 weighted 策略预选的候选代理环形缓冲区
+// 后台协程定期从调度器刷新，获取代理时只检查排除和冷却、并发，不访问数据库
+type CandidateBuffer struct {
+	pool            *ProxyPool
+	proxyType       models.ProxyType
+	size            int
+	refreshInterval time.Duration
+	logger          *zap.Logger
+
+	mu         sync.Mutex
+	candidates []*models.Proxy
+	next       int
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+// NewCandidateBuffer 创建候选代理缓冲区，需调用 Start 启动后台刷新
+func NewCandidateBuffer(pool *ProxyPool, proxyType models.ProxyType, size int, refreshInterval time.Duration) *CandidateBuffer {
+	if size <= 0 {
+		size = DefaultFastPathBufferSize
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultFastPathRefreshInterval
+	}
+	return &CandidateBuffer{
+		pool:            pool,
+		proxyType:       proxyType,
+		size:            size,
+		refreshInterval: refreshInterval,
+		logger:          pool.Logger(),
+		stopCh:          make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// Start 同步填充一次缓冲区并启动后台刷新协程
+func (b *CandidateBuffer) Start() {
+	b.refresh()
+	go b.refreshLoop()
+}
+
+// Stop 停止后台刷新协程
+func (b *CandidateBuffer) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopCh)
+	})
+	<-b.done
+}
+
+// refreshLoop 按刷新间隔定期刷新缓冲区
+func (b *CandidateBuffer) refreshLoop() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+			b.refresh()
+		}
+	}
+}
+
+// refresh 从调度器获取排序后的候选代理替换缓冲区，没有候选代理时清空
+func (b *CandidateBuffer) refresh() {
+	ranked, err := b.pool.scheduler.RankCandidates(&Task{ProxyType: b.proxyType, Strategy: StrategyWeighted}, b.size)
+	var noProxy *NoProxyError
+	if err != nil && !errors.As(err, &noProxy) {
+		b.logger.Warn("候选代理缓冲区刷新失败", zap.String("代理类型", string(b.proxyType)), zap.Error(err))
+		return
+	}
+
+	candidates := make([]*models.Proxy, len(ranked))
+	for i, candidate := range ranked {
+		candidates[i] = candidate.Proxy
+	}
+
+	b.mu.Lock()
+	b.candidates = candidates
+	b.next = 0
+	b.mu.Unlock()
+}
+
+// Pop 从缓冲区轮询取出下一个仍满足任务要求的候选代理并占用，遍历一轮都不满足时返回 nil
+// 同一候选代理会发放给多个调用方，返回副本，调用方修改时不影响缓冲区和其他调用方
+func (b *CandidateBuffer) Pop(task *Task) *models.Proxy {
+	acquirer, ok := b.pool.scheduler.(candidateAcquirer)
+	if !ok {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := 0; i < len(b.candidates); i++ {
+		proxy := b.candidates[b.next]
+		b.next = (b.next + 1) % len(b.candidates)
+		if proxy.EstimatedTTL() <= 0 {
+			continue
+		}
+		if acquirer.AcquireCandidate(proxy, task) {
+			return proxy.Clone()
+		}
+	}
+	return nil
+}
+
+// Size 返回缓冲区中的候选代理数
+func (b *CandidateBuffer) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.candidates)
+}
+
+// CandidateBuffer 获取指定代理类型的候选代理缓冲区，首次使用时创建并启动
+// 首次填充需要查询数据库，在 balancerMu 之外进行，填充完成前取不到候选代理时走普通调度
+func (p *ProxyPool) CandidateBuffer(proxyType models.ProxyType) *CandidateBuffer {
+	p.balancerMu.Lock()
+	if buffer, ok := p.fastPath[proxyType]; ok {
+		p.balancerMu.Unlock()
+		return buffer
+	}
+	buffer := NewCandidateBuffer(p, proxyType, p.fastPathSize, p.fastPathInterval)
+	p.fastPath[proxyType] = buffer
+	p.balancerMu.Unlock()
+
+	buffer.Start()
+	return buffer
+}
+
+// SetFastPath 设置候选代理缓冲区的大小和刷新间隔，对之后创建的缓冲区生效，非正值表示使用默认值
+func (p *ProxyPool) SetFastPath(size int, refreshInterval time.Duration) {
+	p.balancerMu.Lock()
+	defer p.balancerMu.Unlock()
+	p.fastPathSize = size
+	p.fastPathInterval = refreshInterval
+}
+
+// fastProxy 尝试从候选代理缓冲区获取代理，任务不适用快速通道或缓冲区中没有合适的代理时返回 nil
+func (p *ProxyPool) fastProxy(task *Task) *models.Proxy {
+	if !task.fastPathEligible() {
+		return nil
+	}
+	return p.CandidateBuffer(task.ProxyType).Pop(task)
+}
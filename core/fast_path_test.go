@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+// checkedNow 将代理的最后检查时间设为当前时间，未检查过的代理不会从缓冲区发放
+func checkedNow(p *models.Proxy) {
+	p.LastCheck = time.Now()
+	p.MaxConcurrent = 1000
+}
+
+func TestCandidateBufferRefresh(t *testing.T) {
+	pool, _ := newTestPool(t)
+	a := newTestProxy(t, pool.DB(), "1.1.1.1", checkedNow)
+	b := newTestProxy(t, pool.DB(), "2.2.2.2", checkedNow)
+	newTestProxy(t, pool.DB(), "3.3.3.3", checkedNow, func(p *models.Proxy) { p.Type = models.ProxyTypeLong })
+
+	buffer := NewCandidateBuffer(pool, models.ProxyTypeTemp, 8, time.Hour)
+	if n := buffer.Size(); n != 0 {
+		t.Fatalf("size before refresh = %d, want 0", n)
+	}
+	if got := buffer.Pop(&Task{}); got != nil {
+		t.Fatalf("Pop on empty buffer = %d, want nil", got.ID)
+	}
+
+	buffer.refresh()
+	if n := buffer.Size(); n != 2 {
+		t.Fatalf("size after refresh = %d, want 2 (only temp proxies)", n)
+	}
+
+	// 轮询依次发放两个代理
+	seen := map[uint]bool{}
+	for i := 0; i < 2; i++ {
+		got := buffer.Pop(&Task{})
+		if got == nil {
+			t.Fatalf("Pop %d = nil", i)
+		}
+		seen[got.ID] = true
+	}
+	if !seen[a.ID] || !seen[b.ID] {
+		t.Errorf("popped %v, want both %d and %d", seen, a.ID, b.ID)
+	}
+
+	// 排除的代理不发放
+	if got := buffer.Pop(&Task{ExcludeIDs: []uint{a.ID, b.ID}}); got != nil {
+		t.Errorf("Pop excluding every candidate = %d, want nil", got.ID)
+	}
+
+	// 刷新后反映数据库中新增和下线的代理
+	c := newTestProxy(t, pool.DB(), "4.4.4.4", checkedNow)
+	pool.DB().Model(&models.Proxy{}).Where("id = ?", a.ID).UpdateColumn("available", false)
+	buffer.refresh()
+	var ids []uint
+	buffer.mu.Lock()
+	for _, proxy := range buffer.candidates {
+		ids = append(ids, proxy.ID)
+	}
+	buffer.mu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) != 2 || ids[0] != b.ID || ids[1] != c.ID {
+		t.Errorf("candidates after refresh = %v, want [%d %d]", ids, b.ID, c.ID)
+	}
+
+	// 刷新时没有候选代理则清空
+	pool.DB().Model(&models.Proxy{}).Where("1 = 1").UpdateColumn("available", false)
+	buffer.refresh()
+	if n := buffer.Size(); n != 0 {
+		t.Errorf("size after every proxy went offline = %d, want 0", n)
+	}
+}
+
+func TestCandidateBufferSkipsExpired(t *testing.T) {
+	pool, _ := newTestPool(t)
+	newTestProxy(t, pool.DB(), "1.1.1.1", checkedNow)
+
+	buffer := NewCandidateBuffer(pool, models.ProxyTypeTemp, 8, time.Hour)
+	buffer.refresh()
+	expired := time.Now().Add(-time.Minute)
+	buffer.candidates[0].ExpiresAt = &expired
+
+	if got := buffer.Pop(&Task{}); got != nil {
+		t.Errorf("Pop with only an expired candidate = %d, want nil", got.ID)
+	}
+}
+
+func TestCandidateBufferPopReturnsCopy(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1", checkedNow)
+
+	buffer := NewCandidateBuffer(pool, models.ProxyTypeTemp, 8, time.Hour)
+	buffer.refresh()
+
+	first := buffer.Pop(&Task{})
+	if first == nil || first.ID != proxy.ID {
+		t.Fatalf("Pop = %v, want proxy %d", first, proxy.ID)
+	}
+	first.Score = -1
+	first.Source = "modified"
+
+	second := buffer.Pop(&Task{})
+	if second == nil {
+		t.Fatal("second Pop = nil")
+	}
+	if second == first {
+		t.Fatal("Pop returned the same pointer twice")
+	}
+	if second.Score != proxy.Score || second.Source != proxy.Source {
+		t.Errorf("second Pop = score %v source %q, want the buffered values %v %q",
+			second.Score, second.Source, proxy.Score, proxy.Source)
+	}
+}
+
+func TestFastPathConcurrentHandouts(t *testing.T) {
+	pool, _ := newTestPool(t)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		newTestProxy(t, pool.DB(), ip, checkedNow)
+	}
+	pool.SetFastPath(8, 10*time.Millisecond)
+	t.Cleanup(pool.Shutdown)
+
+	// 调用方修改拿到的代理，配合 -race 检查发放的代理是否与缓冲区或其他调用方共享
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				proxy, err := pool.GetProxyForTask(context.Background(), &Task{Fast: true})
+				if err != nil {
+					t.Errorf("GetProxyForTask: %v", err)
+					return
+				}
+				proxy.Score++
+				proxy.UseCount++
+				pool.ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCandidateBufferCreatedOutsideBalancerLock(t *testing.T) {
+	pool, _ := newTestPool(t)
+	newTestProxy(t, pool.DB(), "1.1.1.1", checkedNow)
+	t.Cleanup(pool.Shutdown)
+
+	// 占住唯一的数据库连接让首次刷新阻塞，期间 balancerMu 应当可以获取
+	sqlDB, err := pool.DB().DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+
+	created := make(chan *CandidateBuffer)
+	go func() { created <- pool.CandidateBuffer(models.ProxyTypeTemp) }()
+
+	// 缓冲区登记后首次刷新仍在等待数据库连接，此时 balancerMu 应当可以获取
+	registered := false
+	for deadline := time.Now().Add(2 * time.Second); !registered && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if pool.balancerMu.TryLock() {
+			_, registered = pool.fastPath[models.ProxyTypeTemp]
+			pool.balancerMu.Unlock()
+		}
+	}
+	if !registered {
+		conn.Close()
+		t.Fatal("balancerMu held while the buffer refreshes")
+	}
+
+	conn.Close()
+	if buffer := <-created; buffer.Size() != 1 {
+		t.Errorf("size after start = %d, want 1", buffer.Size())
+	}
+}
+
+// benchmarkGetProxy 依次获取并上报代理，报告获取耗时的 p99
+func benchmarkGetProxy(b *testing.B, fast bool) {
+	pool, _ := newTestPool(b)
+	for i := 1; i <= 20; i++ {
+		newTestProxy(b, pool.DB(), fmt.Sprintf("1.1.1.%d", i), checkedNow)
+	}
+	defer pool.Shutdown()
+	if fast {
+		pool.CandidateBuffer(models.ProxyTypeTemp)
+	}
+
+	latencies := make([]time.Duration, 0, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		proxy, err := pool.GetProxyForTask(context.Background(), &Task{Strategy: StrategyWeighted, Fast: fast})
+		latencies = append(latencies, time.Since(start))
+		if err != nil {
+			b.Fatalf("GetProxyForTask: %v", err)
+		}
+		pool.Scheduler().ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+	}
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	b.ReportMetric(float64(p99.Nanoseconds()), "p99-ns")
+}
+
+func BenchmarkGetProxyFastPath(b *testing.B)   { benchmarkGetProxy(b, true) }
+func BenchmarkGetProxyNormalPath(b *testing.B) { benchmarkGetProxy(b, false) }
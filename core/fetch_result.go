@@ -0,0 +1,116 @@
+package core
+
+import (
+	"proxy_pool/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SourceFetchResult 单个代理源一次获取的统计
+type SourceFetchResult struct {
+	Source     string        `json:"source"`
+	Fetched    int           `json:"fetched"`         // 代理源返回的代理数
+	Valid      int           `json:"valid"`           // 通过验证的新代理数
+	Inserted   int           `json:"inserted"`        // 加入代理池的新代理数
+	Duplicates int           `json:"duplicates"`      // 已在代理池中而跳过的代理数
+	Errors     int           `json:"errors"`          // 获取失败或添加失败的次数
+	Error      string        `json:"error,omitempty"` // 获取失败的原因
+	Duration   time.Duration `json:"duration"`        // 从代理源获取的耗时，不含验证和入库
+}
+
+// FetchResult 一次获取的统计，按代理源区分
+type FetchResult struct {
+	StartedAt time.Time            `json:"started_at"`
+	Duration  time.Duration        `json:"duration"`
+	Sources   []*SourceFetchResult `json:"sources"`
+
+	insertedIDs []uint // 本次获取加入代理池的新代理ID，包括代理源自行保存时新建的代理
+}
+
+// newFetchResult 创建获取统计，开始时间为当前时间
+func newFetchResult() *FetchResult {
+	return &FetchResult{StartedAt: time.Now()}
+}
+
+// source 获取代理源的统计，不存在时追加
+func (r *FetchResult) source(name string) *SourceFetchResult {
+	for _, s := range r.Sources {
+		if s.Source == name {
+			return s
+		}
+	}
+	s := &SourceFetchResult{Source: name}
+	r.Sources = append(r.Sources, s)
+	return s
+}
+
+// finish 记录总耗时
+func (r *FetchResult) finish() *FetchResult {
+	r.Duration = time.Since(r.StartedAt)
+	return r
+}
+
+// merge 合并另一次获取的代理源统计，other 为空时忽略
+func (r *FetchResult) merge(other *FetchResult) {
+	if other == nil {
+		return
+	}
+	r.Sources = append(r.Sources, other.Sources...)
+	r.insertedIDs = append(r.insertedIDs, other.insertedIDs...)
+}
+
+// timedFetch 从代理源获取代理，将获取数量、耗时和错误计入 stats
+func timedFetch(stats *SourceFetchResult, fetch func() ([]*models.Proxy, error)) ([]*models.Proxy, error) {
+	start := time.Now()
+	proxies, err := fetch()
+	stats.Duration = time.Since(start)
+	if err != nil {
+		stats.Errors++
+		stats.Error = err.Error()
+		return nil, err
+	}
+	stats.Fetched = len(proxies)
+	return proxies, nil
+}
+
+// storeFetchResult 记录获取结束：计算总耗时、保存为最近一次统计并记入代理源健康状态
+func (f *ProxyFetcher) storeFetchResult(last **FetchResult, result *FetchResult) {
+	result.finish()
+	f.recordFetchResult(result)
+
+	f.mu.Lock()
+	*last = result
+	f.mu.Unlock()
+}
+
+// Totals 汇总所有代理源的统计，Source 为空
+func (r *FetchResult) Totals() SourceFetchResult {
+	var total SourceFetchResult
+	if r == nil {
+		return total
+	}
+	for _, s := range r.Sources {
+		total.Fetched += s.Fetched
+		total.Valid += s.Valid
+		total.Inserted += s.Inserted
+		total.Duplicates += s.Duplicates
+		total.Errors += s.Errors
+		total.Duration += s.Duration
+	}
+	return total
+}
+
+// Fields 汇总统计的日志字段
+func (r *FetchResult) Fields() []zap.Field {
+	total := r.Totals()
+	return []zap.Field{
+		zap.Int("代理源数", len(r.Sources)),
+		zap.Int("获取数", total.Fetched),
+		zap.Int("验证通过数", total.Valid),
+		zap.Int("新增数", total.Inserted),
+		zap.Int("重复数", total.Duplicates),
+		zap.Int("错误数", total.Errors),
+		zap.Duration("耗时", r.Duration),
+	}
+}
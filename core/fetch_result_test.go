@@ -0,0 +1,78 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proxy_pool/core/sources/paid"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+func TestAddProxiesCountsPerSource(t *testing.T) {
+	db := newTestDB(t)
+	f := NewProxyFetcher(db, zap.NewNop(), &Config{})
+	newTestProxy(t, db, "1.1.1.1")
+	newTestProxy(t, db, "2.2.2.2")
+
+	proxy := func(ip, source string) *models.Proxy {
+		return &models.Proxy{IP: ip, Port: 8080, Protocol: "http", Type: models.ProxyTypeTemp, Source: source}
+	}
+	result := newFetchResult()
+	// 已存在的代理计为重复，私有地址无效直接跳过
+	if err := f.addProxies([]*models.Proxy{
+		proxy("1.1.1.1", "a"), proxy("2.2.2.2", "a"), proxy("10.0.0.1", "a"), proxy("1.1.1.1", "b"),
+	}, result); err != nil {
+		t.Fatalf("addProxies: %v", err)
+	}
+
+	want := map[string]int{"a": 2, "b": 1}
+	if len(result.Sources) != len(want) {
+		t.Fatalf("sources = %d, want %d", len(result.Sources), len(want))
+	}
+	for _, s := range result.Sources {
+		if s.Duplicates != want[s.Source] || s.Inserted != 0 || s.Valid != 0 || s.Errors != 0 {
+			t.Errorf("source %s = %+v, want %d duplicates", s.Source, *s, want[s.Source])
+		}
+	}
+	if total := result.Totals(); total.Duplicates != 3 || total.Source != "" {
+		t.Errorf("totals = %+v, want 3 duplicates", total)
+	}
+
+	var empty *FetchResult
+	if total := empty.Totals(); total != (SourceFetchResult{}) {
+		t.Errorf("nil result totals = %+v, want zero", total)
+	}
+}
+
+func TestFetchPaidProxiesReturnsResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{KuaidailiURL: srv.URL})
+	result, _ := f.FetchPaidProxies()
+	if result == nil || len(result.Sources) != 1 {
+		t.Fatalf("result = %+v, want one source", result)
+	}
+	stats := result.Sources[0]
+	if stats.Source != paid.KuaidailiSourceName || stats.Errors != 1 || stats.Error == "" || stats.Fetched != 0 {
+		t.Errorf("source result = %+v, want one fetch error", *stats)
+	}
+	if result.Duration <= 0 || result.StartedAt.IsZero() {
+		t.Errorf("result timing = %v from %v, want set", result.Duration, result.StartedAt)
+	}
+	if last, _ := f.LastFetchResults(); last != result {
+		t.Errorf("last paid result = %p, want the returned result %p", last, result)
+	}
+
+	// 失败的获取只计入累计数量，不覆盖最近一次的数量
+	f.recordFetchResult(&FetchResult{Sources: []*SourceFetchResult{{Source: "a", Fetched: 5, Inserted: 2}}})
+	f.recordFetchResult(&FetchResult{Sources: []*SourceFetchResult{{Source: "a", Fetched: 1, Error: "timeout"}}})
+	if h := f.SourceHealth("a"); h.LastFetched != 5 || h.LastInserted != 2 || h.TotalFetched != 6 || h.TotalInserted != 2 {
+		t.Errorf("health = %+v, want last 5/2 and total 6/2", h)
+	}
+}
@@ -1,9 +1,15 @@
 package core
 
 import (
+	"context"
+	"proxy_pool/core/redact"
 	"proxy_pool/core/sources/free"
 	"proxy_pool/core/sources/paid"
 	"proxy_pool/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -12,39 +18,461 @@ import (
 // Config 代理获取器配置
 type Config struct {
 	// API配置
-	KuaidailiURL string // 快代理API URL
-	WandouURL    string // 豌豆代理API URL
+	KuaidailiURL string `validate:"omitempty,http_url"` // 快代理API URL，为空或 http(s) URL
+	WandouURL    string `validate:"omitempty,http_url"` // 豌豆代理API URL，为空或 http(s) URL
 	UseFreeAPI   bool   // 是否使用免费API
 
+	GeoNodeMaxPages int `validate:"min=0"` // GeoNode每次最多获取的页数，每页100个，0表示使用默认值，不能为负
+
 	// 定时任务配置 (cron表达式)
-	PaidInterval     string // 付费代理获取间隔
-	FreeInterval     string // 免费代理获取间隔
-	ValidateInterval string // 代理验证间隔
-	CleanupInterval  string // 过期清理间隔
-	OptimizeInterval string // 代理池优化间隔
+	PaidInterval        string `validate:"required_with=KuaidailiURL WandouURL"` // 付费代理获取间隔，配置了付费代理源时必填
+	FreeInterval        string `validate:"required_if=UseFreeAPI true"`          // 免费代理获取间隔，开启免费API时必填
+	ValidateInterval    string `validate:"required"`                             // 代理验证间隔，必填
+	CleanupInterval     string `validate:"required"`                             // 过期清理间隔，必填
+	OptimizeInterval    string `validate:"required"`                             // 代理池优化间隔，必填
+	AgeCleanupInterval  string `validate:"required"`                             // 老化代理清理间隔，必填
+	OrphanSweepInterval string `validate:"required"`                             // 已删除代理的子表记录清理间隔，必填
+	MaintenanceInterval string // 自动维护间隔：可用代理不足时补充获取，为空时不启用
+
+	// 按代理类型单独配置的验证间隔，未配置的类型使用 ValidateInterval
+	ValidateIntervals map[models.ProxyType]string
 
 	// 代理验证配置
-	MaxFailCount int // 最大失败次数，超过后删除代理
+	MaxFailCount     int                      `validate:"min=1"`      // 最大失败次数，超过后删除代理，至少为1
+	TypeMaxFailCount map[models.ProxyType]int `validate:"dive,min=0"` // 各代理类型的最大失败次数，未配置或为0的类型使用 MaxFailCount，不能为负
+
+	// 通过API添加代理的配置
+	APIDuplicatePolicy DuplicatePolicy `validate:"omitempty,oneof=reject upsert"` // 添加已存在的代理时拒绝(reject)或更新已有代理(upsert)，为空时拒绝
+
+	// 代理地址配置
+	ResolveHostnames bool // 入库时将以主机名提供的代理解析为IP并保留主机名，关闭时拒绝主机名
+
+	// 代理老化配置
+	MaxProxyAge time.Duration `validate:"omitempty,positiveduration"` // 代理最大存活时间，超过后删除，超过一半时评分减半，为0时使用默认值，不能为负
+
+	// 并发配置
+	TypeMaxConcurrent      map[models.ProxyType]int `validate:"dive,min=1"`    // 各代理类型新代理的默认最大并发数，每项至少为1
+	SourceMaxConcurrent    map[string]int           `validate:"dive,min=1"`    // 各代理源新代理的默认最大并发数，优先于按类型的配置，每项至少为1
+	HighScoreThreshold     float64                  `validate:"min=0,max=100"` // 代理池优化时评分不低于该值的代理提高最大并发数，范围0-100
+	HighScoreMaxConcurrent int                      `validate:"min=1"`         // 高评分代理的最大并发数，至少为1
+
+	// 验证服务配置
+	ValidateJobTimeout time.Duration `validate:"omitempty,positiveduration"` // 单个代理验证超时时间，为0时使用默认值，不能为负
+	ValidateRunTimeout time.Duration `validate:"omitempty,positiveduration"` // 一轮验证超时时间，为0时使用默认值，不能为负
+
+	// 启动预热配置
+	WarmupEnabled    bool          // 启动时是否先验证一批代理再就绪
+	WarmupProxies    int           `validate:"min=0"`                      // 预热验证最久未检查的代理数，不能为负
+	WarmupMinProxies int           `validate:"min=0"`                      // 可用代理少于该值时预热前先获取一次代理，0 表示不获取，不能为负
+	WarmupTimeout    time.Duration `validate:"omitempty,positiveduration"` // 预热最长时间，超时后直接就绪，为0时使用默认值，不能为负
+
+	// 实时统计配置
+	HandoutWindow time.Duration `validate:"omitempty,positiveduration"` // 代理发放计数窗口，为0时使用默认值，不能为负
+	FailureWindow time.Duration `validate:"omitempty,positiveduration"` // 失败计数窗口，为0时使用默认值，不能为负
+
+	// 代理信誉配置
+	ReputationBanTTL          time.Duration `validate:"positiveduration"` // 封禁上报的有效期，必须为正
+	ReputationDecayDays       int           `validate:"min=0"`            // 多少天没有新的封禁上报后清除上报记录，不能为负
+	ReputationCleanupInterval string        `validate:"required"`         // 上报记录清理间隔，必填
+
+	// 验证结果回调配置
+	WebhookSecret      string        // 回调签名密钥，为空时不签名
+	WebhookMaxAttempts int           `validate:"min=0"`                      // 回调最多投递次数，不能为负
+	WebhookBackoff     time.Duration `validate:"omitempty,positiveduration"` // 回调首次重试间隔，之后每次翻倍，为0时使用默认值，不能为负
+
+	// Redis降级配置
+	RedisFailureThreshold int           `validate:"min=0"`                      // Redis连续失败多少次后进入降级模式，不能为负
+	RedisProbeInterval    time.Duration `validate:"omitempty,positiveduration"` // 降级期间检查Redis恢复的间隔，为0时使用默认值，不能为负
+	RedisKeyPrefix        string        // Redis键前缀，多个部署共用同一个Redis时各自设置，为空时使用 proxy_pool
+
+	// 负载均衡配置
+	BalancerRefreshInterval time.Duration `validate:"positiveduration"`                     // roundrobin_cached 策略的缓存刷新间隔，必须为正
+	BalancerMode            BalancerMode  `validate:"omitempty,oneof=round_robin weighted"` // roundrobin_cached 策略选择代理的方式，为空时轮询
+
+	// 存活检查配置
+	ProbeTopN     int           `validate:"min=0"`                      // 每轮检查最近发放的代理数，0表示不启用存活检查，不能为负
+	ProbeInterval time.Duration `validate:"omitempty,positiveduration"` // 存活检查间隔，为0时使用默认值，不能为负
+	ProbeType     ProbeType     `validate:"omitempty,oneof=tcp head"`   // 存活检查方式，tcp 只建立连接，head 通过代理发送HEAD请求，为空时使用 tcp
+
+	// 调度器配置
+	Scheduler SchedulerConfig // 调度器失败阈值和冷却时间，可按调度策略覆盖，为0的全局项使用默认值(失败3次冷却5分钟)
+
+	// 快速通道配置
+	FastPathBufferSize      int           `validate:"min=0"`                      // 每种代理类型预选的候选代理数，0表示使用默认值，不能为负
+	FastPathRefreshInterval time.Duration `validate:"omitempty,positiveduration"` // 候选代理缓冲区的刷新间隔，0表示使用默认值，不能为负
+
+	// 代理源配置
+	SourceTypeConfig map[string]models.ProxyType // 各付费代理源的默认代理类型，键为代理源名称，如 kuaidaili_paid
+
+	// 清理策略
+	SourceCleanupPolicies map[string]models.CleanupPolicy // 各代理源的自动清理策略，键为代理源名称，未配置的代理源直接删除
+	RetentionMode         models.RetentionMode            `validate:"omitempty,oneof=delete quarantine observe"` // 保留模式，observe 只标记为不可用不删除，为空时按清理策略删除，运行时可修改
+
+	// 标签配置
+	SourceTags map[string][]string // 各代理源的默认标签，键为代理源名称
+
+	// 免费代理源协议配置
+	RequiredProtocols []string // 只获取提供其中任一协议的免费代理源，如 ["socks5"]，为空时不限
+
+	// 礼貌抓取配置
+	SourcePoliteness map[string]free.Politeness // 各免费代理源的请求间隔、并发数和限流重试配置，键为代理源名称，未配置的代理源使用默认值
+
+	// 鉴权配置
+	APIKey  string   // 订阅导出等接口使用的API密钥，为空时这些接口不可用；配置了 APIKeys 时拥有 admin 权限
+	APIKeys []APIKey `validate:"unique=Name,unique=Key,dive"` // 带权限范围的API密钥，配置后所有接口都需要密钥，名称和密钥不能重复
+
+	// 调度记录配置
+	DecisionLogSize       int    `validate:"min=0"` // 内存中保留的调度记录数，0表示使用默认值，不能为负
+	DecisionFlushInterval string // 调度记录写入Redis的间隔，为空时只保存在内存中
+
+	// 发放前验证配置
+	VerifyAllowedDomains []string      // 除注册了站点配置的域名外，允许发放前验证的目标域名
+	VerifyTimeout        time.Duration `validate:"omitempty,positiveduration"` // 发放前验证单次请求的超时时间，0 表示使用默认值，不能为负
+
+	// 数据库配置
+	DBDriver string `validate:"omitempty,oneof=mysql sqlite"` // 数据库驱动，mysql 或 sqlite，sqlite 需使用 -tags sqlite 编译
+	DBDSN    string // 数据库连接串，为空时使用驱动的默认连接串
+
+	DBMaxOpenConns int `validate:"min=0"` // 最大打开连接数，0 表示不限制，不能为负
+	DBMaxIdleConns int `validate:"min=0"` // 最大空闲连接数，0 表示使用默认值，不能为负
+
+	// 补充获取配置
+	MinProxies    int           `validate:"min=0"`                      // 可用代理少于该值时，清理和优化后立即获取一次代理，0表示使用维护配置的默认值，不能为负
+	TopUpCooldown time.Duration `validate:"omitempty,positiveduration"` // 同一类代理源两次获取的最短间隔，补充获取不会早于该间隔再次获取，为0时使用默认值，不能为负
+
+	// 预取配置
+	PrefetchCooldown time.Duration `validate:"omitempty,positiveduration"` // 提前获取付费代理的最短间隔，0表示使用默认值，不能为负
+	NearExpiryWindow time.Duration `validate:"omitempty,positiveduration"` // 剩余有效时长不足该值的代理视为即将过期，其来源会被提前获取，0表示使用默认值，不能为负
+
+	// 监听配置
+	ListenAddrs []string `validate:"required,dive,required"` // API服务监听地址，至少一个；Linux 上 "[::]:8080" 已同时接受IPv4（除非开启 bindv6only），多个地址用于分别监听指定地址，如 ["192.168.1.10:8080", "[2001:db8::10]:8080"]
+
+	HTTPReadHeaderTimeout time.Duration `validate:"omitempty,positiveduration"` // 读取请求头的超时时间，0 表示不限制，不能为负
+	HTTPWriteTimeout      time.Duration `validate:"omitempty,positiveduration"` // 写响应的超时时间，需大于最长的请求处理时间，0 表示不限制，不能为负
+	MaxListLimit          int           `validate:"min=0"`                      // 列表接口 limit 参数的上限，超过时返回400，0表示使用默认值，不能为负
+
+	// HTTPS配置
+	TLSEnabled       bool   // 是否以HTTPS提供API
+	TLSCertFile      string `validate:"required_if=TLSEnabled true"` // 证书文件，开启HTTPS时必填
+	TLSKeyFile       string `validate:"required_if=TLSEnabled true"` // 私钥文件，开启HTTPS时必填
+	HTTPRedirectAddr string // 开启HTTPS时将该地址上的HTTP请求重定向到 ListenAddrs 中第一个地址的端口，为空时不重定向
+
+	// 调试配置
+	EnablePprof bool // 是否在 /debug/pprof 下开启性能分析接口
+
+	// 运行时配置
+	RuntimeConfigFile string // 运行时可修改配置的JSON文件，修改后自动生效，为空时只能通过 PUT /api/config 修改
+}
+
+const (
+	DefaultReputationBanTTL    = 24 * time.Hour // 默认封禁上报有效期
+	DefaultReputationDecayDays = 7              // 默认上报记录保留天数
+)
+
+// ValidationJob 一个代理验证定时任务
+type ValidationJob struct {
+	Spec  string             // cron表达式
+	Types []models.ProxyType // 验证的代理类型
+}
+
+// Name 定时任务名称，如 validate 或 validate:temp,long
+func (j ValidationJob) Name() string {
+	if len(j.Types) == 0 {
+		return "validate"
+	}
+	types := make([]string, len(j.Types))
+	for i, t := range j.Types {
+		types[i] = string(t)
+	}
+	return "validate:" + strings.Join(types, ",")
+}
+
+// ValidationJobs 根据验证间隔配置生成定时任务
+// ValidateIntervals 中的每个类型单独一个任务，其余类型共用 ValidateInterval
+func (c *Config) ValidationJobs() []ValidationJob {
+	var jobs []ValidationJob
+	var rest []models.ProxyType
+	for _, t := range models.AllProxyTypes {
+		if spec := c.ValidateIntervals[t]; spec != "" {
+			jobs = append(jobs, ValidationJob{Spec: spec, Types: []models.ProxyType{t}})
+			continue
+		}
+		rest = append(rest, t)
+	}
+
+	if len(rest) > 0 && c.ValidateInterval != "" {
+		// 所有类型都使用默认间隔时不限定类型，避免遗漏未知类型的代理
+		if len(rest) == len(models.AllProxyTypes) {
+			rest = nil
+		}
+		jobs = append(jobs, ValidationJob{Spec: c.ValidateInterval, Types: rest})
+	}
+	return jobs
+}
+
+// ConcurrencyDefaults 新代理的默认最大并发数配置
+func (c *Config) ConcurrencyDefaults() models.ConcurrencyDefaults {
+	return models.ConcurrencyDefaults{
+		ByType:   c.TypeMaxConcurrent,
+		BySource: c.SourceMaxConcurrent,
+	}
+}
+
+// MaintenanceConfig 代理池优化使用的维护配置，未配置的项使用默认值
+func (c *Config) MaintenanceConfig() *models.MaintenanceConfig {
+	config := *models.DefaultMaintenanceConfig
+	if c.HighScoreThreshold > 0 {
+		config.HighScoreThreshold = c.HighScoreThreshold
+	}
+	if c.HighScoreMaxConcurrent > 0 {
+		config.HighScoreMaxConcurrent = c.HighScoreMaxConcurrent
+	}
+	if c.MinProxies > 0 {
+		config.MinProxies = c.MinProxies
+	}
+	return &config
+}
+
+// GetReputationDecay 获取上报记录保留时长，未配置时使用默认值
+func (c *Config) GetReputationDecay() time.Duration {
+	days := c.ReputationDecayDays
+	if days <= 0 {
+		days = DefaultReputationDecayDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DefaultMaxProxyAge 默认代理最大存活时间
+const DefaultMaxProxyAge = 7 * 24 * time.Hour
+
+// GetMaxProxyAge 获取代理最大存活时间，未配置时使用默认值
+func (c *Config) GetMaxProxyAge() time.Duration {
+	if c.MaxProxyAge <= 0 {
+		return DefaultMaxProxyAge
+	}
+	return c.MaxProxyAge
 }
 
 // ProxyFetcher 代理获取器
 type ProxyFetcher struct {
-	db     *gorm.DB
-	logger *zap.Logger
-	config *Config
+	db        *gorm.DB
+	logger    *zap.Logger
+	config    *Config
+	health    *sourceHealthTracker // 代理源健康状态
+	sources   *SourceRegistry      // 代理源注册表
+	realtime  *RealtimeStats       // 实时统计，可为空
+	events    *EventBus            // 事件总线，可为空
+	validator *ProxyValidator      // 共享的验证器，为空时每次新建
+	freeList  []free.Source        // 免费代理源，创建时构建一次，各代理源的请求间隔在多次获取之间保持
+
+	mu              sync.Mutex
+	lastPaidFetch   time.Time // 最近一次开始获取付费代理的时间
+	lastPaidFailure time.Time // 最近一次获取付费代理失败或所有付费代理源都失败的时间
+	lastFreeFetch   time.Time // 最近一次开始获取免费代理的时间
+
+	paidInFlight atomic.Int32 // 正在进行及已触发尚未开始的付费代理获取数
+
+	lastExpiryFetch map[string]time.Time // 各付费代理源最近一次因代理即将过期提前获取的时间
+
+	lastPaidResult *FetchResult // 最近一次获取付费代理的统计
+	lastFreeResult *FetchResult // 最近一次获取免费代理的统计
 }
 
 // NewProxyFetcher 创建代理获取器
 func NewProxyFetcher(db *gorm.DB, logger *zap.Logger, config *Config) *ProxyFetcher {
-	return &ProxyFetcher{
-		db:     db,
-		logger: logger,
-		config: config,
+	f := &ProxyFetcher{
+		db:      db,
+		logger:  logger,
+		config:  config,
+		health:  newSourceHealthTracker(),
+		sources: NewSourceRegistry(db, logger),
+
+		lastExpiryFetch: make(map[string]time.Time),
+	}
+	f.freeList = f.newFreeSources()
+	return f
+}
+
+// SetRealtimeStats 设置实时统计
+func (f *ProxyFetcher) SetRealtimeStats(stats *RealtimeStats) {
+	f.realtime = stats
+}
+
+// SetValidator 设置验证新代理使用的验证器
+func (f *ProxyFetcher) SetValidator(validator *ProxyValidator) {
+	f.validator = validator
+}
+
+// SetEventBus 设置事件总线，新增代理时发布事件
+func (f *ProxyFetcher) SetEventBus(bus *EventBus) {
+	f.events = bus
+}
+
+// recordSourceFailure 记录代理源获取失败
+func (f *ProxyFetcher) recordSourceFailure(name string, err error) {
+	f.health.recordFailure(name, err)
+	f.realtime.RecordFetchFailure()
+}
+
+// LastFetches 获取最近一次开始获取付费代理和免费代理的时间，从未获取时为零值
+func (f *ProxyFetcher) LastFetches() (paid, free time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastPaidFetch, f.lastFreeFetch
+}
+
+// LastFetchResults 获取最近一次获取付费代理和免费代理的统计，从未获取时为空
+func (f *ProxyFetcher) LastFetchResults() (paid, free *FetchResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastPaidResult, f.lastFreeResult
+}
+
+// recordFetchResult 将获取统计记入各代理源的健康状态和代理源记录
+func (f *ProxyFetcher) recordFetchResult(result *FetchResult) {
+	for _, s := range result.Sources {
+		f.health.recordFetch(*s)
+		f.sources.RecordFetch(s.Source, s.Fetched)
+	}
+}
+
+// SourceHealth 获取指定代理源的健康状态
+func (f *ProxyFetcher) SourceHealth(name string) SourceHealth {
+	return f.health.snapshot(name)
+}
+
+// SourceHealths 获取所有代理源的健康状态
+func (f *ProxyFetcher) SourceHealths() []SourceHealth {
+	return f.health.all()
+}
+
+// SeedSources 将配置中的代理源写入代理源注册表，启动时调用
+// 付费代理源的API地址隐藏密钥和签名后保存
+func (f *ProxyFetcher) SeedSources() error {
+	var sources []models.ProxySource
+	if f.config.KuaidailiURL != "" {
+		sources = append(sources, models.ProxySource{
+			Name:   paid.KuaidailiSourceName,
+			Type:   models.SourceTypePaid,
+			APIURL: redact.URL(f.config.KuaidailiURL),
+		})
+	}
+	if f.config.WandouURL != "" {
+		sources = append(sources, models.ProxySource{
+			Name:   paid.WandouSourceName,
+			Type:   models.SourceTypePaid,
+			APIURL: redact.URL(f.config.WandouURL),
+		})
+	}
+	if f.config.UseFreeAPI {
+		for _, source := range f.freeSources() {
+			sources = append(sources, models.ProxySource{Name: source.Name(), Type: models.SourceTypeFree})
+		}
+	}
+	return f.sources.Seed(sources)
+}
+
+// Sources 获取所有代理源记录及健康状态
+func (f *ProxyFetcher) Sources() ([]SourceStatus, error) {
+	sources, err := f.sources.List()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]SourceStatus, len(sources))
+	for i, source := range sources {
+		statuses[i] = SourceStatus{
+			ProxySource: source,
+			Health:      f.health.snapshot(source.Name),
+		}
+	}
+	return statuses, nil
+}
+
+// Source 获取代理源记录及健康状态，不存在时返回 models.ErrSourceNotFound
+func (f *ProxyFetcher) Source(name string) (*SourceStatus, error) {
+	source, err := f.sources.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceStatus{ProxySource: *source, Health: f.health.snapshot(name)}, nil
+}
+
+// SetSourceEnabled 启用或停用代理源，下一次获取时生效
+func (f *ProxyFetcher) SetSourceEnabled(name string, enabled bool) (*SourceStatus, error) {
+	source, err := f.sources.SetEnabled(name, enabled)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceStatus{ProxySource: *source, Health: f.health.snapshot(name)}, nil
+}
+
+// sourceEnabled 代理源是否启用，停用时记录日志
+func (f *ProxyFetcher) sourceEnabled(name string) bool {
+	if f.sources.Enabled(name) {
+		return true
+	}
+	f.logger.Info("代理源已停用，跳过", zap.String("来源", name))
+	return false
+}
+
+// freeSources 免费代理源
+func (f *ProxyFetcher) freeSources() []free.Source {
+	return f.freeList
+}
+
+// newFreeSources 创建免费代理源，按配置设置各代理源的礼貌抓取配置
+func (f *ProxyFetcher) newFreeSources() []free.Source {
+	sources := []free.Source{
+		free.NewIP3366Source(f.db, f.logger),
+		free.NewGeoNodeSource(f.db, f.logger, f.config.GeoNodeMaxPages),
+		free.NewFateZeroSource(f.db, f.logger), // 支持增量获取，有成功记录后只获取更新的代理
+	}
+	for _, source := range sources {
+		politeness, ok := f.config.SourcePoliteness[source.Name()]
+		if !ok {
+			continue
+		}
+		if s, ok := source.(interface{ SetPoliteness(free.Politeness) }); ok {
+			s.SetPoliteness(politeness)
+		}
+	}
+	return sources
+}
+
+// requiredFreeSources 提供所需协议的免费代理源，未配置 RequiredProtocols 时返回全部
+func (f *ProxyFetcher) requiredFreeSources() []free.Source {
+	var sources []free.Source
+	for _, source := range f.freeSources() {
+		if !free.SupportsAnyProtocol(source, f.config.RequiredProtocols) {
+			f.logger.Info("代理源不提供所需协议，跳过",
+				zap.String("来源", source.Name()),
+				zap.Strings("提供的协议", source.SupportedProtocols()),
+				zap.Strings("所需协议", f.config.RequiredProtocols),
+			)
+			continue
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// SourceProtocols 获取代理源可提供的代理协议，代理源不存在时返回 models.ErrSourceNotFound
+func (f *ProxyFetcher) SourceProtocols(name string) ([]string, error) {
+	switch name {
+	case paid.KuaidailiSourceName, paid.WandouSourceName:
+		return paid.SupportedProtocols(), nil
+	}
+	for _, source := range f.freeSources() {
+		if source.Name() == name {
+			return source.SupportedProtocols(), nil
+		}
 	}
+	return nil, models.ErrSourceNotFound
 }
 
-// FetchProxies 获取代理
-func (f *ProxyFetcher) FetchProxies() error {
+// FetchProxies 获取付费代理和免费代理，返回合并后的统计
+func (f *ProxyFetcher) FetchProxies() (*FetchResult, error) {
 	f.logger.Info("========================================")
 	f.logger.Info("           开始获取代理")
 	f.logger.Info("========================================")
@@ -54,17 +482,23 @@ func (f *ProxyFetcher) FetchProxies() error {
 		zap.Bool("包含免费源", f.config.UseFreeAPI),
 	)
 
+	result := newFetchResult()
+
 	// 获取付费代理
-	if err := f.FetchPaidProxies(); err != nil {
+	paidResult, err := f.FetchPaidProxies()
+	if err != nil {
 		f.logger.Error("付费代理获取失败", zap.Error(err))
 	}
+	result.merge(paidResult)
 
 	// 获取免费代理
-	if err := f.FetchFreeProxies(); err != nil {
+	freeResult, err := f.FetchFreeProxies()
+	if err != nil {
 		f.logger.Error("免费代理获取失败", zap.Error(err))
 	}
+	result.merge(freeResult)
 
-	return nil
+	return result.finish(), nil
 }
 
 // GetSourceCount 获取代理源数量
@@ -77,28 +511,52 @@ func (f *ProxyFetcher) GetSourceCount() int {
 		count++
 	}
 	if f.config.UseFreeAPI {
-		count += 4 // 4个免费源
+		count += len(f.freeSources())
 	}
 	return count
 }
 
-// addProxy 添加代理到数据库
-func (f *ProxyFetcher) addProxy(proxy *models.Proxy) error {
+// addOutcome 添加单个代理的结果
+type addOutcome int
+
+const (
+	proxyInserted    addOutcome = iota // 已加入代理池
+	proxyDuplicate                     // 已存在，跳过
+	proxyInvalid                       // 协议检测或验证失败，跳过
+	proxyFailed                        // 检查是否已存在失败
+	proxyStoreFailed                   // 验证通过但入库失败
+)
+
+// addProxy 添加代理到数据库，返回添加结果
+func (f *ProxyFetcher) addProxy(proxy *models.Proxy) (addOutcome, error) {
+	// 规范化地址，保证按规范形式查重
+	if err := proxy.Normalize(); err != nil {
+		f.logger.Debug("代理地址无效，跳过添加",
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+			zap.String("来源", proxy.Source),
+			zap.Error(err),
+		)
+		return proxyInvalid, nil
+	}
+
 	// 检查代理是否已存在
 	exists, err := models.IsProxyExists(f.db, proxy.IP, proxy.Port)
 	if err != nil {
-		return err
+		return proxyFailed, err
 	}
 	if exists {
 		f.logger.Debug("代理已存在，跳过",
 			zap.String("IP", proxy.IP),
 			zap.Int("端口", proxy.Port),
 		)
-		return nil
+		return proxyDuplicate, nil
 	}
 
-	// 创建验证器
-	validator := NewProxyValidator(f.db, f.logger, f.config.MaxFailCount)
+	validator := f.validator
+	if validator == nil {
+		validator = NewProxyValidator(f.db, f.logger, f.config.MaxFailCount)
+	}
 
 	// 验证代理
 	f.logger.Info("验证新代理",
@@ -107,13 +565,25 @@ func (f *ProxyFetcher) addProxy(proxy *models.Proxy) error {
 		zap.String("来源", proxy.Source),
 	)
 
+	// 代理源未提供协议时先检测协议
+	if proxy.NeedsProtocolDetection() {
+		if err := validator.DetectProtocol(proxy); err != nil {
+			f.logger.Debug("代理协议检测失败，跳过添加",
+				zap.String("IP", proxy.IP),
+				zap.Int("端口", proxy.Port),
+				zap.Error(err),
+			)
+			return proxyInvalid, nil
+		}
+	}
+
 	if err := validator.ValidateProxy(proxy); err != nil {
 		f.logger.Debug("代理验证失败，跳过添加",
 			zap.String("IP", proxy.IP),
 			zap.Int("端口", proxy.Port),
 			zap.Error(err),
 		)
-		return nil
+		return proxyInvalid, nil
 	}
 
 	if !proxy.Available {
@@ -121,7 +591,7 @@ func (f *ProxyFetcher) addProxy(proxy *models.Proxy) error {
 			zap.String("IP", proxy.IP),
 			zap.Int("端口", proxy.Port),
 		)
-		return nil
+		return proxyInvalid, nil
 	}
 
 	f.logger.Info("添加新代理",
@@ -131,11 +601,56 @@ func (f *ProxyFetcher) addProxy(proxy *models.Proxy) error {
 		zap.Int64("响应时间", proxy.Speed),
 	)
 
-	return f.db.Create(proxy).Error
+	if err := f.db.Create(proxy).Error; err != nil {
+		return proxyStoreFailed, err
+	}
+	f.events.Publish(NewProxyEvent(EventProxyAdded, proxy))
+	return proxyInserted, nil
+}
+
+// paidSourceOptions 根据配置生成付费代理源选项
+func (f *ProxyFetcher) paidSourceOptions(name string) []paid.Option {
+	var opts []paid.Option
+	if pt, ok := f.config.SourceTypeConfig[name]; ok {
+		opts = append(opts, paid.WithDefaultType(pt))
+	}
+	return opts
+}
+
+// applySourceTags 为代理添加所属代理源的默认标签
+func (f *ProxyFetcher) applySourceTags(proxies []*models.Proxy) {
+	if len(f.config.SourceTags) == 0 {
+		return
+	}
+
+	for _, proxy := range proxies {
+		tags := f.config.SourceTags[proxy.Source]
+		if len(tags) == 0 {
+			continue
+		}
+
+		// 代理源自行保存的已存在代理没有ID，按地址查找
+		id := proxy.ID
+		if id == 0 {
+			existing, err := models.FindByIP(f.db, proxy.IP, proxy.Port)
+			if err != nil {
+				continue
+			}
+			id = existing.ID
+		}
+
+		if err := models.AddProxyTags(f.db, id, tags); err != nil {
+			f.logger.Error("添加代理源默认标签失败",
+				zap.String("来源", proxy.Source),
+				zap.Uint("代理ID", id),
+				zap.Error(err),
+			)
+		}
+	}
 }
 
-// addProxies 批量添加代理
-func (f *ProxyFetcher) addProxies(proxies []*models.Proxy) error {
+// addProxies 批量添加代理，按代理来源将添加结果计入 result
+func (f *ProxyFetcher) addProxies(proxies []*models.Proxy, result *FetchResult) error {
 	totalCount := len(proxies)
 	f.logger.Info("----------------------------------------")
 	f.logger.Info("           开始批量添加代理")
@@ -148,24 +663,42 @@ func (f *ProxyFetcher) addProxies(proxies []*models.Proxy) error {
 	skipCount := 0
 	failCount := 0
 
+	// 主机名先并发解析，逐个添加时使用缓存的结果
+	models.PrefetchHostnames(proxies)
 	for _, proxy := range proxies {
-		err := f.addProxy(proxy)
-		if err != nil {
+		stats := result.source(proxy.Source)
+		outcome, err := f.addProxy(proxy)
+		switch outcome {
+		case proxyInserted:
+			stats.Valid++
+			stats.Inserted++
+			result.insertedIDs = append(result.insertedIDs, proxy.ID)
+			successCount++
+		case proxyDuplicate:
+			// 代理源自行保存时新建的代理带有ID，已存在的代理没有ID
+			if proxy.ID != 0 {
+				result.insertedIDs = append(result.insertedIDs, proxy.ID)
+			}
+			stats.Duplicates++
+			skipCount++
+		case proxyInvalid:
+			skipCount++
+		case proxyFailed, proxyStoreFailed:
+			if outcome == proxyStoreFailed {
+				stats.Valid++
+			}
+			stats.Errors++
+			failCount++
 			f.logger.Error("添加代理失败",
 				zap.String("IP", proxy.IP),
 				zap.Int("端口", proxy.Port),
 				zap.Error(err),
 			)
-			failCount++
-			continue
-		}
-		if proxy.Available {
-			successCount++
-		} else {
-			skipCount++
 		}
 	}
 
+	f.applySourceTags(proxies)
+
 	f.logger.Info("----------------------------------------")
 	f.logger.Info("           批量添加代理完成")
 	f.logger.Info("----------------------------------------")
@@ -180,29 +713,40 @@ func (f *ProxyFetcher) addProxies(proxies []*models.Proxy) error {
 	return nil
 }
 
-// FetchPaidProxies 获取付费代理
-func (f *ProxyFetcher) FetchPaidProxies() error {
+// FetchPaidProxies 获取付费代理，返回各付费代理源的获取统计
+func (f *ProxyFetcher) FetchPaidProxies() (*FetchResult, error) {
+	f.paidInFlight.Add(1)
+	defer f.paidInFlight.Add(-1)
+	f.mu.Lock()
+	f.lastPaidFetch = time.Now()
+	f.mu.Unlock()
+
 	f.logger.Info("========================================")
 	f.logger.Info("           开始获取付费代理")
 	f.logger.Info("========================================")
 
+	result := newFetchResult()
+	defer f.storeFetchResult(&f.lastPaidResult, result)
+
 	var allProxies []*models.Proxy
 	successCount := 0
 	totalProxies := 0
 
 	// 获取快代理付费代理
-	if f.config.KuaidailiURL != "" {
+	if f.config.KuaidailiURL != "" && f.sourceEnabled(paid.KuaidailiSourceName) {
 		f.logger.Info("----------------------------------------")
 		f.logger.Info("           快代理获取开始")
 		f.logger.Info("----------------------------------------")
 
-		source := paid.NewKuaidailiSource(f.config.KuaidailiURL, f.db, f.logger)
-		proxies, err := source.FetchProxies()
+		source := paid.NewKuaidailiSource(f.config.KuaidailiURL, f.db, f.logger, f.paidSourceOptions(paid.KuaidailiSourceName)...)
+		proxies, err := timedFetch(result.source(source.Name()), source.FetchProxies)
 		if err != nil {
+			f.recordSourceFailure(source.Name(), err)
 			f.logger.Error("快代理获取失败",
 				zap.String("错误", err.Error()),
 			)
 		} else {
+			f.health.recordSuccess(source.Name())
 			successCount++
 			totalProxies += len(proxies)
 			f.logger.Info("快代理获取成功",
@@ -214,18 +758,20 @@ func (f *ProxyFetcher) FetchPaidProxies() error {
 	}
 
 	// 获取豌豆代理付费代理
-	if f.config.WandouURL != "" {
+	if f.config.WandouURL != "" && f.sourceEnabled(paid.WandouSourceName) {
 		f.logger.Info("----------------------------------------")
 		f.logger.Info("           豌豆代理获取开始")
 		f.logger.Info("----------------------------------------")
 
-		source := paid.NewWandouSource(f.config.WandouURL, f.db, f.logger)
-		proxies, err := source.FetchProxies()
+		source := paid.NewWandouSource(f.config.WandouURL, f.db, f.logger, f.paidSourceOptions(paid.WandouSourceName)...)
+		proxies, err := timedFetch(result.source(source.Name()), source.FetchProxies)
 		if err != nil {
+			f.recordSourceFailure(source.Name(), err)
 			f.logger.Error("豌豆代理获取失败",
 				zap.String("错误", err.Error()),
 			)
 		} else {
+			f.health.recordSuccess(source.Name())
 			successCount++
 			totalProxies += len(proxies)
 			f.logger.Info("豌豆代理获取成功",
@@ -245,49 +791,72 @@ func (f *ProxyFetcher) FetchPaidProxies() error {
 		zap.Int("总获取代理数", totalProxies),
 	)
 
+	if successCount == 0 {
+		f.recordPaidFailure()
+	}
+
 	// 添加代理到数据库
 	if len(allProxies) > 0 {
-		if err := f.addProxies(allProxies); err != nil {
+		if err := f.addProxies(allProxies, result); err != nil {
+			f.recordPaidFailure()
 			f.logger.Error("添加代理失败", zap.Error(err))
-			return err
+			return result, err
 		}
 	} else {
 		f.logger.Warn("未获取到任何付费代理")
 	}
 
-	return nil
+	return result, nil
+}
+
+// recordPaidFailure 记录付费代理获取失败的时间，提前获取在冷却时间内不再触发
+func (f *ProxyFetcher) recordPaidFailure() {
+	f.mu.Lock()
+	f.lastPaidFailure = time.Now()
+	f.mu.Unlock()
 }
 
-// FetchFreeProxies 获取免费代理
-func (f *ProxyFetcher) FetchFreeProxies() error {
+// FetchFreeProxies 获取免费代理，返回各免费代理源的获取统计，未启用免费代理时返回空统计
+func (f *ProxyFetcher) FetchFreeProxies() (*FetchResult, error) {
 	if !f.config.UseFreeAPI {
-		return nil
+		return newFetchResult().finish(), nil
 	}
 
+	f.mu.Lock()
+	f.lastFreeFetch = time.Now()
+	f.mu.Unlock()
+
 	f.logger.Info("========================================")
 	f.logger.Info("           开始获取免费代理")
 	f.logger.Info("========================================")
 
+	result := newFetchResult()
+	defer f.storeFetchResult(&f.lastFreeResult, result)
+
 	var allProxies []*models.Proxy
 	successCount := 0
 	totalProxies := 0
 
-	freeSources := []free.Source{
-		free.NewIP3366Source(f.db, f.logger),
-	}
-
+	freeSources := f.requiredFreeSources()
 	for _, source := range freeSources {
 		sourceName := source.Name()
+		if !f.sourceEnabled(sourceName) {
+			continue
+		}
 		f.logger.Info(">>> 正在获取: " + sourceName)
 
-		proxies, err := source.FetchProxies()
+		proxies, err := timedFetch(result.source(sourceName), func() ([]*models.Proxy, error) {
+			return f.fetchFromSource(source)
+		})
 		if err != nil {
+			f.recordSourceFailure(sourceName, err)
 			f.logger.Error("获取失败",
 				zap.String("来源", sourceName),
 				zap.String("错误", err.Error()),
 			)
 			continue
 		}
+		f.health.recordSuccess(sourceName)
 		successCount++
 		totalProxies += len(proxies)
 		f.logger.Info("获取成功",
@@ -309,13 +878,99 @@ func (f *ProxyFetcher) FetchFreeProxies() error {
 
 	// 添加代理到数据库
 	if len(allProxies) > 0 {
-		if err := f.addProxies(allProxies); err != nil {
+		if err := f.addProxies(allProxies, result); err != nil {
 			f.logger.Error("添加代理失败", zap.Error(err))
-			return err
+			return result, err
 		}
 	} else {
 		f.logger.Warn("未获取到任何免费代理")
 	}
 
-	return nil
+	return result, nil
+}
+
+// fetchFromSource 从免费代理源获取代理，支持增量获取的代理源在有成功记录后只获取增量
+func (f *ProxyFetcher) fetchFromSource(source free.Source) ([]*models.Proxy, error) {
+	incremental, ok := source.(free.IncrementalSource)
+	if !ok || !source.SupportsIncremental() {
+		return source.FetchProxies()
+	}
+
+	since := f.health.snapshot(source.Name()).LastSuccessTime
+	if since.IsZero() {
+		return source.FetchProxies()
+	}
+
+	f.logger.Info("使用增量获取",
+		zap.String("来源", source.Name()),
+		zap.Time("上次成功时间", since),
+	)
+	return incremental.FetchIncremental(since)
+}
+
+// fetchWaitPollInterval FetchAndWait 等待可用代理时的检查间隔
+const fetchWaitPollInterval = 2 * time.Second
+
+// FetchAndWait 获取代理并等待新代理通过验证
+// 可用代理已不少于 minNewProxies 时直接返回；否则获取一次代理并验证本次新入库的代理，
+// 直到其中可用的代理数不少于 minNewProxies、全部可用或 ctx 到期，返回本次新增的可用代理数和本次获取的统计，未获取时统计为空
+// 只统计本次获取入库的代理，其他代理的验证结果不影响等待
+func (f *ProxyFetcher) FetchAndWait(ctx context.Context, minNewProxies int) (int, *FetchResult, error) {
+	var available int64
+	if err := f.db.WithContext(ctx).Model(&models.Proxy{}).
+		Where("available = ?", true).
+		Count(&available).Error; err != nil {
+		return 0, nil, err
+	}
+	if available >= int64(minNewProxies) {
+		return 0, nil, nil
+	}
+
+	result, err := f.FetchProxies()
+	if err != nil {
+		return 0, result, err
+	}
+	ids := result.insertedIDs
+	if len(ids) == 0 {
+		return 0, result, nil
+	}
+
+	validator := f.validator
+	if validator == nil {
+		validator = NewProxyValidator(f.db, f.logger, f.config.MaxFailCount)
+	}
+	if _, err := validator.ValidateStale(ctx, ids); err != nil {
+		return 0, result, err
+	}
+
+	added, err := f.waitAvailable(ctx, ids, minNewProxies)
+	return added, result, err
+}
+
+// waitAvailable 定期统计 ids 中的可用代理数，直到不少于 minAvailable、全部可用或 ctx 到期，返回最后一次统计的可用代理数
+func (f *ProxyFetcher) waitAvailable(ctx context.Context, ids []uint, minAvailable int) (int, error) {
+	ticker := time.NewTicker(fetchWaitPollInterval)
+	defer ticker.Stop()
+	available := 0
+	for {
+		var count int64
+		err := f.db.WithContext(ctx).Model(&models.Proxy{}).
+			Where("id IN ? AND available = ?", ids, true).
+			Count(&count).Error
+		if err != nil {
+			if ctx.Err() != nil {
+				return available, ctx.Err()
+			}
+			return available, err
+		}
+		if available = int(count); available >= minAvailable || available == len(ids) {
+			return available, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return available, ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
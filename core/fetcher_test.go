@@ -0,0 +1,224 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxy_pool/core/sources/free"
+	"proxy_pool/core/sources/paid"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+func TestFreeSourcesBuiltOnce(t *testing.T) {
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{})
+
+	// 每次获取使用同一组代理源，请求间隔限制在多次获取之间保持
+	first, second := f.freeSources(), f.freeSources()
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("free sources = %d then %d, want the same non-empty list", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("source %s was rebuilt between calls", first[i].Name())
+		}
+	}
+}
+
+func TestFetchAndWaitSkipsFetchWhenPoolIsFull(t *testing.T) {
+	db := newTestDB(t)
+	newTestProxy(t, db, "1.1.1.1")
+	newTestProxy(t, db, "2.2.2.2")
+	f := NewProxyFetcher(db, zap.NewNop(), &Config{})
+
+	added, result, err := f.FetchAndWait(context.Background(), 2)
+	if err != nil || added != 0 || result != nil {
+		t.Errorf("FetchAndWait = %d, %v, %v, want no fetch", added, result, err)
+	}
+}
+
+func TestWaitAvailableCountsOnlyFetchedProxies(t *testing.T) {
+	db := newTestDB(t)
+	f := NewProxyFetcher(db, zap.NewNop(), &Config{})
+	// 与本次获取无关的可用代理不计入
+	newTestProxy(t, db, "1.1.1.1")
+	newTestProxy(t, db, "2.2.2.2")
+	fetched := []*models.Proxy{newTestProxy(t, db, "3.3.3.3"), newTestProxy(t, db, "4.4.4.4")}
+	ids := []uint{fetched[0].ID, fetched[1].ID}
+	db.Model(&models.Proxy{}).Where("id IN ?", ids).UpdateColumn("available", false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if added, err := f.waitAvailable(ctx, ids, 1); !errors.Is(err, context.DeadlineExceeded) || added != 0 {
+		t.Errorf("waitAvailable before validation = %d, %v, want 0 and deadline exceeded", added, err)
+	}
+
+	db.Model(fetched[0]).UpdateColumn("available", true)
+	if added, err := f.waitAvailable(context.Background(), ids, 1); err != nil || added != 1 {
+		t.Errorf("waitAvailable after validation = %d, %v, want 1", added, err)
+	}
+	// 全部可用后不再等待，即使少于要求的数量
+	db.Model(fetched[1]).UpdateColumn("available", true)
+	if added, err := f.waitAvailable(context.Background(), ids, 5); err != nil || added != 2 {
+		t.Errorf("waitAvailable with all fetched available = %d, %v, want 2", added, err)
+	}
+}
+
+func TestAddProxiesRecordsProxiesSavedBySource(t *testing.T) {
+	db := newTestDB(t)
+	f := NewProxyFetcher(db, zap.NewNop(), &Config{})
+	newTestProxy(t, db, "1.1.1.1")
+
+	// 代理源已保存的新代理带有ID，之前已存在的代理没有ID
+	saved := &models.Proxy{IP: "2.2.2.2", Port: 8080, Protocol: "http", Type: models.ProxyTypeTemp, Source: "test"}
+	if _, err := models.BatchCreateWithDuplicateCheck(db, []*models.Proxy{saved}); err != nil {
+		t.Fatalf("save proxy: %v", err)
+	}
+	existing := &models.Proxy{IP: "1.1.1.1", Port: 8080, Protocol: "http", Type: models.ProxyTypeTemp, Source: "test"}
+
+	result := newFetchResult()
+	if err := f.addProxies([]*models.Proxy{saved, existing}, result); err != nil {
+		t.Fatalf("addProxies: %v", err)
+	}
+	if len(result.insertedIDs) != 1 || result.insertedIDs[0] != saved.ID {
+		t.Errorf("inserted ids = %v, want [%d]", result.insertedIDs, saved.ID)
+	}
+}
+
+// fakeIncrementalSource 记录调用方式的增量代理源
+type fakeIncrementalSource struct {
+	full        int
+	incremental []time.Time
+}
+
+func (s *fakeIncrementalSource) Name() string                 { return "fake" }
+func (s *fakeIncrementalSource) SupportsIncremental() bool    { return true }
+func (s *fakeIncrementalSource) SupportedProtocols() []string { return []string{"http"} }
+
+func (s *fakeIncrementalSource) FetchProxies() ([]*models.Proxy, error) {
+	s.full++
+	return nil, nil
+}
+
+func (s *fakeIncrementalSource) FetchIncremental(since time.Time) ([]*models.Proxy, error) {
+	s.incremental = append(s.incremental, since)
+	return nil, nil
+}
+
+func TestFetchFromSourceUsesIncrementalAfterSuccess(t *testing.T) {
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{})
+
+	var registered bool
+	for _, source := range f.freeSources() {
+		if _, ok := source.(free.IncrementalSource); ok && source.SupportsIncremental() {
+			registered = true
+		}
+	}
+	if !registered {
+		t.Error("no registered free source supports incremental fetching")
+	}
+
+	source := &fakeIncrementalSource{}
+	f.fetchFromSource(source)
+	if source.full != 1 || len(source.incremental) != 0 {
+		t.Fatalf("first fetch: full = %d, incremental = %d, want a full fetch", source.full, len(source.incremental))
+	}
+
+	f.health.recordSuccess(source.Name())
+	since := f.health.snapshot(source.Name()).LastSuccessTime
+	f.fetchFromSource(source)
+	if source.full != 1 || len(source.incremental) != 1 || !source.incremental[0].Equal(since) {
+		t.Errorf("after success: full = %d, incremental = %v, want one incremental fetch since %v", source.full, source.incremental, since)
+	}
+}
+
+func TestPaidSourceOptionsUseConfiguredType(t *testing.T) {
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{
+		SourceTypeConfig: map[string]models.ProxyType{paid.KuaidailiSourceName: models.ProxyTypeTemp},
+	})
+
+	if got := paid.NewBaseSource(nil, zap.NewNop(), f.paidSourceOptions(paid.KuaidailiSourceName)...).DefaultType(); got != models.ProxyTypeTemp {
+		t.Errorf("configured source type = %s, want %s", got, models.ProxyTypeTemp)
+	}
+	if got := paid.NewBaseSource(nil, zap.NewNop(), f.paidSourceOptions(paid.WandouSourceName)...).DefaultType(); got != models.ProxyTypeLong {
+		t.Errorf("unconfigured source type = %s, want %s", got, models.ProxyTypeLong)
+	}
+}
+
+func TestDisabledSourceSkippedOnNextFetch(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{KuaidailiURL: srv.URL})
+	if err := f.SeedSources(); err != nil {
+		t.Fatalf("SeedSources: %v", err)
+	}
+	fetch := func() int64 {
+		t.Helper()
+		before := atomic.LoadInt64(&requests)
+		f.FetchPaidProxies()
+		return atomic.LoadInt64(&requests) - before
+	}
+
+	if n := fetch(); n == 0 {
+		t.Fatal("enabled source was not fetched")
+	}
+	if _, err := f.SetSourceEnabled(paid.KuaidailiSourceName, false); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	// 重启时重新写入配置中的代理源，保留停用状态
+	if err := f.SeedSources(); err != nil {
+		t.Fatalf("SeedSources again: %v", err)
+	}
+	if n := fetch(); n != 0 {
+		t.Errorf("disabled source got %d requests, want none", n)
+	}
+	if _, err := f.SetSourceEnabled(paid.KuaidailiSourceName, true); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if n := fetch(); n == 0 {
+		t.Error("re-enabled source was not fetched")
+	}
+
+	if _, err := f.SetSourceEnabled("missing", false); !errors.Is(err, models.ErrSourceNotFound) {
+		t.Errorf("unknown source error = %v, want %v", err, models.ErrSourceNotFound)
+	}
+}
+
+func TestPurgeSource(t *testing.T) {
+	pool, _ := newTestPool(t)
+	disabled := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Source = "a" })
+	deleted := newTestProxy(t, pool.DB(), "1.1.1.2", func(p *models.Proxy) { p.Source = "b" })
+	kept := newTestProxy(t, pool.DB(), "1.1.1.3", func(p *models.Proxy) { p.Source = "c" })
+
+	if n, err := pool.PurgeSource("a", SourcePurgeDisable); err != nil || n != 1 {
+		t.Errorf("purge disable = %d, %v, want 1", n, err)
+	}
+	if n, err := pool.PurgeSource("b", SourcePurgeDelete); err != nil || n != 1 {
+		t.Errorf("purge delete = %d, %v, want 1", n, err)
+	}
+	if n, err := pool.PurgeSource("c", SourcePurgeNone); err != nil || n != 0 {
+		t.Errorf("purge none = %d, %v, want 0", n, err)
+	}
+
+	var got, other models.Proxy
+	if err := pool.DB().First(&got, disabled.ID).Error; err != nil || got.Available {
+		t.Errorf("proxy of disabled source: available = %v, err = %v, want kept and unavailable", got.Available, err)
+	}
+	if err := pool.DB().First(&models.Proxy{}, deleted.ID).Error; err == nil {
+		t.Error("proxy of purged source was not deleted")
+	}
+	if err := pool.DB().First(&other, kept.ID).Error; err != nil || !other.Available {
+		t.Errorf("proxy of untouched source: available = %v, err = %v", other.Available, err)
+	}
+}
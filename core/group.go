@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// CreateGroup 创建代理分组，组内调度策略为空或为已知策略，不支持只按代理类型过滤的 roundrobin_cached
+func (p *ProxyPool) CreateGroup(group *models.ProxyGroup) error {
+	strategy := ScheduleStrategy(group.Strategy)
+	if strategy != "" && (!strategy.IsValid() || strategy == StrategyRoundRobinCached) {
+		return fmt.Errorf("%w: unsupported strategy %q", models.ErrInvalidGroup, group.Strategy)
+	}
+	if err := models.CreateGroup(p.db, group); err != nil {
+		return err
+	}
+
+	p.logger.Info("创建代理分组",
+		zap.String("分组", group.Name),
+		zap.Float64("最低评分", group.MinScore),
+		zap.String("调度策略", group.Strategy),
+	)
+	return nil
+}
+
+// GetProxyFromGroup 只在分组成员中为任务调度代理，分组不存在时返回 models.ErrGroupNotFound
+// 评分下限取分组与任务中较高的，分组设置了调度策略时覆盖任务的策略
+func (p *ProxyPool) GetProxyFromGroup(ctx context.Context, groupName string, task *Task) (*models.Proxy, error) {
+	task.Group = groupName
+	return p.GetProxyForTask(ctx, task)
+}
+
+// applyGroup 按任务所属分组的设置调整任务
+// 负载均衡器只按代理类型区分，无法限定分组，roundrobin_cached 改为 roundrobin
+func (p *ProxyPool) applyGroup(ctx context.Context, task *Task) error {
+	group, err := models.FindGroup(p.db.WithContext(ctx), task.Group)
+	if err != nil {
+		return err
+	}
+
+	if group.MinScore > task.MinScore {
+		task.MinScore = group.MinScore
+	}
+	if group.Strategy != "" {
+		task.Strategy = ScheduleStrategy(group.Strategy)
+	}
+	if task.Strategy == StrategyRoundRobinCached {
+		task.Strategy = StrategyRoundRobin
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"proxy_pool/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultHandoutTTL           = 10 * time.Minute // 默认发放记录保留时长，超过后未上报的发放计为结果未知
+	DefaultHandoutSweepInterval = time.Minute      // 默认清理过期发放记录的间隔
+	handoutKeyspace             = "handout"
+	handoutPendingKey           = "pending" // 未上报的发放记录，成员为 ID:策略，分数为过期时间
+	handoutIDBytes              = 12        // 发放ID的随机字节数，十六进制编码后为24个字符
+)
+
+// 发放结果，用于 proxy_handout_outcomes_total 的 outcome 标签
+const (
+	HandoutSuccess = "success" // 上报为成功
+	HandoutFailure = "failure" // 上报为失败
+	HandoutUnknown = "unknown" // 过期前未上报
+)
+
+// ErrHandoutMismatch 上报的发放ID属于其他代理
+var ErrHandoutMismatch = errors.New("handout belongs to another proxy")
+
+// Handout 一次代理发放的记录，上报使用结果时按发放ID关联到原始请求
+type Handout struct {
+	ID        string           `json:"id"`
+	ProxyID   uint             `json:"proxy_id"`
+	Strategy  ScheduleStrategy `json:"strategy"`
+	TargetURL string           `json:"target_url,omitempty"`
+	Domain    string           `json:"domain,omitempty"`
+	ClientID  string           `json:"client_id,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// pendingMember 发放记录在未上报集合中的成员，带上调度策略以便过期时按策略统计
+func (h *Handout) pendingMember() string {
+	return h.ID + ":" + string(h.Strategy)
+}
+
+// HandoutTracker 在Redis中记录代理发放，关联之后的使用结果上报
+// 每次发放一个带过期时间的键，另用有序集合记录未上报的发放，过期后由 Run 计为结果未知；
+// Redis不可用时不记录，上报按未关联处理
+type HandoutTracker struct {
+	redis  *RedisGuard
+	logger *zap.Logger
+	ttl    time.Duration
+}
+
+// NewHandoutTracker 创建发放记录
+func NewHandoutTracker(guard *RedisGuard, logger *zap.Logger) *HandoutTracker {
+	return &HandoutTracker{redis: guard, logger: logger, ttl: DefaultHandoutTTL}
+}
+
+// SetTTL 设置发放记录保留时长，非正值表示使用默认值
+func (t *HandoutTracker) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultHandoutTTL
+	}
+	t.ttl = ttl
+}
+
+// Record 记录一次发放并返回发放ID，Redis不可用或写入失败时返回空字符串
+func (t *HandoutTracker) Record(task *Task, proxyID uint) string {
+	id, err := newHandoutID()
+	if err != nil {
+		t.logger.Warn("生成发放ID失败", zap.Error(err))
+		return ""
+	}
+
+	now := time.Now()
+	handout := Handout{
+		ID:        id,
+		ProxyID:   proxyID,
+		Strategy:  task.Strategy,
+		TargetURL: task.TargetURL,
+		Domain:    task.Domain,
+		ClientID:  task.ClientID,
+		CreatedAt: now,
+	}
+	data, err := json.Marshal(handout)
+	if err != nil {
+		return ""
+	}
+
+	err = t.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		pipe := client.TxPipeline()
+		pipe.Set(ctx, t.redis.Key(handoutKeyspace, id), data, t.ttl)
+		pipe.ZAdd(ctx, t.redis.Key(handoutKeyspace, handoutPendingKey), &redis.Z{
+			Score:  float64(now.Add(t.ttl).Unix()),
+			Member: handout.pendingMember(),
+		})
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		if err != ErrRedisDegraded {
+			t.logger.Debug("记录代理发放失败",
+				zap.Uint("代理ID", proxyID),
+				zap.Error(err),
+			)
+		}
+		return ""
+	}
+	return id
+}
+
+// Claim 取出发放记录用于关联使用结果，每条记录只能取出一次
+// 记录不存在、已过期或Redis不可用时返回 nil；记录属于其他代理时返回 ErrHandoutMismatch 并保留记录
+func (t *HandoutTracker) Claim(id string, proxyID uint) (*Handout, error) {
+	if !validHandoutID(id) {
+		return nil, nil
+	}
+
+	key := t.redis.Key(handoutKeyspace, id)
+	var data []byte
+	err := t.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		var err error
+		data, err = client.Get(ctx, key).Bytes()
+		return err
+	})
+	if err != nil {
+		if err != redis.Nil && err != ErrRedisDegraded {
+			t.logger.Debug("查询代理发放记录失败", zap.String("发放ID", id), zap.Error(err))
+		}
+		return nil, nil
+	}
+
+	var handout Handout
+	if err := json.Unmarshal(data, &handout); err != nil {
+		t.logger.Debug("解析代理发放记录失败", zap.String("发放ID", id), zap.Error(err))
+		return nil, nil
+	}
+	if handout.ProxyID != proxyID {
+		return nil, fmt.Errorf("%w: handout %s was issued for proxy %d", ErrHandoutMismatch, id, handout.ProxyID)
+	}
+
+	// 并发的重复上报只有删除成功的一方关联结果
+	var deleted int64
+	err = t.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		pipe := client.TxPipeline()
+		del := pipe.Del(ctx, key)
+		pipe.ZRem(ctx, t.redis.Key(handoutKeyspace, handoutPendingKey), handout.pendingMember())
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		deleted = del.Val()
+		return nil
+	})
+	if err != nil || deleted == 0 {
+		return nil, nil
+	}
+	return &handout, nil
+}
+
+// Discard 删除未交给调用方的发放记录，如发放后又被释放的代理，不计入发放结果
+func (t *HandoutTracker) Discard(id string, strategy ScheduleStrategy) {
+	if !validHandoutID(id) {
+		return
+	}
+	handout := Handout{ID: id, Strategy: strategy}
+	err := t.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		pipe := client.TxPipeline()
+		pipe.Del(ctx, t.redis.Key(handoutKeyspace, id))
+		pipe.ZRem(ctx, t.redis.Key(handoutKeyspace, handoutPendingKey), handout.pendingMember())
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil && err != ErrRedisDegraded {
+		t.logger.Debug("删除代理发放记录失败", zap.String("发放ID", id), zap.Error(err))
+	}
+}
+
+// SweepExpired 清理过期前未上报的发放记录，按调度策略计为结果未知，返回清理的记录数
+func (t *HandoutTracker) SweepExpired() int {
+	key := t.redis.Key(handoutKeyspace, handoutPendingKey)
+	max := strconv.FormatInt(time.Now().Unix(), 10)
+
+	var members []string
+	err := t.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		var err error
+		members, err = client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "-inf", Max: max}).Result()
+		return err
+	})
+	if err != nil || len(members) == 0 {
+		return 0
+	}
+
+	swept := 0
+	for _, member := range members {
+		// 多个实例同时清理时只有移除成功的一方计数
+		var removed int64
+		err := t.redis.Do(func(ctx context.Context, client *redis.Client) error {
+			var err error
+			removed, err = client.ZRem(ctx, key, member).Result()
+			return err
+		})
+		if err != nil {
+			break
+		}
+		if removed == 0 {
+			continue
+		}
+		swept++
+		strategy := ""
+		if i := strings.IndexByte(member, ':'); i >= 0 {
+			strategy = member[i+1:]
+		}
+		metrics.HandoutOutcomes.WithLabelValues(strategy, HandoutUnknown).Inc()
+	}
+	if swept > 0 {
+		t.logger.Debug("清理过期的代理发放记录", zap.Int("数量", swept))
+	}
+	return swept
+}
+
+// Run 定期清理过期的发放记录，直到 ctx 取消
+func (t *HandoutTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(DefaultHandoutSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.SweepExpired()
+		}
+	}
+}
+
+// newHandoutID 生成随机发放ID
+func newHandoutID() (string, error) {
+	buf := make([]byte, handoutIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// validHandoutID 是否为 newHandoutID 生成的格式，避免任意输入拼接出其他键
+func validHandoutID(id string) bool {
+	if len(id) != hex.EncodedLen(handoutIDBytes) {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// correlateReport 按发放ID关联使用结果上报，补全上报中缺少的目标URL和域名并统计发放结果
+// 没有发放ID或记录已过期时按未关联处理
+func (p *ProxyPool) correlateReport(proxyID uint, report *StatusReport) error {
+	if report.HandoutID == "" {
+		return nil
+	}
+	handout, err := p.handouts.Claim(report.HandoutID, proxyID)
+	if err != nil || handout == nil {
+		return err
+	}
+
+	if report.TargetURL == "" {
+		report.TargetURL = handout.TargetURL
+	}
+	if report.Domain == "" {
+		report.Domain = handout.Domain
+	}
+	outcome := HandoutFailure
+	if report.Success {
+		outcome = HandoutSuccess
+	}
+	metrics.HandoutOutcomes.WithLabelValues(string(handout.Strategy), outcome).Inc()
+	return nil
+}
+
+// Handouts 获取发放记录
+func (p *ProxyPool) Handouts() *HandoutTracker {
+	return p.handouts
+}
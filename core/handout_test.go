@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	siteconfig "proxy_pool/core/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// pendingHandouts 获取未上报的发放记录数
+func pendingHandouts(t *testing.T, mr *miniredis.Miniredis) int {
+	t.Helper()
+	for _, key := range mr.Keys() {
+		if strings.HasSuffix(key, handoutKeyspace+":"+handoutPendingKey) {
+			members, err := mr.ZMembers(key)
+			if err != nil {
+				t.Fatalf("read pending handouts: %v", err)
+			}
+			return len(members)
+		}
+	}
+	return 0
+}
+
+func TestGetProxyForURLDiscardsRejectedHandouts(t *testing.T) {
+	pool, mr := newTestPool(t)
+	site := &siteconfig.SiteConfig{
+		Name:          "example",
+		BaseURL:       "https://example.test",
+		LongTermLimit: 1,
+		LongTermTTL:   time.Hour,
+	}
+	pool.RegisterSiteConfig("example.test", site)
+	limited := newTestProxy(t, pool.DB(), "1.1.1.1")
+	ctx := context.Background()
+	// 代理在站点上已用完额度，但还没有记入受限集合，调度后才发现超过上限
+	mr.Set(pool.RedisGuard().Key(site.GetRateLimitKey(limited.ID, "long")), "1")
+
+	// 唯一的代理超过使用上限被释放，发放记录随之删除
+	if _, err := pool.GetProxyForURL(ctx, "https://example.test/item"); err == nil {
+		t.Fatal("GetProxyForURL with only a limited proxy succeeded")
+	}
+	if n := pendingHandouts(t, mr); n != 0 {
+		t.Errorf("pending handouts after rejection = %d, want 0", n)
+	}
+
+	// 交给调用方的代理仍记录发放
+	fresh := newTestProxy(t, pool.DB(), "2.2.2.2")
+	proxy, err := pool.GetProxyForURL(ctx, "https://example.test/item")
+	if err != nil {
+		t.Fatalf("GetProxyForURL: %v", err)
+	}
+	if proxy.ID != fresh.ID {
+		t.Errorf("GetProxyForURL = %d, want %d", proxy.ID, fresh.ID)
+	}
+	if n := pendingHandouts(t, mr); n != 1 {
+		t.Errorf("pending handouts after handout = %d, want 1", n)
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// highReliabilityScheduler 可优先调度高可用代理的调度器
+type highReliabilityScheduler interface {
+	ScheduleHighReliability(ctx context.Context, task *Task) (*models.Proxy, error)
+}
+
+// ScheduleHighReliability 优先从高可用代理中调度：在任务条件基础上要求检查次数不少于 models.DefaultHAMinChecks、
+// 成功率不低于 models.DefaultHAMinSuccessRate（任务要求更高时按任务），与 models.GetHighAvailabilityProxies 条件一致；
+// 没有符合条件的高可用代理时按原任务条件从全部代理中调度
+func (s *ProxyScheduler) ScheduleHighReliability(ctx context.Context, task *Task) (*models.Proxy, error) {
+	mature := *task
+	if mature.MinChecks < models.DefaultHAMinChecks {
+		mature.MinChecks = models.DefaultHAMinChecks
+	}
+	if mature.MinSuccessRate < models.DefaultHAMinSuccessRate {
+		mature.MinSuccessRate = models.DefaultHAMinSuccessRate
+	}
+
+	proxy, err := s.ScheduleProxy(ctx, &mature)
+	var noProxy *NoProxyError
+	if err == nil || !errors.As(err, &noProxy) {
+		return proxy, err
+	}
+
+	s.logger.Debug("没有符合条件的高可用代理，从全部代理中调度",
+		zap.Int("最少检查次数", mature.MinChecks),
+		zap.Float64("最低成功率", mature.MinSuccessRate),
+	)
+	return s.ScheduleProxy(ctx, task)
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestGetProxyForTaskHighReliability(t *testing.T) {
+	pool, _ := newTestPool(t)
+	// 只验证过一次的代理评分更高，但检查次数不够
+	young := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Success, p.Failure, p.Score = 1, 0, 100 })
+	mature := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Score = 30 })
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		proxy, err := pool.GetProxyForTask(ctx, &Task{Strategy: StrategyWeighted, HighReliability: true})
+		if err != nil {
+			t.Fatalf("GetProxyForTask: %v", err)
+		}
+		if proxy.ID != mature.ID {
+			t.Fatalf("high reliability task got proxy %d, want mature proxy %d", proxy.ID, mature.ID)
+		}
+		pool.Scheduler().ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+	}
+
+	// 没有高可用代理时按原条件调度
+	proxy, err := pool.GetProxyForTask(ctx, &Task{Strategy: StrategyWeighted, HighReliability: true, ExcludeIDs: []uint{mature.ID}})
+	if err != nil || proxy.ID != young.ID {
+		t.Fatalf("fallback = %v, %v, want proxy %d", proxy, err, young.ID)
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"proxy_pool/models"
+	"strconv"
+	"strings"
+)
+
+// defaultHTTPSCheckURL 测试网站中没有HTTPS网站时用于HTTPS检查的网站
+const defaultHTTPSCheckURL = "https://www.baidu.com"
+
+// httpsCheckURL 获取用于HTTPS检查的网站，优先使用第一个HTTPS测试网站
+func httpsCheckURL(testURLs []string) string {
+	for _, u := range testURLs {
+		if strings.HasPrefix(u, "https://") {
+			return u
+		}
+	}
+	return defaultHTTPSCheckURL
+}
+
+// checkHTTPS 检查代理能否访问HTTPS网站，不记录测试网站的验证统计
+// HTTP代理直接发送CONNECT请求，代理返回2xx即建立了到目标443端口的隧道；
+// 其他协议的代理本身就是隧道，通过代理访问HTTPS网站，收到任意响应即可
+func (v *ProxyValidator) checkHTTPS(ctx context.Context, client *http.Client, proxy *models.Proxy) error {
+	target, err := url.Parse(httpsCheckURL(v.TestURLs()))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.Timeout())
+	defer cancel()
+
+	if proxy.Protocol != "http" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	host := target.Host
+	if target.Port() == "" {
+		host = net.JoinHostPort(target.Hostname(), "443")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(proxy.IP, strconv.Itoa(proxy.Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", host, host); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("proxy refused CONNECT to %s: %s", host, resp.Status)
+	}
+	return nil
+}
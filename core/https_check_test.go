@@ -0,0 +1,87 @@
+package core
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// startFakeProxy 启动一个本地HTTP代理，普通请求一律返回200，allowConnect 为 false 时拒绝CONNECT
+func startFakeProxy(t *testing.T, allowConnect bool) *models.Proxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				switch {
+				case req.Method != http.MethodConnect:
+					conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok"))
+				case allowConnect:
+					conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				default:
+					conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\nContent-Length: 0\r\n\r\n"))
+				}
+			}(conn)
+		}
+	}()
+
+	_, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return &models.Proxy{IP: "127.0.0.1", Port: port, Protocol: "http"}
+}
+
+func TestProbeVerifiesHTTPSWithConnect(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowConnect bool
+		want         bool
+	}{
+		{"connect allowed", true, true},
+		{"connect refused", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewProxyValidator(nil, zap.NewNop(), 3)
+			// 只有HTTP测试网站，HTTPS检查不能从成功的测试网站推断
+			v.SetTestURLs([]string{"http://check.invalid/"})
+			proxy := startFakeProxy(t, tt.allowConnect)
+
+			if err := v.Probe(proxy); err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if proxy.VerifiedHTTPS != tt.want {
+				t.Errorf("VerifiedHTTPS = %v, want %v", proxy.VerifiedHTTPS, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPSCheckURL(t *testing.T) {
+	if got := httpsCheckURL([]string{"http://a.example", "https://b.example"}); got != "https://b.example" {
+		t.Errorf("httpsCheckURL = %q, want the first https test url", got)
+	}
+	if got := httpsCheckURL([]string{"http://a.example"}); got != defaultHTTPSCheckURL {
+		t.Errorf("httpsCheckURL without https = %q, want %q", got, defaultHTTPSCheckURL)
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ImportProxy 导入的代理
+type ImportProxy struct {
+	IP        string             `json:"ip" binding:"required"`
+	Port      int                `json:"port" binding:"required,min=1,max=65535"`
+	Protocol  string             `json:"protocol"`  // 默认 http
+	Type      models.ProxyType   `json:"type"`      // 默认 temp
+	Region    models.ProxyRegion `json:"region"`    // 默认 other
+	Source    string             `json:"source"`    // 默认 import
+	Anonymous bool               `json:"anonymous"` // 是否匿名
+	Pinned    bool               `json:"pinned"`    // 是否固定，已存在的代理也会设为固定
+	Tags      []string           `json:"tags"`      // 代理标签
+
+	CallbackURL string `json:"callback_url"` // 首次验证完成后回调的地址，为空时使用请求级别的地址
+}
+
+// ImportRejection 被拒绝的导入项
+type ImportRejection struct {
+	IP    string `json:"ip"`
+	Port  int    `json:"port"`
+	Error string `json:"error"`
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Imported int               `json:"imported"` // 新增数量
+	Updated  int               `json:"updated"`  // 已存在并更新标签的数量
+	Rejected []ImportRejection `json:"rejected"` // 被拒绝的代理
+}
+
+// ImportProxies 导入代理，已存在的代理只追加标签，指定了 pinned 时设为固定
+// tags 会追加到每个代理上，callbackURL 用于未单独指定回调地址的代理
+func (p *ProxyPool) ImportProxies(items []ImportProxy, tags []string, callbackURL string) (*ImportResult, error) {
+	commonTags, err := models.NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if callbackURL != "" {
+		if err := ValidateCallbackURL(callbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ImportResult{Rejected: []ImportRejection{}}
+	for _, item := range items {
+		proxy, itemTags, err := item.toProxy()
+		if err == nil {
+			err = proxy.Normalize()
+		}
+		if err != nil {
+			result.Rejected = append(result.Rejected, ImportRejection{IP: item.IP, Port: item.Port, Error: err.Error()})
+			continue
+		}
+
+		existing, err := models.FindByIP(p.db, proxy.IP, proxy.Port)
+		switch {
+		case err == nil:
+			proxy = existing
+			if item.Pinned && !proxy.Pinned {
+				if err := p.db.Model(proxy).UpdateColumn("pinned", true).Error; err != nil {
+					return result, err
+				}
+				proxy.Pinned = true
+			}
+			result.Updated++
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return result, err
+		default:
+			if proxy, _, err = p.AddProxy(proxy); err != nil {
+				result.Rejected = append(result.Rejected, ImportRejection{IP: item.IP, Port: item.Port, Error: err.Error()})
+				continue
+			}
+			result.Imported++
+		}
+
+		if err := models.AddProxyTags(p.db, proxy.ID, append(itemTags, commonTags...)); err != nil {
+			return result, err
+		}
+
+		if url := item.callbackURL(callbackURL); url != "" {
+			if err := p.RegisterCallback(proxy.ID, url); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	p.logger.Info("代理导入完成",
+		zap.Int("新增数量", result.Imported),
+		zap.Int("更新数量", result.Updated),
+		zap.Int("拒绝数量", len(result.Rejected)),
+	)
+	return result, nil
+}
+
+// callbackURL 获取代理的回调地址，未单独指定时使用默认地址
+func (item *ImportProxy) callbackURL(def string) string {
+	if item.CallbackURL != "" {
+		return item.CallbackURL
+	}
+	return def
+}
+
+// toProxy 转换为代理模型，补全默认值
+func (item *ImportProxy) toProxy() (*models.Proxy, []string, error) {
+	tags, err := models.NormalizeTags(item.Tags)
+	if err != nil {
+		return nil, nil, err
+	}
+	if item.CallbackURL != "" {
+		if err := ValidateCallbackURL(item.CallbackURL); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	proxy := &models.Proxy{
+		IP:        item.IP,
+		Port:      item.Port,
+		Protocol:  item.Protocol,
+		Type:      item.Type,
+		Region:    item.Region,
+		Source:    item.Source,
+		Anonymous: item.Anonymous,
+		Pinned:    item.Pinned,
+		Available: true,
+	}
+	if proxy.Protocol == "" {
+		proxy.Protocol = "http"
+	}
+	if proxy.Type == "" {
+		proxy.Type = models.ProxyTypeTemp
+	}
+	if proxy.Region == "" {
+		proxy.Region = models.ProxyRegionOther
+	}
+	if proxy.Source == "" {
+		proxy.Source = "import"
+	}
+	if !proxy.Type.IsValid() {
+		return nil, nil, fmt.Errorf("invalid type: %q", proxy.Type)
+	}
+	if !proxy.Region.IsValid() {
+		return nil, nil, fmt.Errorf("invalid region: %q", proxy.Region)
+	}
+	return proxy, tags, nil
+}
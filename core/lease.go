@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultLeaseTimeout       = DefaultHandoutTTL // 默认占用超时，与发放记录一样，超过后未上报的发放不再计入并发数
+	DefaultLeaseSweepInterval = time.Minute       // 默认回收过期占用的间隔
+)
+
+// lease 一次代理发放占用的并发数，上报使用结果或释放时归还
+// 调用方一直不上报时由 ExpireLeases 按占用时间回收，避免代理永久停留在并发上限
+type lease struct {
+	acquiredAt time.Time
+	strategy   ScheduleStrategy // 发放时的调度策略，失败时按该策略的设置冷却
+}
+
+// leaseExpirer 可回收过期占用的调度器
+type leaseExpirer interface {
+	ExpireLeases(timeout time.Duration) int
+}
+
+// acquireLease 为代理记录一次按 strategy 调度的占用，调用方需持有 s.mu
+func (s *ProxyScheduler) acquireLease(proxyID uint, strategy ScheduleStrategy) {
+	s.inUse[proxyID] = append(s.inUse[proxyID], lease{acquiredAt: time.Now(), strategy: strategy})
+}
+
+// releaseProxy 归还代理最早的一次占用并返回其调度策略，没有占用时返回空字符串，调用方需持有 s.mu
+func (s *ProxyScheduler) releaseProxy(proxyID uint) ScheduleStrategy {
+	released, _ := popLease(s.inUse, proxyID)
+	return released.strategy
+}
+
+// ExpireLeases 回收占用时间超过 timeout 的占用，返回回收的占用数
+func (s *ProxyScheduler) ExpireLeases(timeout time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return expireLeases(s.inUse, time.Now().Add(-timeout))
+}
+
+// popLease 移除并返回代理最早的一次占用，没有占用时返回 false
+func popLease(leases map[uint][]lease, proxyID uint) (lease, bool) {
+	held := leases[proxyID]
+	if len(held) == 0 {
+		return lease{}, false
+	}
+	if len(held) == 1 {
+		delete(leases, proxyID)
+	} else {
+		leases[proxyID] = held[1:]
+	}
+	return held[0], true
+}
+
+// expireLeases 移除早于 cutoff 的占用，返回移除的占用数
+func expireLeases(leases map[uint][]lease, cutoff time.Time) int {
+	expired := 0
+	for proxyID, held := range leases {
+		// 占用按时间顺序追加，找到第一个未过期的占用即可
+		i := 0
+		for i < len(held) && held[i].acquiredAt.Before(cutoff) {
+			i++
+		}
+		if i == 0 {
+			continue
+		}
+		expired += i
+		if i == len(held) {
+			delete(leases, proxyID)
+		} else {
+			leases[proxyID] = held[i:]
+		}
+	}
+	return expired
+}
+
+// RunLeaseExpiry 定期回收调度器中过期的占用，直到 ctx 取消，调度器不支持时直接返回
+func (p *ProxyPool) RunLeaseExpiry(ctx context.Context) {
+	expirer, ok := p.scheduler.(leaseExpirer)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(DefaultLeaseSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expired := expirer.ExpireLeases(DefaultLeaseTimeout); expired > 0 {
+				p.logger.Info("回收超时未上报的代理占用",
+					zap.Int("数量", expired),
+					zap.Duration("超时时间", DefaultLeaseTimeout),
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestSaturatedProxyNotSelected(t *testing.T) {
+	pool, _ := newTestPool(t)
+	busy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 1 })
+	idle := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.MaxConcurrent = 100 })
+
+	// 先占满 busy
+	got, err := pool.Scheduler().ScheduleProxy(context.Background(), &Task{Strategy: StrategyWeighted, ExcludeIDs: []uint{idle.ID}})
+	if err != nil || got.ID != busy.ID {
+		t.Fatalf("first schedule = %v, %v; want proxy %d", got, err, busy.ID)
+	}
+	if n := pool.GetLiveConcurrentUse(busy.ID); n != 1 {
+		t.Fatalf("live concurrent use = %d, want 1", n)
+	}
+
+	for i := 0; i < 50; i++ {
+		got, err := pool.Scheduler().ScheduleProxy(context.Background(), &Task{Strategy: StrategyWeighted})
+		if err != nil {
+			t.Fatalf("schedule %d: %v", i, err)
+		}
+		if got.ID == busy.ID {
+			t.Fatalf("schedule %d returned saturated proxy %d", i, busy.ID)
+		}
+	}
+
+	// 只剩已满载的代理时没有可用代理
+	_, err = pool.Scheduler().ScheduleProxy(context.Background(), &Task{Strategy: StrategyWeighted, ExcludeIDs: []uint{idle.ID}})
+	if !errors.Is(err, ErrNoQualifiedProxy) {
+		t.Fatalf("schedule with only saturated proxy error = %v, want %v", err, ErrNoQualifiedProxy)
+	}
+
+	// 上报后归还占用
+	pool.Scheduler().ReportProxyStatus(busy.ID, StatusReport{Success: true})
+	if n := pool.GetLiveConcurrentUse(busy.ID); n != 0 {
+		t.Fatalf("live concurrent use after report = %d, want 0", n)
+	}
+}
+
+func TestExpireLeases(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 2 })
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	task := &Task{Strategy: StrategyWeighted}
+	for i := 0; i < 2; i++ {
+		if _, err := scheduler.ScheduleProxy(context.Background(), task); err != nil {
+			t.Fatalf("schedule %d: %v", i, err)
+		}
+	}
+	if _, err := scheduler.ScheduleProxy(context.Background(), task); !errors.Is(err, ErrNoQualifiedProxy) {
+		t.Fatalf("schedule past max concurrent error = %v, want %v", err, ErrNoQualifiedProxy)
+	}
+
+	// 调用方一直不上报，未超时的占用保留
+	if expired := scheduler.ExpireLeases(time.Hour); expired != 0 {
+		t.Fatalf("ExpireLeases(1h) = %d, want 0", expired)
+	}
+	if expired := scheduler.ExpireLeases(0); expired != 2 {
+		t.Fatalf("ExpireLeases(0) = %d, want 2", expired)
+	}
+	if n := scheduler.LiveConcurrentUse(proxy.ID); n != 0 {
+		t.Fatalf("live concurrent use after expiry = %d, want 0", n)
+	}
+	if _, err := scheduler.ScheduleProxy(context.Background(), task); err != nil {
+		t.Fatalf("schedule after expiry: %v", err)
+	}
+}
+
+func TestReleaseProxyReturnsOldestLease(t *testing.T) {
+	pool, _ := newTestPool(t)
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	scheduler.mu.Lock()
+	scheduler.inUse[1] = []lease{{acquiredAt: time.Now().Add(-time.Hour)}, {acquiredAt: time.Now()}}
+	scheduler.releaseProxy(1)
+	scheduler.mu.Unlock()
+
+	// 归还的是较早的占用，剩下的占用未超时
+	if expired := scheduler.ExpireLeases(time.Minute); expired != 0 {
+		t.Fatalf("ExpireLeases = %d, want 0", expired)
+	}
+	if n := scheduler.LiveConcurrentUse(1); n != 1 {
+		t.Fatalf("live concurrent use = %d, want 1", n)
+	}
+}
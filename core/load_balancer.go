@@ -0,0 +1,337 @@
+package core
+
+import (
+	"context"
+	"math/rand"
+	"proxy_pool/models"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultBalancerRefreshInterval = 30 * time.Second       // 默认缓存刷新间隔
+	defaultBalancerAcquireTimeout  = 500 * time.Millisecond // 获取代理的最长等待时间
+	balancerRetryDelay             = 10 * time.Millisecond  // 所有代理满载时的重试间隔
+	balancerEventDebounce          = 100 * time.Millisecond // 收到变更事件后延迟刷新，合并短时间内的多个事件
+	balancerEventBuffer            = 256                    // 事件订阅缓冲区大小
+	minBalancerWeight              = 1.0                    // 加权随机时代理的最小权重，评分为0的代理也有机会被选中
+)
+
+// BalancerMode 负载均衡器选择代理的方式
+type BalancerMode string
+
+const (
+	ModeRoundRobin BalancerMode = "round_robin" // 依次轮询，不考虑评分
+	ModeWeighted   BalancerMode = "weighted"    // 按评分加权随机，评分90的代理被选中的概率约为评分10的9倍
+)
+
+// IsValid 是否为支持的选择方式
+func (m BalancerMode) IsValid() bool {
+	return m == ModeRoundRobin || m == ModeWeighted
+}
+
+// LoadBalancer 基于内存缓存的负载均衡器，按 Mode 轮询或按评分加权随机选择代理
+// 缓存由后台协程定期刷新，获取代理时不访问数据库
+type LoadBalancer struct {
+	Mode BalancerMode // 选择代理的方式，需在 Start 之前设置，默认轮询
+
+	pool            *ProxyPool
+	opts            *models.ScheduleOptions
+	logger          *zap.Logger
+	refreshInterval time.Duration
+	acquireTimeout  time.Duration
+
+	mu          sync.Mutex
+	proxyCache  []*models.Proxy
+	weights     []float64 // 与 proxyCache 对应的累计权重，用于加权随机时二分查找
+	currentIdx  int       // 下一次轮询开始的位置
+	lastRefresh time.Time
+	inUse       map[uint][]lease // 本负载均衡器发放的代理占用，按代理ID记录，刷新缓存后仍保留，上报使用结果时归还
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+// NewLoadBalancer 创建负载均衡器，需调用 Start 启动后台刷新
+func NewLoadBalancer(pool *ProxyPool, opts *models.ScheduleOptions, refreshInterval time.Duration) *LoadBalancer {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultBalancerRefreshInterval
+	}
+	return &LoadBalancer{
+		Mode:            ModeRoundRobin,
+		pool:            pool,
+		opts:            opts,
+		logger:          pool.Logger(),
+		refreshInterval: refreshInterval,
+		acquireTimeout:  defaultBalancerAcquireTimeout,
+		inUse:           make(map[uint][]lease),
+		stopCh:          make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// Start 同步加载一次缓存并启动后台刷新协程
+func (lb *LoadBalancer) Start() {
+	if err := lb.refreshProxyCache(); err != nil {
+		lb.logger.Error("负载均衡器缓存初始化失败", zap.Error(err))
+	}
+	go lb.refreshLoop()
+}
+
+// Stop 停止后台刷新协程
+func (lb *LoadBalancer) Stop() {
+	lb.stopOnce.Do(func() {
+		close(lb.stopCh)
+	})
+	<-lb.done
+}
+
+// refreshLoop 按刷新间隔加随机抖动定期刷新缓存，避免多个实例同时查询数据库
+func (lb *LoadBalancer) refreshLoop() {
+	defer close(lb.done)
+
+	for {
+		jitter := time.Duration(rand.Int63n(int64(lb.refreshInterval/10) + 1))
+		timer := time.NewTimer(lb.refreshInterval + jitter)
+
+		select {
+		case <-lb.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := lb.refreshProxyCache(); err != nil {
+				lb.logger.Error("负载均衡器缓存刷新失败", zap.Error(err))
+			}
+		}
+	}
+}
+
+// WatchChanges 订阅代理增删及存活状态变化事件，与当前过滤条件相关的变更会在防抖后触发缓存刷新
+// 在 ctx 取消或 Stop 调用后返回
+func (lb *LoadBalancer) WatchChanges(ctx context.Context, eventBus *EventBus) {
+	events, unsubscribe := eventBus.Subscribe(balancerEventBuffer,
+		EventProxyAdded, EventProxyRemoved, EventProxyDown, EventProxyUp)
+	defer unsubscribe()
+
+	debounce := time.NewTimer(balancerEventDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-lb.stopCh:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !lb.matches(event) {
+				continue
+			}
+			debounce.Reset(balancerEventDebounce)
+		case <-debounce.C:
+			if err := lb.refreshProxyCache(); err != nil {
+				lb.logger.Error("负载均衡器缓存刷新失败", zap.Error(err))
+			}
+		}
+	}
+}
+
+// matches 判断事件是否影响当前缓存
+func (lb *LoadBalancer) matches(event Event) bool {
+	if lb.opts.PreferredType != "" && event.ProxyType != "" && event.ProxyType != lb.opts.PreferredType {
+		return false
+	}
+	// 删除或不可用的代理无论评分如何都可能在缓存中
+	if (event.Type == EventProxyAdded || event.Type == EventProxyUp) && event.Score < lb.opts.MinScore {
+		return false
+	}
+	return true
+}
+
+// GetProxy 按 Mode 获取下一个可用代理，跳过 exclude 中的代理
+// 获取前先从缓存中移除已过有效期的代理；所有代理均已满载时在 acquireTimeout 内重试，超时返回 ErrNoProxyAvailable
+func (lb *LoadBalancer) GetProxy(exclude ...uint) (*models.Proxy, error) {
+	deadline := time.Now().Add(lb.acquireTimeout)
+	lb.evictExpired()
+
+	var excluded map[uint]bool
+	if len(exclude) > 0 {
+		excluded = make(map[uint]bool, len(exclude))
+		for _, id := range exclude {
+			excluded[id] = true
+		}
+	}
+
+	for {
+		if proxy := lb.next(excluded); proxy != nil {
+			lb.pool.realtime.RecordHandout()
+			return proxy, nil
+		}
+		if time.Now().Add(balancerRetryDelay).After(deadline) {
+			return nil, ErrNoProxyAvailable
+		}
+		time.Sleep(balancerRetryDelay)
+	}
+}
+
+// next 遍历一轮缓存，返回第一个未被排除且未满载的代理并记录占用
+// 加权随机时从按权重随机选中的位置开始遍历，选中的代理满载或被排除时依次尝试之后的代理
+// 并发数为本负载均衡器的占用加上调度器的占用，缓存中代理的 ConcurrentUse 来自数据库，可能已过期，不使用
+func (lb *LoadBalancer) next(excluded map[uint]bool) *models.Proxy {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if len(lb.proxyCache) == 0 {
+		return nil
+	}
+	if lb.Mode == ModeWeighted {
+		lb.currentIdx = lb.pickWeighted()
+	}
+
+	for i := 0; i < len(lb.proxyCache); i++ {
+		proxy := lb.proxyCache[lb.currentIdx%len(lb.proxyCache)]
+		lb.currentIdx = (lb.currentIdx + 1) % len(lb.proxyCache)
+		if excluded[proxy.ID] || !proxy.Available {
+			continue
+		}
+		if len(lb.inUse[proxy.ID])+lb.pool.GetLiveConcurrentUse(proxy.ID) >= proxy.MaxConcurrent {
+			continue
+		}
+		lb.inUse[proxy.ID] = append(lb.inUse[proxy.ID], lease{acquiredAt: time.Now(), strategy: lb.Mode.strategy()})
+		return proxy
+	}
+	return nil
+}
+
+// strategy 按选择方式发放的占用记录的调度策略
+func (m BalancerMode) strategy() ScheduleStrategy {
+	if m == ModeWeighted {
+		return StrategyWeightedCached
+	}
+	return StrategyRoundRobinCached
+}
+
+// pickWeighted 按累计权重二分查找随机选中的代理位置，调用方需持有 lb.mu 且缓存不为空
+func (lb *LoadBalancer) pickWeighted() int {
+	total := lb.weights[len(lb.weights)-1]
+	r := rand.Float64() * total
+	// 找到第一个累计权重大于 r 的位置，权重都为正，累计权重严格递增
+	i := sort.SearchFloat64s(lb.weights, r)
+	if i < len(lb.weights)-1 && lb.weights[i] == r {
+		i++
+	}
+	return i
+}
+
+// rebuildWeights 按缓存中代理的评分重新计算累计权重，调用方需持有 lb.mu
+func (lb *LoadBalancer) rebuildWeights() {
+	weights := make([]float64, len(lb.proxyCache))
+	total := 0.0
+	for i, proxy := range lb.proxyCache {
+		weight := proxy.Score
+		if weight < minBalancerWeight {
+			weight = minBalancerWeight
+		}
+		total += weight
+		weights[i] = total
+	}
+	lb.weights = weights
+}
+
+// evictExpired 从缓存中移除 EstimatedTTL 已归零的代理，下次刷新缓存前不再发放
+func (lb *LoadBalancer) evictExpired() {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	kept := lb.proxyCache[:0]
+	evicted := 0
+	for i, proxy := range lb.proxyCache {
+		if proxy.EstimatedTTL() > 0 {
+			kept = append(kept, proxy)
+			continue
+		}
+		evicted++
+		// 移除位于下一个位置之前的代理时前移下一个位置，保持轮询顺序不变
+		if i < lb.currentIdx {
+			lb.currentIdx--
+		}
+	}
+	if evicted == 0 {
+		return
+	}
+	for i := len(kept); i < len(lb.proxyCache); i++ {
+		lb.proxyCache[i] = nil
+	}
+	lb.proxyCache = kept
+	lb.rebuildWeights()
+
+	lb.logger.Debug("负载均衡器移除过期代理",
+		zap.Int("移除数量", evicted),
+		zap.Int("剩余数量", len(kept)),
+	)
+}
+
+// Release 归还代理最早的一次占用，本负载均衡器没有发放过该代理时返回 false
+func (lb *LoadBalancer) Release(proxyID uint) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	_, ok := popLease(lb.inUse, proxyID)
+	return ok
+}
+
+// InUse 返回本负载均衡器发放的代理当前的占用数
+func (lb *LoadBalancer) InUse(proxyID uint) int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return len(lb.inUse[proxyID])
+}
+
+// Size 返回缓存中的代理数量
+func (lb *LoadBalancer) Size() int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return len(lb.proxyCache)
+}
+
+// refreshProxyCache 刷新代理缓存，查询在锁外执行，不阻塞 GetProxy
+// 占用按代理ID记录，不随缓存替换，顺带回收超时未上报的占用
+func (lb *LoadBalancer) refreshProxyCache() error {
+	proxies, err := lb.pool.ListProxies(lb.opts.Filter())
+	if err != nil {
+		return err
+	}
+
+	cache := make([]*models.Proxy, len(proxies))
+	for i := range proxies {
+		cache[i] = &proxies[i]
+		// 没有硬过期时间的代理按加载时的检查时间确定过期时间，缓存期间到期后由 evictExpired 移除
+		if cache[i].ExpiresAt == nil {
+			expiresAt := cache[i].ExpiryTime()
+			cache[i].ExpiresAt = &expiresAt
+		}
+	}
+
+	lb.mu.Lock()
+	lb.proxyCache = cache
+	lb.rebuildWeights()
+	lb.currentIdx = 0
+	lb.lastRefresh = time.Now()
+	expired := expireLeases(lb.inUse, lb.lastRefresh.Add(-DefaultLeaseTimeout))
+	lb.mu.Unlock()
+
+	if expired > 0 {
+		lb.logger.Info("负载均衡器回收超时未上报的代理占用", zap.Int("数量", expired))
+	}
+
+	lb.logger.Debug("负载均衡器缓存已刷新",
+		zap.Int("代理数量", len(cache)),
+	)
+	return nil
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func newTestBalancer(t *testing.T, pool *ProxyPool) *LoadBalancer {
+	t.Helper()
+
+	lb := NewLoadBalancer(pool, &models.ScheduleOptions{PreferredType: models.ProxyTypeTemp}, time.Hour)
+	lb.acquireTimeout = 50 * time.Millisecond
+	if err := lb.refreshProxyCache(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	return lb
+}
+
+func TestLoadBalancerBoundedRetry(t *testing.T) {
+	pool, _ := newTestPool(t)
+	newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 1 })
+	lb := newTestBalancer(t, pool)
+
+	if _, err := lb.GetProxy(); err != nil {
+		t.Fatalf("first GetProxy: %v", err)
+	}
+
+	start := time.Now()
+	_, err := lb.GetProxy()
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("GetProxy on saturated cache error = %v, want %v", err, ErrNoProxyAvailable)
+	}
+	if elapsed > time.Second {
+		t.Errorf("GetProxy took %v, want it bounded by the acquire timeout", elapsed)
+	}
+}
+
+func TestLoadBalancerAcquisitionsSurviveRefresh(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 1 })
+	lb := newTestBalancer(t, pool)
+
+	if _, err := lb.GetProxy(); err != nil {
+		t.Fatalf("GetProxy: %v", err)
+	}
+
+	// 刷新替换缓存后占用仍然有效
+	if err := lb.refreshProxyCache(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if _, err := lb.GetProxy(); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("GetProxy after refresh error = %v, want %v", err, ErrNoProxyAvailable)
+	}
+
+	if !lb.Release(proxy.ID) {
+		t.Fatal("Release returned false for an acquired proxy")
+	}
+	if lb.Release(proxy.ID) {
+		t.Fatal("Release returned true with nothing acquired")
+	}
+	if _, err := lb.GetProxy(); err != nil {
+		t.Fatalf("GetProxy after release: %v", err)
+	}
+}
+
+func TestLoadBalancerCountsSchedulerLeases(t *testing.T) {
+	pool, _ := newTestPool(t)
+	newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 1 })
+	lb := newTestBalancer(t, pool)
+
+	if _, err := pool.Scheduler().ScheduleProxy(context.Background(), &Task{Strategy: StrategyWeighted}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if _, err := lb.GetProxy(); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("GetProxy with proxy held by scheduler error = %v, want %v", err, ErrNoProxyAvailable)
+	}
+}
+
+func TestPoolReportReleasesCachedHandout(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 1 })
+	t.Cleanup(pool.Shutdown)
+
+	task := func() *Task { return &Task{ProxyType: models.ProxyTypeTemp, Strategy: StrategyRoundRobinCached} }
+	if _, err := pool.GetProxyForTask(context.Background(), task()); err != nil {
+		t.Fatalf("first cached handout: %v", err)
+	}
+	if _, err := pool.GetProxyForTask(context.Background(), task()); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("second cached handout error = %v, want %v", err, ErrNoProxyAvailable)
+	}
+
+	if err := pool.ReportProxyStatus(proxy.ID, StatusReport{Success: true}); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if n := pool.LoadBalancer(models.ProxyTypeTemp).InUse(proxy.ID); n != 0 {
+		t.Fatalf("balancer in use after report = %d, want 0", n)
+	}
+	if _, err := pool.GetProxyForTask(context.Background(), task()); err != nil {
+		t.Fatalf("cached handout after report: %v", err)
+	}
+}
+
+func TestLoadBalancerRefreshRace(t *testing.T) {
+	pool, _ := newTestPool(t)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		newTestProxy(t, pool.DB(), ip, func(p *models.Proxy) { p.MaxConcurrent = 1000 })
+	}
+	lb := newTestBalancer(t, pool)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if err := lb.refreshProxyCache(); err != nil {
+					t.Errorf("refresh: %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	var acquired sync.WaitGroup
+	var mu sync.Mutex
+	counts := make(map[uint]int)
+	for g := 0; g < 4; g++ {
+		acquired.Add(1)
+		go func() {
+			defer acquired.Done()
+			for i := 0; i < 50; i++ {
+				proxy, err := lb.GetProxy()
+				if err != nil {
+					t.Errorf("GetProxy: %v", err)
+					return
+				}
+				mu.Lock()
+				counts[proxy.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	acquired.Wait()
+	close(stop)
+	wg.Wait()
+
+	total := 0
+	for id, n := range counts {
+		if got := lb.InUse(id); got != n {
+			t.Errorf("proxy %d in use = %d, want %d", id, got, n)
+		}
+		total += n
+	}
+	if total != 200 {
+		t.Errorf("total acquisitions = %d, want 200", total)
+	}
+}
+
+func TestLoadBalancerEvictExpiredKeepsRotation(t *testing.T) {
+	pool, _ := newTestPool(t)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		newTestProxy(t, pool.DB(), ip, func(p *models.Proxy) { p.LastCheck = time.Now() })
+	}
+	lb := newTestBalancer(t, pool)
+
+	order := make([]uint, len(lb.proxyCache))
+	for i, p := range lb.proxyCache {
+		if p.ExpiresAt == nil {
+			t.Fatalf("cached proxy %d has no ExpiresAt after refresh", p.ID)
+		}
+		order[i] = p.ID
+	}
+
+	first, err := lb.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy: %v", err)
+	}
+	if first.ID != order[0] {
+		t.Fatalf("first GetProxy = %d, want %d", first.ID, order[0])
+	}
+
+	// 移除已发放过的代理后仍从下一个代理继续轮询
+	past := time.Now().Add(-time.Second)
+	lb.proxyCache[0].ExpiresAt = &past
+	second, err := lb.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy after eviction: %v", err)
+	}
+	if second.ID != order[1] {
+		t.Errorf("GetProxy after eviction = %d, want %d", second.ID, order[1])
+	}
+	if lb.Size() != 2 {
+		t.Errorf("cache size = %d, want 2", lb.Size())
+	}
+
+	// 移除下一个位置上的代理时从其后的代理继续，已在末尾时回到开头
+	lb.proxyCache[1].ExpiresAt = &past
+	third, err := lb.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy after second eviction: %v", err)
+	}
+	if third.ID != order[1] {
+		t.Errorf("GetProxy after second eviction = %d, want %d", third.ID, order[1])
+	}
+}
+
+func TestLoadBalancerRefreshesOnMatchingEvents(t *testing.T) {
+	pool, _ := newTestPool(t)
+	lb := newTestBalancer(t, pool)
+	bus := NewEventBus(pool.Logger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		lb.WatchChanges(ctx, bus)
+		close(done)
+	}()
+	// 等待订阅生效
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		bus.mu.RLock()
+		n := len(bus.subs)
+		bus.mu.RUnlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+	waitSize := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for lb.Size() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("cache size = %d, want %d", lb.Size(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// 其他类型的代理变更不触发刷新
+	added := newTestProxy(t, pool.DB(), "1.1.1.1")
+	bus.Publish(Event{Type: EventProxyAdded, ProxyID: added.ID, ProxyType: models.ProxyTypeLong, Score: added.Score})
+	time.Sleep(3 * balancerEventDebounce)
+	if n := lb.Size(); n != 0 {
+		t.Fatalf("cache size after unrelated event = %d, want 0", n)
+	}
+
+	bus.Publish(NewProxyEvent(EventProxyAdded, added))
+	waitSize(1)
+
+	pool.DB().Delete(added)
+	bus.Publish(NewProxyEvent(EventProxyRemoved, added))
+	waitSize(0)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WatchChanges did not return after ctx was cancelled")
+	}
+}
+
+func TestLoadBalancerWeightedSelection(t *testing.T) {
+	pool, _ := newTestPool(t)
+	low := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Score = 10 })
+	high := newTestProxy(t, pool.DB(), "1.1.1.2", func(p *models.Proxy) { p.Score = 90 })
+	lb := newTestBalancer(t, pool)
+	lb.Mode = ModeWeighted
+
+	counts := make(map[uint]int)
+	for i := 0; i < 1000; i++ {
+		proxy, err := lb.GetProxy()
+		if err != nil {
+			t.Fatalf("GetProxy: %v", err)
+		}
+		counts[proxy.ID]++
+		// 加权发放的占用记录加权策略，而不是轮询
+		lb.mu.Lock()
+		strategy := lb.inUse[proxy.ID][0].strategy
+		lb.mu.Unlock()
+		if strategy != StrategyWeightedCached {
+			t.Fatalf("lease strategy = %s, want %s", strategy, StrategyWeightedCached)
+		}
+		lb.Release(proxy.ID)
+	}
+
+	// 评分90的代理被选中的次数约为评分10的9倍
+	if counts[low.ID] == 0 {
+		t.Fatalf("low score proxy never selected: %v", counts)
+	}
+	ratio := float64(counts[high.ID]) / float64(counts[low.ID])
+	if ratio < 6 || ratio > 13 {
+		t.Errorf("selection ratio = %.2f (%d/%d), want about 9", ratio, counts[high.ID], counts[low.ID])
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"proxy_pool/core/sources/paid"
+	"proxy_pool/metrics"
+	"proxy_pool/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultNearExpiryWindow 默认剩余有效时长不足多久的代理视为即将过期
+const DefaultNearExpiryWindow = 5 * time.Minute
+
+// GetNearExpiryWindow 获取即将过期的判断时长，未配置时使用默认值
+func (c *Config) GetNearExpiryWindow() time.Duration {
+	if c.NearExpiryWindow <= 0 {
+		return DefaultNearExpiryWindow
+	}
+	return c.NearExpiryWindow
+}
+
+// FetchSource 从指定代理源获取一次代理并添加到代理池，返回该代理源的获取统计
+// 代理源不存在或未配置时返回 models.ErrSourceNotFound，已停用时不获取并返回空统计
+func (f *ProxyFetcher) FetchSource(name string) (*FetchResult, error) {
+	var fetch func() ([]*models.Proxy, error)
+	switch name {
+	case paid.KuaidailiSourceName:
+		if f.config.KuaidailiURL != "" {
+			fetch = paid.NewKuaidailiSource(f.config.KuaidailiURL, f.db, f.logger, f.paidSourceOptions(name)...).FetchProxies
+		}
+	case paid.WandouSourceName:
+		if f.config.WandouURL != "" {
+			fetch = paid.NewWandouSource(f.config.WandouURL, f.db, f.logger, f.paidSourceOptions(name)...).FetchProxies
+		}
+	default:
+		for _, source := range f.freeSources() {
+			if source.Name() == name {
+				fetch = func() ([]*models.Proxy, error) { return f.fetchFromSource(source) }
+				break
+			}
+		}
+	}
+	if fetch == nil {
+		return nil, models.ErrSourceNotFound
+	}
+	result := newFetchResult()
+	if !f.sourceEnabled(name) {
+		return result.finish(), nil
+	}
+
+	proxies, err := timedFetch(result.source(name), fetch)
+	if err != nil {
+		f.recordSourceFailure(name, err)
+		return result.finish(), err
+	}
+	f.health.recordSuccess(name)
+	if len(proxies) > 0 {
+		err = f.addProxies(proxies, result)
+	}
+	result.finish()
+	f.recordFetchResult(result)
+	return result, err
+}
+
+// nearExpirySources 按来源统计即将过期的代理数，paidOnly 为 true 时只统计付费代理源
+func (f *ProxyFetcher) nearExpirySources(ctx context.Context, paidOnly bool) (map[string]int, error) {
+	proxies, err := models.GetProxiesNearExpiry(f.db.WithContext(ctx), f.config.GetNearExpiryWindow())
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, proxy := range proxies {
+		if paidOnly && proxy.Source != paid.KuaidailiSourceName && proxy.Source != paid.WandouSourceName {
+			continue
+		}
+		counts[proxy.Source]++
+	}
+	return counts, nil
+}
+
+// RefreshNearExpiry 从即将过期的代理的来源各获取一次代理，提前补充替代代理
+// 手动添加等无法获取的来源会被跳过，各代理源的错误合并返回
+func (f *ProxyFetcher) RefreshNearExpiry(ctx context.Context) error {
+	counts, err := f.nearExpirySources(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for source, count := range counts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, err := f.FetchSource(source)
+		if errors.Is(err, models.ErrSourceNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			f.logger.Error("为即将过期的代理获取替代代理失败", zap.String("来源", source), zap.Error(err))
+			continue
+		}
+		f.logger.Info("已为即将过期的代理获取替代代理",
+			zap.String("来源", source),
+			zap.Int("即将过期代理数", count),
+			zap.Int("获取代理数", result.Totals().Fetched),
+			zap.Int("新增代理数", result.Totals().Inserted),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// prefetchNearExpiry 有付费代理即将过期时在后台从其来源获取一次代理，返回触发获取的代理源
+// 同一代理源距上次因此获取不足冷却时间时不触发
+func (f *ProxyFetcher) prefetchNearExpiry(ctx context.Context) ([]string, error) {
+	counts, err := f.nearExpirySources(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	cooldown := f.config.GetPrefetchCooldown()
+	var triggered []string
+	f.mu.Lock()
+	for source := range counts {
+		if time.Since(f.lastExpiryFetch[source]) < cooldown {
+			continue
+		}
+		f.lastExpiryFetch[source] = time.Now()
+		triggered = append(triggered, source)
+	}
+	f.mu.Unlock()
+
+	for _, source := range triggered {
+		metrics.PrefetchTriggered.Inc()
+		f.logger.Info("付费代理即将过期，提前获取",
+			zap.String("来源", source),
+			zap.Int("即将过期代理数", counts[source]),
+		)
+		go func(source string) {
+			if _, err := f.FetchSource(source); err != nil {
+				f.logger.Error("提前获取付费代理失败", zap.String("来源", source), zap.Error(err))
+			}
+		}(source)
+	}
+	return triggered, nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+func TestValidatorKeepsPinnedProxyAfterMaxFailures(t *testing.T) {
+	pool, _ := newTestPool(t)
+	validator := NewProxyValidator(pool.DB(), zap.NewNop(), 1)
+	validator.SetTestURLs([]string{"http://check.invalid/"})
+	validator.SetTimeout(200 * time.Millisecond)
+
+	// 文档保留地址不可达，验证时连接失败
+	pinned := newTestProxy(t, pool.DB(), "192.0.2.1", func(p *models.Proxy) { p.Pinned = true })
+	plain := newTestProxy(t, pool.DB(), "192.0.2.2")
+	for _, p := range []*models.Proxy{pinned, plain} {
+		var stored models.Proxy
+		if err := pool.DB().First(&stored, p.ID).Error; err != nil {
+			t.Fatalf("load proxy: %v", err)
+		}
+		if err := validator.ValidateProxy(&stored); err != nil {
+			t.Fatalf("ValidateProxy %d: %v", p.ID, err)
+		}
+	}
+
+	var kept models.Proxy
+	if err := pool.DB().First(&kept, pinned.ID).Error; err != nil {
+		t.Fatalf("pinned proxy deleted after max failures: %v", err)
+	}
+	if kept.Available || kept.FailCount != 1 || kept.Quarantined {
+		t.Errorf("pinned proxy available = %v, fail count = %d, quarantined = %v, want unavailable with 1 failure",
+			kept.Available, kept.FailCount, kept.Quarantined)
+	}
+	if err := pool.DB().First(&models.Proxy{}, plain.ID).Error; err == nil {
+		t.Error("unpinned proxy kept after max failures, want deleted")
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	siteconfig "proxy_pool/core/config"
 	"proxy_pool/models"
 	"sync"
 	"time"
@@ -10,34 +14,202 @@ import (
 	"gorm.io/gorm"
 )
 
+// statusCacheTTL 代理池完整状态的缓存时间
+const statusCacheTTL = 10 * time.Second
+
 // ProxyPool 代理池管理器
 type ProxyPool struct {
-	db           *gorm.DB
-	redis        *redis.Client
-	logger       *zap.Logger
-	mu           sync.RWMutex
-	scheduler    *ProxyScheduler
-	maxFailCount int // 添加最大失败次数配置
+	db         *gorm.DB
+	redis      *redis.Client
+	logger     *zap.Logger
+	mu         sync.RWMutex
+	scheduler  Scheduler
+	redisGuard *RedisGuard     // Redis熔断器，Redis不可用时降级
+	realtime   *RealtimeStats  // 基于Redis的实时统计
+	validator  *ProxyValidator // 共享的验证器，可在运行时修改配置
+	validation *ValidationService
+	events     *EventBus                         // 代理增删事件
+	recent     *RecentHandouts                   // 各客户端最近获取的代理
+	handouts   *HandoutTracker                   // 发放记录，关联之后的使用结果上报
+	decisions  *DecisionLog                      // 最近的调度记录，用于审计公平性
+	verifier   *TargetVerifier                   // 发放前验证
+	siteLimits *SiteRateLimiter                  // GetProxyForURL 的站点发放限制
+	sites      map[string]*siteconfig.SiteConfig // GetProxyForURL 按域名使用的站点配置，由 mu 保护
+	webhooks   *WebhookNotifier                  // 验证结果回调，可为空
+	warmup     *Warmup                           // 启动预热，可为空
+	fetcher    *ProxyFetcher                     // 代理获取器，供API同步获取代理，可为空
+	topUp      *FetchTopUp                       // 清理和优化后的补充获取，可为空
+	cronJobs   *CronJobs                         // 定时任务，可为空
+	runtime    *RuntimeConfigStore               // 运行时可修改的配置
+	duplicates DuplicatePolicy                   // 通过API添加已存在的代理时的处理方式
+	migration  redisMigration                    // 后台执行的Redis迁移
+
+	reputationBanTTL time.Duration // 封禁上报的有效期，site_adaptive 策略排除有效期内被封禁的代理
+
+	statusMu       sync.Mutex
+	statusCache    *models.PoolStatus // GetFullStatus 的缓存
+	statusCachedAt time.Time
+	scoreCache     map[float64]cachedScoreDistribution // ScoreDistribution 的缓存，按分桶宽度区分，由 statusMu 保护
+
+	balancerMu              sync.Mutex
+	balancers               map[models.ProxyType]*LoadBalancer // 按代理类型缓存的负载均衡器
+	balancerRefreshInterval time.Duration
+	balancerMode            BalancerMode
+
+	fastPath         map[models.ProxyType]*CandidateBuffer // 按代理类型的候选代理缓冲区，由 balancerMu 保护
+	fastPathSize     int
+	fastPathInterval time.Duration
 }
 
 // NewProxyPool 创建新的代理池管理器
-func NewProxyPool(db *gorm.DB, redis *redis.Client, logger *zap.Logger) *ProxyPool {
+func NewProxyPool(db *gorm.DB, redis *redis.Client, logger *zap.Logger, opts ...PoolOption) *ProxyPool {
+	guard := NewRedisGuard(redis, logger)
 	pool := &ProxyPool{
-		db:           db,
-		redis:        redis,
-		logger:       logger,
-		maxFailCount: 3, // 默认3次失败后删除
+		db:         db,
+		redis:      redis,
+		logger:     logger,
+		runtime:    NewRuntimeConfigStore(logger),
+		duplicates: DuplicateReject,
+
+		reputationBanTTL: DefaultReputationBanTTL,
+		redisGuard:       guard,
+		realtime:         NewRealtimeStats(guard, logger),
+		recent:           NewRecentHandouts(guard, logger),
+		handouts:         NewHandoutTracker(guard, logger),
+		decisions:        NewDecisionLog(guard, logger),
+		verifier:         NewTargetVerifier(),
+		siteLimits:       NewSiteRateLimiter(guard, logger),
+		sites:            make(map[string]*siteconfig.SiteConfig),
+		events:           NewEventBus(logger),
+		balancers:        make(map[models.ProxyType]*LoadBalancer),
+		fastPath:         make(map[models.ProxyType]*CandidateBuffer),
+
+		balancerRefreshInterval: DefaultBalancerRefreshInterval,
+		balancerMode:            ModeRoundRobin,
 	}
 	pool.scheduler = NewProxyScheduler(pool)
+	for _, opt := range opts {
+		opt(pool)
+	}
 	return pool
 }
 
-// AddProxy 添加新代理到池中
-func (p *ProxyPool) AddProxy(proxy *models.Proxy) error {
+// PoolOption 代理池选项
+type PoolOption func(*ProxyPool)
+
+// WithScheduler 使用指定的调度器代替默认的 ProxyScheduler，如测试中使用 testutil.MockScheduler，nil 表示保持默认
+func WithScheduler(scheduler Scheduler) PoolOption {
+	return func(p *ProxyPool) {
+		if scheduler != nil {
+			p.scheduler = scheduler
+		}
+	}
+}
+
+// ErrProxyExists 添加的代理已存在，且重复策略为拒绝
+var ErrProxyExists = errors.New("proxy already exists")
+
+// DuplicatePolicy 添加已存在的代理时的处理方式
+type DuplicatePolicy string
+
+const (
+	DuplicateReject DuplicatePolicy = "reject" // 拒绝，返回 ErrProxyExists
+	DuplicateUpsert DuplicatePolicy = "upsert" // 用新的类型、协议、地区、来源和匿名性覆盖已有代理
+)
+
+// IsValid 是否为支持的重复策略
+func (d DuplicatePolicy) IsValid() bool {
+	return d == DuplicateReject || d == DuplicateUpsert
+}
+
+// SetDuplicatePolicy 设置添加已存在的代理时的处理方式
+func (p *ProxyPool) SetDuplicatePolicy(policy DuplicatePolicy) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.duplicates = policy
+}
+
+// AddProxy 添加新代理到池中，返回保存后的代理以及是否为新建
+// 与获取代理时一样先规范化地址，新建时补全类型和地区，未指定协议时检测协议；
+// 同IP端口的代理已存在时按重复策略处理，更新时返回更新后的已有代理
+func (p *ProxyPool) AddProxy(proxy *models.Proxy) (*models.Proxy, bool, error) {
+	if err := proxy.Normalize(); err != nil {
+		return nil, false, err
+	}
+
+	existing, err := p.findDuplicate(proxy)
+	if err != nil {
+		return nil, false, err
+	}
+	// 协议检测需要访问网络，不持锁进行
+	if existing == nil && proxy.NeedsProtocolDetection() {
+		if err := p.Validator().DetectProtocol(proxy); err != nil {
+			return nil, false, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 检测协议期间可能已有相同代理入库
+	if existing == nil {
+		if existing, err = p.findDuplicate(proxy); err != nil {
+			return nil, false, err
+		}
+	}
+	if existing != nil {
+		return p.resolveDuplicate(existing, proxy)
+	}
+
+	// 默认值只用于新建，更新已有代理时未指定的字段保持不变
+	if proxy.Type == "" {
+		proxy.Type = models.ProxyTypeTemp
+	}
+	if proxy.Region == "" {
+		proxy.Region = models.ProxyRegionOther
+	}
+	if err := p.db.Create(proxy).Error; err != nil {
+		return nil, false, err
+	}
+	p.events.Publish(NewProxyEvent(EventProxyAdded, proxy))
+	return proxy, true, nil
+}
+
+// findDuplicate 查找同IP端口的代理，不存在时返回nil
+func (p *ProxyPool) findDuplicate(proxy *models.Proxy) (*models.Proxy, error) {
+	existing, err := models.FindByIP(p.db, proxy.IP, proxy.Port)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return existing, err
+}
+
+// resolveDuplicate 按重复策略处理已存在的代理，调用方需持有 p.mu
+// 更新时只覆盖请求中指定了的字段，未指定的类型、地区等保持已有代理的值
+func (p *ProxyPool) resolveDuplicate(existing, proxy *models.Proxy) (*models.Proxy, bool, error) {
+	if p.duplicates != DuplicateUpsert {
+		return existing, false, fmt.Errorf("%w: %s:%d (id %d)", ErrProxyExists, proxy.IP, proxy.Port, existing.ID)
+	}
 
-	return p.db.Create(proxy).Error
+	if proxy.Type != "" {
+		existing.Type = proxy.Type
+	}
+	if proxy.Region != "" {
+		existing.Region = proxy.Region
+	}
+	if proxy.Anonymous {
+		existing.Anonymous = true
+	}
+	if proxy.Source != "" {
+		existing.Source = proxy.Source
+	}
+	if !proxy.NeedsProtocolDetection() {
+		existing.Protocol = proxy.Protocol
+	}
+	if err := p.db.Save(existing).Error; err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
 }
 
 // GetProxy 根据类型获取代理
@@ -61,16 +233,48 @@ func (p *ProxyPool) GetProxy(proxyType models.ProxyType) (*models.Proxy, error)
 
 // GetProxies 批量获取代理
 func (p *ProxyPool) GetProxies(proxyType models.ProxyType, limit int) ([]models.Proxy, error) {
-	var proxies []models.Proxy
+	return p.ListProxies(models.ProxyFilter{
+		Type:      proxyType,
+		Available: models.Bool(true),
+		Limit:     limit,
+	})
+}
 
-	err := p.db.Where("type = ? AND available = ?", proxyType, true).
-		Order("success_rate DESC, speed ASC").
-		Limit(limit).
-		Find(&proxies).Error
+// ListProxies 按过滤条件获取代理
+func (p *ProxyPool) ListProxies(filter models.ProxyFilter) ([]models.Proxy, error) {
+	return p.ListProxiesContext(context.Background(), filter)
+}
 
+// ListProxiesContext 按条件查询代理，ctx 到期后查询中止
+func (p *ProxyPool) ListProxiesContext(ctx context.Context, filter models.ProxyFilter) ([]models.Proxy, error) {
+	var proxies []models.Proxy
+	err := filter.Apply(p.db.WithContext(ctx)).Find(&proxies).Error
 	return proxies, err
 }
 
+// SearchByIPRange 按网段或IP前缀查找代理
+func (p *ProxyPool) SearchByIPRange(r models.IPRange, limit int) ([]*models.Proxy, error) {
+	return r.FindProxies(p.db, limit)
+}
+
+// RemoveProxiesInRange 删除网段或IP前缀内的代理，返回删除数量和跳过的固定代理数
+// includePinned 为 false 时不删除固定代理
+func (p *ProxyPool) RemoveProxiesInRange(r models.IPRange, includePinned bool) (int, int, error) {
+	removed, skipped, err := r.DeleteProxies(p.db, "ip_range", includePinned)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, proxy := range removed {
+		p.events.Publish(NewProxyEvent(EventProxyRemoved, proxy))
+	}
+	p.logger.Info("按IP范围删除代理",
+		zap.Int("删除数量", len(removed)),
+		zap.Int("跳过固定代理数", skipped),
+	)
+	return len(removed), skipped, nil
+}
+
 // UpdateProxyStatus 更新代理状态
 func (p *ProxyPool) UpdateProxyStatus(proxy *models.Proxy, available bool, speed int64) error {
 	p.mu.Lock()
@@ -88,26 +292,18 @@ func (p *ProxyPool) UpdateProxyStatus(proxy *models.Proxy, available bool, speed
 
 // RemoveProxy 从池中删除代理
 func (p *ProxyPool) RemoveProxy(proxyID uint) error {
-	return p.db.Delete(&models.Proxy{}, proxyID).Error
-}
-
-// CleanupExpired 清理过期代理
-func (p *ProxyPool) CleanupExpired() error {
-	var proxies []models.Proxy
-	if err := p.db.Find(&proxies).Error; err != nil {
+	if err := p.db.Delete(&models.Proxy{}, proxyID).Error; err != nil {
 		return err
 	}
-
-	for _, proxy := range proxies {
-		if proxy.IsExpired() {
-			if err := p.RemoveProxy(proxy.ID); err != nil {
-				p.logger.Error("Failed to remove expired proxy", zap.Error(err))
-			}
-		}
-	}
+	p.events.Publish(Event{Type: EventProxyRemoved, ProxyID: proxyID, Time: time.Now()})
 	return nil
 }
 
+// CleanupExpired 清理过期代理，按保留模式和代理源的清理策略处理，固定代理只标记为不可用
+func (p *ProxyPool) CleanupExpired() error {
+	return p.cleanupExpiredProxies()
+}
+
 // calculateSuccessRate 计算代理成功率
 func (p *ProxyPool) calculateSuccessRate(proxy *models.Proxy, success bool) float64 {
 	currentRate := proxy.GetSuccessRate()
@@ -119,7 +315,7 @@ func (p *ProxyPool) calculateSuccessRate(proxy *models.Proxy, success bool) floa
 
 // ValidateProxy 验证代理可用性
 func (p *ProxyPool) ValidateProxy(proxy *models.Proxy) error {
-	validator := NewProxyValidator(p.db, p.logger, p.maxFailCount)
+	validator := p.Validator()
 
 	// 验证基本可用性和速度
 	if err := validator.ValidateProxy(proxy); err != nil {
@@ -168,20 +364,432 @@ func (p *ProxyPool) Logger() *zap.Logger {
 }
 
 // GetProxyForTask 根据任务需求获取代理
-func (p *ProxyPool) GetProxyForTask(task *Task) (*models.Proxy, error) {
-	return p.scheduler.ScheduleProxy(task)
+// 设置了 ExcludeRecent 时额外排除该客户端最近获取过的代理；
+// 调度最长 task.Timeout，未设置或超过 MaxSchedulingTimeout 时为 MaxSchedulingTimeout，超时返回 *SchedulingTimeoutError
+func (p *ProxyPool) GetProxyForTask(ctx context.Context, task *Task) (*models.Proxy, error) {
+	timeout := task.Timeout
+	if timeout <= 0 || timeout > MaxSchedulingTimeout {
+		timeout = MaxSchedulingTimeout
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	proxy, err := p.getProxyForTask(ctx, task)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		elapsed := time.Since(start)
+		p.logger.Warn("调度代理超时",
+			zap.String("调度策略", string(task.Strategy)),
+			zap.Duration("超时时间", timeout),
+			zap.Duration("耗时", elapsed),
+			zap.Error(err),
+		)
+		return nil, &SchedulingTimeoutError{Timeout: timeout, Elapsed: elapsed}
+	}
+	return proxy, err
+}
+
+// getProxyForTask 在 ctx 限制下根据任务需求获取代理
+func (p *ProxyPool) getProxyForTask(ctx context.Context, task *Task) (*models.Proxy, error) {
+	if task.Group != "" {
+		if err := p.applyGroup(ctx, task); err != nil {
+			return nil, err
+		}
+	}
+	if task.ExcludeRecent > 0 {
+		task.ExcludeIDs = append(task.ExcludeIDs, p.recent.Recent(task.ClientID, task.ExcludeRecent)...)
+	}
+
+	var proxy *models.Proxy
+	var err error
+	if task.Fast {
+		proxy = p.fastProxy(task)
+	}
+	switch {
+	case proxy != nil:
+		// 已从候选代理缓冲区占用代理
+	case task.Strategy == StrategyRoundRobinCached:
+		proxy, err = p.LoadBalancer(task.ProxyType).GetProxy(task.ExcludeIDs...)
+		if errors.Is(err, ErrNoProxyAvailable) {
+			err = &NoProxyError{Err: err, Filters: models.ProxyFilter{Type: task.ProxyType, ExcludeIDs: task.ExcludeIDs}}
+		}
+	default:
+		proxy, err = p.scheduleProxy(ctx, task)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.recent.Record(task.ClientID, proxy.ID)
+	task.HandoutID = p.handouts.Record(task, proxy.ID)
+	p.decisions.Record(proxy.ID, task.Strategy, task.Domain)
+	return proxy, nil
+}
+
+// scheduleProxy 通过调度器调度代理，任务要求高可用且调度器支持时优先调度高可用代理
+func (p *ProxyPool) scheduleProxy(ctx context.Context, task *Task) (*models.Proxy, error) {
+	if task.HighReliability {
+		if hr, ok := p.scheduler.(highReliabilityScheduler); ok {
+			return hr.ScheduleHighReliability(ctx, task)
+		}
+	}
+	return p.scheduler.ScheduleProxy(ctx, task)
+}
+
+// DecisionLog 获取调度记录
+func (p *ProxyPool) DecisionLog() *DecisionLog {
+	return p.decisions
+}
+
+// SchedulerFairness 统计 window 内的发放公平性，当前可用但未发放过的代理也计入统计
+func (p *ProxyPool) SchedulerFairness(window time.Duration) (FairnessReport, error) {
+	var available []uint
+	if err := p.db.Model(&models.Proxy{}).Where("available = ?", true).Pluck("id", &available).Error; err != nil {
+		return FairnessReport{}, err
+	}
+	return p.decisions.Fairness(window, available), nil
+}
+
+// LoadBalancer 获取指定代理类型的负载均衡器，首次使用时创建并启动
+// 负载均衡器只按代理类型区分，任务中的其他过滤条件不生效
+func (p *ProxyPool) LoadBalancer(proxyType models.ProxyType) *LoadBalancer {
+	p.balancerMu.Lock()
+	defer p.balancerMu.Unlock()
+
+	if lb, ok := p.balancers[proxyType]; ok {
+		return lb
+	}
+
+	lb := NewLoadBalancer(p, &models.ScheduleOptions{PreferredType: proxyType}, p.balancerRefreshInterval)
+	lb.Mode = p.balancerMode
+	lb.Start()
+	go lb.WatchChanges(context.Background(), p.events)
+	p.balancers[proxyType] = lb
+	return lb
+}
+
+// SetBalancerRefreshInterval 设置负载均衡器缓存刷新间隔，对之后创建的负载均衡器生效
+func (p *ProxyPool) SetBalancerRefreshInterval(interval time.Duration) {
+	p.balancerMu.Lock()
+	defer p.balancerMu.Unlock()
+	p.balancerRefreshInterval = interval
+	p.logger.Info("更新负载均衡器刷新间隔",
+		zap.Duration("刷新间隔", interval),
+	)
+}
+
+// SetBalancerMode 设置负载均衡器选择代理的方式，对之后创建的负载均衡器生效
+func (p *ProxyPool) SetBalancerMode(mode BalancerMode) {
+	p.balancerMu.Lock()
+	defer p.balancerMu.Unlock()
+	p.balancerMode = mode
+	p.logger.Info("更新负载均衡器选择方式",
+		zap.String("选择方式", string(mode)),
+	)
+}
+
+// Shutdown 停止代理池的后台任务
+func (p *ProxyPool) Shutdown() {
+	p.CancelRedisMigration()
+
+	p.balancerMu.Lock()
+	defer p.balancerMu.Unlock()
+
+	for proxyType, lb := range p.balancers {
+		lb.Stop()
+		delete(p.balancers, proxyType)
+	}
+	for proxyType, buffer := range p.fastPath {
+		buffer.Stop()
+		delete(p.fastPath, proxyType)
+	}
+}
+
+// GetLiveConcurrentUse 获取代理当前并发使用数，数据来自调度器内存而非数据库
+func (p *ProxyPool) GetLiveConcurrentUse(proxyID uint) int {
+	return p.scheduler.LiveConcurrentUse(proxyID)
+}
+
+// ReportProxyStatus 报告代理使用状态，带有发放ID时关联到原始发放
+// 发放ID属于其他代理时返回 ErrHandoutMismatch 且不处理上报
+func (p *ProxyPool) ReportProxyStatus(proxyID uint, report StatusReport) error {
+	if err := p.correlateReport(proxyID, &report); err != nil {
+		return err
+	}
+	p.releaseBalancers(proxyID)
+	p.scheduler.ReportProxyStatus(proxyID, report)
+	return nil
+}
+
+// releaseBalancers 归还负载均衡器为代理记录的一次占用，roundrobin_cached 发放的代理不经过调度器
+func (p *ProxyPool) releaseBalancers(proxyID uint) {
+	p.balancerMu.Lock()
+	defer p.balancerMu.Unlock()
+
+	for _, lb := range p.balancers {
+		if lb.Release(proxyID) {
+			return
+		}
+	}
+}
+
+// PredictProxyScore 预测代理在 horizon 之后的评分
+func (p *ProxyPool) PredictProxyScore(proxyID uint, horizon time.Duration) float64 {
+	return p.scheduler.PredictProxyScore(proxyID, horizon)
+}
+
+// ReportProxyStatuses 批量报告代理使用状态
+// 发放ID属于其他代理的条目结果为 ReportInvalid，不影响其他条目
+func (p *ProxyPool) ReportProxyStatuses(reports []ProxyStatusReport) ([]StatusReportResult, error) {
+	results := make([]StatusReportResult, len(reports))
+	correlated := make([]ProxyStatusReport, 0, len(reports))
+	indexes := make([]int, 0, len(reports))
+	for i, report := range reports {
+		if err := p.correlateReport(report.ProxyID, &report.StatusReport); err != nil {
+			results[i] = StatusReportResult{ProxyID: report.ProxyID, Status: ReportInvalid, Error: err.Error()}
+			continue
+		}
+		p.releaseBalancers(report.ProxyID)
+		correlated = append(correlated, report)
+		indexes = append(indexes, i)
+	}
+
+	applied, err := p.scheduler.ReportProxyStatuses(correlated)
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range applied {
+		results[indexes[j]] = result
+	}
+	return results, nil
+}
+
+// RealtimeStats 获取实时统计
+func (p *ProxyPool) RealtimeStats() *RealtimeStats {
+	return p.realtime
+}
+
+// SetValidationService 设置常驻验证服务，供API触发和查询验证任务
+func (p *ProxyPool) SetValidationService(service *ValidationService) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.validation = service
+}
+
+// SetValidator 设置代理池使用的验证器
+func (p *ProxyPool) SetValidator(validator *ProxyValidator) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.validator = validator
+}
+
+// Validator 获取代理池使用的验证器，未设置时按当前配置创建
+func (p *ProxyPool) Validator() *ProxyValidator {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.validator == nil {
+		p.validator = NewProxyValidator(p.db, p.logger, p.FailPolicy().MaxFailCount)
+		p.validator.SetRuntimeConfig(p.runtime)
+		p.validator.SetRealtimeStats(p.realtime)
+		p.validator.SetEventBus(p.events)
+	}
+	return p.validator
+}
+
+// SetFetcher 设置代理获取器
+func (p *ProxyPool) SetFetcher(fetcher *ProxyFetcher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetcher = fetcher
 }
 
-// ReportProxyStatus 报告代理使用状态
-func (p *ProxyPool) ReportProxyStatus(proxyID uint, success bool, speed int64) {
-	p.scheduler.ReportProxyStatus(proxyID, success, speed)
+// SetFetchTopUp 设置清理和优化后的补充获取
+func (p *ProxyPool) SetFetchTopUp(topUp *FetchTopUp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.topUp = topUp
+}
+
+// CheckTopUp 清理或优化后检查可用代理是否不足，不足时补充获取，未设置补充获取时不处理
+func (p *ProxyPool) CheckTopUp(reason string) {
+	p.mu.RLock()
+	topUp := p.topUp
+	p.mu.RUnlock()
+	if topUp != nil {
+		topUp.Check(reason)
+	}
+}
+
+// AutoMaintenance 自动维护代理池，可用代理少于 MinProxies 时通过补充获取获取代理，与清理后的补充获取共用冷却时间
+func (p *ProxyPool) AutoMaintenance() error {
+	return models.AutoMaintenance(p.db, p.MaintenanceConfig(), func() error {
+		p.CheckTopUp("自动维护")
+		return nil
+	})
+}
+
+// SetCronJobs 设置定时任务管理器，供API查询任务状态
+func (p *ProxyPool) SetCronJobs(jobs *CronJobs) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cronJobs = jobs
+}
+
+// CronJobs 获取定时任务管理器，未设置时返回nil
+func (p *ProxyPool) CronJobs() *CronJobs {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cronJobs
+}
+
+// Fetcher 获取代理获取器，未设置时返回nil
+func (p *ProxyPool) Fetcher() *ProxyFetcher {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.fetcher
+}
+
+// SetWarmup 设置启动预热，预热结束前就绪检查返回未就绪
+func (p *ProxyPool) SetWarmup(warmup *Warmup) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.warmup = warmup
+}
+
+// WarmupStatus 获取启动预热状态，未设置预热时视为已完成
+func (p *ProxyPool) WarmupStatus() WarmupStatus {
+	p.mu.RLock()
+	warmup := p.warmup
+	p.mu.RUnlock()
+	return warmup.Status()
+}
+
+// ValidationService 获取验证服务，未设置时返回nil
+func (p *ProxyPool) ValidationService() *ValidationService {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.validation
+}
+
+// GetFullStatus 获取代理池完整状态，合并数据库统计和调度器内存状态
+// 结果缓存 statusCacheTTL，避免频繁请求反复查询数据库
+func (p *ProxyPool) GetFullStatus() (*models.PoolStatus, error) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+
+	if p.statusCache != nil && time.Since(p.statusCachedAt) < statusCacheTTL {
+		cached := *p.statusCache
+		return &cached, nil
+	}
+
+	status, err := p.GetFullStatusLive()
+	if err != nil {
+		return nil, err
+	}
+	cached := *status
+	p.statusCache = &cached
+	p.statusCachedAt = time.Now()
+	return status, nil
+}
+
+// cachedScoreDistribution 缓存的评分分布
+type cachedScoreDistribution struct {
+	dist     *models.ScoreDistribution
+	cachedAt time.Time
+}
+
+// ScoreDistribution 获取代理评分分布，与完整状态一样缓存 statusCacheTTL
+func (p *ProxyPool) ScoreDistribution(bucketSize float64) (*models.ScoreDistribution, error) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+
+	if cached, ok := p.scoreCache[bucketSize]; ok && time.Since(cached.cachedAt) < statusCacheTTL {
+		return cached.dist, nil
+	}
+
+	dist, err := models.GetScoreDistribution(p.db, bucketSize)
+	if err != nil {
+		return nil, err
+	}
+	if p.scoreCache == nil {
+		p.scoreCache = make(map[float64]cachedScoreDistribution)
+	}
+	// 分桶宽度由请求指定，顺带清除过期的缓存
+	for size, cached := range p.scoreCache {
+		if time.Since(cached.cachedAt) >= statusCacheTTL {
+			delete(p.scoreCache, size)
+		}
+	}
+	p.scoreCache[bucketSize] = cachedScoreDistribution{dist: dist, cachedAt: time.Now()}
+	return dist, nil
+}
+
+// GetFullStatusLive 不使用缓存，直接查询代理池完整状态
+func (p *ProxyPool) GetFullStatusLive() (*models.PoolStatus, error) {
+	status, err := models.GetPoolStatus(p.db)
+	if err != nil {
+		return nil, err
+	}
+	status.ProxiesInCooldown = int64(p.scheduler.CooldownCount())
+	return status, nil
+}
+
+// SetWebhookNotifier 设置验证结果回调
+func (p *ProxyPool) SetWebhookNotifier(notifier *WebhookNotifier) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.webhooks = notifier
+}
+
+// RegisterCallback 为代理注册验证结果回调，未配置回调通知器时返回错误
+func (p *ProxyPool) RegisterCallback(proxyID uint, callbackURL string) error {
+	p.mu.RLock()
+	notifier := p.webhooks
+	p.mu.RUnlock()
+
+	if notifier == nil {
+		return ErrWebhooksDisabled
+	}
+	return notifier.Register(proxyID, callbackURL)
+}
+
+// RedisGuard 获取Redis熔断器，使用Redis的组件应通过它访问Redis
+func (p *ProxyPool) RedisGuard() *RedisGuard {
+	return p.redisGuard
+}
+
+// Events 获取事件总线
+func (p *ProxyPool) Events() *EventBus {
+	return p.events
+}
+
+// PublishRetained 发布观察模式下本应清理的代理事件，供 models.SetRetentionObserver 使用
+func (p *ProxyPool) PublishRetained(retained []models.RetainedProxy) {
+	now := time.Now()
+	for _, r := range retained {
+		p.events.Publish(Event{
+			Type:      EventProxyRetained,
+			ProxyID:   r.ProxyID,
+			ProxyType: r.Type,
+			Score:     r.Score,
+			Action:    r.Action,
+			Time:      now,
+		})
+	}
+	p.logger.Info("观察模式下保留本应清理的代理", zap.Int("代理数", len(retained)))
 }
 
 // Scheduler 获取调度器
-func (p *ProxyPool) Scheduler() *ProxyScheduler {
+func (p *ProxyPool) Scheduler() Scheduler {
 	return p.scheduler
 }
 
+// SetScheduler 替换调度器，默认使用 ProxyScheduler
+// 调度时不加锁读取调度器，需在启动任务队列和API服务前调用
+func (p *ProxyPool) SetScheduler(scheduler Scheduler) {
+	p.scheduler = scheduler
+}
+
 // validateProxy 验证代理
 func (p *ProxyPool) validateProxy(proxy *models.Proxy) error {
 	p.logger.Info("开始验证代理",
@@ -189,8 +797,7 @@ func (p *ProxyPool) validateProxy(proxy *models.Proxy) error {
 		zap.Int("端口", proxy.Port),
 	)
 
-	// 创建验证器
-	validator := NewProxyValidator(p.db, p.logger, p.maxFailCount)
+	validator := p.Validator()
 
 	// 基本验证
 	if err := validator.ValidateProxy(proxy); err != nil {
@@ -223,12 +830,12 @@ func (p *ProxyPool) validateProxy(proxy *models.Proxy) error {
 	return nil
 }
 
-// validateAllProxies 验证所有代理
-func (p *ProxyPool) validateAllProxies() error {
+// validateAllProxies 验证所有代理，指定 types 时只验证这些类型的代理，ctx 取消时中止
+func (p *ProxyPool) validateAllProxies(ctx context.Context, types ...models.ProxyType) error {
 	p.logger.Info("开始验证所有代理")
 
-	validator := NewProxyValidator(p.db, p.logger, p.maxFailCount)
-	return validator.ValidateAll()
+	validator := p.Validator()
+	return validator.ValidateAll(ctx, types...)
 }
 
 // cleanupExpiredProxies 清理过期代理
@@ -237,18 +844,86 @@ func (p *ProxyPool) cleanupExpiredProxies() error {
 	return models.CleanupExpired(p.db)
 }
 
-// optimizePool 优化代理池
-func (p *ProxyPool) optimizePool() error {
+// OptimizePool 优化代理池
+func (p *ProxyPool) OptimizePool() (*models.OptimizeResult, error) {
 	p.logger.Info("开始优化代理池")
-	return models.OptimizePool(p.db)
+	result, err := models.OptimizePool(p.db, p.MaintenanceConfig())
+	if err != nil {
+		return nil, err
+	}
+	p.logger.Info("代理池优化完成",
+		zap.Int64("删除代理数", result.Deleted),
+		zap.Int64("隔离代理数", result.Quarantined),
+		zap.Int64("标记不可用的固定代理数", result.Pinned),
+		zap.Int64("观察模式保留代理数", result.Observed),
+		zap.Int64("评分变化代理数", result.Rescored),
+		zap.Int64("提高并发数代理数", result.Promoted),
+	)
+	p.CheckTopUp("手动优化代理池")
+	return result, nil
+}
+
+// SweepOrphans 清理已删除代理遗留的使用记录、标签、评分历史等子表记录，dryRun 为 true 时只统计数量
+func (p *ProxyPool) SweepOrphans(dryRun bool) (*models.OrphanSweepResult, error) {
+	result, err := models.SweepOrphans(p.db, dryRun, models.DefaultOrphanBatchSize)
+	if err != nil {
+		p.logger.Error("清理孤立记录失败", zap.Bool("仅统计", dryRun), zap.Error(err))
+		return nil, err
+	}
+	if dryRun {
+		p.logger.Info("统计孤立记录完成", zap.Int64("孤立记录数", result.Total), zap.Any("各表记录数", result.Tables))
+	} else if result.Total > 0 {
+		p.logger.Info("孤立记录清理完成", zap.Int64("删除数量", result.Total), zap.Any("各表删除数", result.Tables))
+	}
+	return result, nil
+}
+
+// SetMaxProxyAge 设置代理最大存活时间，计算评分(优化、上报使用结果、调度权重)时年龄超过一半的代理评分减半
+func (p *ProxyPool) SetMaxProxyAge(maxAge time.Duration) {
+	models.SetScoreMaxAge(maxAge)
 }
 
-// SetMaxFailCount 设置最大失败次数
-func (p *ProxyPool) SetMaxFailCount(count int) {
+// SetReputationBanTTL 设置封禁上报的有效期
+func (p *ProxyPool) SetReputationBanTTL(ttl time.Duration) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.maxFailCount = count
+	p.reputationBanTTL = ttl
+	p.logger.Info("更新封禁上报有效期",
+		zap.Duration("有效期", ttl),
+	)
+}
+
+// ReputationBanTTL 获取封禁上报的有效期
+func (p *ProxyPool) ReputationBanTTL() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.reputationBanTTL
+}
+
+// SetFailPolicy 设置默认及各代理类型的最大失败次数，策略不合法时保持不变并返回错误
+func (p *ProxyPool) SetFailPolicy(policy FailPolicy) error {
+	if err := p.runtime.modify(func(c *RuntimeConfig) { c.FailPolicy = policy.clone() }); err != nil {
+		p.logger.Error("更新代理最大失败次数失败", zap.Any("失败策略", policy), zap.Error(err))
+		return err
+	}
 	p.logger.Info("更新代理最大失败次数",
-		zap.Int("新的最大失败次数", count),
+		zap.Int("默认最大失败次数", policy.MaxFailCount),
+		zap.Any("各类型最大失败次数", policy.TypeMaxFailCount),
 	)
+	return nil
+}
+
+// FailPolicy 获取默认及各代理类型的最大失败次数
+func (p *ProxyPool) FailPolicy() FailPolicy {
+	return p.runtime.Get().FailPolicy
+}
+
+// RuntimeConfig 获取运行时可修改的配置
+func (p *ProxyPool) RuntimeConfig() *RuntimeConfigStore {
+	return p.runtime
+}
+
+// MaintenanceConfig 代理池优化使用的维护配置，阈值取自当前运行时配置
+func (p *ProxyPool) MaintenanceConfig() *models.MaintenanceConfig {
+	return p.runtime.Get().MaintenanceConfig()
 }
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建迁移好的内存SQLite数据库，测试结束后关闭
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// 内存数据库每个连接各自独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := models.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// newTestPool 创建使用内存SQLite和 miniredis 的代理池
+func newTestPool(t testing.TB) (*ProxyPool, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewProxyPool(newTestDB(t), client, zap.NewNop()), mr
+}
+
+// newTestProxy 创建一个可用的代理并写入数据库，modify 可在写入前修改字段
+func newTestProxy(t testing.TB, db *gorm.DB, ip string, modify ...func(*models.Proxy)) *models.Proxy {
+	t.Helper()
+
+	p := &models.Proxy{
+		IP:        ip,
+		Port:      8080,
+		Type:      models.ProxyTypeTemp,
+		Protocol:  "http",
+		Region:    models.ProxyRegionOther,
+		Source:    "test",
+		Available: true,
+		Success:   9,
+		Failure:   1,
+		Score:     80,
+	}
+	for _, fn := range modify {
+		fn(p)
+	}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatalf("create proxy %s: %v", ip, err)
+	}
+	return p
+}
+
+func TestAddProxyUpsertKeepsUnspecifiedFields(t *testing.T) {
+	pool, _ := newTestPool(t)
+	pool.SetDuplicatePolicy(DuplicateUpsert)
+	existing := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) {
+		p.Type = models.ProxyTypeLong
+		p.Region = models.ProxyRegionCN
+		p.Anonymous = true
+	})
+
+	// 只指定了来源的更新不会把类型和地区改回默认值
+	updated, created, err := pool.AddProxy(&models.Proxy{IP: "1.1.1.1", Port: 8080, Protocol: "http", Source: "manual"})
+	if err != nil {
+		t.Fatalf("AddProxy: %v", err)
+	}
+	if created || updated.ID != existing.ID {
+		t.Fatalf("AddProxy created = %v, id = %d, want update of %d", created, updated.ID, existing.ID)
+	}
+
+	var stored models.Proxy
+	pool.DB().First(&stored, existing.ID)
+	if stored.Type != models.ProxyTypeLong || stored.Region != models.ProxyRegionCN || !stored.Anonymous {
+		t.Errorf("stored type = %s, region = %s, anonymous = %v, want long, cn, true", stored.Type, stored.Region, stored.Anonymous)
+	}
+	if stored.Source != "manual" {
+		t.Errorf("stored source = %q, want %q", stored.Source, "manual")
+	}
+
+	// 指定了的字段照常覆盖
+	if _, _, err := pool.AddProxy(&models.Proxy{IP: "1.1.1.1", Port: 8080, Protocol: "http", Region: models.ProxyRegionOther}); err != nil {
+		t.Fatalf("AddProxy: %v", err)
+	}
+	pool.DB().First(&stored, existing.ID)
+	if stored.Region != models.ProxyRegionOther || stored.Type != models.ProxyTypeLong {
+		t.Errorf("stored type = %s, region = %s, want long, other", stored.Type, stored.Region)
+	}
+
+	// 新建时仍补全默认值
+	fresh, created, err := pool.AddProxy(&models.Proxy{IP: "2.2.2.2", Port: 8080, Protocol: "http"})
+	if err != nil || !created {
+		t.Fatalf("AddProxy new = %v, %v, want created", created, err)
+	}
+	if fresh.Type != models.ProxyTypeTemp || fresh.Region != models.ProxyRegionOther {
+		t.Errorf("new proxy type = %s, region = %s, want temp, other", fresh.Type, fresh.Region)
+	}
+}
+
+func TestCleanupExpiredFollowsRetentionMode(t *testing.T) {
+	pool, _ := newTestPool(t)
+	expired := newTestProxy(t, pool.DB(), "1.1.1.1")
+	pinned := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Pinned = true })
+	live := newTestProxy(t, pool.DB(), "3.3.3.3")
+	// 创建时会写入当前时间，之后再改为很久以前检查过
+	pool.DB().Model(&models.Proxy{}).Where("id IN ?", []uint{expired.ID, pinned.ID}).
+		UpdateColumn("last_check", time.Now().Add(-30*24*time.Hour))
+
+	models.SetRetentionMode(models.RetentionQuarantine)
+	t.Cleanup(func() { models.SetRetentionMode(models.RetentionDelete) })
+
+	if err := pool.CleanupExpired(); err != nil {
+		t.Fatalf("CleanupExpired: %v", err)
+	}
+
+	load := func(id uint) *models.Proxy {
+		var stored models.Proxy
+		if err := pool.DB().First(&stored, id).Error; err != nil {
+			t.Fatalf("load proxy %d: %v", id, err)
+		}
+		return &stored
+	}
+	// 隔离模式下过期代理隔离而不删除
+	if got := load(expired.ID); got.Available || !got.Quarantined {
+		t.Errorf("expired proxy available = %v, quarantined = %v, want false, true", got.Available, got.Quarantined)
+	}
+	if got := load(pinned.ID); got.Available || got.Quarantined {
+		t.Errorf("pinned proxy available = %v, quarantined = %v, want false, false", got.Available, got.Quarantined)
+	}
+	if got := load(live.ID); !got.Available || got.Quarantined {
+		t.Errorf("unexpired proxy available = %v, quarantined = %v, want true, false", got.Available, got.Quarantined)
+	}
+}
+
+func TestGetProxyForTaskTimesOutOnSlowDatabase(t *testing.T) {
+	pool, _ := newTestPool(t)
+	newTestProxy(t, pool.DB(), "1.1.1.1")
+
+	// 查询在 ctx 结束或1秒后才继续执行，模拟缓慢的数据库
+	if err := pool.DB().Callback().Query().Before("gorm:query").Register("test:slow", func(tx *gorm.DB) {
+		select {
+		case <-tx.Statement.Context.Done():
+			tx.AddError(tx.Statement.Context.Err())
+		case <-time.After(time.Second):
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	start := time.Now()
+	_, err := pool.GetProxyForTask(context.Background(), &Task{ProxyType: models.ProxyTypeTemp, Timeout: 50 * time.Millisecond})
+	elapsed := time.Since(start)
+
+	var timeoutErr *SchedulingTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrSchedulingTimeout) {
+		t.Fatalf("error = %v, want a SchedulingTimeoutError", err)
+	}
+	if timeoutErr.Timeout != 50*time.Millisecond || timeoutErr.Elapsed < timeoutErr.Timeout {
+		t.Errorf("timeout error = %+v, want the 50ms task timeout", timeoutErr)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("GetProxyForTask took %s, want it bounded by the task timeout", elapsed)
+	}
+
+	// 数据库恢复后正常调度
+	pool.DB().Callback().Query().Remove("test:slow")
+	if _, err := pool.GetProxyForTask(context.Background(), &Task{ProxyType: models.ProxyTypeTemp, Timeout: time.Second}); err != nil {
+		t.Errorf("GetProxyForTask after recovery: %v", err)
+	}
+}
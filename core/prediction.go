@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"math"
+	"proxy_pool/models"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	predictionHistorySize    = 20               // 预测评分使用的历史记录数
+	minPredictionPoints      = 3                // 历史记录少于该数量时使用当前评分
+	DefaultLookAheadDuration = 30 * time.Minute // 预测调度默认的预测时长
+)
+
+// PredictProxyScore 根据评分历史线性回归预测代理在 now+horizon 时的评分
+// 历史记录不足时返回当前评分，代理不存在时返回0
+func (s *ProxyScheduler) PredictProxyScore(proxyID uint, horizon time.Duration) float64 {
+	proxy, err := s.getProxyByID(proxyID)
+	if err != nil {
+		s.logger.Warn("预测评分时获取代理失败",
+			zap.Uint("代理ID", proxyID),
+			zap.Error(err),
+		)
+		return 0
+	}
+	return s.predictScore(context.Background(), proxy, horizon)
+}
+
+// predictScore 预测代理评分，查询历史失败时使用当前评分
+func (s *ProxyScheduler) predictScore(ctx context.Context, proxy *models.Proxy, horizon time.Duration) float64 {
+	history, err := models.ListScoreHistory(s.pool.DB().WithContext(ctx), proxy.ID, predictionHistorySize)
+	if err != nil {
+		s.logger.Warn("获取代理评分历史失败",
+			zap.Uint("代理ID", proxy.ID),
+			zap.Error(err),
+		)
+		return proxy.Score
+	}
+	return predictFromHistory(proxy, history, horizon)
+}
+
+// predictFromHistory 按时间升序的评分历史预测代理评分，历史记录不足时返回当前评分
+func predictFromHistory(proxy *models.Proxy, history []models.ProxyScoreHistory, horizon time.Duration) float64 {
+	if len(history) < minPredictionPoints {
+		return proxy.Score
+	}
+
+	// 以当前时间为原点，时间单位为小时
+	now := time.Now()
+	xs := make([]float64, len(history))
+	ys := make([]float64, len(history))
+	for i, h := range history {
+		xs[i] = h.RecordedAt.Sub(now).Hours()
+		ys[i] = h.Score
+	}
+
+	a, b, ok := linearRegression(xs, ys)
+	if !ok {
+		return proxy.Score
+	}
+	return math.Max(0, math.Min(a*horizon.Hours()+b, 100))
+}
+
+// linearRegression 最小二乘拟合 y = a*x + b，所有 x 相同时返回 false
+func linearRegression(xs, ys []float64) (a, b float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, 0, false
+	}
+	a = (n*sumXY - sumX*sumY) / denom
+	b = (sumY - a*sumX) / n
+	return a, b, true
+}
+
+// predictiveSchedule 按预测评分选择代理，避开评分快速下降的代理，调用方需持有 s.mu
+// predictions 由调用方在加锁前计算，避免持锁查询数据库
+func (s *ProxyScheduler) predictiveSchedule(proxies []models.Proxy, task *Task, predictions map[uint]float64) (*models.Proxy, error) {
+	if len(proxies) == 0 {
+		return nil, ErrNoProxyAvailable
+	}
+
+	var candidates []*models.Proxy
+	for i := range proxies {
+		proxy := &proxies[i]
+		if !s.isProxyQualified(proxy, task) {
+			continue
+		}
+		candidates = append(candidates, proxy)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoQualifiedProxy
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return predictions[candidates[i].ID] > predictions[candidates[j].ID]
+	})
+
+	selected := candidates[0]
+	s.recordHandout(selected)
+	return selected, nil
+}
+
+// predictScores 预测一组代理的评分，评分历史一次查询取回，查询失败或 ctx 到期时使用当前评分
+func (s *ProxyScheduler) predictScores(ctx context.Context, proxies []models.Proxy, horizon time.Duration) map[uint]float64 {
+	predictions := make(map[uint]float64, len(proxies))
+	ids := make([]uint, len(proxies))
+	for i := range proxies {
+		ids[i] = proxies[i].ID
+		predictions[proxies[i].ID] = proxies[i].Score
+	}
+	if len(proxies) == 0 || ctx.Err() != nil {
+		return predictions
+	}
+
+	histories, err := models.ListScoreHistories(s.pool.DB().WithContext(ctx), ids, predictionHistorySize)
+	if err != nil {
+		s.logger.Warn("批量获取代理评分历史失败",
+			zap.Int("代理数", len(ids)),
+			zap.Error(err),
+		)
+		return predictions
+	}
+	for i := range proxies {
+		predictions[proxies[i].ID] = predictFromHistory(&proxies[i], histories[proxies[i].ID], horizon)
+	}
+	return predictions
+}
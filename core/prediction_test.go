@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestPredictScoresBatchesHistory(t *testing.T) {
+	pool, _ := newTestPool(t)
+	rising := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Score = 60 })
+	falling := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Score = 60 })
+	fresh := newTestProxy(t, pool.DB(), "3.3.3.3", func(p *models.Proxy) { p.Score = 55 })
+	for i := 0; i < 4; i++ {
+		at := time.Now().Add(time.Duration(i-4) * time.Hour)
+		pool.DB().Create(&models.ProxyScoreHistory{ProxyID: rising.ID, Score: float64(30 + 10*i), RecordedAt: at})
+		pool.DB().Create(&models.ProxyScoreHistory{ProxyID: falling.ID, Score: float64(90 - 10*i), RecordedAt: at})
+	}
+
+	var proxies []models.Proxy
+	if err := pool.DB().Order("id").Find(&proxies).Error; err != nil {
+		t.Fatalf("load proxies: %v", err)
+	}
+	predictions := pool.Scheduler().(*ProxyScheduler).predictScores(context.Background(), proxies, time.Hour)
+
+	// 批量预测与逐个预测结果一致
+	for i := range proxies {
+		p := &proxies[i]
+		if want := pool.PredictProxyScore(p.ID, time.Hour); math.Abs(predictions[p.ID]-want) > 1e-3 {
+			t.Errorf("proxy %d batch prediction = %v, single = %v", p.ID, predictions[p.ID], want)
+		}
+	}
+	if predictions[rising.ID] <= predictions[falling.ID] {
+		t.Errorf("rising = %v, falling = %v, want rising higher", predictions[rising.ID], predictions[falling.ID])
+	}
+	if predictions[fresh.ID] != 55 {
+		t.Errorf("proxy without history = %v, want current score 55", predictions[fresh.ID])
+	}
+
+	// ctx 已结束时使用当前评分
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for id, score := range pool.Scheduler().(*ProxyScheduler).predictScores(ctx, proxies, time.Hour) {
+		if score != 60 && score != 55 {
+			t.Errorf("proxy %d prediction with cancelled ctx = %v, want current score", id, score)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"proxy_pool/metrics"
+	"proxy_pool/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultPrefetchCooldown = 2 * time.Minute  // 默认提前获取的最短间隔
+	prefetchPollInterval    = 30 * time.Second // 检查可用代理数的间隔
+	prefetchFactor          = 1.5              // 可用代理少于 MinProxies 的该倍数时提前获取
+)
+
+// GetPrefetchCooldown 获取提前获取付费代理的最短间隔，未配置时使用默认值
+func (c *Config) GetPrefetchCooldown() time.Duration {
+	if c.PrefetchCooldown <= 0 {
+		return DefaultPrefetchCooldown
+	}
+	return c.PrefetchCooldown
+}
+
+// StartPrefetchMonitor 启动预取监控，每30秒检查一次可用代理数，接近 MinProxies 时提前获取付费代理；
+// 同时检查即将过期的付费代理，从其来源提前获取替代代理。在 ctx 取消后停止
+func (p *ProxyPool) StartPrefetchMonitor(ctx context.Context, fetcher *ProxyFetcher) {
+	go func() {
+		ticker := time.NewTicker(prefetchPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				var available int64
+				if err := p.db.WithContext(ctx).Model(&models.Proxy{}).Where("available = ?", true).Count(&available).Error; err != nil {
+					p.logger.Warn("预取监控统计可用代理失败", zap.Error(err))
+					continue
+				}
+				fetcher.prefetchOnLowPool(available)
+
+				if _, err := fetcher.prefetchNearExpiry(ctx); err != nil {
+					p.logger.Warn("预取监控查询即将过期的代理失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// prefetchOnLowPool 可用代理少于 MinProxies*1.5 时在后台获取一次付费代理，返回是否触发
+// 正在获取付费代理、距上次开始获取或上次获取失败不足冷却时间时不触发
+func (f *ProxyFetcher) prefetchOnLowPool(available int64) bool {
+	minProxies := f.config.MaintenanceConfig().MinProxies
+	threshold := int64(float64(minProxies) * prefetchFactor)
+	if available >= threshold {
+		return false
+	}
+
+	cooldown := f.config.GetPrefetchCooldown()
+	f.mu.Lock()
+	if f.paidInFlight.Load() > 0 || time.Since(f.lastPaidFetch) < cooldown || time.Since(f.lastPaidFailure) < cooldown {
+		f.mu.Unlock()
+		return false
+	}
+	// 先计入进行中的获取，避免后台获取开始前重复触发
+	f.paidInFlight.Add(1)
+	f.mu.Unlock()
+
+	metrics.PrefetchTriggered.Inc()
+	f.logger.Info("可用代理接近下限，提前获取付费代理",
+		zap.Int64("可用代理数", available),
+		zap.Int64("预取阈值", threshold),
+		zap.Int("最少可用代理数", minProxies),
+	)
+
+	go func() {
+		defer f.paidInFlight.Add(-1)
+		if _, err := f.FetchPaidProxies(); err != nil {
+			f.logger.Error("提前获取付费代理失败", zap.Error(err))
+		}
+	}()
+	return true
+}
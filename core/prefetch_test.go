@@ -0,0 +1,87 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"proxy_pool/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+// waitPaidIdle 等待后台的付费代理获取结束
+func waitPaidIdle(t *testing.T, f *ProxyFetcher) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for f.paidInFlight.Load() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("paid fetch still in flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrefetchOnLowPool(t *testing.T) {
+	// 未配置付费代理源，每次获取都计为失败
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{MinProxies: 10, PrefetchCooldown: time.Minute})
+	before := testutil.ToFloat64(metrics.PrefetchTriggered)
+
+	if f.prefetchOnLowPool(15) {
+		t.Fatal("prefetch triggered at the threshold")
+	}
+
+	if !f.prefetchOnLowPool(14) {
+		t.Fatal("prefetch not triggered below the threshold")
+	}
+	// 已触发但尚未结束时不重复触发
+	if f.paidInFlight.Load() > 0 && f.prefetchOnLowPool(0) {
+		t.Fatal("prefetch triggered while a fetch is in flight")
+	}
+	waitPaidIdle(t, f)
+
+	paidAt, _ := f.LastFetches()
+	if paidAt.IsZero() {
+		t.Fatal("paid fetch start was not recorded")
+	}
+	if f.prefetchOnLowPool(0) {
+		t.Fatal("prefetch triggered within the cooldown")
+	}
+
+	// 上次开始获取已超过冷却时间，但失败在冷却时间内
+	f.mu.Lock()
+	f.lastPaidFetch = time.Now().Add(-time.Hour)
+	f.mu.Unlock()
+	if f.prefetchOnLowPool(0) {
+		t.Fatal("prefetch triggered within the cooldown after a failure")
+	}
+
+	// 失败也超过冷却时间后再次触发
+	f.mu.Lock()
+	f.lastPaidFailure = time.Now().Add(-time.Hour)
+	f.mu.Unlock()
+	if !f.prefetchOnLowPool(0) {
+		t.Fatal("prefetch not triggered after the cooldown")
+	}
+	waitPaidIdle(t, f)
+
+	if got := testutil.ToFloat64(metrics.PrefetchTriggered) - before; got != 2 {
+		t.Errorf("prefetch_triggered_total grew by %v, want 2", got)
+	}
+}
+
+func TestPrefetchSkipsWhileFetching(t *testing.T) {
+	f := NewProxyFetcher(newTestDB(t), zap.NewNop(), &Config{MinProxies: 10, PrefetchCooldown: time.Minute})
+
+	// 定时任务正在获取付费代理
+	f.paidInFlight.Add(1)
+	if f.prefetchOnLowPool(0) {
+		t.Fatal("prefetch triggered while a scheduled fetch is running")
+	}
+	f.paidInFlight.Add(-1)
+
+	if !f.prefetchOnLowPool(0) {
+		t.Fatal("prefetch not triggered once the fetch finished")
+	}
+	waitPaidIdle(t, f)
+}
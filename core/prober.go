@@ -0,0 +1,301 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"proxy_pool/models"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultProbeInterval = 15 * time.Second // 默认存活检查间隔
+	DefaultProbeTopN     = 50               // 默认每轮检查最近发放的代理数
+	defaultProbeWorkers  = 8                // 存活检查的并发数
+	defaultProbeTimeout  = 3 * time.Second  // 单个代理存活检查的超时时间
+)
+
+// ProbeType 存活检查方式
+type ProbeType string
+
+const (
+	ProbeTCP  ProbeType = "tcp"  // 只检查能否建立TCP连接
+	ProbeHEAD ProbeType = "head" // 通过代理发送HEAD请求，收到任意响应即视为存活
+)
+
+// IsValid 是否为支持的检查方式
+func (t ProbeType) IsValid() bool {
+	return t == ProbeTCP || t == ProbeHEAD
+}
+
+// recentUseTracker 可按最近使用时间列出代理的调度器
+type recentUseTracker interface {
+	RecentlyUsed(n int) []uint
+}
+
+// RecentlyUsed 按最后使用时间从近到远返回最多 n 个代理ID
+func (s *ProxyScheduler) RecentlyUsed(n int) []uint {
+	s.mu.RLock()
+	ids := make([]uint, 0, len(s.lastUsed))
+	for id := range s.lastUsed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return s.lastUsed[ids[i]].After(s.lastUsed[ids[j]])
+	})
+	s.mu.RUnlock()
+
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
+
+// ProbeResult 一轮存活检查的结果
+type ProbeResult struct {
+	Probed    int `json:"probed"`     // 检查的代理数
+	MarkedOff int `json:"marked_off"` // 连接失败被标记为不可用的代理数
+	Restored  int `json:"restored"`   // 之前由存活检查标记为不可用、本轮恢复的代理数
+}
+
+// Prober 两轮完整验证之间对正在发放的代理做轻量存活检查
+// 每轮检查调度器中最近发放的 topN 个代理，连接失败时立即标记为不可用并发布 EventProxyDown，
+// 使调度器在数秒内停止发放；由存活检查标记为不可用的代理恢复连接后重新标记为可用并发布 EventProxyUp
+type Prober struct {
+	pool      *ProxyPool
+	logger    *zap.Logger
+	probeType ProbeType
+	topN      int
+	interval  time.Duration
+	workers   int
+	timeout   time.Duration
+
+	mu   sync.Mutex
+	down map[uint]time.Time // 由存活检查标记为不可用的代理及标记时间
+}
+
+// NewProber 创建存活检查器，topN 或 interval 不大于0时使用默认值，probeType 为空时使用TCP检查
+func NewProber(pool *ProxyPool, probeType ProbeType, topN int, interval time.Duration) *Prober {
+	if probeType == "" {
+		probeType = ProbeTCP
+	}
+	if topN <= 0 {
+		topN = DefaultProbeTopN
+	}
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	return &Prober{
+		pool:      pool,
+		logger:    pool.Logger(),
+		probeType: probeType,
+		topN:      topN,
+		interval:  interval,
+		workers:   defaultProbeWorkers,
+		timeout:   defaultProbeTimeout,
+		down:      make(map[uint]time.Time),
+	}
+}
+
+// Run 按间隔进行存活检查，直到 ctx 取消
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.ProbeOnce(ctx); err != nil && ctx.Err() == nil {
+				p.logger.Warn("代理存活检查失败", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ProbeOnce 检查一轮最近发放的代理，以及之前由存活检查标记为不可用的代理
+func (p *Prober) ProbeOnce(ctx context.Context) (ProbeResult, error) {
+	var result ProbeResult
+	tracker, ok := p.pool.scheduler.(recentUseTracker)
+	if !ok {
+		return result, nil
+	}
+
+	ids := tracker.RecentlyUsed(p.topN)
+	p.mu.Lock()
+	for id := range p.down {
+		ids = append(ids, id)
+	}
+	p.mu.Unlock()
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var proxies []*models.Proxy
+	if err := p.pool.db.WithContext(ctx).Where("id IN ?", ids).Find(&proxies).Error; err != nil {
+		return result, err
+	}
+	p.forgetMissing(proxies)
+
+	jobs := make(chan *models.Proxy)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	workers := p.workers
+	if len(proxies) < workers {
+		workers = len(proxies)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for proxy := range jobs {
+				alive := p.probe(ctx, proxy) == nil
+				if ctx.Err() != nil {
+					continue
+				}
+				markedOff, restored, err := p.apply(proxy, alive)
+				if err != nil {
+					p.logger.Warn("更新代理可用状态失败", zap.Uint("代理ID", proxy.ID), zap.Error(err))
+					continue
+				}
+
+				mu.Lock()
+				result.Probed++
+				if markedOff {
+					result.MarkedOff++
+				}
+				if restored {
+					result.Restored++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, proxy := range proxies {
+		select {
+		case jobs <- proxy:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if result.MarkedOff > 0 || result.Restored > 0 {
+		p.logger.Info("代理存活检查完成",
+			zap.Int("检查数", result.Probed),
+			zap.Int("标记不可用数", result.MarkedOff),
+			zap.Int("恢复数", result.Restored),
+		)
+	}
+	return result, ctx.Err()
+}
+
+// forgetMissing 不再记录已删除的代理
+func (p *Prober) forgetMissing(found []*models.Proxy) {
+	exists := make(map[uint]bool, len(found))
+	for _, proxy := range found {
+		exists[proxy.ID] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.down {
+		if !exists[id] {
+			delete(p.down, id)
+		}
+	}
+}
+
+// apply 按检查结果切换代理的可用状态，返回是否标记为不可用、是否恢复
+// 只恢复由存活检查标记为不可用、之后没有经过完整验证且未被隔离的代理，标记后完整验证过的代理以验证结果为准
+func (p *Prober) apply(proxy *models.Proxy, alive bool) (bool, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	markedAt, marked := p.down[proxy.ID]
+	switch {
+	case !alive && proxy.Available:
+		if err := p.pool.db.Model(proxy).UpdateColumn("available", false).Error; err != nil {
+			return false, false, err
+		}
+		proxy.Available = false
+		p.down[proxy.ID] = time.Now()
+		p.pool.events.Publish(NewProxyEvent(EventProxyDown, proxy))
+		p.logger.Info("代理连接失败，标记为不可用",
+			zap.Uint("代理ID", proxy.ID),
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+		)
+		return true, false, nil
+
+	case alive && marked:
+		delete(p.down, proxy.ID)
+		if proxy.Available || proxy.Quarantined || proxy.LastCheck.After(markedAt) {
+			// 完整验证已恢复、判定失败或隔离了该代理
+			return false, false, nil
+		}
+		// 查询之后验证可能刚更新过代理，按条件更新避免覆盖验证结果
+		res := p.pool.db.Model(&models.Proxy{}).
+			Where("id = ? AND quarantined = ? AND last_check <= ?", proxy.ID, false, markedAt).
+			UpdateColumn("available", true)
+		if res.Error != nil {
+			return false, false, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return false, false, nil
+		}
+		proxy.Available = true
+		p.pool.events.Publish(NewProxyEvent(EventProxyUp, proxy))
+		return false, true, nil
+	}
+	return false, false, nil
+}
+
+// probe 按检查方式检查代理是否存活
+func (p *Prober) probe(ctx context.Context, proxy *models.Proxy) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(proxy.IP, strconv.Itoa(proxy.Port))
+	if p.probeType == ProbeTCP {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	testURLs := p.pool.Validator().TestURLs()
+	if len(testURLs) == 0 {
+		return fmt.Errorf("no test url configured")
+	}
+	proxyURL := &url.URL{Scheme: proxy.Protocol, Host: addr}
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, testURLs[0], nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+// reload 从数据库重新读取代理，模拟存活检查每轮查询到的最新状态
+func reload(t *testing.T, pool *ProxyPool, id uint) *models.Proxy {
+	t.Helper()
+	var proxy models.Proxy
+	if err := pool.DB().First(&proxy, id).Error; err != nil {
+		t.Fatalf("load proxy %d: %v", id, err)
+	}
+	return &proxy
+}
+
+func TestProberRestore(t *testing.T) {
+	tests := []struct {
+		name         string
+		afterMark    map[string]interface{} // 标记不可用后、恢复连接前对代理的修改
+		wantRestored bool
+	}{
+		{"probe marked only", nil, true},
+		{"validated after mark", map[string]interface{}{"last_check": time.Now().Add(time.Second)}, false},
+		{"quarantined after mark", map[string]interface{}{"quarantined": true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, _ := newTestPool(t)
+			proxy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.LastCheck = time.Now().Add(-time.Minute) })
+			prober := NewProber(pool, ProbeTCP, 0, 0)
+
+			markedOff, _, err := prober.apply(reload(t, pool, proxy.ID), false)
+			if err != nil || !markedOff {
+				t.Fatalf("apply(down) = %v, %v, want marked off", markedOff, err)
+			}
+			if tt.afterMark != nil {
+				pool.DB().Model(&models.Proxy{}).Where("id = ?", proxy.ID).UpdateColumns(tt.afterMark)
+			}
+
+			_, restored, err := prober.apply(reload(t, pool, proxy.ID), true)
+			if err != nil {
+				t.Fatalf("apply(up): %v", err)
+			}
+			if restored != tt.wantRestored {
+				t.Errorf("restored = %v, want %v", restored, tt.wantRestored)
+			}
+			if got := reload(t, pool, proxy.ID).Available; got != tt.wantRestored {
+				t.Errorf("available = %v, want %v", got, tt.wantRestored)
+			}
+			if len(prober.down) != 0 {
+				t.Errorf("prober still tracks %d proxies, want none", len(prober.down))
+			}
+		})
+	}
+}
+
+func TestProberTCPListener(t *testing.T) {
+	pool, _ := newTestPool(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	// 回环地址不能通过校验写入，按列更新指向本地监听
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+	pool.DB().Model(&models.Proxy{}).Where("id = ?", proxy.ID).
+		UpdateColumns(map[string]interface{}{"ip": "127.0.0.1", "port": port})
+
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+	scheduler.mu.Lock()
+	scheduler.recordHandout(proxy)
+	scheduler.mu.Unlock()
+
+	prober := NewProber(pool, ProbeTCP, 0, 0)
+	ctx := context.Background()
+
+	result, err := prober.ProbeOnce(ctx)
+	if err != nil {
+		t.Fatalf("ProbeOnce: %v", err)
+	}
+	if result != (ProbeResult{Probed: 1}) {
+		t.Fatalf("listening proxy result = %+v, want only probed", result)
+	}
+
+	// 监听停止后标记为不可用
+	ln.Close()
+	if result, _ = prober.ProbeOnce(ctx); result.MarkedOff != 1 {
+		t.Fatalf("closed listener result = %+v, want marked off", result)
+	}
+	if reload(t, pool, proxy.ID).Available {
+		t.Fatal("proxy still available after its listener closed")
+	}
+
+	// 重新监听后恢复
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("relisten on %s: %v", addr, err)
+	}
+	defer ln.Close()
+	if result, _ = prober.ProbeOnce(ctx); result.Restored != 1 {
+		t.Fatalf("relistened result = %+v, want restored", result)
+	}
+	if !reload(t, pool, proxy.ID).Available {
+		t.Error("proxy not available after its listener came back")
+	}
+}
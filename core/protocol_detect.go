@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"proxy_pool/metrics"
+	"proxy_pool/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// protocolProbeTimeout 检测协议时每种协议的超时时间
+const protocolProbeTimeout = 2 * time.Second
+
+// protocolDetectNone 所有协议都检测失败时的指标标签
+const protocolDetectNone = "none"
+
+// detectProtocols 按顺序尝试的协议
+var detectProtocols = []string{"http", "https", "socks5"}
+
+var ErrProtocolUndetected = errors.New("no protocol detected")
+
+// DetectProtocol 依次用 http、https、socks5 访问第一个测试网站，将协议设为第一个成功的协议
+// 全部失败时将代理标记为不可用并返回 ErrProtocolUndetected
+func (v *ProxyValidator) DetectProtocol(proxy *models.Proxy) error {
+	testURLs := v.TestURLs()
+	if len(testURLs) == 0 {
+		return fmt.Errorf("%w: no test url", ErrProtocolUndetected)
+	}
+
+	for _, protocol := range detectProtocols {
+		if err := probeProtocol(protocol, proxy, testURLs[0]); err != nil {
+			v.logger.Debug("协议检测失败",
+				zap.String("IP", proxy.IP),
+				zap.Int("端口", proxy.Port),
+				zap.String("协议", protocol),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		proxy.Protocol = protocol
+		metrics.ProtocolDetected.WithLabelValues(protocol).Inc()
+		v.logger.Info("检测到代理协议",
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+			zap.String("协议", protocol),
+		)
+		return nil
+	}
+
+	proxy.Available = false
+	metrics.ProtocolDetected.WithLabelValues(protocolDetectNone).Inc()
+	return fmt.Errorf("%w: %s:%d", ErrProtocolUndetected, proxy.IP, proxy.Port)
+}
+
+// probeProtocol 以指定协议通过代理访问测试网站
+func probeProtocol(protocol string, proxy *models.Proxy, testURL string) error {
+	proxyURL, err := url.Parse(fmt.Sprintf("%s://%s:%d", protocol, proxy.IP, proxy.Port))
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   protocolProbeTimeout,
+	}
+	resp, err := client.Get(testURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if class := classifyValidationError(nil, resp.StatusCode); class != ErrorClassNone {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestRandomScheduleIgnoresScore(t *testing.T) {
+	pool, _ := newTestPool(t)
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	scores := []float64{100, 80, 10, 1}
+	proxies := make([]models.Proxy, len(scores))
+	for i, score := range scores {
+		proxies[i].ID = uint(i + 1)
+		proxies[i].Type = models.ProxyTypeTemp
+		proxies[i].Available = true
+		proxies[i].Score = score
+		proxies[i].MaxConcurrent = 1
+	}
+	task := &Task{ProxyType: models.ProxyTypeTemp, Strategy: StrategyRandom}
+
+	const draws = 4000
+	counts := make(map[uint]int)
+	scheduler.mu.Lock()
+	for i := 0; i < draws; i++ {
+		proxy, err := scheduler.randomSchedule(proxies, task)
+		if err != nil {
+			scheduler.mu.Unlock()
+			t.Fatalf("randomSchedule: %v", err)
+		}
+		counts[proxy.ID]++
+	}
+	scheduler.mu.Unlock()
+
+	// 每个代理期望1000次，标准差约27，评分不影响选择
+	for id := uint(1); id <= uint(len(scores)); id++ {
+		if n := counts[id]; n < 850 || n > 1150 {
+			t.Errorf("proxy %d (score %v) picked %d times out of %d, want about %d", id, scores[id-1], n, draws, draws/len(scores))
+		}
+	}
+
+	// 不满足要求的代理不参与选择
+	scheduler.mu.Lock()
+	for i := range proxies[1:] {
+		scheduler.cooldown[proxies[i+1].ID] = time.Now().Add(time.Hour)
+	}
+	for i := 0; i < 20; i++ {
+		if proxy, err := scheduler.randomSchedule(proxies, task); err != nil || proxy.ID != 1 {
+			t.Errorf("with others cooling down got %v, %v, want proxy 1", proxy, err)
+			break
+		}
+	}
+	scheduler.cooldown[1] = time.Now().Add(time.Hour)
+	_, allCooling := scheduler.randomSchedule(proxies, task)
+	_, none := scheduler.randomSchedule(nil, task)
+	// 候选预览中各代理的权重相同
+	weights := []float64{
+		scheduler.candidateWeight(&proxies[0], StrategyRandom, nil),
+		scheduler.candidateWeight(&proxies[3], StrategyRandom, nil),
+	}
+	scheduler.mu.Unlock()
+
+	if weights[0] != 1 || weights[1] != 1 {
+		t.Errorf("random candidate weights = %v, want 1 for every proxy", weights)
+	}
+
+	if !errors.Is(allCooling, ErrNoQualifiedProxy) {
+		t.Errorf("all proxies cooling down error = %v, want %v", allCooling, ErrNoQualifiedProxy)
+	}
+	if !errors.Is(none, ErrNoProxyAvailable) {
+		t.Errorf("no proxies error = %v, want %v", none, ErrNoProxyAvailable)
+	}
+}
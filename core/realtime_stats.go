@@ -0,0 +1,243 @@
+package core
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultHandoutWindow = time.Minute     // 默认发放计数窗口
+	DefaultFailureWindow = 5 * time.Minute // 默认失败计数窗口
+
+	realtimeKeyspace    = "realtime"
+	realtimeBucket      = 10 * time.Second // 计数桶粒度
+	counterHandedOut    = "handed_out"
+	counterFailures     = "failures"
+	counterFetchFailure = "fetch_failures"
+	gaugeValidating     = "validations" // 有序集合，与旧版本的计数键 validating 区分
+
+	// validatingTTL 正在进行的验证的最长计数时间，进程异常退出未结束的验证超过该时间后不再计入
+	validatingTTL = 5 * time.Minute
+)
+
+// RealtimeSnapshot 实时统计快照
+type RealtimeSnapshot struct {
+	HandedOut           int64   `json:"handed_out"`            // 窗口内发放的代理数
+	HandoutWindow       string  `json:"handout_window"`        // 发放计数窗口
+	ValidationsInFlight int64   `json:"validations_in_flight"` // 正在进行的验证数
+	Failures            int64   `json:"failures"`              // 窗口内代理使用及验证失败数
+	FetchFailures       int64   `json:"fetch_failures"`        // 窗口内代理源获取失败数
+	FailureWindow       string  `json:"failure_window"`        // 失败计数窗口
+	Goroutines          int     `json:"goroutines"`            // 当前协程数
+	UptimeSeconds       float64 `json:"uptime_seconds"`        // 运行时长(秒)
+	RedisAvailable      bool    `json:"redis_available"`       // Redis是否可用，不可用时计数均为0
+}
+
+// RealtimeStats 基于Redis的滚动窗口计数器
+// 计数按 realtimeBucket 分桶写入，读取时汇总窗口内的所有桶；Redis不可用时写入静默失败，读取返回0
+type RealtimeStats struct {
+	redis     *RedisGuard
+	logger    *zap.Logger
+	startTime time.Time
+
+	mu            sync.RWMutex
+	handoutWindow time.Duration
+	failureWindow time.Duration
+
+	validatingTTL time.Duration // 正在进行的验证的最长计数时间
+	validatingSeq atomic.Int64  // 正在进行的验证的序号，与启动时间组成有序集合的成员
+}
+
+// NewRealtimeStats 创建实时统计
+func NewRealtimeStats(guard *RedisGuard, logger *zap.Logger) *RealtimeStats {
+	return &RealtimeStats{
+		redis:         guard,
+		logger:        logger,
+		startTime:     time.Now(),
+		handoutWindow: DefaultHandoutWindow,
+		failureWindow: DefaultFailureWindow,
+		validatingTTL: validatingTTL,
+	}
+}
+
+// SetWindows 设置计数窗口，非正值表示保持不变
+func (r *RealtimeStats) SetWindows(handout, failure time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if handout > 0 {
+		r.handoutWindow = handout
+	}
+	if failure > 0 {
+		r.failureWindow = failure
+	}
+}
+
+// windows 获取当前计数窗口
+func (r *RealtimeStats) windows() (time.Duration, time.Duration) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handoutWindow, r.failureWindow
+}
+
+// RecordHandout 记录一次代理发放
+func (r *RealtimeStats) RecordHandout() {
+	if r == nil {
+		return
+	}
+	handout, _ := r.windows()
+	r.incr(counterHandedOut, handout)
+}
+
+// RecordFailure 记录一次代理使用或验证失败
+func (r *RealtimeStats) RecordFailure() {
+	if r == nil {
+		return
+	}
+	_, failure := r.windows()
+	r.incr(counterFailures, failure)
+}
+
+// RecordFetchFailure 记录一次代理源获取失败
+func (r *RealtimeStats) RecordFetchFailure() {
+	if r == nil {
+		return
+	}
+	_, failure := r.windows()
+	r.incr(counterFetchFailure, failure)
+}
+
+// ValidationStarted 记录一次验证开始，返回的函数在验证结束时调用
+// 每次验证是有序集合中的一个成员，分值为过期时间，进程异常退出时未移除的成员过期后不再计数
+func (r *RealtimeStats) ValidationStarted() func() {
+	if r == nil {
+		return func() {}
+	}
+
+	key := r.redis.Key(realtimeKeyspace, gaugeValidating)
+	member := strconv.FormatInt(r.startTime.UnixNano(), 36) + ":" + strconv.FormatInt(r.validatingSeq.Add(1), 10)
+	err := r.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		now := time.Now()
+		pipe := client.Pipeline()
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now.UnixNano(), 10))
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.Add(r.validatingTTL).UnixNano()), Member: member})
+		pipe.PExpire(ctx, key, r.validatingTTL)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		r.logger.Debug("实时统计写入失败", zap.String("计数器", gaugeValidating), zap.Error(err))
+		// 未成功计数时无需回退
+		return func() {}
+	}
+
+	return func() {
+		err := r.redis.Do(func(ctx context.Context, client *redis.Client) error {
+			return client.ZRem(ctx, key, member).Err()
+		})
+		if err != nil {
+			r.logger.Debug("实时统计写入失败", zap.String("计数器", gaugeValidating), zap.Error(err))
+		}
+	}
+}
+
+// Snapshot 获取实时统计快照，Redis读取失败时计数均为0
+func (r *RealtimeStats) Snapshot() RealtimeSnapshot {
+	handout, failure := r.windows()
+	snapshot := RealtimeSnapshot{
+		HandoutWindow: handout.String(),
+		FailureWindow: failure.String(),
+		Goroutines:    runtime.NumGoroutine(),
+		UptimeSeconds: time.Since(r.startTime).Seconds(),
+	}
+
+	err := r.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		return r.readCounters(ctx, client, &snapshot, handout, failure)
+	})
+	if err != nil {
+		r.logger.Debug("实时统计读取失败", zap.Error(err))
+		return snapshot
+	}
+	snapshot.RedisAvailable = true
+	return snapshot
+}
+
+// readCounters 从Redis读取所有计数，全部成功时才写入快照
+func (r *RealtimeStats) readCounters(ctx context.Context, client *redis.Client, snapshot *RealtimeSnapshot, handout, failure time.Duration) error {
+	now := time.Now()
+	handedOut, err := r.sumBuckets(ctx, client, counterHandedOut, handout, now)
+	if err != nil {
+		return err
+	}
+	failures, err := r.sumBuckets(ctx, client, counterFailures, failure, now)
+	if err != nil {
+		return err
+	}
+	fetchFailures, err := r.sumBuckets(ctx, client, counterFetchFailure, failure, now)
+	if err != nil {
+		return err
+	}
+	// 只统计未过期的成员
+	inFlight, err := client.ZCount(ctx, r.redis.Key(realtimeKeyspace, gaugeValidating), strconv.FormatInt(now.UnixNano(), 10), "+inf").Result()
+	if err != nil {
+		return err
+	}
+
+	snapshot.HandedOut = handedOut
+	snapshot.Failures = failures
+	snapshot.FetchFailures = fetchFailures
+	snapshot.ValidationsInFlight = inFlight
+	return nil
+}
+
+// incr 对当前时间所在的桶加一，并设置过期时间为窗口长度加一个桶
+func (r *RealtimeStats) incr(name string, window time.Duration) {
+	key := r.bucketKey(name, time.Now())
+	err := r.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		pipe := client.Pipeline()
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, window+realtimeBucket)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		r.logger.Debug("实时统计写入失败", zap.String("计数器", name), zap.Error(err))
+	}
+}
+
+// sumBuckets 汇总窗口内所有桶的计数
+func (r *RealtimeStats) sumBuckets(ctx context.Context, client *redis.Client, name string, window time.Duration, now time.Time) (int64, error) {
+	buckets := int((window + realtimeBucket - 1) / realtimeBucket)
+	keys := make([]string, buckets)
+	for i := 0; i < buckets; i++ {
+		keys[i] = r.bucketKey(name, now.Add(-time.Duration(i)*realtimeBucket))
+	}
+
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// bucketKey 生成计数桶的键
+func (r *RealtimeStats) bucketKey(name string, t time.Time) string {
+	return r.redis.Key(realtimeKeyspace, name, strconv.FormatInt(t.Unix()/int64(realtimeBucket/time.Second), 10))
+}
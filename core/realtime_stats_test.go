@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidationsInFlightExpire(t *testing.T) {
+	pool, _ := newTestPool(t)
+	stats := pool.RealtimeStats()
+
+	done := stats.ValidationStarted()
+	stats.ValidationStarted()
+	if n := stats.Snapshot().ValidationsInFlight; n != 2 {
+		t.Fatalf("validations in flight = %d, want 2", n)
+	}
+	done()
+	done()
+	if n := stats.Snapshot().ValidationsInFlight; n != 1 {
+		t.Fatalf("validations in flight after one finished = %d, want 1", n)
+	}
+
+	// 未结束的验证（如进程异常退出）超过最长计数时间后不再计入
+	stats.validatingTTL = 20 * time.Millisecond
+	stats.ValidationStarted()
+	time.Sleep(50 * time.Millisecond)
+	if n := stats.Snapshot().ValidationsInFlight; n != 1 {
+		t.Errorf("validations in flight after short-lived entry expired = %d, want 1", n)
+	}
+}
+
+func TestRealtimeCountersSumWindow(t *testing.T) {
+	pool, mr := newTestPool(t)
+	stats := pool.RealtimeStats()
+
+	stats.RecordHandout()
+	stats.RecordHandout()
+	stats.RecordFailure()
+	stats.RecordFetchFailure()
+	// 两分钟前的桶在发放窗口(1分钟)之外，在失败窗口(5分钟)之内
+	old := time.Now().Add(-2 * time.Minute)
+	mr.Set(stats.bucketKey(counterHandedOut, old), "5")
+	mr.Set(stats.bucketKey(counterFailures, old), "3")
+
+	snapshot := stats.Snapshot()
+	if !snapshot.RedisAvailable || snapshot.HandedOut != 2 || snapshot.Failures != 4 || snapshot.FetchFailures != 1 {
+		t.Errorf("snapshot = %+v, want 2 handouts, 4 failures and 1 fetch failure", snapshot)
+	}
+	if snapshot.HandoutWindow != DefaultHandoutWindow.String() || snapshot.FailureWindow != DefaultFailureWindow.String() {
+		t.Errorf("windows = %s/%s, want defaults", snapshot.HandoutWindow, snapshot.FailureWindow)
+	}
+
+	stats.SetWindows(5*time.Minute, 0)
+	if n := stats.Snapshot().HandedOut; n != 7 {
+		t.Errorf("handouts in a 5m window = %d, want 7", n)
+	}
+
+	// Redis不可用时计数为0
+	mr.Close()
+	if snapshot := stats.Snapshot(); snapshot.RedisAvailable || snapshot.HandedOut != 0 {
+		t.Errorf("snapshot without redis = %+v, want zero counts", snapshot)
+	}
+}
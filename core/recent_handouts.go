@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	MaxExcludeRecentWindow = 10 * time.Minute // exclude_recent 的最大窗口，发放记录最多保留这么久
+	recentKeyspace         = "recent"
+)
+
+// RecentHandouts 记录每个客户端最近获取的代理，供 exclude_recent 排除
+// 每个客户端一个有序集合，成员为代理ID，分数为发放时间；Redis不可用时不排除任何代理
+type RecentHandouts struct {
+	redis  *RedisGuard
+	logger *zap.Logger
+}
+
+// NewRecentHandouts 创建最近发放记录
+func NewRecentHandouts(guard *RedisGuard, logger *zap.Logger) *RecentHandouts {
+	return &RecentHandouts{redis: guard, logger: logger}
+}
+
+// Record 记录一次发放
+func (r *RecentHandouts) Record(clientID string, proxyID uint) {
+	if clientID == "" {
+		return
+	}
+
+	now := time.Now()
+	key := r.redis.Key(recentKeyspace, clientID)
+	err := r.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		pipe := client.TxPipeline()
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: proxyID})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-MaxExcludeRecentWindow).UnixNano(), 10))
+		pipe.Expire(ctx, key, MaxExcludeRecentWindow)
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil && err != ErrRedisDegraded {
+		r.logger.Debug("记录代理发放失败",
+			zap.String("客户端", clientID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Recent 获取客户端在 window 内获取过的代理ID，window 最长为 MaxExcludeRecentWindow
+func (r *RecentHandouts) Recent(clientID string, window time.Duration) []uint {
+	if clientID == "" || window <= 0 {
+		return nil
+	}
+	if window > MaxExcludeRecentWindow {
+		window = MaxExcludeRecentWindow
+	}
+
+	var members []string
+	min := strconv.FormatInt(time.Now().Add(-window).UnixNano(), 10)
+	key := r.redis.Key(recentKeyspace, clientID)
+	err := r.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		var err error
+		members, err = client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+		return err
+	})
+	if err != nil {
+		if err != ErrRedisDegraded {
+			r.logger.Debug("查询最近发放的代理失败",
+				zap.String("客户端", clientID),
+				zap.Error(err),
+			)
+		}
+		return nil
+	}
+
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestExcludeIDsAppliesToEveryStrategy(t *testing.T) {
+	pool, _ := newTestPool(t)
+	a := newTestProxy(t, pool.DB(), "1.1.1.1")
+	b := newTestProxy(t, pool.DB(), "1.1.1.2")
+	c := newTestProxy(t, pool.DB(), "1.1.1.3")
+
+	strategies := []ScheduleStrategy{
+		StrategyWeighted, StrategyRoundRobin, StrategyLeastUsed, StrategyFailover,
+		StrategySiteAdaptive, StrategyPredictive, StrategyRandom, StrategyRoundRobinCached,
+	}
+	for _, strategy := range strategies {
+		for i := 0; i < 3; i++ {
+			task := &Task{ProxyType: models.ProxyTypeTemp, Strategy: strategy, ExcludeIDs: []uint{a.ID, b.ID}}
+			got, err := pool.GetProxyForTask(context.Background(), task)
+			if err != nil {
+				t.Fatalf("%s: GetProxyForTask: %v", strategy, err)
+			}
+			if got.ID != c.ID {
+				t.Errorf("%s: got proxy %d, want the only non-excluded proxy %d", strategy, got.ID, c.ID)
+			}
+			pool.releaseProxy(task, got.ID)
+		}
+	}
+
+	task := &Task{ProxyType: models.ProxyTypeTemp, ExcludeIDs: []uint{a.ID, b.ID, c.ID}}
+	var noProxy *NoProxyError
+	if _, err := pool.GetProxyForTask(context.Background(), task); !errors.As(err, &noProxy) {
+		t.Errorf("all excluded error = %v, want a NoProxyError", err)
+	}
+}
+
+func TestExcludeRecentPerClient(t *testing.T) {
+	pool, _ := newTestPool(t)
+	for _, ip := range []string{"1.1.1.1", "1.1.1.2"} {
+		newTestProxy(t, pool.DB(), ip)
+	}
+
+	get := func(client string, window time.Duration) (*models.Proxy, error) {
+		t.Helper()
+		task := &Task{ProxyType: models.ProxyTypeTemp, ClientID: client, ExcludeRecent: window}
+		proxy, err := pool.GetProxyForTask(context.Background(), task)
+		if err == nil {
+			pool.releaseProxy(task, proxy.ID)
+		}
+		return proxy, err
+	}
+
+	first, err := get("a", time.Minute)
+	if err != nil {
+		t.Fatalf("first: %v", err)
+	}
+	second, err := get("a", time.Minute)
+	if err != nil {
+		t.Fatalf("second: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Errorf("client a got proxy %d again within exclude_recent", first.ID)
+	}
+	// 两个代理都在窗口内获取过
+	if _, err := get("a", time.Minute); err == nil {
+		t.Error("client a got a proxy although every proxy was handed out recently")
+	}
+
+	// 其他客户端和不设置 exclude_recent 的请求不受影响
+	if _, err := get("b", time.Minute); err != nil {
+		t.Errorf("client b: %v", err)
+	}
+	if _, err := get("a", 0); err != nil {
+		t.Errorf("client a without exclude_recent: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MaxBodyLength 日志中记录的响应体最大字节数
+const MaxBodyLength = 512
+
+// mask 替换敏感值的占位符
+const mask = "***"
+
+// sensitiveParams 值需要隐藏的查询参数，不区分大小写
+var sensitiveParams = map[string]bool{
+	"secret_id":  true,
+	"secret_key": true,
+	"secret":     true,
+	"signature":  true,
+	"sign":       true,
+	"token":      true,
+	"key":        true,
+	"api_key":    true,
+	"apikey":     true,
+	"app_key":    true,
+	"password":   true,
+	"passwd":     true,
+}
+
+// URL 隐藏URL中的密钥、签名等查询参数和用户信息中的密码，用于记录日志
+// 其余部分保持原样，无法解析时不返回原URL
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[invalid url]"
+	}
+
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			name, _, found := strings.Cut(param, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+			if found && sensitiveParams[strings.ToLower(name)] {
+				params[i] = param[:strings.Index(param, "=")+1] + mask
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.Redacted()
+}
+
+// Error 隐藏错误链中 *url.Error 的URL，返回原错误
+// net/http 的请求错误会包含完整的请求URL，记录日志或返回给调用方前应先处理
+func Error(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = URL(urlErr.URL)
+	}
+	return err
+}
+
+// Body 截取响应体的前 MaxBodyLength 字节用于记录日志，超出时标明原长度
+func Body(body []byte) string {
+	if len(body) <= MaxBodyLength {
+		return string(body)
+	}
+	return fmt.Sprintf("%s...(truncated, %d bytes)", strings.ToValidUTF8(string(body[:MaxBodyLength]), ""), len(body))
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"proxy_pool/metrics"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRedisOpTimeout        = 200 * time.Millisecond // 单次Redis操作超时，避免Redis故障拖慢主流程
+	DefaultRedisFailureThreshold = 5                      // 连续失败多少次后进入降级模式
+	DefaultRedisProbeInterval    = 5 * time.Second        // 健康检查间隔
+)
+
+var ErrRedisDegraded = errors.New("redis degraded")
+
+// RedisStatus Redis健康状态
+type RedisStatus struct {
+	Available           bool       `json:"available"`                // 是否可用，降级时为false
+	ConsecutiveFailures int        `json:"consecutive_failures"`     // 连续失败次数
+	DegradedSince       *time.Time `json:"degraded_since,omitempty"` // 进入降级模式的时间
+}
+
+// RedisGuard Redis熔断器
+// 所有Redis操作通过 Do 执行并带有短超时；连续失败达到阈值后进入降级模式，
+// 降级期间 Do 直接返回 ErrRedisDegraded，调用方应绕过Redis；Run 定期检查，Ping 成功后自动恢复
+type RedisGuard struct {
+	client    *redis.Client
+	logger    *zap.Logger
+	opTimeout time.Duration
+
+	mu            sync.Mutex
+	keys          KeyBuilder
+	threshold     int
+	probeInterval time.Duration
+	failures      int
+	degraded      bool
+	degradedSince time.Time
+}
+
+// NewRedisGuard 创建Redis熔断器，client 为空时始终处于降级模式
+func NewRedisGuard(client *redis.Client, logger *zap.Logger) *RedisGuard {
+	g := &RedisGuard{
+		client:        client,
+		logger:        logger,
+		opTimeout:     DefaultRedisOpTimeout,
+		keys:          NewKeyBuilder(DefaultRedisKeyPrefix),
+		threshold:     DefaultRedisFailureThreshold,
+		probeInterval: DefaultRedisProbeInterval,
+	}
+	if client == nil {
+		g.degraded = true
+		g.degradedSince = time.Now()
+	}
+	metrics.RedisAvailable.Set(boolGauge(!g.degraded))
+	return g
+}
+
+// SetPolicy 设置降级阈值和健康检查间隔，非正值表示保持不变
+func (g *RedisGuard) SetPolicy(threshold int, probeInterval time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if threshold > 0 {
+		g.threshold = threshold
+	}
+	if probeInterval > 0 {
+		g.probeInterval = probeInterval
+	}
+}
+
+// SetKeyPrefix 设置Redis键前缀，为空时使用 DefaultRedisKeyPrefix，需在使用Redis之前调用
+func (g *RedisGuard) SetKeyPrefix(prefix string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.keys = NewKeyBuilder(prefix)
+}
+
+// Key 使用当前前缀生成Redis键
+func (g *RedisGuard) Key(parts ...string) string {
+	if g == nil {
+		return NewKeyBuilder("").Build(parts...)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.keys.Build(parts...)
+}
+
+// Available Redis是否可用
+func (g *RedisGuard) Available() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.degraded
+}
+
+// Status 获取Redis健康状态
+func (g *RedisGuard) Status() RedisStatus {
+	if g == nil {
+		return RedisStatus{}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := RedisStatus{
+		Available:           !g.degraded,
+		ConsecutiveFailures: g.failures,
+	}
+	if g.degraded {
+		since := g.degradedSince
+		status.DegradedSince = &since
+	}
+	return status
+}
+
+// Do 在超时时间内执行Redis操作，降级期间直接返回 ErrRedisDegraded
+// redis.Nil 视为成功，原样返回给调用方
+func (g *RedisGuard) Do(fn func(ctx context.Context, client *redis.Client) error) error {
+	if !g.Available() {
+		return ErrRedisDegraded
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.opTimeout)
+	defer cancel()
+
+	err := fn(ctx, g.client)
+	if err != nil && err != redis.Nil {
+		g.recordFailure(err)
+		return err
+	}
+	g.recordSuccess()
+	return err
+}
+
+// Run 定期检查Redis健康状态，直到 ctx 取消
+func (g *RedisGuard) Run(ctx context.Context) {
+	if g.client == nil {
+		return
+	}
+
+	g.mu.Lock()
+	interval := g.probeInterval
+	g.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.probe(ctx)
+		}
+	}
+}
+
+// probe 执行一次Ping，降级期间 Ping 成功即恢复
+func (g *RedisGuard) probe(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, g.opTimeout)
+	defer cancel()
+
+	if err := g.client.Ping(ctx).Err(); err != nil {
+		g.recordFailure(err)
+		return
+	}
+	g.recordSuccess()
+}
+
+// recordFailure 记录一次失败，达到阈值时进入降级模式
+func (g *RedisGuard) recordFailure(err error) {
+	metrics.RedisErrors.Inc()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.failures++
+	if g.degraded || g.failures < g.threshold {
+		return
+	}
+
+	g.degraded = true
+	g.degradedSince = time.Now()
+	metrics.RedisAvailable.Set(0)
+	g.logger.Warn("Redis连续失败，进入降级模式",
+		zap.Int("连续失败次数", g.failures),
+		zap.Error(err),
+	)
+}
+
+// recordSuccess 记录一次成功，降级模式下自动恢复
+func (g *RedisGuard) recordSuccess() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.failures = 0
+	if !g.degraded {
+		return
+	}
+
+	g.degraded = false
+	metrics.RedisAvailable.Set(1)
+	g.logger.Info("Redis已恢复，退出降级模式",
+		zap.Duration("降级时长", time.Since(g.degradedSince)),
+	)
+}
+
+// boolGauge 将布尔值转换为指标值
+func boolGauge(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+func TestRedisGuardDegradesAndRecovers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	guard := NewRedisGuard(client, zap.NewNop())
+	guard.SetPolicy(2, 0)
+
+	ping := func(ctx context.Context, client *redis.Client) error {
+		return client.Ping(ctx).Err()
+	}
+	if err := guard.Do(ping); err != nil || !guard.Available() {
+		t.Fatalf("Do with redis up = %v, available %v", err, guard.Available())
+	}
+
+	mr.Close()
+	for i := 0; i < 2; i++ {
+		if err := guard.Do(ping); err == nil {
+			t.Fatal("Do with redis down succeeded")
+		}
+	}
+	status := guard.Status()
+	if status.Available || status.ConsecutiveFailures != 2 || status.DegradedSince == nil {
+		t.Fatalf("status after failures = %+v, want degraded", status)
+	}
+	// 降级期间不再访问Redis
+	called := false
+	err := guard.Do(func(ctx context.Context, client *redis.Client) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrRedisDegraded) || called {
+		t.Errorf("Do while degraded = %v (called %v), want %v without calling redis", err, called, ErrRedisDegraded)
+	}
+
+	// 健康检查 Ping 成功后恢复
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("restart redis: %v", err)
+	}
+	guard.probe(context.Background())
+	if status := guard.Status(); !status.Available || status.ConsecutiveFailures != 0 {
+		t.Errorf("status after probe = %+v, want available", status)
+	}
+
+	if NewRedisGuard(nil, zap.NewNop()).Available() {
+		t.Error("guard without a client is available, want degraded")
+	}
+}
+
+func TestGetProxyWorksWhileRedisDegraded(t *testing.T) {
+	pool, mr := newTestPool(t)
+	want := newTestProxy(t, pool.DB(), "1.1.1.1")
+	mr.Close()
+
+	for i := 0; i < DefaultRedisFailureThreshold+2; i++ {
+		proxy, err := pool.GetProxyForTask(context.Background(), &Task{Strategy: StrategyWeighted})
+		if err != nil {
+			t.Fatalf("GetProxyForTask without redis (attempt %d): %v", i, err)
+		}
+		if proxy.ID != want.ID {
+			t.Fatalf("scheduled proxy %d, want %d", proxy.ID, want.ID)
+		}
+		pool.ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+	}
+	if pool.RedisGuard().Available() {
+		t.Error("redis guard still available after repeated failures")
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import "strings"
+
+// DefaultRedisKeyPrefix 默认的Redis键前缀
+const DefaultRedisKeyPrefix = "proxy_pool"
+
+// KeyBuilder Redis键生成器，键格式为 {prefix}:{part1}:{part2}
+// 多个部署共用同一个Redis时各自使用不同的前缀，避免键冲突
+type KeyBuilder struct {
+	prefix string
+}
+
+// NewKeyBuilder 创建Redis键生成器，prefix 为空时使用 DefaultRedisKeyPrefix
+func NewKeyBuilder(prefix string) KeyBuilder {
+	prefix = strings.TrimRight(prefix, ":")
+	if prefix == "" {
+		prefix = DefaultRedisKeyPrefix
+	}
+	return KeyBuilder{prefix: prefix}
+}
+
+// Prefix 键前缀
+func (b KeyBuilder) Prefix() string {
+	if b.prefix == "" {
+		return DefaultRedisKeyPrefix
+	}
+	return b.prefix
+}
+
+// Build 生成键，如 Build("realtime", "handed_out") 返回 proxy_pool:realtime:handed_out
+func (b KeyBuilder) Build(parts ...string) string {
+	return b.Prefix() + ":" + strings.Join(parts, ":")
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", "proxy_pool:realtime:handed_out"},
+		{"staging", "staging:realtime:handed_out"},
+		{"staging:", "staging:realtime:handed_out"},
+		{":", "proxy_pool:realtime:handed_out"},
+	}
+	for _, tt := range tests {
+		if got := NewKeyBuilder(tt.prefix).Build("realtime", "handed_out"); got != tt.want {
+			t.Errorf("prefix %q: Build = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+	if got := (KeyBuilder{}).Build("a"); got != "proxy_pool:a" {
+		t.Errorf("zero KeyBuilder Build = %q, want the default prefix", got)
+	}
+}
+
+func TestRedisKeyPrefixSeparatesDeployments(t *testing.T) {
+	pool, mr := newTestPool(t)
+	pool.RedisGuard().SetKeyPrefix("blue")
+
+	// 第二个部署与第一个共用同一个Redis
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	other := NewProxyPool(newTestDB(t), client, zap.NewNop())
+	other.RedisGuard().SetKeyPrefix("green")
+
+	pool.RealtimeStats().RecordHandout()
+	pool.RealtimeStats().RecordHandout()
+	other.RealtimeStats().RecordHandout()
+	pool.recent.Record("client", 1)
+	pool.DecisionLog().Record(1, "", "")
+	pool.DecisionLog().Flush()
+
+	if n := pool.RealtimeStats().Snapshot().HandedOut; n != 2 {
+		t.Errorf("blue handed out = %d, want 2", n)
+	}
+	if n := other.RealtimeStats().Snapshot().HandedOut; n != 1 {
+		t.Errorf("green handed out = %d, want 1", n)
+	}
+	if ids := other.recent.Recent("client", time.Minute); len(ids) != 0 {
+		t.Errorf("green sees blue's recent handouts %v", ids)
+	}
+
+	// 所有键都带有各自的前缀
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "blue:") && !strings.HasPrefix(key, "green:") {
+			t.Errorf("key %q has no deployment prefix", key)
+		}
+	}
+	for _, key := range []string{"blue:recent:client", "blue:scheduler:decisions"} {
+		if !mr.Exists(key) {
+			t.Errorf("key %q missing, keys = %v", key, mr.Keys())
+		}
+	}
+}
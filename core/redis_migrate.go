@@ -0,0 +1,320 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"proxy_pool/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// MigrateFormat 旧代理池在Redis中保存代理列表的数据结构
+type MigrateFormat string
+
+const (
+	MigrateFormatSet  MigrateFormat = "set"  // SET，成员为 ip:port
+	MigrateFormatZSet MigrateFormat = "zset" // ZSET，成员为 ip:port，分数作为代理评分
+)
+
+// migrateSource 从Redis迁移的代理的来源
+const migrateSource = "redis_migrate"
+
+// ErrInvalidMigrateFormat 不支持的迁移数据结构
+var ErrInvalidMigrateFormat = errors.New("invalid migrate format")
+
+// IsValid 是否为支持的数据结构
+func (f MigrateFormat) IsValid() bool {
+	return f == MigrateFormatSet || f == MigrateFormatZSet
+}
+
+// migrateEntry Redis中的一个代理
+type migrateEntry struct {
+	member string
+	score  float64
+	scored bool // 是否带有旧代理池的分数，SET 的成员没有分数
+}
+
+// migrateScanCount 每次 SSCAN/ZSCAN 请求的成员数
+const migrateScanCount = 100
+
+// ErrMigrationRunning 已有Redis迁移在后台执行
+var ErrMigrationRunning = errors.New("redis migration already running")
+
+// MigrateStatus 后台Redis迁移的进度
+type MigrateStatus struct {
+	Running    bool          `json:"running"`     // 是否正在迁移
+	Key        string        `json:"key"`         // 迁移的Redis键
+	Format     MigrateFormat `json:"format"`      // 迁移的数据结构
+	Scanned    int           `json:"scanned"`     // 已读取的成员数
+	Migrated   int           `json:"migrated"`    // 迁移成功数
+	StartedAt  time.Time     `json:"started_at"`  // 开始时间
+	FinishedAt time.Time     `json:"finished_at"` // 结束时间，进行中时为零值
+	LastError  string        `json:"last_error"`  // 中止或取消的原因
+}
+
+// redisMigration 后台执行的Redis迁移，同时只有一个
+type redisMigration struct {
+	mu     sync.Mutex
+	status MigrateStatus
+	cancel context.CancelFunc
+}
+
+// StartRedisMigration 在后台执行 MigrateFromRedis，立即返回当前进度
+// 已有迁移在执行时返回 ErrMigrationRunning 和该迁移的进度，进度通过 RedisMigrationStatus 查询
+func (p *ProxyPool) StartRedisMigration(redisKey string, format MigrateFormat) (MigrateStatus, error) {
+	if !format.IsValid() {
+		return MigrateStatus{}, fmt.Errorf("%w: %q", ErrInvalidMigrateFormat, format)
+	}
+
+	m := &p.migration
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status.Running {
+		return m.status, ErrMigrationRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.status = MigrateStatus{Running: true, Key: redisKey, Format: format, StartedAt: time.Now()}
+
+	go func() {
+		defer cancel()
+		_, err := p.migrateFromRedis(ctx, redisKey, format, func(scanned, migrated int) {
+			m.mu.Lock()
+			m.status.Scanned += scanned
+			m.status.Migrated += migrated
+			m.mu.Unlock()
+		})
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.status.Running = false
+		m.status.FinishedAt = time.Now()
+		if err != nil {
+			m.status.LastError = err.Error()
+		}
+		m.cancel = nil
+	}()
+	return m.status, nil
+}
+
+// CancelRedisMigration 取消后台执行的Redis迁移，已迁移的代理保留，返回当前进度
+// 取消后正在验证的代理仍会完成，进度中的 Running 在其完成后变为 false
+func (p *ProxyPool) CancelRedisMigration() MigrateStatus {
+	m := &p.migration
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	return m.status
+}
+
+// RedisMigrationStatus 获取最近一次后台Redis迁移的进度，从未迁移时为零值
+func (p *ProxyPool) RedisMigrationStatus() MigrateStatus {
+	p.migration.mu.Lock()
+	defer p.migration.mu.Unlock()
+	return p.migration.status
+}
+
+// MigrateFromRedis 从旧代理池的Redis SET 或 ZSET 中导入代理，返回迁移成功的数量
+// 用 SSCAN/ZSCAN 分批读取，成员格式为 ip:port，按 http 临时代理入库；格式错误、已存在或验证不通过的代理跳过，
+// 验证并发数与验证器相同；ctx 取消后不再读取和验证剩余的代理，返回已迁移的数量和 ctx 的错误
+func (p *ProxyPool) MigrateFromRedis(ctx context.Context, redisKey string, format MigrateFormat) (int, error) {
+	if !format.IsValid() {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMigrateFormat, format)
+	}
+	return p.migrateFromRedis(ctx, redisKey, format, nil)
+}
+
+// migrateFromRedis 执行迁移，progress 不为空时每读取一批成员和每迁移一个代理后调用，参数为新增的数量
+func (p *ProxyPool) migrateFromRedis(ctx context.Context, redisKey string, format MigrateFormat, progress func(scanned, migrated int)) (int, error) {
+	if progress == nil {
+		progress = func(int, int) {}
+	}
+	p.logger.Info("开始从Redis迁移代理",
+		zap.String("键", redisKey),
+		zap.String("数据结构", string(format)),
+	)
+
+	validator := p.Validator()
+	jobs := make(chan migrateEntry)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		migrated int
+	)
+	workers := validator.maxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				if p.migrateEntry(validator, entry) {
+					mu.Lock()
+					migrated++
+					mu.Unlock()
+					progress(0, 1)
+				}
+			}
+		}()
+	}
+
+	scanned, err := p.scanMigrateEntries(ctx, redisKey, format, func(batch []migrateEntry) bool {
+		progress(len(batch), 0)
+		for _, entry := range batch {
+			select {
+			case jobs <- entry:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	})
+	close(jobs)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	p.logger.Info("Redis代理迁移完成",
+		zap.String("键", redisKey),
+		zap.Int("读取数量", scanned),
+		zap.Int("迁移数量", migrated),
+		zap.Error(err),
+	)
+	return migrated, err
+}
+
+// scanMigrateEntries 用 SSCAN 或 ZSCAN 分批读取成员并交给 handle，handle 返回 false 或 ctx 取消时停止，返回读取的成员数
+// 键不存在时没有成员；扫描期间集合被修改时成员可能重复，重复的代理在入库前按已存在跳过
+func (p *ProxyPool) scanMigrateEntries(ctx context.Context, redisKey string, format MigrateFormat, handle func([]migrateEntry) bool) (int, error) {
+	var (
+		cursor  uint64
+		scanned int
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return scanned, err
+		}
+
+		var batch []migrateEntry
+		err := p.redisGuard.Do(func(rctx context.Context, client *redis.Client) error {
+			var (
+				keys []string
+				err  error
+			)
+			if format == MigrateFormatZSet {
+				keys, cursor, err = client.ZScan(rctx, redisKey, cursor, "", migrateScanCount).Result()
+			} else {
+				keys, cursor, err = client.SScan(rctx, redisKey, cursor, "", migrateScanCount).Result()
+			}
+			if err != nil {
+				return err
+			}
+			batch = parseScanEntries(keys, format)
+			return nil
+		})
+		if err != nil {
+			return scanned, fmt.Errorf("scan %s %q: %w", format, redisKey, err)
+		}
+
+		scanned += len(batch)
+		if len(batch) > 0 && !handle(batch) {
+			return scanned, ctx.Err()
+		}
+		if cursor == 0 {
+			return scanned, nil
+		}
+	}
+}
+
+// parseScanEntries 解析一批扫描结果，ZSCAN 的结果为成员和分数交替排列
+func parseScanEntries(keys []string, format MigrateFormat) []migrateEntry {
+	if format != MigrateFormatZSet {
+		entries := make([]migrateEntry, len(keys))
+		for i, member := range keys {
+			entries[i] = migrateEntry{member: member}
+		}
+		return entries
+	}
+
+	entries := make([]migrateEntry, 0, len(keys)/2)
+	for i := 0; i+1 < len(keys); i += 2 {
+		score, err := strconv.ParseFloat(keys[i+1], 64)
+		entries = append(entries, migrateEntry{member: keys[i], score: score, scored: err == nil})
+	}
+	return entries
+}
+
+// migrateEntry 解析、验证并添加一个代理，返回是否添加成功
+func (p *ProxyPool) migrateEntry(validator *ProxyValidator, entry migrateEntry) bool {
+	host, rawPort, err := net.SplitHostPort(entry.member)
+	var port int
+	if err == nil {
+		port, err = strconv.Atoi(rawPort)
+	}
+	if err != nil {
+		p.logger.Debug("代理格式错误，跳过迁移", zap.String("成员", entry.member), zap.Error(err))
+		return false
+	}
+
+	proxy := &models.Proxy{
+		IP:        host,
+		Port:      port,
+		Protocol:  "http",
+		Type:      models.ProxyTypeTemp,
+		Region:    models.ProxyRegionOther,
+		Source:    migrateSource,
+		Available: true,
+	}
+	if err := proxy.Normalize(); err != nil {
+		p.logger.Debug("代理地址无效，跳过迁移", zap.String("成员", entry.member), zap.Error(err))
+		return false
+	}
+	if exists, err := models.IsProxyExists(p.db, proxy.IP, proxy.Port); err != nil || exists {
+		return false
+	}
+	if err := validator.Probe(proxy); err != nil {
+		p.logger.Debug("代理验证失败，跳过迁移", zap.String("成员", entry.member), zap.Error(err))
+		return false
+	}
+	entry.applyScore(proxy)
+
+	if _, created, err := p.AddProxy(proxy); err != nil || !created {
+		p.logger.Debug("代理添加失败，跳过迁移", zap.String("成员", entry.member), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// applyScore 设置验证通过的代理入库时的评分
+// ZSET 保留旧代理池的分数；SET 没有分数，按本次验证通过计一次成功并计算评分，避免以0分入库后被优化任务清理
+func (e migrateEntry) applyScore(proxy *models.Proxy) {
+	if e.scored {
+		proxy.Score = clampScore(e.score)
+		return
+	}
+	proxy.Success = 1
+	proxy.UpdateScore()
+}
+
+// clampScore 将旧代理池的分数限制在评分范围 [0, 100] 内
+func clampScore(score float64) float64 {
+	switch {
+	case score < 0:
+		return 0
+	case score > 100:
+		return 100
+	}
+	return score
+}
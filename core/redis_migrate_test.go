@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestScanMigrateEntries(t *testing.T) {
+	pool, mr := newTestPool(t)
+	for i := 0; i < 250; i++ {
+		mr.SAdd("legacy", fmt.Sprintf("bad-member-%d", i))
+	}
+	mr.ZAdd("legacy_z", 42.5, "1.1.1.1:80")
+	mr.ZAdd("legacy_z", 150, "2.2.2.2:80")
+
+	// SSCAN 分批读取全部成员
+	seen := map[string]bool{}
+	batches := 0
+	scanned, err := pool.scanMigrateEntries(context.Background(), "legacy", MigrateFormatSet, func(batch []migrateEntry) bool {
+		batches++
+		for _, e := range batch {
+			if e.scored {
+				t.Errorf("set member %q has a score", e.member)
+			}
+			seen[e.member] = true
+		}
+		return true
+	})
+	if err != nil || scanned != 250 || len(seen) != 250 {
+		t.Fatalf("scan set = %d scanned, %d unique, %v, want 250", scanned, len(seen), err)
+	}
+	if batches < 2 {
+		t.Errorf("scan set took %d batches, want more than one", batches)
+	}
+
+	// ZSCAN 带上分数
+	scores := map[string]float64{}
+	if _, err := pool.scanMigrateEntries(context.Background(), "legacy_z", MigrateFormatZSet, func(batch []migrateEntry) bool {
+		for _, e := range batch {
+			if !e.scored {
+				t.Errorf("zset member %q has no score", e.member)
+			}
+			scores[e.member] = e.score
+		}
+		return true
+	}); err != nil {
+		t.Fatalf("scan zset: %v", err)
+	}
+	if scores["1.1.1.1:80"] != 42.5 || scores["2.2.2.2:80"] != 150 {
+		t.Errorf("zset scores = %v", scores)
+	}
+
+	// 取消后停止读取，返回已读取的数量
+	ctx, cancel := context.WithCancel(context.Background())
+	scanned, err = pool.scanMigrateEntries(ctx, "legacy", MigrateFormatSet, func([]migrateEntry) bool {
+		cancel()
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || scanned == 0 || scanned >= 250 {
+		t.Errorf("cancelled scan = %d, %v, want a partial count and %v", scanned, err, context.Canceled)
+	}
+
+	// 键不存在时没有成员
+	if scanned, err := pool.scanMigrateEntries(context.Background(), "missing", MigrateFormatSet, func([]migrateEntry) bool { return true }); err != nil || scanned != 0 {
+		t.Errorf("scan missing key = %d, %v, want 0, nil", scanned, err)
+	}
+}
+
+func TestMigrateEntryScore(t *testing.T) {
+	scored := &models.Proxy{Speed: 100}
+	migrateEntry{score: 150, scored: true}.applyScore(scored)
+	if scored.Score != 100 {
+		t.Errorf("zset score 150 = %v, want clamped to 100", scored.Score)
+	}
+
+	// SET 成员没有分数，按验证通过计算而不是0分
+	unscored := &models.Proxy{Speed: 100}
+	migrateEntry{}.applyScore(unscored)
+	if unscored.Score <= 0 || unscored.Success != 1 {
+		t.Errorf("set member score = %v, success = %d, want a positive score from one success", unscored.Score, unscored.Success)
+	}
+}
+
+func TestStartRedisMigration(t *testing.T) {
+	pool, mr := newTestPool(t)
+	// 格式错误和回环地址在验证前跳过，不访问网络
+	for i := 0; i < 120; i++ {
+		mr.SAdd("legacy", fmt.Sprintf("bad-member-%d", i))
+	}
+	mr.SAdd("legacy", "127.0.0.1:8080")
+
+	if _, err := pool.StartRedisMigration("legacy", "list"); !errors.Is(err, ErrInvalidMigrateFormat) {
+		t.Fatalf("StartRedisMigration(list) error = %v, want %v", err, ErrInvalidMigrateFormat)
+	}
+
+	status, err := pool.StartRedisMigration("legacy", MigrateFormatSet)
+	if err != nil {
+		t.Fatalf("StartRedisMigration: %v", err)
+	}
+	if !status.Running || status.Key != "legacy" {
+		t.Fatalf("start status = %+v, want running for legacy", status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status = pool.RedisMigrationStatus(); status.Running && time.Now().Before(deadline); status = pool.RedisMigrationStatus() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status.Running {
+		t.Fatal("migration still running after 5s")
+	}
+	if status.Scanned != 121 || status.Migrated != 0 || status.LastError != "" || status.FinishedAt.IsZero() {
+		t.Errorf("finished status = %+v, want 121 scanned, 0 migrated, no error", status)
+	}
+
+	// 同时只能有一个迁移
+	pool.migration.mu.Lock()
+	pool.migration.status.Running = true
+	pool.migration.mu.Unlock()
+	if _, err := pool.StartRedisMigration("legacy", MigrateFormatSet); !errors.Is(err, ErrMigrationRunning) {
+		t.Errorf("second StartRedisMigration error = %v, want %v", err, ErrMigrationRunning)
+	}
+}
+
+func TestMigrateFromRedisCancelled(t *testing.T) {
+	pool, mr := newTestPool(t)
+	mr.SAdd("legacy", "bad-member")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if migrated, err := pool.MigrateFromRedis(ctx, "legacy", MigrateFormatSet); !errors.Is(err, context.Canceled) || migrated != 0 {
+		t.Errorf("MigrateFromRedis with cancelled ctx = %d, %v, want 0, %v", migrated, err, context.Canceled)
+	}
+}
@@ -0,0 +1,407 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"proxy_pool/models"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// DefaultValidatorTimeout 默认单个代理验证超时时间
+const DefaultValidatorTimeout = 5 * time.Second
+
+var (
+	// ErrRestartRequired 修改的是只能重启后生效的结构性配置
+	ErrRestartRequired = errors.New("config field requires restart")
+	// ErrUnknownConfigField 不是运行时可修改的配置项
+	ErrUnknownConfigField = errors.New("unknown runtime config field")
+	// ErrInvalidRuntimeConfig 运行时配置的值不合法
+	ErrInvalidRuntimeConfig = errors.New("invalid runtime config")
+)
+
+// restartOnlyFields 只能重启后生效的配置项，运行时修改返回 ErrRestartRequired
+var restartOnlyFields = map[string]bool{
+	"db_driver":          true,
+	"db_dsn":             true,
+	"db_max_open_conns":  true,
+	"db_max_idle_conns":  true,
+	"listen_addrs":       true,
+	"tls_enabled":        true,
+	"tls_cert_file":      true,
+	"tls_key_file":       true,
+	"http_redirect_addr": true,
+	"redis_key_prefix":   true,
+	"api_key":            true,
+	"enable_pprof":       true,
+}
+
+// ScoringWeights 调度评分中成功率、速度和使用次数分数的权重
+type ScoringWeights struct {
+	SuccessRate float64 `json:"success_rate"`
+	Speed       float64 `json:"speed"`
+	Usage       float64 `json:"usage"`
+}
+
+// DefaultScoringWeights 默认调度评分权重，成功率占60%，速度占30%，使用次数占10%
+var DefaultScoringWeights = ScoringWeights{SuccessRate: 0.6, Speed: 0.3, Usage: 0.1}
+
+// FailPolicy 代理验证失败多少次后清理，可按代理类型设置
+// 代理源的清理策略配置了最大失败次数时优先使用清理策略的配置
+type FailPolicy struct {
+	MaxFailCount     int                      `json:"max_fail_count"`      // 默认最大失败次数，超过后删除代理
+	TypeMaxFailCount map[models.ProxyType]int `json:"type_max_fail_count"` // 各代理类型的最大失败次数，未配置或为0的类型使用默认值
+}
+
+// MaxFailCountFor 获取代理类型的最大失败次数
+func (p FailPolicy) MaxFailCountFor(proxyType models.ProxyType) int {
+	if n := p.TypeMaxFailCount[proxyType]; n > 0 {
+		return n
+	}
+	return p.MaxFailCount
+}
+
+// MaxFailCountForProxy 获取代理实际使用的最大失败次数，代理源清理策略的配置优先于按类型的配置
+func (p FailPolicy) MaxFailCountForProxy(proxy *models.Proxy) int {
+	if n := models.CleanupPolicyFor(proxy.Source).MaxFailCount; n > 0 {
+		return n
+	}
+	return p.MaxFailCountFor(proxy.Type)
+}
+
+// clone 复制失败策略，不与原策略共用按类型的配置
+func (p FailPolicy) clone() FailPolicy {
+	if p.TypeMaxFailCount != nil {
+		types := make(map[models.ProxyType]int, len(p.TypeMaxFailCount))
+		for t, n := range p.TypeMaxFailCount {
+			types[t] = n
+		}
+		p.TypeMaxFailCount = types
+	}
+	return p
+}
+
+// validate 检查失败策略，按类型排序以便错误信息顺序稳定
+func (p FailPolicy) validate() []error {
+	var errs []error
+	if p.MaxFailCount <= 0 {
+		errs = append(errs, fmt.Errorf("max_fail_count: must be positive, got %d", p.MaxFailCount))
+	}
+	types := make([]string, 0, len(p.TypeMaxFailCount))
+	for t := range p.TypeMaxFailCount {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if !models.ProxyType(t).IsValid() {
+			errs = append(errs, fmt.Errorf("type_max_fail_count: unknown proxy type %q", t))
+		}
+		if n := p.TypeMaxFailCount[models.ProxyType(t)]; n < 0 {
+			errs = append(errs, fmt.Errorf("type_max_fail_count[%s]: must not be negative, got %d", t, n))
+		}
+	}
+	return errs
+}
+
+// RuntimeConfig 运行时可修改的配置，修改后对之后的操作生效，不需要重启
+// Get 返回的配置与当前快照共用按类型、按调度策略的配置，不应修改
+type RuntimeConfig struct {
+	FailPolicy                                   // 最大失败次数，JSON中展开为 max_fail_count 和 type_max_fail_count
+	ValidatorTimeoutSeconds float64              `json:"validator_timeout_seconds"` // 单个代理验证超时时间(秒)
+	ScoringWeights          ScoringWeights       `json:"scoring_weights"`           // 调度评分权重
+	Scheduler               SchedulerConfig      `json:"scheduler"`                 // 调度器失败阈值和冷却时间
+	RetentionMode           models.RetentionMode `json:"retention_mode"`            // 保留模式：delete、quarantine 或 observe，observe 下验证和清理任务不删除代理
+
+	// 维护阈值
+	MinProxies             int     `json:"min_proxies"`               // 可用代理少于该值时补充获取
+	MinScore               float64 `json:"min_score"`                 // 优化时删除评分低于该值的代理
+	MinSuccessRate         float64 `json:"min_success_rate"`          // 优化时删除成功率(百分比)低于该值的代理
+	HighScoreThreshold     float64 `json:"high_score_threshold"`      // 评分不低于该值的代理提高最大并发数
+	HighScoreMaxConcurrent int     `json:"high_score_max_concurrent"` // 高评分代理的最大并发数
+}
+
+// DefaultRuntimeConfig 默认运行时配置
+func DefaultRuntimeConfig() RuntimeConfig {
+	maintenance := models.DefaultMaintenanceConfig
+	return RuntimeConfig{
+		FailPolicy:              FailPolicy{MaxFailCount: 3},
+		ValidatorTimeoutSeconds: DefaultValidatorTimeout.Seconds(),
+		ScoringWeights:          DefaultScoringWeights,
+		Scheduler:               DefaultSchedulerConfig(),
+		RetentionMode:           models.RetentionDelete,
+
+		MinProxies:             maintenance.MinProxies,
+		MinScore:               maintenance.MinScore,
+		MinSuccessRate:         maintenance.MinSuccessRate,
+		HighScoreThreshold:     maintenance.HighScoreThreshold,
+		HighScoreMaxConcurrent: maintenance.HighScoreMaxConcurrent,
+	}
+}
+
+// RuntimeConfig 启动时的运行时配置，未配置的项使用默认值
+func (c *Config) RuntimeConfig() RuntimeConfig {
+	runtime := DefaultRuntimeConfig()
+	if c.MaxFailCount > 0 {
+		runtime.MaxFailCount = c.MaxFailCount
+	}
+	runtime.TypeMaxFailCount = FailPolicy{TypeMaxFailCount: c.TypeMaxFailCount}.clone().TypeMaxFailCount
+	runtime.Scheduler = c.Scheduler.withDefaults()
+	if c.RetentionMode != "" {
+		runtime.RetentionMode = c.RetentionMode
+	}
+	maintenance := c.MaintenanceConfig()
+	runtime.MinProxies = maintenance.MinProxies
+	runtime.HighScoreThreshold = maintenance.HighScoreThreshold
+	runtime.HighScoreMaxConcurrent = maintenance.HighScoreMaxConcurrent
+	return runtime
+}
+
+// Validate 检查运行时配置，返回的错误包装 ErrInvalidRuntimeConfig
+func (c RuntimeConfig) Validate() error {
+	errs := c.FailPolicy.validate()
+	errs = append(errs, c.Scheduler.validate()...)
+	if !c.RetentionMode.IsValid() {
+		errs = append(errs, fmt.Errorf("retention_mode: must be one of delete, quarantine, observe, got %q", c.RetentionMode))
+	}
+	if c.ValidatorTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("validator_timeout_seconds: must be positive, got %v", c.ValidatorTimeoutSeconds))
+	}
+	w := c.ScoringWeights
+	if w.SuccessRate < 0 || w.Speed < 0 || w.Usage < 0 || w.SuccessRate+w.Speed+w.Usage <= 0 {
+		errs = append(errs, fmt.Errorf("scoring_weights: must be non-negative and not all zero, got %+v", w))
+	}
+	if c.MinProxies < 0 {
+		errs = append(errs, fmt.Errorf("min_proxies: must not be negative, got %d", c.MinProxies))
+	}
+	if c.MinScore < 0 || c.MinScore > 100 {
+		errs = append(errs, fmt.Errorf("min_score: must be within [0, 100], got %v", c.MinScore))
+	}
+	if c.MinSuccessRate < 0 || c.MinSuccessRate > 100 {
+		errs = append(errs, fmt.Errorf("min_success_rate: must be within [0, 100], got %v", c.MinSuccessRate))
+	}
+	if c.HighScoreThreshold < 0 || c.HighScoreThreshold > 100 {
+		errs = append(errs, fmt.Errorf("high_score_threshold: must be within [0, 100], got %v", c.HighScoreThreshold))
+	}
+	if c.HighScoreMaxConcurrent <= 0 {
+		errs = append(errs, fmt.Errorf("high_score_max_concurrent: must be positive, got %d", c.HighScoreMaxConcurrent))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidRuntimeConfig, errors.Join(errs...))
+	}
+	return nil
+}
+
+// ValidatorTimeout 单个代理验证超时时间
+func (c RuntimeConfig) ValidatorTimeout() time.Duration {
+	return time.Duration(c.ValidatorTimeoutSeconds * float64(time.Second))
+}
+
+// clone 复制运行时配置，不与原配置共用按类型、按调度策略的配置
+func (c RuntimeConfig) clone() RuntimeConfig {
+	c.FailPolicy = c.FailPolicy.clone()
+	c.Scheduler = c.Scheduler.clone()
+	return c
+}
+
+// MaintenanceConfig 代理池优化使用的维护配置，其他项使用默认值
+func (c RuntimeConfig) MaintenanceConfig() *models.MaintenanceConfig {
+	config := *models.DefaultMaintenanceConfig
+	config.MinProxies = c.MinProxies
+	config.MinScore = c.MinScore
+	config.MinSuccessRate = c.MinSuccessRate
+	config.HighScoreThreshold = c.HighScoreThreshold
+	config.HighScoreMaxConcurrent = c.HighScoreMaxConcurrent
+	return &config
+}
+
+// RuntimeConfigStore 保存当前运行时配置的快照
+// 修改时整体替换快照，各组件每次使用时通过访问方法读取，修改对之后的操作生效
+type RuntimeConfigStore struct {
+	logger  *zap.Logger
+	current atomic.Pointer[RuntimeConfig]
+	mu      sync.Mutex // 串行化修改
+}
+
+// NewRuntimeConfigStore 创建运行时配置，初始值为默认配置
+func NewRuntimeConfigStore(logger *zap.Logger) *RuntimeConfigStore {
+	s := &RuntimeConfigStore{logger: logger}
+	initial := DefaultRuntimeConfig()
+	s.current.Store(&initial)
+	return s
+}
+
+// Get 获取当前运行时配置的副本
+func (s *RuntimeConfigStore) Get() RuntimeConfig {
+	return *s.current.Load()
+}
+
+// Set 检查并整体替换运行时配置
+func (s *RuntimeConfigStore) Set(config RuntimeConfig) error {
+	config = config.clone()
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(&config)
+	return nil
+}
+
+// store 替换当前配置，保留模式同步到清理逻辑，调用方需持有 s.mu
+func (s *RuntimeConfigStore) store(config *RuntimeConfig) {
+	s.current.Store(config)
+	models.SetRetentionMode(config.RetentionMode)
+}
+
+// modify 在当前配置上修改并替换，修改后的配置不合法时保持不变
+func (s *RuntimeConfigStore) modify(fn func(*RuntimeConfig)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.current.Load().clone()
+	fn(&next)
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	s.store(&next)
+	return nil
+}
+
+// Update 用JSON对象修改运行时配置，未传入的配置项保持不变，返回修改后的配置
+// 按类型的最大失败次数只修改传入的类型，设为0时恢复使用默认值；按调度策略的调度器设置只替换传入的策略；包含结构性配置项时返回 ErrRestartRequired，包含其他未知配置项时返回 ErrUnknownConfigField，均不做任何修改
+func (s *RuntimeConfigStore) Update(patch []byte) (RuntimeConfig, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return RuntimeConfig{}, fmt.Errorf("%w: %v", ErrInvalidRuntimeConfig, err)
+	}
+	if err := checkRuntimeFields(fields); err != nil {
+		return RuntimeConfig{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := *s.current.Load()
+	next := previous.clone()
+	decoder := json.NewDecoder(bytes.NewReader(patch))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&next); err != nil {
+		return previous, fmt.Errorf("%w: %v", ErrInvalidRuntimeConfig, err)
+	}
+	if err := next.Validate(); err != nil {
+		return previous, err
+	}
+	s.store(&next)
+
+	s.logger.Info("更新运行时配置",
+		zap.Any("原配置", previous),
+		zap.Any("新配置", next),
+	)
+	return next, nil
+}
+
+// checkRuntimeFields 检查修改的配置项是否都可以在运行时修改
+func checkRuntimeFields(fields map[string]json.RawMessage) error {
+	var restart, unknown []string
+	known := runtimeConfigFields()
+	for name := range fields {
+		switch {
+		case restartOnlyFields[name]:
+			restart = append(restart, name)
+		case !known[name]:
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(restart)
+	sort.Strings(unknown)
+
+	if len(restart) > 0 {
+		return fmt.Errorf("%w: %v", ErrRestartRequired, restart)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %v", ErrUnknownConfigField, unknown)
+	}
+	return nil
+}
+
+// runtimeConfigFields 运行时配置的JSON字段名
+func runtimeConfigFields() map[string]bool {
+	data, _ := json.Marshal(RuntimeConfig{})
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+
+	known := make(map[string]bool, len(fields))
+	for name := range fields {
+		known[name] = true
+	}
+	return known
+}
+
+// LoadFile 读取JSON文件修改运行时配置，文件中只需包含要修改的配置项
+func (s *RuntimeConfigStore) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = s.Update(data)
+	return err
+}
+
+// WatchFile 读取配置文件并监听其变化，文件被写入或替换后重新读取，直到 ctx 结束
+// 重新读取失败时保持当前配置并记录错误
+func (s *RuntimeConfigStore) WatchFile(ctx context.Context, path string) error {
+	if err := s.LoadFile(path); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// 监听所在目录，编辑器保存时常以新文件替换原文件
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		name := filepath.Clean(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != name || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := s.LoadFile(path); err != nil {
+					s.logger.Error("重新读取运行时配置文件失败",
+						zap.String("文件", path),
+						zap.Error(err),
+					)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				s.logger.Error("监听运行时配置文件失败", zap.String("文件", path), zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRuntimeConfigUpdateMergesPartialJSON(t *testing.T) {
+	pool, _ := newTestPool(t)
+	store := pool.RuntimeConfig()
+
+	config, err := store.Update([]byte(`{"max_fail_count": 5, "validator_timeout_seconds": 2, "scoring_weights": {"speed": 0.5}, "min_score": 40}`))
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// 嵌套对象中未传入的字段同样保持不变
+	want := DefaultScoringWeights
+	want.Speed = 0.5
+	if config.MaxFailCount != 5 || config.ScoringWeights != want || config.MinProxies != DefaultRuntimeConfig().MinProxies {
+		t.Errorf("updated config = %+v", config)
+	}
+
+	// 修改对之后读取配置的组件生效
+	if got := pool.Validator().Timeout(); got != 2*time.Second {
+		t.Errorf("validator timeout = %v, want 2s", got)
+	}
+	if got := pool.FailPolicy().MaxFailCount; got != 5 {
+		t.Errorf("max fail count = %d, want 5", got)
+	}
+	if got := pool.MaintenanceConfig().MinScore; got != 40 {
+		t.Errorf("maintenance min score = %v, want 40", got)
+	}
+
+	tests := []struct {
+		patch string
+		want  error
+	}{
+		{`{"db_dsn": "other", "max_fail_count": 1}`, ErrRestartRequired},
+		{`{"listen_addrs": [":9090"]}`, ErrRestartRequired},
+		{`{"max_fail": 1}`, ErrUnknownConfigField},
+		{`{"max_fail_count": 0}`, ErrInvalidRuntimeConfig},
+		{`{"scoring_weights": {"success_rate": 0, "speed": 0, "usage": 0}}`, ErrInvalidRuntimeConfig},
+		{`{"min_success_rate": 101}`, ErrInvalidRuntimeConfig},
+		{`not json`, ErrInvalidRuntimeConfig},
+	}
+	for _, tt := range tests {
+		if _, err := store.Update([]byte(tt.patch)); !errors.Is(err, tt.want) {
+			t.Errorf("Update(%s) error = %v, want %v", tt.patch, err, tt.want)
+		}
+	}
+	// 被拒绝的修改不改变当前配置
+	if got := store.Get(); got.MaxFailCount != 5 || got.ScoringWeights != want {
+		t.Errorf("config after rejected updates = %+v", got)
+	}
+}
+
+func TestRuntimeConfigWatchFile(t *testing.T) {
+	store := NewRuntimeConfigStore(zap.NewNop())
+	path := filepath.Join(t.TempDir(), "runtime.json")
+	if err := os.WriteFile(path, []byte(`{"min_proxies": 7}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := store.WatchFile(ctx, path); err != nil {
+		t.Fatalf("WatchFile: %v", err)
+	}
+	if got := store.Get().MinProxies; got != 7 {
+		t.Fatalf("min proxies after load = %d, want 7", got)
+	}
+
+	waitFor := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for store.Get().MinProxies != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("min proxies = %d, want %d after file change", store.Get().MinProxies, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// 文件被改写后重新读取
+	if err := os.WriteFile(path, []byte(`{"min_proxies": 9}`), 0o644); err != nil {
+		t.Fatalf("rewrite config: %v", err)
+	}
+	waitFor(9)
+
+	// 文件不合法时保持当前配置，之后合法的内容仍会生效
+	if err := os.WriteFile(path, []byte(`{"min_proxies": -1}`), 0o644); err != nil {
+		t.Fatalf("write invalid config: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"min_proxies": 11}`), 0o644); err != nil {
+		t.Fatalf("write config again: %v", err)
+	}
+	waitFor(11)
+
+	if err := store.WatchFile(ctx, filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("WatchFile on a missing file succeeded")
+	}
+}
@@ -1,7 +1,11 @@
 package core
 
 import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"proxy_pool/models"
@@ -12,7 +16,27 @@ import (
 	"go.uber.org/zap"
 )
 
-// ProxyScheduler 代理调度器
+// Scheduler 调度器接口，ProxyPool 和任务队列通过它调度代理，便于替换实现
+type Scheduler interface {
+	// ScheduleProxy 根据任务需求调度代理，ctx 到期后查询中止
+	ScheduleProxy(ctx context.Context, task *Task) (*models.Proxy, error)
+	// ReportProxyStatus 报告代理使用状态
+	ReportProxyStatus(proxyID uint, report StatusReport)
+	// ReportProxyStatuses 批量报告代理使用状态，结果与 reports 一一对应
+	ReportProxyStatuses(reports []ProxyStatusReport) ([]StatusReportResult, error)
+	// PredictProxyScore 预测代理在 horizon 之后的评分
+	PredictProxyScore(proxyID uint, horizon time.Duration) float64
+	// LiveConcurrentUse 获取代理当前并发使用数
+	LiveConcurrentUse(proxyID uint) int
+	// CooldownCount 获取处于冷却中的代理数
+	CooldownCount() int
+	// RankCandidates 按任务的调度策略排序符合条件的代理，返回前 n 个，不占用代理
+	RankCandidates(task *Task, n int) ([]ScheduleCandidate, error)
+}
+
+var _ Scheduler = (*ProxyScheduler)(nil)
+
+// ProxyScheduler 代理调度器，Scheduler 的默认实现
 type ProxyScheduler struct {
 	pool      *ProxyPool
 	mu        sync.RWMutex
@@ -21,7 +45,10 @@ type ProxyScheduler struct {
 	failCount map[uint]int       // 代理失败次数
 	weights   map[uint]float64   // 代理权重缓存
 	cooldown  map[uint]time.Time // 代理冷却时间
-	logger    *zap.Logger
+	inUse     map[uint][]lease   // 代理当前的占用，调度时追加，报告使用状态时归还，超时未上报的由 ExpireLeases 回收
+
+	domainFails map[string]map[uint]int // 各域名上代理的连续失败次数，成功后清零
+	logger      *zap.Logger
 }
 
 // NewProxyScheduler 创建新的代理调度器
@@ -33,52 +60,150 @@ func NewProxyScheduler(pool *ProxyPool) *ProxyScheduler {
 		failCount: make(map[uint]int),
 		weights:   make(map[uint]float64),
 		cooldown:  make(map[uint]time.Time),
-		logger:    pool.Logger(),
+		inUse:     make(map[uint][]lease),
+
+		domainFails: make(map[string]map[uint]int),
+		logger:      pool.Logger(),
 	}
 
 	return scheduler
 }
 
-// ScheduleProxy 根据任务需求调度代理
-func (s *ProxyScheduler) ScheduleProxy(task *Task) (*models.Proxy, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
+// ScheduleProxy 根据任务需求调度代理，数据库查询受 ctx 限制
+func (s *ProxyScheduler) ScheduleProxy(ctx context.Context, task *Task) (*models.Proxy, error) {
 	// 获取符合要求的代理列表
-	proxies, err := s.pool.GetProxies(task.ProxyType, 50)
+	filter := task.Filter()
+	if task.Strategy == StrategySiteAdaptive && task.Domain != "" {
+		filter.ExcludeIDs = append(filter.ExcludeIDs, s.bannedProxies(ctx, task.Domain)...)
+	}
+	proxies, err := s.pool.ListProxiesContext(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
+	// 预测评分需要查询评分历史，在加锁前完成
+	var predictions map[uint]float64
+	if task.Strategy == StrategyPredictive {
+		horizon := task.LookAheadDuration
+		if horizon <= 0 {
+			horizon = DefaultLookAheadDuration
+		}
+		predictions = s.predictScores(ctx, proxies, horizon)
+	}
+	// 查询已超时时不再调度，避免占用代理后调用方已不再等待
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 调度策略会更新内存统计，需要独占锁
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// 根据调度策略选择代理
+	var proxy *models.Proxy
 	switch task.Strategy {
 	case StrategySiteAdaptive:
-		return s.siteAdaptiveSchedule(proxies, task)
+		proxy, err = s.siteAdaptiveSchedule(proxies, task)
 	case StrategyWeighted:
-		return s.weightedSchedule(proxies, task)
+		proxy, err = s.weightedSchedule(proxies, task)
 	case StrategyRoundRobin:
-		return s.roundRobinSchedule(proxies, task)
+		proxy, err = s.roundRobinSchedule(proxies, task)
 	case StrategyLeastUsed:
-		return s.leastUsedSchedule(proxies, task)
+		proxy, err = s.leastUsedSchedule(proxies, task)
 	case StrategyFailover:
-		return s.failoverSchedule(proxies, task)
+		proxy, err = s.failoverSchedule(proxies, task)
+	case StrategyPredictive:
+		proxy, err = s.predictiveSchedule(proxies, task, predictions)
+	case StrategyRandom:
+		proxy, err = s.randomSchedule(proxies, task)
 	default:
-		return s.defaultSchedule(proxies, task)
+		proxy, err = s.defaultSchedule(proxies, task)
 	}
+
+	if errors.Is(err, ErrNoProxyAvailable) || errors.Is(err, ErrNoQualifiedProxy) {
+		return nil, &NoProxyError{Err: err, Filters: filter}
+	}
+	if err == nil {
+		s.acquireLease(proxy.ID, task.Strategy)
+		s.pool.realtime.RecordHandout()
+	}
+	return proxy, err
 }
 
+// MaxSchedulingTimeout GetProxyForTask 调度代理的最长时间，客户端指定更长的超时也不超过该值
+const MaxSchedulingTimeout = 30 * time.Second
+
 // Task 任务定义
 type Task struct {
-	ProxyType   models.ProxyType // 代理类型
-	Strategy    ScheduleStrategy // 调度策略
-	Priority    int              // 任务优先级
-	Timeout     time.Duration    // 超时时间
-	RetryCount  int              // 重试次数
-	TargetURL   string           // 目标URL
-	Domain      string           // 目标域名
-	RequireAnon bool             // 是否需要匿名代理
-	MaxFailures int              // 最大失败次数
-	MinSpeed    int64            // 最低速度要求
+	ProxyType      models.ProxyType   // 代理类型
+	Strategy       ScheduleStrategy   // 调度策略
+	Priority       int                // 任务优先级
+	Timeout        time.Duration      // 超时时间，GetProxyForTask 调度代理的最长时间，不超过 MaxSchedulingTimeout
+	RetryCount     int                // 重试次数
+	TargetURL      string             // 目标URL
+	Domain         string             // 目标域名
+	RequireAnon    bool               // 是否需要匿名代理
+	MaxFailures    int                // 最大失败次数
+	MinSpeed       int64              // 最低速度要求，即响应时间上限(毫秒)，0表示不限
+	Region         models.ProxyRegion // 代理地区
+	Protocol       string             // 协议类型
+	Source         string             // 代理来源
+	MinScore       float64            // 最低评分
+	MinSuccessRate float64            // 最低成功率(百分比)
+	MinChecks      int                // 最少检查次数(成功+失败)，0表示不限
+	Tag            string             // 代理标签，只调度带有该标签的代理
+	Group          string             // 代理分组名称，只调度该分组的成员，由 GetProxyFromGroup 设置
+
+	LookAheadDuration time.Duration // predictive 策略的预测时长，0 表示使用 DefaultLookAheadDuration
+
+	ExcludeIDs    []uint        // 排除的代理ID，所有策略都生效
+	ClientID      string        // 客户端标识，用于记录和排除最近获取的代理
+	ExcludeRecent time.Duration // 排除该客户端在此时间内获取过的代理，0 表示不排除
+
+	Fast bool // 优先从候选代理缓冲区获取，只适用于 weighted 策略，缓冲区中没有合适的代理时按正常流程调度
+
+	HighReliability bool // 优先调度检查次数和成功率达到高可用标准的代理，没有时按原条件调度，见 ScheduleHighReliability
+
+	HandoutID string // 发放ID，调度成功后设置，Redis不可用时为空
+}
+
+// Requirements 将任务转换为代理可复用条件
+func (t *Task) Requirements() *models.ReuseRequirements {
+	return &models.ReuseRequirements{
+		Type:           t.ProxyType,
+		Region:         t.Region,
+		Protocol:       t.Protocol,
+		Source:         t.Source,
+		MinScore:       t.MinScore,
+		MinSuccessRate: t.MinSuccessRate,
+		MaxSpeed:       t.MinSpeed,
+		RequireAnon:    t.RequireAnon,
+		ExcludeIDs:     t.ExcludeIDs,
+	}
+}
+
+// Filter 根据任务要求生成代理查询条件
+// 响应时间和匿名要求也在查询中过滤，避免评分靠前的不合格代理占满 Limit
+func (t *Task) Filter() models.ProxyFilter {
+	filter := models.ProxyFilter{
+		Type:           t.ProxyType,
+		Region:         t.Region,
+		Protocol:       t.Protocol,
+		Source:         t.Source,
+		MinScore:       t.MinScore,
+		MinSuccessRate: t.MinSuccessRate,
+		MinChecks:      t.MinChecks,
+		MaxSpeed:       t.MinSpeed,
+		Tag:            t.Tag,
+		Group:          t.Group,
+		Available:      models.Bool(true),
+		ExcludeIDs:     t.ExcludeIDs,
+		Limit:          50,
+	}
+	if t.RequireAnon {
+		filter.Anonymous = models.Bool(true)
+	}
+	return filter
 }
 
 // ScheduleStrategy 调度策略
@@ -90,8 +215,31 @@ const (
 	StrategyLeastUsed    ScheduleStrategy = "leastused"     // 最少使用
 	StrategyFailover     ScheduleStrategy = "failover"      // 故障转移
 	StrategySiteAdaptive ScheduleStrategy = "site_adaptive" // 站点自适应
+	StrategyPredictive   ScheduleStrategy = "predictive"    // 按评分趋势预测
+	StrategyRandom       ScheduleStrategy = "random"        // 不考虑评分的均匀随机
+
+	StrategyRoundRobinCached ScheduleStrategy = "roundrobin_cached" // 基于内存缓存的轮询，不实时查询数据库
+
+	// StrategyWeightedCached 负载均衡器按评分加权随机发放时记录的策略，任务通过 roundrobin_cached 使用，不能直接指定
+	StrategyWeightedCached ScheduleStrategy = "weighted_cached"
 )
 
+// scheduleStrategies 所有已知的调度策略
+var scheduleStrategies = []ScheduleStrategy{
+	StrategyWeighted, StrategyRoundRobin, StrategyLeastUsed, StrategyFailover, StrategySiteAdaptive,
+	StrategyPredictive, StrategyRandom, StrategyRoundRobinCached,
+}
+
+// IsValid 检查调度策略是否为已知策略
+func (st ScheduleStrategy) IsValid() bool {
+	for _, known := range scheduleStrategies {
+		if st == known {
+			return true
+		}
+	}
+	return false
+}
+
 // weightedSchedule 权重调度
 func (s *ProxyScheduler) weightedSchedule(proxies []models.Proxy, task *Task) (*models.Proxy, error) {
 	if len(proxies) == 0 {
@@ -107,11 +255,6 @@ func (s *ProxyScheduler) weightedSchedule(proxies []models.Proxy, task *Task) (*
 			continue
 		}
 
-		// 检查失败次数
-		if s.failCount[proxy.Model.ID] >= 3 {
-			continue
-		}
-
 		candidates = append(candidates, proxy)
 		weight := s.weights[proxy.Model.ID]
 		if weight == 0 {
@@ -135,13 +278,13 @@ func (s *ProxyScheduler) weightedSchedule(proxies []models.Proxy, task *Task) (*
 	for i, w := range weights {
 		r -= w
 		if r <= 0 {
-			s.updateProxyStats(candidates[i], true)
+			s.recordHandout(candidates[i])
 			return candidates[i], nil
 		}
 	}
 
 	// 保底选择最后一个
-	s.updateProxyStats(candidates[len(candidates)-1], true)
+	s.recordHandout(candidates[len(candidates)-1])
 	return candidates[len(candidates)-1], nil
 }
 
@@ -173,7 +316,7 @@ func (s *ProxyScheduler) roundRobinSchedule(proxies []models.Proxy, task *Task)
 	})
 
 	selected := candidates[0]
-	s.updateProxyStats(selected, true)
+	s.recordHandout(selected)
 	return selected, nil
 }
 
@@ -205,7 +348,7 @@ func (s *ProxyScheduler) leastUsedSchedule(proxies []models.Proxy, task *Task) (
 	})
 
 	selected := candidates[0]
-	s.updateProxyStats(selected, true)
+	s.recordHandout(selected)
 	return selected, nil
 }
 
@@ -237,7 +380,7 @@ func (s *ProxyScheduler) failoverSchedule(proxies []models.Proxy, task *Task) (*
 	})
 
 	selected := candidates[0]
-	s.updateProxyStats(selected, true)
+	s.recordHandout(selected)
 	return selected, nil
 }
 
@@ -263,7 +406,37 @@ func (s *ProxyScheduler) defaultSchedule(proxies []models.Proxy, task *Task) (*m
 
 	// 随机选择一个代理
 	selected := candidates[rand.Intn(len(candidates))]
-	s.updateProxyStats(selected, true)
+	s.recordHandout(selected)
+	return selected, nil
+}
+
+// randomSchedule 随机调度策略，在满足任务要求的代理中均匀选择，不考虑评分和权重
+// 使用 crypto/rand 而不是 math/rand，选择结果不可预测
+func (s *ProxyScheduler) randomSchedule(proxies []models.Proxy, task *Task) (*models.Proxy, error) {
+	if len(proxies) == 0 {
+		return nil, ErrNoProxyAvailable
+	}
+
+	var candidates []*models.Proxy
+	for i := range proxies {
+		proxy := &proxies[i]
+		if !s.isProxyQualified(proxy, task) {
+			continue
+		}
+
+		candidates = append(candidates, proxy)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoQualifiedProxy
+	}
+
+	var buf [8]byte
+	if _, err := cryptorand.Read(buf[:]); err != nil {
+		return nil, fmt.Errorf("read random bytes: %w", err)
+	}
+	selected := candidates[binary.BigEndian.Uint64(buf[:])%uint64(len(candidates))]
+	s.recordHandout(selected)
 	return selected, nil
 }
 
@@ -303,39 +476,75 @@ func (s *ProxyScheduler) calculateWeight(proxy *models.Proxy) float64 {
 
 // isProxyQualified 检查代理是否满足任务要求
 func (s *ProxyScheduler) isProxyQualified(proxy *models.Proxy, task *Task) bool {
-	// 检查代理类型
-	if task.ProxyType != "" && proxy.Type != task.ProxyType {
-		return false
-	}
+	return proxy.IsReusable(task.Requirements(), s.statsFor(proxy.ID))
+}
 
-	// 检查代理是否在冷却期
-	if cooldownTime, ok := s.cooldown[proxy.Model.ID]; ok {
+// statsFor 获取代理在调度器内存中的状态，顺带清除已过期的冷却，调用方需持有 s.mu
+// 数据库中的 ConcurrentUse 可能已过期，并发数使用内存中的实时计数
+func (s *ProxyScheduler) statsFor(proxyID uint) models.ProxySchedulerStats {
+	inCooldown := false
+	if cooldownTime, ok := s.cooldown[proxyID]; ok {
 		if time.Now().Before(cooldownTime) {
-			return false
+			inCooldown = true
+		} else {
+			delete(s.cooldown, proxyID)
 		}
-		delete(s.cooldown, proxy.Model.ID)
 	}
 
-	// 检查失败次数
-	if s.failCount[proxy.Model.ID] >= 3 {
-		return false
+	return models.ProxySchedulerStats{
+		InCooldown: inCooldown,
+		InUse:      len(s.inUse[proxyID]),
 	}
+}
 
-	return true
+// LiveConcurrentUse 获取代理当前并发使用数
+func (s *ProxyScheduler) LiveConcurrentUse(proxyID uint) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.inUse[proxyID])
+}
+
+// CooldownCount 获取仍处于冷却期的代理数
+func (s *ProxyScheduler) CooldownCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, until := range s.cooldown {
+		if now.Before(until) {
+			count++
+		}
+	}
+	return count
 }
 
-// updateProxyStats 更新代理统计信息
-func (s *ProxyScheduler) updateProxyStats(proxy *models.Proxy, success bool) {
+// ReleaseProxy 释放一次已调度但未使用的代理，不更新使用统计
+func (s *ProxyScheduler) ReleaseProxy(proxyID uint) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.releaseProxy(proxyID)
+}
 
+// recordHandout 记录一次发放的使用时间和次数并更新权重，调用方需持有 s.mu
+// 不影响连续失败次数和冷却，失败后冷却结束再发放的代理再次失败时冷却时间继续翻倍
+func (s *ProxyScheduler) recordHandout(proxy *models.Proxy) {
+	s.lastUsed[proxy.Model.ID] = time.Now()
+	s.useCount[proxy.Model.ID]++
+	s.weights[proxy.Model.ID] = s.calculateScore(proxy)
+}
+
+// updateProxyStats 更新代理统计信息，调用方需持有 s.mu
+// 失败时按发放代理时调度策略 strategy 的设置冷却，连续失败越多冷却越长
+func (s *ProxyScheduler) updateProxyStats(proxy *models.Proxy, success bool, strategy ScheduleStrategy) {
 	s.lastUsed[proxy.Model.ID] = time.Now()
 	s.useCount[proxy.Model.ID]++
 
 	if !success {
 		s.failCount[proxy.Model.ID]++
-		if s.failCount[proxy.Model.ID] >= 3 {
-			s.cooldown[proxy.Model.ID] = time.Now().Add(5 * time.Minute)
+		policy := s.cooldownPolicy(strategy)
+		if cooldown := policy.Cooldown(s.failCount[proxy.Model.ID]); cooldown > 0 {
+			s.cooldown[proxy.Model.ID] = time.Now().Add(cooldown)
 		}
 	} else {
 		s.failCount[proxy.Model.ID] = 0
@@ -346,19 +555,102 @@ func (s *ProxyScheduler) updateProxyStats(proxy *models.Proxy, success bool) {
 	s.weights[proxy.Model.ID] = s.calculateScore(proxy)
 }
 
+// StatusReport 代理使用结果
+type StatusReport struct {
+	Success   bool   // 是否成功
+	Speed     int64  // 响应时间(毫秒)
+	TargetURL string // 访问的目标URL
+	Domain    string // 目标域名，为空时不更新域名统计
+	ErrorMsg  string // 失败原因
+	HandoutID string // 获取代理时返回的发放ID，用于补全目标URL、域名并统计发放结果
+}
+
 // ReportProxyStatus 报告代理使用状态
-func (s *ProxyScheduler) ReportProxyStatus(proxyID uint, success bool, speed int64) {
+func (s *ProxyScheduler) ReportProxyStatus(proxyID uint, report StatusReport) {
+	// 代理可能已被删除，先释放并发计数
+	s.mu.Lock()
+	strategy := s.releaseProxy(proxyID)
+	s.updateDomainStats(report.Domain, proxyID, report.Success)
+	s.mu.Unlock()
+
 	proxy, err := s.getProxyByID(proxyID)
 	if err != nil {
 		s.logger.Error("Failed to get proxy", zap.Error(err))
 		return
 	}
 
-	s.updateProxyStats(proxy, success)
-	if !success {
+	if err := models.RecordStreak(s.pool.DB(), proxyID, report.Success); err != nil {
+		s.logger.Warn("更新代理连续成功/失败次数失败",
+			zap.Uint("代理ID", proxyID),
+			zap.Error(err),
+		)
+	}
+	proxy.RecordStreak(report.Success)
+
+	// 失败且附带原因时记录失败原因，成功时清空
+	if report.Success || report.ErrorMsg != "" {
+		errorMsg := report.ErrorMsg
+		if report.Success {
+			errorMsg = ""
+		}
+		if err := models.RecordProxyError(s.pool.DB(), proxyID, errorMsg); err != nil {
+			s.logger.Warn("更新代理失败原因失败",
+				zap.Uint("代理ID", proxyID),
+				zap.Error(err),
+			)
+		}
+		proxy.RecordError(errorMsg)
+	}
+
+	s.mu.Lock()
+	s.updateProxyStats(proxy, report.Success, strategy)
+	s.mu.Unlock()
+
+	usage := &models.ProxyUsage{
+		ProxyID:   proxyID,
+		Success:   report.Success,
+		Speed:     report.Speed,
+		ErrorMsg:  report.ErrorMsg,
+		TargetURL: report.TargetURL,
+		Domain:    report.Domain,
+	}
+	if err := models.RecordUsage(s.pool.DB(), usage); err != nil {
+		s.logger.Warn("记录代理使用失败",
+			zap.Uint("代理ID", proxyID),
+			zap.Error(err),
+		)
+	}
+
+	if !report.Success {
+		s.pool.realtime.RecordFailure()
+
 		// 更新数据库中的代理状态
-		s.pool.UpdateProxyStatus(proxy, false, speed)
+		s.pool.UpdateProxyStatus(proxy, false, report.Speed)
+	}
+}
+
+// updateDomainStats 更新代理在域名上的连续失败次数，调用方需持有 s.mu
+func (s *ProxyScheduler) updateDomainStats(domain string, proxyID uint, success bool) {
+	if domain == "" {
+		return
+	}
+
+	fails := s.domainFails[domain]
+	if success {
+		if fails != nil {
+			delete(fails, proxyID)
+			if len(fails) == 0 {
+				delete(s.domainFails, domain)
+			}
+		}
+		return
 	}
+
+	if fails == nil {
+		fails = make(map[uint]int)
+		s.domainFails[domain] = fails
+	}
+	fails[proxyID]++
 }
 
 // adaptiveProxy 用于代理排序的辅助结构
@@ -369,9 +661,24 @@ type adaptiveProxy struct {
 	score    float64
 }
 
+// bannedProxies 获取在域名上被上报封禁的代理，查询失败时不排除任何代理
+func (s *ProxyScheduler) bannedProxies(ctx context.Context, domain string) []uint {
+	since := time.Now().Add(-s.pool.ReputationBanTTL())
+	ids, err := models.BannedProxyIDs(s.pool.DB().WithContext(ctx), domain, since)
+	if err != nil {
+		s.logger.Warn("查询代理封禁上报失败",
+			zap.String("域名", domain),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return ids
+}
+
 // siteAdaptiveSchedule 基于站点自适应的代理调度
+// 被上报封禁的代理已在 ScheduleProxy 查询时排除
 func (s *ProxyScheduler) siteAdaptiveSchedule(proxies []models.Proxy, task *Task) (*models.Proxy, error) {
-	domain := task.Domain
+	domain := models.NormalizeDomain(task.Domain)
 	if domain == "" {
 		return s.defaultSchedule(proxies, task)
 	}
@@ -379,6 +686,15 @@ func (s *ProxyScheduler) siteAdaptiveSchedule(proxies []models.Proxy, task *Task
 	var candidates []adaptiveProxy
 	for i := range proxies {
 		proxy := &proxies[i]
+		if !s.isProxyQualified(proxy, task) {
+			continue
+		}
+
+		// 跳过在该域名上连续失败过多的代理
+		if task.MaxFailures > 0 && s.domainFails[domain][proxy.Model.ID] >= task.MaxFailures {
+			continue
+		}
+
 		useCount := s.useCount[proxy.Model.ID]
 
 		candidates = append(candidates, adaptiveProxy{
@@ -393,25 +709,8 @@ func (s *ProxyScheduler) siteAdaptiveSchedule(proxies []models.Proxy, task *Task
 		return nil, ErrNoQualifiedProxy
 	}
 
-	// 根据多个因素排序：
-	// 1. 使用次数（优先使用次数少的）
-	// 2. 最后使用时间（优先使用间隔时间长的）
-	// 3. 代理评分（优先使用评分高的）
 	sort.Slice(candidates, func(i, j int) bool {
-		// 如果使用次数相差超过2次，优先考虑使用次数
-		if abs(candidates[i].useCount-candidates[j].useCount) > 2 {
-			return candidates[i].useCount < candidates[j].useCount
-		}
-
-		// 如果最后使用时间间隔超过5秒，优先考虑间隔时间
-		timeDiffI := time.Since(candidates[i].lastUsed)
-		timeDiffJ := time.Since(candidates[j].lastUsed)
-		if timeDiffI > 5*time.Second || timeDiffJ > 5*time.Second {
-			return timeDiffI > timeDiffJ
-		}
-
-		// 其他情况下考虑代理评分
-		return candidates[i].score > candidates[j].score
+		return adaptiveLess(candidates[i], candidates[j])
 	})
 
 	// 从前3个候选代理中随机选择一个，增加随机性
@@ -421,11 +720,33 @@ func (s *ProxyScheduler) siteAdaptiveSchedule(proxies []models.Proxy, task *Task
 	}
 
 	selected := candidates[selectedIndex].proxy
-	s.updateProxyStats(selected, true)
+	s.recordHandout(selected)
 
 	return selected, nil
 }
 
+// adaptiveLess 站点自适应调度的排序规则，a 应排在 b 之前时返回 true
+// 根据多个因素排序：
+// 1. 使用次数（优先使用次数少的）
+// 2. 最后使用时间（优先使用间隔时间长的）
+// 3. 代理评分（优先使用评分高的）
+func adaptiveLess(a, b adaptiveProxy) bool {
+	// 如果使用次数相差超过2次，优先考虑使用次数
+	if abs(a.useCount-b.useCount) > 2 {
+		return a.useCount < b.useCount
+	}
+
+	// 如果最后使用时间间隔超过5秒，优先考虑间隔时间
+	timeDiffA := time.Since(a.lastUsed)
+	timeDiffB := time.Since(b.lastUsed)
+	if timeDiffA > 5*time.Second || timeDiffB > 5*time.Second {
+		return timeDiffA > timeDiffB
+	}
+
+	// 其他情况下考虑代理评分
+	return a.score > b.score
+}
+
 // 辅助函数：计算绝对值
 func abs(n int) int {
 	if n < 0 {
@@ -435,8 +756,43 @@ func abs(n int) int {
 }
 
 var (
-	ErrNoProxyAvailable = errors.New("no proxy available")
-	ErrNoQualifiedProxy = errors.New("no qualified proxy found")
+	ErrNoProxyAvailable  = errors.New("no proxy available")
+	ErrNoQualifiedProxy  = errors.New("no qualified proxy found")
+	ErrSchedulingTimeout = errors.New("scheduling timed out")
+)
+
+// SchedulingTimeoutError 调度代理超时，附带超时时间和实际耗时
+type SchedulingTimeoutError struct {
+	Timeout time.Duration
+	Elapsed time.Duration
+}
+
+func (e *SchedulingTimeoutError) Error() string {
+	return fmt.Sprintf("%s after %s (timeout %s)", ErrSchedulingTimeout, e.Elapsed.Round(time.Millisecond), e.Timeout)
+}
+
+func (e *SchedulingTimeoutError) Unwrap() error {
+	return ErrSchedulingTimeout
+}
+
+// NoProxyError 无可用代理错误，附带调度时使用的过滤条件
+type NoProxyError struct {
+	Err     error
+	Filters models.ProxyFilter
+}
+
+func (e *NoProxyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NoProxyError) Unwrap() error {
+	return e.Err
+}
+
+// 连续成功加成
+const (
+	streakBoostPerSuccess = 0.025 // 每次连续成功提高的权重比例
+	maxStreakBoostCount   = 20    // 最多计入的连续成功次数
 )
 
 // calculateScore 计算代理评分
@@ -445,22 +801,30 @@ func (s *ProxyScheduler) calculateScore(proxy *models.Proxy) float64 {
 	speed := float64(proxy.Speed)
 	useCount := float64(proxy.UseCount)
 
+	// 各项分数的权重取自运行时配置，默认成功率60%、速度30%、使用次数10%
+	weights := s.pool.RuntimeConfig().Get().ScoringWeights
+
 	// 基础分数
-	score := successRate * 0.6 // 成功率占60%权重
+	score := successRate * weights.SuccessRate
 
 	// 速度分数 (假设5000ms为基准)
 	if speed > 0 {
 		speedScore := math.Max(0, 100-speed/50)
-		score += speedScore * 0.3 // 速度占30%权重
+		score += speedScore * weights.Speed
 	}
 
 	// 使用次数分数 (鼓励使用较少使用的代理)
 	if useCount > 0 {
 		usageScore := math.Max(0, 100-useCount/100)
-		score += usageScore * 0.1 // 使用次数占10%权重
+		score += usageScore * weights.Usage
 	}
 
-	return score
+	// 连续成功加成，每次连续成功提高2.5%，最多提高50%
+	streak := math.Min(float64(proxy.ConsecutiveSuccess), maxStreakBoostCount)
+	score *= 1 + streak*streakBoostPerSuccess
+
+	// 老化代理的权重与评分一样减半
+	return score * proxy.AgeDecay(models.ScoreMaxAge())
 }
 
 // 修复 Score 相关的调用
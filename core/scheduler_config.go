@@ -0,0 +1,168 @@
+package core
+
+import (
+	"fmt"
+	"proxy_pool/models"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultSchedulerFailThreshold = models.MaxSchedulerFailures // 默认连续失败多少次后冷却
+	DefaultSchedulerCooldown      = 5 * time.Minute             // 默认冷却时间
+)
+
+// CooldownPolicy 调度器的失败阈值和冷却时间
+// 代理连续失败 FailThreshold 次后冷却 CooldownBaseSeconds 秒，冷却期间不再调度；冷却结束后再次失败时冷却时间翻倍，不超过 CooldownMaxSeconds
+type CooldownPolicy struct {
+	FailThreshold       int     `json:"fail_threshold" validate:"min=0"`        // 连续失败多少次后冷却
+	CooldownBaseSeconds float64 `json:"cooldown_base_seconds" validate:"min=0"` // 首次冷却时间(秒)
+	CooldownMaxSeconds  float64 `json:"cooldown_max_seconds" validate:"min=0"`  // 冷却时间上限(秒)
+}
+
+// SchedulerConfig 调度器的失败阈值和冷却时间，可按调度策略覆盖
+type SchedulerConfig struct {
+	CooldownPolicy                                     // 全局设置，JSON中展开
+	Strategies     map[ScheduleStrategy]CooldownPolicy `json:"strategies"` // 各调度策略的设置，为0的项使用全局设置
+}
+
+// DefaultSchedulerConfig 默认调度器配置，连续失败3次后冷却5分钟
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{CooldownPolicy: CooldownPolicy{
+		FailThreshold:       DefaultSchedulerFailThreshold,
+		CooldownBaseSeconds: DefaultSchedulerCooldown.Seconds(),
+		CooldownMaxSeconds:  DefaultSchedulerCooldown.Seconds(),
+	}}
+}
+
+// CooldownBase 首次冷却时间
+func (p CooldownPolicy) CooldownBase() time.Duration {
+	return time.Duration(p.CooldownBaseSeconds * float64(time.Second))
+}
+
+// CooldownMax 冷却时间上限
+func (p CooldownPolicy) CooldownMax() time.Duration {
+	return time.Duration(p.CooldownMaxSeconds * float64(time.Second))
+}
+
+// Cooldown 连续失败 failures 次后的冷却时间，未达到失败阈值时为0
+func (p CooldownPolicy) Cooldown(failures int) time.Duration {
+	if failures < p.FailThreshold {
+		return 0
+	}
+	cooldown, max := p.CooldownBase(), p.CooldownMax()
+	for i := p.FailThreshold; i < failures && cooldown < max; i++ {
+		cooldown *= 2
+	}
+	if cooldown > max {
+		cooldown = max
+	}
+	return cooldown
+}
+
+// merge 用 base 补全为0的项
+func (p CooldownPolicy) merge(base CooldownPolicy) CooldownPolicy {
+	if p.FailThreshold == 0 {
+		p.FailThreshold = base.FailThreshold
+	}
+	if p.CooldownBaseSeconds == 0 {
+		p.CooldownBaseSeconds = base.CooldownBaseSeconds
+	}
+	if p.CooldownMaxSeconds == 0 {
+		p.CooldownMaxSeconds = base.CooldownMaxSeconds
+	}
+	return p
+}
+
+// For 获取调度策略实际使用的设置，策略未覆盖的项使用全局设置
+func (c SchedulerConfig) For(strategy ScheduleStrategy) CooldownPolicy {
+	return c.Strategies[strategy].merge(c.CooldownPolicy)
+}
+
+// Effective 获取各调度器策略实际使用的设置，roundrobin_cached 不经过调度器，不在其中
+func (c SchedulerConfig) Effective() map[ScheduleStrategy]CooldownPolicy {
+	effective := make(map[ScheduleStrategy]CooldownPolicy, len(scheduleStrategies))
+	for _, strategy := range scheduleStrategies {
+		if strategy != StrategyRoundRobinCached {
+			effective[strategy] = c.For(strategy)
+		}
+	}
+	return effective
+}
+
+// withDefaults 用默认配置补全全局设置中为0的项
+func (c SchedulerConfig) withDefaults() SchedulerConfig {
+	c.CooldownPolicy = c.CooldownPolicy.merge(DefaultSchedulerConfig().CooldownPolicy)
+	return c.clone()
+}
+
+// clone 复制调度器配置，不与原配置共用按策略的设置
+func (c SchedulerConfig) clone() SchedulerConfig {
+	if c.Strategies != nil {
+		strategies := make(map[ScheduleStrategy]CooldownPolicy, len(c.Strategies))
+		for s, p := range c.Strategies {
+			strategies[s] = p
+		}
+		c.Strategies = strategies
+	}
+	return c
+}
+
+// validate 检查调度器配置，按策略排序以便错误信息顺序稳定
+func (c SchedulerConfig) validate() []error {
+	var errs []error
+	if c.FailThreshold <= 0 {
+		errs = append(errs, fmt.Errorf("scheduler.fail_threshold: must be positive, got %d", c.FailThreshold))
+	}
+	if c.CooldownBaseSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("scheduler.cooldown_base_seconds: must be positive, got %v", c.CooldownBaseSeconds))
+	}
+	if c.CooldownMaxSeconds < c.CooldownBaseSeconds {
+		errs = append(errs, fmt.Errorf("scheduler.cooldown_max_seconds: must not be less than cooldown_base_seconds, got %v", c.CooldownMaxSeconds))
+	}
+
+	strategies := make([]string, 0, len(c.Strategies))
+	for s := range c.Strategies {
+		strategies = append(strategies, string(s))
+	}
+	sort.Strings(strategies)
+	for _, s := range strategies {
+		strategy := ScheduleStrategy(s)
+		if !strategy.IsValid() || strategy == StrategyRoundRobinCached {
+			errs = append(errs, fmt.Errorf("scheduler.strategies: unsupported strategy %q", s))
+			continue
+		}
+		override := c.Strategies[strategy]
+		if override.FailThreshold < 0 || override.CooldownBaseSeconds < 0 || override.CooldownMaxSeconds < 0 {
+			errs = append(errs, fmt.Errorf("scheduler.strategies[%s]: must not be negative, got %+v", s, override))
+			continue
+		}
+		if p := c.For(strategy); p.CooldownMaxSeconds < p.CooldownBaseSeconds {
+			errs = append(errs, fmt.Errorf("scheduler.strategies[%s]: cooldown_max_seconds %v is less than cooldown_base_seconds %v",
+				s, p.CooldownMaxSeconds, p.CooldownBaseSeconds))
+		}
+	}
+	return errs
+}
+
+// cooldownPolicy 获取调度策略当前使用的失败阈值和冷却时间
+func (s *ProxyScheduler) cooldownPolicy(strategy ScheduleStrategy) CooldownPolicy {
+	return s.pool.RuntimeConfig().Get().Scheduler.For(strategy)
+}
+
+// SchedulerStats 调度器当前的失败阈值、冷却设置和冷却中的代理数
+type SchedulerStats struct {
+	Config        SchedulerConfig                     `json:"config"`         // 当前配置
+	Effective     map[ScheduleStrategy]CooldownPolicy `json:"effective"`      // 各调度策略实际使用的设置
+	CooldownCount int                                 `json:"cooldown_count"` // 冷却中的代理数
+}
+
+// SchedulerStats 获取调度器当前的设置和冷却中的代理数
+func (p *ProxyPool) SchedulerStats() SchedulerStats {
+	config := p.runtime.Get().Scheduler
+	return SchedulerStats{
+		Config:        config,
+		Effective:     config.Effective(),
+		CooldownCount: p.scheduler.CooldownCount(),
+	}
+}
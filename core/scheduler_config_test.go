@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerConfigOverridesTakePrecedence(t *testing.T) {
+	config := SchedulerConfig{
+		CooldownPolicy: CooldownPolicy{FailThreshold: 3, CooldownBaseSeconds: 300, CooldownMaxSeconds: 300},
+		Strategies: map[ScheduleStrategy]CooldownPolicy{
+			StrategyFailover:   {FailThreshold: 1, CooldownBaseSeconds: 600, CooldownMaxSeconds: 3600},
+			StrategyRoundRobin: {CooldownBaseSeconds: 10},
+		},
+	}
+
+	tests := []struct {
+		strategy ScheduleStrategy
+		want     CooldownPolicy
+	}{
+		{StrategyFailover, CooldownPolicy{FailThreshold: 1, CooldownBaseSeconds: 600, CooldownMaxSeconds: 3600}},
+		{StrategyRoundRobin, CooldownPolicy{FailThreshold: 3, CooldownBaseSeconds: 10, CooldownMaxSeconds: 300}},
+		{StrategyWeighted, CooldownPolicy{FailThreshold: 3, CooldownBaseSeconds: 300, CooldownMaxSeconds: 300}},
+	}
+	for _, tt := range tests {
+		if got := config.For(tt.strategy); got != tt.want {
+			t.Errorf("For(%s) = %+v, want %+v", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestCooldownBackoff(t *testing.T) {
+	policy := CooldownPolicy{FailThreshold: 2, CooldownBaseSeconds: 10, CooldownMaxSeconds: 35}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{4, 35 * time.Second},
+		{10, 35 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Cooldown(tt.failures); got != tt.want {
+			t.Errorf("Cooldown(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestProxyReschedulableAfterCooldown(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	err := pool.RuntimeConfig().modify(func(c *RuntimeConfig) {
+		c.Scheduler.Strategies = map[ScheduleStrategy]CooldownPolicy{
+			StrategyFailover: {FailThreshold: 1, CooldownBaseSeconds: 60, CooldownMaxSeconds: 600},
+		}
+	})
+	if err != nil {
+		t.Fatalf("set scheduler config: %v", err)
+	}
+
+	task := &Task{Strategy: StrategyFailover}
+	fail := func() time.Duration {
+		t.Helper()
+		if _, err := scheduler.ScheduleProxy(context.Background(), task); err != nil {
+			t.Fatalf("schedule: %v", err)
+		}
+		scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: false})
+		scheduler.mu.RLock()
+		defer scheduler.mu.RUnlock()
+		return time.Until(scheduler.cooldown[proxy.ID])
+	}
+	expireCooldown := func() {
+		scheduler.mu.Lock()
+		scheduler.cooldown[proxy.ID] = time.Now().Add(-time.Second)
+		scheduler.mu.Unlock()
+	}
+
+	// failover 覆盖的失败阈值为1，失败一次即冷却
+	if cooldown := fail(); cooldown <= 50*time.Second || cooldown > time.Minute {
+		t.Fatalf("first cooldown = %v, want about 1m", cooldown)
+	}
+	if _, err := scheduler.ScheduleProxy(context.Background(), task); !errors.Is(err, ErrNoQualifiedProxy) {
+		t.Fatalf("schedule during cooldown error = %v, want %v", err, ErrNoQualifiedProxy)
+	}
+
+	// 冷却结束后可再次调度，再失败时冷却时间翻倍
+	expireCooldown()
+	if cooldown := fail(); cooldown <= 110*time.Second || cooldown > 2*time.Minute {
+		t.Fatalf("second cooldown = %v, want about 2m", cooldown)
+	}
+
+	// 成功后清零
+	expireCooldown()
+	if _, err := scheduler.ScheduleProxy(context.Background(), task); err != nil {
+		t.Fatalf("schedule after second cooldown: %v", err)
+	}
+	scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+	scheduler.mu.RLock()
+	failures := scheduler.failCount[proxy.ID]
+	scheduler.mu.RUnlock()
+	if failures != 0 {
+		t.Errorf("fail count after success = %d, want 0", failures)
+	}
+}
+
+func TestGlobalThresholdAppliesWithoutOverride(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	// 默认失败阈值为3，weighted 没有覆盖，失败两次仍可调度
+	task := &Task{Strategy: StrategyWeighted}
+	for i := 0; i < 2; i++ {
+		if _, err := scheduler.ScheduleProxy(context.Background(), task); err != nil {
+			t.Fatalf("schedule %d: %v", i, err)
+		}
+		scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: false})
+		// 失败上报会将代理标记为不可用，重新标记可用只观察调度器的冷却
+		pool.DB().Model(proxy).UpdateColumn("available", true)
+	}
+	if _, err := scheduler.ScheduleProxy(context.Background(), task); err != nil {
+		t.Fatalf("schedule below threshold: %v", err)
+	}
+	scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: false})
+	pool.DB().Model(proxy).UpdateColumn("available", true)
+	if _, err := scheduler.ScheduleProxy(context.Background(), task); !errors.Is(err, ErrNoQualifiedProxy) {
+		t.Fatalf("schedule at threshold error = %v, want %v", err, ErrNoQualifiedProxy)
+	}
+}
+
+func TestLeaseDropsHandoutStrategy(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	if _, err := scheduler.ScheduleProxy(context.Background(), &Task{Strategy: StrategyFailover}); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+
+	scheduler.mu.RLock()
+	defer scheduler.mu.RUnlock()
+	if len(scheduler.inUse) != 0 {
+		t.Errorf("inUse has %d entries after report, want 0", len(scheduler.inUse))
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+)
+
+func TestSiteAdaptiveSkipsUnqualifiedProxies(t *testing.T) {
+	pool, _ := newTestPool(t)
+	best := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Score = 95 })
+	other := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Score = 40 })
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	scheduler.mu.Lock()
+	scheduler.cooldown[best.ID] = time.Now().Add(time.Hour)
+	scheduler.mu.Unlock()
+
+	task := &Task{Strategy: StrategySiteAdaptive, Domain: "example.com"}
+	for i := 0; i < 10; i++ {
+		proxy, err := scheduler.ScheduleProxy(context.Background(), task)
+		if err != nil {
+			t.Fatalf("schedule: %v", err)
+		}
+		if proxy.ID != other.ID {
+			t.Fatalf("scheduled proxy %d in cooldown, want %d", proxy.ID, other.ID)
+		}
+		scheduler.ReleaseProxy(proxy.ID)
+	}
+}
+
+func TestScheduleFindsQualifiedProxyBeyondLimit(t *testing.T) {
+	pool, _ := newTestPool(t)
+	for i := 0; i < 60; i++ {
+		newTestProxy(t, pool.DB(), fmt.Sprintf("20.0.%d.%d", i/250, i%250+1), func(p *models.Proxy) {
+			p.Score = 90
+			p.Speed = 5000
+		})
+	}
+	for i := 0; i < 60; i++ {
+		newTestProxy(t, pool.DB(), fmt.Sprintf("20.1.%d.%d", i/250, i%250+1), func(p *models.Proxy) {
+			p.Score = 90
+			p.Speed = 100
+		})
+	}
+	want := newTestProxy(t, pool.DB(), "3.3.3.3", func(p *models.Proxy) {
+		p.Score = 20
+		p.Speed = 100
+		p.Anonymous = true
+	})
+
+	task := &Task{Strategy: StrategyWeighted, MinSpeed: 1000, RequireAnon: true}
+	proxy, err := pool.Scheduler().ScheduleProxy(context.Background(), task)
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if proxy.ID != want.ID {
+		t.Errorf("scheduled proxy %d, want %d", proxy.ID, want.ID)
+	}
+}
+
+func TestReportProxyStatusTracksDomainFailures(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+	other := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Score = 40 })
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	for i := 0; i < 3; i++ {
+		scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: false, TargetURL: "https://example.com/a", Domain: "example.com"})
+	}
+	scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: false})
+
+	scheduler.mu.Lock()
+	fails := scheduler.domainFails["example.com"][proxy.ID]
+	scheduler.mu.Unlock()
+	if fails != 3 {
+		t.Fatalf("domain failures = %d, want 3", fails)
+	}
+
+	// 排除失败对代理本身的影响（冷却、使用次数、降分），只保留域名上的失败计数，
+	// 并让 other 最近刚被使用，不排除时应优先调度 proxy
+	scheduler.mu.Lock()
+	delete(scheduler.cooldown, proxy.ID)
+	delete(scheduler.lastUsed, proxy.ID)
+	delete(scheduler.useCount, proxy.ID)
+	scheduler.lastUsed[other.ID] = time.Now()
+	scheduler.mu.Unlock()
+	pool.DB().Model(proxy).UpdateColumns(map[string]interface{}{"score": 80, "available": true, "success_rate": 90})
+
+	// 在该域名上失败次数达到上限的代理不再调度到该域名
+	task := &Task{Strategy: StrategySiteAdaptive, Domain: "example.com", MaxFailures: 3}
+	got, err := scheduler.ScheduleProxy(context.Background(), task)
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if got.ID != other.ID {
+		t.Errorf("scheduled proxy %d for example.com, want %d", got.ID, other.ID)
+	}
+	scheduler.ReleaseProxy(got.ID)
+
+	// 成功后清零
+	scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: true, Domain: "example.com"})
+	scheduler.mu.Lock()
+	_, tracked := scheduler.domainFails["example.com"]
+	scheduler.mu.Unlock()
+	if tracked {
+		t.Error("domain failures kept after a success")
+	}
+}
+
+func TestReportProxyStatusWeightAppliesAgeDecay(t *testing.T) {
+	pool, _ := newTestPool(t)
+	pool.SetMaxProxyAge(7 * 24 * time.Hour)
+	t.Cleanup(func() { pool.SetMaxProxyAge(0) })
+	fresh := newTestProxy(t, pool.DB(), "1.1.1.1")
+	aged := newTestProxy(t, pool.DB(), "1.1.1.2", func(p *models.Proxy) { p.CreatedAt = time.Now().Add(-5 * 24 * time.Hour) })
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+
+	// 上报后重新计算的调度权重与评分一样对老化代理减半
+	scheduler.ReportProxyStatus(fresh.ID, StatusReport{Success: true})
+	scheduler.ReportProxyStatus(aged.ID, StatusReport{Success: true})
+
+	scheduler.mu.Lock()
+	freshWeight, agedWeight := scheduler.weights[fresh.ID], scheduler.weights[aged.ID]
+	scheduler.mu.Unlock()
+	if freshWeight == 0 || agedWeight != freshWeight/2 {
+		t.Errorf("aged weight = %v, want half of fresh weight %v", agedWeight, freshWeight)
+	}
+}
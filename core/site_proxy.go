@@ -0,0 +1,350 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	siteconfig "proxy_pool/core/config"
+	"proxy_pool/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	siteLimitKeyspace    = "site_limited"
+	MaxRateLimitAttempts = 5 // GetProxyForURL 遇到受限代理时最多尝试的代理数
+)
+
+var (
+	// ErrInvalidTargetURL 目标URL无法解析出域名
+	ErrInvalidTargetURL = errors.New("invalid target url")
+	// ErrSiteNotRegistered 域名没有注册站点配置
+	ErrSiteNotRegistered = errors.New("site config not registered")
+	// ErrProxyRateLimited 尝试的代理在站点上均已达到使用上限
+	ErrProxyRateLimited = errors.New("proxy rate limited for site")
+)
+
+// extractDomain 从URL中提取域名，无法解析时返回空字符串
+func extractDomain(targetURL string) string {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// SiteRateLimiter 按站点配置的短期、长期限制统计每个代理发放给站点的次数
+// 代理在任一窗口内达到上限后记入站点的受限集合直到窗口结束，调度时排除；Redis不可用时不限制
+type SiteRateLimiter struct {
+	redis  *RedisGuard
+	logger *zap.Logger
+}
+
+// NewSiteRateLimiter 创建站点限流器
+func NewSiteRateLimiter(guard *RedisGuard, logger *zap.Logger) *SiteRateLimiter {
+	return &SiteRateLimiter{redis: guard, logger: logger}
+}
+
+// siteWindow 站点的一个限流窗口
+type siteWindow struct {
+	term  string
+	limit int
+	ttl   time.Duration
+}
+
+// siteWindows 站点的短期和长期限流窗口
+func siteWindows(site *siteconfig.SiteConfig) []siteWindow {
+	return []siteWindow{
+		{term: "short", limit: site.ShortTermLimit, ttl: site.ShortTermTTL},
+		{term: "long", limit: site.LongTermLimit, ttl: site.LongTermTTL},
+	}
+}
+
+// RateLimitWindow 代理在一个限流窗口内的使用情况
+type RateLimitWindow struct {
+	Limit         int     `json:"limit"`          // 窗口内的使用上限，0表示不限制
+	Used          int     `json:"used"`           // 窗口内已使用次数
+	Remaining     int     `json:"remaining"`      // 窗口内剩余次数，不限制时为-1
+	WindowSeconds float64 `json:"window_seconds"` // 窗口时长
+	ResetSeconds  float64 `json:"reset_seconds"`  // 距窗口结束的时间，窗口内未使用时为0
+}
+
+// RateLimitQuota 代理在站点上的剩余使用次数
+type RateLimitQuota struct {
+	ProxyID   uint            `json:"proxy_id"`
+	Site      string          `json:"site"`
+	ShortTerm RateLimitWindow `json:"short_term"`
+	LongTerm  RateLimitWindow `json:"long_term"`
+}
+
+// Limited 获取在站点上已达到发放上限的代理ID
+func (l *SiteRateLimiter) Limited(site *siteconfig.SiteConfig) []uint {
+	var members []string
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	key := l.redis.Key(siteLimitKeyspace, site.Name)
+	err := l.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		if err := client.ZRemRangeByScore(ctx, key, "-inf", now).Err(); err != nil {
+			return err
+		}
+		var err error
+		members, err = client.ZRange(ctx, key, 0, -1).Result()
+		return err
+	})
+	if err != nil {
+		if err != ErrRedisDegraded {
+			l.logger.Debug("查询站点受限代理失败",
+				zap.String("站点", site.Name),
+				zap.Error(err),
+			)
+		}
+		return nil
+	}
+
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// Allow 记录代理在站点上的一次使用，返回是否未超过短期和长期限制
+// 依次累加短期和长期窗口的使用次数，超过某个窗口的上限时不再累加后面的窗口；窗口上限或时长非正时不限制该窗口
+// 达到上限的代理记入受限集合，在窗口结束前不会被 GetProxyForURL 调度；Redis不可用时不限制
+func (l *SiteRateLimiter) Allow(proxyID uint, site *siteconfig.SiteConfig) (bool, error) {
+	allowed := true
+	err := l.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		for _, window := range siteWindows(site) {
+			n, err := l.count(ctx, client, site, proxyID, window)
+			if err != nil {
+				return err
+			}
+			if n > int64(window.limit) {
+				allowed = false
+				return nil
+			}
+		}
+		return nil
+	})
+	if err == ErrRedisDegraded {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return allowed, nil
+}
+
+// Quota 获取代理在站点上各窗口的剩余使用次数，Redis不可用时返回 ErrRedisDegraded
+func (l *SiteRateLimiter) Quota(proxyID uint, site *siteconfig.SiteConfig) (*RateLimitQuota, error) {
+	quota := &RateLimitQuota{ProxyID: proxyID, Site: site.Name}
+	windows := []*RateLimitWindow{&quota.ShortTerm, &quota.LongTerm}
+	err := l.redis.Do(func(ctx context.Context, client *redis.Client) error {
+		for i, window := range siteWindows(site) {
+			result := windows[i]
+			result.Remaining = -1
+			if window.limit <= 0 || window.ttl <= 0 {
+				continue
+			}
+
+			key := l.redis.Key(site.GetRateLimitKey(proxyID, window.term))
+			used, err := client.Get(ctx, key).Int()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			ttl, err := client.PTTL(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+
+			result.Limit = window.limit
+			result.Used = used
+			result.Remaining = window.limit - used
+			if result.Remaining < 0 {
+				result.Remaining = 0
+			}
+			result.WindowSeconds = window.ttl.Seconds()
+			if ttl > 0 {
+				result.ResetSeconds = ttl.Seconds()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// count 累加代理在一个窗口内的使用次数并返回，达到上限时记入受限集合直到窗口结束，不限制的窗口返回0
+func (l *SiteRateLimiter) count(ctx context.Context, client *redis.Client, site *siteconfig.SiteConfig, proxyID uint, window siteWindow) (int64, error) {
+	if window.limit <= 0 || window.ttl <= 0 {
+		return 0, nil
+	}
+
+	key := l.redis.Key(site.GetRateLimitKey(proxyID, window.term))
+	n, err := client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		if err := client.Expire(ctx, key, window.ttl).Err(); err != nil {
+			return 0, err
+		}
+	}
+	if n < int64(window.limit) {
+		return n, nil
+	}
+
+	remaining, err := client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if remaining <= 0 {
+		remaining = window.ttl
+	}
+	// 受限集合保留到最长的窗口结束，避免短期窗口缩短长期受限代理的有效期
+	keep := site.LongTermTTL
+	if site.ShortTermTTL > keep {
+		keep = site.ShortTermTTL
+	}
+	limitedKey := l.redis.Key(siteLimitKeyspace, site.Name)
+	until := time.Now().Add(remaining)
+	pipe := client.TxPipeline()
+	pipe.ZAdd(ctx, limitedKey, &redis.Z{Score: float64(until.UnixNano()), Member: proxyID})
+	pipe.Expire(ctx, limitedKey, keep)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// RegisterSiteConfig 按域名注册 GetProxyForURL 使用的站点配置，cfg 为 nil 时取消注册
+func (p *ProxyPool) RegisterSiteConfig(domain string, cfg *siteconfig.SiteConfig) {
+	domain = models.NormalizeDomain(domain)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cfg == nil {
+		delete(p.sites, domain)
+		return
+	}
+	p.sites[domain] = cfg
+}
+
+// SiteConfig 获取域名注册的站点配置，未注册时返回 nil
+func (p *ProxyPool) SiteConfig(domain string) *siteconfig.SiteConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.sites[models.NormalizeDomain(domain)]
+}
+
+// siteTask 根据站点配置生成任务，未注册站点配置时使用临时代理和 weighted 策略
+// 站点配置的代理类型是代理池的代理类型时按类型调度，否则视为协议
+func siteTask(targetURL, domain string, site *siteconfig.SiteConfig) *Task {
+	task := &Task{
+		ProxyType: models.ProxyTypeTemp,
+		Strategy:  StrategyWeighted,
+		TargetURL: targetURL,
+		Domain:    domain,
+	}
+	if site == nil {
+		return task
+	}
+
+	if proxyType := models.ProxyType(site.ProxyType); proxyType.IsValid() {
+		task.ProxyType = proxyType
+	} else if site.ProxyType != "" {
+		task.Protocol = site.ProxyType
+	}
+	task.RequireAnon = site.RequireAnon
+	return task
+}
+
+// RateLimitProxy 记录代理在域名上的一次使用，返回是否未超过域名站点配置的使用上限
+// 域名没有注册站点配置时不限制
+func (p *ProxyPool) RateLimitProxy(ctx context.Context, proxyID uint, domain string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	site := p.SiteConfig(domain)
+	if site == nil {
+		return true, nil
+	}
+	return p.siteLimits.Allow(proxyID, site)
+}
+
+// RateLimitQuota 获取代理在域名上的剩余使用次数，域名没有注册站点配置时返回 ErrSiteNotRegistered
+func (p *ProxyPool) RateLimitQuota(proxyID uint, domain string) (*RateLimitQuota, error) {
+	site := p.SiteConfig(domain)
+	if site == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSiteNotRegistered, domain)
+	}
+	return p.siteLimits.Quota(proxyID, site)
+}
+
+// proxyReleaser 可释放已调度代理的调度器
+type proxyReleaser interface {
+	ReleaseProxy(proxyID uint)
+}
+
+// releaseProxy 释放为 task 调度但未交给调用方的代理，不计入使用结果，同时删除本次发放记录
+func (p *ProxyPool) releaseProxy(task *Task, proxyID uint) {
+	if task.HandoutID != "" {
+		p.handouts.Discard(task.HandoutID, task.Strategy)
+		task.HandoutID = ""
+	}
+	p.releaseBalancers(proxyID)
+	if releaser, ok := p.scheduler.(proxyReleaser); ok {
+		releaser.ReleaseProxy(proxyID)
+	}
+}
+
+// GetProxyForURL 获取访问 targetURL 使用的代理
+// 按目标域名注册的站点配置生成任务，并排除在该站点上已达到使用上限的代理；
+// 选出的代理超过使用上限时释放并换下一个代理，最多尝试 MaxRateLimitAttempts 个，均受限时返回 ErrProxyRateLimited
+func (p *ProxyPool) GetProxyForURL(ctx context.Context, targetURL string) (*models.Proxy, error) {
+	domain := extractDomain(targetURL)
+	if domain == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTargetURL, targetURL)
+	}
+
+	site := p.SiteConfig(domain)
+	task := siteTask(targetURL, domain, site)
+	if site == nil {
+		return p.GetProxyForTask(ctx, task)
+	}
+	task.ExcludeIDs = p.siteLimits.Limited(site)
+
+	for attempt := 1; attempt <= MaxRateLimitAttempts; attempt++ {
+		proxy, err := p.GetProxyForTask(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+
+		allowed, err := p.RateLimitProxy(ctx, proxy.ID, domain)
+		if err != nil {
+			p.releaseProxy(task, proxy.ID)
+			return nil, err
+		}
+		if allowed {
+			return proxy, nil
+		}
+
+		p.logger.Debug("代理在站点上已达到使用上限，换下一个代理",
+			zap.Uint("代理ID", proxy.ID),
+			zap.String("站点", site.Name),
+			zap.Int("第几次尝试", attempt),
+		)
+		p.releaseProxy(task, proxy.ID)
+		task.ExcludeIDs = append(task.ExcludeIDs, proxy.ID)
+	}
+	return nil, fmt.Errorf("%w: %s after %d attempts", ErrProxyRateLimited, domain, MaxRateLimitAttempts)
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	siteconfig "proxy_pool/core/config"
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+func TestSiteTask(t *testing.T) {
+	tests := []struct {
+		name         string
+		site         *siteconfig.SiteConfig
+		wantType     models.ProxyType
+		wantProtocol string
+		wantAnon     bool
+	}{
+		{"no site config", nil, models.ProxyTypeTemp, "", false},
+		{"proxy type", &siteconfig.SiteConfig{ProxyType: "long"}, models.ProxyTypeLong, "", false},
+		{"protocol", &siteconfig.SiteConfig{ProxyType: "socks5", RequireAnon: true}, models.ProxyTypeTemp, "socks5", true},
+	}
+	for _, tt := range tests {
+		task := siteTask("https://example.test/a", "example.test", tt.site)
+		if task.ProxyType != tt.wantType || task.Protocol != tt.wantProtocol || task.RequireAnon != tt.wantAnon ||
+			task.Strategy != StrategyWeighted || task.Domain != "example.test" || task.TargetURL != "https://example.test/a" {
+			t.Errorf("%s: task = %+v", tt.name, task)
+		}
+	}
+}
+
+func TestGetProxyForURLUsesSiteConfig(t *testing.T) {
+	pool, _ := newTestPool(t)
+	ctx := context.Background()
+	temp := newTestProxy(t, pool.DB(), "1.1.1.1")
+	long := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Type = models.ProxyTypeLong })
+
+	get := func(targetURL string) *models.Proxy {
+		t.Helper()
+		proxy, err := pool.GetProxyForURL(ctx, targetURL)
+		if err != nil {
+			t.Fatalf("GetProxyForURL(%s): %v", targetURL, err)
+		}
+		pool.releaseProxy(&Task{}, proxy.ID)
+		return proxy
+	}
+
+	if _, err := pool.GetProxyForURL(ctx, "not a url"); !errors.Is(err, ErrInvalidTargetURL) {
+		t.Errorf("invalid url error = %v, want %v", err, ErrInvalidTargetURL)
+	}
+	// 未注册站点配置的域名使用临时代理
+	if got := get("https://unknown.test/"); got.ID != temp.ID {
+		t.Errorf("unregistered domain got proxy %d, want temp proxy %d", got.ID, temp.ID)
+	}
+
+	pool.RegisterSiteConfig("Shop.Test", &siteconfig.SiteConfig{Name: "shop", ProxyType: "long"})
+	if got := get("https://shop.test/item"); got.ID != long.ID {
+		t.Errorf("long site got proxy %d, want %d", got.ID, long.ID)
+	}
+	anon := newTestProxy(t, pool.DB(), "3.3.3.3", func(p *models.Proxy) {
+		p.Type = models.ProxyTypeLong
+		p.Anonymous = true
+	})
+	pool.RegisterSiteConfig("shop.test", &siteconfig.SiteConfig{Name: "shop", ProxyType: "long", RequireAnon: true})
+	if got := get("https://shop.test/item"); got.ID != anon.ID {
+		t.Errorf("anonymous site got proxy %d, want %d", got.ID, anon.ID)
+	}
+
+	pool.RegisterSiteConfig("shop.test", nil)
+	if pool.SiteConfig("shop.test") != nil {
+		t.Error("site config still registered after unregistering")
+	}
+}
+
+func TestGetProxyForURLExcludesLimitedProxies(t *testing.T) {
+	pool, _ := newTestPool(t)
+	ctx := context.Background()
+	site := &siteconfig.SiteConfig{Name: "shop", LongTermLimit: 2, LongTermTTL: time.Hour}
+	pool.RegisterSiteConfig("shop.test", site)
+	first := newTestProxy(t, pool.DB(), "1.1.1.1")
+
+	get := func() uint {
+		t.Helper()
+		proxy, err := pool.GetProxyForURL(ctx, "https://shop.test/")
+		if err != nil {
+			t.Fatalf("GetProxyForURL: %v", err)
+		}
+		pool.releaseProxy(&Task{}, proxy.ID)
+		return proxy.ID
+	}
+	for i := 0; i < 2; i++ {
+		if id := get(); id != first.ID {
+			t.Fatalf("handout %d = %d, want %d", i+1, id, first.ID)
+		}
+	}
+	// 用完额度后记入受限集合，之后调度时直接排除
+	if limited := pool.siteLimits.Limited(site); len(limited) != 1 || limited[0] != first.ID {
+		t.Errorf("limited = %v, want [%d]", limited, first.ID)
+	}
+	second := newTestProxy(t, pool.DB(), "2.2.2.2")
+	if id := get(); id != second.ID {
+		t.Errorf("handout after the limit = %d, want %d", id, second.ID)
+	}
+}
+
+func TestRateLimitProxy(t *testing.T) {
+	pool, _ := newTestPool(t)
+	ctx := context.Background()
+	site := &siteconfig.SiteConfig{
+		Name:           "shop",
+		ShortTermLimit: 2,
+		ShortTermTTL:   time.Hour,
+		LongTermLimit:  3,
+		LongTermTTL:    2 * time.Hour,
+	}
+	pool.RegisterSiteConfig("shop.test", site)
+
+	for i, want := range []bool{true, true, false} {
+		if allowed, err := pool.RateLimitProxy(ctx, 1, "shop.test"); err != nil || allowed != want {
+			t.Errorf("use %d: allowed = %v, %v, want %v", i+1, allowed, err, want)
+		}
+	}
+	// 超过短期上限后不再累加长期窗口
+	quota, err := pool.RateLimitQuota(1, "shop.test")
+	if err != nil {
+		t.Fatalf("RateLimitQuota: %v", err)
+	}
+	if quota.ShortTerm.Used != 3 || quota.ShortTerm.Remaining != 0 || quota.ShortTerm.ResetSeconds <= 0 ||
+		quota.LongTerm.Used != 2 || quota.LongTerm.Remaining != 1 || quota.LongTerm.WindowSeconds != 7200 {
+		t.Errorf("quota = %+v", quota)
+	}
+	if other, err := pool.RateLimitQuota(2, "shop.test"); err != nil || other.ShortTerm.Used != 0 || other.ShortTerm.Remaining != 2 {
+		t.Errorf("unused proxy quota = %+v, %v, want the full limit", other, err)
+	}
+
+	// 未注册站点配置的域名不限制
+	if allowed, err := pool.RateLimitProxy(ctx, 1, "other.test"); err != nil || !allowed {
+		t.Errorf("unregistered domain allowed = %v, %v, want true", allowed, err)
+	}
+	if _, err := pool.RateLimitQuota(1, "other.test"); !errors.Is(err, ErrSiteNotRegistered) {
+		t.Errorf("unregistered domain quota error = %v, want %v", err, ErrSiteNotRegistered)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := pool.RateLimitProxy(cancelled, 1, "shop.test"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	// Redis不可用时不限制
+	degraded := NewSiteRateLimiter(NewRedisGuard(nil, zap.NewNop()), zap.NewNop())
+	if allowed, err := degraded.Allow(1, site); err != nil || !allowed {
+		t.Errorf("degraded Allow = %v, %v, want true", allowed, err)
+	}
+	if _, err := degraded.Quota(1, site); !errors.Is(err, ErrRedisDegraded) {
+		t.Errorf("degraded Quota error = %v, want %v", err, ErrRedisDegraded)
+	}
+}
+
+func TestGetProxyForURLRateLimitedAfterAttempts(t *testing.T) {
+	pool, mr := newTestPool(t)
+	site := &siteconfig.SiteConfig{Name: "shop", LongTermLimit: 1, LongTermTTL: time.Hour}
+	pool.RegisterSiteConfig("shop.test", site)
+	for i := 1; i <= MaxRateLimitAttempts+1; i++ {
+		proxy := newTestProxy(t, pool.DB(), fmt.Sprintf("20.0.0.%d", i))
+		// 额度已用完但还没有记入受限集合，只能在调度后发现
+		mr.Set(pool.RedisGuard().Key(site.GetRateLimitKey(proxy.ID, "long")), "1")
+	}
+
+	_, err := pool.GetProxyForURL(context.Background(), "https://shop.test/")
+	if !errors.Is(err, ErrProxyRateLimited) {
+		t.Fatalf("GetProxyForURL error = %v, want %v", err, ErrProxyRateLimited)
+	}
+	// 每个被拒绝的代理都已释放
+	var ids []uint
+	pool.DB().Model(&models.Proxy{}).Pluck("id", &ids)
+	for _, id := range ids {
+		if n := pool.GetLiveConcurrentUse(id); n != 0 {
+			t.Errorf("proxy %d live concurrent use = %d, want 0", id, n)
+		}
+	}
+	if limited := pool.siteLimits.Limited(site); len(limited) != MaxRateLimitAttempts {
+		t.Errorf("limited = %v, want the %d tried proxies", limited, MaxRateLimitAttempts)
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SourceHealth 代理源健康状态
+type SourceHealth struct {
+	Name                string    `json:"name"`                 // 代理源名称
+	LastSuccessTime     time.Time `json:"last_success_time"`    // 最后一次成功获取时间
+	LastFailureTime     time.Time `json:"last_failure_time"`    // 最后一次失败时间
+	LastError           string    `json:"last_error"`           // 最后一次错误信息
+	SuccessCount        int       `json:"success_count"`        // 成功次数
+	FailureCount        int       `json:"failure_count"`        // 失败次数
+	ConsecutiveFailures int       `json:"consecutive_failures"` // 连续失败次数
+
+	LastFetched   int `json:"last_fetched"`   // 最近一次获取到的代理数
+	LastInserted  int `json:"last_inserted"`  // 最近一次新增的代理数
+	TotalFetched  int `json:"total_fetched"`  // 累计获取到的代理数
+	TotalInserted int `json:"total_inserted"` // 累计新增的代理数
+}
+
+// sourceHealthTracker 记录各代理源的健康状态
+type sourceHealthTracker struct {
+	mu     sync.RWMutex
+	health map[string]*SourceHealth
+}
+
+func newSourceHealthTracker() *sourceHealthTracker {
+	return &sourceHealthTracker{
+		health: make(map[string]*SourceHealth),
+	}
+}
+
+// get 获取代理源状态，不存在时创建，调用方需持有 t.mu
+func (t *sourceHealthTracker) get(name string) *SourceHealth {
+	h, ok := t.health[name]
+	if !ok {
+		h = &SourceHealth{Name: name}
+		t.health[name] = h
+	}
+	return h
+}
+
+// recordSuccess 记录一次成功获取
+func (t *sourceHealthTracker) recordSuccess(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(name)
+	h.LastSuccessTime = time.Now()
+	h.SuccessCount++
+	h.ConsecutiveFailures = 0
+}
+
+// recordFailure 记录一次失败获取
+func (t *sourceHealthTracker) recordFailure(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(name)
+	h.LastFailureTime = time.Now()
+	h.LastError = err.Error()
+	h.FailureCount++
+	h.ConsecutiveFailures++
+}
+
+// recordFetch 记录一次获取的代理数统计，获取失败时只累计不覆盖最近一次的数量
+func (t *sourceHealthTracker) recordFetch(result SourceFetchResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(result.Source)
+	if result.Error == "" {
+		h.LastFetched = result.Fetched
+		h.LastInserted = result.Inserted
+	}
+	h.TotalFetched += result.Fetched
+	h.TotalInserted += result.Inserted
+}
+
+// snapshot 获取指定代理源状态的副本
+func (t *sourceHealthTracker) snapshot(name string) SourceHealth {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if h, ok := t.health[name]; ok {
+		return *h
+	}
+	return SourceHealth{Name: name}
+}
+
+// all 获取所有代理源状态的副本，按名称排序
+func (t *sourceHealthTracker) all() []SourceHealth {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]SourceHealth, 0, len(t.health))
+	for _, h := range t.health {
+		result = append(result, *h)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
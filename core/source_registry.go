@@ -0,0 +1,107 @@
+package core
+
+import (
+	"proxy_pool/models"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SourceStatus 代理源记录及健康状态
+type SourceStatus struct {
+	models.ProxySource
+	Health SourceHealth `json:"health"`
+}
+
+// SourceRegistry 代理源注册表，记录配置中的代理源、运行时启用状态和获取统计
+// 保存在 sources 表中，重启后保留；获取器在每次获取某个代理源前检查启用状态，获取后记录统计
+type SourceRegistry struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+// NewSourceRegistry 创建代理源注册表
+func NewSourceRegistry(db *gorm.DB, logger *zap.Logger) *SourceRegistry {
+	return &SourceRegistry{db: db, logger: logger}
+}
+
+// Seed 写入配置中的代理源，新代理源默认启用，已存在的代理源保留原有的启用状态和获取统计
+func (r *SourceRegistry) Seed(sources []models.ProxySource) error {
+	for i := range sources {
+		sources[i].Enabled = true
+	}
+	return models.SeedSources(r.db, sources)
+}
+
+// Enabled 代理源是否启用，查询失败时视为启用，避免数据库故障时停止获取代理
+func (r *SourceRegistry) Enabled(name string) bool {
+	enabled, err := models.SourceEnabled(r.db, name)
+	if err != nil {
+		r.logger.Warn("查询代理源启用状态失败，按启用处理", zap.String("来源", name), zap.Error(err))
+		return true
+	}
+	return enabled
+}
+
+// List 获取所有代理源
+func (r *SourceRegistry) List() ([]models.ProxySource, error) {
+	return models.ListSources(r.db)
+}
+
+// Get 获取代理源，不存在时返回 models.ErrSourceNotFound
+func (r *SourceRegistry) Get(name string) (*models.ProxySource, error) {
+	return models.FindSource(r.db, name)
+}
+
+// SetEnabled 启用或停用代理源，代理源不存在时返回 models.ErrSourceNotFound
+func (r *SourceRegistry) SetEnabled(name string, enabled bool) (*models.ProxySource, error) {
+	source, err := models.SetSourceEnabled(r.db, name, enabled)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Info("代理源启用状态已修改", zap.String("来源", name), zap.Bool("启用", enabled))
+	return source, nil
+}
+
+// RecordFetch 记录代理源的一次获取，记录失败只记录日志，不影响获取结果
+func (r *SourceRegistry) RecordFetch(name string, fetched int) {
+	err := models.RecordSourceFetch(r.db, name, models.SourceTypeOf(name), int64(fetched), time.Now())
+	if err != nil {
+		r.logger.Warn("记录代理源获取统计失败", zap.String("来源", name), zap.Error(err))
+	}
+}
+
+// SourcePurge 停用代理源时对其已有代理的处理方式
+type SourcePurge string
+
+const (
+	SourcePurgeNone    SourcePurge = ""        // 保留已有代理
+	SourcePurgeDisable SourcePurge = "disable" // 标记为不可用
+	SourcePurgeDelete  SourcePurge = "delete"  // 删除
+)
+
+// PurgeSource 按处理方式清理来源的已有代理，返回受影响的代理数
+func (p *ProxyPool) PurgeSource(source string, purge SourcePurge) (int64, error) {
+	switch purge {
+	case SourcePurgeDisable:
+		affected, err := models.DisableSourceProxies(p.db, source)
+		if err != nil {
+			return 0, err
+		}
+		p.logger.Info("停用代理源，已有代理标记为不可用", zap.String("来源", source), zap.Int64("代理数", affected))
+		return affected, nil
+	case SourcePurgeDelete:
+		removed, err := models.DeleteSourceProxies(p.db, source, "source_disabled")
+		if err != nil {
+			return 0, err
+		}
+		for _, proxy := range removed {
+			p.events.Publish(NewProxyEvent(EventProxyRemoved, proxy))
+		}
+		p.logger.Info("停用代理源，已删除已有代理", zap.String("来源", source), zap.Int("代理数", len(removed)))
+		return int64(len(removed)), nil
+	default:
+		return 0, nil
+	}
+}
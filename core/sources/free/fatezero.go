@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"proxy_pool/models"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	"gorm.io/gorm"
 )
 
+const fateZeroURL = "http://proxylist.fatezero.org/proxy.list"
+
 // FateZeroSource FateZero代理源
 type FateZeroSource struct {
 	*BaseSource
@@ -32,15 +35,44 @@ func (s *FateZeroSource) Name() string {
 	return "fatezero"
 }
 
+// SupportsIncremental FateZero支持基于 If-Modified-Since 的增量获取
+func (s *FateZeroSource) SupportsIncremental() bool {
+	return true
+}
+
 // FetchProxies 获取代理列表
 func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
-	url := "http://proxylist.fatezero.org/proxy.list"
-
 	s.logger.Info("开始获取FateZero代理",
-		zap.String("URL", url),
+		zap.String("URL", fateZeroURL),
+	)
+
+	resp, err := s.get(s.client, fateZeroURL)
+	if err != nil {
+		s.logger.Error("请求API失败",
+			zap.String("错误", err.Error()),
+		)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return s.handleResponse(resp, time.Time{})
+}
+
+// FetchIncremental 增量获取代理列表
+// 服务端返回304时说明列表未变化，返回空列表；否则只返回 since 之后更新的代理
+func (s *FateZeroSource) FetchIncremental(since time.Time) ([]*models.Proxy, error) {
+	s.logger.Info("开始增量获取FateZero代理",
+		zap.String("URL", fateZeroURL),
+		zap.Time("上次成功时间", since),
 	)
 
-	resp, err := s.client.Get(url)
+	req, err := http.NewRequest(http.MethodGet, fateZeroURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+
+	resp, err := s.do(s.client, req)
 	if err != nil {
 		s.logger.Error("请求API失败",
 			zap.String("错误", err.Error()),
@@ -49,6 +81,16 @@ func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		s.logger.Info("FateZero代理列表未变化，跳过解析")
+		return []*models.Proxy{}, nil
+	}
+
+	return s.handleResponse(resp, since)
+}
+
+// handleResponse 读取并解析响应，保存解析出的代理
+func (s *FateZeroSource) handleResponse(resp *http.Response, since time.Time) ([]*models.Proxy, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		s.logger.Error("读取响应失败",
@@ -61,6 +103,26 @@ func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
 		zap.Int("内容长度", len(body)),
 	)
 
+	proxies := s.parseList(body, since)
+
+	// 保存代理
+	if err := s.SaveProxies(proxies); err != nil {
+		s.logger.Error("保存代理失败",
+			zap.String("来源", s.Name()),
+			zap.String("错误", err.Error()),
+		)
+		return nil, err
+	}
+
+	s.logger.Info("FateZero代理获取完成",
+		zap.Int("总数量", len(proxies)),
+	)
+
+	return proxies, nil
+}
+
+// parseList 解析代理列表，since 非零时跳过在此之前更新的条目
+func (s *FateZeroSource) parseList(body []byte, since time.Time) []*models.Proxy {
 	var proxies []*models.Proxy
 
 	// FateZero的数据格式是每行一个JSON对象
@@ -71,6 +133,7 @@ func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
 
 	successCount := 0
 	errorCount := 0
+	skipCount := 0
 
 	for _, line := range lines {
 		if line == "" {
@@ -78,13 +141,14 @@ func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
 		}
 
 		var data struct {
-			Host     string  `json:"host"`
-			Port     int     `json:"port"`
-			Type     string  `json:"type"`
-			Protocol string  `json:"protocol"`
-			Country  string  `json:"country"`
-			Response float64 `json:"response_time"`
-			Level    string  `json:"anonymity"`
+			Host      string          `json:"host"`
+			Port      int             `json:"port"`
+			Type      string          `json:"type"`
+			Protocol  string          `json:"protocol"`
+			Country   string          `json:"country"`
+			Response  float64         `json:"response_time"`
+			Level     string          `json:"anonymity"`
+			UpdatedAt json.RawMessage `json:"updated_at"`
 		}
 
 		if err := json.Unmarshal([]byte(line), &data); err != nil {
@@ -96,6 +160,14 @@ func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
 			continue
 		}
 
+		// 增量获取时跳过未更新的条目
+		if !since.IsZero() {
+			if updatedAt, ok := parseUpdatedAt(data.UpdatedAt); ok && !updatedAt.After(since) {
+				skipCount++
+				continue
+			}
+		}
+
 		proxyType := models.ProxyTypeTemp
 		if strings.Contains(strings.ToLower(data.Level), "high") {
 			proxyType = models.ProxyTypeHighAnon
@@ -120,20 +192,28 @@ func (s *FateZeroSource) FetchProxies() ([]*models.Proxy, error) {
 	s.logger.Info("代理解析完成",
 		zap.Int("成功数量", successCount),
 		zap.Int("失败数量", errorCount),
+		zap.Int("未更新数量", skipCount),
 	)
 
-	// 保存代理
-	if err := s.SaveProxies(proxies); err != nil {
-		s.logger.Error("保存代理失败",
-			zap.String("来源", s.Name()),
-			zap.String("错误", err.Error()),
-		)
-		return nil, err
+	return proxies
+}
+
+// parseUpdatedAt 解析更新时间，兼容Unix时间戳和RFC3339字符串
+func parseUpdatedAt(raw json.RawMessage) (time.Time, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, false
 	}
 
-	s.logger.Info("FateZero代理获取完成",
-		zap.Int("总数量", len(proxies)),
-	)
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if t, err := time.Parse(time.RFC3339, text); err == nil {
+			return t, true
+		}
+		raw = json.RawMessage(text)
+	}
 
-	return proxies, nil
+	if seconds, err := strconv.ParseFloat(string(raw), 64); err == nil {
+		return time.Unix(int64(seconds), 0), true
+	}
+	return time.Time{}, false
 }
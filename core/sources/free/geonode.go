@@ -0,0 +1,180 @@
+package free
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"proxy_pool/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	geoNodeURL             = "https://proxylist.geonode.com/api/proxy-list"
+	geoNodePageSize        = 100
+	DefaultGeoNodeMaxPages = 5 // 默认最多获取的页数
+)
+
+// GeoNodeSource GeoNode代理源，按最后检查时间倒序分页获取
+type GeoNodeSource struct {
+	*BaseSource
+	client   *http.Client
+	baseURL  string
+	maxPages int
+}
+
+// NewGeoNodeSource 创建GeoNode代理源，maxPages 非正时使用 DefaultGeoNodeMaxPages
+func NewGeoNodeSource(db *gorm.DB, logger *zap.Logger, maxPages int) *GeoNodeSource {
+	if maxPages <= 0 {
+		maxPages = DefaultGeoNodeMaxPages
+	}
+	return &GeoNodeSource{
+		BaseSource: NewBaseSource(db, logger),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		baseURL:  geoNodeURL,
+		maxPages: maxPages,
+	}
+}
+
+func (s *GeoNodeSource) Name() string {
+	return "geonode"
+}
+
+// SupportedProtocols GeoNode同时提供HTTP和SOCKS代理
+func (s *GeoNodeSource) SupportedProtocols() []string {
+	return []string{"http", "https", "socks4", "socks5"}
+}
+
+// geoNodeProxy GeoNode返回的单个代理
+type geoNodeProxy struct {
+	IP             string   `json:"ip"`
+	Port           string   `json:"port"`
+	Protocols      []string `json:"protocols"`
+	AnonymityLevel string   `json:"anonymityLevel"`
+	Country        string   `json:"country"`
+}
+
+// FetchProxies 逐页获取代理列表，某页没有数据或达到最大页数时停止
+func (s *GeoNodeSource) FetchProxies() ([]*models.Proxy, error) {
+	s.logger.Info("开始获取GeoNode代理",
+		zap.Int("最大页数", s.maxPages),
+	)
+
+	var allProxies []*models.Proxy
+	for page := 1; page <= s.maxPages; page++ {
+		items, err := s.fetchPage(page)
+		if err != nil {
+			s.logger.Error("页面获取失败",
+				zap.Int("页码", page),
+				zap.String("错误", err.Error()),
+			)
+			if page == 1 {
+				return nil, err
+			}
+			break
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		proxies := s.parseItems(items)
+		s.logger.Info("页面获取成功",
+			zap.Int("页码", page),
+			zap.Int("代理数量", len(proxies)),
+		)
+		allProxies = append(allProxies, proxies...)
+	}
+
+	// 保存代理
+	if err := s.SaveProxies(allProxies); err != nil {
+		s.logger.Error("保存代理失败",
+			zap.String("来源", s.Name()),
+			zap.String("错误", err.Error()),
+		)
+		return nil, err
+	}
+
+	s.logger.Info("GeoNode代理获取完成",
+		zap.Int("总数量", len(allProxies)),
+	)
+
+	return allProxies, nil
+}
+
+// fetchPage 获取一页代理
+func (s *GeoNodeSource) fetchPage(page int) ([]geoNodeProxy, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(geoNodePageSize))
+	query.Set("page", strconv.Itoa(page))
+	query.Set("sort_by", "lastChecked")
+	query.Set("sort_type", "desc")
+
+	resp, err := s.get(s.client, s.baseURL+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data []geoNodeProxy `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Data, nil
+}
+
+// parseItems 将GeoNode的代理转换为代理模型，跳过端口不合法的条目
+func (s *GeoNodeSource) parseItems(items []geoNodeProxy) []*models.Proxy {
+	var proxies []*models.Proxy
+	for _, item := range items {
+		port, err := strconv.Atoi(item.Port)
+		if err != nil {
+			s.logger.Warn("端口解析失败",
+				zap.String("IP", item.IP),
+				zap.String("端口", item.Port),
+			)
+			continue
+		}
+
+		proxyType := models.ProxyTypeTemp
+		switch strings.ToLower(item.AnonymityLevel) {
+		case "elite":
+			proxyType = models.ProxyTypeHighAnon
+		case "anonymous":
+			proxyType = models.ProxyTypeAnon
+		}
+
+		protocol := "http"
+		if len(item.Protocols) > 0 {
+			protocol = strings.ToLower(item.Protocols[0])
+		}
+
+		region := models.ProxyRegionOther
+		if strings.EqualFold(item.Country, "CN") {
+			region = models.ProxyRegionCN
+		}
+
+		proxies = append(proxies, &models.Proxy{
+			IP:        item.IP,
+			Port:      port,
+			Type:      proxyType,
+			Protocol:  protocol,
+			Region:    region,
+			Source:    s.Name(),
+			Anonymous: proxyType != models.ProxyTypeTemp,
+		})
+	}
+	return proxies
+}
@@ -0,0 +1,128 @@
+package free
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"proxy_pool/models"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// geoNodePages 各页返回的代理，超出范围的页返回空列表
+var geoNodePages = []string{
+	`[{"ip":"20.0.0.1","port":"8080","protocols":["HTTP"],"anonymityLevel":"elite","country":"CN"},
+	  {"ip":"20.0.0.2","port":"1080","protocols":["socks5"],"anonymityLevel":"anonymous","country":"US"}]`,
+	`[{"ip":"20.0.0.3","port":"3128","protocols":[],"anonymityLevel":"transparent","country":"DE"},
+	  {"ip":"20.0.0.4","port":"bad","protocols":["http"],"anonymityLevel":"elite","country":"US"}]`,
+}
+
+// newGeoNodeServer 按 page 参数返回 geoNodePages 的测试服务器，记录请求次数
+func newGeoNodeServer(t *testing.T, requests *int64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		data := "[]"
+		if page >= 1 && page <= len(geoNodePages) {
+			data = geoNodePages[page-1]
+		}
+		fmt.Fprintf(w, `{"data":%s}`, data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// 内存数据库每个连接各自独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := models.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func newTestGeoNodeSource(t *testing.T, db *gorm.DB, url string, maxPages int) *GeoNodeSource {
+	t.Helper()
+	s := NewGeoNodeSource(db, zap.NewNop(), maxPages)
+	s.baseURL = url
+	s.SetPoliteness(Politeness{MinDelay: time.Millisecond})
+	return s
+}
+
+func TestGeoNodeSourceFetchesPagesUntilEmpty(t *testing.T) {
+	var requests int64
+	srv := newGeoNodeServer(t, &requests)
+	db := newTestDB(t)
+
+	proxies, err := newTestGeoNodeSource(t, db, srv.URL, 0).FetchProxies()
+	if err != nil {
+		t.Fatalf("FetchProxies: %v", err)
+	}
+	// 第3页为空后停止，端口不合法的条目被跳过
+	if got := atomic.LoadInt64(&requests); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+
+	type fields struct {
+		Port      int
+		Type      models.ProxyType
+		Protocol  string
+		Region    models.ProxyRegion
+		Anonymous bool
+	}
+	want := map[string]fields{
+		"20.0.0.1": {Port: 8080, Type: models.ProxyTypeHighAnon, Protocol: "http", Region: models.ProxyRegionCN, Anonymous: true},
+		"20.0.0.2": {Port: 1080, Type: models.ProxyTypeAnon, Protocol: "socks5", Region: models.ProxyRegionOther, Anonymous: true},
+		"20.0.0.3": {Port: 3128, Type: models.ProxyTypeTemp, Protocol: "http", Region: models.ProxyRegionOther},
+	}
+	if len(proxies) != len(want) {
+		t.Fatalf("got %d proxies, want %d", len(proxies), len(want))
+	}
+	for _, p := range proxies {
+		w, ok := want[p.IP]
+		if !ok {
+			t.Errorf("unexpected proxy %s", p.IP)
+			continue
+		}
+		if got := (fields{p.Port, p.Type, p.Protocol, p.Region, p.Anonymous}); got != w || p.Source != "geonode" {
+			t.Errorf("proxy %s from %s = %+v, want %+v from geonode", p.IP, p.Source, got, w)
+		}
+	}
+
+	var saved int64
+	db.Model(&models.Proxy{}).Where("source = ?", "geonode").Count(&saved)
+	if saved != int64(len(want)) {
+		t.Errorf("saved %d proxies, want %d", saved, len(want))
+	}
+}
+
+func TestGeoNodeSourceMaxPages(t *testing.T) {
+	var requests int64
+	srv := newGeoNodeServer(t, &requests)
+
+	proxies, err := newTestGeoNodeSource(t, newTestDB(t), srv.URL, 1).FetchProxies()
+	if err != nil {
+		t.Fatalf("FetchProxies: %v", err)
+	}
+	if got := atomic.LoadInt64(&requests); got != 1 || len(proxies) != 2 {
+		t.Errorf("requests = %d, proxies = %d, want only the first page", got, len(proxies))
+	}
+}
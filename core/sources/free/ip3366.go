@@ -82,7 +82,7 @@ func (s *IP3366Source) FetchProxies() ([]*models.Proxy, error) {
 }
 
 func (s *IP3366Source) fetchFromURL(url string) ([]*models.Proxy, error) {
-	resp, err := s.client.Get(url)
+	resp, err := s.get(s.client, url)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,191 @@
+package free
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultPoliteDelay   = 2 * time.Second // 默认同一主机两次请求的最小间隔
+	DefaultPoliteJitter  = time.Second     // 默认在最小间隔上随机增加的最大延迟
+	DefaultMaxRetryAfter = time.Minute     // 默认 Retry-After 和退避等待的上限
+	DefaultPoliteRetries = 2               // 默认遇到429/503时的最多重试次数
+)
+
+// Politeness 代理源的礼貌抓取配置，零值字段使用默认值
+type Politeness struct {
+	MinDelay      time.Duration // 同一主机两次请求的最小间隔
+	Jitter        time.Duration // 在最小间隔上随机增加的最大延迟
+	MaxConcurrent int           // 同时进行的请求数上限，默认1
+	MaxRetryAfter time.Duration // Retry-After 和退避等待的上限
+	MaxRetries    int           // 遇到429/503时的最多重试次数，负数表示不重试
+}
+
+// withDefaults 补全未配置的字段
+func (p Politeness) withDefaults() Politeness {
+	if p.MinDelay <= 0 {
+		p.MinDelay = DefaultPoliteDelay
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.MaxConcurrent <= 0 {
+		p.MaxConcurrent = 1
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+	if p.MaxRetries == 0 {
+		p.MaxRetries = DefaultPoliteRetries
+	} else if p.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	return p
+}
+
+// DefaultPoliteness 默认的礼貌抓取配置
+func DefaultPoliteness() Politeness {
+	return Politeness{Jitter: DefaultPoliteJitter}.withDefaults()
+}
+
+// hostLimiter 按主机控制请求间隔和并发数
+type hostLimiter struct {
+	politeness Politeness
+	sem        chan struct{}
+
+	mu   sync.Mutex
+	next map[string]time.Time // 各主机允许下一次请求的最早时间
+}
+
+func newHostLimiter(p Politeness) *hostLimiter {
+	p = p.withDefaults()
+	return &hostLimiter{
+		politeness: p,
+		sem:        make(chan struct{}, p.MaxConcurrent),
+		next:       make(map[string]time.Time),
+	}
+}
+
+// reserve 预约主机的下一次请求，返回需要等待的时长
+func (l *hostLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	delay := l.politeness.MinDelay
+	if l.politeness.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(l.politeness.Jitter) + 1))
+	}
+	l.next[host] = at.Add(delay)
+	return at.Sub(now)
+}
+
+// backoff 主机要求等待 d 后再请求
+func (l *hostLimiter) backoff(host string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if at := time.Now().Add(d); at.After(l.next[host]) {
+		l.next[host] = at
+	}
+}
+
+// retryDelay 计算第 attempt 次重试前的等待时长，优先使用 Retry-After，否则按最小间隔指数退避
+func (l *hostLimiter) retryDelay(resp *http.Response, attempt int) time.Duration {
+	delay := l.politeness.MinDelay << attempt
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			delay = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(v); err == nil {
+			delay = time.Until(at)
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > l.politeness.MaxRetryAfter {
+		delay = l.politeness.MaxRetryAfter
+	}
+	return delay
+}
+
+// sleep 等待 d，ctx 取消时提前返回
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetPoliteness 设置代理源的礼貌抓取配置，需在获取代理前设置
+func (s *BaseSource) SetPoliteness(p Politeness) {
+	s.limiter = newHostLimiter(p)
+}
+
+// get 按礼貌抓取配置发送 GET 请求
+func (s *BaseSource) get(client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(client, req)
+}
+
+// do 按礼貌抓取配置发送请求：同一主机的请求间隔不小于最小间隔，并发数不超过上限，
+// 遇到429/503时按 Retry-After 或指数退避等待后重试；只适用于没有请求体的请求
+func (s *BaseSource) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	l := s.limiter
+	ctx := req.Context()
+
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-l.sem }()
+
+	host := req.URL.Host
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, l.reserve(host)); err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req.Clone(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, nil
+		}
+		if attempt >= l.politeness.MaxRetries {
+			return resp, nil
+		}
+
+		delay := l.retryDelay(resp, attempt)
+		resp.Body.Close()
+		l.backoff(host, delay)
+		s.logger.Warn("代理源限流，等待后重试",
+			zap.String("主机", host),
+			zap.Int("状态码", resp.StatusCode),
+			zap.Duration("等待时间", delay),
+			zap.Int("第几次重试", attempt+1),
+		)
+	}
+}
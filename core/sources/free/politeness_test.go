@@ -0,0 +1,92 @@
+package free
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recordingServer 记录每次请求到达时间的测试服务器，前 throttled 次请求返回429
+func recordingServer(t *testing.T, throttled int) (*httptest.Server, func() []time.Time) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		n := len(arrivals)
+		mu.Unlock()
+		if n <= throttled {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), arrivals...)
+	}
+}
+
+func TestPolitenessSpacesRequests(t *testing.T) {
+	const minDelay = 50 * time.Millisecond
+	srv, arrivals := recordingServer(t, 0)
+
+	s := NewBaseSource(nil, zap.NewNop())
+	s.SetPoliteness(Politeness{MinDelay: minDelay, MaxConcurrent: 4})
+
+	// 并发请求同一主机也按最小间隔依次发出
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.get(srv.Client(), srv.URL)
+			if err != nil {
+				t.Errorf("get: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	got := arrivals()
+	if len(got) != 4 {
+		t.Fatalf("requests = %d, want 4", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		// 到达时间与预约时间有少量误差
+		if gap := got[i].Sub(got[i-1]); gap < minDelay-5*time.Millisecond {
+			t.Errorf("gap between request %d and %d = %v, want at least %v", i-1, i, gap, minDelay)
+		}
+	}
+}
+
+func TestPolitenessRetriesThrottled(t *testing.T) {
+	srv, arrivals := recordingServer(t, 2)
+
+	s := NewBaseSource(nil, zap.NewNop())
+	s.SetPoliteness(Politeness{MinDelay: time.Millisecond, MaxRetries: 2})
+
+	resp, err := s.get(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d after retries", resp.StatusCode, http.StatusOK)
+	}
+	if n := len(arrivals()); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
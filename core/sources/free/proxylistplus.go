@@ -29,6 +29,11 @@ func NewProxyListPlusSource(db *gorm.DB, logger *zap.Logger) *ProxyListPlusSourc
 	}
 }
 
+// SupportedProtocols ProxyListPlus只提供HTTP代理
+func (s *ProxyListPlusSource) SupportedProtocols() []string {
+	return []string{"http"}
+}
+
 func (s *ProxyListPlusSource) Name() string {
 	return "proxylistplus"
 }
@@ -82,7 +87,7 @@ func (s *ProxyListPlusSource) FetchProxies() ([]*models.Proxy, error) {
 }
 
 func (s *ProxyListPlusSource) fetchFromURL(url string) ([]*models.Proxy, error) {
-	resp, err := s.client.Get(url)
+	resp, err := s.get(s.client, url)
 	if err != nil {
 		return nil, err
 	}
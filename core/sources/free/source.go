@@ -2,6 +2,8 @@ package free
 
 import (
 	"proxy_pool/models"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -11,23 +13,60 @@ import (
 type Source interface {
 	Name() string
 	FetchProxies() ([]*models.Proxy, error)
+	SupportsIncremental() bool    // 是否支持增量获取
+	SupportedProtocols() []string // 可提供的代理协议
+}
+
+// IncrementalSource 支持增量获取的代理源
+type IncrementalSource interface {
+	Source
+	FetchIncremental(since time.Time) ([]*models.Proxy, error) // 获取 since 之后更新的代理
 }
 
 // BaseSource 基础代理源实现
+// 请求代理源网站时按礼貌抓取配置控制请求间隔、并发数和限流重试
 type BaseSource struct {
-	db     *gorm.DB
-	logger *zap.Logger
+	db      *gorm.DB
+	logger  *zap.Logger
+	limiter *hostLimiter
 }
 
-// NewBaseSource 创建基础代理源
+// NewBaseSource 创建基础代理源，使用默认的礼貌抓取配置
 func NewBaseSource(db *gorm.DB, logger *zap.Logger) *BaseSource {
 	return &BaseSource{
-		db:     db,
-		logger: logger,
+		db:      db,
+		logger:  logger,
+		limiter: newHostLimiter(DefaultPoliteness()),
+	}
+}
+
+// SupportsIncremental 默认不支持增量获取
+func (s *BaseSource) SupportsIncremental() bool {
+	return false
+}
+
+// SupportedProtocols 默认提供HTTP和HTTPS代理
+func (s *BaseSource) SupportedProtocols() []string {
+	return []string{"http", "https"}
+}
+
+// SupportsAnyProtocol 代理源是否提供 protocols 中的任一协议，protocols 为空时视为提供
+func SupportsAnyProtocol(source Source, protocols []string) bool {
+	if len(protocols) == 0 {
+		return true
+	}
+	for _, supported := range source.SupportedProtocols() {
+		for _, protocol := range protocols {
+			if strings.EqualFold(supported, protocol) {
+				return true
+			}
+		}
 	}
+	return false
 }
 
-// SaveProxies 保存代理列表
+// SaveProxies 保存代理列表，部分代理写入失败时只记录日志，全部失败时返回错误
 func (s *BaseSource) SaveProxies(proxies []*models.Proxy) error {
-	return models.BatchCreateWithDuplicateCheck(s.db, proxies)
+	_, err := models.BatchCreateWithDuplicateCheck(s.db, proxies)
+	return err
 }
@@ -83,7 +83,7 @@ func (s *XiladailiSource) FetchProxies() ([]*models.Proxy, error) {
 }
 
 func (s *XiladailiSource) fetchFromURL(url string) ([]*models.Proxy, error) {
-	resp, err := s.client.Get(url)
+	resp, err := s.get(s.client, url)
 	if err != nil {
 		return nil, err
 	}
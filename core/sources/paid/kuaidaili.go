@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"proxy_pool/core/redact"
 	"proxy_pool/models"
 	"strconv"
 	"strings"
@@ -14,6 +15,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// KuaidailiSourceName 快代理源名称
+const KuaidailiSourceName = "kuaidaili_paid"
+
 // KuaidailiSource 快代理源
 type KuaidailiSource struct {
 	*BaseSource
@@ -22,9 +26,9 @@ type KuaidailiSource struct {
 }
 
 // NewKuaidailiSource 创建快代理源
-func NewKuaidailiSource(apiURL string, db *gorm.DB, logger *zap.Logger) *KuaidailiSource {
+func NewKuaidailiSource(apiURL string, db *gorm.DB, logger *zap.Logger, opts ...Option) *KuaidailiSource {
 	return &KuaidailiSource{
-		BaseSource: NewBaseSource(db, logger),
+		BaseSource: NewBaseSource(db, logger, opts...),
 		apiURL:     apiURL,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
@@ -33,7 +37,7 @@ func NewKuaidailiSource(apiURL string, db *gorm.DB, logger *zap.Logger) *Kuaidai
 }
 
 func (s *KuaidailiSource) Name() string {
-	return "kuaidaili_paid"
+	return KuaidailiSourceName
 }
 
 // FetchProxies 获取代理列表
@@ -54,11 +58,12 @@ func (s *KuaidailiSource) FetchProxies() ([]*models.Proxy, error) {
 // fetchFromAPI 从API获取代理
 func (s *KuaidailiSource) fetchFromAPI() ([]*models.Proxy, error) {
 	s.logger.Info("正在请求快代理API",
-		zap.String("URL", s.apiURL),
+		zap.String("URL", redact.URL(s.apiURL)),
 	)
 
 	resp, err := s.client.Get(s.apiURL)
 	if err != nil {
+		err = redact.Error(err)
 		s.logger.Error("请求快代理API失败",
 			zap.String("错误", err.Error()),
 		)
@@ -74,10 +79,6 @@ func (s *KuaidailiSource) fetchFromAPI() ([]*models.Proxy, error) {
 		return nil, err
 	}
 
-	s.logger.Debug("快代理API响应内容",
-		zap.String("响应", string(body)),
-	)
-
 	var result struct {
 		Code int    `json:"code"`
 		Msg  string `json:"msg"`
@@ -90,6 +91,7 @@ func (s *KuaidailiSource) fetchFromAPI() ([]*models.Proxy, error) {
 	if err := json.Unmarshal(body, &result); err != nil {
 		s.logger.Error("解析快代理响应失败",
 			zap.String("错误", err.Error()),
+			zap.String("响应", redact.Body(body)),
 		)
 		return nil, fmt.Errorf("解析响应失败: %v", err)
 	}
@@ -102,8 +104,16 @@ func (s *KuaidailiSource) fetchFromAPI() ([]*models.Proxy, error) {
 		return nil, fmt.Errorf("API错误: %s", result.Msg)
 	}
 
+	s.logger.Debug("快代理API响应",
+		zap.Int("响应字节数", len(body)),
+		zap.Int("返回代理数", len(result.Data.Proxies)),
+		zap.Int("返回总数", result.Data.Count),
+	)
+
 	var proxies []*models.Proxy
 	for _, proxyStr := range result.Data.Proxies {
+		// 请求带 f_et=1 时每项为 ip:port,剩余有效秒数
+		proxyStr, ttlStr, hasTTL := strings.Cut(proxyStr, ",")
 		parts := strings.Split(proxyStr, ":")
 		if len(parts) != 2 {
 			s.logger.Warn("快代理返回的代理格式错误",
@@ -124,11 +134,22 @@ func (s *KuaidailiSource) fetchFromAPI() ([]*models.Proxy, error) {
 		proxy := &models.Proxy{
 			IP:        ip,
 			Port:      port,
-			Type:      models.ProxyTypeLong,
+			Type:      s.DefaultType(),
 			Protocol:  "http",
 			Source:    s.Name(),
 			Anonymous: true,
 		}
+		if hasTTL {
+			seconds, err := strconv.Atoi(strings.TrimSpace(ttlStr))
+			if err != nil || seconds <= 0 {
+				s.logger.Warn("快代理返回的剩余有效时长格式错误",
+					zap.String("剩余有效时长", ttlStr),
+				)
+			} else {
+				expiresAt := time.Now().Add(time.Duration(seconds) * time.Second)
+				proxy.ExpiresAt = &expiresAt
+			}
+		}
 		proxies = append(proxies, proxy)
 	}
 
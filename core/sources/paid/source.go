@@ -15,19 +15,48 @@ type PaidSource interface {
 
 // BaseSource 基础代理源实现
 type BaseSource struct {
-	db     *gorm.DB
-	logger *zap.Logger
+	db          *gorm.DB
+	logger      *zap.Logger
+	defaultType models.ProxyType // API未返回类型信息时使用的代理类型
 }
 
-// NewBaseSource 创建基础代理源
-func NewBaseSource(db *gorm.DB, logger *zap.Logger) *BaseSource {
-	return &BaseSource{
-		db:     db,
-		logger: logger,
+// Option 代理源选项
+type Option func(*BaseSource)
+
+// WithDefaultType 设置代理源的默认代理类型，空值表示保持默认
+func WithDefaultType(pt models.ProxyType) Option {
+	return func(s *BaseSource) {
+		if pt != "" {
+			s.defaultType = pt
+		}
+	}
+}
+
+// NewBaseSource 创建基础代理源，默认代理类型为长效代理
+func NewBaseSource(db *gorm.DB, logger *zap.Logger, opts ...Option) *BaseSource {
+	s := &BaseSource{
+		db:          db,
+		logger:      logger,
+		defaultType: models.ProxyTypeLong,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// DefaultType 获取默认代理类型
+func (s *BaseSource) DefaultType() models.ProxyType {
+	return s.defaultType
+}
+
+// SupportedProtocols 付费代理源可提供的代理协议，目前均为HTTP代理
+func SupportedProtocols() []string {
+	return []string{"http"}
 }
 
-// SaveProxies 保存代理列表
+// SaveProxies 保存代理列表，部分代理写入失败时只记录日志，全部失败时返回错误
 func (s *BaseSource) SaveProxies(proxies []*models.Proxy) error {
-	return models.BatchCreateWithDuplicateCheck(s.db, proxies)
+	_, err := models.BatchCreateWithDuplicateCheck(s.db, proxies)
+	return err
 }
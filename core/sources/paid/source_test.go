@@ -0,0 +1,132 @@
+package paid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func serveJSON(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestKuaidailiParsesTTL(t *testing.T) {
+	srv := serveJSON(t, `{"code":0,"msg":"","data":{"count":3,"proxy_list":["1.2.3.4:8080,120","5.6.7.8:3128","9.9.9.9:80,x"]}}`)
+	s := NewKuaidailiSource(srv.URL, nil, zap.NewNop())
+
+	proxies, err := s.fetchFromAPI()
+	if err != nil {
+		t.Fatalf("fetchFromAPI: %v", err)
+	}
+	if len(proxies) != 3 {
+		t.Fatalf("parsed %d proxies, want 3", len(proxies))
+	}
+	if proxies[0].ExpiresAt == nil {
+		t.Fatal("proxy with TTL has no ExpiresAt")
+	}
+	if ttl := time.Until(*proxies[0].ExpiresAt); ttl < 110*time.Second || ttl > 120*time.Second {
+		t.Errorf("ExpiresAt in %v, want about 120s", ttl)
+	}
+	if proxies[0].IP != "1.2.3.4" || proxies[0].Port != 8080 {
+		t.Errorf("proxy = %s:%d, want 1.2.3.4:8080", proxies[0].IP, proxies[0].Port)
+	}
+	for _, p := range proxies[1:] {
+		if p.ExpiresAt != nil {
+			t.Errorf("proxy %s:%d ExpiresAt = %v, want nil", p.IP, p.Port, p.ExpiresAt)
+		}
+	}
+}
+
+func TestWandouParsesExpireTime(t *testing.T) {
+	srv := serveJSON(t, `{"code":200,"msg":"ok","data":[
+		{"ip":"1.2.3.4","port":8080,"anonymous":true,"expire_time":"2030-01-02 08:00:00"},
+		{"ip":"5.6.7.8","port":3128,"anonymous":false}
+	]}`)
+	s := NewWandouSource(srv.URL, nil, zap.NewNop())
+
+	proxies, err := s.fetchFromAPI()
+	if err != nil {
+		t.Fatalf("fetchFromAPI: %v", err)
+	}
+	if len(proxies) != 2 {
+		t.Fatalf("parsed %d proxies, want 2", len(proxies))
+	}
+	want := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	if proxies[0].ExpiresAt == nil || !proxies[0].ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", proxies[0].ExpiresAt, want)
+	}
+	if proxies[1].ExpiresAt != nil {
+		t.Errorf("proxy without expire_time ExpiresAt = %v, want nil", proxies[1].ExpiresAt)
+	}
+}
+
+func TestPaidSourceDefaultType(t *testing.T) {
+	kuaidaili := serveJSON(t, `{"code":0,"msg":"","data":{"count":1,"proxy_list":["1.2.3.4:8080"]}}`)
+	wandou := serveJSON(t, `{"code":200,"msg":"ok","data":[{"ip":"1.2.3.4","port":8080}]}`)
+
+	tests := []struct {
+		name  string
+		fetch func() ([]*models.Proxy, error)
+		want  models.ProxyType
+	}{
+		{"kuaidaili default", NewKuaidailiSource(kuaidaili.URL, nil, zap.NewNop()).fetchFromAPI, models.ProxyTypeLong},
+		{"kuaidaili temp", NewKuaidailiSource(kuaidaili.URL, nil, zap.NewNop(), WithDefaultType(models.ProxyTypeTemp)).fetchFromAPI, models.ProxyTypeTemp},
+		{"wandou default", NewWandouSource(wandou.URL, nil, zap.NewNop()).fetchFromAPI, models.ProxyTypeLong},
+		{"wandou temp", NewWandouSource(wandou.URL, nil, zap.NewNop(), WithDefaultType(models.ProxyTypeTemp)).fetchFromAPI, models.ProxyTypeTemp},
+		{"empty option keeps default", NewWandouSource(wandou.URL, nil, zap.NewNop(), WithDefaultType("")).fetchFromAPI, models.ProxyTypeLong},
+	}
+	for _, tt := range tests {
+		proxies, err := tt.fetch()
+		if err != nil {
+			t.Fatalf("%s: fetchFromAPI: %v", tt.name, err)
+		}
+		if len(proxies) != 1 || proxies[0].Type != tt.want {
+			t.Errorf("%s: proxies = %+v, want one %s proxy", tt.name, proxies, tt.want)
+		}
+	}
+}
+
+func TestKuaidailiLogsRedactedURLAndTrimmedBody(t *testing.T) {
+	observed, logs := observer.New(zap.DebugLevel)
+	garbage := "<html>" + strings.Repeat("x", 2000)
+	srv := serveJSON(t, garbage)
+	s := NewKuaidailiSource(srv.URL+"/api/getdps?secret_id=abc123&signature=sig456", nil, zap.New(observed))
+
+	if _, err := s.fetchFromAPI(); err == nil {
+		t.Fatal("fetchFromAPI with an HTML body succeeded")
+	}
+	for _, entry := range logs.All() {
+		for key, value := range entry.ContextMap() {
+			text, _ := value.(string)
+			if strings.Contains(text, "abc123") || strings.Contains(text, "sig456") {
+				t.Errorf("log %q field %s leaks the secret: %q", entry.Message, key, text)
+			}
+			if len(text) > 2000 {
+				t.Errorf("log %q field %s has %d bytes, want the body trimmed", entry.Message, key, len(text))
+			}
+		}
+	}
+	if logs.FilterMessage("解析快代理响应失败").Len() != 1 {
+		t.Error("parse failure was not logged")
+	}
+
+	// 请求失败的错误中不包含完整的URL
+	srv.Close()
+	_, err := s.fetchFromAPI()
+	if err == nil || strings.Contains(err.Error(), "abc123") {
+		t.Errorf("request error = %v, want it without the secret", err)
+	}
+}
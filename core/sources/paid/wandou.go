@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"proxy_pool/core/redact"
 	"proxy_pool/models"
 	"time"
 
@@ -12,6 +13,14 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	WandouSourceName = "wandou_paid"         // 豌豆代理源名称
+	wandouTimeLayout = "2006-01-02 15:04:05" // 豌豆代理返回的到期时间格式
+)
+
+// wandouLocation 豌豆代理返回的到期时间为北京时间
+var wandouLocation = time.FixedZone("CST", 8*60*60)
+
 // WandouSource 豌豆代理源
 type WandouSource struct {
 	*BaseSource
@@ -20,9 +29,9 @@ type WandouSource struct {
 }
 
 // NewWandouSource 创建豌豆代理源
-func NewWandouSource(apiURL string, db *gorm.DB, logger *zap.Logger) *WandouSource {
+func NewWandouSource(apiURL string, db *gorm.DB, logger *zap.Logger, opts ...Option) *WandouSource {
 	return &WandouSource{
-		BaseSource: NewBaseSource(db, logger),
+		BaseSource: NewBaseSource(db, logger, opts...),
 		apiURL:     apiURL,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
@@ -31,7 +40,7 @@ func NewWandouSource(apiURL string, db *gorm.DB, logger *zap.Logger) *WandouSour
 }
 
 func (s *WandouSource) Name() string {
-	return "wandou_paid"
+	return WandouSourceName
 }
 
 // FetchProxies 获取代理列表
@@ -52,11 +61,12 @@ func (s *WandouSource) FetchProxies() ([]*models.Proxy, error) {
 // fetchFromAPI 从API获取代理
 func (s *WandouSource) fetchFromAPI() ([]*models.Proxy, error) {
 	s.logger.Info("正在请求豌豆代理API",
-		zap.String("URL", s.apiURL),
+		zap.String("URL", redact.URL(s.apiURL)),
 	)
 
 	resp, err := s.client.Get(s.apiURL)
 	if err != nil {
+		err = redact.Error(err)
 		s.logger.Error("请求豌豆代理API失败",
 			zap.String("错误", err.Error()),
 		)
@@ -72,23 +82,21 @@ func (s *WandouSource) fetchFromAPI() ([]*models.Proxy, error) {
 		return nil, err
 	}
 
-	s.logger.Debug("豌豆代理API响应内容",
-		zap.String("响应", string(body)),
-	)
-
 	var result struct {
 		Code int    `json:"code"`
 		Msg  string `json:"msg"`
 		Data []struct {
-			IP        string `json:"ip"`
-			Port      int    `json:"port"`
-			Anonymous bool   `json:"anonymous"`
+			IP         string `json:"ip"`
+			Port       int    `json:"port"`
+			Anonymous  bool   `json:"anonymous"`
+			ExpireTime string `json:"expire_time"`
 		} `json:"data"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
 		s.logger.Error("解析豌豆代理响应失败",
 			zap.String("错误", err.Error()),
+			zap.String("响应", redact.Body(body)),
 		)
 		return nil, fmt.Errorf("解析响应失败: %v", err)
 	}
@@ -101,16 +109,31 @@ func (s *WandouSource) fetchFromAPI() ([]*models.Proxy, error) {
 		return nil, fmt.Errorf("API错误: %s", result.Msg)
 	}
 
+	s.logger.Debug("豌豆代理API响应",
+		zap.Int("响应字节数", len(body)),
+		zap.Int("返回代理数", len(result.Data)),
+	)
+
 	var proxies []*models.Proxy
 	for _, item := range result.Data {
 		proxy := &models.Proxy{
 			IP:        item.IP,
 			Port:      item.Port,
-			Type:      models.ProxyTypeLong,
+			Type:      s.DefaultType(),
 			Protocol:  "http",
 			Source:    s.Name(),
 			Anonymous: item.Anonymous,
 		}
+		if item.ExpireTime != "" {
+			expiresAt, err := time.ParseInLocation(wandouTimeLayout, item.ExpireTime, wandouLocation)
+			if err != nil {
+				s.logger.Warn("豌豆代理返回的到期时间格式错误",
+					zap.String("到期时间", item.ExpireTime),
+				)
+			} else {
+				proxy.ExpiresAt = &expiresAt
+			}
+		}
 		proxies = append(proxies, proxy)
 	}
 
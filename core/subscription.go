@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"proxy_pool/models"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// SubscriptionFormat 订阅导出格式
+type SubscriptionFormat string
+
+const (
+	SubscriptionClash SubscriptionFormat = "clash" // Clash 配置的 proxies 段
+	SubscriptionV2Ray SubscriptionFormat = "v2ray" // base64编码的分享链接列表
+	SubscriptionPlain SubscriptionFormat = "plain" // 每行一个 protocol://ip:port
+)
+
+// IsValid 是否为支持的订阅格式
+func (f SubscriptionFormat) IsValid() bool {
+	return f == SubscriptionClash || f == SubscriptionV2Ray || f == SubscriptionPlain
+}
+
+// ContentType 订阅响应的内容类型
+func (f SubscriptionFormat) ContentType() string {
+	if f == SubscriptionClash {
+		return "text/yaml; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// supports 格式是否能表示该协议的代理
+// https 代理需要与代理服务器建立TLS连接，V2Ray 分享链接无法表示
+func (f SubscriptionFormat) supports(protocol string) bool {
+	switch protocol {
+	case "http", "socks5":
+		return true
+	case "https":
+		return f != SubscriptionV2Ray
+	default:
+		return false
+	}
+}
+
+// ExportSubscription 将符合条件的可用代理按订阅格式写入 w，无法转换为该格式的代理跳过
+func (p *ProxyPool) ExportSubscription(w io.Writer, format SubscriptionFormat, filter models.ListFilter) error {
+	var proxies []*models.Proxy
+	err := models.EachAvailable(p.db, filter, func(proxy *models.Proxy) error {
+		if format.supports(proxy.Protocol) {
+			proxies = append(proxies, proxy.Clone())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := RenderSubscription(w, format, proxies); err != nil {
+		return err
+	}
+	p.logger.Info("订阅导出完成", zap.String("格式", string(format)), zap.Int("代理数", len(proxies)))
+	return nil
+}
+
+// RenderSubscription 将代理渲染为订阅格式，无法转换为该格式的代理跳过
+// 代理名称为 来源-地区-序号，序号在同一来源和地区内从1开始
+func RenderSubscription(w io.Writer, format SubscriptionFormat, proxies []*models.Proxy) error {
+	var supported []*models.Proxy
+	for _, proxy := range proxies {
+		if format.supports(proxy.Protocol) {
+			supported = append(supported, proxy)
+		}
+	}
+	names := subscriptionNames(supported)
+
+	switch format {
+	case SubscriptionClash:
+		return renderClash(w, supported, names)
+	case SubscriptionV2Ray:
+		return renderV2Ray(w, supported, names)
+	case SubscriptionPlain:
+		return renderPlain(w, supported)
+	default:
+		return fmt.Errorf("unsupported subscription format: %q", format)
+	}
+}
+
+// subscriptionNames 生成代理名称
+func subscriptionNames(proxies []*models.Proxy) []string {
+	names := make([]string, len(proxies))
+	counts := make(map[string]int)
+	for i, proxy := range proxies {
+		source := proxy.Source
+		if source == "" {
+			source = "unknown"
+		}
+		prefix := source + "-" + string(proxy.Region)
+		counts[prefix]++
+		names[i] = prefix + "-" + strconv.Itoa(counts[prefix])
+	}
+	return names
+}
+
+// renderClash 渲染为 Clash 配置的 proxies 段
+func renderClash(w io.Writer, proxies []*models.Proxy, names []string) error {
+	bw := bufio.NewWriter(w)
+	if len(proxies) == 0 {
+		bw.WriteString("proxies: []\n")
+		return bw.Flush()
+	}
+
+	bw.WriteString("proxies:\n")
+	for i, proxy := range proxies {
+		proxyType := proxy.Protocol
+		if proxyType == "https" {
+			proxyType = "http"
+		}
+		fmt.Fprintf(bw, "  - name: %s\n", strconv.Quote(names[i]))
+		fmt.Fprintf(bw, "    type: %s\n", proxyType)
+		fmt.Fprintf(bw, "    server: %s\n", strconv.Quote(proxy.IP))
+		fmt.Fprintf(bw, "    port: %d\n", proxy.Port)
+		if proxy.Protocol == "https" {
+			bw.WriteString("    tls: true\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// renderV2Ray 渲染为 base64 编码的分享链接列表，每行一个链接
+func renderV2Ray(w io.Writer, proxies []*models.Proxy, names []string) error {
+	var buf bytes.Buffer
+	for i, proxy := range proxies {
+		scheme := proxy.Protocol
+		if scheme == "socks5" {
+			scheme = "socks"
+		}
+		link := url.URL{
+			Scheme:   scheme,
+			Host:     net.JoinHostPort(proxy.IP, strconv.Itoa(proxy.Port)),
+			Fragment: names[i],
+		}
+		buf.WriteString(link.String())
+		buf.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return err
+}
+
+// renderPlain 渲染为每行一个 protocol://ip:port
+func renderPlain(w io.Writer, proxies []*models.Proxy) error {
+	bw := bufio.NewWriter(w)
+	for _, proxy := range proxies {
+		fmt.Fprintf(bw, "%s://%s\n", proxy.Protocol, net.JoinHostPort(proxy.IP, strconv.Itoa(proxy.Port)))
+	}
+	return bw.Flush()
+}
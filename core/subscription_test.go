@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestRenderSubscriptionGolden(t *testing.T) {
+	proxies := []*models.Proxy{
+		{IP: "1.2.3.4", Port: 8080, Protocol: "http", Source: "kuaidaili_paid", Region: models.ProxyRegionCN},
+		{IP: "5.6.7.8", Port: 1080, Protocol: "socks5", Source: "kuaidaili_paid", Region: models.ProxyRegionCN},
+		{IP: "9.9.9.9", Port: 443, Protocol: "https", Source: "free", Region: models.ProxyRegionOther},
+		{IP: "2001:db8::1", Port: 3128, Protocol: "http", Region: models.ProxyRegionOther},
+		{IP: "8.8.4.4", Port: 1081, Protocol: "socks4", Source: "free", Region: models.ProxyRegionOther},
+	}
+
+	for _, format := range []SubscriptionFormat{SubscriptionClash, SubscriptionV2Ray, SubscriptionPlain} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := RenderSubscription(&buf, format, proxies); err != nil {
+				t.Fatalf("RenderSubscription: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "subscription."+string(format)+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s output mismatch\ngot:\n%s\nwant:\n%s", format, buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestRenderSubscriptionEmptyClash(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderSubscription(&buf, SubscriptionClash, nil); err != nil {
+		t.Fatalf("RenderSubscription: %v", err)
+	}
+	if got := buf.String(); got != "proxies: []\n" {
+		t.Errorf("empty clash = %q, want %q", got, "proxies: []\n")
+	}
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	siteconfig "proxy_pool/core/config"
+	"proxy_pool/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultVerifyTimeout = 3 * time.Second // 发放前验证单次请求的默认超时时间
+	MaxVerifyAttempts    = 5               // 发放前验证最多尝试的代理数
+	verifyBodyLimit      = 64 << 10        // GET 验证最多读取的响应体字节数
+)
+
+var (
+	// ErrVerifyTargetNotAllowed 目标域名未注册站点配置也不在白名单中，不允许验证
+	ErrVerifyTargetNotAllowed = errors.New("target domain not allowed for verification")
+	// ErrVerifyFailed 尝试的代理均未通过发放前验证
+	ErrVerifyFailed = errors.New("no proxy passed verification")
+	// ErrVerifyUnsupportedProtocol 代理协议无法用于发放前验证
+	ErrVerifyUnsupportedProtocol = errors.New("proxy protocol not supported for verification")
+)
+
+// VerifyResult 发放前验证的结果
+type VerifyResult struct {
+	Latency  time.Duration // 通过验证的代理访问目标的耗时
+	Attempts int           // 尝试的代理数
+}
+
+// TargetVerifier 发放代理前通过代理访问任务目标，确认代理可用
+// 为避免代理池被当作访问任意网址的开放代理，只允许验证注册了站点配置或在白名单中的域名，
+// 且只发送一次不跟随重定向的 HEAD 或 GET 请求，不返回响应内容
+type TargetVerifier struct {
+	mu      sync.RWMutex
+	sites   map[string]*siteconfig.SiteConfig // 按域名注册的站点配置
+	allowed map[string]bool                   // 没有站点配置但允许验证的域名
+	timeout time.Duration
+}
+
+// NewTargetVerifier 创建发放前验证器，默认不允许验证任何域名
+func NewTargetVerifier() *TargetVerifier {
+	return &TargetVerifier{
+		sites:   make(map[string]*siteconfig.SiteConfig),
+		allowed: make(map[string]bool),
+		timeout: DefaultVerifyTimeout,
+	}
+}
+
+// RegisterSite 注册站点配置，站点域名取自 BaseURL，验证时使用站点的请求方法和请求头
+func (v *TargetVerifier) RegisterSite(site *siteconfig.SiteConfig) error {
+	if err := site.Validate(); err != nil {
+		return err
+	}
+	u, err := url.Parse(site.BaseURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid site base url: %q", site.BaseURL)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sites[models.NormalizeDomain(u.Hostname())] = site
+	return nil
+}
+
+// SetAllowedDomains 设置没有站点配置但允许验证的域名，替换原有白名单
+func (v *TargetVerifier) SetAllowedDomains(domains []string) {
+	allowed := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		if domain = models.NormalizeDomain(domain); domain != "" {
+			allowed[domain] = true
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.allowed = allowed
+}
+
+// SetTimeout 设置单次验证请求的超时时间，非正值表示保持不变
+func (v *TargetVerifier) SetTimeout(d time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if d > 0 {
+		v.timeout = d
+	}
+}
+
+// target 检查目标URL是否允许验证，返回目标域名的站点配置，只在白名单中时站点配置为nil
+func (v *TargetVerifier) target(targetURL string) (*siteconfig.SiteConfig, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: invalid target url %q", ErrVerifyTargetNotAllowed, targetURL)
+	}
+	domain := models.NormalizeDomain(u.Hostname())
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if site, ok := v.sites[domain]; ok {
+		return site, nil
+	}
+	if v.allowed[domain] {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrVerifyTargetNotAllowed, domain)
+}
+
+// verifyProxyURL 按代理协议生成验证请求使用的代理地址
+// net/http 只支持 http、https 和 socks5 代理，其他协议返回 ErrVerifyUnsupportedProtocol
+func verifyProxyURL(proxy *models.Proxy) (*url.URL, error) {
+	scheme := proxy.Protocol
+	switch scheme {
+	case "":
+		scheme = "http"
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrVerifyUnsupportedProtocol, proxy.Protocol)
+	}
+	return &url.URL{Scheme: scheme, Host: net.JoinHostPort(proxy.IP, strconv.Itoa(proxy.Port))}, nil
+}
+
+// classifyVerifyError 对发放前验证的结果分类，状态码小于400视为通过
+// 与验证器的分类相同，另外将域名解析失败归为目标网站的问题
+func classifyVerifyError(err error, statusCode int) ValidationErrorClass {
+	if err == nil && statusCode < http.StatusBadRequest {
+		return ErrorClassNone
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassTarget
+	}
+	return classifyValidationError(err, statusCode)
+}
+
+// verify 通过代理向目标发送一次请求，返回耗时和错误分类
+// 代理协议不支持验证时返回 ErrVerifyUnsupportedProtocol，分类为 ErrorClassNone
+func (v *TargetVerifier) verify(ctx context.Context, proxy *models.Proxy, targetURL string, site *siteconfig.SiteConfig) (time.Duration, ValidationErrorClass, error) {
+	v.mu.RLock()
+	timeout := v.timeout
+	v.mu.RUnlock()
+
+	method := http.MethodHead
+	if site != nil {
+		if site.VerifyMethod != "" {
+			method = site.VerifyMethod
+		}
+		if site.Timeout > 0 && site.Timeout < timeout {
+			timeout = site.Timeout
+		}
+	}
+
+	proxyURL, err := verifyProxyURL(proxy)
+	if err != nil {
+		return 0, ErrorClassNone, err
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyURL(proxyURL),
+			DisableKeepAlives: true,
+		},
+		Timeout: timeout,
+		// 不跟随重定向，避免被引导访问其他网址
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, nil)
+	if err != nil {
+		return 0, ErrorClassNone, err
+	}
+	if site != nil {
+		for k, val := range site.Headers {
+			req.Header.Set(k, val)
+		}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Since(start), classifyVerifyError(err, 0), err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, verifyBodyLimit))
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	if class := classifyVerifyError(nil, resp.StatusCode); class != ErrorClassNone {
+		return latency, class, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return latency, ErrorClassNone, nil
+}
+
+// TargetVerifier 获取发放前验证器
+func (p *ProxyPool) TargetVerifier() *TargetVerifier {
+	return p.verifier
+}
+
+// GetVerifiedProxyForTask 根据任务需求获取代理，发放前通过代理访问 task.TargetURL 确认可用
+// 验证失败的代理排除后换下一个代理重试，最多尝试 task.RetryCount+1 个（不超过 MaxVerifyAttempts）；
+// 只有代理本身的问题（无法连接、超时、要求认证等）记为一次失败，目标网站的问题、域名解析失败、
+// 协议不支持验证和请求取消只释放代理；
+// 目标不允许验证时返回 ErrVerifyTargetNotAllowed，均未通过时返回 ErrVerifyFailed
+func (p *ProxyPool) GetVerifiedProxyForTask(ctx context.Context, task *Task) (*models.Proxy, *VerifyResult, error) {
+	site, err := p.verifier.target(task.TargetURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attempts := task.RetryCount + 1
+	if attempts > MaxVerifyAttempts {
+		attempts = MaxVerifyAttempts
+	}
+
+	var lastErr error
+	tried := 0
+	for i := 1; i <= attempts; i++ {
+		tried = i
+		proxy, err := p.GetProxyForTask(ctx, task)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		latency, class, err := p.verifier.verify(ctx, proxy, task.TargetURL, site)
+		if err == nil {
+			return proxy, &VerifyResult{Latency: latency, Attempts: i}, nil
+		}
+
+		lastErr = err
+		p.logger.Info("代理发放前验证失败，换下一个代理",
+			zap.Uint("代理ID", proxy.ID),
+			zap.String("目标URL", task.TargetURL),
+			zap.Int("第几次尝试", i),
+			zap.Duration("耗时", latency),
+			zap.String("错误分类", string(class)),
+			zap.Error(err),
+		)
+		if class.IsProxyFault() && ctx.Err() == nil {
+			p.ReportProxyStatus(proxy.ID, StatusReport{
+				Success:   false,
+				TargetURL: task.TargetURL,
+				Domain:    task.Domain,
+				ErrorMsg:  err.Error(),
+				HandoutID: task.HandoutID,
+			})
+		} else {
+			p.releaseProxy(task, proxy.ID)
+		}
+		task.ExcludeIDs = append(task.ExcludeIDs, proxy.ID)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, nil, fmt.Errorf("%w after %d attempts: %v", ErrVerifyFailed, tried, lastErr)
+}
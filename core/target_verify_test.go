@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"proxy_pool/models"
+)
+
+func TestVerifyProxyURL(t *testing.T) {
+	tests := []struct {
+		protocol string
+		scheme   string
+	}{
+		{"", "http"},
+		{"http", "http"},
+		{"https", "https"},
+		{"socks5", "socks5"},
+		{"socks4", ""},
+		{models.ProtocolAuto, ""},
+	}
+	for _, tt := range tests {
+		u, err := verifyProxyURL(&models.Proxy{IP: "1.1.1.1", Port: 1080, Protocol: tt.protocol})
+		if tt.scheme == "" {
+			if !errors.Is(err, ErrVerifyUnsupportedProtocol) {
+				t.Errorf("verifyProxyURL(%q) error = %v, want %v", tt.protocol, err, ErrVerifyUnsupportedProtocol)
+			}
+			continue
+		}
+		if err != nil || u.Scheme != tt.scheme || u.Host != "1.1.1.1:1080" {
+			t.Errorf("verifyProxyURL(%q) = %v, %v, want %s://1.1.1.1:1080", tt.protocol, u, err, tt.scheme)
+		}
+	}
+}
+
+func TestClassifyVerifyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   ValidationErrorClass
+	}{
+		{"ok", nil, http.StatusOK, ErrorClassNone},
+		{"redirect", nil, http.StatusFound, ErrorClassNone},
+		{"target 5xx", nil, http.StatusServiceUnavailable, ErrorClassTarget},
+		{"target 4xx", nil, http.StatusForbidden, ErrorClassTarget},
+		{"proxy auth", nil, http.StatusProxyAuthRequired, ErrorClassProxyAuth},
+		{"dns", &url.Error{Op: "Head", Err: &net.DNSError{Err: "no such host", Name: "example.test"}}, 0, ErrorClassTarget},
+		{"dial", &url.Error{Op: "Head", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, 0, ErrorClassDial},
+	}
+	for _, tt := range tests {
+		if got := classifyVerifyError(tt.err, tt.status); got != tt.want {
+			t.Errorf("%s: classifyVerifyError = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetVerifiedProxyPenalisesOnlyProxyFaults(t *testing.T) {
+	pool, mr := newTestPool(t)
+	pool.TargetVerifier().SetAllowedDomains([]string{"example.test"})
+
+	// 作为HTTP代理的测试服务器，转发请求时目标网站返回503
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(upstream.Close)
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+	upstreamPort, _ := strconv.Atoi(port)
+
+	// 关闭的端口，连接代理失败
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	targetDown := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Port = upstreamPort; p.Score = 90 })
+	socks4 := newTestProxy(t, pool.DB(), "2.2.2.2", func(p *models.Proxy) { p.Protocol = "socks4"; p.Score = 85 })
+	dead := newTestProxy(t, pool.DB(), "3.3.3.3", func(p *models.Proxy) { p.Port = closedPort; p.Score = 70 })
+	// 入库校验不允许回环地址，写入后再指向本机的测试代理
+	pool.DB().Model(&models.Proxy{}).Where("id IN ?", []uint{targetDown.ID, dead.ID}).UpdateColumn("ip", "127.0.0.1")
+
+	// 依次尝试全部三个代理
+	task := &Task{TargetURL: "http://example.test/", Domain: "example.test", RetryCount: 2}
+	if _, _, err := pool.GetVerifiedProxyForTask(context.Background(), task); !errors.Is(err, ErrVerifyFailed) {
+		t.Fatalf("GetVerifiedProxyForTask error = %v, want %v", err, ErrVerifyFailed)
+	}
+
+	reported := func(id uint) int64 {
+		var n int64
+		pool.DB().Model(&models.ProxyUsage{}).Where("proxy_id = ?", id).Count(&n)
+		return n
+	}
+	for _, p := range []*models.Proxy{targetDown, socks4} {
+		if n := reported(p.ID); n != 0 {
+			t.Errorf("proxy %d (%s) reported %d failures, want 0", p.ID, p.Protocol, n)
+		}
+	}
+	if n := reported(dead.ID); n != 1 {
+		t.Errorf("unreachable proxy reported %d failures, want 1", n)
+	}
+	for _, p := range []*models.Proxy{targetDown, socks4, dead} {
+		if n := pool.GetLiveConcurrentUse(p.ID); n != 0 {
+			t.Errorf("proxy %d concurrent use after verification = %d, want 0", p.ID, n)
+		}
+	}
+	// 上报的发放已关联，释放的发放已删除
+	if n := pendingHandouts(t, mr); n != 0 {
+		t.Errorf("pending handouts after verification = %d, want 0", n)
+	}
+}
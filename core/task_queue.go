@@ -0,0 +1,258 @@
+package core
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"proxy_pool/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	MinTaskPriority = 1 // 最低优先级
+	MaxTaskPriority = 5 // 最高优先级
+
+	taskQueueBackoff = 500 * time.Millisecond // 无可用代理时的退避时间
+)
+
+var ErrTaskDeadlineExceeded = errors.New("task deadline exceeded")
+
+// TaskResult 任务调度结果
+type TaskResult struct {
+	Proxy *models.Proxy
+	Err   error
+}
+
+// queuedTask 队列中的任务
+type queuedTask struct {
+	task     *Task
+	priority int
+	seq      uint64    // 入队序号，同优先级先进先出
+	deadline time.Time // 截止时间，零值表示不限
+	result   chan TaskResult
+	index    int
+}
+
+// expired 检查任务是否已超过截止时间
+func (t *queuedTask) expired(now time.Time) bool {
+	return !t.deadline.IsZero() && now.After(t.deadline)
+}
+
+// taskHeap 基于 container/heap 的优先级队列
+type taskHeap []*queuedTask
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	item := x.(*queuedTask)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// TaskQueue 带优先级的代理获取队列
+// 代理紧张时，高优先级任务优先获得代理
+type TaskQueue struct {
+	scheduler Scheduler
+	logger    *zap.Logger
+	mu        sync.Mutex
+	tasks     taskHeap
+	seq       uint64
+	notify    chan struct{}
+	backoff   time.Duration
+}
+
+// newTaskQueue 创建任务队列
+func newTaskQueue(scheduler Scheduler, logger *zap.Logger) *TaskQueue {
+	return &TaskQueue{
+		scheduler: scheduler,
+		logger:    logger,
+		notify:    make(chan struct{}, 1),
+		backoff:   taskQueueBackoff,
+	}
+}
+
+// NewTaskQueue 创建并启动任务队列，ctx 取消后队列停止分发
+func (p *ProxyPool) NewTaskQueue(ctx context.Context) *TaskQueue {
+	queue := newTaskQueue(p.scheduler, p.logger)
+	go queue.Dispatch(ctx)
+	return queue
+}
+
+// Enqueue 将任务加入队列，返回的通道在获取到代理或超过截止时间后收到一次结果
+func (q *TaskQueue) Enqueue(task *Task) <-chan TaskResult {
+	priority := task.Priority
+	if priority < MinTaskPriority {
+		priority = MinTaskPriority
+	}
+	if priority > MaxTaskPriority {
+		priority = MaxTaskPriority
+	}
+
+	item := &queuedTask{
+		task:     task,
+		priority: priority,
+		result:   make(chan TaskResult, 1),
+	}
+	if task.Timeout > 0 {
+		item.deadline = time.Now().Add(task.Timeout)
+	}
+
+	q.mu.Lock()
+	q.seq++
+	item.seq = q.seq
+	heap.Push(&q.tasks, item)
+	q.mu.Unlock()
+
+	// 唤醒分发协程
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return item.result
+}
+
+// Len 返回等待中的任务数
+func (q *TaskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tasks.Len()
+}
+
+// Dispatch 分发循环，始终为优先级最高的任务调度代理
+func (q *TaskQueue) Dispatch(ctx context.Context) {
+	for {
+		q.expireTasks()
+
+		item := q.pop()
+		if item == nil {
+			select {
+			case <-ctx.Done():
+				q.drain(ctx.Err())
+				return
+			case <-q.notify:
+				continue
+			}
+		}
+
+		proxy, err := q.schedule(ctx, item)
+		if err == nil {
+			item.result <- TaskResult{Proxy: proxy}
+			continue
+		}
+
+		if !errors.Is(err, ErrNoProxyAvailable) && !errors.Is(err, ErrNoQualifiedProxy) {
+			item.result <- TaskResult{Err: err}
+			continue
+		}
+
+		// 暂无可用代理，放回队列并退避后重试
+		q.push(item)
+		q.logger.Debug("暂无可用代理，任务等待重试",
+			zap.Int("优先级", item.priority),
+			zap.Int("等待任务数", q.Len()),
+		)
+
+		timer := time.NewTimer(q.backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			q.drain(ctx.Err())
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// schedule 为任务调度代理，设置了截止时间的任务查询不超过截止时间
+func (q *TaskQueue) schedule(ctx context.Context, item *queuedTask) (*models.Proxy, error) {
+	if !item.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, item.deadline)
+		defer cancel()
+	}
+	return q.scheduler.ScheduleProxy(ctx, item.task)
+}
+
+// pop 取出优先级最高的任务
+func (q *TaskQueue) pop() *queuedTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.tasks.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(&q.tasks).(*queuedTask)
+}
+
+// push 将任务放回队列，保留原有入队序号
+func (q *TaskQueue) push(item *queuedTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	heap.Push(&q.tasks, item)
+}
+
+// expireTasks 移除已超过截止时间的任务
+func (q *TaskQueue) expireTasks() {
+	now := time.Now()
+
+	q.mu.Lock()
+	var expired []*queuedTask
+	remaining := q.tasks[:0]
+	for _, item := range q.tasks {
+		if item.expired(now) {
+			expired = append(expired, item)
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+	if len(expired) > 0 {
+		for i := len(remaining); i < len(q.tasks); i++ {
+			q.tasks[i] = nil
+		}
+		q.tasks = remaining
+		heap.Init(&q.tasks)
+	}
+	q.mu.Unlock()
+
+	for _, item := range expired {
+		item.result <- TaskResult{Err: ErrTaskDeadlineExceeded}
+	}
+}
+
+// drain 队列停止时通知所有等待中的任务
+func (q *TaskQueue) drain(err error) {
+	q.mu.Lock()
+	pending := q.tasks
+	q.tasks = nil
+	q.mu.Unlock()
+
+	for _, item := range pending {
+		item.result <- TaskResult{Err: err}
+	}
+}
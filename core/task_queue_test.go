@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// scriptedScheduler 按调用顺序记录任务，前 unavailable 次调度返回无可用代理
+type scriptedScheduler struct {
+	Scheduler
+
+	mu          sync.Mutex
+	unavailable int
+	calls       []string      // 各次调度的任务目标URL
+	called      chan struct{} // 每次调度后通知
+}
+
+func (s *scriptedScheduler) ScheduleProxy(ctx context.Context, task *Task) (*models.Proxy, error) {
+	s.mu.Lock()
+	s.calls = append(s.calls, task.TargetURL)
+	fail := len(s.calls) <= s.unavailable
+	s.mu.Unlock()
+	if s.called != nil {
+		s.called <- struct{}{}
+	}
+	if fail {
+		return nil, ErrNoProxyAvailable
+	}
+	return &models.Proxy{IP: "1.1.1.1", Port: 8080}, nil
+}
+
+func (s *scriptedScheduler) order() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// waitResult 等待任务结果，超时时测试失败
+func waitResult(t *testing.T, ch <-chan TaskResult) TaskResult {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for task result")
+		return TaskResult{}
+	}
+}
+
+func TestTaskQueueDispatchesByPriority(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.MaxConcurrent = 1 })
+	scheduler := pool.Scheduler().(*ProxyScheduler)
+	q := newTaskQueue(scheduler, zap.NewNop())
+	q.backoff = 10 * time.Millisecond
+
+	// 唯一的代理先被占用，入队的任务都需要等待
+	newTask := func(name string, priority int) *Task {
+		return &Task{TargetURL: name, Priority: priority, ProxyType: models.ProxyTypeTemp}
+	}
+	if _, err := scheduler.ScheduleProxy(context.Background(), newTask("holder", MinTaskPriority)); err != nil {
+		t.Fatalf("hold proxy: %v", err)
+	}
+
+	// 先入队10个低优先级任务，再入队2个高优先级任务
+	type queued struct {
+		name   string
+		result <-chan TaskResult
+	}
+	var tasks []queued
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("low-%d", i)
+		tasks = append(tasks, queued{name, q.Enqueue(newTask(name, MinTaskPriority))})
+	}
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("high-%d", i)
+		tasks = append(tasks, queued{name, q.Enqueue(newTask(name, MaxTaskPriority))})
+	}
+
+	// 每个任务获得代理后记录顺序并归还，下一个任务才能获得代理
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task queued) {
+			defer wg.Done()
+			r := waitResult(t, task.result)
+			if r.Err != nil || r.Proxy == nil {
+				t.Errorf("%s: result = %+v, want a proxy", task.name, r)
+				return
+			}
+			mu.Lock()
+			order = append(order, task.name)
+			mu.Unlock()
+			scheduler.ReportProxyStatus(r.Proxy.ID, StatusReport{Success: true})
+		}(task)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Dispatch(ctx)
+	scheduler.ReportProxyStatus(proxy.ID, StatusReport{Success: true})
+	wg.Wait()
+
+	if len(order) != len(tasks) {
+		t.Fatalf("dispatch order = %v, want all %d tasks", order, len(tasks))
+	}
+	if order[0] != "high-0" || order[1] != "high-1" {
+		t.Errorf("dispatch order = %v, want both high priority tasks first", order)
+	}
+	for i, name := range order[2:] {
+		if want := fmt.Sprintf("low-%d", i); name != want {
+			t.Errorf("dispatch order = %v, want low priority tasks in enqueue order", order)
+			break
+		}
+	}
+}
+
+func TestTaskQueueClampsPriority(t *testing.T) {
+	scheduler := &scriptedScheduler{}
+	q := newTaskQueue(scheduler, zap.NewNop())
+
+	// 分发开始前入队，同优先级先进先出，超出范围的优先级按边界处理
+	var results []<-chan TaskResult
+	for _, task := range []*Task{
+		{TargetURL: "low", Priority: 1},
+		{TargetURL: "mid-1", Priority: 3},
+		{TargetURL: "clamped-high", Priority: 9},
+		{TargetURL: "mid-2", Priority: 3},
+		{TargetURL: "clamped-low", Priority: 0},
+		{TargetURL: "high", Priority: 5},
+	} {
+		results = append(results, q.Enqueue(task))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Dispatch(ctx)
+	for _, ch := range results {
+		if r := waitResult(t, ch); r.Err != nil || r.Proxy == nil {
+			t.Fatalf("task result = %+v, want a proxy", r)
+		}
+	}
+
+	want := []string{"clamped-high", "high", "mid-1", "mid-2", "low", "clamped-low"}
+	got := scheduler.order()
+	if len(got) != len(want) {
+		t.Fatalf("dispatch order = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("dispatch order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTaskQueueRequeuesAfterBackoff(t *testing.T) {
+	scheduler := &scriptedScheduler{unavailable: 1, called: make(chan struct{}, 10)}
+	q := newTaskQueue(scheduler, zap.NewNop())
+	q.backoff = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Dispatch(ctx)
+
+	low := q.Enqueue(&Task{TargetURL: "low", Priority: 1})
+	<-scheduler.called
+	// 低优先级任务退避期间入队的高优先级任务先获得代理
+	high := q.Enqueue(&Task{TargetURL: "high", Priority: 5})
+
+	if r := waitResult(t, high); r.Err != nil {
+		t.Fatalf("high priority task: %v", r.Err)
+	}
+	if r := waitResult(t, low); r.Err != nil {
+		t.Fatalf("low priority task after backoff: %v", r.Err)
+	}
+	want := []string{"low", "high", "low"}
+	if got := scheduler.order(); len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("dispatch order = %v, want %v", got, want)
+	}
+}
+
+func TestTaskQueueExpiresWaitingTasks(t *testing.T) {
+	scheduler := &scriptedScheduler{unavailable: 1 << 30}
+	q := newTaskQueue(scheduler, zap.NewNop())
+	q.backoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Dispatch(ctx)
+
+	r := waitResult(t, q.Enqueue(&Task{TargetURL: "late", Timeout: 50 * time.Millisecond}))
+	if !errors.Is(r.Err, ErrTaskDeadlineExceeded) {
+		t.Errorf("expired task error = %v, want %v", r.Err, ErrTaskDeadlineExceeded)
+	}
+	if q.Len() != 0 {
+		t.Errorf("queue length after expiry = %d, want 0", q.Len())
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"proxy_pool/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultTopUpCooldown 默认同一类代理源两次获取的最短间隔
+const DefaultTopUpCooldown = 5 * time.Minute
+
+// topUpFetcher 补充获取使用的代理获取器
+type topUpFetcher interface {
+	FetchPaidProxies() (*FetchResult, error)
+	FetchFreeProxies() (*FetchResult, error)
+	LastFetches() (paid, free time.Time)
+}
+
+// FetchTopUp 清理或优化后的补充获取
+// 大批代理被清理后，可用代理可能要等到下一次定时获取才能恢复；Check 在可用代理低于
+// MinProxies 时立即在后台获取一次，付费和免费代理源各自距上次获取不足冷却时间时跳过，避免超出付费代理的提取额度
+type FetchTopUp struct {
+	db      *gorm.DB
+	logger  *zap.Logger
+	fetcher topUpFetcher
+
+	mu         sync.Mutex
+	minProxies int                 // 未设置运行时配置时的最少可用代理数
+	runtime    *RuntimeConfigStore // 运行时配置，设置后最少可用代理数从中读取
+	cooldown   time.Duration
+	running    bool
+}
+
+// NewFetchTopUp 创建补充获取
+func NewFetchTopUp(db *gorm.DB, logger *zap.Logger, fetcher *ProxyFetcher, minProxies int) *FetchTopUp {
+	return &FetchTopUp{
+		db:         db,
+		logger:     logger,
+		fetcher:    fetcher,
+		minProxies: minProxies,
+		cooldown:   DefaultTopUpCooldown,
+	}
+}
+
+// SetCooldown 设置同一类代理源两次获取的最短间隔，非正值表示保持不变
+func (t *FetchTopUp) SetCooldown(cooldown time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cooldown > 0 {
+		t.cooldown = cooldown
+	}
+}
+
+// SetRuntimeConfig 设置运行时配置，之后的检查使用其中的最少可用代理数
+func (t *FetchTopUp) SetRuntimeConfig(runtime *RuntimeConfigStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runtime = runtime
+}
+
+// minimum 获取最少可用代理数，调用方需持有 t.mu
+func (t *FetchTopUp) minimum() int {
+	if t.runtime != nil {
+		return t.runtime.Get().MinProxies
+	}
+	return t.minProxies
+}
+
+// Check 可用代理低于 MinProxies 时在后台获取一次代理，reason 为触发原因，如 "过期清理"
+// 返回是否触发了获取；上一次补充获取尚未结束或两类代理源都在冷却中时不触发
+func (t *FetchTopUp) Check(reason string) bool {
+	var available int64
+	if err := t.db.Model(&models.Proxy{}).Where("available = ?", true).Count(&available).Error; err != nil {
+		t.logger.Error("补充获取统计可用代理失败", zap.String("触发原因", reason), zap.Error(err))
+		return false
+	}
+
+	t.mu.Lock()
+	minProxies := t.minimum()
+	deficit := int64(minProxies) - available
+	if deficit <= 0 {
+		t.mu.Unlock()
+		return false
+	}
+	if t.running {
+		t.mu.Unlock()
+		t.logger.Info("上一次补充获取尚未结束，跳过", zap.String("触发原因", reason))
+		return false
+	}
+
+	lastPaid, lastFree := t.fetcher.LastFetches()
+	fetchPaid := time.Since(lastPaid) >= t.cooldown
+	fetchFree := time.Since(lastFree) >= t.cooldown
+	if !fetchPaid && !fetchFree {
+		t.mu.Unlock()
+		t.logger.Info("代理源均在冷却中，跳过补充获取",
+			zap.String("触发原因", reason),
+			zap.Int64("缺少代理数", deficit),
+			zap.Duration("冷却时间", t.cooldown),
+		)
+		return false
+	}
+	t.running = true
+	t.mu.Unlock()
+
+	t.logger.Info("可用代理不足，开始补充获取",
+		zap.String("触发原因", reason),
+		zap.Int64("可用代理数", available),
+		zap.Int("最少可用代理数", minProxies),
+		zap.Int64("缺少代理数", deficit),
+		zap.Bool("获取付费代理", fetchPaid),
+		zap.Bool("获取免费代理", fetchFree),
+	)
+
+	go t.run(reason, fetchPaid, fetchFree)
+	return true
+}
+
+// run 执行补充获取
+func (t *FetchTopUp) run(reason string, fetchPaid, fetchFree bool) {
+	defer func() {
+		t.mu.Lock()
+		t.running = false
+		t.mu.Unlock()
+	}()
+
+	if fetchPaid {
+		if _, err := t.fetcher.FetchPaidProxies(); err != nil {
+			t.logger.Error("补充获取付费代理失败", zap.String("触发原因", reason), zap.Error(err))
+		}
+	}
+	if fetchFree {
+		if _, err := t.fetcher.FetchFreeProxies(); err != nil {
+			t.logger.Error("补充获取免费代理失败", zap.String("触发原因", reason), zap.Error(err))
+		}
+	}
+}
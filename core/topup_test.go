@@ -0,0 +1,205 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// fakeTopUpFetcher 记录获取次数的代理获取器，lastPaid、lastFree 为上次获取时间
+type fakeTopUpFetcher struct {
+	paid, free         atomic.Int32
+	fetched            chan struct{}
+	lastPaid, lastFree time.Time
+}
+
+func (f *fakeTopUpFetcher) FetchPaidProxies() (*FetchResult, error) {
+	f.paid.Add(1)
+	return newFetchResult().finish(), nil
+}
+
+func (f *fakeTopUpFetcher) FetchFreeProxies() (*FetchResult, error) {
+	f.free.Add(1)
+	f.fetched <- struct{}{}
+	return newFetchResult().finish(), nil
+}
+
+func (f *fakeTopUpFetcher) LastFetches() (paid, free time.Time) {
+	return f.lastPaid, f.lastFree
+}
+
+// newTestTopUp 为代理池设置使用 fake 获取器的补充获取，最少可用代理数为 minProxies
+func newTestTopUp(t *testing.T, pool *ProxyPool, minProxies int) *fakeTopUpFetcher {
+	t.Helper()
+
+	if _, err := pool.RuntimeConfig().Update([]byte(fmt.Sprintf(`{"min_proxies": %d}`, minProxies))); err != nil {
+		t.Fatalf("set min_proxies: %v", err)
+	}
+	fetcher := &fakeTopUpFetcher{fetched: make(chan struct{}, 1)}
+	topUp := &FetchTopUp{db: pool.DB(), logger: zap.NewNop(), fetcher: fetcher, cooldown: DefaultTopUpCooldown}
+	topUp.SetRuntimeConfig(pool.RuntimeConfig())
+	pool.SetFetchTopUp(topUp)
+	return fetcher
+}
+
+func TestAutoMaintenanceTriggersTopUpBelowMinimum(t *testing.T) {
+	pool, _ := newTestPool(t)
+	fetcher := newTestTopUp(t, pool, 4)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		newTestProxy(t, pool.DB(), ip, func(p *models.Proxy) { p.LastCheck = time.Now() })
+	}
+	// 成功率过低的代理由清理任务处理，维护任务不删除
+	stale := newTestProxy(t, pool.DB(), "4.4.4.4", func(p *models.Proxy) {
+		p.LastCheck = time.Now()
+		p.Success, p.Failure = 1, 9
+	})
+
+	// 可用代理达到下限时不获取
+	if err := pool.AutoMaintenance(); err != nil {
+		t.Fatalf("AutoMaintenance: %v", err)
+	}
+	select {
+	case <-fetcher.fetched:
+		t.Fatal("fetch triggered with enough available proxies")
+	case <-time.After(50 * time.Millisecond):
+	}
+	var kept models.Proxy
+	if err := pool.DB().Unscoped().First(&kept, stale.ID).Error; err != nil {
+		t.Fatalf("load stale proxy: %v", err)
+	}
+	if kept.DeletedAt.Valid || kept.Quarantined || !kept.Available {
+		t.Errorf("stale proxy after maintenance = deleted %v quarantined %v available %v, want untouched",
+			kept.DeletedAt.Valid, kept.Quarantined, kept.Available)
+	}
+
+	// 代理失效后低于下限，触发补充获取
+	pool.DB().Model(&models.Proxy{}).Where("ip = ?", "1.1.1.1").UpdateColumn("available", false)
+	if err := pool.AutoMaintenance(); err != nil {
+		t.Fatalf("AutoMaintenance: %v", err)
+	}
+	select {
+	case <-fetcher.fetched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch not triggered below the minimum")
+	}
+	if n := fetcher.paid.Load(); n != 1 {
+		t.Errorf("paid fetches = %d, want 1", n)
+	}
+}
+
+// waitTopUpIdle 等待后台补充获取结束
+func waitTopUpIdle(t *testing.T, topUp *FetchTopUp) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		topUp.mu.Lock()
+		running := topUp.running
+		topUp.mu.Unlock()
+		if !running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("top-up still running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCheckTopUpAfterCleanup(t *testing.T) {
+	tests := []struct {
+		name string
+		doom map[string]interface{}      // 使代理被本次清理删除的字段，创建钩子会重置检查时间，创建后再写入
+		run  func(pool *ProxyPool) error // 清理或优化，之后与定时任务一样调用 CheckTopUp
+	}{
+		{
+			name: "过期清理",
+			doom: map[string]interface{}{"last_check": time.Now().Add(-time.Hour)},
+			run:  func(pool *ProxyPool) error { return models.CleanupExpired(pool.DB()) },
+		},
+		{
+			name: "代理池优化",
+			doom: map[string]interface{}{"success": 1, "failure": 9},
+			run: func(pool *ProxyPool) error {
+				_, err := models.OptimizePool(pool.DB(), pool.MaintenanceConfig())
+				return err
+			},
+		},
+		{
+			name: "老化代理清理",
+			doom: map[string]interface{}{"created_at": time.Now().Add(-30 * 24 * time.Hour)},
+			run: func(pool *ProxyPool) error {
+				_, err := models.CleanupOldProxies(pool.DB(), 7*24*time.Hour)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, deficit := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/deficit=%v", tt.name, deficit), func(t *testing.T) {
+				pool, _ := newTestPool(t)
+				fetcher := newTestTopUp(t, pool, 3)
+				// 有缺口时清理前恰好达到下限，清理后低于下限；无缺口时清理后仍达到下限
+				healthy := 3
+				if deficit {
+					healthy = 2
+				}
+				for i := 1; i <= healthy; i++ {
+					newTestProxy(t, pool.DB(), fmt.Sprintf("1.1.1.%d", i))
+				}
+				doomed := newTestProxy(t, pool.DB(), "2.2.2.2")
+				pool.DB().Model(doomed).UpdateColumns(tt.doom)
+
+				if err := tt.run(pool); err != nil {
+					t.Fatalf("run: %v", err)
+				}
+				var remaining int64
+				pool.DB().Model(&models.Proxy{}).Count(&remaining)
+				if remaining != int64(healthy) {
+					t.Fatalf("remaining proxies = %d, want %d", remaining, healthy)
+				}
+				pool.CheckTopUp(tt.name)
+
+				select {
+				case <-fetcher.fetched:
+					if !deficit {
+						t.Error("fetch triggered without a deficit")
+					}
+				case <-time.After(100 * time.Millisecond):
+					if deficit {
+						t.Error("fetch not triggered after cleanup left a deficit")
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTopUpCooldownSuppressesFetch(t *testing.T) {
+	pool, _ := newTestPool(t)
+	newTestTopUp(t, pool, 3)
+	newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.LastCheck = time.Now() })
+
+	fetcher := &fakeTopUpFetcher{fetched: make(chan struct{}, 1), lastPaid: time.Now(), lastFree: time.Now()}
+	topUp := &FetchTopUp{db: pool.DB(), logger: zap.NewNop(), fetcher: fetcher, cooldown: time.Minute}
+	topUp.SetRuntimeConfig(pool.RuntimeConfig())
+
+	// 两类代理源都在冷却中，即使可用代理不足也不获取
+	if topUp.Check("测试") {
+		t.Error("Check triggered a fetch while both sources are cooling down")
+	}
+
+	// 只有付费代理源在冷却中，只获取免费代理
+	fetcher.lastFree = time.Now().Add(-2 * time.Minute)
+	if !topUp.Check("测试") {
+		t.Fatal("Check did not trigger a fetch after the free cooldown passed")
+	}
+	waitTopUpIdle(t, topUp)
+	if paid, free := fetcher.paid.Load(), fetcher.free.Load(); paid != 0 || free != 1 {
+		t.Errorf("fetches = %d paid, %d free, want only 1 free", paid, free)
+	}
+}
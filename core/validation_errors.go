@@ -0,0 +1,126 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ValidationErrorClass 验证错误分类
+type ValidationErrorClass string
+
+const (
+	ErrorClassNone      ValidationErrorClass = ""           // 无错误
+	ErrorClassDial      ValidationErrorClass = "dial"       // 无法连接代理
+	ErrorClassTimeout   ValidationErrorClass = "timeout"    // 连接或请求超时
+	ErrorClassProxyAuth ValidationErrorClass = "proxy_auth" // 代理要求认证
+	ErrorClassTLS       ValidationErrorClass = "tls"        // 经代理的TLS握手失败
+	ErrorClassTarget    ValidationErrorClass = "target"     // 代理正常，目标网站拒绝或返回异常状态码
+)
+
+// IsProxyFault 该类错误是否由代理本身导致，只有代理错误才计入失败次数
+func (c ValidationErrorClass) IsProxyFault() bool {
+	switch c {
+	case ErrorClassDial, ErrorClassTimeout, ErrorClassProxyAuth, ErrorClassTLS:
+		return true
+	}
+	return false
+}
+
+// classifyValidationError 对一次测试请求的结果分类
+// err 为请求错误，statusCode 为请求成功时的响应状态码
+func classifyValidationError(err error, statusCode int) ValidationErrorClass {
+	if err == nil {
+		switch {
+		case statusCode == http.StatusOK:
+			return ErrorClassNone
+		case statusCode == http.StatusProxyAuthRequired:
+			return ErrorClassProxyAuth
+		default:
+			return ErrorClassTarget
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	// HTTPS 经 CONNECT 隧道时，代理返回的非200状态以错误形式返回
+	if strings.Contains(err.Error(), http.StatusText(http.StatusProxyAuthRequired)) {
+		return ErrorClassProxyAuth
+	}
+
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) ||
+		errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) {
+		return ErrorClassTLS
+	}
+
+	// 其余错误（连接拒绝、DNS、CONNECT失败、连接被重置等）均视为无法通过代理连接
+	return ErrorClassDial
+}
+
+// TestURLStats 单个测试网站的验证统计
+type TestURLStats struct {
+	URL           string `json:"url"`
+	Success       int    `json:"success"`        // 成功次数
+	ProxyFailures int    `json:"proxy_failures"` // 代理导致的失败次数
+	TargetErrors  int    `json:"target_errors"`  // 目标网站导致的失败次数
+}
+
+// testURLStatsTracker 记录各测试网站的验证结果
+type testURLStatsTracker struct {
+	mu    sync.Mutex
+	stats map[string]*TestURLStats
+}
+
+func newTestURLStatsTracker() *testURLStatsTracker {
+	return &testURLStatsTracker{
+		stats: make(map[string]*TestURLStats),
+	}
+}
+
+// record 记录一次测试结果
+func (t *testURLStatsTracker) record(testURL string, class ValidationErrorClass) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.stats[testURL]
+	if !ok {
+		s = &TestURLStats{URL: testURL}
+		t.stats[testURL] = s
+	}
+
+	switch {
+	case class == ErrorClassNone:
+		s.Success++
+	case class.IsProxyFault():
+		s.ProxyFailures++
+	default:
+		s.TargetErrors++
+	}
+}
+
+// all 获取所有测试网站统计的副本，按URL排序
+func (t *testURLStatsTracker) all() []TestURLStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]TestURLStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// timeoutError 超时的网络错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyValidationError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   ValidationErrorClass
+		fault  bool
+	}{
+		{"ok", nil, http.StatusOK, ErrorClassNone, false},
+		{"target 403", nil, http.StatusForbidden, ErrorClassTarget, false},
+		{"target 503", nil, http.StatusServiceUnavailable, ErrorClassTarget, false},
+		{"proxy auth status", nil, http.StatusProxyAuthRequired, ErrorClassProxyAuth, true},
+		{"proxy auth on connect", errors.New("proxyconnect tcp: Proxy Authentication Required"), 0, ErrorClassProxyAuth, true},
+		{"timeout", &url.Error{Op: "Get", Err: timeoutError{}}, 0, ErrorClassTimeout, true},
+		{"tls", &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, 0, ErrorClassTLS, true},
+		{"dial", &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, 0, ErrorClassDial, true},
+	}
+	for _, tt := range tests {
+		got := classifyValidationError(tt.err, tt.status)
+		if got != tt.want || got.IsProxyFault() != tt.fault {
+			t.Errorf("%s: class = %q (proxy fault %v), want %q (%v)", tt.name, got, got.IsProxyFault(), tt.want, tt.fault)
+		}
+	}
+}
+
+func TestProbeSeparatesTargetErrorsFromProxyFaults(t *testing.T) {
+	// 作为HTTP代理的测试服务器，转发请求时目标网站返回403
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(upstreamURL.Port())
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	v := NewProxyValidator(nil, zap.NewNop(), 3)
+	v.SetTestURLs([]string{"http://check.invalid/"})
+
+	result, err := v.probe(context.Background(), &models.Proxy{IP: "127.0.0.1", Port: port, Protocol: "http"})
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if result.success || result.proxyFault || result.lastClass != ErrorClassTarget || result.last.statusCode != http.StatusForbidden {
+		t.Errorf("target refusal result = %+v, want a target error that is not the proxy's fault", result)
+	}
+
+	result, err = v.probe(context.Background(), &models.Proxy{IP: "127.0.0.1", Port: closedPort, Protocol: "http"})
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if result.success || !result.proxyFault || result.lastClass != ErrorClassDial {
+		t.Errorf("unreachable proxy result = %+v, want a dial failure counted against the proxy", result)
+	}
+
+	stats := v.TestURLStats()
+	if len(stats) != 1 || stats[0].TargetErrors != 1 || stats[0].ProxyFailures != 1 || stats[0].Success != 0 {
+		t.Errorf("test url stats = %+v, want one target error and one proxy failure", stats)
+	}
+}
@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"proxy_pool/metrics"
+	"proxy_pool/models"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultValidateJobTimeout = 30 * time.Second // 单个代理验证的最长时间
+	DefaultValidateRunTimeout = 5 * time.Minute  // 一轮验证的最长时间
+	validationQueueFactor     = 2                // 任务队列容量为工作协程数的倍数
+)
+
+var ErrValidationRunning = errors.New("validation run already active")
+
+// ValidationTypeStats 单个代理类型的验证结果
+type ValidationTypeStats struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	TimedOut  int `json:"timed_out"`
+}
+
+// ValidationStatus 验证服务状态
+type ValidationStatus struct {
+	Running    bool               `json:"running"`               // 是否有正在进行的验证
+	ProxyTypes []models.ProxyType `json:"proxy_types,omitempty"` // 本轮验证的代理类型，为空表示所有类型
+	InFlight   int64              `json:"in_flight"`             // 正在执行的验证数，包含已超时但尚未返回的验证
+	Queued     int                `json:"queued"`                // 队列中等待的代理数
+	Total      int                `json:"total"`                 // 本轮代理总数
+	Succeeded  int                `json:"succeeded"`             // 本轮验证成功数
+	Failed     int                `json:"failed"`                // 本轮验证失败数
+	TimedOut   int                `json:"timed_out"`             // 本轮验证超时数
+	StartedAt  time.Time          `json:"started_at"`            // 本轮开始时间
+	FinishedAt time.Time          `json:"finished_at"`           // 本轮结束时间
+	LastError  string             `json:"last_error"`            // 本轮错误信息
+
+	ByType map[models.ProxyType]ValidationTypeStats `json:"by_type"` // 本轮各代理类型的验证结果
+
+	URLStats []TestURLStats `json:"url_stats"` // 各测试网站的累计验证统计
+}
+
+// ValidationService 常驻验证服务
+// 定时任务只负责调用 Enqueue 触发一轮验证，验证在 Run 循环中按触发顺序逐轮执行；
+// 不同代理类型可以分别触发，同一组类型同时只能有一轮在等待或执行；
+// 所有轮次共享同一个并发信号量，即使验证阻塞在数据库上，同时执行的验证数也不会超过 workers
+type ValidationService struct {
+	validator  *ProxyValidator
+	logger     *zap.Logger
+	workers    int
+	jobTimeout time.Duration
+	runTimeout time.Duration
+
+	trigger  chan []models.ProxyType
+	sem      chan struct{}
+	inFlight int64
+
+	mu      sync.Mutex
+	pending map[string]bool // 等待执行的轮次，键为 typesKey
+	running string          // 正在执行的轮次，未执行时为 noRunKey
+	status  ValidationStatus
+	jobs    chan *models.Proxy
+}
+
+// NewValidationService 创建验证服务，需调用 Run 启动
+func NewValidationService(validator *ProxyValidator, logger *zap.Logger) *ValidationService {
+	workers := validator.maxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	return &ValidationService{
+		validator:  validator,
+		logger:     logger,
+		workers:    workers,
+		jobTimeout: DefaultValidateJobTimeout,
+		runTimeout: DefaultValidateRunTimeout,
+		trigger:    make(chan []models.ProxyType, len(models.AllProxyTypes)+1),
+		sem:        make(chan struct{}, workers),
+		pending:    make(map[string]bool),
+		running:    noRunKey,
+	}
+}
+
+// SetTimeouts 设置单个代理和整轮验证的超时时间，非正值表示保持不变
+func (s *ValidationService) SetTimeouts(job, run time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job > 0 {
+		s.jobTimeout = job
+	}
+	if run > 0 {
+		s.runTimeout = run
+	}
+}
+
+// Enqueue 触发一轮验证，指定 types 时只验证这些类型的代理
+// 相同类型的验证正在进行或等待执行、或等待队列已满时返回 ErrValidationRunning
+func (s *ValidationService) Enqueue(types ...models.ProxyType) (ValidationStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := typesKey(types)
+	if s.pending[key] || s.running == key {
+		return s.snapshot(), ErrValidationRunning
+	}
+	select {
+	case s.trigger <- types:
+		s.pending[key] = true
+	default:
+		return s.snapshot(), ErrValidationRunning
+	}
+	return s.snapshot(), nil
+}
+
+// noRunKey 没有正在执行的轮次，不会与任何 typesKey 相同
+const noRunKey = "-"
+
+// typesKey 代理类型组合的唯一标识，与顺序无关，空表示所有类型
+func typesKey(types []models.ProxyType) string {
+	keys := make([]string, len(types))
+	for i, t := range types {
+		keys[i] = string(t)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// Status 获取验证服务状态
+func (s *ValidationService) Status() ValidationStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+// snapshot 生成状态副本，调用方需持有 s.mu
+func (s *ValidationService) snapshot() ValidationStatus {
+	status := s.status
+	status.ByType = make(map[models.ProxyType]ValidationTypeStats, len(s.status.ByType))
+	for t, stats := range s.status.ByType {
+		status.ByType[t] = stats
+	}
+	status.InFlight = atomic.LoadInt64(&s.inFlight)
+	status.URLStats = s.validator.TestURLStats()
+	if s.jobs != nil {
+		status.Queued = len(s.jobs)
+	}
+	return status
+}
+
+// Run 验证循环，直到 ctx 取消
+func (s *ValidationService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case types := <-s.trigger:
+			s.runOnce(ctx, types)
+		}
+	}
+}
+
+// runOnce 执行一轮验证
+func (s *ValidationService) runOnce(parent context.Context, types []models.ProxyType) {
+	key := typesKey(types)
+
+	s.mu.Lock()
+	ctx, cancel := context.WithTimeout(parent, s.runTimeout)
+	jobTimeout := s.jobTimeout
+	jobs := make(chan *models.Proxy, s.workers*validationQueueFactor)
+	delete(s.pending, key)
+	s.running = key
+	s.jobs = jobs
+	s.status = ValidationStatus{
+		Running:    true,
+		ProxyTypes: types,
+		StartedAt:  time.Now(),
+		ByType:     make(map[models.ProxyType]ValidationTypeStats),
+	}
+	s.mu.Unlock()
+	defer cancel()
+
+	err := s.validateAll(ctx, jobs, jobTimeout, types)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if parent.Err() != nil {
+		metrics.ValidationCancelled.Inc()
+	}
+
+	s.mu.Lock()
+	s.jobs = nil
+	s.running = noRunKey
+	s.status.Running = false
+	s.status.FinishedAt = time.Now()
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	status := s.snapshot()
+	s.mu.Unlock()
+
+	for proxyType, stats := range status.ByType {
+		s.logger.Info("代理类型验证结果",
+			zap.String("代理类型", string(proxyType)),
+			zap.Int("总数", stats.Total),
+			zap.Int("成功数", stats.Succeeded),
+			zap.Int("失败数", stats.Failed),
+			zap.Int("超时数", stats.TimedOut),
+		)
+	}
+
+	if err != nil {
+		s.logger.Error("代理验证任务中止",
+			zap.Error(err),
+			zap.Any("代理类型", types),
+			zap.Int("总数", status.Total),
+			zap.Int("成功数", status.Succeeded),
+			zap.Int("失败数", status.Failed),
+			zap.Int("超时数", status.TimedOut),
+		)
+		return
+	}
+	s.logger.Info("代理验证任务完成",
+		zap.Any("代理类型", types),
+		zap.Int("总数", status.Total),
+		zap.Int("成功数", status.Succeeded),
+		zap.Int("失败数", status.Failed),
+		zap.Int("超时数", status.TimedOut),
+		zap.Duration("耗时", status.FinishedAt.Sub(status.StartedAt)),
+	)
+}
+
+// validateAll 加载指定类型的代理并分发给工作协程，ctx 到期时立即返回
+func (s *ValidationService) validateAll(ctx context.Context, jobs chan *models.Proxy, jobTimeout time.Duration, types []models.ProxyType) error {
+	var proxies []*models.Proxy
+	if err := models.TypeScope(s.validator.db.WithContext(ctx), types).Find(&proxies).Error; err != nil {
+		close(jobs)
+		return err
+	}
+
+	s.mu.Lock()
+	s.status.Total = len(proxies)
+	for _, proxy := range proxies {
+		stats := s.status.ByType[proxy.Type]
+		stats.Total++
+		s.status.ByType[proxy.Type] = stats
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for proxy := range jobs {
+				s.validateOne(ctx, proxy, jobTimeout)
+			}
+		}()
+	}
+
+	// 队列有界，生产者在队列满时等待，ctx 到期时停止分发
+dispatch:
+	for _, proxy := range proxies {
+		select {
+		case jobs <- proxy:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	return nil
+}
+
+// validateOne 在截止时间内验证单个代理
+// 超时后工作协程立即返回，验证协程在后台结束后才释放信号量
+func (s *ValidationService) validateOne(parent context.Context, proxy *models.Proxy, timeout time.Duration) {
+	if parent.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		s.recordResult(proxy.Type, false, true)
+		return
+	}
+
+	done := make(chan bool, 1)
+	atomic.AddInt64(&s.inFlight, 1)
+	go func() {
+		defer func() {
+			atomic.AddInt64(&s.inFlight, -1)
+			<-s.sem
+		}()
+		// 使用任务的 ctx 而不是单个代理的超时，超时后验证仍在后台完成，退出时则立即中止
+		err := s.validator.ValidateProxyContext(parent, proxy)
+		done <- err == nil && proxy.Available
+	}()
+
+	select {
+	case ok := <-done:
+		s.recordResult(proxy.Type, ok, false)
+	case <-ctx.Done():
+		s.logger.Warn("代理验证超时",
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+			zap.Duration("超时时间", timeout),
+		)
+		s.recordResult(proxy.Type, false, true)
+	}
+}
+
+// recordResult 记录单个代理的验证结果
+func (s *ValidationService) recordResult(proxyType models.ProxyType, ok bool, timedOut bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.status.ByType[proxyType]
+	switch {
+	case timedOut:
+		s.status.TimedOut++
+		stats.TimedOut++
+	case ok:
+		s.status.Succeeded++
+		stats.Succeeded++
+	default:
+		s.status.Failed++
+		stats.Failed++
+	}
+	s.status.ByType[proxyType] = stats
+}
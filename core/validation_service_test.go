@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+// startSlowProxy 启动一个本地HTTP代理，每个请求等待 delay 后返回200，记录同时处理的最大请求数
+func startSlowProxy(t *testing.T, delay time.Duration, peak *int64) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var active int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				n := atomic.AddInt64(&active, 1)
+				for {
+					old := atomic.LoadInt64(peak)
+					if n <= old || atomic.CompareAndSwapInt64(peak, old, n) {
+						break
+					}
+				}
+				time.Sleep(delay)
+				atomic.AddInt64(&active, -1)
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok"))
+			}(conn)
+		}
+	}()
+
+	_, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
+
+func TestValidationServiceBoundsConcurrency(t *testing.T) {
+	pool, _ := newTestPool(t)
+	var peak int64
+	port := startSlowProxy(t, 50*time.Millisecond, &peak)
+	for i := 0; i < 6; i++ {
+		p := newTestProxy(t, pool.DB(), "20.0.0."+strconv.Itoa(i+1))
+		// 本地代理地址是回环地址，绕过入库校验直接改写
+		pool.DB().Model(p).UpdateColumns(map[string]interface{}{"ip": "127.0.0.1", "port": port})
+	}
+
+	validator := NewProxyValidator(pool.DB(), zap.NewNop(), 3)
+	validator.maxWorkers = 2
+	validator.SetTestURLs([]string{"http://check.invalid/"})
+	service := NewValidationService(validator, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.Run(ctx)
+
+	if _, err := service.Enqueue(); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	// 同一组类型在等待或执行时不重复触发
+	if _, err := service.Enqueue(); !errors.Is(err, ErrValidationRunning) {
+		t.Errorf("second Enqueue error = %v, want %v", err, ErrValidationRunning)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var status ValidationStatus
+	for {
+		status = service.Status()
+		if !status.Running && !status.FinishedAt.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("validation run did not finish: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.Total != 6 || status.Succeeded+status.Failed+status.TimedOut != 6 || status.InFlight != 0 {
+		t.Errorf("status = %+v, want 6 proxies validated and none in flight", status)
+	}
+	if got := atomic.LoadInt64(&peak); got < 1 || got > 2 {
+		t.Errorf("peak concurrent validations = %d, want at most 2 workers", got)
+	}
+	// 上一轮结束后可以再次触发
+	if _, err := service.Enqueue(); err != nil {
+		t.Errorf("Enqueue after run finished: %v", err)
+	}
+}
+
+func TestValidationJobsPerType(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []ValidationJob
+	}{
+		{"default only", Config{ValidateInterval: "@every 1m"}, []ValidationJob{{Spec: "@every 1m"}}},
+		{"long separately", Config{
+			ValidateInterval:  "@every 1m",
+			ValidateIntervals: map[models.ProxyType]string{models.ProxyTypeLong: "@every 30m"},
+		}, []ValidationJob{
+			{Spec: "@every 30m", Types: []models.ProxyType{models.ProxyTypeLong}},
+			{Spec: "@every 1m", Types: []models.ProxyType{models.ProxyTypeTemp, models.ProxyTypeAnon, models.ProxyTypeHighAnon}},
+		}},
+		{"no default interval", Config{
+			ValidateIntervals: map[models.ProxyType]string{models.ProxyTypeTemp: "@every 10s"},
+		}, []ValidationJob{{Spec: "@every 10s", Types: []models.ProxyType{models.ProxyTypeTemp}}}},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.ValidationJobs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ValidationJobs = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidationServiceRunsOnlyRequestedTypes(t *testing.T) {
+	pool, _ := newTestPool(t)
+	var peak int64
+	port := startSlowProxy(t, 0, &peak)
+	for i, proxyType := range []models.ProxyType{models.ProxyTypeTemp, models.ProxyTypeTemp, models.ProxyTypeLong} {
+		p := newTestProxy(t, pool.DB(), "20.0.0."+strconv.Itoa(i+1), func(p *models.Proxy) { p.Type = proxyType })
+		pool.DB().Model(p).UpdateColumns(map[string]interface{}{"ip": "127.0.0.1", "port": port})
+	}
+
+	validator := NewProxyValidator(pool.DB(), zap.NewNop(), 3)
+	validator.SetTestURLs([]string{"http://check.invalid/"})
+	service := NewValidationService(validator, zap.NewNop())
+
+	// 不同类型的轮次可以同时等待，同一类型不重复触发
+	if _, err := service.Enqueue(models.ProxyTypeLong); err != nil {
+		t.Fatalf("Enqueue long: %v", err)
+	}
+	if _, err := service.Enqueue(models.ProxyTypeTemp); err != nil {
+		t.Fatalf("Enqueue temp: %v", err)
+	}
+	if _, err := service.Enqueue(models.ProxyTypeLong); !errors.Is(err, ErrValidationRunning) {
+		t.Errorf("second Enqueue long error = %v, want %v", err, ErrValidationRunning)
+	}
+
+	service.runOnce(context.Background(), <-service.trigger)
+	status := service.Status()
+	if status.Total != 1 || len(status.ByType) != 1 || status.ByType[models.ProxyTypeLong].Total != 1 {
+		t.Errorf("long run status = %+v, want only the long proxy", status)
+	}
+	service.runOnce(context.Background(), <-service.trigger)
+	if status := service.Status(); status.Total != 2 || status.ByType[models.ProxyTypeTemp].Total != 2 {
+		t.Errorf("temp run status = %+v, want the two temp proxies", status)
+	}
+}
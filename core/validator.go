@@ -1,10 +1,14 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"proxy_pool/metrics"
 	"proxy_pool/models"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,10 +21,17 @@ type ProxyValidator struct {
 	db           *gorm.DB
 	logger       *zap.Logger
 	client       *http.Client
-	maxWorkers   int           // 最大并发验证数
-	timeout      time.Duration // 单个代理验证超时时间
-	testURLs     []string      // 测试网站列表
-	maxFailCount int           // 最大失败次数
+	maxWorkers   int              // 最大并发验证数
+	maxFailCount int              // 最大失败次数
+	realtime     *RealtimeStats   // 实时统计，可为空
+	events       *EventBus        // 事件总线，可为空
+	webhooks     *WebhookNotifier // 验证结果回调，可为空
+	urlStats     *testURLStatsTracker
+
+	configMu sync.RWMutex        // 保护 timeout、testURLs 和 runtime，运行时可修改
+	timeout  time.Duration       // 单个代理验证超时时间，设置了运行时配置时不使用
+	testURLs []string            // 测试网站列表
+	runtime  *RuntimeConfigStore // 运行时配置，设置后最大失败次数和超时时间从中读取
 }
 
 // NewProxyValidator 创建代理验证器
@@ -28,121 +39,233 @@ func NewProxyValidator(db *gorm.DB, logger *zap.Logger, maxFailCount int) *Proxy
 	return &ProxyValidator{
 		db:         db,
 		logger:     logger,
-		maxWorkers: 50,              // 最大50个并发
-		timeout:    5 * time.Second, // 超时5秒
+		maxWorkers: 50,                      // 最大50个并发
+		timeout:    DefaultValidatorTimeout, // 超时5秒
 		testURLs: []string{
 			"http://www.baidu.com",
 			"https://store.steampowered.com",
 		},
 		maxFailCount: maxFailCount,
+		urlStats:     newTestURLStatsTracker(),
 	}
 }
 
+// SetTestURLs 设置测试网站列表，对之后开始的验证生效
+func (v *ProxyValidator) SetTestURLs(urls []string) {
+	v.configMu.Lock()
+	defer v.configMu.Unlock()
+	v.testURLs = append([]string(nil), urls...)
+	v.logger.Info("更新验证测试网站",
+		zap.Strings("测试网站", v.testURLs),
+	)
+}
+
+// TestURLs 获取测试网站列表的副本
+func (v *ProxyValidator) TestURLs() []string {
+	v.configMu.RLock()
+	defer v.configMu.RUnlock()
+	return append([]string(nil), v.testURLs...)
+}
+
+// SetRuntimeConfig 设置运行时配置，之后的验证使用其中的最大失败次数和超时时间
+func (v *ProxyValidator) SetRuntimeConfig(runtime *RuntimeConfigStore) {
+	v.configMu.Lock()
+	defer v.configMu.Unlock()
+	v.runtime = runtime
+}
+
+// SetTimeout 设置单个代理验证超时时间，对之后开始的验证生效
+// 设置了运行时配置时修改运行时配置
+func (v *ProxyValidator) SetTimeout(d time.Duration) {
+	v.configMu.Lock()
+	defer v.configMu.Unlock()
+	if v.runtime != nil {
+		if err := v.runtime.modify(func(c *RuntimeConfig) { c.ValidatorTimeoutSeconds = d.Seconds() }); err != nil {
+			v.logger.Error("更新验证超时时间失败", zap.Duration("超时时间", d), zap.Error(err))
+			return
+		}
+	} else {
+		v.timeout = d
+	}
+	v.logger.Info("更新验证超时时间",
+		zap.Duration("超时时间", d),
+	)
+}
+
+// Timeout 获取单个代理验证超时时间
+func (v *ProxyValidator) Timeout() time.Duration {
+	v.configMu.RLock()
+	defer v.configMu.RUnlock()
+	if v.runtime != nil {
+		return v.runtime.Get().ValidatorTimeout()
+	}
+	return v.timeout
+}
+
+// FailPolicy 获取最大失败次数，设置了运行时配置时可按代理类型区分
+func (v *ProxyValidator) FailPolicy() FailPolicy {
+	v.configMu.RLock()
+	defer v.configMu.RUnlock()
+	if v.runtime != nil {
+		return v.runtime.Get().FailPolicy
+	}
+	return FailPolicy{MaxFailCount: v.maxFailCount}
+}
+
+// TestURLStats 获取各测试网站的验证统计
+func (v *ProxyValidator) TestURLStats() []TestURLStats {
+	return v.urlStats.all()
+}
+
+// SetRealtimeStats 设置实时统计
+func (v *ProxyValidator) SetRealtimeStats(stats *RealtimeStats) {
+	v.realtime = stats
+}
+
+// SetEventBus 设置事件总线，删除代理时发布事件
+func (v *ProxyValidator) SetEventBus(bus *EventBus) {
+	v.events = bus
+}
+
+// SetWebhookNotifier 设置验证结果回调
+func (v *ProxyValidator) SetWebhookNotifier(notifier *WebhookNotifier) {
+	v.webhooks = notifier
+}
+
 // ValidateProxy 验证单个代理
 func (v *ProxyValidator) ValidateProxy(proxy *models.Proxy) error {
+	return v.ValidateProxyContext(context.Background(), proxy)
+}
+
+// ValidateProxyContext 验证代理，ctx 取消时中止访问测试网站并返回 ctx 的错误，
+// 此时不更新失败次数等状态，也不写入数据库，避免退出时把正常的代理记为失败
+func (v *ProxyValidator) ValidateProxyContext(ctx context.Context, proxy *models.Proxy) error {
+	defer v.realtime.ValidationStarted()()
+
 	v.logger.Debug("开始验证代理",
 		zap.String("IP", proxy.IP),
 		zap.Int("端口", proxy.Port),
 		zap.String("协议", proxy.Protocol),
 	)
 
-	// 构建代理URL
-	proxyURL := fmt.Sprintf("%s://%s:%d", proxy.Protocol, proxy.IP, proxy.Port)
-	parsedURL, err := url.Parse(proxyURL)
+	result, err := v.probe(ctx, proxy)
 	if err != nil {
-		v.logger.Error("代理URL解析失败",
-			zap.String("URL", proxyURL),
-			zap.Error(err),
-		)
 		return err
 	}
-
-	// 创建带代理的HTTP客户端
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyURL(parsedURL),
-		},
-		Timeout: v.timeout,
-	}
-
-	startTime := time.Now()
-	success := false
-	var lastErr error
-
-	// 尝试访问测试网站
-	for _, testURL := range v.testURLs {
-		v.logger.Debug("正在测试网站",
-			zap.String("IP", proxy.IP),
-			zap.Int("端口", proxy.Port),
-			zap.String("测试URL", testURL),
-		)
-
-		resp, err := client.Get(testURL)
-		if err != nil {
-			lastErr = err
-			v.logger.Debug("测试网站访问失败",
-				zap.String("IP", proxy.IP),
-				zap.Int("端口", proxy.Port),
-				zap.String("测试URL", testURL),
-				zap.Error(err),
-			)
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			success = true
-			v.logger.Debug("测试网站访问成功",
-				zap.String("IP", proxy.IP),
-				zap.Int("端口", proxy.Port),
-				zap.String("测试URL", testURL),
-				zap.Int("状态码", resp.StatusCode),
-			)
-			break
-		} else {
-			v.logger.Debug("测试网站返回非200状态码",
-				zap.String("IP", proxy.IP),
-				zap.Int("端口", proxy.Port),
-				zap.String("测试URL", testURL),
-				zap.Int("状态码", resp.StatusCode),
-			)
-		}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-
-	// 计算响应时间
-	responseTime := time.Since(startTime).Milliseconds()
+	success, proxyFault := result.success, result.proxyFault
+	lastErr, lastClass, last := result.lastErr, result.lastClass, result.last
+	responseTime := result.elapsed.Milliseconds()
 
 	// 更新代理状态
 	proxy.LastCheck = time.Now()
 	proxy.Speed = responseTime
 	proxy.Available = success
+	proxy.LastErrorClass = string(lastClass)
+	proxy.LastStatusCode = last.statusCode
+	proxy.LastLatency = last.latency.Milliseconds()
+	proxy.LastSuccessURL = ""
+	proxy.VerifiedHTTPS = false
+	if success {
+		proxy.LastSuccessURL = last.url
+		proxy.VerifiedHTTPS = result.https
+		proxy.RecordError("")
+	} else if lastErr != nil {
+		proxy.RecordError(lastErr.Error())
+	}
+	if success || proxyFault {
+		// 目标网站拒绝访问不是代理的问题，不影响连续成功/失败次数
+		proxy.RecordStreak(success)
+	}
 
 	if success {
 		proxy.FailCount = 0
+		proxy.Quarantined = false
 		v.logger.Info("代理验证成功",
 			zap.String("IP", proxy.IP),
 			zap.Int("端口", proxy.Port),
 			zap.Int64("响应时间(ms)", responseTime),
 		)
+	} else if !proxyFault {
+		// 代理可以连通，只是目标网站拒绝访问，不计入失败次数
+		v.logger.Info("代理验证未通过，目标网站拒绝访问",
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+			zap.Int("失败次数", proxy.FailCount),
+			zap.Error(lastErr),
+		)
 	} else {
+		// 最大失败次数按代理类型区分，代理源的清理策略可放宽失败次数，或以隔离代替删除
+		policy := models.CleanupPolicyFor(proxy.Source)
+		maxFailCount := v.FailPolicy().MaxFailCountForProxy(proxy)
+		mode := models.GetRetentionMode()
+
 		proxy.FailCount++
+		v.realtime.RecordFailure()
 		v.logger.Warn("代理验证失败",
 			zap.String("IP", proxy.IP),
 			zap.Int("端口", proxy.Port),
 			zap.Int("失败次数", proxy.FailCount),
-			zap.Int("最大失败次数", v.maxFailCount),
+			zap.Int("最大失败次数", maxFailCount),
 			zap.Error(lastErr),
 		)
 
-		// 如果失败次数超过最大值，删除代理
-		if proxy.FailCount >= v.maxFailCount {
+		switch {
+		case proxy.FailCount < maxFailCount:
+			// 未达到最大失败次数
+		case proxy.Pinned:
+			// 固定代理不删除，验证失败时已标记为不可用
+			v.logger.Info("代理失败次数超过限制，固定代理不删除",
+				zap.String("IP", proxy.IP),
+				zap.Int("端口", proxy.Port),
+				zap.Int("失败次数", proxy.FailCount),
+			)
+		case policy.InGracePeriod(proxy):
+			v.logger.Info("代理失败次数超过限制，仍在宽限期内，暂不清理",
+				zap.String("IP", proxy.IP),
+				zap.Int("端口", proxy.Port),
+				zap.String("来源", proxy.Source),
+				zap.Duration("宽限期", policy.GracePeriod),
+			)
+		case mode == models.RetentionObserve:
+			// 观察模式不删除也不隔离，验证失败时已标记为不可用
+			action := models.CleanupActionDelete
+			if policy.Quarantine {
+				action = models.CleanupActionQuarantine
+			}
+			v.logger.Info("代理失败次数超过限制，观察模式不清理",
+				zap.String("IP", proxy.IP),
+				zap.Int("端口", proxy.Port),
+				zap.Int("失败次数", proxy.FailCount),
+				zap.String("本应执行的操作", action),
+			)
+			models.NotifyRetained(models.RetainedProxy{ProxyID: proxy.ID, Type: proxy.Type, Score: proxy.Score, Action: action})
+		case policy.Quarantine || mode == models.RetentionQuarantine:
+			if !proxy.Quarantined {
+				v.logger.Info("代理失败次数超过限制，隔离代理",
+					zap.String("IP", proxy.IP),
+					zap.Int("端口", proxy.Port),
+					zap.String("来源", proxy.Source),
+					zap.Int("失败次数", proxy.FailCount),
+				)
+			}
+			proxy.Quarantine()
+		default:
+			// 失败次数超过最大值，删除代理
 			v.logger.Info("代理失败次数超过限制，删除代理",
 				zap.String("IP", proxy.IP),
 				zap.Int("端口", proxy.Port),
 				zap.Int("失败次数", proxy.FailCount),
-				zap.Int("最大失败次数", v.maxFailCount),
+				zap.Int("最大失败次数", maxFailCount),
 			)
-			return v.db.Delete(proxy).Error
+			if err := v.db.Delete(proxy).Error; err != nil {
+				return err
+			}
+			v.events.Publish(NewProxyEvent(EventProxyRemoved, proxy))
+			v.webhooks.ProxyValidated(proxy, true, lastErr)
+			return nil
 		}
 	}
 
@@ -156,15 +279,167 @@ func (v *ProxyValidator) ValidateProxy(proxy *models.Proxy) error {
 		return err
 	}
 
+	if success {
+		lastErr = nil
+	}
+	v.webhooks.ProxyValidated(proxy, false, lastErr)
+	return nil
+}
+
+// probeResult 通过代理依次访问测试网站的结果
+type probeResult struct {
+	success    bool                 // 是否有测试网站访问成功
+	proxyFault bool                 // 失败中是否有代理本身的问题
+	lastErr    error                // 最后一个失败的测试网站的错误
+	lastClass  ValidationErrorClass // 最后一个失败的测试网站的错误分类
+	last       urlProbe             // 最后访问的测试网站的结果
+	elapsed    time.Duration        // 访问所有测试网站的总耗时
+	https      bool                 // 是否通过了HTTPS检查，只在有测试网站访问成功时检查
+}
+
+// probe 通过代理依次访问测试网站，直到有一个通过或 ctx 取消，不修改代理也不写入数据库
+func (v *ProxyValidator) probe(ctx context.Context, proxy *models.Proxy) (probeResult, error) {
+	var result probeResult
+
+	// 构建代理URL
+	proxyURL := fmt.Sprintf("%s://%s:%d", proxy.Protocol, proxy.IP, proxy.Port)
+	parsedURL, err := url.Parse(proxyURL)
+	if err != nil {
+		v.logger.Error("代理URL解析失败",
+			zap.String("URL", proxyURL),
+			zap.Error(err),
+		)
+		return result, err
+	}
+
+	// 创建带代理的HTTP客户端
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(parsedURL),
+		},
+		Timeout: v.Timeout(),
+	}
+
+	startTime := time.Now()
+	result.lastClass = ErrorClassNone
+	for _, testURL := range v.TestURLs() {
+		if ctx.Err() != nil {
+			break
+		}
+		result.last = v.probeURL(ctx, client, proxy, testURL)
+		if result.last.class == ErrorClassNone {
+			result.success = true
+			break
+		}
+		result.lastErr, result.lastClass = result.last.err, result.last.class
+		result.proxyFault = result.proxyFault || result.last.class.IsProxyFault()
+	}
+	result.elapsed = time.Since(startTime)
+
+	// 成功的测试网站为HTTP时另外检查代理能否访问HTTPS网站
+	if result.success {
+		result.https = strings.HasPrefix(result.last.url, "https://") || v.checkHTTPS(ctx, client, proxy) == nil
+	}
+	return result, nil
+}
+
+// Probe 检查尚未入库的代理是否可用，只在访问测试网站成功时返回nil，成功时记录响应时间和是否通过HTTPS检查
+// 与 ValidateProxy 不同，不修改失败次数等状态，也不写入数据库
+func (v *ProxyValidator) Probe(proxy *models.Proxy) error {
+	result, err := v.probe(context.Background(), proxy)
+	if err != nil {
+		return err
+	}
+	if !result.success {
+		if result.lastErr == nil {
+			return errors.New("no test url configured")
+		}
+		return result.lastErr
+	}
+	proxy.Speed = result.elapsed.Milliseconds()
+	proxy.VerifiedHTTPS = result.https
 	return nil
 }
 
-// ValidateAll 验证所有代理
-func (v *ProxyValidator) ValidateAll() error {
-	v.logger.Info("开始验证所有代理")
+// urlProbe 通过代理访问单个测试网站的结果
+type urlProbe struct {
+	url        string
+	statusCode int // 连接失败时为0
+	latency    time.Duration
+	class      ValidationErrorClass
+	err        error
+}
+
+// probeURL 通过代理访问测试网站并记录该网站的验证统计
+func (v *ProxyValidator) probeURL(ctx context.Context, client *http.Client, proxy *models.Proxy, testURL string) urlProbe {
+	v.logger.Debug("正在测试网站",
+		zap.String("IP", proxy.IP),
+		zap.Int("端口", proxy.Port),
+		zap.String("测试URL", testURL),
+	)
+
+	start := time.Now()
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, testURL, nil)
+	if err == nil {
+		resp, err = client.Do(req)
+	}
+	probe := urlProbe{url: testURL, latency: time.Since(start)}
+	if err != nil {
+		probe.class, probe.err = classifyValidationError(err, 0), err
+		if ctx.Err() != nil {
+			// 取消导致的失败不是测试网站或代理的问题，不计入统计
+			return probe
+		}
+		v.urlStats.record(testURL, probe.class)
+		v.logger.Debug("测试网站访问失败",
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+			zap.String("测试URL", testURL),
+			zap.String("错误分类", string(probe.class)),
+			zap.Error(err),
+		)
+		return probe
+	}
+	resp.Body.Close()
+
+	probe.statusCode = resp.StatusCode
+	probe.class = classifyValidationError(nil, resp.StatusCode)
+	v.urlStats.record(testURL, probe.class)
+	if probe.class == ErrorClassNone {
+		v.logger.Debug("测试网站访问成功",
+			zap.String("IP", proxy.IP),
+			zap.Int("端口", proxy.Port),
+			zap.String("测试URL", testURL),
+			zap.Int("状态码", resp.StatusCode),
+		)
+		return probe
+	}
+
+	probe.err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	v.logger.Debug("测试网站返回非200状态码",
+		zap.String("IP", proxy.IP),
+		zap.Int("端口", proxy.Port),
+		zap.String("测试URL", testURL),
+		zap.Int("状态码", resp.StatusCode),
+		zap.String("错误分类", string(probe.class)),
+	)
+	return probe
+}
+
+// validateResult 单个代理的验证结果
+type validateResult struct {
+	proxyType models.ProxyType
+	ok        bool
+}
+
+// ValidateAll 验证所有代理，指定 types 时只验证这些类型的代理
+// ctx 取消时停止分发，工作协程不再开始新的验证，进行中的验证随之中止，返回 ctx 的错误
+func (v *ProxyValidator) ValidateAll(ctx context.Context, types ...models.ProxyType) error {
+	v.logger.Info("开始验证所有代理", zap.Any("代理类型", types))
 
 	var proxies []*models.Proxy
-	if err := v.db.Find(&proxies).Error; err != nil {
+	if err := models.TypeScope(v.db.WithContext(ctx), types).Find(&proxies).Error; err != nil {
 		v.logger.Error("获取代理列表失败", zap.Error(err))
 		return err
 	}
@@ -179,9 +454,9 @@ func (v *ProxyValidator) ValidateAll() error {
 		zap.Int("数量", totalCount),
 	)
 
-	// 创建工作池
-	jobs := make(chan *models.Proxy, totalCount)
-	results := make(chan bool, totalCount)
+	// 创建工作池，任务逐个分发，取消后剩余的代理不再入队
+	jobs := make(chan *models.Proxy)
+	results := make(chan validateResult, totalCount)
 	var wg sync.WaitGroup
 
 	// 启动工作协程
@@ -198,16 +473,35 @@ func (v *ProxyValidator) ValidateAll() error {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			for proxy := range jobs {
-				err := v.ValidateProxy(proxy)
-				results <- err == nil && proxy.Available
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case proxy, ok := <-jobs:
+					if !ok {
+						return
+					}
+					if ctx.Err() != nil {
+						return
+					}
+					err := v.ValidateProxyContext(ctx, proxy)
+					if ctx.Err() != nil {
+						return
+					}
+					results <- validateResult{proxyType: proxy.Type, ok: err == nil && proxy.Available}
+				}
 			}
 		}(i)
 	}
 
-	// 发送任务
+	// 发送任务，取消时关闭任务通道以释放等待中的工作协程
+send:
 	for _, proxy := range proxies {
-		jobs <- proxy
+		select {
+		case jobs <- proxy:
+		case <-ctx.Done():
+			break send
+		}
 	}
 	close(jobs)
 
@@ -220,14 +514,42 @@ func (v *ProxyValidator) ValidateAll() error {
 	// 统计结果
 	successCount := 0
 	failCount := 0
+	byType := make(map[models.ProxyType]*ValidationTypeStats)
 	for result := range results {
-		if result {
+		stats, ok := byType[result.proxyType]
+		if !ok {
+			stats = &ValidationTypeStats{}
+			byType[result.proxyType] = stats
+		}
+		stats.Total++
+		if result.ok {
 			successCount++
+			stats.Succeeded++
 		} else {
 			failCount++
+			stats.Failed++
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		metrics.ValidationCancelled.Inc()
+		v.logger.Warn("代理验证已取消",
+			zap.Int("总数", totalCount),
+			zap.Int("已完成数", successCount+failCount),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	for proxyType, stats := range byType {
+		v.logger.Info("代理类型验证完成",
+			zap.String("代理类型", string(proxyType)),
+			zap.Int("总数", stats.Total),
+			zap.Int("成功数", stats.Succeeded),
+			zap.Int("失败数", stats.Failed),
+		)
+	}
+
 	v.logger.Info("代理验证完成",
 		zap.Int("总数", totalCount),
 		zap.Int("成功数", successCount),
@@ -237,3 +559,51 @@ func (v *ProxyValidator) ValidateAll() error {
 
 	return nil
 }
+
+// ValidateStale 验证指定代理中入库后尚未验证过的代理，返回验证的代理数
+// 代理源直接入库的代理检查时间为创建时间，之后没有再验证过
+func (v *ProxyValidator) ValidateStale(ctx context.Context, ids []uint) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var proxies []*models.Proxy
+	err := v.db.WithContext(ctx).
+		Where("id IN ? AND last_check <= created_at", ids).
+		Find(&proxies).Error
+	if err != nil {
+		return 0, err
+	}
+
+	workers := v.maxWorkers
+	if len(proxies) < workers {
+		workers = len(proxies)
+	}
+
+	jobs := make(chan *models.Proxy)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for proxy := range jobs {
+				v.ValidateProxy(proxy)
+			}
+		}()
+	}
+
+	validated := 0
+dispatch:
+	for _, proxy := range proxies {
+		select {
+		case jobs <- proxy:
+			validated++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return validated, ctx.Err()
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"context"
+	"proxy_pool/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultWarmupProxies          = 200             // 默认预热验证的代理数
+	DefaultWarmupTimeout          = 2 * time.Minute // 默认预热最长时间
+	DefaultWarmupProgressInterval = 5 * time.Second // 默认预热进度输出间隔
+)
+
+// 预热状态
+const (
+	WarmupPending  = "pending"   // 尚未开始
+	WarmupWarming  = "warming"   // 正在预热
+	WarmupDone     = "done"      // 预热完成
+	WarmupTimedOut = "timed_out" // 预热超时，仍视为就绪
+	WarmupCanceled = "canceled"  // 收到退出信号，预热中止
+)
+
+// WarmupStatus 启动预热状态
+type WarmupStatus struct {
+	State      string    `json:"state"`
+	Validated  int       `json:"validated"` // 已验证的代理数
+	Total      int       `json:"total"`     // 本次预热需验证的代理数
+	Fetched    bool      `json:"fetched"`   // 是否因代理不足执行了获取
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Ready 预热是否已结束，超时也视为就绪
+func (s WarmupStatus) Ready() bool {
+	return s.State == WarmupDone || s.State == WarmupTimedOut
+}
+
+// Warmup 启动预热
+// 服务重启后代理的检查时间可能已过去很久，Run 在就绪前先验证最久未检查的一批代理，
+// 可用代理不足 minProxies 时先获取一次代理
+type Warmup struct {
+	db        *gorm.DB
+	logger    *zap.Logger
+	validator *ProxyValidator
+	fetcher   *ProxyFetcher // 为空时不获取代理
+
+	mu               sync.Mutex
+	proxies          int
+	minProxies       int
+	timeout          time.Duration
+	progressInterval time.Duration
+	status           WarmupStatus
+}
+
+// NewWarmup 创建启动预热，需调用 Run 执行
+func NewWarmup(db *gorm.DB, logger *zap.Logger, validator *ProxyValidator, fetcher *ProxyFetcher) *Warmup {
+	return &Warmup{
+		db:               db,
+		logger:           logger,
+		validator:        validator,
+		fetcher:          fetcher,
+		proxies:          DefaultWarmupProxies,
+		timeout:          DefaultWarmupTimeout,
+		progressInterval: DefaultWarmupProgressInterval,
+		status:           WarmupStatus{State: WarmupPending},
+	}
+}
+
+// SetLimits 设置预热代理数、触发获取的最少可用代理数和预热最长时间，非正值表示保持不变
+func (w *Warmup) SetLimits(proxies, minProxies int, timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if proxies > 0 {
+		w.proxies = proxies
+	}
+	if minProxies > 0 {
+		w.minProxies = minProxies
+	}
+	if timeout > 0 {
+		w.timeout = timeout
+	}
+}
+
+// SetProgressInterval 设置进度输出间隔，非正值表示保持不变
+func (w *Warmup) SetProgressInterval(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if interval > 0 {
+		w.progressInterval = interval
+	}
+}
+
+// Status 获取预热状态，未配置预热时视为已完成
+func (w *Warmup) Status() WarmupStatus {
+	if w == nil {
+		return WarmupStatus{State: WarmupDone}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Run 执行预热，超时或 ctx 取消时停止
+func (w *Warmup) Run(ctx context.Context) {
+	w.mu.Lock()
+	limit, minProxies := w.proxies, w.minProxies
+	timeout, interval := w.timeout, w.progressInterval
+	w.status = WarmupStatus{State: WarmupWarming, StartedAt: time.Now()}
+	w.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	w.logger.Info("开始启动预热",
+		zap.Int("预热代理数", limit),
+		zap.Int("最少可用代理数", minProxies),
+		zap.Duration("最长时间", timeout),
+	)
+
+	w.fetchIfNeeded(ctx, minProxies)
+
+	var proxies []*models.Proxy
+	err := w.db.WithContext(ctx).Order("last_check ASC").Limit(limit).Find(&proxies).Error
+	if err != nil {
+		w.logger.Error("获取预热代理失败", zap.Error(err))
+	}
+
+	w.mu.Lock()
+	w.status.Total = len(proxies)
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.validate(ctx, proxies)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-done:
+			waiting = false
+		case <-ticker.C:
+			status := w.Status()
+			w.logger.Info("启动预热进行中",
+				zap.Int("已验证", status.Validated),
+				zap.Int("总数", status.Total),
+			)
+		}
+	}
+
+	w.finish(ctx.Err())
+}
+
+// fetchIfNeeded 可用代理不足时获取一次代理
+func (w *Warmup) fetchIfNeeded(ctx context.Context, minProxies int) {
+	if w.fetcher == nil || minProxies <= 0 {
+		return
+	}
+
+	var available int64
+	if err := w.db.WithContext(ctx).Model(&models.Proxy{}).Where("available = ?", true).Count(&available).Error; err != nil {
+		w.logger.Error("统计可用代理失败", zap.Error(err))
+		return
+	}
+	if available >= int64(minProxies) {
+		return
+	}
+
+	w.logger.Info("可用代理不足，预热前先获取代理",
+		zap.Int64("可用代理数", available),
+		zap.Int("最少可用代理数", minProxies),
+	)
+	if _, err := w.fetcher.FetchProxies(); err != nil {
+		w.logger.Error("预热获取代理失败", zap.Error(err))
+	}
+
+	w.mu.Lock()
+	w.status.Fetched = true
+	w.mu.Unlock()
+}
+
+// validate 并发验证代理，ctx 到期后不再分发新的代理
+func (w *Warmup) validate(ctx context.Context, proxies []*models.Proxy) {
+	workers := w.validator.maxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	jobs := make(chan *models.Proxy)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for proxy := range jobs {
+				w.validator.ValidateProxy(proxy)
+				w.mu.Lock()
+				w.status.Validated++
+				w.mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, proxy := range proxies {
+		select {
+		case jobs <- proxy:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// finish 记录预热结果
+func (w *Warmup) finish(err error) {
+	w.mu.Lock()
+	w.status.FinishedAt = time.Now()
+	switch err {
+	case nil:
+		w.status.State = WarmupDone
+	case context.DeadlineExceeded:
+		w.status.State = WarmupTimedOut
+	default:
+		w.status.State = WarmupCanceled
+	}
+	status := w.status
+	w.mu.Unlock()
+
+	w.logger.Info("启动预热结束",
+		zap.String("状态", status.State),
+		zap.Int("已验证", status.Validated),
+		zap.Int("总数", status.Total),
+		zap.Bool("已获取代理", status.Fetched),
+		zap.Duration("耗时", status.FinishedAt.Sub(status.StartedAt)),
+	)
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startCountingProxy 启动一个本地HTTP代理，记录收到的请求数
+func startCountingProxy(t *testing.T, count *int64) int {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(count, 1)
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+func TestWarmupValidatesStalestProxies(t *testing.T) {
+	pool, _ := newTestPool(t)
+	// 同一地址和端口只能有一个代理，每个代理使用各自的本地代理
+	var stale, fresh int64
+	checks := []struct {
+		age  time.Duration
+		port int
+	}{
+		{3 * time.Hour, startCountingProxy(t, &stale)},
+		{2 * time.Hour, startCountingProxy(t, &stale)},
+		{time.Minute, startCountingProxy(t, &fresh)},
+	}
+	for i, c := range checks {
+		p := newTestProxy(t, pool.DB(), "20.0.0."+strconv.Itoa(i+1))
+		// 本地代理地址是回环地址，绕过入库校验直接改写
+		pool.DB().Model(p).UpdateColumns(map[string]interface{}{
+			"ip": "127.0.0.1", "port": c.port, "last_check": time.Now().Add(-c.age),
+		})
+	}
+
+	validator := NewProxyValidator(pool.DB(), zap.NewNop(), 3)
+	validator.SetTestURLs([]string{"http://check.invalid/"})
+	warmup := NewWarmup(pool.DB(), zap.NewNop(), validator, nil)
+	warmup.SetLimits(2, 0, 5*time.Second)
+
+	if status := warmup.Status(); status.State != WarmupPending || status.Ready() {
+		t.Fatalf("status before Run = %+v, want pending and not ready", status)
+	}
+	var unset *Warmup
+	if !unset.Status().Ready() {
+		t.Error("pool without warmup is not ready")
+	}
+
+	warmup.Run(context.Background())
+
+	status := warmup.Status()
+	if status.State != WarmupDone || !status.Ready() || status.Total != 2 || status.Validated != 2 || status.Fetched {
+		t.Errorf("status = %+v, want done with the 2 stalest proxies validated", status)
+	}
+	// 验证成功后还会通过代理检查HTTPS，每个代理收到2个请求
+	if got := atomic.LoadInt64(&stale); got != 4 {
+		t.Errorf("stale proxies received %d requests, want 4", got)
+	}
+	if got := atomic.LoadInt64(&fresh); got != 0 {
+		t.Errorf("recently checked proxy received %d requests, want 0", got)
+	}
+}
+
+func TestWarmupTimesOut(t *testing.T) {
+	pool, _ := newTestPool(t)
+	var peak int64
+	port := startSlowProxy(t, 200*time.Millisecond, &peak)
+	for i := 0; i < 4; i++ {
+		p := newTestProxy(t, pool.DB(), "20.0.0."+strconv.Itoa(i+1))
+		pool.DB().Model(p).UpdateColumns(map[string]interface{}{"ip": "127.0.0.1", "port": port})
+	}
+
+	validator := NewProxyValidator(pool.DB(), zap.NewNop(), 3)
+	validator.maxWorkers = 1
+	validator.SetTestURLs([]string{"http://check.invalid/"})
+	warmup := NewWarmup(pool.DB(), zap.NewNop(), validator, nil)
+	warmup.SetLimits(10, 0, 50*time.Millisecond)
+
+	warmup.Run(context.Background())
+
+	// 超时后不再分发新的代理，但仍视为就绪
+	status := warmup.Status()
+	if status.State != WarmupTimedOut || !status.Ready() {
+		t.Errorf("state = %q, want %q and ready", status.State, WarmupTimedOut)
+	}
+	if status.Total != 4 || status.Validated >= status.Total {
+		t.Errorf("status = %+v, want validation cut short", status)
+	}
+}
+
+func TestReadyWaitsForWarmup(t *testing.T) {
+	pool, _ := newTestPool(t)
+	validator := NewProxyValidator(pool.DB(), zap.NewNop(), 3)
+	warmup := NewWarmup(pool.DB(), zap.NewNop(), validator, nil)
+	pool.SetWarmup(warmup)
+
+	if pool.WarmupStatus().Ready() {
+		t.Fatal("pool ready before warmup ran")
+	}
+	warmup.Run(context.Background())
+	if status := pool.WarmupStatus(); !status.Ready() || status.Total != 0 {
+		t.Errorf("status = %+v, want ready with nothing to validate", status)
+	}
+}
@@ -0,0 +1,309 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"proxy_pool/core/redact"
+	"proxy_pool/models"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultWebhookMaxAttempts = 5                // 默认最多投递次数
+	DefaultWebhookBackoff     = time.Second      // 默认首次重试间隔，之后每次翻倍
+	webhookRequestTimeout     = 10 * time.Second // 单次投递超时时间
+	callbackResolveTimeout    = 5 * time.Second  // 注册回调时解析主机的超时时间
+
+	// WebhookSignatureHeader 回调签名请求头，值为 sha256=<请求体的HMAC-SHA256十六进制>
+	WebhookSignatureHeader = "X-Proxy-Pool-Signature"
+)
+
+var (
+	ErrInvalidCallbackURL = errors.New("invalid callback url")
+	ErrWebhooksDisabled   = errors.New("webhooks not configured")
+)
+
+// ValidationOutcome 代理验证结果回调内容
+type ValidationOutcome struct {
+	ProxyID     uint      `json:"proxy_id"`
+	IP          string    `json:"ip"`
+	Port        int       `json:"port"`
+	Available   bool      `json:"available"`
+	Speed       int64     `json:"speed"`                 // 响应时间(毫秒)
+	Error       string    `json:"error,omitempty"`       // 验证失败原因
+	ErrorClass  string    `json:"error_class,omitempty"` // 验证错误分类
+	Deleted     bool      `json:"deleted"`               // 是否因失败次数过多被删除
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// ValidateCallbackURL 检查回调地址，只允许 http 和 https，主机解析出的地址必须都是公网地址
+// 投递时连接的地址还会再检查一次，避免注册后域名改为解析到内网地址
+func ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidCallbackURL, raw)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: resolve %q: %v", ErrInvalidCallbackURL, u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if err := models.CheckPublicIP(addr.IP); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidCallbackURL, err)
+		}
+	}
+	return nil
+}
+
+// newCallbackClient 创建投递回调的HTTP客户端，只连接公网地址，重定向到内网地址时同样拒绝
+func newCallbackClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookRequestTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return models.CheckPublicIP(net.ParseIP(host))
+		},
+	}
+	return &http.Client{
+		Timeout:   webhookRequestTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+// SignWebhookPayload 计算回调签名
+func SignWebhookPayload(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookNotifier 代理验证结果回调
+// 代理首次验证完成或因失败被删除时，向注册的回调地址投递结果；
+// 投递失败时按指数退避重试，仍失败则写入死信表
+type WebhookNotifier struct {
+	db     *gorm.DB
+	logger *zap.Logger
+	client *http.Client
+	secret []byte
+
+	mu          sync.RWMutex
+	pending     map[uint]bool // 等待回调的代理ID，避免每次验证都查询数据库
+	maxAttempts int
+	backoff     time.Duration
+
+	wg sync.WaitGroup
+}
+
+// NewWebhookNotifier 创建回调通知器，secret 为空时不签名
+func NewWebhookNotifier(db *gorm.DB, logger *zap.Logger, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		db:          db,
+		logger:      logger,
+		client:      newCallbackClient(),
+		secret:      []byte(secret),
+		pending:     make(map[uint]bool),
+		maxAttempts: DefaultWebhookMaxAttempts,
+		backoff:     DefaultWebhookBackoff,
+	}
+}
+
+// SetRetry 设置最多投递次数和首次重试间隔，非正值表示保持不变
+func (n *WebhookNotifier) SetRetry(maxAttempts int, backoff time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if maxAttempts > 0 {
+		n.maxAttempts = maxAttempts
+	}
+	if backoff > 0 {
+		n.backoff = backoff
+	}
+}
+
+// LoadPending 从数据库加载等待回调的代理，启动时调用
+func (n *WebhookNotifier) LoadPending() error {
+	ids, err := models.ListPendingCallbackIDs(n.db)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, id := range ids {
+		n.pending[id] = true
+	}
+	return nil
+}
+
+// Register 为代理注册回调地址
+func (n *WebhookNotifier) Register(proxyID uint, callbackURL string) error {
+	if err := ValidateCallbackURL(callbackURL); err != nil {
+		return err
+	}
+	if err := models.SetProxyCallback(n.db, proxyID, callbackURL); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.pending[proxyID] = true
+	n.mu.Unlock()
+	return nil
+}
+
+// ProxyValidated 代理验证完成，有回调时异步投递结果
+func (n *WebhookNotifier) ProxyValidated(proxy *models.Proxy, deleted bool, validateErr error) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	if !n.pending[proxy.ID] {
+		n.mu.Unlock()
+		return
+	}
+	delete(n.pending, proxy.ID)
+	n.mu.Unlock()
+
+	callback, err := models.TakeProxyCallback(n.db, proxy.ID)
+	if err != nil {
+		n.logger.Error("获取代理回调地址失败",
+			zap.Uint("代理ID", proxy.ID),
+			zap.Error(err),
+		)
+		return
+	}
+	if callback == nil {
+		return
+	}
+
+	outcome := ValidationOutcome{
+		ProxyID:     proxy.ID,
+		IP:          proxy.IP,
+		Port:        proxy.Port,
+		Available:   proxy.Available,
+		Speed:       proxy.Speed,
+		ErrorClass:  proxy.LastErrorClass,
+		Deleted:     deleted,
+		ValidatedAt: proxy.LastCheck,
+	}
+	if validateErr != nil {
+		outcome.Error = validateErr.Error()
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.deliver(callback.URL, outcome)
+	}()
+}
+
+// Wait 等待所有进行中的投递结束
+func (n *WebhookNotifier) Wait() {
+	n.wg.Wait()
+}
+
+// deliver 投递回调，失败时按指数退避重试，全部失败后写入死信表
+func (n *WebhookNotifier) deliver(callbackURL string, outcome ValidationOutcome) {
+	body, err := json.Marshal(outcome)
+	if err != nil {
+		n.logger.Error("回调内容序列化失败", zap.Error(err))
+		return
+	}
+
+	n.mu.RLock()
+	maxAttempts, backoff := n.maxAttempts, n.backoff
+	n.mu.RUnlock()
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if lastErr = redact.Error(n.post(callbackURL, body)); lastErr == nil {
+			n.logger.Debug("代理验证回调投递成功",
+				zap.Uint("代理ID", outcome.ProxyID),
+				zap.String("回调地址", redact.URL(callbackURL)),
+				zap.Int("尝试次数", attempt),
+			)
+			return
+		}
+
+		n.logger.Warn("代理验证回调投递失败",
+			zap.Uint("代理ID", outcome.ProxyID),
+			zap.String("回调地址", redact.URL(callbackURL)),
+			zap.Int("尝试次数", attempt),
+			zap.Error(lastErr),
+		)
+		if attempt < maxAttempts {
+			time.Sleep(backoff << (attempt - 1))
+		}
+	}
+
+	n.logger.Error("代理验证回调多次投递失败，写入死信表",
+		zap.Uint("代理ID", outcome.ProxyID),
+		zap.String("回调地址", redact.URL(callbackURL)),
+		zap.Int("尝试次数", maxAttempts),
+		zap.Error(lastErr),
+	)
+	deadLetter := &models.WebhookDeadLetter{
+		ProxyID:   outcome.ProxyID,
+		URL:       callbackURL,
+		Payload:   string(body),
+		Attempts:  maxAttempts,
+		LastError: truncate(lastErr.Error(), 512),
+	}
+	if err := n.db.Create(deadLetter).Error; err != nil {
+		n.logger.Error("写入回调死信失败",
+			zap.Uint("代理ID", outcome.ProxyID),
+			zap.String("内容", string(body)),
+			zap.Error(err),
+		)
+	}
+}
+
+// post 发送一次回调请求，非2xx响应视为失败
+func (n *WebhookNotifier) post(callbackURL string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// truncate 截断字符串到指定字节数
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
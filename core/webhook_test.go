@@ -0,0 +1,163 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy_pool/models"
+
+	"go.uber.org/zap"
+)
+
+func TestValidateCallbackURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://8.8.8.8/hook", true},
+		{"http://[2001:4860:4860::8888]:8080/hook", true},
+		{"ftp://8.8.8.8/hook", false},
+		{"https:///hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://localhost/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://192.168.1.10:8080/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://host.invalid/hook", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := ValidateCallbackURL(tt.url)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateCallbackURL(%q) = %v, want nil", tt.url, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidCallbackURL) {
+				t.Errorf("ValidateCallbackURL(%q) = %v, want %v", tt.url, err, ErrInvalidCallbackURL)
+			}
+		})
+	}
+}
+
+func TestCallbackClientRefusesPrivateDial(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer srv.Close()
+
+	// 注册后域名改为解析到内网地址时，投递在连接时被拒绝
+	n := NewWebhookNotifier(nil, zap.NewNop(), "")
+	err := n.post(srv.URL, []byte("{}"))
+	if !errors.Is(err, models.ErrLoopbackIP) {
+		t.Errorf("post to loopback error = %v, want %v", err, models.ErrLoopbackIP)
+	}
+	if hit {
+		t.Error("callback reached a loopback server")
+	}
+}
+
+// newLoopbackNotifier 创建可以投递到本地 httptest 接收端的回调通知器
+func newLoopbackNotifier(t *testing.T, pool *ProxyPool, srv *httptest.Server, secret string) *WebhookNotifier {
+	t.Helper()
+	n := NewWebhookNotifier(pool.DB(), zap.NewNop(), secret)
+	n.client = srv.Client()
+	n.SetRetry(3, time.Millisecond)
+	return n
+}
+
+// registerLoopback 跳过地址检查为代理登记本地回调地址
+func registerLoopback(t *testing.T, n *WebhookNotifier, proxyID uint, url string) {
+	t.Helper()
+	if err := models.SetProxyCallback(n.db, proxyID, url); err != nil {
+		t.Fatalf("SetProxyCallback: %v", err)
+	}
+	n.pending[proxyID] = true
+}
+
+func TestWebhookDeliversSignedOutcome(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1", func(p *models.Proxy) { p.Speed = 120 })
+
+	var (
+		mu        sync.Mutex
+		body      []byte
+		signature string
+		requests  int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+	}))
+	defer srv.Close()
+
+	n := newLoopbackNotifier(t, pool, srv, "secret")
+	registerLoopback(t, n, proxy.ID, srv.URL)
+
+	n.ProxyValidated(proxy, false, nil)
+	n.Wait()
+	// 回调只投递一次
+	n.ProxyValidated(proxy, false, nil)
+	n.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Fatalf("callback requests = %d, want 1", requests)
+	}
+	if want := SignWebhookPayload([]byte("secret"), body); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	var outcome ValidationOutcome
+	if err := json.Unmarshal(body, &outcome); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if outcome.ProxyID != proxy.ID || !outcome.Available || outcome.Speed != 120 || outcome.Deleted {
+		t.Errorf("outcome = %+v, want proxy %d available with speed 120", outcome, proxy.ID)
+	}
+}
+
+func TestWebhookDeadLetterAfterRetries(t *testing.T) {
+	pool, _ := newTestPool(t)
+	proxy := newTestProxy(t, pool.DB(), "1.1.1.1")
+
+	var mu sync.Mutex
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	n := newLoopbackNotifier(t, pool, srv, "")
+	registerLoopback(t, n, proxy.ID, srv.URL)
+
+	n.ProxyValidated(proxy, true, errors.New("timeout"))
+	n.Wait()
+
+	mu.Lock()
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	mu.Unlock()
+
+	var letters []models.WebhookDeadLetter
+	pool.DB().Find(&letters)
+	if len(letters) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(letters))
+	}
+	if letters[0].ProxyID != proxy.ID || letters[0].Attempts != 3 {
+		t.Errorf("dead letter = %+v, want proxy %d after 3 attempts", letters[0], proxy.ID)
+	}
+}
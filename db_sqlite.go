@@ -0,0 +1,15 @@
+//go:build sqlite
+
+package main
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// 使用 -tags sqlite 编译时注册SQLite驱动，需要cgo
+func init() {
+	sqliteDialector = func(dsn string) gorm.Dialector {
+		return sqlite.Open(dsn)
+	}
+}
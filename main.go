@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"proxy_pool/api"
 	"proxy_pool/core"
+	siteconfig "proxy_pool/core/config"
+	"proxy_pool/core/sources/free"
 	"proxy_pool/models"
+	"syscall"
+	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gorm.io/driver/mysql"
@@ -62,13 +69,61 @@ func initLogger() (*zap.Logger, error) {
 	return logger, nil
 }
 
+// 各数据库驱动的默认连接串
+const (
+	defaultMySQLDSN  = "root:root@tcp(127.0.0.1:3306)/proxy_pool?charset=utf8mb4&parseTime=True&loc=Local"
+	defaultSQLiteDSN = "./proxy_pool.db"
+)
+
+// sqliteDialector 由 db_sqlite.go 在使用 -tags sqlite 编译时设置，默认编译不依赖cgo
+var sqliteDialector func(dsn string) gorm.Dialector
+
+// 根据驱动名创建数据库方言
+func openDialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "mysql":
+		if dsn == "" {
+			dsn = defaultMySQLDSN
+		}
+		return mysql.Open(dsn), nil
+	case "sqlite":
+		if sqliteDialector == nil {
+			return nil, errors.New("sqlite support not compiled in, rebuild with -tags sqlite")
+		}
+		if dsn == "" {
+			dsn = defaultSQLiteDSN
+		}
+		return sqliteDialector(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
 // 初始化数据库
-func initDB() (*gorm.DB, error) {
-	dsn := "root:root@tcp(127.0.0.1:3306)/proxy_pool?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+func initDB(config *core.Config) (*gorm.DB, error) {
+	dialector, err := openDialector(config.DBDriver, config.DBDSN)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
+	if config.DBMaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.DBMaxIdleConns)
+	}
+	if config.DBMaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.DBMaxOpenConns)
+	}
+	// SQLite 不支持并发写，只保留一个连接避免 database is locked
+	if db.Dialector.Name() == "sqlite" {
+		sqlDB.SetMaxOpenConns(1)
+	}
 
 	// 自动迁移数据库表结构
 	if err := models.AutoMigrate(db); err != nil {
@@ -85,12 +140,69 @@ var redisClient = redis.NewClient(&redis.Options{
 	DB:       0,  // 默认DB
 })
 
-// 启动HTTP服务
-func startHTTPServer(pool *core.ProxyPool, logger *zap.Logger) {
-	server := api.NewServer(pool)
-	if err := server.Run(":8080"); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+// shutdownTimeout 退出时等待处理中请求完成的最长时间
+const shutdownTimeout = 10 * time.Second
+
+// partnerAPIKeys 合作方使用的密钥，只能获取代理、导出订阅和上报代理状态，key 为空时返回nil
+func partnerAPIKeys(key string) []core.APIKey {
+	if key == "" {
+		return nil
+	}
+	return []core.APIKey{{
+		Name:              "partner",
+		Key:               key,
+		Scopes:            []core.APIScope{core.ScopeRead, core.ScopeReport},
+		RequestsPerMinute: 600, // 每分钟最多600次请求
+	}}
+}
+
+// 创建API服务
+func newAPIServer(pool *core.ProxyPool, config *core.Config, logger *zap.Logger) *api.Server {
+	server := api.NewServer(pool, api.RecoveryContributor{Logger: logger})
+	server.SetAPIKey(config.APIKey)
+	server.SetAPIKeys(config.APIKeys)
+	server.SetTimeouts(config.HTTPReadHeaderTimeout, config.HTTPWriteTimeout)
+	server.SetMaxListLimit(config.MaxListLimit)
+	if config.EnablePprof {
+		server.AddContributor(api.PProfContributor{})
+		logger.Info("已开启pprof性能分析接口", zap.String("路径", "/debug/pprof"))
 	}
+	return server
+}
+
+// 启动HTTP服务，监听成功后返回，HTTPS在后台启动
+func startHTTPServer(server *api.Server, config *core.Config, logger *zap.Logger) {
+	logger.Info("API服务监听地址", zap.Strings("地址", config.ListenAddrs))
+
+	if !config.TLSEnabled {
+		if len(config.ListenAddrs) == 0 {
+			logger.Fatal("Failed to start server", zap.Error(errors.New("no listen address")))
+		}
+		var errs []error
+		for _, addr := range config.ListenAddrs {
+			if err := server.Start(addr); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			logger.Fatal("Failed to start server", zap.Error(err))
+		}
+		return
+	}
+
+	if config.HTTPRedirectAddr != "" && len(config.ListenAddrs) > 0 {
+		go func() {
+			if err := server.RedirectToHTTPS(config.HTTPRedirectAddr, config.ListenAddrs[0]); err != nil {
+				logger.Error("HTTP重定向服务启动失败", zap.String("地址", config.HTTPRedirectAddr), zap.Error(err))
+			}
+		}()
+	}
+	logger.Info("以HTTPS提供API服务", zap.String("证书", config.TLSCertFile))
+	go func() {
+		if err := server.RunMultiTLS(config.TLSCertFile, config.TLSKeyFile, config.ListenAddrs...); err != nil {
+			logger.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
 }
 
 func main() {
@@ -106,6 +218,10 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 收到退出信号时取消 ctx，后台任务随之停止
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	logger.Info("========================================")
 	logger.Info("           代理池服务启动")
 	logger.Info("========================================")
@@ -115,40 +231,235 @@ func main() {
 		zap.String("日志级别", "INFO"),
 	)
 
-	// 初始化数据库
-	db, err := initDB()
-	if err != nil {
-		logger.Fatal("数据库连接失败", zap.Error(err))
-	}
-	logger.Info("数据库连接成功")
-
 	// 创建代理获取器配置
 	config := &core.Config{
 		// API配置
-		KuaidailiURL: "https://dps.kdlapi.com/api/getdps/?secret_id=oxu5r8ejomi6uy3kk753&signature=0wwtxxe3uhtba21zegp6b2ehyj36fx91&num=1&pt=1&format=json&sep=1&dedup=1",
+		KuaidailiURL: "https://dps.kdlapi.com/api/getdps/?secret_id=oxu5r8ejomi6uy3kk753&signature=0wwtxxe3uhtba21zegp6b2ehyj36fx91&num=1&pt=1&format=json&sep=1&dedup=1&f_et=1",
 		WandouURL:    "",
 		UseFreeAPI:   false,
 
+		GeoNodeMaxPages: 5, // GeoNode每次最多获取5页
+
 		// 定时任务配置
-		PaidInterval:     "*/30 * * * * *", // 每30秒获取一次付费代理
-		FreeInterval:     "0 */5 * * * *",  // 每5分钟获取一次免费代理
-		ValidateInterval: "0 */1 * * * *",  // 每1分钟验证一次代理
-		CleanupInterval:  "0 0 * * * *",    // 每小时清理一次过期代理
-		OptimizeInterval: "0 0 */6 * * *",  // 每6小时优化一次代理池
+		PaidInterval:        "*/30 * * * * *", // 每30秒获取一次付费代理
+		FreeInterval:        "0 */5 * * * *",  // 每5分钟获取一次免费代理
+		ValidateInterval:    "0 */1 * * * *",  // 每1分钟验证一次代理
+		CleanupInterval:     "0 0 * * * *",    // 每小时清理一次过期代理
+		OptimizeInterval:    "0 0 */6 * * *",  // 每6小时优化一次代理池
+		AgeCleanupInterval:  "0 0 0 * * 0",    // 每周清理一次老化代理
+		OrphanSweepInterval: "0 15 3 * * *",   // 每天凌晨清理一次已删除代理的子表记录
+		MaintenanceInterval: "0 0 * * * *",    // 每小时自动维护一次代理池
+
+		// 按类型的验证间隔，未列出的类型使用 ValidateInterval
+		ValidateIntervals: map[models.ProxyType]string{
+			models.ProxyTypeLong: "0 */30 * * * *", // 长效代理每30分钟验证一次
+		},
 
 		// 代理验证配置
-		MaxFailCount: 5, // 连续失败3次后删除代理
+		MaxFailCount: 5, // 连续失败5次后删除代理
+		TypeMaxFailCount: map[models.ProxyType]int{
+			models.ProxyTypeTemp: 2, // 临时代理有效期短，失败2次即删除
+		},
+
+		// 通过API添加代理的配置
+		APIDuplicatePolicy: core.DuplicateReject, // 已存在的代理返回409
+
+		// 代理地址配置
+		ResolveHostnames: false, // 拒绝以主机名提供的代理，开启后解析为IP入库
+
+		// 代理老化配置
+		MaxProxyAge: core.DefaultMaxProxyAge, // 代理最长保留7天
+
+		// 并发配置
+		TypeMaxConcurrent: map[models.ProxyType]int{
+			models.ProxyTypeLong: 50, // 长效代理默认50并发
+		},
+		SourceMaxConcurrent:    map[string]int{}, // 如 {"kuaidaili": 100}，优先于按类型的配置
+		HighScoreThreshold:     80.0,             // 评分80以上的代理
+		HighScoreMaxConcurrent: 20,               // 最大并发数提高到20
+
+		// 验证服务配置
+		ValidateJobTimeout: core.DefaultValidateJobTimeout, // 单个代理最多验证30秒
+		ValidateRunTimeout: core.DefaultValidateRunTimeout, // 一轮验证最多5分钟
+
+		// 启动预热配置
+		WarmupEnabled:    true,
+		WarmupProxies:    core.DefaultWarmupProxies, // 启动时验证最久未检查的200个代理
+		WarmupMinProxies: 20,                        // 可用代理少于20个时先获取一次
+		WarmupTimeout:    core.DefaultWarmupTimeout, // 预热最多2分钟
+
+		// 实时统计配置
+		HandoutWindow: core.DefaultHandoutWindow, // 统计最近1分钟发放的代理
+		FailureWindow: core.DefaultFailureWindow, // 统计最近5分钟的失败
+
+		// 代理信誉配置
+		ReputationBanTTL:          core.DefaultReputationBanTTL,    // 封禁上报24小时内有效
+		ReputationDecayDays:       core.DefaultReputationDecayDays, // 7天没有新的封禁上报后清除记录
+		ReputationCleanupInterval: "0 30 * * * *",                  // 每小时清理一次上报记录
+
+		// 验证结果回调配置
+		WebhookSecret:      os.Getenv("PROXY_POOL_WEBHOOK_SECRET"), // 回调签名密钥从环境变量读取
+		WebhookMaxAttempts: core.DefaultWebhookMaxAttempts,         // 最多投递5次
+		WebhookBackoff:     core.DefaultWebhookBackoff,             // 重试间隔从1秒开始翻倍
+
+		// Redis降级配置
+		RedisFailureThreshold: core.DefaultRedisFailureThreshold, // 连续失败5次后绕过Redis
+		RedisProbeInterval:    core.DefaultRedisProbeInterval,    // 每5秒检查一次Redis
+		RedisKeyPrefix:        core.DefaultRedisKeyPrefix,        // 键形如 proxy_pool:realtime:handed_out:...
+
+		// 负载均衡配置
+		BalancerRefreshInterval: core.DefaultBalancerRefreshInterval, // 缓存每30秒刷新一次
+		BalancerMode:            core.ModeWeighted,                   // 按评分加权随机，高分代理被选中的次数更多
+
+		// 存活检查配置
+		ProbeTopN:     core.DefaultProbeTopN,     // 每轮检查最近发放的50个代理
+		ProbeInterval: core.DefaultProbeInterval, // 每15秒检查一次
+		ProbeType:     core.ProbeTCP,             // 只检查能否建立TCP连接
+
+		// 调度器配置，全局使用默认的失败3次冷却5分钟
+		Scheduler: core.SchedulerConfig{
+			Strategies: map[core.ScheduleStrategy]core.CooldownPolicy{
+				core.StrategyFailover:   {FailThreshold: 1, CooldownBaseSeconds: 600, CooldownMaxSeconds: 3600}, // 失败1次即冷却10分钟，之后翻倍，最长1小时
+				core.StrategyRoundRobin: {FailThreshold: 10, CooldownBaseSeconds: 10, CooldownMaxSeconds: 30},   // 失败10次才冷却，最长30秒
+			},
+		},
+
+		// 快速通道配置
+		FastPathBufferSize:      core.DefaultFastPathBufferSize,      // 每种代理类型预选32个候选代理
+		FastPathRefreshInterval: core.DefaultFastPathRefreshInterval, // 每秒刷新一次
+
+		// 代理源配置
+		SourceTypeConfig: map[string]models.ProxyType{
+			"kuaidaili_paid": models.ProxyTypeLong, // 快代理私密代理为长效代理
+		},
+
+		// 清理策略，付费代理容忍更多失败、隔离代替删除，创建后一天内不清理
+		SourceCleanupPolicies: map[string]models.CleanupPolicy{
+			"kuaidaili_paid": {MaxFailCount: 10, Quarantine: true, GracePeriod: 24 * time.Hour},
+			"wandou_paid":    {MaxFailCount: 10, Quarantine: true, GracePeriod: 24 * time.Hour},
+		},
+		RetentionMode: models.RetentionDelete, // 改为 observe 时只标记为不可用并发布 proxy_retained 事件，不删除代理
+
+		// 标签配置
+		SourceTags: map[string][]string{}, // 如 {"kuaidaili": {"paid"}}，为代理源获取的代理添加默认标签
+
+		// 免费代理源协议配置
+		RequiredProtocols: nil, // 如 []string{"socks5"}，只获取提供SOCKS5代理的免费代理源
+
+		// 礼貌抓取配置，未列出的免费代理源同一主机请求间隔2-3秒、不并发
+		SourcePoliteness: map[string]free.Politeness{
+			"ip3366": {MinDelay: 5 * time.Second, Jitter: 3 * time.Second}, // ip3366 对频繁请求返回验证码
+		},
+
+		// 鉴权配置
+		APIKey:  os.Getenv("PROXY_POOL_API_KEY"),                         // 订阅导出的API密钥从环境变量读取
+		APIKeys: partnerAPIKeys(os.Getenv("PROXY_POOL_PARTNER_API_KEY")), // 合作方只读密钥，未设置时不启用按权限范围鉴权
+
+		// 调度记录配置
+		DecisionLogSize:       core.DefaultDecisionLogSize, // 保留最近10000次发放记录
+		DecisionFlushInterval: "",                          // 如 "*/10 * * * * *"，每10秒写入Redis
+
+		// 发放前验证配置，注册了站点配置的域名(buff.163.com)默认允许
+		VerifyAllowedDomains: []string{},                // 如 {"example.com"}
+		VerifyTimeout:        core.DefaultVerifyTimeout, // 单次验证最多3秒
+
+		// 数据库配置，开发环境可设置 PROXY_POOL_DB_DRIVER=sqlite
+		DBDriver: os.Getenv("PROXY_POOL_DB_DRIVER"), // 为空时使用mysql
+		DBDSN:    os.Getenv("PROXY_POOL_DB_DSN"),    // 为空时使用驱动的默认连接串
+
+		DBMaxOpenConns: 50, // 最多50个连接
+		DBMaxIdleConns: 10, // 保留10个空闲连接
+
+		// 运行时配置，如 {"max_fail_count": 5, "scoring_weights": {"speed": 0.4}}
+		RuntimeConfigFile: os.Getenv("PROXY_POOL_RUNTIME_CONFIG"), // 为空时不监听配置文件
+
+		// 补充获取配置
+		MinProxies:    100,                       // 清理后可用代理少于100个时立即获取
+		TopUpCooldown: core.DefaultTopUpCooldown, // 同一类代理源5分钟内不重复补充获取
+
+		// 预取配置
+		NearExpiryWindow: core.DefaultNearExpiryWindow, // 剩余有效时长不足5分钟的付费代理提前获取替代代理
+		PrefetchCooldown: core.DefaultPrefetchCooldown, // 提前获取付费代理至少间隔2分钟
+
+		// 监听配置
+		// Linux 上 ":8080" 或 "[::]:8080" 已同时接受IPv4和IPv6（除非开启了 net.ipv6.bindv6only），
+		// 再监听 0.0.0.0:8080 会因端口被占用而失败；多个地址用于分别监听指定网卡的地址
+		ListenAddrs: []string{":8080"},
+
+		HTTPReadHeaderTimeout: 10 * time.Second,        // 10秒内未发完请求头的连接直接关闭
+		HTTPWriteTimeout:      6 * time.Minute,         // 同步获取代理最长等待5分钟
+		MaxListLimit:          api.DefaultMaxListLimit, // 更多代理通过 /api/proxies/stream 导出
+
+		// HTTPS配置
+		TLSEnabled:       false,
+		TLSCertFile:      "./certs/server.crt",
+		TLSKeyFile:       "./certs/server.key",
+		HTTPRedirectAddr: ":80", // 开启HTTPS后80端口的请求重定向到HTTPS
+
+		// 调试配置
+		EnablePprof: false, // 生产环境不开启pprof
 	}
 
+	// 启动前检查配置，避免定时任务表达式等错误到运行时才发现
+	if err := config.Validate(); err != nil {
+		logger.Fatal("配置无效", zap.Error(err))
+	}
+
+	// 初始化数据库
+	db, err := initDB(config)
+	if err != nil {
+		logger.Fatal("数据库连接失败", zap.Error(err))
+	}
+	logger.Info("数据库连接成功", zap.String("驱动", db.Dialector.Name()))
+
+	// 新代理的默认最大并发数
+	models.SetConcurrencyDefaults(config.ConcurrencyDefaults())
+
+	// 各代理源的自动清理策略
+	models.SetCleanupPolicies(config.SourceCleanupPolicies)
+
+	// 入库时是否解析主机名
+	models.SetResolveHostnames(config.ResolveHostnames)
+
 	// 创建代理池
 	pool := core.NewProxyPool(db, redisClient, logger)
-	pool.SetMaxFailCount(config.MaxFailCount) // 设置最大失败次数
+	if err := pool.RuntimeConfig().Set(config.RuntimeConfig()); err != nil { // 设置最大失败次数、维护阈值等运行时配置
+		logger.Fatal("运行时配置无效", zap.Error(err))
+	}
+	models.SetRetentionObserver(pool.PublishRetained) // 观察模式下本应清理的代理发布 proxy_retained 事件
+	if config.RuntimeConfigFile != "" {
+		if err := pool.RuntimeConfig().WatchFile(ctx, config.RuntimeConfigFile); err != nil {
+			logger.Fatal("读取运行时配置文件失败", zap.String("文件", config.RuntimeConfigFile), zap.Error(err))
+		}
+	}
+	pool.SetBalancerRefreshInterval(config.BalancerRefreshInterval) // 设置负载均衡器刷新间隔
+	if config.BalancerMode != "" {
+		pool.SetBalancerMode(config.BalancerMode) // 设置负载均衡器选择代理的方式
+	}
+	pool.SetReputationBanTTL(config.ReputationBanTTL)  // 设置封禁上报有效期
+	pool.SetMaxProxyAge(config.GetMaxProxyAge())       // 设置评分衰减的代理年龄
+	pool.SetDuplicatePolicy(config.APIDuplicatePolicy) // 设置API添加已存在代理时的处理方式
+	pool.RealtimeStats().SetWindows(config.HandoutWindow, config.FailureWindow)
+	pool.DecisionLog().SetSize(config.DecisionLogSize)
+	pool.TargetVerifier().SetAllowedDomains(config.VerifyAllowedDomains)
+	pool.TargetVerifier().SetTimeout(config.VerifyTimeout)
+	buff163 := siteconfig.DefaultBuff163Config()
+	if err := pool.TargetVerifier().RegisterSite(buff163); err != nil {
+		logger.Fatal("注册站点配置失败", zap.Error(err))
+	}
+	pool.RegisterSiteConfig("buff.163.com", buff163)
+	pool.RedisGuard().SetPolicy(config.RedisFailureThreshold, config.RedisProbeInterval)
+	pool.RedisGuard().SetKeyPrefix(config.RedisKeyPrefix)
+	pool.SetFastPath(config.FastPathBufferSize, config.FastPathRefreshInterval)
+	go pool.RedisGuard().Run(ctx)
 	logger.Info("代理池初始化完成",
 		zap.Int("最大失败次数", config.MaxFailCount),
 	)
 
 	// 创建代理获取器
 	fetcher := core.NewProxyFetcher(db, logger, config)
+	fetcher.SetRealtimeStats(pool.RealtimeStats())
+	fetcher.SetEventBus(pool.Events())
 	logger.Info("代理获取器初始化完成",
 		zap.String("付费代理获取间隔", config.PaidInterval),
 		zap.String("免费代理获取间隔", config.FreeInterval),
@@ -157,114 +468,251 @@ func main() {
 		zap.String("代理池优化间隔", config.OptimizeInterval),
 		zap.Int("最大失败次数", config.MaxFailCount),
 	)
+	if err := fetcher.SeedSources(); err != nil {
+		logger.Error("写入代理源设置失败", zap.Error(err))
+	}
 
 	// 创建代理验证器
 	validator := core.NewProxyValidator(db, logger, config.MaxFailCount)
+	validator.SetRuntimeConfig(pool.RuntimeConfig())
+	validator.SetRealtimeStats(pool.RealtimeStats())
+	validator.SetEventBus(pool.Events())
+	pool.SetValidator(validator)
+
+	// 创建验证结果回调
+	webhooks := core.NewWebhookNotifier(db, logger, config.WebhookSecret)
+	webhooks.SetRetry(config.WebhookMaxAttempts, config.WebhookBackoff)
+	if err := webhooks.LoadPending(); err != nil {
+		logger.Error("加载待回调代理失败", zap.Error(err))
+	}
+	if config.WebhookSecret == "" {
+		logger.Warn("未配置回调签名密钥，验证结果回调将不带签名")
+	}
+	validator.SetWebhookNotifier(webhooks)
+	pool.SetWebhookNotifier(webhooks)
+	fetcher.SetValidator(validator)
+	pool.SetFetcher(fetcher)
+
+	// 清理和优化后可用代理不足时立即补充获取
+	topUp := core.NewFetchTopUp(db, logger, fetcher, config.MaintenanceConfig().MinProxies)
+	topUp.SetCooldown(config.TopUpCooldown)
+	topUp.SetRuntimeConfig(pool.RuntimeConfig())
+	pool.SetFetchTopUp(topUp)
+
+	// 可用代理接近下限时提前获取付费代理
+	if config.KuaidailiURL != "" || config.WandouURL != "" {
+		pool.StartPrefetchMonitor(ctx, fetcher)
+	}
+
+	// 创建常驻验证服务，定时任务只负责触发
+	validationService := core.NewValidationService(validator, logger)
+	validationService.SetTimeouts(config.ValidateJobTimeout, config.ValidateRunTimeout)
+	pool.SetValidationService(validationService)
+	go validationService.Run(ctx)
+
+	// 过期前未上报的代理发放计为结果未知
+	go pool.Handouts().Run(ctx)
+
+	// 回收超时未上报的代理占用，避免代理一直停留在并发上限
+	go pool.RunLeaseExpiry(ctx)
+
+	// 两轮验证之间检查正在发放的代理是否存活
+	if config.ProbeTopN > 0 {
+		go core.NewProber(pool, config.ProbeType, config.ProbeTopN, config.ProbeInterval).Run(ctx)
+	}
+
 	logger.Info("代理验证器初始化完成",
 		zap.Int("最大失败次数", config.MaxFailCount),
 	)
 
-	// 立即执行一次测试
-	//logger.Info("========================================")
-	//logger.Info("           执行初始测试")
-	//logger.Info("========================================")
-	//if err := fetcher.FetchProxies(); err != nil {
-	//	logger.Error("初始测试失败", zap.Error(err))
-	//}
+	// 创建启动预热，预热结束前就绪检查返回未就绪
+	var warmup *core.Warmup
+	if config.WarmupEnabled {
+		warmup = core.NewWarmup(db, logger, validator, fetcher)
+		warmup.SetLimits(config.WarmupProxies, config.WarmupMinProxies, config.WarmupTimeout)
+		pool.SetWarmup(warmup)
+	}
 
 	// 创建定时任务
-	c := cron.New(cron.WithSeconds(), cron.WithChain(
-		cron.SkipIfStillRunning(cron.DefaultLogger),
-	))
+	jobs := core.NewCronJobs(logger)
+	pool.SetCronJobs(jobs)
 	logger.Info("定时任务管理器初始化完成")
 
 	// 付费代理获取任务
 	if config.KuaidailiURL != "" || config.WandouURL != "" {
-		_, err = c.AddFunc(config.PaidInterval, func() {
+		jobs.Add("fetch_paid", config.PaidInterval, func() {
 			logger.Info("========================================")
 			logger.Info("           定时任务：付费代理获取")
 			logger.Info("========================================")
-			if err := fetcher.FetchPaidProxies(); err != nil {
+			result, err := fetcher.FetchPaidProxies()
+			if err != nil {
 				logger.Error("付费代理获取任务失败", zap.Error(err))
 			}
+			logger.Info("付费代理获取任务完成", result.Fields()...)
 		})
-		if err != nil {
-			logger.Fatal("添加付费代理获取定时任务失败", zap.Error(err))
-		}
 	}
 
 	// 免费代理获取任务
 	if config.UseFreeAPI {
-		_, err = c.AddFunc(config.FreeInterval, func() {
+		jobs.Add("fetch_free", config.FreeInterval, func() {
 			logger.Info("========================================")
 			logger.Info("           定时任务：免费代理获取")
 			logger.Info("========================================")
-			if err := fetcher.FetchFreeProxies(); err != nil {
+			result, err := fetcher.FetchFreeProxies()
+			if err != nil {
 				logger.Error("免费代理获取任务失败", zap.Error(err))
 			}
+			logger.Info("免费代理获取任务完成", result.Fields()...)
 		})
-		if err != nil {
-			logger.Fatal("添加免费代理获取定时任务失败", zap.Error(err))
-		}
 	}
 
-	// 代理验证任务
-	_, err = c.AddFunc(config.ValidateInterval, func() {
-		logger.Info("========================================")
-		logger.Info("           定时任务：代理验证")
-		logger.Info("========================================")
-		if err := validator.ValidateAll(); err != nil {
-			logger.Error("代理验证任务失败", zap.Error(err))
-		}
-	})
-	if err != nil {
-		logger.Fatal("添加代理验证定时任务失败", zap.Error(err))
+	// 代理验证任务，按类型分别注册
+	for _, job := range config.ValidationJobs() {
+		types := job.Types
+		jobs.Add(job.Name(), job.Spec, func() {
+			logger.Info("========================================")
+			logger.Info("           定时任务：代理验证")
+			logger.Info("========================================")
+			if _, err := validationService.Enqueue(types...); err != nil {
+				logger.Warn("上一轮代理验证尚未结束，跳过本次验证",
+					zap.Any("代理类型", types),
+					zap.Error(err),
+				)
+			}
+		})
 	}
 
 	// 过期代理清理任务
-	_, err = c.AddFunc(config.CleanupInterval, func() {
+	jobs.Add("cleanup_expired", config.CleanupInterval, func() {
 		logger.Info("========================================")
 		logger.Info("           定时任务：清理过期")
 		logger.Info("========================================")
 		if err := models.CleanupExpired(db); err != nil {
 			logger.Error("清理过期代理失败", zap.Error(err))
+			return
 		}
+		pool.CheckTopUp("过期清理")
 	})
-	if err != nil {
-		logger.Fatal("添加清理过期定时任务失败", zap.Error(err))
-	}
 
 	// 代理池优化任务
-	_, err = c.AddFunc(config.OptimizeInterval, func() {
+	jobs.Add("optimize", config.OptimizeInterval, func() {
 		logger.Info("========================================")
 		logger.Info("           定时任务：优化代理池")
 		logger.Info("========================================")
-		if err := models.OptimizePool(db); err != nil {
+		result, err := models.OptimizePool(db, pool.MaintenanceConfig())
+		if err != nil {
 			logger.Error("优化代理池失败", zap.Error(err))
+		} else {
+			logger.Info("代理池优化完成",
+				zap.Int64("删除代理数", result.Deleted),
+				zap.Int64("隔离代理数", result.Quarantined),
+				zap.Int64("标记不可用的固定代理数", result.Pinned),
+				zap.Int64("观察模式保留代理数", result.Observed),
+				zap.Int64("评分变化代理数", result.Rescored),
+				zap.Int64("提高并发数代理数", result.Promoted),
+			)
 		}
+		pool.CheckTopUp("代理池优化")
 	})
-	if err != nil {
-		logger.Fatal("添加优化代理池定时任务失败", zap.Error(err))
+
+	// 代理池自动维护任务，可用代理不足时补充获取，清理和优化由各自的定时任务执行
+	if config.MaintenanceInterval != "" {
+		jobs.Add("maintenance", config.MaintenanceInterval, func() {
+			logger.Info("========================================")
+			logger.Info("           定时任务：自动维护代理池")
+			logger.Info("========================================")
+			if err := pool.AutoMaintenance(); err != nil {
+				logger.Error("自动维护代理池失败", zap.Error(err))
+			}
+		})
 	}
 
-	// 启动定时任务
-	c.Start()
+	// 老化代理清理任务
+	jobs.Add("cleanup_aged", config.AgeCleanupInterval, func() {
+		logger.Info("========================================")
+		logger.Info("           定时任务：清理老化代理")
+		logger.Info("========================================")
+		deleted, err := models.CleanupOldProxies(db, config.GetMaxProxyAge())
+		if err != nil {
+			logger.Error("清理老化代理失败", zap.Error(err))
+			return
+		}
+		logger.Info("老化代理清理完成",
+			zap.Int64("删除数量", deleted),
+			zap.Duration("最大存活时间", config.GetMaxProxyAge()),
+		)
+		pool.CheckTopUp("老化代理清理")
+	})
+
+	// 代理信誉上报清理任务
+	jobs.Add("cleanup_reputation", config.ReputationCleanupInterval, func() {
+		deleted, err := models.CleanupReputation(db, config.GetReputationDecay())
+		if err != nil {
+			logger.Error("清理代理信誉上报失败", zap.Error(err))
+			return
+		}
+		if deleted > 0 {
+			logger.Info("代理信誉上报清理完成", zap.Int64("删除数量", deleted))
+		}
+	})
+
+	// 已删除代理的子表记录清理任务
+	jobs.Add("sweep_orphans", config.OrphanSweepInterval, func() {
+		pool.SweepOrphans(false)
+	})
+
+	// 调度记录写入Redis
+	if config.DecisionFlushInterval != "" {
+		jobs.Add("flush_decisions", config.DecisionFlushInterval, pool.DecisionLog().Flush)
+	}
+
+	// 启动定时任务，所有表达式错误一次性报告
+	if err := jobs.Err(); err != nil {
+		logger.Fatal("添加定时任务失败", zap.Error(err))
+	}
+	jobs.Start()
 	logger.Info("定时任务已启动")
 	logger.Info("定时任务执行计划：")
 	logger.Info("- 付费代理获取：" + config.PaidInterval)
 	logger.Info("- 免费代理获取：" + config.FreeInterval)
-	logger.Info("- 代理验证：" + config.ValidateInterval)
+	for _, job := range config.ValidationJobs() {
+		logger.Info("- 代理验证："+job.Spec, zap.Any("代理类型", job.Types))
+	}
 	logger.Info("- 过期清理：" + config.CleanupInterval)
 	logger.Info("- 代理池优化：" + config.OptimizeInterval)
-
-	// 启动HTTP服务（在新的goroutine中运行）
-	go func() {
-		logger.Info("HTTP服务启动中...")
-		startHTTPServer(pool, logger)
-	}()
+	logger.Info("- 老化清理：" + config.AgeCleanupInterval)
+	logger.Info("- 信誉上报清理：" + config.ReputationCleanupInterval)
+	logger.Info("- 孤立记录清理：" + config.OrphanSweepInterval)
+
+	// 启动HTTP服务
+	server := newAPIServer(pool, config, logger)
+	logger.Info("HTTP服务启动中...")
+	startHTTPServer(server, config, logger)
+
+	// HTTP服务启动后再预热，就绪检查可以返回预热进度
+	if warmup != nil {
+		go warmup.Run(ctx)
+	}
 
 	logger.Info("服务已完全启动，按 Ctrl+C 停止")
 
-	// 保持主线程运行
-	select {}
+	// 等待退出信号，HTTP服务意外退出时同样停止服务
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		logger.Info("收到退出信号，停止服务")
+	case serveErr = <-server.Err():
+		logger.Error("HTTP服务异常退出，停止服务", zap.Error(serveErr))
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Stop(shutdownCtx); err != nil {
+		logger.Error("HTTP服务关闭失败", zap.Error(err))
+	}
+	<-jobs.Stop().Done()
+	pool.Shutdown()
+	if serveErr != nil {
+		logger.Sync()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ProxyRejectedPrivateIP 因私有地址被拒绝的代理数量
+	ProxyRejectedPrivateIP = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "proxy_rejected_private_ip_total",
+			Help: "Number of proxies rejected because of a private IP.",
+		},
+		[]string{"source"},
+	)
+
+	// ProxyRejectedIP 因IP无效、回环或链路本地地址被拒绝的代理数量，按原因统计
+	ProxyRejectedIP = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "proxy_rejected_ip_total",
+			Help: "Number of proxies rejected because of an invalid, loopback or link-local IP, by reason.",
+		},
+		[]string{"source", "reason"},
+	)
+
+	// ProxyValidationErrors 代理字段校验失败次数，按原因统计
+	ProxyValidationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "proxy_validation_error_total",
+			Help: "Number of proxy field validation failures by reason.",
+		},
+		[]string{"reason"},
+	)
+
+	// ProtocolDetected 自动检测代理协议的结果，检测失败时 detected_as 为 none
+	ProtocolDetected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "protocol_detected_total",
+			Help: "Number of proxies whose protocol was auto-detected, by detected protocol.",
+		},
+		[]string{"detected_as"},
+	)
+
+	// RedisAvailable Redis是否可用，降级模式下为0
+	RedisAvailable = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "proxy_pool_redis_available",
+			Help: "Whether Redis is available (1) or the service runs in degraded mode (0).",
+		},
+	)
+
+	// RedisErrors Redis操作失败次数
+	RedisErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "proxy_pool_redis_errors_total",
+			Help: "Number of failed Redis operations and health checks.",
+		},
+	)
+
+	// PrefetchTriggered 可用代理接近下限时提前触发获取的次数
+	PrefetchTriggered = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prefetch_triggered_total",
+			Help: "Number of paid proxy fetches triggered early because the pool was running low.",
+		},
+	)
+
+	// ValidationCancelled 批量验证在完成前被取消的次数，如收到退出信号
+	ValidationCancelled = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "validation_cancelled_total",
+			Help: "Number of bulk proxy validation runs cancelled before finishing.",
+		},
+	)
+
+	// CronJobPanics 定时任务发生panic的次数，按任务名统计
+	CronJobPanics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cron_job_panics_total",
+			Help: "Number of panics recovered in scheduled jobs, by job name.",
+		},
+		[]string{"job"},
+	)
+
+	// ConflictsDetected 不同代理源返回同一个 ip:port 的次数
+	ConflictsDetected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "conflicts_detected_total",
+			Help: "Number of proxies returned by a source different from the one already stored.",
+		},
+		[]string{"existing_source", "new_source"},
+	)
+
+	// HandoutOutcomes 按发放ID关联的代理使用结果，按调度策略和结果统计，过期前未上报的计为 unknown
+	HandoutOutcomes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "proxy_handout_outcomes_total",
+			Help: "Outcomes of proxy hand-outs correlated by hand-out id, by strategy; unknown when never reported before expiry.",
+		},
+		[]string{"strategy", "outcome"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		ProxyRejectedPrivateIP,
+		ProxyRejectedIP,
+		ProxyValidationErrors,
+		ProtocolDetected,
+		RedisAvailable,
+		RedisErrors,
+		PrefetchTriggered,
+		ValidationCancelled,
+		CronJobPanics,
+		ConflictsDetected,
+		HandoutOutcomes,
+	)
+}
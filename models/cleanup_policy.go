@@ -0,0 +1,147 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CleanupPolicy 代理源的自动清理策略，未配置策略的代理源按默认规则直接删除
+// 付费代理删除后要到下个计费周期才能重新获取，可配置更高的失败容忍度、隔离代替删除和创建后的宽限期
+type CleanupPolicy struct {
+	MaxFailCount int           // 验证连续失败多少次后清理，0 表示使用验证器的配置
+	Quarantine   bool          // 隔离代替删除：标记为不可用并继续验证，验证通过后恢复
+	GracePeriod  time.Duration // 创建后该时长内不自动清理
+}
+
+// InGracePeriod 代理是否仍在创建后的宽限期内
+func (c CleanupPolicy) InGracePeriod(p *Proxy) bool {
+	return c.GracePeriod > 0 && time.Since(p.CreatedAt) < c.GracePeriod
+}
+
+// CleanupResult 一次清理的结果
+type CleanupResult struct {
+	Deleted     int64 `json:"deleted"`     // 删除的代理数
+	Quarantined int64 `json:"quarantined"` // 隔离的代理数
+	Pinned      int64 `json:"pinned"`      // 未删除只标记为不可用的固定代理数
+	Observed    int64 `json:"observed"`    // 观察模式下本应清理、只标记为不可用的代理数
+}
+
+var (
+	cleanupPolicyMu sync.RWMutex
+	cleanupPolicies map[string]CleanupPolicy
+)
+
+// SetCleanupPolicies 设置各代理源的清理策略，键为代理来源
+func SetCleanupPolicies(policies map[string]CleanupPolicy) {
+	cleanupPolicyMu.Lock()
+	defer cleanupPolicyMu.Unlock()
+	cleanupPolicies = make(map[string]CleanupPolicy, len(policies))
+	for source, policy := range policies {
+		cleanupPolicies[source] = policy
+	}
+}
+
+// CleanupPolicyFor 获取代理源的清理策略，未配置时返回零值
+func CleanupPolicyFor(source string) CleanupPolicy {
+	cleanupPolicyMu.RLock()
+	defer cleanupPolicyMu.RUnlock()
+	return cleanupPolicies[source]
+}
+
+// cleanupPolicySnapshot 获取全部清理策略的副本
+func cleanupPolicySnapshot() map[string]CleanupPolicy {
+	cleanupPolicyMu.RLock()
+	defer cleanupPolicyMu.RUnlock()
+	policies := make(map[string]CleanupPolicy, len(cleanupPolicies))
+	for source, policy := range cleanupPolicies {
+		policies[source] = policy
+	}
+	return policies
+}
+
+// Quarantine 隔离代理，不再参与调度
+func (p *Proxy) Quarantine() {
+	p.Available = false
+	p.Quarantined = true
+}
+
+// cleanupWhere 按保留模式和代理源的清理策略清理 scope 选中的代理
+// 固定代理不删除也不隔离，只标记为不可用；
+// 未配置策略的代理源直接删除；配置了策略的跳过宽限期内的代理，开启隔离时标记为隔离而不删除，
+// 但已过硬过期时间的代理不会再恢复，仍然删除，避免到期的付费代理一直隔离；
+// 隔离模式下所有代理源都以隔离代替删除，观察模式下只标记为不可用并通知本应执行的操作，reason 记录为清理原因
+func cleanupWhere(db *gorm.DB, reason string, scope func(*gorm.DB) *gorm.DB) (CleanupResult, error) {
+	var result CleanupResult
+	policies := cleanupPolicySnapshot()
+	mode := GetRetentionMode()
+
+	// apply 按保留模式清理 query 选中的代理，quarantine 为代理源的清理策略是否以隔离代替删除
+	apply := func(query *gorm.DB, quarantine bool) error {
+		action := CleanupActionDelete
+		if quarantine || mode == RetentionQuarantine {
+			action = CleanupActionQuarantine
+		}
+
+		switch {
+		case mode == RetentionObserve:
+			observed, err := observeCleanup(db, query, action, reason)
+			result.Observed += observed
+			return err
+		case action == CleanupActionQuarantine:
+			quarantined := query.Where("quarantined = ?", false).
+				UpdateColumns(map[string]interface{}{"available": false, "quarantined": true})
+			result.Quarantined += quarantined.RowsAffected
+			return quarantined.Error
+		default:
+			deleted := query.Delete(&Proxy{})
+			result.Deleted += deleted.RowsAffected
+			return deleted.Error
+		}
+	}
+
+	pinned := scope(db.Model(&Proxy{})).Where("pinned = ? AND available = ?", true, true).
+		UpdateColumn("available", false)
+	if pinned.Error != nil {
+		return result, pinned.Error
+	}
+	result.Pinned = pinned.RowsAffected
+
+	query := scope(db.Model(&Proxy{})).Where("pinned = ?", false)
+	if len(policies) > 0 {
+		sources := make([]string, 0, len(policies))
+		for source := range policies {
+			sources = append(sources, source)
+		}
+		query = query.Where("source NOT IN ?", sources)
+	}
+	if err := apply(query, false); err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	for source, policy := range policies {
+		policyQuery := func() *gorm.DB {
+			query := scope(db.Model(&Proxy{})).Where("source = ? AND pinned = ?", source, false)
+			if policy.GracePeriod > 0 {
+				query = query.Where("created_at < ?", now.Add(-policy.GracePeriod))
+			}
+			return query
+		}
+		if !policy.Quarantine {
+			if err := apply(policyQuery(), false); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		if err := apply(policyQuery().Where("expires_at IS NOT NULL AND expires_at <= ?", now), false); err != nil {
+			return result, err
+		}
+		if err := apply(policyQuery().Where("expires_at IS NULL OR expires_at > ?", now), true); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
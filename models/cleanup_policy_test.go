@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// usePolicies 在测试期间设置代理源的清理策略
+func usePolicies(t *testing.T, policies map[string]CleanupPolicy) {
+	t.Helper()
+	SetCleanupPolicies(policies)
+	t.Cleanup(func() { SetCleanupPolicies(nil) })
+}
+
+func TestCleanupExpiredDeletesHardExpiredQuarantined(t *testing.T) {
+	db := newTestDB(t)
+	usePolicies(t, map[string]CleanupPolicy{"paid": {Quarantine: true}})
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	paid := func(expiresAt *time.Time) func(*Proxy) {
+		return func(p *Proxy) { p.Source = "paid"; p.ExpiresAt = expiresAt }
+	}
+	// 已隔离、仍在验证的付费代理到期
+	lapsed := newTestProxy(t, db, "1.1.1.1", 80, paid(&past))
+	db.Model(&Proxy{}).Where("id = ?", lapsed.ID).UpdateColumns(map[string]interface{}{"available": false, "quarantined": true})
+	// 未到期但检查已过期的付费代理仍按策略隔离
+	stale := newTestProxy(t, db, "2.2.2.2", 80, paid(&future))
+	db.Model(&Proxy{}).Where("id = ?", stale.ID).UpdateColumn("last_check", time.Now().Add(-30*24*time.Hour))
+	live := newTestProxy(t, db, "3.3.3.3", 80, paid(&future))
+
+	if err := CleanupExpired(db); err != nil {
+		t.Fatalf("CleanupExpired: %v", err)
+	}
+
+	var count int64
+	db.Model(&Proxy{}).Where("id = ?", lapsed.ID).Count(&count)
+	if count != 0 {
+		t.Error("hard-expired quarantined proxy still present, want deleted")
+	}
+	if got := loadProxy(t, db, stale.ID); got.Available || !got.Quarantined {
+		t.Errorf("stale paid proxy available = %v, quarantined = %v, want false, true", got.Available, got.Quarantined)
+	}
+	if got := loadProxy(t, db, live.ID); !got.Available || got.Quarantined {
+		t.Errorf("live paid proxy available = %v, quarantined = %v, want true, false", got.Available, got.Quarantined)
+	}
+}
+
+func TestIsHardExpired(t *testing.T) {
+	past, future := time.Now().Add(-time.Second), time.Now().Add(time.Hour)
+	tests := []struct {
+		expiresAt *time.Time
+		want      bool
+	}{
+		{nil, false},
+		{&past, true},
+		{&future, false},
+	}
+	for _, tt := range tests {
+		if got := (&Proxy{ExpiresAt: tt.expiresAt}).IsHardExpired(); got != tt.want {
+			t.Errorf("IsHardExpired(%v) = %v, want %v", tt.expiresAt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ConcurrencyDefaults 新代理的默认最大并发数，代理源配置优先于代理类型配置
+type ConcurrencyDefaults struct {
+	ByType   map[ProxyType]int // 各代理类型的默认最大并发数
+	BySource map[string]int    // 各代理源的默认最大并发数，键为代理来源
+}
+
+// For 获取指定类型和来源的代理的默认最大并发数，都未配置时使用 defaultMaxConcurrent
+func (d ConcurrencyDefaults) For(proxyType ProxyType, source string) int {
+	if n := d.BySource[source]; n > 0 {
+		return n
+	}
+	if n := d.ByType[proxyType]; n > 0 {
+		return n
+	}
+	return defaultMaxConcurrent
+}
+
+var (
+	concurrencyMu       sync.RWMutex
+	concurrencyDefaults ConcurrencyDefaults
+)
+
+// SetConcurrencyDefaults 设置新代理的默认最大并发数，入库时对未指定最大并发数的代理生效
+func SetConcurrencyDefaults(d ConcurrencyDefaults) {
+	concurrencyMu.Lock()
+	defer concurrencyMu.Unlock()
+	concurrencyDefaults = d
+}
+
+// defaultMaxConcurrentFor 获取代理的默认最大并发数
+func defaultMaxConcurrentFor(p *Proxy) int {
+	concurrencyMu.RLock()
+	defer concurrencyMu.RUnlock()
+	return concurrencyDefaults.For(p.Type, p.Source)
+}
+
+// applyDefaultMaxConcurrent 未指定最大并发数时使用默认值
+func (p *Proxy) applyDefaultMaxConcurrent() {
+	if p.MaxConcurrent == 0 {
+		p.MaxConcurrent = defaultMaxConcurrentFor(p)
+	}
+}
+
+// BulkUpdateMaxConcurrent 批量修改符合条件的代理的最大并发数，返回修改数量
+// 过滤条件为空时返回 gorm.ErrMissingWhereClause
+func BulkUpdateMaxConcurrent(db *gorm.DB, filter ProxyFilter, maxConcurrent int) (int64, error) {
+	result := filter.Apply(db.Model(&Proxy{})).UpdateColumn("max_concurrent", maxConcurrent)
+	return result.RowsAffected, result.Error
+}
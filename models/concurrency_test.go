@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestConcurrencyDefaultsPerTypeAndSource(t *testing.T) {
+	db := newTestDB(t)
+	SetConcurrencyDefaults(ConcurrencyDefaults{
+		ByType:   map[ProxyType]int{ProxyTypeLong: 50},
+		BySource: map[string]int{"paid": 100, "free": 2},
+	})
+	t.Cleanup(func() { SetConcurrencyDefaults(ConcurrencyDefaults{}) })
+
+	tests := []struct {
+		name      string
+		proxyType ProxyType
+		source    string
+		preset    int
+		want      int
+	}{
+		{"unconfigured", ProxyTypeTemp, "test", 0, defaultMaxConcurrent},
+		{"by type", ProxyTypeLong, "test", 0, 50},
+		{"source over type", ProxyTypeLong, "paid", 0, 100},
+		{"low source limit", ProxyTypeTemp, "free", 0, 2},
+		{"explicit value kept", ProxyTypeLong, "paid", 7, 7},
+	}
+	for i, tt := range tests {
+		p := newTestProxy(t, db, "1.1.1.1", 8000+i, func(p *Proxy) {
+			p.Type, p.Source, p.MaxConcurrent = tt.proxyType, tt.source, tt.preset
+		})
+		var stored Proxy
+		db.First(&stored, p.ID)
+		if stored.MaxConcurrent != tt.want {
+			t.Errorf("%s: max_concurrent = %d, want %d", tt.name, stored.MaxConcurrent, tt.want)
+		}
+	}
+}
+
+func TestBulkUpdateMaxConcurrent(t *testing.T) {
+	db := newTestDB(t)
+	paid1 := newTestProxy(t, db, "1.1.1.1", 8080, func(p *Proxy) { p.Source = "paid" })
+	paid2 := newTestProxy(t, db, "1.1.1.2", 8080, func(p *Proxy) { p.Source = "paid" })
+	free := newTestProxy(t, db, "1.1.1.3", 8080, func(p *Proxy) { p.Source = "free" })
+
+	updated, err := BulkUpdateMaxConcurrent(db, ProxyFilter{Source: "paid"}, 100)
+	if err != nil {
+		t.Fatalf("BulkUpdateMaxConcurrent: %v", err)
+	}
+	if updated != 2 {
+		t.Errorf("updated = %d, want 2", updated)
+	}
+	for _, tc := range []struct {
+		proxy *Proxy
+		want  int
+	}{{paid1, 100}, {paid2, 100}, {free, defaultMaxConcurrent}} {
+		var stored Proxy
+		db.First(&stored, tc.proxy.ID)
+		if stored.MaxConcurrent != tc.want {
+			t.Errorf("proxy %s max_concurrent = %d, want %d", stored.IP, stored.MaxConcurrent, tc.want)
+		}
+	}
+
+	// 过滤条件为空时拒绝修改全部代理
+	if _, err := BulkUpdateMaxConcurrent(db, ProxyFilter{}, 5); !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Errorf("empty filter error = %v, want %v", err, gorm.ErrMissingWhereClause)
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConflictRetention 代理源冲突记录的保留时长
+const ConflictRetention = 7 * 24 * time.Hour
+
+// ProxyConflict 不同代理源返回了同一个 ip:port 的记录
+type ProxyConflict struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	ProxyID        uint      `gorm:"not null;index" json:"proxy_id"`
+	ExistingSource string    `gorm:"type:varchar(64);not null" json:"existing_source"` // 已有代理的来源
+	NewSource      string    `gorm:"type:varchar(64);not null" json:"new_source"`      // 新获取到的来源
+	DetectedAt     time.Time `gorm:"not null;index" json:"detected_at"`
+}
+
+// RecordConflict 记录一次代理源冲突
+func RecordConflict(db *gorm.DB, conflict *ProxyConflict) error {
+	if conflict.DetectedAt.IsZero() {
+		conflict.DetectedAt = time.Now()
+	}
+	return db.Create(conflict).Error
+}
+
+// ListConflicts 获取最近的代理源冲突记录，按发现时间倒序，limit 为0表示不限
+func ListConflicts(db *gorm.DB, limit int) ([]ProxyConflict, error) {
+	var conflicts []ProxyConflict
+	query := db.Order("detected_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&conflicts).Error
+	return conflicts, err
+}
+
+// PurgeConflicts 删除 retention 之前发现的代理源冲突记录，返回删除数量
+func PurgeConflicts(db *gorm.DB, retention time.Duration) (int64, error) {
+	result := db.Where("detected_at < ?", time.Now().Add(-retention)).Delete(&ProxyConflict{})
+	return result.RowsAffected, result.Error
+}
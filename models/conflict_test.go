@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"proxy_pool/metrics"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBatchCreateRecordsSourceConflict(t *testing.T) {
+	db := newTestDB(t)
+	claimed := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.Source = "ip3366" })
+	newTestProxy(t, db, "2.2.2.2", 80, func(p *Proxy) { p.Source = "geonode" })
+
+	counter := metrics.ConflictsDetected.WithLabelValues("ip3366", "geonode")
+	before := testutil.ToFloat64(counter)
+
+	batch := []*Proxy{
+		{IP: "1.1.1.1", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "geonode"},
+		{IP: "2.2.2.2", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "geonode"},
+		{IP: "3.3.3.3", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "geonode"},
+	}
+	result, err := BatchCreateWithDuplicateCheck(db, batch)
+	if err != nil {
+		t.Fatalf("BatchCreateWithDuplicateCheck: %v", err)
+	}
+	if result.Added != 1 || result.Updated != 2 {
+		t.Errorf("result = %+v, want 1 added and 2 updated", result)
+	}
+
+	// 只有来源不同的代理记录冲突
+	conflicts, err := ListConflicts(db, 0)
+	if err != nil {
+		t.Fatalf("ListConflicts: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("conflicts = %+v, want 1", conflicts)
+	}
+	c := conflicts[0]
+	if c.ProxyID != claimed.ID || c.ExistingSource != "ip3366" || c.NewSource != "geonode" {
+		t.Errorf("conflict = %+v, want proxy %d ip3366 -> geonode", c, claimed.ID)
+	}
+	if c.DetectedAt.IsZero() {
+		t.Error("conflict has no detection time")
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("conflicts_detected_total{ip3366,geonode} grew by %v, want 1", got)
+	}
+
+	// 过期的冲突记录被清理
+	db.Model(&ProxyConflict{}).Where("id = ?", c.ID).UpdateColumn("detected_at", time.Now().Add(-8*24*time.Hour))
+	purged, err := PurgeConflicts(db, 7*24*time.Hour)
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeConflicts = %d, %v; want 1", purged, err)
+	}
+}
+
+func TestGetExistingProxySet(t *testing.T) {
+	db := newTestDB(t)
+	newTestProxy(t, db, "1.1.1.1", 80)
+	newTestProxy(t, db, "2.2.2.2", 8080)
+
+	set, err := GetExistingProxySet(db, []*Proxy{
+		{IP: "1.1.1.1", Port: 80},
+		{IP: "2.2.2.2", Port: 80},
+		{IP: "2.2.2.2", Port: 8080},
+	})
+	if err != nil {
+		t.Fatalf("GetExistingProxySet: %v", err)
+	}
+	want := map[string]bool{"1.1.1.1:80": true, "2.2.2.2:8080": true}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("GetExistingProxySet = %v, want %v", set, want)
+	}
+}
+
+// BenchmarkExistingProxyLookup 对比逐个 IsProxyExists 查询与 GetExistingProxySet 一次查询，批次中一半代理已存在
+func BenchmarkExistingProxyLookup(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		db := newTestDB(b)
+		batch := make([]*Proxy, size)
+		for i := range batch {
+			ip := fmt.Sprintf("1.1.%d.%d", i/250, i%250+1)
+			batch[i] = &Proxy{IP: ip, Port: 80}
+			if i%2 == 0 {
+				newTestProxy(b, db, ip, 80)
+			}
+		}
+
+		b.Run(fmt.Sprintf("IsProxyExists/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, p := range batch {
+					if _, err := IsProxyExists(db, p.IP, p.Port); err != nil {
+						b.Fatalf("IsProxyExists: %v", err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("GetExistingProxySet/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				set, err := GetExistingProxySet(db, batch)
+				if err != nil {
+					b.Fatalf("GetExistingProxySet: %v", err)
+				}
+				if len(set) != (size+1)/2 {
+					b.Fatalf("found %d existing proxies, want %d", len(set), (size+1)/2)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProxyOrder 代理排序方式
+type ProxyOrder string
+
+const (
+	OrderByScore     ProxyOrder = "score"      // 评分从高到低，速度从快到慢
+	OrderBySpeed     ProxyOrder = "speed"      // 速度从快到慢
+	OrderByNewest    ProxyOrder = "newest"     // 创建时间从新到旧
+	OrderByLastCheck ProxyOrder = "last_check" // 最近检查优先
+	OrderByID        ProxyOrder = "id"         // ID升序
+)
+
+// orderClauses 排序方式对应的SQL
+var orderClauses = map[ProxyOrder]string{
+	OrderByScore:     "score DESC, speed ASC",
+	OrderBySpeed:     "speed ASC",
+	OrderByNewest:    "created_at DESC",
+	OrderByLastCheck: "last_check DESC",
+	OrderByID:        "id ASC",
+}
+
+// IsValid 检查排序方式是否为已知方式
+func (o ProxyOrder) IsValid() bool {
+	_, ok := orderClauses[o]
+	return ok
+}
+
+// ProxyFilter 代理查询条件，零值字段不参与过滤
+type ProxyFilter struct {
+	Type           ProxyType     `json:"type,omitempty"`             // 代理类型
+	Region         ProxyRegion   `json:"region,omitempty"`           // 代理地区
+	Protocol       string        `json:"protocol,omitempty"`         // 协议类型
+	Source         string        `json:"source,omitempty"`           // 代理来源
+	MinScore       float64       `json:"min_score,omitempty"`        // 最低评分
+	MinSuccessRate float64       `json:"min_success_rate,omitempty"` // 最低成功率(百分比)
+	MinChecks      int           `json:"min_checks,omitempty"`       // 最少检查次数(成功+失败)，避免只检查过一次的代理成功率虚高
+	MaxSpeed       int64         `json:"max_speed,omitempty"`        // 响应时间上限(毫秒)
+	MaxAge         time.Duration `json:"max_age,omitempty"`          // 代理年龄上限，0表示不限
+	Tag            string        `json:"tag,omitempty"`              // 代理标签，精确匹配
+	TagPattern     string        `json:"tag_pattern,omitempty"`      // 代理标签前缀模式，* 匹配任意字符，如 us-*
+	TagSearch      string        `json:"tag_search,omitempty"`       // 代理标签全文搜索(布尔模式)，仅支持MySQL
+	Group          string        `json:"group,omitempty"`            // 代理分组名称，只查询该分组的成员
+	Anonymous      *bool         `json:"anonymous,omitempty"`        // 是否匿名
+	Available      *bool         `json:"available,omitempty"`        // 是否可用
+	VerifiedHTTPS  *bool         `json:"verified_https,omitempty"`   // 最近一次验证是否通过HTTPS测试网站
+	HasError       *bool         `json:"has_error,omitempty"`        // 是否记录了最近一次失败的原因
+	ExcludeIDs     []uint        `json:"exclude_ids,omitempty"`      // 排除的代理ID
+	AfterID        uint          `json:"after_id,omitempty"`         // 只查询ID大于该值的代理，用于按ID游标分页
+	Limit          int           `json:"limit,omitempty"`            // 返回数量上限，0表示不限
+	Order          ProxyOrder    `json:"order,omitempty"`            // 排序方式，默认按评分
+}
+
+// successRateExpr 成功率(百分比)的SQL表达式，没有检查记录时为0
+const successRateExpr = "CASE WHEN success+failure > 0 THEN 100.0*success/(success+failure) ELSE 0 END"
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ListFilter 可用代理查询条件，零值字段不参与过滤
+type ListFilter struct {
+	MinScore       float64     // 最低评分
+	MinSuccessRate float64     // 最低成功率(百分比)
+	MaxSpeed       int64       // 响应时间上限(毫秒)
+	Protocol       string      // 协议类型
+	Region         ProxyRegion // 代理地区
+	Type           ProxyType   // 代理类型
+}
+
+// Validate 检查查询条件是否合法
+func (f ListFilter) Validate() error {
+	switch {
+	case f.MinScore < 0 || f.MinScore > 100:
+		return fmt.Errorf("%w: min_score %v out of range [0, 100]", ErrInvalidFilter, f.MinScore)
+	case f.MinSuccessRate < 0 || f.MinSuccessRate > 100:
+		return fmt.Errorf("%w: min_success_rate %v out of range [0, 100]", ErrInvalidFilter, f.MinSuccessRate)
+	case f.MaxSpeed < 0:
+		return fmt.Errorf("%w: negative max_speed %d", ErrInvalidFilter, f.MaxSpeed)
+	case f.Protocol != "" && !IsSupportedProtocol(f.Protocol):
+		return fmt.Errorf("%w: unsupported protocol %q", ErrInvalidFilter, f.Protocol)
+	case f.Region != "" && !f.Region.IsValid():
+		return fmt.Errorf("%w: invalid region %q", ErrInvalidFilter, f.Region)
+	case f.Type != "" && !f.Type.IsValid():
+		return fmt.Errorf("%w: invalid type %q", ErrInvalidFilter, f.Type)
+	}
+	return nil
+}
+
+// ProxyFilter 转换为代理查询条件，只查询可用代理
+func (f ListFilter) ProxyFilter() ProxyFilter {
+	return ProxyFilter{
+		Type:           f.Type,
+		Region:         f.Region,
+		Protocol:       f.Protocol,
+		MinScore:       f.MinScore,
+		MinSuccessRate: f.MinSuccessRate,
+		MaxSpeed:       f.MaxSpeed,
+		Available:      Bool(true),
+		Order:          OrderByID,
+	}
+}
+
+// TypeScope 限定代理类型，types 为空时不限
+func TypeScope(db *gorm.DB, types []ProxyType) *gorm.DB {
+	if len(types) == 0 {
+		return db
+	}
+	return db.Where("type IN ?", types)
+}
+
+// Bool 返回布尔值指针，便于构造过滤条件
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Apply 将过滤条件应用到查询上
+func (f ProxyFilter) Apply(db *gorm.DB) *gorm.DB {
+	query := db
+
+	if f.Available != nil {
+		query = query.Where("available = ?", *f.Available)
+	}
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.Region != "" {
+		query = query.Where("region = ?", f.Region)
+	}
+	if f.Protocol != "" {
+		query = query.Where("protocol = ?", f.Protocol)
+	}
+	if f.Source != "" {
+		query = query.Where("source = ?", f.Source)
+	}
+	if f.MinScore > 0 {
+		query = query.Where("score >= ?", f.MinScore)
+	}
+	if f.MinSuccessRate > 0 {
+		query = query.Where(successRateExpr+" >= ?", f.MinSuccessRate)
+	}
+	if f.MinChecks > 0 {
+		query = query.Where("success+failure >= ?", f.MinChecks)
+	}
+	if f.MaxSpeed > 0 {
+		query = query.Where("speed <= ?", f.MaxSpeed)
+	}
+	if f.MaxAge > 0 {
+		query = query.Where("created_at >= ?", time.Now().Add(-f.MaxAge))
+	}
+	if f.Tag != "" {
+		query = query.Where("id IN (SELECT proxy_id FROM proxy_tags WHERE tag = ?)", f.Tag)
+	}
+	if f.TagPattern != "" {
+		query = query.Where("id IN (SELECT proxy_id FROM proxy_tags WHERE tag LIKE ? ESCAPE '"+likeEscape+"')", tagLikePattern(f.TagPattern))
+	}
+	if f.TagSearch != "" {
+		if db.Dialector.Name() != "mysql" {
+			query.AddError(ErrFullTextUnsupported)
+		}
+		query = query.Where("id IN (SELECT proxy_id FROM proxy_tags WHERE MATCH(tag) AGAINST(? IN BOOLEAN MODE))", f.TagSearch)
+	}
+	if f.Group != "" {
+		query = query.Where("id IN (SELECT proxy_group_members.proxy_id FROM proxy_group_members"+
+			" JOIN proxy_groups ON proxy_groups.id = proxy_group_members.proxy_group_id WHERE proxy_groups.name = ?)", f.Group)
+	}
+	if f.Anonymous != nil {
+		query = query.Where("anonymous = ?", *f.Anonymous)
+	}
+	if f.VerifiedHTTPS != nil {
+		query = query.Where("verified_https = ?", *f.VerifiedHTTPS)
+	}
+	if f.HasError != nil {
+		if *f.HasError {
+			query = query.Where("last_error_at IS NOT NULL")
+		} else {
+			query = query.Where("last_error_at IS NULL")
+		}
+	}
+	if len(f.ExcludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", f.ExcludeIDs)
+	}
+	if f.AfterID > 0 {
+		query = query.Where("id > ?", f.AfterID)
+	}
+	if f.Limit > 0 {
+		query = query.Limit(f.Limit)
+	}
+
+	order, ok := orderClauses[f.Order]
+	if !ok {
+		order = orderClauses[OrderByScore]
+	}
+	return query.Order(order)
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrGroupNotFound = errors.New("group not found")
+	ErrGroupExists   = errors.New("group already exists")
+	ErrInvalidGroup  = errors.New("invalid group")
+)
+
+const (
+	MaxGroupNameLength  = 64   // 分组名称最大长度
+	MaxGroupMemberBatch = 1000 // 单次加入分组的代理数上限
+)
+
+// groupMembersTable 代理分组成员表
+const groupMembersTable = "proxy_group_members"
+
+// ProxyGroup 运维定义的代理分组，作为独立的代理池调度，可设置自己的评分下限和调度策略
+type ProxyGroup struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	MinScore    float64   `gorm:"not null;default:0" json:"min_score"` // 组内调度的最低评分，与请求中的 min_score 取较高者
+	Strategy    string    `gorm:"type:varchar(32)" json:"strategy"`    // 组内调度策略，为空时使用请求中的策略
+	Proxies     []*Proxy  `gorm:"many2many:proxy_group_members" json:"proxies,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// GroupSummary 代理分组及其成员数
+type GroupSummary struct {
+	ProxyGroup
+	MemberCount int64 `json:"member_count"` // 未删除的成员代理数
+}
+
+// Validate 检查分组名称和评分下限，调度策略由调用方检查
+func (g *ProxyGroup) Validate() error {
+	g.Name = strings.TrimSpace(g.Name)
+	switch {
+	case g.Name == "" || len(g.Name) > MaxGroupNameLength || strings.Contains(g.Name, "/"):
+		return fmt.Errorf("%w: name %q", ErrInvalidGroup, g.Name)
+	case g.MinScore < 0 || g.MinScore > 100:
+		return fmt.Errorf("%w: min_score %v out of range [0, 100]", ErrInvalidGroup, g.MinScore)
+	}
+	return nil
+}
+
+// CreateGroup 创建代理分组，名称已存在时返回 ErrGroupExists
+func CreateGroup(db *gorm.DB, group *ProxyGroup) error {
+	if err := group.Validate(); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(&ProxyGroup{}).Where("name = ?", group.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %q", ErrGroupExists, group.Name)
+	}
+	return db.Omit("Proxies").Create(group).Error
+}
+
+// FindGroup 按名称获取代理分组，不存在时返回 ErrGroupNotFound
+func FindGroup(db *gorm.DB, name string) (*ProxyGroup, error) {
+	var group ProxyGroup
+	if err := db.Where("name = ?", name).Take(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %q", ErrGroupNotFound, name)
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// groupMemberCounts 统计各分组未删除的成员代理数，groupIDs 为空时统计所有分组
+func groupMemberCounts(db *gorm.DB, groupIDs ...uint) (map[uint]int64, error) {
+	var rows []struct {
+		ProxyGroupID uint
+		Count        int64
+	}
+	query := db.Table(groupMembersTable).
+		Select("proxy_group_members.proxy_group_id, COUNT(*) AS count").
+		Joins("JOIN proxies ON proxies.id = proxy_group_members.proxy_id AND proxies.deleted_at IS NULL").
+		Group("proxy_group_members.proxy_group_id")
+	if len(groupIDs) > 0 {
+		query = query.Where("proxy_group_members.proxy_group_id IN ?", groupIDs)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ProxyGroupID] = row.Count
+	}
+	return counts, nil
+}
+
+// ListGroups 获取所有代理分组及其成员数，按名称排序
+func ListGroups(db *gorm.DB) ([]GroupSummary, error) {
+	var groups []ProxyGroup
+	if err := db.Order("name").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	counts, err := groupMemberCounts(db)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]GroupSummary, len(groups))
+	for i, group := range groups {
+		summaries[i] = GroupSummary{ProxyGroup: group, MemberCount: counts[group.ID]}
+	}
+	return summaries, nil
+}
+
+// GetGroupSummary 按名称获取代理分组及其成员数
+func GetGroupSummary(db *gorm.DB, name string) (*GroupSummary, error) {
+	group, err := FindGroup(db, name)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := groupMemberCounts(db, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupSummary{ProxyGroup: *group, MemberCount: counts[group.ID]}, nil
+}
+
+// AddGroupMembers 将代理加入分组，已是成员的代理忽略；任一代理不存在时不加入任何代理
+func AddGroupMembers(db *gorm.DB, name string, proxyIDs []uint) error {
+	group, err := FindGroup(db, name)
+	if err != nil {
+		return err
+	}
+	if len(proxyIDs) == 0 {
+		return nil
+	}
+
+	var found []uint
+	if err := db.Model(&Proxy{}).Where("id IN ?", proxyIDs).Pluck("id", &found).Error; err != nil {
+		return err
+	}
+	exists := make(map[uint]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	seen := make(map[uint]bool, len(proxyIDs))
+	rows := make([]map[string]interface{}, 0, len(proxyIDs))
+	for _, id := range proxyIDs {
+		if !exists[id] {
+			return fmt.Errorf("proxy %d: %w", id, gorm.ErrRecordNotFound)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		rows = append(rows, map[string]interface{}{"proxy_group_id": group.ID, "proxy_id": id})
+	}
+	return db.Table(groupMembersTable).Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// RemoveGroupMember 将代理移出分组，代理不是成员时不做处理
+func RemoveGroupMember(db *gorm.DB, name string, proxyID uint) error {
+	group, err := FindGroup(db, name)
+	if err != nil {
+		return err
+	}
+	return db.Exec("DELETE FROM "+groupMembersTable+" WHERE proxy_group_id = ? AND proxy_id = ?", group.ID, proxyID).Error
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	hostnameCacheTTL       = time.Minute // 主机名解析结果（包括失败）的缓存时长
+	hostnamePrefetchWorker = 16          // PrefetchHostnames 同时解析的主机名数
+)
+
+// hostnameLookupTimeout 解析单个主机名的超时时间
+var hostnameLookupTimeout = 5 * time.Second
+
+// ErrHostnameNotAllowed 代理地址是主机名，且未开启主机名解析
+var ErrHostnameNotAllowed = errors.New("hostname not allowed")
+
+// resolveHostnames 入库时是否将主机名解析为IP，关闭时拒绝主机名
+var resolveHostnames atomic.Bool
+
+// lookupIP 解析主机名，可替换以便离线使用
+var lookupIP = net.DefaultResolver.LookupIP
+
+// hostnameLookup 缓存的主机名解析结果
+type hostnameLookup struct {
+	ip      net.IP
+	err     error
+	expires time.Time
+}
+
+// hostnameCache 主机名解析结果的缓存，同一批代理常用同一主机名的不同端口，只解析一次
+var hostnameCache = struct {
+	sync.Mutex
+	entries map[string]hostnameLookup
+}{entries: make(map[string]hostnameLookup)}
+
+// SetResolveHostnames 设置入库时是否将主机名解析为IP，关闭时拒绝主机名
+func SetResolveHostnames(enabled bool) {
+	resolveHostnames.Store(enabled)
+}
+
+// parseIP 解析IP文本，去除首尾空白和IPv6地址的方括号，IPv4各段的前导零按十进制处理
+// 如 " 1.02.3.4" 解析为 1.2.3.4，无法解析时返回nil
+func parseIP(raw string) net.IP {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	return parseIPv4LeadingZeros(s)
+}
+
+// parseIPv4LeadingZeros 解析各段带前导零的IPv4地址，net.ParseIP 会拒绝这种写法
+func parseIPv4LeadingZeros(s string) net.IP {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return nil
+	}
+	var octets [4]byte
+	for i, part := range parts {
+		if len(part) == 0 || len(part) > 3 {
+			return nil
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 255 || part[0] == '+' || part[0] == '-' {
+			return nil
+		}
+		octets[i] = byte(n)
+	}
+	return net.IPv4(octets[0], octets[1], octets[2], octets[3])
+}
+
+// CanonicalIP 获取IP的规范文本形式，用于按IP查询，无法解析时原样返回去除空白后的文本
+func CanonicalIP(raw string) string {
+	if ip := parseIP(raw); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimSpace(raw)
+}
+
+// CanonicalizeProxyAddrs 将已有代理的IP改写为规范形式，供升级时迁移使用
+// 改写后与另一条代理地址相同的记录，以及原本就重复的记录，只保留ID最小的一条，其余标记为 duplicate 后删除
+func CanonicalizeProxyAddrs(db *gorm.DB) error {
+	var proxies []*Proxy
+	var duplicates []uint
+	err := db.Select("id", "ip", "port").FindInBatches(&proxies, 500, func(tx *gorm.DB, batch int) error {
+		for _, p := range proxies {
+			canonical := CanonicalIP(p.IP)
+			if canonical == p.IP {
+				continue
+			}
+			var existing int64
+			if err := db.Model(&Proxy{}).Where("ip = ? AND port = ? AND id <> ?", canonical, p.Port, p.ID).
+				Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				duplicates = append(duplicates, p.ID)
+				continue
+			}
+			num, _ := IPv4ToUint(canonical)
+			if err := db.Model(&Proxy{}).Where("id = ?", p.ID).
+				UpdateColumns(map[string]interface{}{"ip": canonical, "ip_num": num}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	// 地址完全相同的记录保留ID最小的一条
+	var extra []uint
+	if err := db.Model(&Proxy{}).
+		Where("id NOT IN (?) AND id NOT IN ?", db.Model(&Proxy{}).Select("MIN(id)").Group("ip, port"), append(duplicates, 0)).
+		Pluck("id", &extra).Error; err != nil {
+		return err
+	}
+	duplicates = append(duplicates, extra...)
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Proxy{}).Where("id IN ?", duplicates).
+			UpdateColumn("deleted_by_reason", "duplicate").Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", duplicates).Delete(&Proxy{}).Error
+	})
+}
+
+// isHostname 是否为合法的主机名，至少包含一个字母以区别于不合法的IP
+func isHostname(s string) bool {
+	if len(s) == 0 || len(s) > 253 {
+		return false
+	}
+	hasLetter := false
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+				hasLetter = true
+			case c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return hasLetter
+}
+
+// normalizeHost 去除地址首尾空白，地址是主机名时按配置解析为IP并保留主机名，或返回 ErrHostnameNotAllowed
+// 解析见 resolveHostname；地址既不是IP也不是主机名时保持不变，由IP校验拒绝
+func (p *Proxy) normalizeHost() error {
+	p.IP = strings.TrimSpace(p.IP)
+	hostname := hostnameOf(p.IP)
+	if hostname == "" {
+		return nil
+	}
+
+	if !resolveHostnames.Load() {
+		return fmt.Errorf("%w: %q", ErrHostnameNotAllowed, hostname)
+	}
+
+	resolved, err := resolveHostname(hostname)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %q: %v", ErrInvalidIP, hostname, err)
+	}
+	p.IP = resolved.String()
+	p.Hostname = hostname
+	return nil
+}
+
+// hostnameOf 地址是主机名时返回小写且去除末尾点的主机名，否则返回空
+func hostnameOf(raw string) string {
+	s := strings.TrimSpace(raw)
+	if parseIP(s) != nil || !isHostname(s) {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+// resolveHostname 解析主机名，优先使用IPv4地址，每次解析不超过 hostnameLookupTimeout
+// 结果和失败都缓存 hostnameCacheTTL，避免同一主机名重复解析或重复等待超时
+func resolveHostname(hostname string) (net.IP, error) {
+	now := time.Now()
+	hostnameCache.Lock()
+	cached, ok := hostnameCache.entries[hostname]
+	hostnameCache.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.ip, cached.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hostnameLookupTimeout)
+	defer cancel()
+	ips, err := lookupIP(ctx, "ip", hostname)
+	if err == nil && len(ips) == 0 {
+		err = errors.New("no addresses")
+	}
+
+	var resolved net.IP
+	if err == nil {
+		resolved = ips[0]
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				resolved = ip
+				break
+			}
+		}
+	}
+
+	hostnameCache.Lock()
+	for name, entry := range hostnameCache.entries {
+		if !now.Before(entry.expires) {
+			delete(hostnameCache.entries, name)
+		}
+	}
+	hostnameCache.entries[hostname] = hostnameLookup{ip: resolved, err: err, expires: time.Now().Add(hostnameCacheTTL)}
+	hostnameCache.Unlock()
+	return resolved, err
+}
+
+// PrefetchHostnames 并发解析一批代理中以主机名提供的地址，结果缓存供之后的 Normalize 使用
+// 批量入库前调用，避免逐个代理串行等待解析；未开启主机名解析时不做任何事
+func PrefetchHostnames(proxies []*Proxy) {
+	if !resolveHostnames.Load() {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var hostnames []string
+	for _, p := range proxies {
+		if hostname := hostnameOf(p.IP); hostname != "" && !seen[hostname] {
+			seen[hostname] = true
+			hostnames = append(hostnames, hostname)
+		}
+	}
+
+	sem := make(chan struct{}, hostnamePrefetchWorker)
+	var wg sync.WaitGroup
+	for _, hostname := range hostnames {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(hostname string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resolveHostname(hostname)
+		}(hostname)
+	}
+	wg.Wait()
+}
+
+// Normalize 规范化入库的代理地址：去除空白，按配置解析或拒绝主机名，检查端口范围，
+// 再规范化IP的文本形式并拒绝回环、链路本地和私有地址
+// 获取、API添加和导入在查重前调用，保证按IP和端口查重时使用规范形式
+func (p *Proxy) Normalize() error {
+	if err := p.normalizeHost(); err != nil {
+		return err
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		return &ProxyValidationError{Violations: []Violation{{
+			Reason:  ViolationPort,
+			Message: fmt.Sprintf("port %d out of range [1, 65535]", p.Port),
+		}}}
+	}
+	return p.NormalizeIP()
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useLookup 在测试期间替换主机名解析函数并开启主机名解析，清空解析缓存
+func useLookup(t *testing.T, fn func(ctx context.Context, network, host string) ([]net.IP, error)) {
+	t.Helper()
+	reset := func() {
+		hostnameCache.Lock()
+		hostnameCache.entries = make(map[string]hostnameLookup)
+		hostnameCache.Unlock()
+	}
+	orig, origEnabled := lookupIP, resolveHostnames.Load()
+	lookupIP = fn
+	SetResolveHostnames(true)
+	reset()
+	t.Cleanup(func() {
+		lookupIP = orig
+		SetResolveHostnames(origEnabled)
+		reset()
+	})
+}
+
+func TestCanonicalizeProxyAddrs(t *testing.T) {
+	db := newTestDB(t)
+	// 旧版本写入的记录没有唯一索引约束
+	if err := db.Exec("DROP INDEX " + proxyAddrIndex).Error; err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+
+	canonical := newTestProxy(t, db, "1.1.1.1", 80)
+	clash := newTestProxy(t, db, "9.9.9.1", 80)
+	padded := newTestProxy(t, db, "9.9.9.2", 80)
+	first := newTestProxy(t, db, "2.2.2.2", 80)
+	second := newTestProxy(t, db, "9.9.9.3", 80)
+	db.Model(&Proxy{}).Where("id = ?", clash.ID).UpdateColumn("ip", "001.001.001.001")
+	db.Model(&Proxy{}).Where("id = ?", padded.ID).UpdateColumns(map[string]interface{}{"ip": "003.003.003.003", "ip_num": 0})
+	db.Model(&Proxy{}).Where("id = ?", second.ID).UpdateColumn("ip", "2.2.2.2")
+
+	if err := CanonicalizeProxyAddrs(db); err != nil {
+		t.Fatalf("CanonicalizeProxyAddrs: %v", err)
+	}
+
+	if got := loadProxy(t, db, padded.ID); got.IP != "3.3.3.3" || got.IPNum == 0 {
+		t.Errorf("padded proxy ip = %q, ip_num = %d, want 3.3.3.3 with ip_num", got.IP, got.IPNum)
+	}
+	for _, id := range []uint{canonical.ID, first.ID} {
+		loadProxy(t, db, id)
+	}
+	for _, id := range []uint{clash.ID, second.ID} {
+		var deleted Proxy
+		if err := db.Unscoped().First(&deleted, id).Error; err != nil {
+			t.Fatalf("load duplicate %d: %v", id, err)
+		}
+		if !deleted.DeletedAt.Valid || deleted.DeletedByReason != "duplicate" {
+			t.Errorf("duplicate %d deleted = %v, reason = %q, want deleted as duplicate", id, deleted.DeletedAt.Valid, deleted.DeletedByReason)
+		}
+	}
+
+	// 合并后可以建立唯一索引
+	if err := createProxyAddrIndex(db); err != nil {
+		t.Fatalf("createProxyAddrIndex after canonicalize: %v", err)
+	}
+}
+
+func TestProxyAddrUniqueIndex(t *testing.T) {
+	db := newTestDB(t)
+	if !db.Migrator().HasIndex(&Proxy{}, proxyAddrIndex) {
+		t.Fatalf("index %s missing after AutoMigrate", proxyAddrIndex)
+	}
+
+	p := newTestProxy(t, db, "1.1.1.1", 80)
+	if err := db.Create(&Proxy{IP: "1.1.1.1", Port: 80, Protocol: "http"}).Error; err == nil {
+		t.Error("created a second live proxy with the same address")
+	}
+	if err := db.Create(&Proxy{IP: "1.1.1.1", Port: 81, Protocol: "http"}).Error; err != nil {
+		t.Errorf("create proxy on another port: %v", err)
+	}
+
+	// 软删除后可以重新添加同一地址
+	if err := db.Delete(&Proxy{}, p.ID).Error; err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := db.Create(&Proxy{IP: "1.1.1.1", Port: 80, Protocol: "http"}).Error; err != nil {
+		t.Errorf("re-add deleted address: %v", err)
+	}
+}
+
+func TestResolveHostnameTimeoutAndCache(t *testing.T) {
+	var calls atomic.Int32
+	useLookup(t, func(ctx context.Context, network, host string) ([]net.IP, error) {
+		calls.Add(1)
+		if host == "slow.example" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("8.8.8.8")}, nil
+	})
+	orig := hostnameLookupTimeout
+	hostnameLookupTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { hostnameLookupTimeout = orig })
+
+	// 无响应的解析按超时失败，失败结果也缓存
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		if _, err := resolveHostname("slow.example"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("resolveHostname(slow) error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("resolveHostname(slow) took %v", elapsed)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("slow hostname looked up %d times, want 1", n)
+	}
+
+	// 优先使用IPv4地址，同一主机名只解析一次
+	for port := 80; port < 83; port++ {
+		p := &Proxy{IP: "Fast.Example.", Port: port}
+		if err := p.normalizeHost(); err != nil {
+			t.Fatalf("normalizeHost: %v", err)
+		}
+		if p.IP != "8.8.8.8" || p.Hostname != "fast.example" {
+			t.Errorf("normalized = %s (%s), want 8.8.8.8 (fast.example)", p.IP, p.Hostname)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("lookups = %d, want 2", n)
+	}
+}
+
+func TestPrefetchHostnamesConcurrent(t *testing.T) {
+	const hosts = 4
+	var (
+		mu      sync.Mutex
+		waiting int
+		release = make(chan struct{})
+		seen    = map[string]int{}
+	)
+	// 所有主机名同时处于解析中才返回，串行解析会一直阻塞到超时
+	useLookup(t, func(ctx context.Context, network, host string) ([]net.IP, error) {
+		mu.Lock()
+		seen[host]++
+		waiting++
+		if waiting == hosts {
+			close(release)
+		}
+		mu.Unlock()
+		select {
+		case <-release:
+			return []net.IP{net.ParseIP("8.8.8.8")}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+
+	var proxies []*Proxy
+	for _, host := range []string{"a.example", "b.example", "c.example", "d.example", "A.example", "1.1.1.1"} {
+		proxies = append(proxies, &Proxy{IP: host, Port: 80}, &Proxy{IP: host, Port: 81})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		PrefetchHostnames(proxies)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("PrefetchHostnames did not resolve hostnames concurrently")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != hosts {
+		t.Errorf("looked up %v, want %d hostnames", seen, hosts)
+	}
+	for host, n := range seen {
+		if n != 1 {
+			t.Errorf("%s looked up %d times, want 1", host, n)
+		}
+	}
+}
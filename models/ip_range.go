@@ -0,0 +1,229 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"proxy_pool/metrics"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmptyIPRange = errors.New("cidr or ip_prefix is required")
+	ErrInvalidIP    = errors.New("invalid ip")
+	ErrLoopbackIP   = errors.New("loopback ip not allowed")
+	ErrLinkLocalIP  = errors.New("link-local ip not allowed")
+	ErrPrivateIP    = errors.New("private ip not allowed")
+)
+
+// IPv4ToUint 将IPv4地址转换为整数，非IPv4地址返回false
+func IPv4ToUint(ip string) (uint32, bool) {
+	parsed := parseIP(ip)
+	if parsed == nil {
+		return 0, false
+	}
+	v4 := parsed.To4()
+	if v4 == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(v4), true
+}
+
+// NormalizeIP 校验并规范化代理IP的文本形式，拒绝无效、回环、链路本地和私有地址
+// 去除空白和IPv6地址的方括号，IPv4各段的前导零按十进制处理，不解析主机名
+func (p *Proxy) NormalizeIP() error {
+	ip := parseIP(p.IP)
+	if ip == nil {
+		return fmt.Errorf("%w: %q", ErrInvalidIP, p.IP)
+	}
+	if err := CheckPublicIP(ip); err != nil {
+		return err
+	}
+
+	p.IP = ip.String()
+	return nil
+}
+
+// CheckPublicIP 拒绝回环、链路本地、私有和未指定地址，代理地址和回调地址使用同样的规则
+func CheckPublicIP(ip net.IP) error {
+	switch {
+	case ip == nil:
+		return ErrInvalidIP
+	case ip.IsLoopback():
+		return fmt.Errorf("%w: %s", ErrLoopbackIP, ip)
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return fmt.Errorf("%w: %s", ErrLinkLocalIP, ip)
+	case ip.IsPrivate():
+		return fmt.Errorf("%w: %s", ErrPrivateIP, ip)
+	case ip.IsUnspecified():
+		return fmt.Errorf("%w: %s", ErrInvalidIP, ip)
+	}
+	return nil
+}
+
+// IP被拒绝的原因，用作 proxy_rejected_ip_total 的指标标签
+const (
+	ipRejectInvalid   = "invalid"
+	ipRejectLoopback  = "loopback"
+	ipRejectLinkLocal = "link_local"
+)
+
+// normalizeIPWithMetric 规范化IP，被拒绝时按来源计数
+// 私有地址计入 proxy_rejected_private_ip_total，无效、回环和链路本地地址按原因计入 proxy_rejected_ip_total
+func (p *Proxy) normalizeIPWithMetric() error {
+	err := p.NormalizeIP()
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrPrivateIP):
+		metrics.ProxyRejectedPrivateIP.WithLabelValues(p.Source).Inc()
+	case errors.Is(err, ErrLoopbackIP):
+		metrics.ProxyRejectedIP.WithLabelValues(p.Source, ipRejectLoopback).Inc()
+	case errors.Is(err, ErrLinkLocalIP):
+		metrics.ProxyRejectedIP.WithLabelValues(p.Source, ipRejectLinkLocal).Inc()
+	default:
+		metrics.ProxyRejectedIP.WithLabelValues(p.Source, ipRejectInvalid).Inc()
+	}
+	return err
+}
+
+// IPRange IP范围条件，CIDR 与 Prefix 二选一
+type IPRange struct {
+	CIDR   *net.IPNet // 网段，如 1.2.3.0/24
+	Prefix string     // IP文本前缀，如 1.2.3.
+}
+
+// ParseIPRange 解析 cidr 或 ip_prefix 参数
+func ParseIPRange(cidr, prefix string) (IPRange, error) {
+	cidr = strings.TrimSpace(cidr)
+	prefix = strings.TrimSpace(prefix)
+
+	switch {
+	case cidr != "":
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return IPRange{}, fmt.Errorf("invalid cidr: %q", cidr)
+		}
+		return IPRange{CIDR: ipNet}, nil
+	case prefix != "":
+		return IPRange{Prefix: prefix}, nil
+	}
+	return IPRange{}, ErrEmptyIPRange
+}
+
+// isIPv4 网段是否为IPv4
+func (r IPRange) isIPv4() bool {
+	return r.CIDR != nil && r.CIDR.IP.To4() != nil && len(r.CIDR.Mask) == net.IPv4len
+}
+
+// bounds 计算IPv4网段的起止数值
+func (r IPRange) bounds() (uint32, uint32) {
+	start := binary.BigEndian.Uint32(r.CIDR.IP.To4())
+	mask := binary.BigEndian.Uint32(r.CIDR.Mask)
+	return start, start | ^mask
+}
+
+// FindProxies 查找范围内的代理，limit 为0表示不限
+// IPv4网段使用 ip_num 范围查询，IPv6代理和未补全的代理 ip_num 为0，不参与匹配；IPv6网段需扫描所有IPv6代理后逐个匹配
+func (r IPRange) FindProxies(db *gorm.DB, limit int) ([]*Proxy, error) {
+	var proxies []*Proxy
+
+	switch {
+	case r.Prefix != "":
+		query := db.Where("ip LIKE ? ESCAPE '"+likeEscape+"'", escapeLike(r.Prefix)+"%").Order("ip_num ASC, ip ASC")
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		err := query.Find(&proxies).Error
+		return proxies, err
+
+	case r.isIPv4():
+		start, end := r.bounds()
+		query := db.Where("ip_num > 0 AND ip_num BETWEEN ? AND ?", start, end).Order("ip_num ASC")
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		err := query.Find(&proxies).Error
+		return proxies, err
+
+	case r.CIDR != nil:
+		var candidates []*Proxy
+		if err := db.Where("ip LIKE ?", "%:%").Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range candidates {
+			if ip := net.ParseIP(p.IP); ip != nil && r.CIDR.Contains(ip) {
+				proxies = append(proxies, p)
+				if limit > 0 && len(proxies) >= limit {
+					break
+				}
+			}
+		}
+		return proxies, nil
+	}
+
+	return nil, ErrEmptyIPRange
+}
+
+// DeleteProxies 删除范围内的代理，返回被删除的代理和跳过的固定代理数，includePinned 为 true 时固定代理也删除
+func (r IPRange) DeleteProxies(db *gorm.DB, reason string, includePinned bool) ([]*Proxy, int, error) {
+	found, err := r.FindProxies(db, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var proxies []*Proxy
+	var ids []uint
+	for _, p := range found {
+		if p.Pinned && !includePinned {
+			continue
+		}
+		proxies = append(proxies, p)
+		ids = append(ids, p.ID)
+	}
+	skipped := len(found) - len(proxies)
+	if len(proxies) == 0 {
+		return proxies, skipped, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 记录删除原因
+		if err := tx.Model(&Proxy{}).Where("id IN ?", ids).
+			UpdateColumn("deleted_by_reason", reason).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&Proxy{}).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return proxies, skipped, nil
+}
+
+// BackfillIPNum 为已有代理补全 ip_num 字段
+func BackfillIPNum(db *gorm.DB) error {
+	var proxies []*Proxy
+	return db.Where("ip_num = ?", 0).FindInBatches(&proxies, 500, func(tx *gorm.DB, batch int) error {
+		for _, p := range proxies {
+			num, ok := IPv4ToUint(p.IP)
+			if !ok {
+				continue
+			}
+			if err := tx.Model(p).UpdateColumn("ip_num", num).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// likeEscape LIKE 的转义字符，使用 ESCAPE 子句显式指定，MySQL 和 SQLite 行为一致
+const likeEscape = "!"
+
+// escapeLike 转义 LIKE 通配符，查询需带 ESCAPE '!' 子句
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
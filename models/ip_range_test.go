@@ -0,0 +1,88 @@
+package models
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestIPRangeFindProxies(t *testing.T) {
+	db := newTestDB(t)
+	inside := newTestProxy(t, db, "1.2.3.4", 80)
+	edge := newTestProxy(t, db, "1.2.3.255", 80)
+	outside := newTestProxy(t, db, "1.2.4.1", 80)
+	v6 := newTestProxy(t, db, "2001:db8::1", 80)
+
+	tests := []struct {
+		cidr   string
+		prefix string
+		want   []uint
+	}{
+		{cidr: "1.2.3.0/24", want: []uint{inside.ID, edge.ID}},
+		{cidr: "1.2.0.0/16", want: []uint{inside.ID, edge.ID, outside.ID}},
+		{cidr: "0.0.0.0/0", want: []uint{inside.ID, edge.ID, outside.ID}},
+		{cidr: "2001:db8::/32", want: []uint{v6.ID}},
+		{prefix: "1.2.3.", want: []uint{inside.ID, edge.ID}},
+		{prefix: "1_2", want: nil},
+	}
+	for _, tt := range tests {
+		r, err := ParseIPRange(tt.cidr, tt.prefix)
+		if err != nil {
+			t.Fatalf("ParseIPRange(%q, %q): %v", tt.cidr, tt.prefix, err)
+		}
+		found, err := r.FindProxies(db, 0)
+		if err != nil {
+			t.Fatalf("FindProxies(%q, %q): %v", tt.cidr, tt.prefix, err)
+		}
+		if got := proxyIDs(found); !equalIDs(got, tt.want) {
+			t.Errorf("FindProxies(%q, %q) = %v, want %v", tt.cidr, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestIPRangeDeleteKeepsIPv6Proxies(t *testing.T) {
+	db := newTestDB(t)
+	v4 := newTestProxy(t, db, "1.2.3.4", 80)
+	pinned := newTestProxy(t, db, "5.6.7.8", 80, func(p *Proxy) { p.Pinned = true })
+	v6 := newTestProxy(t, db, "2001:db8::1", 80)
+
+	r, err := ParseIPRange("0.0.0.0/0", "")
+	if err != nil {
+		t.Fatalf("ParseIPRange: %v", err)
+	}
+	deleted, skipped, err := r.DeleteProxies(db, "manual", false)
+	if err != nil {
+		t.Fatalf("DeleteProxies: %v", err)
+	}
+	if got := proxyIDs(deleted); !equalIDs(got, []uint{v4.ID}) || skipped != 1 {
+		t.Errorf("deleted %v, skipped %d, want [%d] and 1 pinned skipped", got, skipped, v4.ID)
+	}
+
+	var remaining []*Proxy
+	db.Order("id").Find(&remaining)
+	if got := proxyIDs(remaining); !equalIDs(got, []uint{pinned.ID, v6.ID}) {
+		t.Errorf("remaining proxies %v, want pinned %d and IPv6 %d", got, pinned.ID, v6.ID)
+	}
+}
+
+// proxyIDs 获取代理ID，按升序排列
+func proxyIDs(proxies []*Proxy) []uint {
+	ids := make([]uint, 0, len(proxies))
+	for _, p := range proxies {
+		ids = append(ids, p.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// equalIDs 比较两组已排序的代理ID
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRecordProxyErrorTruncatesAndClears(t *testing.T) {
+	db := newTestDB(t)
+	failing := newTestProxy(t, db, "1.1.1.1", 80)
+	healthy := newTestProxy(t, db, "2.2.2.2", 80)
+
+	// 按字符截断，多字节字符不会被截成半个
+	long := strings.Repeat("超", MaxLastErrorLength+10)
+	if err := RecordProxyError(db, failing.ID, long); err != nil {
+		t.Fatalf("RecordProxyError: %v", err)
+	}
+	stored := loadProxy(t, db, failing.ID)
+	if n := utf8.RuneCountInString(stored.LastError); n != MaxLastErrorLength || !utf8.ValidString(stored.LastError) {
+		t.Errorf("last error has %d runes, want %d valid runes", n, MaxLastErrorLength)
+	}
+	if stored.LastErrorAt == nil {
+		t.Error("last error time not set")
+	}
+
+	hasError := func(want bool) []uint {
+		t.Helper()
+		var found []*Proxy
+		if err := (ProxyFilter{HasError: Bool(want)}).Apply(db).Find(&found).Error; err != nil {
+			t.Fatalf("has_error=%v: %v", want, err)
+		}
+		return proxyIDs(found)
+	}
+	if got := hasError(true); !equalIDs(got, []uint{failing.ID}) {
+		t.Errorf("has_error=true = %v, want [%d]", got, failing.ID)
+	}
+	if got := hasError(false); !equalIDs(got, []uint{healthy.ID}) {
+		t.Errorf("has_error=false = %v, want [%d]", got, healthy.ID)
+	}
+
+	// 空原因表示成功，清空失败原因
+	if err := RecordProxyError(db, failing.ID, ""); err != nil {
+		t.Fatalf("clear error: %v", err)
+	}
+	cleared := loadProxy(t, db, failing.ID)
+	if cleared.LastError != "" || cleared.LastErrorAt != nil {
+		t.Errorf("after success last error = %q at %v, want cleared", cleared.LastError, cleared.LastErrorAt)
+	}
+	if got := hasError(true); len(got) != 0 {
+		t.Errorf("has_error=true after clearing = %v, want none", got)
+	}
+}
+
+func TestProxyRecordError(t *testing.T) {
+	p := &Proxy{}
+	p.RecordError("connection refused")
+	if p.LastError != "connection refused" || p.LastErrorAt == nil {
+		t.Errorf("after failure last error = %q at %v", p.LastError, p.LastErrorAt)
+	}
+	p.RecordError("")
+	if p.LastError != "" || p.LastErrorAt != nil {
+		t.Errorf("after success last error = %q at %v, want cleared", p.LastError, p.LastErrorAt)
+	}
+}
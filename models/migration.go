@@ -11,25 +11,92 @@ func AutoMigrate(db *gorm.DB) error {
 		return err
 	}
 
+	// 补全已有代理的IPv4数值
+	if err := BackfillIPNum(db); err != nil {
+		return err
+	}
+
+	// 统一已有代理的IP写法并合并重复记录，再为地址建唯一索引
+	if err := CanonicalizeProxyAddrs(db); err != nil {
+		return err
+	}
+	if err := createProxyAddrIndex(db); err != nil {
+		return err
+	}
+
 	// 创建代理使用记录表
 	if err := db.AutoMigrate(&ProxyUsage{}); err != nil {
 		return err
 	}
 
-	// 检查并修复 last_check 字段
-	var tableInfo struct {
-		ColumnDefault string
+	// 按代理查询使用历史时按时间排序
+	if !db.Migrator().HasIndex(&ProxyUsage{}, "idx_proxy_usages_proxy_created") {
+		if err := db.Exec("CREATE INDEX idx_proxy_usages_proxy_created ON proxy_usages (proxy_id, created_at)").Error; err != nil {
+			return err
+		}
+	}
+
+	// 创建代理标签表
+	if err := db.AutoMigrate(&ProxyTag{}); err != nil {
+		return err
+	}
+
+	// 创建代理评分历史表
+	if err := db.AutoMigrate(&ProxyScoreHistory{}); err != nil {
+		return err
+	}
+
+	// 创建代理回调及回调死信表
+	if err := db.AutoMigrate(&ProxyCallback{}, &WebhookDeadLetter{}); err != nil {
+		return err
+	}
+
+	// 创建代理信誉上报表
+	if err := db.AutoMigrate(&ProxyReputation{}); err != nil {
+		return err
+	}
+
+	// 创建代理源表，并为已有代理的来源补建记录
+	if err := db.AutoMigrate(&ProxySource{}); err != nil {
+		return err
+	}
+	if err := migrateSourceNames(db); err != nil {
+		return err
 	}
 
-	if err := db.Raw("SHOW COLUMNS FROM proxies WHERE Field = 'last_check'").Scan(&tableInfo).Error; err != nil {
+	// 创建代理源冲突记录表
+	if err := db.AutoMigrate(&ProxyConflict{}); err != nil {
 		return err
 	}
 
-	// 如果 last_check 字段的默认值不正确，修改它
-	if tableInfo.ColumnDefault != "" {
-		if err := db.Exec("ALTER TABLE proxies MODIFY COLUMN last_check datetime(3)").Error; err != nil {
+	// 创建代理分组表及分组成员表
+	if err := db.AutoMigrate(&ProxyGroup{}); err != nil {
+		return err
+	}
+
+	// MySQL 下为标签创建全文索引
+	if db.Dialector.Name() == "mysql" && !db.Migrator().HasIndex(&ProxyTag{}, "idx_proxy_tags_tag_fulltext") {
+		if err := db.Exec("CREATE FULLTEXT INDEX idx_proxy_tags_tag_fulltext ON proxy_tags (tag)").Error; err != nil {
+			return err
+		}
+	}
+
+	// 检查并修复 last_check 字段，如果默认值不正确，修改它
+	if db.Migrator().HasColumn(&Proxy{}, "last_check") {
+		columnTypes, err := db.Migrator().ColumnTypes(&Proxy{})
+		if err != nil {
 			return err
 		}
+		for _, column := range columnTypes {
+			if column.Name() != "last_check" {
+				continue
+			}
+			if value, ok := column.DefaultValue(); ok && value != "" {
+				if err := db.Migrator().AlterColumn(&Proxy{}, "LastCheck"); err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	return nil
@@ -43,4 +110,33 @@ type ProxyUsage struct {
 	Speed     int64  `gorm:"default:0"`
 	ErrorMsg  string `gorm:"type:text"`
 	TargetURL string `gorm:"type:varchar(1024)"`
+	Domain    string `gorm:"type:varchar(255);index"`
+}
+
+// maxUsageTargetURL 使用记录中目标URL的最大长度
+const maxUsageTargetURL = 1024
+
+// RecordUsage 记录一次代理使用
+func RecordUsage(db *gorm.DB, usage *ProxyUsage) error {
+	if len(usage.TargetURL) > maxUsageTargetURL {
+		usage.TargetURL = usage.TargetURL[:maxUsageTargetURL]
+	}
+	return db.Create(usage).Error
+}
+
+// proxyAddrIndex 代理地址（ip, port）唯一索引名
+const proxyAddrIndex = "idx_proxies_ip_port"
+
+// createProxyAddrIndex 为未删除代理的地址创建唯一索引，软删除的记录不参与约束，删除后可以重新添加
+// 不能直接用 (ip, port, deleted_at)：未删除记录的 deleted_at 都是 NULL，而 NULL 之间互不相等，约束不生效。
+// SQLite 使用部分索引；MySQL 不支持部分索引，用函数索引让未删除记录都取0、已删除记录取各自ID（需要 MySQL 8.0.13+）
+func createProxyAddrIndex(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&Proxy{}, proxyAddrIndex) {
+		return nil
+	}
+	sql := "CREATE UNIQUE INDEX " + proxyAddrIndex + " ON proxies (ip, port) WHERE deleted_at IS NULL"
+	if db.Dialector.Name() == "mysql" {
+		sql = "CREATE UNIQUE INDEX " + proxyAddrIndex + " ON proxies (ip, port, (IF(deleted_at IS NULL, 0, id)))"
+	}
+	return db.Exec(sql).Error
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// lastCheckDefault 获取 proxies.last_check 列的默认值
+func lastCheckDefault(t *testing.T, db *gorm.DB) (string, bool) {
+	t.Helper()
+	columns, err := db.Migrator().ColumnTypes(&Proxy{})
+	if err != nil {
+		t.Fatalf("column types: %v", err)
+	}
+	for _, column := range columns {
+		if column.Name() == "last_check" {
+			return column.DefaultValue()
+		}
+	}
+	t.Fatal("last_check column missing")
+	return "", false
+}
+
+func TestAutoMigrateOnSQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// 旧版本建的表 last_check 带有默认值
+	if err := db.Exec(`CREATE TABLE proxies (
+		id integer PRIMARY KEY AUTOINCREMENT, created_at datetime, updated_at datetime, deleted_at datetime,
+		ip varchar(64) NOT NULL, port integer NOT NULL, type varchar(32) NOT NULL, protocol varchar(32) NOT NULL,
+		region varchar(32) NOT NULL, source varchar(64) NOT NULL, last_check datetime DEFAULT CURRENT_TIMESTAMP)`).Error; err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if err := db.Exec(`INSERT INTO proxies (ip, port, type, protocol, region, source) VALUES ('1.1.1.1', 80, 'temp', 'http', 'other', 'test')`).Error; err != nil {
+		t.Fatalf("insert legacy proxy: %v", err)
+	}
+
+	if value, ok := lastCheckDefault(t, db); !ok || value == "" {
+		t.Fatalf("legacy last_check default = %q, %v, want CURRENT_TIMESTAMP", value, ok)
+	}
+
+	// 重复迁移不报错
+	for i := 0; i < 2; i++ {
+		if err := AutoMigrate(db); err != nil {
+			t.Fatalf("AutoMigrate run %d: %v", i+1, err)
+		}
+	}
+
+	if value, ok := lastCheckDefault(t, db); ok && value != "" {
+		t.Errorf("last_check default = %q, want none", value)
+	}
+	var proxies []Proxy
+	db.Find(&proxies)
+	if len(proxies) != 1 || proxies[0].IP != "1.1.1.1" {
+		t.Errorf("proxies after migration = %d, want the legacy proxy kept", len(proxies))
+	}
+	if !db.Migrator().HasIndex(&Proxy{}, proxyAddrIndex) {
+		t.Errorf("index %s missing after migration", proxyAddrIndex)
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// DefaultOrphanBatchSize 清理孤立记录时每批删除的数量
+const DefaultOrphanBatchSize = 500
+
+// orphanTables 按 proxy_id 引用代理的子表，代理删除后其中的记录不再使用
+// 代理是软删除，外键的 ON DELETE CASCADE 不会触发，因此由定时任务清理；
+// 回调及回调死信由回调通知自行处理，不在此列；分组成员表没有自增ID，统计和调度时只关联未删除的代理
+var orphanTables = []interface{}{
+	&ProxyUsage{},
+	&ProxyTag{},
+	&ProxyScoreHistory{},
+	&ProxyReputation{},
+	&ProxyConflict{},
+}
+
+// OrphanSweepResult 孤立记录清理结果
+type OrphanSweepResult struct {
+	DryRun bool             `json:"dry_run"` // 为 true 时只统计不删除
+	Tables map[string]int64 `json:"tables"`  // 各表的孤立记录数，表名为键
+	Total  int64            `json:"total"`
+}
+
+// orphanQuery 查询子表中代理已删除或不存在的记录，子表自身已软删除的记录也包括在内
+func orphanQuery(db *gorm.DB, model interface{}, table string) *gorm.DB {
+	return db.Unscoped().Model(model).Where(fmt.Sprintf(
+		"NOT EXISTS (SELECT 1 FROM proxies WHERE proxies.id = %s.proxy_id AND proxies.deleted_at IS NULL)", table))
+}
+
+// SweepOrphans 删除代理已删除或不存在的子表记录，dryRun 为 true 时只统计数量
+// 每批先查出 batchSize 条记录的ID再按ID删除，避免长时间锁表；batchSize 为0时使用默认值
+func SweepOrphans(db *gorm.DB, dryRun bool, batchSize int) (*OrphanSweepResult, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultOrphanBatchSize
+	}
+
+	result := &OrphanSweepResult{DryRun: dryRun, Tables: make(map[string]int64, len(orphanTables))}
+	for _, model := range orphanTables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return result, err
+		}
+		table := stmt.Schema.Table
+
+		var n int64
+		if dryRun {
+			if err := orphanQuery(db, model, table).Count(&n).Error; err != nil {
+				return result, fmt.Errorf("count orphans in %s: %w", table, err)
+			}
+		} else {
+			for {
+				var ids []uint
+				if err := orphanQuery(db, model, table).Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+					return result, fmt.Errorf("find orphans in %s: %w", table, err)
+				}
+				if len(ids) == 0 {
+					break
+				}
+				deleted := db.Unscoped().Where("id IN ?", ids).Delete(model)
+				if deleted.Error != nil {
+					return result, fmt.Errorf("delete orphans in %s: %w", table, deleted.Error)
+				}
+				n += deleted.RowsAffected
+				if len(ids) < batchSize {
+					break
+				}
+			}
+		}
+
+		result.Tables[table] = n
+		result.Total += n
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSweepOrphans(t *testing.T) {
+	db := newTestDB(t)
+	live := newTestProxy(t, db, "1.1.1.1", 80)
+	deleted := newTestProxy(t, db, "2.2.2.2", 80)
+	const missing = uint(999)
+
+	now := time.Now()
+	for _, id := range []uint{live.ID, deleted.ID, missing} {
+		addUsages(t, db, id, true, false)
+		rows := []interface{}{
+			&ProxyTag{ProxyID: id, Tag: "t"},
+			&ProxyScoreHistory{ProxyID: id, Score: 50, RecordedAt: now},
+			&ProxyReputation{ProxyID: id, Domain: "example.com", BanReported: true, ReportedAt: now},
+			&ProxyConflict{ProxyID: id, ExistingSource: "a", NewSource: "b", DetectedAt: now},
+		}
+		for _, row := range rows {
+			if err := db.Create(row).Error; err != nil {
+				t.Fatalf("create %T: %v", row, err)
+			}
+		}
+	}
+	if err := db.Delete(deleted).Error; err != nil {
+		t.Fatalf("delete proxy: %v", err)
+	}
+
+	want := map[string]int64{
+		"proxy_usages": 4, "proxy_tags": 2, "proxy_score_histories": 2, "proxy_reputations": 2, "proxy_conflicts": 2,
+	}
+	dry, err := SweepOrphans(db, true, 0)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !dry.DryRun || !reflect.DeepEqual(dry.Tables, want) || dry.Total != 12 {
+		t.Errorf("dry run = %+v, want %v", dry, want)
+	}
+	var usages int64
+	db.Model(&ProxyUsage{}).Count(&usages)
+	if usages != 6 {
+		t.Errorf("usages after dry run = %d, want nothing deleted", usages)
+	}
+
+	// 每批数量小于孤立记录数时分多批删除
+	swept, err := SweepOrphans(db, false, 3)
+	if err != nil {
+		t.Fatalf("SweepOrphans: %v", err)
+	}
+	if swept.DryRun || !reflect.DeepEqual(swept.Tables, want) || swept.Total != 12 {
+		t.Errorf("sweep = %+v, want %v", swept, want)
+	}
+	for _, model := range []interface{}{&ProxyUsage{}, &ProxyTag{}, &ProxyScoreHistory{}, &ProxyReputation{}, &ProxyConflict{}} {
+		var others int64
+		db.Unscoped().Model(model).Where("proxy_id <> ?", live.ID).Count(&others)
+		var kept int64
+		db.Model(model).Where("proxy_id = ?", live.ID).Count(&kept)
+		if others != 0 || kept == 0 {
+			t.Errorf("%T: %d orphan rows left, %d live rows kept", model, others, kept)
+		}
+	}
+
+	if again, err := SweepOrphans(db, false, 0); err != nil || again.Total != 0 {
+		t.Errorf("second sweep = %+v, %v, want nothing left", again, err)
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestCleanupInvalidKeepsPinnedProxies(t *testing.T) {
+	db := newTestDB(t)
+	usePolicies(t, map[string]CleanupPolicy{"paid": {Quarantine: true}})
+	failing := func(p *Proxy) { p.Success, p.Failure = 1, 9 }
+	plain := newTestProxy(t, db, "1.1.1.1", 80, failing)
+	quarantined := newTestProxy(t, db, "2.2.2.2", 80, failing, func(p *Proxy) { p.Source = "paid" })
+	pinned := newTestProxy(t, db, "3.3.3.3", 80, failing, func(p *Proxy) { p.Pinned = true })
+	pinnedPaid := newTestProxy(t, db, "4.4.4.4", 80, failing, func(p *Proxy) { p.Source = "paid"; p.Pinned = true })
+
+	result, err := CleanupInvalid(db)
+	if err != nil {
+		t.Fatalf("CleanupInvalid: %v", err)
+	}
+	if result.Deleted != 1 || result.Quarantined != 1 || result.Pinned != 2 {
+		t.Errorf("result = %+v, want 1 deleted, 1 quarantined, 2 pinned", result)
+	}
+
+	var count int64
+	db.Model(&Proxy{}).Where("id = ?", plain.ID).Count(&count)
+	if count != 0 {
+		t.Error("unpinned failing proxy still present, want deleted")
+	}
+	if got := loadProxy(t, db, quarantined.ID); !got.Quarantined {
+		t.Error("unpinned paid proxy not quarantined")
+	}
+	// 固定代理保留，只标记为不可用
+	for _, id := range []uint{pinned.ID, pinnedPaid.ID} {
+		if got := loadProxy(t, db, id); got.Available || got.Quarantined {
+			t.Errorf("pinned proxy %d available = %v, quarantined = %v, want false, false", id, got.Available, got.Quarantined)
+		}
+	}
+
+	// 已标记为不可用的固定代理不重复计数
+	if result, err := CleanupInvalid(db); err != nil || result.Pinned != 0 {
+		t.Errorf("second cleanup = %+v, %v, want no pinned proxies counted", result, err)
+	}
+	status, err := GetPoolStatus(db)
+	if err != nil {
+		t.Fatalf("GetPoolStatus: %v", err)
+	}
+	if status.PinnedProxies != 2 {
+		t.Errorf("pinned proxies = %d, want 2", status.PinnedProxies)
+	}
+}
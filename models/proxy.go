@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"proxy_pool/metrics"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +24,18 @@ const (
 	ProxyTypeHighAnon ProxyType = "high_anon" // 高匿代理
 )
 
+// AllProxyTypes 所有代理类型
+var AllProxyTypes = []ProxyType{ProxyTypeTemp, ProxyTypeLong, ProxyTypeAnon, ProxyTypeHighAnon}
+
+// IsValid 检查代理类型是否为已知类型
+func (t ProxyType) IsValid() bool {
+	switch t {
+	case ProxyTypeTemp, ProxyTypeLong, ProxyTypeAnon, ProxyTypeHighAnon:
+		return true
+	}
+	return false
+}
+
 // ProxyRegion 代理地区类型
 type ProxyRegion string
 
@@ -28,34 +44,56 @@ const (
 	ProxyRegionOther ProxyRegion = "other" // 国外
 )
 
+// IsValid 检查代理地区是否为已知地区
+func (r ProxyRegion) IsValid() bool {
+	return r == ProxyRegionCN || r == ProxyRegionOther
+}
+
 // Proxy 代理模型
 type Proxy struct {
 	gorm.Model
-	IP            string      `gorm:"type:varchar(64);not null"` // IP地址
-	Port          int         `gorm:"not null"`                  // 端口
-	Type          ProxyType   `gorm:"type:varchar(32);not null"` // 代理类型
-	Protocol      string      `gorm:"type:varchar(32);not null"` // 协议类型
-	Region        ProxyRegion `gorm:"type:varchar(32);not null"` // 代理地区
-	Source        string      `gorm:"type:varchar(64);not null"` // 代理来源
-	Anonymous     bool        `gorm:"default:false"`             // 是否匿名
-	Speed         int64       `gorm:"default:0"`                 // 响应速度(毫秒)
-	Success       int         `gorm:"default:0"`                 // 成功次数
-	Failure       int         `gorm:"default:0"`                 // 失败次数
-	Score         float64     `gorm:"default:0"`                 // 综合评分
-	LastCheck     time.Time   // 最后检查时间
-	Available     bool        `gorm:"default:true"`   // 是否可用
-	UseCount      int         `gorm:"default:0"`      // 使用次数
-	ConcurrentUse int         `gorm:"default:0"`      // 当前并发使用数
-	MaxConcurrent int         `gorm:"default:10"`     // 最大并发数
-	LastUsedAt    time.Time   `gorm:"type:timestamp"` // 最后使用时间
-	Version       int         `gorm:"default:0"`      // 乐观锁版本号
-	FailCount     int         `gorm:"type:int;default:0"`
+	IP                 string      `gorm:"type:varchar(64);not null"` // IP地址
+	IPNum              uint32      `gorm:"index;default:0"`           // IPv4数值，用于网段查询，IPv6为0
+	Port               int         `gorm:"not null"`                  // 端口
+	Type               ProxyType   `gorm:"type:varchar(32);not null"` // 代理类型
+	Protocol           string      `gorm:"type:varchar(32);not null"` // 协议类型
+	Region             ProxyRegion `gorm:"type:varchar(32);not null"` // 代理地区
+	Source             string      `gorm:"type:varchar(64);not null"` // 代理来源
+	Anonymous          bool        `gorm:"default:false"`             // 是否匿名
+	Speed              int64       `gorm:"default:0"`                 // 响应速度(毫秒)
+	Success            int         `gorm:"default:0"`                 // 成功次数
+	Failure            int         `gorm:"default:0"`                 // 失败次数
+	Score              float64     `gorm:"default:0"`                 // 综合评分
+	ReliabilityScore   float64     `gorm:"default:0"`                 // 按最近使用记录计算的可靠性评分，由 OptimizePool 更新
+	LastCheck          time.Time   // 最后检查时间
+	Available          bool        `gorm:"default:true"`   // 是否可用
+	UseCount           int         `gorm:"default:0"`      // 使用次数
+	ConcurrentUse      int         `gorm:"default:0"`      // 当前并发使用数
+	MaxConcurrent      int         `gorm:"default:10"`     // 最大并发数
+	LastUsedAt         time.Time   `gorm:"type:timestamp"` // 最后使用时间
+	Version            int         `gorm:"default:0"`      // 乐观锁版本号
+	FailCount          int         `gorm:"type:int;default:0"`
+	ConsecutiveSuccess int         `gorm:"default:0"`           // 连续成功次数，包含验证和使用上报
+	ConsecutiveFailure int         `gorm:"default:0"`           // 连续失败次数，包含验证和使用上报
+	Whitelisted        bool        `gorm:"default:false"`       // 白名单代理不参与自动清理
+	Pinned             bool        `gorm:"index;default:false"` // 固定代理不会被自动删除，自动清理时只标记为不可用，批量删除需显式指定
+	Hostname           string      `gorm:"type:varchar(255)"`   // 以主机名提供的代理解析前的主机名，IP保存解析结果
+	DeletedByReason    string      `gorm:"type:varchar(32)"`    // 删除原因
+	LastErrorClass     string      `gorm:"type:varchar(16)"`    // 最近一次验证的错误分类
+	LastSuccessURL     string      `gorm:"type:varchar(255)"`   // 最近一次验证通过的测试网站，未通过时为空
+	LastStatusCode     int         `gorm:"default:0"`           // 最近一次验证最后访问的测试网站返回的状态码，连接失败时为0
+	LastLatency        int64       `gorm:"default:0"`           // 最近一次验证最后访问的测试网站的耗时(毫秒)
+	Quarantined        bool        `gorm:"index;default:false"` // 是否被隔离，按清理策略代替删除，验证通过后恢复
+	VerifiedHTTPS      bool        `gorm:"index;default:false"` // 最近一次验证是否通过HTTPS测试网站
+	ExpiresAt          *time.Time  // 硬过期时间，如付费代理的到期时间，为空表示只按类型和检查时间估计有效期
+	LastError          string      `gorm:"type:varchar(255)"` // 最近一次失败的原因，下一次成功后清空
+	LastErrorAt        *time.Time  // 最近一次失败的时间，下一次成功后清空
 
 	mu sync.RWMutex `gorm:"-"` // 互斥锁，不保存到数据库
 }
 
 // TableName 表名
-func (Proxy) TableName() string {
+func (*Proxy) TableName() string {
 	return "proxies"
 }
 
@@ -68,11 +106,20 @@ func (p *Proxy) GetSuccessRate() float64 {
 	return float64(p.Success) / float64(total) * 100
 }
 
-// UpdateScore 更新评分
+// UpdateScore 没有使用记录时更新评分，可靠性评分取累计成功率，等同于 UpdateScoreWithUsage(nil)
 func (p *Proxy) UpdateScore() {
-	// 计算成功率
-	successRate := p.GetSuccessRate()
+	p.UpdateScoreWithUsage(nil)
+}
 
+// UpdateScoreWithUsage 按最近的使用记录更新可靠性评分和评分，recentUsage 按时间从近到远排列
+// 评分只由这里计算：可靠性评分代替累计成功率与速度综合，没有使用记录时可靠性评分即累计成功率，再乘以年龄衰减系数
+func (p *Proxy) UpdateScoreWithUsage(recentUsage []ProxyUsage) {
+	p.ReliabilityScore = p.ComputeReliabilityScore(recentUsage)
+	p.Score = p.scoreFor(p.ReliabilityScore) * p.AgeDecay(ScoreMaxAge())
+}
+
+// scoreFor 按成功率(百分比)和响应速度计算综合评分
+func (p *Proxy) scoreFor(successRate float64) float64 {
 	// 计算速度分数 (假设1000ms为基准)
 	speedScore := 100.0
 	if p.Speed > 0 {
@@ -80,7 +127,31 @@ func (p *Proxy) UpdateScore() {
 	}
 
 	// 综合评分 (成功率占70%，速度占30%)
-	p.Score = successRate*0.7 + speedScore*0.3
+	return successRate*0.7 + speedScore*0.3
+}
+
+const (
+	ReliabilityWindow      = 20  // 计算可靠性评分使用的最近使用记录数
+	ReliabilityDecayFactor = 0.1 // 可靠性评分的衰减系数，越大越偏重最近的记录
+)
+
+// ComputeReliabilityScore 按最近的使用记录计算可靠性评分(0-100)，recentUsage 按时间从近到远排列
+// 第 i 条记录(从0开始)的权重为 exp(-i*ReliabilityDecayFactor)，成功计1失败计0，按权重之和归一化；
+// 累计次数相同时最近失败较多的代理评分更低，没有使用记录时返回累计成功率
+func (p *Proxy) ComputeReliabilityScore(recentUsage []ProxyUsage) float64 {
+	if len(recentUsage) == 0 {
+		return p.GetSuccessRate()
+	}
+
+	var score, total float64
+	for i, usage := range recentUsage {
+		weight := math.Exp(-float64(i) * ReliabilityDecayFactor)
+		total += weight
+		if usage.Success {
+			score += weight
+		}
+	}
+	return score / total * 100
 }
 
 // AcquireProxy 获取代理使用权
@@ -108,16 +179,130 @@ func (p *Proxy) ReleaseProxy() {
 	}
 }
 
-// IsExpired 检查代理是否过期
-func (p *Proxy) IsExpired() bool {
-	switch p.Type {
+// 各类型代理距上次检查多久后视为过期
+const (
+	tempProxyExpiry    = 30 * time.Minute
+	longProxyExpiry    = 24 * time.Hour
+	defaultProxyExpiry = 1 * time.Hour
+)
+
+// ExpiredScope 过期代理的查询条件，与 IsExpired 的判断保持一致
+func ExpiredScope(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where(
+		"(type = ? AND last_check < ?) OR (type = ? AND last_check < ?) OR (type NOT IN ? AND last_check < ?)",
+		ProxyTypeTemp, now.Add(-tempProxyExpiry),
+		ProxyTypeLong, now.Add(-longProxyExpiry),
+		[]ProxyType{ProxyTypeTemp, ProxyTypeLong}, now.Add(-defaultProxyExpiry),
+	)
+}
+
+// expiryFor 获取指定类型的代理距上次检查多久后过期
+func expiryFor(proxyType ProxyType) time.Duration {
+	switch proxyType {
 	case ProxyTypeTemp:
-		return time.Since(p.LastCheck) > 30*time.Minute
+		return tempProxyExpiry
 	case ProxyTypeLong:
-		return time.Since(p.LastCheck) > 24*time.Hour
+		return longProxyExpiry
 	default:
-		return time.Since(p.LastCheck) > 1*time.Hour
+		return defaultProxyExpiry
+	}
+}
+
+// IsExpired 检查代理是否过期
+func (p *Proxy) IsExpired() bool {
+	return time.Since(p.LastCheck) > expiryFor(p.Type)
+}
+
+// IsHardExpired 是否已过硬过期时间，如付费代理已到期，之后不会再恢复可用
+func (p *Proxy) IsHardExpired() bool {
+	return p.ExpiresAt != nil && !time.Now().Before(*p.ExpiresAt)
+}
+
+// EstimatedTTL 估计代理在需要重新验证前的剩余有效时长，已过期时返回0
+// 设置了 ExpiresAt 时以其为准，否则按类型的过期时长减去距上次检查的时间，不超过过期时长
+func (p *Proxy) EstimatedTTL() time.Duration {
+	if p.ExpiresAt != nil {
+		if ttl := time.Until(*p.ExpiresAt); ttl > 0 {
+			return ttl
+		}
+		return 0
+	}
+
+	expiry := expiryFor(p.Type)
+	ttl := expiry - time.Since(p.LastCheck)
+	if ttl < 0 {
+		return 0
+	}
+	if ttl > expiry {
+		return expiry
+	}
+	return ttl
+}
+
+// ExpiryTime 代理需要重新验证的时间，设置了 ExpiresAt 时以其为准，否则为上次检查时间加类型的过期时长
+func (p *Proxy) ExpiryTime() time.Time {
+	if p.ExpiresAt != nil {
+		return *p.ExpiresAt
+	}
+	return p.LastCheck.Add(expiryFor(p.Type))
+}
+
+// GetProxiesNearExpiry 获取剩余有效时长已不足 warningWindow 但尚未过期的可用代理，按剩余有效时长升序
+// 与 EstimatedTTL 一致：设置了 ExpiresAt 时以其为准，否则按类型的过期时长和上次检查时间计算
+func GetProxiesNearExpiry(db *gorm.DB, warningWindow time.Duration) ([]*Proxy, error) {
+	return GetProxiesNearExpiryLimit(db, warningWindow, 0)
+}
+
+// GetProxiesNearExpiryLimit 同 GetProxiesNearExpiry，最多返回剩余有效时长最短的 limit 个代理，limit 为0表示不限
+func GetProxiesNearExpiryLimit(db *gorm.DB, warningWindow time.Duration, limit int) ([]*Proxy, error) {
+	now := time.Now()
+	// 剩余有效时长在 (0, warningWindow) 内，即上次检查时间在 (now-过期时长, now-过期时长+warningWindow) 内
+	checkedWithin := func(expiry time.Duration) (time.Time, time.Time) {
+		return now.Add(-expiry), now.Add(-expiry + warningWindow)
+	}
+	tempFrom, tempTo := checkedWithin(tempProxyExpiry)
+	longFrom, longTo := checkedWithin(longProxyExpiry)
+	otherFrom, otherTo := checkedWithin(defaultProxyExpiry)
+
+	// 每类代理内的剩余有效时长与排序列同序，分别排序取前 limit 个后合并，避免加载全部即将过期的代理
+	queries := []*gorm.DB{
+		db.Where("expires_at IS NOT NULL AND expires_at > ? AND expires_at < ?", now, now.Add(warningWindow)).Order("expires_at"),
+		db.Where("expires_at IS NULL AND type = ? AND last_check > ? AND last_check < ?", ProxyTypeTemp, tempFrom, tempTo).Order("last_check"),
+		db.Where("expires_at IS NULL AND type = ? AND last_check > ? AND last_check < ?", ProxyTypeLong, longFrom, longTo).Order("last_check"),
+		db.Where("expires_at IS NULL AND type NOT IN ? AND last_check > ? AND last_check < ?",
+			[]ProxyType{ProxyTypeTemp, ProxyTypeLong}, otherFrom, otherTo).Order("last_check"),
+	}
+
+	var proxies []*Proxy
+	for _, query := range queries {
+		query = query.Where("available = ?", true)
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		var batch []*Proxy
+		if err := query.Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, batch...)
+	}
+
+	sort.Slice(proxies, func(i, j int) bool {
+		return proxies[i].EstimatedTTL() < proxies[j].EstimatedTTL()
+	})
+	if limit > 0 && len(proxies) > limit {
+		proxies = proxies[:limit]
 	}
+	return proxies, nil
+}
+
+// Age 代理年龄，即创建至今的时长
+func (p *Proxy) Age() time.Duration {
+	return time.Since(p.CreatedAt)
+}
+
+// AgeHours 代理年龄(小时)
+func (p *Proxy) AgeHours() float64 {
+	return p.Age().Hours()
 }
 
 // UpdateStats 更新代理统计信息
@@ -160,36 +345,102 @@ func (p *Proxy) String() string {
 	return fmt.Sprintf("%s://%s:%d", p.Protocol, p.IP, p.Port)
 }
 
-// Clone 克隆代理对象
+// Clone 克隆代理对象，复制除锁以外的所有字段，时间指针也复制一份，修改克隆不影响原代理
 func (p *Proxy) Clone() *Proxy {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	return &Proxy{
-		Model:         p.Model,
-		IP:            p.IP,
-		Port:          p.Port,
-		Type:          p.Type,
-		Protocol:      p.Protocol,
-		Region:        p.Region,
-		Source:        p.Source,
-		Anonymous:     p.Anonymous,
-		Speed:         p.Speed,
-		Success:       p.Success,
-		Failure:       p.Failure,
-		Score:         p.Score,
-		LastCheck:     p.LastCheck,
-		Available:     p.Available,
-		UseCount:      p.UseCount,
-		MaxConcurrent: p.MaxConcurrent,
-		Version:       p.Version,
+		Model:              p.Model,
+		IP:                 p.IP,
+		IPNum:              p.IPNum,
+		Port:               p.Port,
+		Type:               p.Type,
+		Protocol:           p.Protocol,
+		Region:             p.Region,
+		Source:             p.Source,
+		Anonymous:          p.Anonymous,
+		Speed:              p.Speed,
+		Success:            p.Success,
+		Failure:            p.Failure,
+		Score:              p.Score,
+		ReliabilityScore:   p.ReliabilityScore,
+		LastCheck:          p.LastCheck,
+		Available:          p.Available,
+		UseCount:           p.UseCount,
+		ConcurrentUse:      p.ConcurrentUse,
+		MaxConcurrent:      p.MaxConcurrent,
+		LastUsedAt:         p.LastUsedAt,
+		Version:            p.Version,
+		FailCount:          p.FailCount,
+		ConsecutiveSuccess: p.ConsecutiveSuccess,
+		ConsecutiveFailure: p.ConsecutiveFailure,
+		Whitelisted:        p.Whitelisted,
+		Pinned:             p.Pinned,
+		Hostname:           p.Hostname,
+		DeletedByReason:    p.DeletedByReason,
+		LastErrorClass:     p.LastErrorClass,
+		LastSuccessURL:     p.LastSuccessURL,
+		LastStatusCode:     p.LastStatusCode,
+		LastLatency:        p.LastLatency,
+		Quarantined:        p.Quarantined,
+		VerifiedHTTPS:      p.VerifiedHTTPS,
+		ExpiresAt:          cloneTime(p.ExpiresAt),
+		LastError:          p.LastError,
+		LastErrorAt:        cloneTime(p.LastErrorAt),
+	}
+}
+
+// cloneTime 复制时间指针
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// BeforeSave GORM 保存前钩子，校验字段和IP并计算IPv4数值
+// 创建时 BeforeSave 先于 BeforeCreate 执行，因此在这里设置默认并发数
+// Update/Updates 只写部分列，钩子拿到的模型可能为空，这时不做整行校验
+func (p *Proxy) BeforeSave(tx *gorm.DB) error {
+	if isColumnUpdate(tx, p) {
+		return nil
+	}
+	if p.ID == 0 {
+		p.applyDefaultMaxConcurrent()
+	}
+	if err := p.validateWithMetric(); err != nil {
+		return err
 	}
+	if err := p.normalizeIPWithMetric(); err != nil {
+		return err
+	}
+	p.IPNum, _ = IPv4ToUint(p.IP)
+	return nil
+}
+
+// isColumnUpdate 是否为 Update/Updates 按列更新，写入的值在 Dest 中而不是钩子接收的模型上
+func isColumnUpdate(tx *gorm.DB, p *Proxy) bool {
+	switch dest := tx.Statement.Dest.(type) {
+	case map[string]interface{}:
+		return true
+	case *Proxy:
+		return dest != p
+	case Proxy:
+		return true
+	}
+	return false
 }
 
 // BeforeCreate GORM 创建前钩子
 func (p *Proxy) BeforeCreate(tx *gorm.DB) error {
-	if p.MaxConcurrent == 0 {
-		p.MaxConcurrent = 10 // 默认最大并发数
+	p.applyDefaultMaxConcurrent()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := p.NormalizeIP(); err != nil {
+		return err
 	}
 	p.LastCheck = time.Now() // 设置初始检查时间
 	return nil
@@ -211,26 +462,81 @@ func (p *Proxy) Delete(db *gorm.DB) error {
 	return db.Delete(p).Error
 }
 
-// FindByIP 根据IP和端口查找代理
+// FindByID 根据ID查找代理
+func FindByID(db *gorm.DB, id uint) (*Proxy, error) {
+	var proxy Proxy
+	if err := db.First(&proxy, id).Error; err != nil {
+		return nil, err
+	}
+	return &proxy, nil
+}
+
+// FindByIP 根据IP和端口查找代理，IP按规范形式匹配
 func FindByIP(db *gorm.DB, ip string, port int) (*Proxy, error) {
 	var proxy Proxy
-	err := db.Where("ip = ? AND port = ?", ip, port).First(&proxy).Error
+	err := db.Where("ip = ? AND port = ?", CanonicalIP(ip), port).First(&proxy).Error
 	if err != nil {
 		return nil, err
 	}
 	return &proxy, nil
 }
 
-// ListAvailable 获取所有可用代理
-func ListAvailable(db *gorm.DB) ([]*Proxy, error) {
+// ListAvailable 获取符合条件的可用代理
+func ListAvailable(db *gorm.DB, filter ListFilter) ([]*Proxy, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
 	var proxies []*Proxy
-	err := db.Where("available = ?", true).Find(&proxies).Error
+	err := filter.ProxyFilter().Apply(db).Find(&proxies).Error
 	if err != nil {
 		return nil, err
 	}
 	return proxies, nil
 }
 
+// ListAvailableAfterCursor 按ID升序获取ID大于 afterID 的至多 limit 个符合条件的可用代理，afterID 为0时从头开始
+// 使用 id > ? 而不是 OFFSET，翻到后面的页时不需要扫描并丢弃前面的记录
+func ListAvailableAfterCursor(db *gorm.DB, filter ListFilter, afterID uint, limit int) ([]*Proxy, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := filter.ProxyFilter()
+	query.AfterID = afterID
+	query.Limit = limit
+
+	var proxies []*Proxy
+	if err := query.Apply(db).Find(&proxies).Error; err != nil {
+		return nil, err
+	}
+	return proxies, nil
+}
+
+// EachAvailable 按ID升序逐个遍历符合条件的可用代理，用于流式导出
+func EachAvailable(db *gorm.DB, filter ListFilter, fn func(*Proxy) error) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	rows, err := filter.ProxyFilter().Apply(db.Model(&Proxy{})).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var proxy Proxy
+		if err := db.ScanRows(rows, &proxy); err != nil {
+			return err
+		}
+		if err := fn(&proxy); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // ListByType 根据类型获取代理
 func ListByType(db *gorm.DB, proxyType ProxyType) ([]*Proxy, error) {
 	var proxies []*Proxy
@@ -374,7 +680,8 @@ func FindBestProxy(db *gorm.DB, proxyType ProxyType, region ProxyRegion) (*Proxy
 	return &proxy, nil
 }
 
-// CleanupExpired 清理过期代理
+// CleanupExpired 清理过期代理，包括超过检查有效期和已过硬过期时间的代理
+// 已过硬过期时间的代理即使代理源配置了隔离也会删除，见 cleanupWhere
 func CleanupExpired(db *gorm.DB) error {
 	var expiredIDs []uint
 
@@ -385,32 +692,107 @@ func CleanupExpired(db *gorm.DB) error {
 	}
 
 	for _, p := range proxies {
-		if p.IsExpired() {
+		if p.IsExpired() || p.IsHardExpired() {
 			expiredIDs = append(expiredIDs, p.ID)
 		}
 	}
 
-	if len(expiredIDs) > 0 {
-		return BatchDelete(db, expiredIDs)
+	if len(expiredIDs) == 0 {
+		return nil
 	}
-	return nil
+	_, err := cleanupWhere(db, "expired", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id IN ?", expiredIDs)
+	})
+	return err
+}
+
+// CleanupOldProxies 删除创建时间超过 maxAge 的代理，白名单代理和固定代理除外
+// 观察模式下不删除，只标记为不可用并通知，已标记过的代理不再重复处理，返回的删除数为0
+func CleanupOldProxies(db *gorm.DB, maxAge time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-maxAge)
+	var deleted int64
+
+	if GetRetentionMode() == RetentionObserve {
+		_, err := observeCleanup(db, db.Model(&Proxy{}).
+			Where("created_at < ? AND whitelisted = ? AND pinned = ?", cutoff, false, false), CleanupActionDelete, "age")
+		return 0, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 记录删除原因
+		if err := tx.Model(&Proxy{}).
+			Where("created_at < ? AND whitelisted = ? AND pinned = ?", cutoff, false, false).
+			UpdateColumn("deleted_by_reason", "age").Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("created_at < ? AND whitelisted = ? AND pinned = ?", cutoff, false, false).Delete(&Proxy{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return nil
+	})
+
+	return deleted, err
+}
+
+// CountOldProxies 统计创建时间超过 maxAge 的代理数量，白名单代理和固定代理除外
+func CountOldProxies(db *gorm.DB, maxAge time.Duration) (int64, error) {
+	var count int64
+	err := db.Model(&Proxy{}).
+		Where("created_at < ? AND whitelisted = ? AND pinned = ?", time.Now().Add(-maxAge), false, false).
+		Count(&count).Error
+	return count, err
+}
+
+var (
+	scoreAgeMu  sync.RWMutex
+	scoreMaxAge time.Duration
+)
+
+// SetScoreMaxAge 设置计算评分时年龄衰减使用的代理最大存活时间，0表示不衰减
+func SetScoreMaxAge(maxAge time.Duration) {
+	scoreAgeMu.Lock()
+	defer scoreAgeMu.Unlock()
+	scoreMaxAge = maxAge
 }
 
-// CleanupInvalid 清理无效代理
-func CleanupInvalid(db *gorm.DB) error {
-	// 删除成功率过低或速度过慢的代理
-	return db.Delete(&Proxy{}, "success_rate < ? OR (speed > ? AND speed != 0)", 20.0, 5000).Error
+// ScoreMaxAge 获取计算评分时年龄衰减使用的代理最大存活时间
+func ScoreMaxAge() time.Duration {
+	scoreAgeMu.RLock()
+	defer scoreAgeMu.RUnlock()
+	return scoreMaxAge
+}
+
+// AgeDecay 计算代理的年龄衰减系数，超过 maxAge 一半的代理评分减半，尚未入库的代理不衰减
+func (p *Proxy) AgeDecay(maxAge time.Duration) float64 {
+	if maxAge > 0 && !p.CreatedAt.IsZero() && p.Age() > maxAge/2 {
+		return 0.5
+	}
+	return 1.0
+}
+
+// CleanupInvalid 清理成功率过低或速度过慢的代理，未检查过的代理和白名单代理除外，按代理源的清理策略处理，固定代理只标记为不可用
+func CleanupInvalid(db *gorm.DB) (CleanupResult, error) {
+	return cleanupWhere(db, "invalid", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("success+failure > 0 AND whitelisted = ?", false).
+			Where(successRateExpr+" < ? OR (speed > ? AND speed != 0)", 20.0, 5000)
+	})
 }
 
 // GetPoolStatus 获取代理池状态
 type PoolStatus struct {
-	TotalProxies     int64             // 总代理数
-	AvailableProxies int64             // 可用代理数
-	ExpiredProxies   int64             // 过期代理数
-	TypeDistribution map[ProxyType]int // 各类型代理分布
-	AvgResponseTime  int64             // 平均响应时间
-	SuccessRate      float64           // 整体成功率
-	LastUpdate       time.Time         // 最后更新时间
+	TotalProxies             int64             `json:"total_proxies"`              // 总代理数
+	AvailableProxies         int64             `json:"available_proxies"`          // 可用代理数
+	PinnedProxies            int64             `json:"pinned_proxies"`             // 固定代理数
+	ExpiredProxies           int64             `json:"expired_proxies"`            // 过期代理数
+	ProxiesNeedingValidation int64             `json:"proxies_needing_validation"` // 已到验证时间的代理数，与 IsExpired 判断一致
+	ProxiesInCooldown        int64             `json:"proxies_in_cooldown"`        // 调度器中处于冷却期的代理数，仅 GetFullStatus 填充
+	TypeDistribution         map[ProxyType]int `json:"type_distribution"`          // 各类型代理分布
+	AvgResponseTime          int64             `json:"avg_response_time"`          // 平均响应时间
+	SuccessRate              float64           `json:"success_rate"`               // 整体成功率
+	LastUpdate               time.Time         `json:"last_update"`                // 最后更新时间
 }
 
 func GetPoolStatus(db *gorm.DB) (*PoolStatus, error) {
@@ -429,16 +811,27 @@ func GetPoolStatus(db *gorm.DB) (*PoolStatus, error) {
 		return nil, err
 	}
 
-	// 获取过期代理数
-	var proxies []*Proxy
-	if err := db.Find(&proxies).Error; err != nil {
+	// 获取固定代理数
+	if err := db.Model(&Proxy{}).Where("pinned = ?", true).Count(&status.PinnedProxies).Error; err != nil {
 		return nil, err
 	}
-	for _, p := range proxies {
-		if p.IsExpired() {
-			status.ExpiredProxies++
-		}
-		status.TypeDistribution[p.Type]++
+
+	// 获取过期代理数，过期的代理即需要重新验证的代理
+	if err := ExpiredScope(db.Model(&Proxy{}), time.Now()).Count(&status.ExpiredProxies).Error; err != nil {
+		return nil, err
+	}
+	status.ProxiesNeedingValidation = status.ExpiredProxies
+
+	// 统计各类型代理分布
+	var typeCounts []struct {
+		Type  ProxyType
+		Count int
+	}
+	if err := db.Model(&Proxy{}).Select("type, COUNT(*) AS count").Group("type").Scan(&typeCounts).Error; err != nil {
+		return nil, err
+	}
+	for _, tc := range typeCounts {
+		status.TypeDistribution[tc.Type] = tc.Count
 	}
 
 	// 计算平均响应时间
@@ -460,8 +853,8 @@ func GetPoolStatus(db *gorm.DB) (*PoolStatus, error) {
 	return status, nil
 }
 
-// GetProxyHistory 获取代理历史记录
-type ProxyHistory struct {
+// RecentProxy 最近添加的代理
+type RecentProxy struct {
 	ID        uint      `json:"id"`
 	IP        string    `json:"ip"`
 	Port      int       `json:"port"`
@@ -472,12 +865,41 @@ type ProxyHistory struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-func GetProxyHistory(db *gorm.DB, limit int) ([]ProxyHistory, error) {
-	var history []ProxyHistory
+// GetRecentProxies 获取最近添加的代理，按添加时间降序
+func GetRecentProxies(db *gorm.DB, limit int) ([]RecentProxy, error) {
+	var proxies []RecentProxy
 	err := db.Model(&Proxy{}).
 		Select("id, ip, port, success, failure, speed, score, created_at").
 		Order("created_at DESC").
 		Limit(limit).
+		Find(&proxies).Error
+	return proxies, err
+}
+
+// MaxProxyHistory 单次查询代理使用历史的最大条数
+const MaxProxyHistory = 500
+
+// ProxyHistory 代理的一条使用记录
+type ProxyHistory struct {
+	ProxyID   uint      `json:"proxy_id"`
+	Success   bool      `json:"success"`
+	Speed     int64     `json:"speed"` // 本次使用的响应时间(毫秒)
+	Score     float64   `json:"score"` // 代理当前评分，使用记录不保存当时的评分
+	TargetURL string    `json:"target_url"`
+	ErrorMsg  string    `json:"error_msg"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetProxyHistory 获取代理最近的使用记录，按时间降序
+func GetProxyHistory(db *gorm.DB, proxyID uint, limit int) ([]ProxyHistory, error) {
+	var history []ProxyHistory
+	err := db.Model(&ProxyUsage{}).
+		Select("proxy_usages.proxy_id, proxy_usages.success, proxy_usages.speed, proxies.score, "+
+			"proxy_usages.target_url, proxy_usages.error_msg, proxy_usages.created_at AS timestamp").
+		Joins("JOIN proxies ON proxies.id = proxy_usages.proxy_id").
+		Where("proxy_usages.proxy_id = ?", proxyID).
+		Order("proxy_usages.created_at DESC, proxy_usages.id DESC").
+		Limit(limit).
 		Find(&history).Error
 	return history, err
 }
@@ -543,9 +965,14 @@ func calculateStabilityScore(p *Proxy) float64 {
 	// 基础分数
 	baseScore := 100.0
 
-	// 根据连续失败次数减分
-	if p.Failure > 0 {
-		baseScore -= math.Min(float64(p.Failure)*5, 50)
+	// 根据连续失败次数减分，成功后清零
+	if p.ConsecutiveFailure > 0 {
+		baseScore -= math.Min(float64(p.ConsecutiveFailure)*10, 50)
+	}
+
+	// 根据连续成功次数加分
+	if p.ConsecutiveSuccess > 0 {
+		baseScore += math.Min(float64(p.ConsecutiveSuccess), 10)
 	}
 
 	// 根据响应时间波动减分
@@ -588,39 +1015,158 @@ func calculateQualityScore(p *Proxy) float64 {
 	return math.Max(0, math.Min(baseScore, 100))
 }
 
-// OptimizePool 优化代理池
-func OptimizePool(db *gorm.DB) error {
-	// 清理性能差的代理
-	if err := db.Delete(&Proxy{}, "score < ? OR success_rate < ?", 30.0, 20.0).Error; err != nil {
-		return err
+// optimizeBatchSize 优化代理池时每批重新计算评分的代理数
+const optimizeBatchSize = 500
+
+// OptimizeResult 一次代理池优化的结果
+type OptimizeResult struct {
+	Deleted     int64 `json:"deleted"`     // 因评分或成功率过低删除的代理数
+	Quarantined int64 `json:"quarantined"` // 按清理策略隔离的代理数
+	Pinned      int64 `json:"pinned"`      // 未删除只标记为不可用的固定代理数
+	Observed    int64 `json:"observed"`    // 观察模式下本应清理、只标记为不可用的代理数
+	Rescored    int64 `json:"rescored"`    // 评分发生变化的代理数
+	Promoted    int64 `json:"promoted"`    // 提高最大并发数的代理数
+}
+
+// OptimizePool 优化代理池，config 为空时使用默认维护配置
+// 删除已检查过且评分或成功率低于阈值的代理(固定代理只标记为不可用)，分批重新计算评分(含年龄衰减，见 SetScoreMaxAge)并按批写回，最后提高高评分代理的最大并发数
+func OptimizePool(db *gorm.DB, config *MaintenanceConfig) (*OptimizeResult, error) {
+	if config == nil {
+		config = DefaultMaintenanceConfig
 	}
+	result := &OptimizeResult{}
 
-	// 更新所有代理的评分
+	// 清理性能差的代理，未检查过的代理和白名单代理不清理，按代理源的清理策略处理
+	cleaned, err := cleanupWhere(db, "performance", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("success+failure > 0 AND whitelisted = ?", false).
+			Where("score < ? OR "+successRateExpr+" < ?", config.MinScore, config.MinSuccessRate)
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Deleted, result.Quarantined, result.Pinned = cleaned.Deleted, cleaned.Quarantined, cleaned.Pinned
+	result.Observed = cleaned.Observed
+
+	// 分批重新计算评分，只写回变化的评分；每批的使用记录一次查询
 	var proxies []*Proxy
-	if err := db.Find(&proxies).Error; err != nil {
-		return err
+	err = db.Model(&Proxy{}).
+		Select("id, success, failure, speed, score, reliability_score, created_at").
+		FindInBatches(&proxies, optimizeBatchSize, func(tx *gorm.DB, batch int) error {
+			ids := make([]uint, len(proxies))
+			for i, p := range proxies {
+				ids[i] = p.ID
+			}
+			recent, err := recentUsages(db, ids, ReliabilityWindow)
+			if err != nil {
+				return err
+			}
+
+			var changes []ScoreChange
+			reliability := make(map[uint]float64)
+			for _, p := range proxies {
+				oldScore, oldReliability := p.Score, p.ReliabilityScore
+				p.UpdateScoreWithUsage(recent[p.ID])
+				if p.Score != oldScore {
+					changes = append(changes, ScoreChange{ProxyID: p.ID, OldScore: oldScore, NewScore: p.Score})
+				}
+				if p.ReliabilityScore != oldReliability {
+					reliability[p.ID] = p.ReliabilityScore
+				}
+			}
+			if err := updateScores(db, changes); err != nil {
+				return err
+			}
+			if err := updateReliabilityScores(db, reliability); err != nil {
+				return err
+			}
+			result.Rescored += int64(len(changes))
+			return RecordScoreChanges(db, changes)
+		}).Error
+	if err != nil {
+		return result, err
 	}
 
-	for _, p := range proxies {
-		p.UpdateScore()
-		if err := p.Save(db); err != nil {
-			return err
-		}
+	// 提高高评分代理的最大并发数，只升不降，保留按类型或来源配置的更高并发数
+	promoted := db.Model(&Proxy{}).
+		Where("score >= ? AND max_concurrent < ?", config.HighScoreThreshold, config.HighScoreMaxConcurrent).
+		UpdateColumn("max_concurrent", config.HighScoreMaxConcurrent)
+	if promoted.Error != nil {
+		return result, promoted.Error
+	}
+	result.Promoted = promoted.RowsAffected
+	return result, nil
+}
+
+// updateScores 用一条 CASE 语句批量写回评分
+func updateScores(db *gorm.DB, changes []ScoreChange) error {
+	if len(changes) == 0 {
+		return nil
 	}
 
-	// 设置最大并发数
-	return db.Model(&Proxy{}).
-		Where("score >= ?", 80.0).
-		Update("max_concurrent", 20).Error
+	ids := make([]uint, 0, len(changes))
+	args := make([]interface{}, 0, 2*len(changes))
+	for _, c := range changes {
+		ids = append(ids, c.ProxyID)
+		args = append(args, c.ProxyID, c.NewScore)
+	}
+	expr := "CASE id " + strings.Repeat("WHEN ? THEN ? ", len(changes)) + "END"
+	return db.Model(&Proxy{}).Where("id IN ?", ids).UpdateColumn("score", gorm.Expr(expr, args...)).Error
+}
+
+// updateReliabilityScores 用一条 CASE 语句批量写回可靠性评分
+func updateReliabilityScores(db *gorm.DB, scores map[uint]float64) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(scores))
+	args := make([]interface{}, 0, 2*len(scores))
+	for id, score := range scores {
+		ids = append(ids, id)
+		args = append(args, id, score)
+	}
+	expr := "CASE id " + strings.Repeat("WHEN ? THEN ? ", len(scores)) + "END"
+	return db.Model(&Proxy{}).Where("id IN ?", ids).UpdateColumn("reliability_score", gorm.Expr(expr, args...)).Error
+}
+
+// recentUsages 获取每个代理最近的 limit 条使用记录，按时间从近到远排列
+// 一批代理只执行一条查询，按代理分区编号后取每个代理的前 limit 条，需要 MySQL 8.0 或 SQLite 3.25 以上的窗口函数
+func recentUsages(db *gorm.DB, proxyIDs []uint, limit int) (map[uint][]ProxyUsage, error) {
+	result := make(map[uint][]ProxyUsage, len(proxyIDs))
+	if len(proxyIDs) == 0 {
+		return result, nil
+	}
+
+	ranked := db.Model(&ProxyUsage{}).
+		Select("id, proxy_id, success, created_at, ROW_NUMBER() OVER (PARTITION BY proxy_id ORDER BY created_at DESC, id DESC) AS rn").
+		Where("proxy_id IN ?", proxyIDs)
+	var usages []ProxyUsage
+	// 软删除条件已在子查询中生效，外层查询不再追加
+	err := db.Unscoped().Table("(?) AS ranked", ranked).
+		Select("id, proxy_id, success, created_at").
+		Where("rn <= ?", limit).
+		Order("proxy_id, created_at DESC, id DESC").
+		Find(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, usage := range usages {
+		result[usage.ProxyID] = append(result[usage.ProxyID], usage)
+	}
+	return result, nil
 }
 
 // MaintenanceConfig 代理池维护配置
 type MaintenanceConfig struct {
-	MinProxies       int           // 最小代理数量
-	MaxProxies       int           // 最大代理数量
-	MinScore         float64       // 最低评分要求
-	MinSuccessRate   float64       // 最低成功率要求
-	MaxResponseTime  int64         // 最大响应时间(毫秒)
+	MinProxies      int     // 最小代理数量
+	MaxProxies      int     // 最大代理数量
+	MinScore        float64 // 最低评分要求
+	MinSuccessRate  float64 // 最低成功率要求
+	MaxResponseTime int64   // 最大响应时间(毫秒)
+
+	HighScoreThreshold     float64 // 评分不低于该值的代理提高最大并发数
+	HighScoreMaxConcurrent int     // 高评分代理的最大并发数，已高于该值的代理不调整
+
 	CheckInterval    time.Duration // 检查间隔
 	CleanupInterval  time.Duration // 清理间隔
 	OptimizeInterval time.Duration // 优化间隔
@@ -628,233 +1174,505 @@ type MaintenanceConfig struct {
 
 // DefaultMaintenanceConfig 默认维护配置
 var DefaultMaintenanceConfig = &MaintenanceConfig{
-	MinProxies:       100,
-	MaxProxies:       1000,
-	MinScore:         30.0,
-	MinSuccessRate:   20.0,
-	MaxResponseTime:  5000,
+	MinProxies:      100,
+	MaxProxies:      1000,
+	MinScore:        30.0,
+	MinSuccessRate:  20.0,
+	MaxResponseTime: 5000,
+
+	HighScoreThreshold:     80.0,
+	HighScoreMaxConcurrent: 20,
+
 	CheckInterval:    5 * time.Minute,
 	CleanupInterval:  1 * time.Hour,
 	OptimizeInterval: 12 * time.Hour,
 }
 
-// AutoMaintenance 自动维护代理池
-func AutoMaintenance(db *gorm.DB, config *MaintenanceConfig) error {
+// AutoMaintenance 自动维护代理池，config 为空时使用默认维护配置
+// 可用代理少于 MinProxies 时调用 fetch 获取代理，fetch 为空时不获取；清理和优化由各自的定时任务执行，这里不再重复；
+// models 不能依赖 core，获取代理由调用方以 fetch 传入，如 ProxyPool 的补充获取
+func AutoMaintenance(db *gorm.DB, config *MaintenanceConfig, fetch func() error) error {
+	if config == nil {
+		config = DefaultMaintenanceConfig
+	}
+
 	// 获取代理池状态
 	status, err := GetPoolStatus(db)
 	if err != nil {
 		return err
 	}
 
-	// 检查代理数量是否足够
-	if status.AvailableProxies < int64(config.MinProxies) {
-		// TODO: 触发代理获取任务
-		return nil
+	// 检查代理数量是否足够，不足时获取代理
+	if status.AvailableProxies < int64(config.MinProxies) && fetch != nil {
+		return fetch()
 	}
+	return nil
+}
 
-	// 清理过期和无效代理
-	if err := CleanupExpired(db); err != nil {
-		return err
-	}
-	if err := CleanupInvalid(db); err != nil {
-		return err
-	}
+// 高可用代理的默认条件
+const (
+	DefaultHAMinChecks      = 5  // 最少检查次数
+	DefaultHAMinSuccessRate = 80 // 最低成功率(百分比)
+)
 
-	// 优化代理池
-	return OptimizePool(db)
+// GetHighAvailabilityProxies 获取检查次数不少于 minChecks 且成功率不低于 minSuccessRate 的可用代理，按评分降序，limit 为0表示不限
+// 只检查过一次的代理成功率可能是100%但并不可靠，要求多次检查的一致结果
+func GetHighAvailabilityProxies(db *gorm.DB, minChecks int, minSuccessRate float64, limit int) ([]*Proxy, error) {
+	filter := ProxyFilter{
+		MinChecks:      minChecks,
+		MinSuccessRate: minSuccessRate,
+		Available:      Bool(true),
+		Limit:          limit,
+	}
+	var proxies []*Proxy
+	err := filter.Apply(db).Find(&proxies).Error
+	return proxies, err
 }
 
-// ScheduleProxy 调度代理
+// ScheduleOptions ScheduleProxy 的调度选项
 type ScheduleOptions struct {
 	PreferredType   ProxyType   // 优先代理类型
 	PreferredRegion ProxyRegion // 优先地区
 	MinScore        float64     // 最低评分要求
+	MinSuccessRate  float64     // 最低成功率要求(百分比)
 	MaxResponseTime int64       // 最大响应时间要求
 	RequireAnon     bool        // 是否要求匿名
+	MinCheckCount   int         // 最少检查次数(成功+失败)，0表示不限
 }
 
-func ScheduleProxy(db *gorm.DB, opts *ScheduleOptions) (*Proxy, error) {
-	query := db.Where("available = ?", true)
-
-	if opts.PreferredType != "" {
-		query = query.Where("type = ?", opts.PreferredType)
-	}
-	if opts.PreferredRegion != "" {
-		query = query.Where("region = ?", opts.PreferredRegion)
-	}
-	if opts.MinScore > 0 {
-		query = query.Where("score >= ?", opts.MinScore)
-	}
-	if opts.MaxResponseTime > 0 {
-		query = query.Where("speed <= ?", opts.MaxResponseTime)
+// Filter 将调度选项转换为代理查询条件
+func (opts *ScheduleOptions) Filter() ProxyFilter {
+	filter := ProxyFilter{
+		Type:           opts.PreferredType,
+		Region:         opts.PreferredRegion,
+		MinScore:       opts.MinScore,
+		MinSuccessRate: opts.MinSuccessRate,
+		MaxSpeed:       opts.MaxResponseTime,
+		MinChecks:      opts.MinCheckCount,
+		Available:      Bool(true),
 	}
 	if opts.RequireAnon {
-		query = query.Where("anonymous = ?", true)
+		filter.Anonymous = Bool(true)
 	}
+	return filter
+}
 
-	// 按评分降序排序
-	query = query.Order("score DESC")
-
-	var proxy Proxy
-	err := query.First(&proxy).Error
-	if err != nil {
-		return nil, err
+// Requirements 将调度选项转换为代理可复用条件
+func (opts *ScheduleOptions) Requirements() *ReuseRequirements {
+	return &ReuseRequirements{
+		Type:           opts.PreferredType,
+		Region:         opts.PreferredRegion,
+		MinScore:       opts.MinScore,
+		MinSuccessRate: opts.MinSuccessRate,
+		MaxSpeed:       opts.MaxResponseTime,
+		RequireAnon:    opts.RequireAnon,
 	}
+}
 
-	// 尝试获取代理使用权
-	if !proxy.AcquireProxy() {
-		// 如果获取失败，尝试获取下一个可用代理
-		err = query.Where("id != ?", proxy.ID).First(&proxy).Error
-		if err != nil {
+// schedulePageSize ScheduleProxy 每次查询的候选代理数
+const schedulePageSize = 10
+
+// ScheduleMaxCandidates ScheduleProxy 最多尝试的候选代理数
+var ScheduleMaxCandidates = 50
+
+// ErrNoAvailableProxy 没有符合条件且可以获取使用权的代理
+var ErrNoAvailableProxy = errors.New("no available proxy")
+
+// ScheduleProxy 按评分从高到低逐页查询符合调度选项的代理，获取第一个可复用且未满载的代理的使用权
+// 并发使用数在数据库中按条件递增，查询之后被其他实例占满的代理跳过并尝试下一个；
+// 最多尝试 ScheduleMaxCandidates 个代理，都无法获取时返回 ErrNoAvailableProxy
+func ScheduleProxy(db *gorm.DB, opts *ScheduleOptions) (*Proxy, error) {
+	// 按调度选项过滤，按评分降序排序
+	query := opts.Filter().Apply(db).Session(&gorm.Session{})
+	req := opts.Requirements()
+
+	for offset := 0; offset < ScheduleMaxCandidates; offset += schedulePageSize {
+		limit := schedulePageSize
+		if remaining := ScheduleMaxCandidates - offset; remaining < limit {
+			limit = remaining
+		}
+
+		var proxies []*Proxy
+		if err := query.Offset(offset).Limit(limit).Find(&proxies).Error; err != nil {
 			return nil, err
 		}
-		if !proxy.AcquireProxy() {
-			return nil, errors.New("no available proxy")
+
+		// 尝试获取代理使用权，失败时尝试下一个代理
+		for _, proxy := range proxies {
+			if !proxy.isReusableNow(req) || !proxy.AcquireProxy() {
+				continue
+			}
+			acquired, err := acquireConcurrentUse(db, proxy)
+			if err != nil {
+				return nil, err
+			}
+			if !acquired {
+				proxy.ReleaseProxy()
+				continue
+			}
+			return proxy, nil
+		}
+
+		if len(proxies) < limit {
+			break
 		}
 	}
+	return nil, ErrNoAvailableProxy
+}
 
-	return &proxy, nil
+// acquireConcurrentUse 在数据库中占用代理的一个并发数并记录使用，代理已满载时返回 false
+// 按当前值递增而不是写回查询到的值，多个实例同时调度时不会相互覆盖
+func acquireConcurrentUse(db *gorm.DB, proxy *Proxy) (bool, error) {
+	res := db.Model(&Proxy{}).
+		Where("id = ? AND concurrent_use < max_concurrent", proxy.ID).
+		UpdateColumns(map[string]interface{}{
+			"concurrent_use": gorm.Expr("concurrent_use + 1"),
+			"use_count":      gorm.Expr("use_count + 1"),
+			"last_used_at":   proxy.LastUsedAt,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// isReusableNow 不经过调度器时判断代理是否可复用，并发数使用数据库中的记录
+func (p *Proxy) isReusableNow(req *ReuseRequirements) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.IsReusable(req, ProxySchedulerStats{InUse: p.ConcurrentUse})
 }
 
-// ReleaseScheduledProxy 释放调度的代理
+// ReleaseScheduledProxy 释放调度的代理，评分按最近的使用记录计算，与 OptimizePool 一致
+// 并发使用数在数据库中按当前值递减，不随其他字段写回
 func ReleaseScheduledProxy(db *gorm.DB, proxy *Proxy, success bool, speed int64) error {
+	oldScore := proxy.Score
 	proxy.ReleaseProxy()
 	proxy.UpdateStats(success, speed)
-	return proxy.Save(db)
+	recent, err := recentUsages(db, []uint{proxy.ID}, ReliabilityWindow)
+	if err != nil {
+		return err
+	}
+	proxy.UpdateScoreWithUsage(recent[proxy.ID])
+	if err := proxy.Save(db.Omit("concurrent_use")); err != nil {
+		return err
+	}
+	if err := db.Model(&Proxy{}).
+		Where("id = ? AND concurrent_use > 0", proxy.ID).
+		UpdateColumn("concurrent_use", gorm.Expr("concurrent_use - 1")).Error; err != nil {
+		return err
+	}
+	return RecordScoreChange(db, proxy.ID, oldScore, proxy.Score)
 }
 
-// GetProxyLoadBalancer 获取代理负载均衡器
-type LoadBalancer struct {
-	db          *gorm.DB
-	opts        *ScheduleOptions
-	proxyCache  []*Proxy
-	currentIdx  int
-	mu          sync.RWMutex
-	lastRefresh time.Time
+// IsProxyExists 检查代理是否已存在，IP按规范形式匹配
+func IsProxyExists(db *gorm.DB, ip string, port int) (bool, error) {
+	var count int64
+	err := db.Model(&Proxy{}).Where("ip = ? AND port = ?", CanonicalIP(ip), port).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
-func NewLoadBalancer(db *gorm.DB, opts *ScheduleOptions) *LoadBalancer {
-	return &LoadBalancer{
-		db:   db,
-		opts: opts,
+// existingProxyChunkSize 查询已存在代理时每条SQL的最大代理数，避免超出SQLite的参数个数限制
+const existingProxyChunkSize = 400
+
+// proxyKey 代理的去重键 ip:port
+func proxyKey(ip string, port int) string {
+	return fmt.Sprintf("%s:%d", ip, port)
+}
+
+// GetExistingProxies 查询一批代理中已存在于数据库的代理，返回以 ip:port 为键的代理，只包含ID、IP、端口和来源
+// 每批代理只执行一条查询：MySQL 使用 (ip, port) IN ((...), ...)，其他数据库使用 OR 连接的条件
+func GetExistingProxies(db *gorm.DB, proxies []*Proxy) (map[string]*Proxy, error) {
+	existing := make(map[string]*Proxy)
+	for start := 0; start < len(proxies); start += existingProxyChunkSize {
+		end := start + existingProxyChunkSize
+		if end > len(proxies) {
+			end = len(proxies)
+		}
+		chunk := proxies[start:end]
+
+		query := db.Model(&Proxy{}).Select("id", "ip", "port", "source")
+		if db.Dialector.Name() == "mysql" {
+			pairs := make([][]interface{}, len(chunk))
+			for i, proxy := range chunk {
+				pairs[i] = []interface{}{proxy.IP, proxy.Port}
+			}
+			query = query.Where("(ip, port) IN ?", pairs)
+		} else {
+			conds := db.Where("ip = ? AND port = ?", chunk[0].IP, chunk[0].Port)
+			for _, proxy := range chunk[1:] {
+				conds = conds.Or("ip = ? AND port = ?", proxy.IP, proxy.Port)
+			}
+			query = query.Where(conds)
+		}
+
+		var rows []*Proxy
+		if err := query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			existing[proxyKey(row.IP, row.Port)] = row
+		}
+	}
+	return existing, nil
+}
+
+// GetExistingProxySet 查询一批代理中已存在于数据库的代理，返回以 ip:port 为键的集合
+// 只需判断是否存在时使用；需要已有代理的ID或来源时使用 GetExistingProxies
+func GetExistingProxySet(db *gorm.DB, proxies []*Proxy) (map[string]bool, error) {
+	existing, err := GetExistingProxies(db, proxies)
+	if err != nil {
+		return nil, err
 	}
+	set := make(map[string]bool, len(existing))
+	for key := range existing {
+		set[key] = true
+	}
+	return set, nil
+}
+
+// BatchCreateResult 批量创建代理的结果
+type BatchCreateResult struct {
+	Added   int     // 新建的代理数
+	Updated int     // 已存在而更新的代理数
+	Failed  int     // 写入失败的代理数
+	Errors  []error // 每个写入失败的代理的错误，附带代理地址
 }
 
-// GetProxy 获取下一个可用代理
-func (lb *LoadBalancer) GetProxy() (*Proxy, error) {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
+// saveBatchProxy 写入批量创建中的一个代理，current 为已存在的代理，为空时新建
+// 已存在的代理来源不同时记录冲突并返回
+func saveBatchProxy(tx *gorm.DB, proxy, current *Proxy) (*ProxyConflict, error) {
+	if current == nil {
+		return nil, tx.Create(proxy).Error
+	}
 
-	// 检查是否需要刷新代理缓存
-	if time.Since(lb.lastRefresh) > 5*time.Minute || len(lb.proxyCache) == 0 {
-		if err := lb.refreshProxyCache(); err != nil {
+	var conflict *ProxyConflict
+	if current.Source != proxy.Source {
+		conflict = &ProxyConflict{
+			ProxyID:        current.ID,
+			ExistingSource: current.Source,
+			NewSource:      proxy.Source,
+		}
+		if err := RecordConflict(tx, conflict); err != nil {
 			return nil, err
 		}
 	}
 
-	if len(lb.proxyCache) == 0 {
-		return nil, errors.New("no available proxy")
+	// 如果代理已存在，更新其信息
+	// 字段已在写入前校验过，使用 UpdateColumns 跳过对空模型的 BeforeSave 校验
+	updates := map[string]interface{}{
+		"type":       proxy.Type,
+		"protocol":   proxy.Protocol,
+		"region":     proxy.Region,
+		"source":     proxy.Source,
+		"anonymous":  proxy.Anonymous,
+		"updated_at": time.Now(),
+	}
+	// 代理源返回了到期时间时同步更新，如付费代理续期
+	if proxy.ExpiresAt != nil {
+		updates["expires_at"] = proxy.ExpiresAt
 	}
+	err := tx.Model(&Proxy{}).
+		Where("ip = ? AND port = ?", proxy.IP, proxy.Port).
+		UpdateColumns(updates).Error
+	if err != nil {
+		return nil, err
+	}
+	return conflict, nil
+}
+
+// BatchCreateWithDuplicateCheck 批量创建代理（带去重）
+// 已存在的代理只更新类型、协议等信息；同一批中重复的代理只创建一次
+// 已存在的代理来源不同时记录一条代理源冲突
+// 单个代理写入失败时记录错误并继续处理其余代理，至少一个代理新建或更新成功才提交事务；
+// 地址无法使用、字段不合法或为私有地址的代理在写入前跳过，不计入结果
+func BatchCreateWithDuplicateCheck(db *gorm.DB, proxies []*Proxy) (*BatchCreateResult, error) {
+	result := &BatchCreateResult{}
+	if len(proxies) == 0 {
+		return result, nil
+	}
+
+	valid := make([]*Proxy, 0, len(proxies))
+	for _, proxy := range proxies {
+		// 跳过未开启解析时的主机名和无法解析的主机名
+		if err := proxy.normalizeHost(); err != nil {
+			zap.L().Warn("代理地址无法使用，已跳过",
+				zap.String("代理", fmt.Sprintf("%s:%d", proxy.IP, proxy.Port)),
+				zap.String("来源", proxy.Source),
+				zap.Error(err),
+			)
+			continue
+		}
 
-	// 轮询选择代理
-	startIdx := lb.currentIdx
-	for {
-		lb.currentIdx = (lb.currentIdx + 1) % len(lb.proxyCache)
-		proxy := lb.proxyCache[lb.currentIdx]
+		// 跳过字段不合法的代理
+		proxy.applyDefaultMaxConcurrent()
+		if err := proxy.validateWithMetric(); err != nil {
+			logViolations(proxy, err)
+			continue
+		}
 
-		if proxy.AcquireProxy() {
-			return proxy, nil
+		// 跳过私有和回环地址
+		if err := proxy.normalizeIPWithMetric(); err != nil {
+			continue
+		}
+		valid = append(valid, proxy)
+	}
+	if len(valid) == 0 {
+		return result, nil
+	}
+
+	// 使用事务处理，事务提交后再计入冲突指标
+	var conflicts []*ProxyConflict
+	fail := func(proxy *Proxy, err error) {
+		result.Failed++
+		result.Errors = append(result.Errors, fmt.Errorf("proxy %s:%d: %w", proxy.IP, proxy.Port, err))
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 一次查询出已存在的代理
+		existing, err := GetExistingProxies(tx, valid)
+		if err != nil {
+			return err
 		}
 
-		// 如果已经遍历了所有代理仍未找到可用的，刷新缓存重试
-		if lb.currentIdx == startIdx {
-			if err := lb.refreshProxyCache(); err != nil {
-				return nil, err
+		for i, proxy := range valid {
+			key := proxyKey(proxy.IP, proxy.Port)
+			current, exists := existing[key]
+
+			// 每个代理在各自的保存点内写入，失败时只回滚该代理，事务可以继续使用
+			savepoint := fmt.Sprintf("proxy_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
 			}
-			if len(lb.proxyCache) == 0 {
-				return nil, errors.New("no available proxy")
+			conflict, err := saveBatchProxy(tx, proxy, current)
+			if err != nil {
+				if rbErr := tx.RollbackTo(savepoint).Error; rbErr != nil {
+					return rbErr
+				}
+				fail(proxy, err)
+				continue
 			}
-		}
-	}
-}
 
-// refreshProxyCache 刷新代理缓存
-func (lb *LoadBalancer) refreshProxyCache() error {
-	query := lb.db.Where("available = ?", true)
+			if !exists {
+				existing[key] = proxy
+				result.Added++
+				continue
+			}
+			if conflict != nil {
+				conflicts = append(conflicts, conflict)
+				current.Source = proxy.Source
+			}
+			result.Updated++
+		}
 
-	if lb.opts.PreferredType != "" {
-		query = query.Where("type = ?", lb.opts.PreferredType)
+		// 没有任何代理写入成功时回滚，避免只留下冲突记录
+		if result.Added+result.Updated == 0 && result.Failed > 0 {
+			return errors.Join(result.Errors...)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
 	}
-	if lb.opts.PreferredRegion != "" {
-		query = query.Where("region = ?", lb.opts.PreferredRegion)
+
+	if result.Failed > 0 {
+		zap.L().Warn("部分代理保存失败",
+			zap.Int("新建数", result.Added),
+			zap.Int("更新数", result.Updated),
+			zap.Int("失败数", result.Failed),
+			zap.Error(result.Errors[0]),
+		)
 	}
-	if lb.opts.MinScore > 0 {
-		query = query.Where("score >= ?", lb.opts.MinScore)
+	for _, conflict := range conflicts {
+		metrics.ConflictsDetected.WithLabelValues(conflict.ExistingSource, conflict.NewSource).Inc()
 	}
-	if lb.opts.MaxResponseTime > 0 {
-		query = query.Where("speed <= ?", lb.opts.MaxResponseTime)
+	return result, nil
+}
+
+// RecordStreak 更新连续成功和连续失败次数
+func (p *Proxy) RecordStreak(success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if success {
+		p.ConsecutiveSuccess++
+		p.ConsecutiveFailure = 0
+	} else {
+		p.ConsecutiveFailure++
+		p.ConsecutiveSuccess = 0
 	}
-	if lb.opts.RequireAnon {
-		query = query.Where("anonymous = ?", true)
+}
+
+// RecordStreak 在数据库中更新代理的连续成功和连续失败次数
+func RecordStreak(db *gorm.DB, proxyID uint, success bool) error {
+	return RecordStreaks(db, []uint{proxyID}, success, 1, false)
+}
+
+// RecordStreaks 批量更新一组代理的连续次数，这些代理最近连续 count 次结果均为 success
+// reset 为 true 表示之前有相反的结果，连续次数直接设为 count，否则在原有基础上累加
+func RecordStreaks(db *gorm.DB, proxyIDs []uint, success bool, count int, reset bool) error {
+	if len(proxyIDs) == 0 || count <= 0 {
+		return nil
 	}
 
-	var proxies []*Proxy
-	err := query.Order("score DESC").Find(&proxies).Error
-	if err != nil {
-		return err
+	column, other := "consecutive_success", "consecutive_failure"
+	if !success {
+		column, other = other, column
 	}
 
-	lb.proxyCache = proxies
-	lb.currentIdx = 0
-	lb.lastRefresh = time.Now()
-	return nil
+	var value interface{} = count
+	if !reset {
+		value = gorm.Expr(column+" + ?", count)
+	}
+	return db.Model(&Proxy{}).Where("id IN ?", proxyIDs).UpdateColumns(map[string]interface{}{
+		column: value,
+		other:  0,
+	}).Error
 }
 
-// IsProxyExists 检查代理是否已存在
-func IsProxyExists(db *gorm.DB, ip string, port int) (bool, error) {
-	var count int64
-	err := db.Model(&Proxy{}).Where("ip = ? AND port = ?", ip, port).Count(&count).Error
-	if err != nil {
-		return false, err
+// MaxLastErrorLength 代理失败原因保存的最大字符数
+const MaxLastErrorLength = 255
+
+// truncateError 按字符截断失败原因
+func truncateError(msg string) string {
+	if runes := []rune(msg); len(runes) > MaxLastErrorLength {
+		return string(runes[:MaxLastErrorLength])
 	}
-	return count > 0, nil
+	return msg
 }
 
-// BatchCreateWithDuplicateCheck 批量创建代理（带去重）
-func BatchCreateWithDuplicateCheck(db *gorm.DB, proxies []*Proxy) error {
-	if len(proxies) == 0 {
-		return nil
+// RecordError 记录代理最近一次失败的原因，msg 为空时清空
+func (p *Proxy) RecordError(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if msg == "" {
+		p.LastError = ""
+		p.LastErrorAt = nil
+		return
 	}
+	now := time.Now()
+	p.LastError = truncateError(msg)
+	p.LastErrorAt = &now
+}
 
-	// 使用事务处理
-	return db.Transaction(func(tx *gorm.DB) error {
-		for _, proxy := range proxies {
-			// 检查代理是否已存在
-			exists, err := IsProxyExists(tx, proxy.IP, proxy.Port)
-			if err != nil {
-				return err
-			}
+// RecordProxyError 在数据库中记录代理最近一次失败的原因，msg 为空时清空
+func RecordProxyError(db *gorm.DB, proxyID uint, msg string) error {
+	if msg == "" {
+		return ClearProxyErrors(db, []uint{proxyID})
+	}
+	return db.Model(&Proxy{}).Where("id = ?", proxyID).UpdateColumns(map[string]interface{}{
+		"last_error":    truncateError(msg),
+		"last_error_at": time.Now(),
+	}).Error
+}
 
-			// 如果代理不存在，则创建
-			if !exists {
-				if err := tx.Create(proxy).Error; err != nil {
-					return err
-				}
-			} else {
-				// 如果代理已存在，更新其信息
-				if err := tx.Model(&Proxy{}).
-					Where("ip = ? AND port = ?", proxy.IP, proxy.Port).
-					Updates(map[string]interface{}{
-						"type":      proxy.Type,
-						"protocol":  proxy.Protocol,
-						"region":    proxy.Region,
-						"source":    proxy.Source,
-						"anonymous": proxy.Anonymous,
-					}).Error; err != nil {
-					return err
-				}
-			}
-		}
+// ClearProxyErrors 批量清空代理最近一次失败的原因
+func ClearProxyErrors(db *gorm.DB, proxyIDs []uint) error {
+	if len(proxyIDs) == 0 {
 		return nil
-	})
+	}
+	return db.Model(&Proxy{}).Where("id IN ? AND last_error_at IS NOT NULL", proxyIDs).UpdateColumns(map[string]interface{}{
+		"last_error":    "",
+		"last_error_at": nil,
+	}).Error
 }
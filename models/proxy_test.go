@@ -0,0 +1,391 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建迁移好的内存SQLite数据库，测试结束后关闭
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// 内存数据库每个连接各自独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := AutoMigrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// newTestProxy 创建一个合法的代理并写入数据库
+func newTestProxy(t testing.TB, db *gorm.DB, ip string, port int, modify ...func(*Proxy)) *Proxy {
+	t.Helper()
+
+	p := &Proxy{
+		IP:        ip,
+		Port:      port,
+		Type:      ProxyTypeTemp,
+		Protocol:  "http",
+		Region:    ProxyRegionOther,
+		Source:    "test",
+		Available: true,
+	}
+	for _, fn := range modify {
+		fn(p)
+	}
+	if err := db.Create(p).Error; err != nil {
+		t.Fatalf("create proxy %s:%d: %v", ip, port, err)
+	}
+	return p
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+		err  error
+	}{
+		{"public ipv4", "8.8.8.8", "8.8.8.8", nil},
+		{"public ipv4 with spaces", " 1.2.3.4 ", "1.2.3.4", nil},
+		{"public ipv6", "[2001:4860:4860::8888]", "2001:4860:4860::8888", nil},
+		{"private 10/8", "10.1.2.3", "", ErrPrivateIP},
+		{"private 172.16/12", "172.20.0.1", "", ErrPrivateIP},
+		{"private 192.168/16", "192.168.1.1", "", ErrPrivateIP},
+		{"ipv6 ula", "fd12:3456::1", "", ErrPrivateIP},
+		{"ipv4 loopback", "127.0.0.1", "", ErrLoopbackIP},
+		{"ipv6 loopback", "::1", "", ErrLoopbackIP},
+		{"ipv4 link-local", "169.254.1.1", "", ErrLinkLocalIP},
+		{"ipv6 link-local", "fe80::1", "", ErrLinkLocalIP},
+		{"unspecified", "0.0.0.0", "", ErrInvalidIP},
+		{"hostname", "example.com", "", ErrInvalidIP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Proxy{IP: tt.ip}
+			err := p.NormalizeIP()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NormalizeIP(%q) error = %v, want %v", tt.ip, err, tt.err)
+			}
+			if err == nil && p.IP != tt.want {
+				t.Errorf("NormalizeIP(%q) = %q, want %q", tt.ip, p.IP, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateRejectsPrivateIP(t *testing.T) {
+	db := newTestDB(t)
+
+	p := &Proxy{IP: "192.168.0.10", Port: 8080, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionCN, Source: "test"}
+	if err := db.Create(p).Error; !errors.Is(err, ErrPrivateIP) {
+		t.Fatalf("create private proxy error = %v, want %v", err, ErrPrivateIP)
+	}
+}
+
+func TestColumnUpdatesSkipRowValidation(t *testing.T) {
+	db := newTestDB(t)
+	a := newTestProxy(t, db, "1.1.1.1", 80)
+	b := newTestProxy(t, db, "2.2.2.2", 80)
+
+	if err := BatchUpdateAvailable(db, []uint{a.ID, b.ID}, false); err != nil {
+		t.Fatalf("BatchUpdateAvailable: %v", err)
+	}
+	var count int64
+	db.Model(&Proxy{}).Where("available = ?", false).Count(&count)
+	if count != 2 {
+		t.Errorf("unavailable count = %d, want 2", count)
+	}
+
+	if err := db.Model(&Proxy{}).Where("id = ?", a.ID).Updates(map[string]interface{}{"score": 42.0}).Error; err != nil {
+		t.Fatalf("Updates on empty model: %v", err)
+	}
+
+	// 整行保存仍然校验
+	a.Port = 0
+	if err := db.Save(a).Error; !errors.Is(err, ErrInvalidProxy) {
+		t.Errorf("save invalid proxy error = %v, want %v", err, ErrInvalidProxy)
+	}
+}
+
+func TestCleanupOldProxies(t *testing.T) {
+	db := newTestDB(t)
+	old := newTestProxy(t, db, "1.1.1.1", 80)
+	whitelisted := newTestProxy(t, db, "2.2.2.2", 80, func(p *Proxy) { p.Whitelisted = true })
+	pinned := newTestProxy(t, db, "3.3.3.3", 80, func(p *Proxy) { p.Pinned = true })
+	fresh := newTestProxy(t, db, "4.4.4.4", 80)
+
+	past := time.Now().Add(-48 * time.Hour)
+	db.Model(&Proxy{}).Where("id IN ?", []uint{old.ID, whitelisted.ID, pinned.ID}).UpdateColumn("created_at", past)
+
+	deleted, err := CleanupOldProxies(db, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupOldProxies: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+
+	var remaining []uint
+	db.Model(&Proxy{}).Order("id").Pluck("id", &remaining)
+	want := []uint{whitelisted.ID, pinned.ID, fresh.ID}
+	if !reflect.DeepEqual(remaining, want) {
+		t.Errorf("remaining = %v, want %v", remaining, want)
+	}
+
+	var removed Proxy
+	if err := db.Unscoped().First(&removed, old.ID).Error; err != nil {
+		t.Fatalf("load deleted proxy: %v", err)
+	}
+	if removed.DeletedByReason != "age" {
+		t.Errorf("deleted_by_reason = %q, want %q", removed.DeletedByReason, "age")
+	}
+}
+
+func TestEstimatedTTL(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name  string
+		proxy *Proxy
+		want  time.Duration
+	}{
+		{"fresh temp", &Proxy{Type: ProxyTypeTemp, LastCheck: now}, tempProxyExpiry},
+		{"half-aged long", &Proxy{Type: ProxyTypeLong, LastCheck: now.Add(-12 * time.Hour)}, 12 * time.Hour},
+		{"expired temp", &Proxy{Type: ProxyTypeTemp, LastCheck: now.Add(-time.Hour)}, 0},
+		{"never checked", &Proxy{Type: ProxyTypeTemp}, 0},
+		{"check in future capped", &Proxy{Type: ProxyTypeTemp, LastCheck: now.Add(time.Hour)}, tempProxyExpiry},
+		{"hard expiry wins", &Proxy{Type: ProxyTypeLong, LastCheck: now, ExpiresAt: at(10 * time.Minute)}, 10 * time.Minute},
+		{"hard expiry passed", &Proxy{Type: ProxyTypeLong, LastCheck: now, ExpiresAt: at(-time.Minute)}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.proxy.EstimatedTTL()
+			if diff := got - tt.want; diff > time.Second || diff < -time.Second {
+				t.Errorf("EstimatedTTL() = %v, want about %v", got, tt.want)
+			}
+		})
+	}
+
+	p := Proxy{Type: ProxyTypeTemp, LastCheck: now}
+	if got, want := p.ExpiryTime(), now.Add(tempProxyExpiry); !got.Equal(want) {
+		t.Errorf("ExpiryTime() = %v, want %v", got, want)
+	}
+	p.ExpiresAt = at(time.Minute)
+	if got := p.ExpiryTime(); !got.Equal(*p.ExpiresAt) {
+		t.Errorf("ExpiryTime() with ExpiresAt = %v, want %v", got, *p.ExpiresAt)
+	}
+}
+
+func TestBatchCreateContinuesAfterFailure(t *testing.T) {
+	db := newTestDB(t)
+	existing := newTestProxy(t, db, "5.5.5.5", 80)
+
+	// 数据库拒绝写入其中两个代理：一个新建失败，一个更新失败
+	for _, stmt := range []string{
+		`CREATE TRIGGER reject_insert BEFORE INSERT ON proxies WHEN NEW.ip = '6.6.6.6' BEGIN SELECT RAISE(ABORT, 'rejected'); END`,
+		`CREATE TRIGGER reject_update BEFORE UPDATE ON proxies WHEN NEW.source = 'rejected' BEGIN SELECT RAISE(ABORT, 'rejected'); END`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create trigger: %v", err)
+		}
+	}
+
+	batch := []*Proxy{
+		{IP: "1.1.1.1", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "test"},
+		{IP: "2.2.2.2", Port: 0, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "test"},
+		{IP: "6.6.6.6", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "test"},
+		{IP: "5.5.5.5", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "rejected"},
+		{IP: "3.3.3.3", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "test"},
+	}
+	result, err := BatchCreateWithDuplicateCheck(db, batch)
+	if err != nil {
+		t.Fatalf("BatchCreateWithDuplicateCheck: %v", err)
+	}
+	if result.Added != 2 || result.Updated != 0 || result.Failed != 2 {
+		t.Errorf("result = %+v, want 2 added, 0 updated, 2 failed", result)
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("errors = %v, want 2", result.Errors)
+	}
+
+	var ips []string
+	db.Model(&Proxy{}).Order("ip").Pluck("ip", &ips)
+	if want := []string{"1.1.1.1", "3.3.3.3", "5.5.5.5"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("stored proxies = %v, want %v", ips, want)
+	}
+
+	// 更新失败的代理的冲突记录随之回滚
+	var conflicts int64
+	db.Model(&ProxyConflict{}).Where("proxy_id = ?", existing.ID).Count(&conflicts)
+	if conflicts != 0 {
+		t.Errorf("conflicts for failed update = %d, want 0", conflicts)
+	}
+}
+
+func TestBatchCreateAllFailedRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Exec(`CREATE TRIGGER reject_insert BEFORE INSERT ON proxies BEGIN SELECT RAISE(ABORT, 'rejected'); END`).Error; err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	batch := []*Proxy{
+		{IP: "1.1.1.1", Port: 80, Type: ProxyTypeTemp, Protocol: "http", Region: ProxyRegionOther, Source: "test"},
+	}
+	result, err := BatchCreateWithDuplicateCheck(db, batch)
+	if err == nil {
+		t.Fatal("BatchCreateWithDuplicateCheck with every write failing returned nil error")
+	}
+	if result.Failed != 1 {
+		t.Errorf("failed = %d, want 1", result.Failed)
+	}
+}
+
+func TestCloneCopiesEveryField(t *testing.T) {
+	now := time.Now()
+	p := &Proxy{}
+	// 为每个导出字段设置非零值，新增字段未加入 Clone 时测试失败
+	v := reflect.ValueOf(p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch field.Interface().(type) {
+		case gorm.Model:
+			field.Set(reflect.ValueOf(gorm.Model{ID: 7, CreatedAt: now, UpdatedAt: now}))
+		case time.Time:
+			field.Set(reflect.ValueOf(now))
+		case *time.Time:
+			at := now
+			field.Set(reflect.ValueOf(&at))
+		default:
+			switch field.Kind() {
+			case reflect.String:
+				field.SetString("x")
+			case reflect.Bool:
+				field.SetBool(true)
+			case reflect.Int, reflect.Int64:
+				field.SetInt(3)
+			case reflect.Uint, reflect.Uint32, reflect.Uint64:
+				field.SetUint(3)
+			case reflect.Float64:
+				field.SetFloat(1.5)
+			default:
+				t.Fatalf("unhandled field %s of kind %s", v.Type().Field(i).Name, field.Kind())
+			}
+		}
+	}
+
+	clone := p.Clone()
+	c := reflect.ValueOf(clone).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).CanSet() {
+			continue
+		}
+		name := v.Type().Field(i).Name
+		if !reflect.DeepEqual(c.Field(i).Interface(), v.Field(i).Interface()) {
+			t.Errorf("Clone().%s = %v, want %v", name, c.Field(i).Interface(), v.Field(i).Interface())
+		}
+	}
+
+	// 时间指针单独复制，修改克隆不影响原代理
+	*clone.ExpiresAt = now.Add(time.Hour)
+	if !p.ExpiresAt.Equal(now) {
+		t.Errorf("modifying the clone's ExpiresAt changed the original to %v", *p.ExpiresAt)
+	}
+}
+
+func TestAutoMaintenanceFetchesBelowMinimum(t *testing.T) {
+	db := newTestDB(t)
+	newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.LastCheck = time.Now() })
+	newTestProxy(t, db, "2.2.2.2", 80, func(p *Proxy) { p.LastCheck = time.Now() })
+
+	fetches := 0
+	fetch := func() error { fetches++; return nil }
+	config := *DefaultMaintenanceConfig
+
+	config.MinProxies = 2
+	if err := AutoMaintenance(db, &config, fetch); err != nil {
+		t.Fatalf("AutoMaintenance: %v", err)
+	}
+	if fetches != 0 {
+		t.Errorf("fetches at the minimum = %d, want 0", fetches)
+	}
+
+	config.MinProxies = 3
+	if err := AutoMaintenance(db, &config, fetch); err != nil {
+		t.Fatalf("AutoMaintenance: %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("fetches below the minimum = %d, want 1", fetches)
+	}
+
+	if err := AutoMaintenance(db, &config, nil); err != nil {
+		t.Errorf("AutoMaintenance without fetch: %v", err)
+	}
+}
+
+func TestGetProxiesNearExpiryLimit(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	// 剩余有效时长：hard 2分钟，temp 4分钟，long 1分钟，other 3分钟，fresh 不在窗口内
+	hard := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) {
+		p.Type = ProxyTypeLong
+		expires := now.Add(2 * time.Minute)
+		p.ExpiresAt = &expires
+	})
+	temp := newTestProxy(t, db, "2.2.2.2", 80)
+	long := newTestProxy(t, db, "3.3.3.3", 80, func(p *Proxy) { p.Type = ProxyTypeLong })
+	other := newTestProxy(t, db, "4.4.4.4", 80, func(p *Proxy) { p.Type = "dynamic" })
+	newTestProxy(t, db, "5.5.5.5", 80)
+	db.Model(&Proxy{}).Where("id = ?", temp.ID).UpdateColumn("last_check", now.Add(-tempProxyExpiry+4*time.Minute))
+	db.Model(&Proxy{}).Where("id = ?", long.ID).UpdateColumn("last_check", now.Add(-longProxyExpiry+time.Minute))
+	db.Model(&Proxy{}).Where("id = ?", other.ID).UpdateColumn("last_check", now.Add(-defaultProxyExpiry+3*time.Minute))
+
+	ids := func(proxies []*Proxy) []uint {
+		var ids []uint
+		for _, p := range proxies {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	all, err := GetProxiesNearExpiry(db, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("GetProxiesNearExpiry: %v", err)
+	}
+	if got, want := ids(all), []uint{long.ID, hard.ID, other.ID, temp.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("near expiry = %v, want %v", got, want)
+	}
+
+	limited, err := GetProxiesNearExpiryLimit(db, 5*time.Minute, 2)
+	if err != nil {
+		t.Fatalf("GetProxiesNearExpiryLimit: %v", err)
+	}
+	if got, want := ids(limited), []uint{long.ID, hard.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("near expiry with limit 2 = %v, want %v", got, want)
+	}
+}
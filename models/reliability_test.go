@@ -0,0 +1,253 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// addUsages 按给定顺序写入使用记录，第一条最旧
+func addUsages(t *testing.T, db *gorm.DB, proxyID uint, results ...bool) {
+	t.Helper()
+	base := time.Now().Add(-time.Hour)
+	for i, success := range results {
+		usage := &ProxyUsage{ProxyID: proxyID, Success: success}
+		usage.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := db.Create(usage).Error; err != nil {
+			t.Fatalf("create usage: %v", err)
+		}
+	}
+}
+
+// countQueries 统计 fn 执行期间的查询次数
+func countQueries(t *testing.T, db *gorm.DB, fn func()) int64 {
+	t.Helper()
+	var count atomic.Int64
+	name := "test:count_" + t.Name()
+	if err := db.Callback().Query().Before("gorm:query").Register(name, func(tx *gorm.DB) {
+		// 子查询以 DryRun 方式构建，不计入
+		if !tx.DryRun {
+			count.Add(1)
+		}
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	defer db.Callback().Query().Remove(name)
+	fn()
+	return count.Load()
+}
+
+func TestRecentUsagesSingleQuery(t *testing.T) {
+	db := newTestDB(t)
+	a := newTestProxy(t, db, "1.1.1.1", 80)
+	b := newTestProxy(t, db, "2.2.2.2", 80)
+	c := newTestProxy(t, db, "3.3.3.3", 80)
+	addUsages(t, db, a.ID, false, false, true, true)
+	addUsages(t, db, b.ID, true)
+	// 已删除的使用记录不计入
+	addUsages(t, db, c.ID, true)
+	db.Where("proxy_id = ?", c.ID).Delete(&ProxyUsage{})
+
+	var recent map[uint][]ProxyUsage
+	queries := countQueries(t, db, func() {
+		var err error
+		if recent, err = recentUsages(db, []uint{a.ID, b.ID, c.ID}, 3); err != nil {
+			t.Fatalf("recentUsages: %v", err)
+		}
+	})
+	if queries != 1 {
+		t.Errorf("recentUsages ran %d queries for 3 proxies, want 1", queries)
+	}
+
+	// 每个代理最多 limit 条，从近到远
+	got := recent[a.ID]
+	if len(got) != 3 || !got[0].Success || !got[1].Success || got[2].Success {
+		t.Errorf("recent usages of a = %+v, want the newest 3 (true, true, false)", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].CreatedAt.After(got[i-1].CreatedAt) {
+			t.Errorf("recent usages of a not ordered newest first: %v before %v", got[i-1].CreatedAt, got[i].CreatedAt)
+		}
+	}
+	if len(recent[b.ID]) != 1 {
+		t.Errorf("recent usages of b = %d, want 1", len(recent[b.ID]))
+	}
+	if _, ok := recent[c.ID]; ok {
+		t.Errorf("recent usages of c = %+v, want none (deleted)", recent[c.ID])
+	}
+
+	if empty, err := recentUsages(db, nil, 3); err != nil || len(empty) != 0 {
+		t.Errorf("recentUsages(nil) = %v, %v, want empty", empty, err)
+	}
+}
+
+func TestScoreFollowsRecentUsage(t *testing.T) {
+	db := newTestDB(t)
+	// 累计成功率相同，最近的表现不同
+	stats := func(p *Proxy) { p.Success = 8; p.Failure = 2; p.Speed = 100; p.Score = 80 }
+	recovering := newTestProxy(t, db, "1.1.1.1", 80, stats)
+	degrading := newTestProxy(t, db, "2.2.2.2", 80, stats)
+	addUsages(t, db, recovering.ID, false, false, true, true, true)
+	addUsages(t, db, degrading.ID, true, true, true, false, false)
+
+	if _, err := OptimizePool(db, DefaultMaintenanceConfig); err != nil {
+		t.Fatalf("OptimizePool: %v", err)
+	}
+	optimized := map[uint]float64{
+		recovering.ID: loadProxy(t, db, recovering.ID).Score,
+		degrading.ID:  loadProxy(t, db, degrading.ID).Score,
+	}
+	if optimized[recovering.ID] <= optimized[degrading.ID] {
+		t.Fatalf("after OptimizePool recovering score %v <= degrading score %v", optimized[recovering.ID], optimized[degrading.ID])
+	}
+
+	// 再次优化结果不变
+	if _, err := OptimizePool(db, DefaultMaintenanceConfig); err != nil {
+		t.Fatalf("OptimizePool: %v", err)
+	}
+	for id, want := range optimized {
+		if got := loadProxy(t, db, id).Score; got != want {
+			t.Errorf("proxy %d score after second OptimizePool = %v, want %v", id, got, want)
+		}
+	}
+
+	// 释放代理时同样按最近的使用记录计算
+	p := loadProxy(t, db, recovering.ID)
+	// 调度时已占用并计数
+	p.ConcurrentUse, p.UseCount = 1, 1
+	db.Model(p).UpdateColumns(map[string]interface{}{"concurrent_use": 1, "use_count": 1})
+	if err := ReleaseScheduledProxy(db, p, true, 100); err != nil {
+		t.Fatalf("ReleaseScheduledProxy: %v", err)
+	}
+	var recent []ProxyUsage
+	db.Where("proxy_id = ?", p.ID).Order("created_at DESC, id DESC").Find(&recent)
+	want := p.Clone()
+	want.UpdateScoreWithUsage(recent)
+	if got := loadProxy(t, db, p.ID); got.Score != want.Score || got.ReliabilityScore != want.ReliabilityScore {
+		t.Errorf("after release score = %v, reliability = %v, want %v, %v",
+			got.Score, got.ReliabilityScore, want.Score, want.ReliabilityScore)
+	}
+}
+
+// useScoreMaxAge 在测试期间设置评分年龄衰减，结束后恢复
+func useScoreMaxAge(t *testing.T, maxAge time.Duration) {
+	t.Helper()
+	previous := ScoreMaxAge()
+	SetScoreMaxAge(maxAge)
+	t.Cleanup(func() { SetScoreMaxAge(previous) })
+}
+
+func TestOptimizePoolAppliesAgeDecayOnce(t *testing.T) {
+	db := newTestDB(t)
+	useScoreMaxAge(t, 7*24*time.Hour)
+	stats := func(p *Proxy) { p.Success = 8; p.Failure = 2; p.Speed = 100; p.Score = 80 }
+	fresh := newTestProxy(t, db, "1.1.1.1", 80, stats)
+	aged := newTestProxy(t, db, "2.2.2.2", 80, stats, func(p *Proxy) { p.CreatedAt = time.Now().Add(-5 * 24 * time.Hour) })
+
+	for i := 0; i < 2; i++ {
+		if _, err := OptimizePool(db, DefaultMaintenanceConfig); err != nil {
+			t.Fatalf("OptimizePool: %v", err)
+		}
+		freshScore, agedScore := loadProxy(t, db, fresh.ID).Score, loadProxy(t, db, aged.ID).Score
+		if agedScore != freshScore/2 {
+			t.Errorf("run %d: aged score = %v, want half of fresh score %v", i+1, agedScore, freshScore)
+		}
+	}
+
+	var histories int64
+	db.Model(&ProxyScoreHistory{}).Where("proxy_id = ?", aged.ID).Count(&histories)
+	if histories != 1 {
+		t.Errorf("aged proxy has %d score history rows, want 1", histories)
+	}
+}
+
+func TestScoreAgeDecayOnRelease(t *testing.T) {
+	db := newTestDB(t)
+	useScoreMaxAge(t, 7*24*time.Hour)
+	stats := func(p *Proxy) { p.Success = 8; p.Failure = 2; p.Speed = 100; p.ConcurrentUse = 1; p.UseCount = 1 }
+	fresh := newTestProxy(t, db, "1.1.1.1", 80, stats)
+	aged := newTestProxy(t, db, "2.2.2.2", 80, stats, func(p *Proxy) { p.CreatedAt = time.Now().Add(-5 * 24 * time.Hour) })
+
+	// 上报使用结果后重新计算的评分同样应用年龄衰减
+	for _, p := range []*Proxy{fresh, aged} {
+		if err := ReleaseScheduledProxy(db, loadProxy(t, db, p.ID), true, 100); err != nil {
+			t.Fatalf("ReleaseScheduledProxy: %v", err)
+		}
+	}
+	freshScore, agedScore := loadProxy(t, db, fresh.ID).Score, loadProxy(t, db, aged.ID).Score
+	if freshScore == 0 || agedScore != freshScore/2 {
+		t.Errorf("aged score after release = %v, want half of fresh score %v", agedScore, freshScore)
+	}
+
+	// 尚未入库的代理没有创建时间，不衰减
+	unsaved := &Proxy{Success: 8, Failure: 2, Speed: 100}
+	unsaved.UpdateScore()
+	if want := unsaved.scoreFor(unsaved.ReliabilityScore); unsaved.Score != want {
+		t.Errorf("unsaved proxy score = %v, want undecayed %v", unsaved.Score, want)
+	}
+}
+
+func TestOptimizePoolResult(t *testing.T) {
+	db := newTestDB(t)
+	good := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.Success = 9; p.Failure = 1; p.Speed = 100; p.Score = 90 })
+	bad := newTestProxy(t, db, "2.2.2.2", 80, func(p *Proxy) { p.Success = 1; p.Failure = 9; p.Score = 10 })
+	unchecked := newTestProxy(t, db, "3.3.3.3", 80, func(p *Proxy) { p.Score = 10 })
+	whitelisted := newTestProxy(t, db, "4.4.4.4", 80, func(p *Proxy) { p.Success = 1; p.Failure = 9; p.Score = 10; p.Whitelisted = true })
+
+	result, err := OptimizePool(db, nil)
+	if err != nil {
+		t.Fatalf("OptimizePool: %v", err)
+	}
+	if result.Deleted != 1 || result.Promoted != 1 || result.Rescored == 0 {
+		t.Errorf("result = %+v, want 1 deleted, 1 promoted and some rescored", result)
+	}
+
+	var remaining []uint
+	db.Model(&Proxy{}).Order("id").Pluck("id", &remaining)
+	if want := []uint{good.ID, unchecked.ID, whitelisted.ID}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("remaining proxies = %v, want %v (bad proxy %d deleted)", remaining, want, bad.ID)
+	}
+	if got := loadProxy(t, db, good.ID); got.MaxConcurrent != DefaultMaintenanceConfig.HighScoreMaxConcurrent {
+		t.Errorf("good proxy max_concurrent = %d, want %d", got.MaxConcurrent, DefaultMaintenanceConfig.HighScoreMaxConcurrent)
+	}
+
+	// 评分没有变化时不再写回
+	result, err = OptimizePool(db, nil)
+	if err != nil {
+		t.Fatalf("second OptimizePool: %v", err)
+	}
+	if result.Deleted != 0 || result.Rescored != 0 || result.Promoted != 0 {
+		t.Errorf("second result = %+v, want nothing changed", result)
+	}
+}
+
+func TestOptimizePoolRescoresEveryBatch(t *testing.T) {
+	db := newTestDB(t)
+	proxies := make([]*Proxy, optimizeBatchSize+1)
+	for i := range proxies {
+		proxies[i] = &Proxy{
+			IP: fmt.Sprintf("20.0.%d.%d", i/250, i%250+1), Port: 80, Type: ProxyTypeTemp, Protocol: "http",
+			Region: ProxyRegionOther, Source: "test", Available: true, Success: 9, Failure: 1, Score: 50,
+		}
+	}
+	if err := db.CreateInBatches(proxies, 100).Error; err != nil {
+		t.Fatalf("create proxies: %v", err)
+	}
+
+	result, err := OptimizePool(db, nil)
+	if err != nil {
+		t.Fatalf("OptimizePool: %v", err)
+	}
+	if result.Rescored != int64(len(proxies)) {
+		t.Errorf("rescored = %d, want all %d proxies across batches", result.Rescored, len(proxies))
+	}
+	var stale int64
+	db.Model(&Proxy{}).Where("score = ?", 50).Count(&stale)
+	if stale != 0 {
+		t.Errorf("%d proxies kept their old score", stale)
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProxyReputation 客户端上报的代理在某个域名上的封禁情况
+type ProxyReputation struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	ProxyID     uint      `gorm:"not null;index:idx_reputation_proxy_domain,priority:1" json:"proxy_id"`
+	Domain      string    `gorm:"type:varchar(255);not null;index:idx_reputation_proxy_domain,priority:2;index:idx_reputation_domain" json:"domain"`
+	BanReported bool      `gorm:"not null" json:"ban_reported"` // true 表示被封禁，false 表示已解封
+	ReportedAt  time.Time `gorm:"not null;index" json:"reported_at"`
+	ReportedBy  string    `gorm:"type:varchar(64)" json:"reported_by"`
+}
+
+// NormalizeDomain 规范化域名，去除空白并转为小写
+func NormalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+// ReportReputation 记录一次上报
+func ReportReputation(db *gorm.DB, report *ProxyReputation) error {
+	report.Domain = NormalizeDomain(report.Domain)
+	if report.ReportedAt.IsZero() {
+		report.ReportedAt = time.Now()
+	}
+	return db.Create(report).Error
+}
+
+// ListReputation 获取代理的上报记录，按上报时间倒序
+func ListReputation(db *gorm.DB, proxyID uint) ([]ProxyReputation, error) {
+	var reports []ProxyReputation
+	err := db.Where("proxy_id = ?", proxyID).
+		Order("reported_at DESC, id DESC").
+		Find(&reports).Error
+	return reports, err
+}
+
+// BannedProxyIDs 获取在域名上被封禁的代理ID
+// since 之后有封禁上报、且之后没有解封上报的代理视为被封禁
+func BannedProxyIDs(db *gorm.DB, domain string, since time.Time) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&ProxyReputation{}).
+		Where("domain = ? AND ban_reported = ? AND reported_at >= ?", NormalizeDomain(domain), true, since).
+		Where(`NOT EXISTS (SELECT 1 FROM proxy_reputations r2 WHERE r2.proxy_id = proxy_reputations.proxy_id
+			AND r2.domain = proxy_reputations.domain AND r2.ban_reported = ? AND r2.reported_at > proxy_reputations.reported_at)`, false).
+		Distinct("proxy_id").
+		Pluck("proxy_id", &ids).Error
+	return ids, err
+}
+
+// reputationCleanupBatchSize 清理上报记录时每批删除的数量
+const reputationCleanupBatchSize = 500
+
+// CleanupReputation 清除 decay 时间内没有新封禁上报的代理和域名的全部上报记录
+// 每批先查出 reputationCleanupBatchSize 条记录的ID再按ID删除，避免一次加载全部ID和长时间锁表
+func CleanupReputation(db *gorm.DB, decay time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-decay)
+
+	var total int64
+	for {
+		// MySQL 不允许在 DELETE 的子查询中引用同一张表，先查出待删除的ID
+		var ids []uint
+		err := db.Model(&ProxyReputation{}).
+			Where(`NOT EXISTS (SELECT 1 FROM proxy_reputations r2 WHERE r2.proxy_id = proxy_reputations.proxy_id
+			AND r2.domain = proxy_reputations.domain AND r2.ban_reported = ? AND r2.reported_at >= ?)`, true, cutoff).
+			Limit(reputationCleanupBatchSize).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return total, err
+		}
+
+		result := db.Where("id IN ?", ids).Delete(&ProxyReputation{})
+		total += result.RowsAffected
+		if result.Error != nil || len(ids) < reputationCleanupBatchSize {
+			return total, result.Error
+		}
+	}
+}
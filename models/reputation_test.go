@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// addReputation 写入一条 ago 之前的上报记录
+func addReputation(t *testing.T, db *gorm.DB, proxyID uint, domain string, banned bool, ago time.Duration) {
+	t.Helper()
+	report := &ProxyReputation{ProxyID: proxyID, Domain: domain, BanReported: banned, ReportedAt: time.Now().Add(-ago)}
+	if err := ReportReputation(db, report); err != nil {
+		t.Fatalf("ReportReputation: %v", err)
+	}
+}
+
+func TestBannedProxyIDs(t *testing.T) {
+	db := newTestDB(t)
+	// 1 被封禁；2 封禁后解封；3 解封后再次封禁；4 封禁已超过 since；5 在其他域名上被封禁
+	addReputation(t, db, 1, "example.com", true, time.Minute)
+	addReputation(t, db, 2, "example.com", true, 3*time.Minute)
+	addReputation(t, db, 2, "example.com", false, 2*time.Minute)
+	addReputation(t, db, 3, "example.com", false, 3*time.Minute)
+	addReputation(t, db, 3, "Example.com ", true, time.Minute)
+	addReputation(t, db, 4, "example.com", true, 2*time.Hour)
+	addReputation(t, db, 5, "other.com", true, time.Minute)
+
+	ids, err := BannedProxyIDs(db, " EXAMPLE.com", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("BannedProxyIDs: %v", err)
+	}
+	if !equalIDs(ids, []uint{1, 3}) {
+		t.Errorf("banned = %v, want [1 3]", ids)
+	}
+}
+
+func TestCleanupReputation(t *testing.T) {
+	db := newTestDB(t)
+	// 1 近期被封禁，保留全部记录；2 封禁已衰减，连同解封记录一起删除；3 只有近期的解封记录，删除
+	addReputation(t, db, 1, "example.com", false, 48*time.Hour)
+	addReputation(t, db, 1, "example.com", true, time.Hour)
+	addReputation(t, db, 2, "example.com", true, 48*time.Hour)
+	addReputation(t, db, 2, "example.com", false, 47*time.Hour)
+	addReputation(t, db, 3, "example.com", false, time.Hour)
+	// 同一代理在另一个域名上的封禁已衰减，不受 example.com 上近期封禁的影响
+	addReputation(t, db, 1, "other.com", true, 48*time.Hour)
+
+	deleted, err := CleanupReputation(db, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupReputation: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("deleted = %d, want 4", deleted)
+	}
+	var kept []ProxyReputation
+	db.Order("id").Find(&kept)
+	if len(kept) != 2 || kept[0].ProxyID != 1 || kept[1].ProxyID != 1 || kept[0].Domain != "example.com" || kept[1].Domain != "example.com" {
+		t.Errorf("kept = %+v, want both example.com reports of proxy 1", kept)
+	}
+}
+
+func TestCleanupReputationInBatches(t *testing.T) {
+	db := newTestDB(t)
+	stale := make([]ProxyReputation, reputationCleanupBatchSize*2+10)
+	for i := range stale {
+		stale[i] = ProxyReputation{ProxyID: uint(i + 1), Domain: "example.com", BanReported: true, ReportedAt: time.Now().Add(-48 * time.Hour)}
+	}
+	if err := db.CreateInBatches(stale, 100).Error; err != nil {
+		t.Fatalf("create reports: %v", err)
+	}
+
+	deleted, err := CleanupReputation(db, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupReputation: %v", err)
+	}
+	var remaining int64
+	db.Model(&ProxyReputation{}).Count(&remaining)
+	if deleted != int64(len(stale)) || remaining != 0 {
+		t.Errorf("deleted = %d, remaining = %d, want %d deleted and none remaining", deleted, remaining, len(stale))
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// RetentionMode 自动清理的保留模式，验证器达到最大失败次数和清理任务都按该模式处理
+type RetentionMode string
+
+const (
+	RetentionDelete     RetentionMode = "delete"     // 按代理源的清理策略删除或隔离，默认模式
+	RetentionQuarantine RetentionMode = "quarantine" // 所有代理源都以隔离代替删除
+	RetentionObserve    RetentionMode = "observe"    // 不删除也不隔离，只标记为不可用并记录本应执行的操作
+)
+
+// IsValid 是否为已知的保留模式
+func (m RetentionMode) IsValid() bool {
+	return m == RetentionDelete || m == RetentionQuarantine || m == RetentionObserve
+}
+
+// 清理操作，观察模式下记录本应执行的操作
+const (
+	CleanupActionDelete     = "delete"     // 删除代理
+	CleanupActionQuarantine = "quarantine" // 隔离代理
+)
+
+// RetainedProxy 观察模式下本应被清理、只标记为不可用的代理
+type RetainedProxy struct {
+	ProxyID uint
+	Type    ProxyType
+	Score   float64
+	Action  string // 本应执行的清理操作
+}
+
+var (
+	retentionMu       sync.RWMutex
+	retentionMode     = RetentionDelete
+	retentionObserver func([]RetainedProxy)
+)
+
+// SetRetentionMode 设置保留模式，未知模式按 RetentionDelete 处理
+func SetRetentionMode(mode RetentionMode) {
+	if !mode.IsValid() {
+		mode = RetentionDelete
+	}
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	retentionMode = mode
+}
+
+// GetRetentionMode 获取当前保留模式
+func GetRetentionMode() RetentionMode {
+	retentionMu.RLock()
+	defer retentionMu.RUnlock()
+	return retentionMode
+}
+
+// SetRetentionObserver 设置观察模式下接收本应清理的代理的回调，如发布事件，为空时不通知
+func SetRetentionObserver(observer func([]RetainedProxy)) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	retentionObserver = observer
+}
+
+// NotifyRetained 通知观察模式下本应清理的代理
+func NotifyRetained(retained ...RetainedProxy) {
+	if len(retained) == 0 {
+		return
+	}
+	retentionMu.RLock()
+	observer := retentionObserver
+	retentionMu.RUnlock()
+	if observer != nil {
+		observer(retained)
+	}
+}
+
+// observeCleanup 观察模式下代替清理：将 query 选中的代理标记为不可用并通知本应执行的操作，返回涉及的代理数
+// 代理不会被删除，deleted_by_reason 记录本应清理的原因；已记录原因的代理不再重复标记和通知，
+// 避免验证恢复可用的代理每轮清理都被重新标记为不可用
+func observeCleanup(db, query *gorm.DB, action, reason string) (int64, error) {
+	var proxies []*Proxy
+	err := query.Where("deleted_by_reason = '' OR deleted_by_reason IS NULL").
+		Select("id, type, score").Find(&proxies).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(proxies) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, len(proxies))
+	retained := make([]RetainedProxy, len(proxies))
+	for i, p := range proxies {
+		ids[i] = p.ID
+		retained[i] = RetainedProxy{ProxyID: p.ID, Type: p.Type, Score: p.Score, Action: action}
+	}
+	err = db.Model(&Proxy{}).Where("id IN ?", ids).
+		UpdateColumns(map[string]interface{}{"available": false, "deleted_by_reason": reason}).Error
+	if err != nil {
+		return 0, err
+	}
+	NotifyRetained(retained...)
+	return int64(len(proxies)), nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// useRetentionMode 在测试期间切换保留模式并记录观察模式的通知
+func useRetentionMode(t *testing.T, mode RetentionMode) *[]RetainedProxy {
+	t.Helper()
+	var retained []RetainedProxy
+	SetRetentionMode(mode)
+	SetRetentionObserver(func(r []RetainedProxy) { retained = append(retained, r...) })
+	t.Cleanup(func() {
+		SetRetentionMode(RetentionDelete)
+		SetRetentionObserver(nil)
+	})
+	return &retained
+}
+
+func TestObserveCleanupOnlyOnce(t *testing.T) {
+	db := newTestDB(t)
+	retained := useRetentionMode(t, RetentionObserve)
+	old := newTestProxy(t, db, "1.1.1.1", 80)
+	db.Model(&Proxy{}).Where("id = ?", old.ID).UpdateColumn("created_at", time.Now().Add(-48*time.Hour))
+
+	if _, err := CleanupOldProxies(db, 24*time.Hour); err != nil {
+		t.Fatalf("CleanupOldProxies: %v", err)
+	}
+	got := loadProxy(t, db, old.ID)
+	if got.Available || got.DeletedByReason != "age" {
+		t.Fatalf("after observe cleanup available = %v, deleted_by_reason = %q, want false, %q", got.Available, got.DeletedByReason, "age")
+	}
+	if len(*retained) != 1 || (*retained)[0].ProxyID != old.ID || (*retained)[0].Action != CleanupActionDelete {
+		t.Fatalf("retained = %+v, want one delete for proxy %d", *retained, old.ID)
+	}
+
+	// 验证通过后恢复可用，之后的清理不再标记和通知
+	db.Model(&Proxy{}).Where("id = ?", old.ID).UpdateColumn("available", true)
+	if _, err := CleanupOldProxies(db, 24*time.Hour); err != nil {
+		t.Fatalf("second CleanupOldProxies: %v", err)
+	}
+	if got := loadProxy(t, db, old.ID); !got.Available {
+		t.Error("second observe cleanup marked the recovered proxy unavailable again")
+	}
+	if len(*retained) != 1 {
+		t.Errorf("retained after second cleanup = %d, want 1", len(*retained))
+	}
+
+	// 切回删除模式后已标记的代理照常清理
+	SetRetentionMode(RetentionDelete)
+	if deleted, err := CleanupOldProxies(db, 24*time.Hour); err != nil || deleted != 1 {
+		t.Errorf("CleanupOldProxies in delete mode = %d, %v, want 1", deleted, err)
+	}
+}
+
+func TestCleanupInvalidObserveRecordsReason(t *testing.T) {
+	db := newTestDB(t)
+	retained := useRetentionMode(t, RetentionObserve)
+	bad := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.Success = 1; p.Failure = 9 })
+
+	for i := 0; i < 2; i++ {
+		result, err := CleanupInvalid(db)
+		if err != nil {
+			t.Fatalf("CleanupInvalid: %v", err)
+		}
+		if want := int64(1 - i); result.Observed != want {
+			t.Errorf("run %d observed = %d, want %d", i, result.Observed, want)
+		}
+	}
+	if got := loadProxy(t, db, bad.ID); got.DeletedByReason != "invalid" {
+		t.Errorf("deleted_by_reason = %q, want %q", got.DeletedByReason, "invalid")
+	}
+	if len(*retained) != 1 {
+		t.Errorf("retained = %d, want 1", len(*retained))
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+// MaxSchedulerFailures 调度器默认的失败阈值，连续失败达到该值后开始冷却
+const MaxSchedulerFailures = 3
+
+// ReuseRequirements 调度时代理需要满足的条件，零值字段不参与判断
+type ReuseRequirements struct {
+	Type           ProxyType   // 代理类型
+	Region         ProxyRegion // 代理地区
+	Protocol       string      // 协议类型
+	Source         string      // 代理来源
+	MinScore       float64     // 最低评分
+	MinSuccessRate float64     // 最低成功率(百分比)
+	MaxSpeed       int64       // 响应时间上限(毫秒)
+	RequireAnon    bool        // 是否要求匿名
+	ExcludeIDs     []uint      // 排除的代理ID
+}
+
+// ProxySchedulerStats 调度器内存中代理的状态
+// 连续失败次数只决定冷却时长，达到失败阈值的代理由冷却排除，冷却结束后可再次分配
+type ProxySchedulerStats struct {
+	InCooldown bool // 是否处于冷却期
+	InUse      int  // 当前并发使用数
+}
+
+// IsReusable 代理是否可以分配给满足 req 的任务，任一条件不满足即返回 false
+func (p *Proxy) IsReusable(req *ReuseRequirements, stats ProxySchedulerStats) bool {
+	if !p.Available {
+		return false
+	}
+	for _, id := range req.ExcludeIDs {
+		if id == p.ID {
+			return false
+		}
+	}
+
+	// 检查类型、地区、协议、来源和匿名性
+	if req.Type != "" && p.Type != req.Type {
+		return false
+	}
+	if req.Region != "" && p.Region != req.Region {
+		return false
+	}
+	if req.Protocol != "" && p.Protocol != req.Protocol {
+		return false
+	}
+	if req.Source != "" && p.Source != req.Source {
+		return false
+	}
+	if req.RequireAnon && !p.Anonymous {
+		return false
+	}
+
+	// 检查评分、成功率和响应时间
+	if req.MinScore > 0 && p.Score < req.MinScore {
+		return false
+	}
+	if req.MinSuccessRate > 0 && p.GetSuccessRate() < req.MinSuccessRate {
+		return false
+	}
+	if req.MaxSpeed > 0 && p.Speed > req.MaxSpeed {
+		return false
+	}
+
+	// 检查调度器状态和并发数
+	if stats.InCooldown {
+		return false
+	}
+	return stats.InUse < p.MaxConcurrent
+}
@@ -0,0 +1,49 @@
+package models
+
+import "testing"
+
+func TestIsReusable(t *testing.T) {
+	base := func() *Proxy {
+		p := &Proxy{
+			Type: ProxyTypeTemp, Region: ProxyRegionCN, Protocol: "http", Source: "test",
+			Available: true, Anonymous: true, Speed: 500, Success: 9, Failure: 1, Score: 80, MaxConcurrent: 2,
+		}
+		p.ID = 7
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Proxy)
+		req    ReuseRequirements
+		stats  ProxySchedulerStats
+		want   bool
+	}{
+		{name: "no requirements", want: true},
+		{name: "all requirements met", req: ReuseRequirements{
+			Type: ProxyTypeTemp, Region: ProxyRegionCN, Protocol: "http", Source: "test",
+			MinScore: 80, MinSuccessRate: 90, MaxSpeed: 500, RequireAnon: true, ExcludeIDs: []uint{1, 2},
+		}, stats: ProxySchedulerStats{InUse: 1}, want: true},
+		{name: "unavailable", modify: func(p *Proxy) { p.Available = false }},
+		{name: "excluded", req: ReuseRequirements{ExcludeIDs: []uint{7}}},
+		{name: "type", req: ReuseRequirements{Type: ProxyTypeLong}},
+		{name: "region", req: ReuseRequirements{Region: ProxyRegionOther}},
+		{name: "protocol", req: ReuseRequirements{Protocol: "socks5"}},
+		{name: "source", req: ReuseRequirements{Source: "paid"}},
+		{name: "not anonymous", modify: func(p *Proxy) { p.Anonymous = false }, req: ReuseRequirements{RequireAnon: true}},
+		{name: "score", req: ReuseRequirements{MinScore: 81}},
+		{name: "success rate", req: ReuseRequirements{MinSuccessRate: 91}},
+		{name: "speed", req: ReuseRequirements{MaxSpeed: 499}},
+		{name: "cooldown", stats: ProxySchedulerStats{InCooldown: true}},
+		{name: "saturated", stats: ProxySchedulerStats{InUse: 2}},
+	}
+	for _, tt := range tests {
+		p := base()
+		if tt.modify != nil {
+			tt.modify(p)
+		}
+		if got := p.IsReusable(&tt.req, tt.stats); got != tt.want {
+			t.Errorf("%s: IsReusable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// loadProxy 从数据库重新读取代理
+func loadProxy(t *testing.T, db *gorm.DB, id uint) *Proxy {
+	t.Helper()
+	var p Proxy
+	if err := db.First(&p, id).Error; err != nil {
+		t.Fatalf("load proxy %d: %v", id, err)
+	}
+	return &p
+}
+
+func TestScheduleProxySkipsSaturated(t *testing.T) {
+	db := newTestDB(t)
+	top := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.Score = 90; p.MaxConcurrent = 1 })
+	second := newTestProxy(t, db, "2.2.2.2", 80, func(p *Proxy) { p.Score = 80; p.MaxConcurrent = 1 })
+
+	got, err := ScheduleProxy(db, &ScheduleOptions{})
+	if err != nil {
+		t.Fatalf("first ScheduleProxy: %v", err)
+	}
+	if got.ID != top.ID {
+		t.Fatalf("first ScheduleProxy = %d, want top proxy %d", got.ID, top.ID)
+	}
+
+	got, err = ScheduleProxy(db, &ScheduleOptions{})
+	if err != nil {
+		t.Fatalf("second ScheduleProxy: %v", err)
+	}
+	if got.ID != second.ID {
+		t.Fatalf("ScheduleProxy with saturated top = %d, want %d", got.ID, second.ID)
+	}
+
+	if _, err := ScheduleProxy(db, &ScheduleOptions{}); !errors.Is(err, ErrNoAvailableProxy) {
+		t.Errorf("ScheduleProxy with all saturated error = %v, want %v", err, ErrNoAvailableProxy)
+	}
+	for _, id := range []uint{top.ID, second.ID} {
+		if p := loadProxy(t, db, id); p.ConcurrentUse != 1 || p.UseCount != 1 {
+			t.Errorf("proxy %d concurrent_use = %d, use_count = %d, want 1, 1", id, p.ConcurrentUse, p.UseCount)
+		}
+	}
+}
+
+func TestAcquireConcurrentUseRespectsDatabase(t *testing.T) {
+	db := newTestDB(t)
+	p := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.MaxConcurrent = 1 })
+
+	// 另一个实例在查询之后占满了代理
+	stale := loadProxy(t, db, p.ID)
+	db.Model(&Proxy{}).Where("id = ?", p.ID).UpdateColumn("concurrent_use", 1)
+
+	acquired, err := acquireConcurrentUse(db, stale)
+	if err != nil {
+		t.Fatalf("acquireConcurrentUse: %v", err)
+	}
+	if acquired {
+		t.Error("acquired a proxy already saturated in the database")
+	}
+	if got := loadProxy(t, db, p.ID).ConcurrentUse; got != 1 {
+		t.Errorf("concurrent_use = %d, want 1", got)
+	}
+}
+
+func TestReleaseScheduledProxyDecrements(t *testing.T) {
+	db := newTestDB(t)
+	p := newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.MaxConcurrent = 5 })
+
+	first, err := ScheduleProxy(db, &ScheduleOptions{})
+	if err != nil {
+		t.Fatalf("ScheduleProxy: %v", err)
+	}
+	if _, err := ScheduleProxy(db, &ScheduleOptions{}); err != nil {
+		t.Fatalf("ScheduleProxy: %v", err)
+	}
+
+	// 释放较早查询到的代理不会把另一次占用覆盖掉
+	if err := ReleaseScheduledProxy(db, first, true, 100); err != nil {
+		t.Fatalf("ReleaseScheduledProxy: %v", err)
+	}
+	if got := loadProxy(t, db, p.ID).ConcurrentUse; got != 1 {
+		t.Errorf("concurrent_use after one release = %d, want 1", got)
+	}
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ScoreHistoryThreshold = 5.0 // 评分变化超过该值时记录历史
+	MaxScoreHistory       = 200 // 每个代理最多保留的历史记录数
+)
+
+// ProxyScoreHistory 代理评分历史
+type ProxyScoreHistory struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ProxyID    uint      `gorm:"not null;index:idx_proxy_recorded,priority:1" json:"proxy_id"`
+	Score      float64   `json:"score"`
+	RecordedAt time.Time `gorm:"not null;index:idx_proxy_recorded,priority:2" json:"recorded_at"`
+}
+
+// RecordScoreChange 评分变化超过阈值时写入历史，并删除超出上限的最旧记录
+func RecordScoreChange(db *gorm.DB, proxyID uint, oldScore, newScore float64) error {
+	if math.Abs(newScore-oldScore) <= ScoreHistoryThreshold {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		history := &ProxyScoreHistory{
+			ProxyID:    proxyID,
+			Score:      newScore,
+			RecordedAt: time.Now(),
+		}
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+		return trimScoreHistory(tx, proxyID)
+	})
+}
+
+// ScoreChange 一个代理的评分变化
+type ScoreChange struct {
+	ProxyID  uint
+	OldScore float64
+	NewScore float64
+}
+
+// RecordScoreChanges 批量写入超过阈值的评分变化，并删除这些代理超出上限的最旧记录
+func RecordScoreChanges(db *gorm.DB, changes []ScoreChange) error {
+	now := time.Now()
+	var histories []*ProxyScoreHistory
+	for _, c := range changes {
+		if math.Abs(c.NewScore-c.OldScore) <= ScoreHistoryThreshold {
+			continue
+		}
+		histories = append(histories, &ProxyScoreHistory{
+			ProxyID:    c.ProxyID,
+			Score:      c.NewScore,
+			RecordedAt: now,
+		})
+	}
+	if len(histories) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(histories, len(histories)).Error; err != nil {
+			return err
+		}
+		for _, h := range histories {
+			if err := trimScoreHistory(tx, h.ProxyID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// trimScoreHistory 只保留最新的 MaxScoreHistory 条记录
+// ID 随插入递增，找到第 MaxScoreHistory 新的记录后删除比它更早的记录
+func trimScoreHistory(tx *gorm.DB, proxyID uint) error {
+	var cutoff []uint
+	err := tx.Model(&ProxyScoreHistory{}).
+		Where("proxy_id = ?", proxyID).
+		Order("id DESC").
+		Offset(MaxScoreHistory-1).
+		Limit(1).
+		Pluck("id", &cutoff).Error
+	if err != nil || len(cutoff) == 0 {
+		return err
+	}
+
+	return tx.Where("proxy_id = ? AND id < ?", proxyID, cutoff[0]).
+		Delete(&ProxyScoreHistory{}).Error
+}
+
+// ListScoreHistory 获取代理最近的评分历史，按记录时间升序返回
+func ListScoreHistory(db *gorm.DB, proxyID uint, limit int) ([]ProxyScoreHistory, error) {
+	var history []ProxyScoreHistory
+	err := db.Where("proxy_id = ?", proxyID).
+		Order("recorded_at DESC, id DESC").
+		Limit(limit).
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 查询时取最新的记录，返回前反转为时间升序
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+// ListScoreHistories 批量获取多个代理各自最近 limit 条评分历史，每个代理按记录时间升序
+// 一次查询完成，用窗口函数为每个代理的历史编号（需要 MySQL 8.0 或 SQLite 3.25 以上）
+func ListScoreHistories(db *gorm.DB, proxyIDs []uint, limit int) (map[uint][]ProxyScoreHistory, error) {
+	result := make(map[uint][]ProxyScoreHistory, len(proxyIDs))
+	if len(proxyIDs) == 0 {
+		return result, nil
+	}
+
+	ranked := db.Model(&ProxyScoreHistory{}).
+		Select("id, proxy_id, score, recorded_at, ROW_NUMBER() OVER (PARTITION BY proxy_id ORDER BY recorded_at DESC, id DESC) AS rn").
+		Where("proxy_id IN ?", proxyIDs)
+	var history []ProxyScoreHistory
+	err := db.Table("(?) AS ranked", ranked).
+		Select("id, proxy_id, score, recorded_at").
+		Where("rn <= ?", limit).
+		Order("proxy_id, recorded_at ASC, id ASC").
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range history {
+		result[h.ProxyID] = append(result[h.ProxyID], h)
+	}
+	return result, nil
+}
+
+// EachScoreHistory 按时间升序逐条遍历代理的全部评分历史，用于流式导出
+func EachScoreHistory(db *gorm.DB, proxyID uint, fn func(*ProxyScoreHistory) error) error {
+	rows, err := db.Model(&ProxyScoreHistory{}).
+		Where("proxy_id = ?", proxyID).
+		Order("recorded_at ASC, id ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var history ProxyScoreHistory
+		if err := db.ScanRows(rows, &history); err != nil {
+			return err
+		}
+		if err := fn(&history); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
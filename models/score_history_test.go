@@ -0,0 +1,95 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListScoreHistoriesSingleQuery(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Now().Add(-time.Hour)
+	add := func(proxyID uint, scores ...float64) {
+		for i, score := range scores {
+			h := &ProxyScoreHistory{ProxyID: proxyID, Score: score, RecordedAt: base.Add(time.Duration(i) * time.Minute)}
+			if err := db.Create(h).Error; err != nil {
+				t.Fatalf("create history: %v", err)
+			}
+		}
+	}
+	add(1, 10, 20, 30, 40)
+	add(2, 50)
+
+	var histories map[uint][]ProxyScoreHistory
+	var err error
+	if n := countQueries(t, db, func() {
+		histories, err = ListScoreHistories(db, []uint{1, 2, 3}, 3)
+	}); n != 1 {
+		t.Errorf("ListScoreHistories ran %d queries, want 1", n)
+	}
+	if err != nil {
+		t.Fatalf("ListScoreHistories: %v", err)
+	}
+
+	// 每个代理取最新的 limit 条，按时间升序，与 ListScoreHistory 一致
+	single, err := ListScoreHistory(db, 1, 3)
+	if err != nil {
+		t.Fatalf("ListScoreHistory: %v", err)
+	}
+	got := histories[1]
+	if len(got) != 3 || len(single) != 3 {
+		t.Fatalf("proxy 1 history = %d records, single = %d, want 3", len(got), len(single))
+	}
+	for i := range got {
+		if got[i].Score != single[i].Score || got[i].Score != float64(20+10*i) {
+			t.Errorf("proxy 1 history[%d] = %v, single = %v, want %v", i, got[i].Score, single[i].Score, 20+10*i)
+		}
+	}
+	if len(histories[2]) != 1 || len(histories[3]) != 0 {
+		t.Errorf("proxy 2 history = %d, proxy 3 history = %d, want 1 and 0", len(histories[2]), len(histories[3]))
+	}
+}
+
+func TestRecordScoreChangeThresholdAndCap(t *testing.T) {
+	db := newTestDB(t)
+
+	// 变化不超过阈值时不记录
+	if err := RecordScoreChange(db, 1, 50, 50+ScoreHistoryThreshold); err != nil {
+		t.Fatalf("RecordScoreChange: %v", err)
+	}
+	if history, _ := ListScoreHistory(db, 1, MaxScoreHistory); len(history) != 0 {
+		t.Fatalf("history after small change = %d records, want 0", len(history))
+	}
+
+	for i := 0; i < MaxScoreHistory+5; i++ {
+		if err := RecordScoreChange(db, 1, 0, float64(10+i)); err != nil {
+			t.Fatalf("RecordScoreChange: %v", err)
+		}
+	}
+	if err := RecordScoreChanges(db, []ScoreChange{
+		{ProxyID: 2, OldScore: 80, NewScore: 60},
+		{ProxyID: 3, OldScore: 80, NewScore: 78},
+	}); err != nil {
+		t.Fatalf("RecordScoreChanges: %v", err)
+	}
+
+	// 超出上限时删除最旧的记录
+	history, err := ListScoreHistory(db, 1, MaxScoreHistory*2)
+	if err != nil {
+		t.Fatalf("ListScoreHistory: %v", err)
+	}
+	if len(history) != MaxScoreHistory {
+		t.Fatalf("proxy 1 history = %d records, want %d", len(history), MaxScoreHistory)
+	}
+	if history[0].Score != 15 || history[len(history)-1].Score != float64(10+MaxScoreHistory+4) {
+		t.Errorf("proxy 1 history = %v..%v, want the newest records", history[0].Score, history[len(history)-1].Score)
+	}
+	var counts []int64
+	for _, id := range []uint{2, 3} {
+		var n int64
+		db.Model(&ProxyScoreHistory{}).Where("proxy_id = ?", id).Count(&n)
+		counts = append(counts, n)
+	}
+	if counts[0] != 1 || counts[1] != 0 {
+		t.Errorf("batch history counts = %v, want [1 0]", counts)
+	}
+}
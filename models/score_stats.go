@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultScoreBucketSize = 10.0 // 评分分布默认的分桶宽度
+	MinScoreBucketSize     = 1.0  // 评分分布最小的分桶宽度，最多100个区间
+)
+
+// ScoreBucket 评分分布中的一个区间 [Min, Max)，最后一个区间包含100分
+type ScoreBucket struct {
+	Min         float64          `json:"min"`
+	Max         float64          `json:"max"`
+	Available   int64            `json:"available"`   // 区间内的可用代理数
+	Unavailable int64            `json:"unavailable"` // 区间内的不可用代理数
+	BySource    map[string]int64 `json:"by_source"`   // 区间内各来源的代理数
+}
+
+// ScoreDistribution 代理评分分布
+type ScoreDistribution struct {
+	BucketSize float64       `json:"bucket_size"`
+	Total      int64         `json:"total"`
+	Median     float64       `json:"median"`
+	StdDev     float64       `json:"std_dev"` // 总体标准差
+	Buckets    []ScoreBucket `json:"buckets"`
+}
+
+// GetScoreDistribution 按 bucketSize 分桶统计所有代理的当前评分
+// 分桶计数使用一条 GROUP BY FLOOR(score/bucketSize) 查询
+func GetScoreDistribution(db *gorm.DB, bucketSize float64) (*ScoreDistribution, error) {
+	if bucketSize < MinScoreBucketSize || bucketSize > 100 {
+		return nil, fmt.Errorf("%w: bucket size %v out of range [%v, 100]", ErrInvalidFilter, bucketSize, MinScoreBucketSize)
+	}
+
+	n := int(math.Ceil(100 / bucketSize))
+	dist := &ScoreDistribution{BucketSize: bucketSize, Buckets: make([]ScoreBucket, n)}
+	for i := range dist.Buckets {
+		dist.Buckets[i] = ScoreBucket{
+			Min:      float64(i) * bucketSize,
+			Max:      math.Min(float64(i+1)*bucketSize, 100),
+			BySource: map[string]int64{},
+		}
+	}
+
+	var rows []struct {
+		Bucket    int
+		Available bool
+		Source    string
+		Count     int64
+	}
+	// SQLite 默认未编译数学函数，评分非负，取整即向下取整
+	bucketExpr := "FLOOR(score / ?)"
+	if db.Dialector.Name() == "sqlite" {
+		bucketExpr = "CAST(score / ? AS INTEGER)"
+	}
+	err := db.Model(&Proxy{}).
+		Select(bucketExpr+" AS bucket, available, source, COUNT(*) AS count", bucketSize).
+		Group("bucket, available, source").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		// 满分及越界的评分归入两端的区间
+		i := row.Bucket
+		if i >= n {
+			i = n - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		bucket := &dist.Buckets[i]
+		if row.Available {
+			bucket.Available += row.Count
+		} else {
+			bucket.Unavailable += row.Count
+		}
+		bucket.BySource[row.Source] += row.Count
+		dist.Total += row.Count
+	}
+	if dist.Total == 0 {
+		return dist, nil
+	}
+
+	var moments struct {
+		Mean       float64
+		MeanSquare float64
+	}
+	if err := db.Model(&Proxy{}).Select("AVG(score) AS mean, AVG(score * score) AS mean_square").Scan(&moments).Error; err != nil {
+		return nil, err
+	}
+	dist.StdDev = math.Sqrt(math.Max(0, moments.MeanSquare-moments.Mean*moments.Mean))
+
+	// 总数为偶数时取中间两个评分的平均值
+	var middle []float64
+	err = db.Model(&Proxy{}).
+		Order("score ASC").
+		Offset(int((dist.Total-1)/2)).
+		Limit(int(2-dist.Total%2)).
+		Pluck("score", &middle).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, score := range middle {
+		dist.Median += score / float64(len(middle))
+	}
+	return dist, nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetScoreDistribution(t *testing.T) {
+	db := newTestDB(t)
+	scores := map[string]float64{"1.1.1.1": 5, "2.2.2.2": 15, "3.3.3.3": 15, "4.4.4.4": 99, "5.5.5.5": 100}
+	for ip, score := range scores {
+		p := newTestProxy(t, db, ip, 80)
+		db.Model(&Proxy{}).Where("id = ?", p.ID).UpdateColumn("score", score)
+	}
+
+	dist, err := GetScoreDistribution(db, 10)
+	if err != nil {
+		t.Fatalf("GetScoreDistribution: %v", err)
+	}
+	if len(dist.Buckets) != 10 || dist.Total != 5 {
+		t.Fatalf("buckets = %d, total = %d, want 10 buckets and 5 proxies", len(dist.Buckets), dist.Total)
+	}
+	want := map[int]int64{0: 1, 1: 2, 9: 2}
+	for i, b := range dist.Buckets {
+		if got := b.Available + b.Unavailable; got != want[i] {
+			t.Errorf("bucket [%v, %v) count = %d, want %d", b.Min, b.Max, got, want[i])
+		}
+	}
+
+	for _, size := range []float64{0, 0.5, -1, 101} {
+		if _, err := GetScoreDistribution(db, size); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("GetScoreDistribution(%v) error = %v, want %v", size, err, ErrInvalidFilter)
+		}
+	}
+	fine, err := GetScoreDistribution(db, MinScoreBucketSize)
+	if err != nil {
+		t.Fatalf("GetScoreDistribution(%v): %v", MinScoreBucketSize, err)
+	}
+	if len(fine.Buckets) != 100 {
+		t.Errorf("GetScoreDistribution(%v) buckets = %d, want 100", MinScoreBucketSize, len(fine.Buckets))
+	}
+}
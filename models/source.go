@@ -0,0 +1,204 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSourceNotFound 代理源不存在
+var ErrSourceNotFound = errors.New("source not found")
+
+// SourceType 代理源类型
+type SourceType string
+
+const (
+	SourceTypePaid SourceType = "paid"
+	SourceTypeFree SourceType = "free"
+)
+
+// paidSourceSuffix 付费代理源名称的后缀，如 kuaidaili_paid
+const paidSourceSuffix = "_paid"
+
+// ProxySource 代理源，记录类型、运行时启用状态和获取统计
+// 启动时按配置写入，之后可通过API启用或停用；停用不删除记录，保留获取统计
+type ProxySource struct {
+	Name         string     `gorm:"primarykey;type:varchar(64)" json:"name"`
+	Type         SourceType `gorm:"column:kind;type:varchar(16);not null" json:"type"`
+	Kind         SourceType `gorm:"-" json:"kind"` // 已废弃，与 Type 相同，保留给仍读取 kind 字段的客户端
+	Enabled      bool       `gorm:"not null" json:"enabled"`
+	APIURL       string     `gorm:"type:varchar(1024)" json:"api_url"`       // 付费代理源的API地址，已隐藏密钥和签名
+	LastFetchAt  *time.Time `json:"last_fetch_at"`                           // 最近一次获取的时间，从未获取时为空
+	TotalFetched int64      `gorm:"not null;default:0" json:"total_fetched"` // 累计获取的代理数
+	TotalActive  int64      `gorm:"not null;default:0" json:"total_active"`  // 最近一次获取后该来源的可用代理数
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName 代理源表名
+func (ProxySource) TableName() string {
+	return "sources"
+}
+
+// AfterFind 查询后填充已废弃的 Kind 字段
+func (s *ProxySource) AfterFind(tx *gorm.DB) error {
+	s.Kind = s.Type
+	return nil
+}
+
+// SourceTypeOf 按名称推断代理源类型，名称以 _paid 结尾的为付费代理源
+func SourceTypeOf(name string) SourceType {
+	if strings.HasSuffix(name, paidSourceSuffix) {
+		return SourceTypePaid
+	}
+	return SourceTypeFree
+}
+
+// SeedSources 写入代理源，已存在的代理源只更新类型和API地址，保留启用状态和获取统计
+func SeedSources(db *gorm.DB, sources []ProxySource) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind", "api_url"}),
+	}).Create(&sources).Error
+}
+
+// migrateSourceNames 为已有代理的来源补建代理源记录，只处理还没有记录的来源，按名称推断类型
+func migrateSourceNames(db *gorm.DB) error {
+	var rows []struct {
+		Source    string
+		Available int64
+	}
+	err := db.Model(&Proxy{}).
+		Select("source, SUM(CASE WHEN available THEN 1 ELSE 0 END) AS available").
+		Where("source NOT IN (?)", db.Model(&ProxySource{}).Select("name")).
+		Group("source").
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	sources := make([]ProxySource, len(rows))
+	for i, row := range rows {
+		sources[i] = ProxySource{
+			Name:        row.Source,
+			Type:        SourceTypeOf(row.Source),
+			Enabled:     true,
+			TotalActive: row.Available,
+		}
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&sources).Error
+}
+
+// ListSources 获取所有代理源，按名称排序
+func ListSources(db *gorm.DB) ([]ProxySource, error) {
+	var sources []ProxySource
+	err := db.Order("name").Find(&sources).Error
+	return sources, err
+}
+
+// FindSource 获取代理源，不存在时返回 ErrSourceNotFound
+func FindSource(db *gorm.DB, name string) (*ProxySource, error) {
+	var source ProxySource
+	if err := db.Where("name = ?", name).Take(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSourceNotFound
+		}
+		return nil, err
+	}
+	return &source, nil
+}
+
+// SourceEnabled 代理源是否启用，没有记录的代理源视为启用
+func SourceEnabled(db *gorm.DB, name string) (bool, error) {
+	var sources []ProxySource
+	if err := db.Where("name = ?", name).Limit(1).Find(&sources).Error; err != nil {
+		return true, err
+	}
+	if len(sources) == 0 {
+		return true, nil
+	}
+	return sources[0].Enabled, nil
+}
+
+// SetSourceEnabled 设置代理源是否启用，返回更新后的代理源
+func SetSourceEnabled(db *gorm.DB, name string, enabled bool) (*ProxySource, error) {
+	source, err := FindSource(db, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(source).Updates(map[string]interface{}{
+		"enabled":    enabled,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	source.Enabled = enabled
+	return source, nil
+}
+
+// RecordSourceFetch 记录代理源的一次获取：更新最近获取时间，累加获取数，重新统计可用代理数
+// 没有记录的代理源按启用状态新建记录
+func RecordSourceFetch(db *gorm.DB, name string, sourceType SourceType, fetched int64, at time.Time) error {
+	var active int64
+	if err := db.Model(&Proxy{}).Where("source = ? AND available = ?", name, true).Count(&active).Error; err != nil {
+		return err
+	}
+
+	source := ProxySource{
+		Name:         name,
+		Type:         sourceType,
+		Enabled:      true,
+		LastFetchAt:  &at,
+		TotalFetched: fetched,
+		TotalActive:  active,
+		UpdatedAt:    at,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_fetch_at": at,
+			"total_fetched": gorm.Expr("total_fetched + ?", fetched),
+			"total_active":  active,
+			"updated_at":    at,
+		}),
+	}).Create(&source).Error
+}
+
+// DisableSourceProxies 将来源的所有可用代理标记为不可用，返回标记数量
+func DisableSourceProxies(db *gorm.DB, source string) (int64, error) {
+	result := db.Model(&Proxy{}).Where("source = ? AND available = ?", source, true).
+		UpdateColumn("available", false)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteSourceProxies 删除来源的所有代理，返回被删除的代理
+func DeleteSourceProxies(db *gorm.DB, source, reason string) ([]*Proxy, error) {
+	var proxies []*Proxy
+	if err := db.Where("source = ?", source).Find(&proxies).Error; err != nil || len(proxies) == 0 {
+		return proxies, err
+	}
+
+	ids := make([]uint, len(proxies))
+	for i, p := range proxies {
+		ids[i] = p.ID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 记录删除原因
+		if err := tx.Model(&Proxy{}).Where("id IN ?", ids).
+			UpdateColumn("deleted_by_reason", reason).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&Proxy{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proxies, nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestProxySourceJSONKeepsKind(t *testing.T) {
+	db := newTestDB(t)
+	if err := SeedSources(db, []ProxySource{{Name: "kuaidaili_paid", Type: SourceTypePaid, Enabled: true}}); err != nil {
+		t.Fatalf("SeedSources: %v", err)
+	}
+
+	source, err := FindSource(db, "kuaidaili_paid")
+	if err != nil {
+		t.Fatalf("FindSource: %v", err)
+	}
+	data, err := json.Marshal(source)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	// 旧字段 kind 与新字段 type 同时输出
+	if fields["type"] != "paid" || fields["kind"] != "paid" {
+		t.Errorf("source json type = %v, kind = %v, want both paid", fields["type"], fields["kind"])
+	}
+
+	sources, err := ListSources(db)
+	if err != nil || len(sources) != 1 || sources[0].Kind != SourceTypePaid {
+		t.Errorf("ListSources = %+v, %v, want kind filled", sources, err)
+	}
+}
+
+func TestRecordSourceFetchUpserts(t *testing.T) {
+	db := newTestDB(t)
+	newTestProxy(t, db, "1.1.1.1", 80, func(p *Proxy) { p.Source = "kuaidaili_paid" })
+	down := newTestProxy(t, db, "2.2.2.2", 80, func(p *Proxy) { p.Source = "kuaidaili_paid" })
+	db.Model(down).UpdateColumn("available", false)
+
+	// 没有记录的代理源新建记录
+	first := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := RecordSourceFetch(db, "kuaidaili_paid", SourceTypePaid, 5, first); err != nil {
+		t.Fatalf("first RecordSourceFetch: %v", err)
+	}
+	source, err := FindSource(db, "kuaidaili_paid")
+	if err != nil {
+		t.Fatalf("FindSource: %v", err)
+	}
+	if !source.Enabled || source.Type != SourceTypePaid || source.TotalFetched != 5 || source.TotalActive != 1 ||
+		source.LastFetchAt == nil || !source.LastFetchAt.Equal(first) {
+		t.Errorf("after first fetch source = %+v, want enabled paid source with 5 fetched, 1 active at %v", source, first)
+	}
+
+	// 已有记录时累加获取数并更新最近获取时间，保留停用状态
+	if _, err := SetSourceEnabled(db, "kuaidaili_paid", false); err != nil {
+		t.Fatalf("SetSourceEnabled: %v", err)
+	}
+	second := first.Add(30 * time.Second)
+	if err := RecordSourceFetch(db, "kuaidaili_paid", SourceTypePaid, 3, second); err != nil {
+		t.Fatalf("second RecordSourceFetch: %v", err)
+	}
+	if source, err = FindSource(db, "kuaidaili_paid"); err != nil {
+		t.Fatalf("FindSource: %v", err)
+	}
+	if source.Enabled || source.TotalFetched != 8 || source.LastFetchAt == nil || !source.LastFetchAt.Equal(second) {
+		t.Errorf("after second fetch source = %+v, want disabled source with 8 fetched at %v", source, second)
+	}
+
+	var rows int64
+	db.Model(&ProxySource{}).Count(&rows)
+	if rows != 1 {
+		t.Errorf("sources rows = %d, want 1", rows)
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestRecordStreakTransitions(t *testing.T) {
+	db := newTestDB(t)
+	p := newTestProxy(t, db, "1.1.1.1", 8080)
+
+	steps := []struct {
+		success               bool
+		wantSuccess, wantFail int
+	}{
+		{true, 1, 0},
+		{true, 2, 0},
+		{false, 0, 1},
+		{false, 0, 2},
+		{true, 1, 0},
+	}
+	for i, step := range steps {
+		p.RecordStreak(step.success)
+		if p.ConsecutiveSuccess != step.wantSuccess || p.ConsecutiveFailure != step.wantFail {
+			t.Errorf("step %d: in memory streak = %d/%d, want %d/%d",
+				i, p.ConsecutiveSuccess, p.ConsecutiveFailure, step.wantSuccess, step.wantFail)
+		}
+
+		if err := RecordStreak(db, p.ID, step.success); err != nil {
+			t.Fatalf("step %d: RecordStreak: %v", i, err)
+		}
+		var stored Proxy
+		db.First(&stored, p.ID)
+		if stored.ConsecutiveSuccess != step.wantSuccess || stored.ConsecutiveFailure != step.wantFail {
+			t.Errorf("step %d: stored streak = %d/%d, want %d/%d",
+				i, stored.ConsecutiveSuccess, stored.ConsecutiveFailure, step.wantSuccess, step.wantFail)
+		}
+	}
+}
+
+func TestStabilityScoreUsesStreaks(t *testing.T) {
+	// 总失败次数不再影响稳定性评分，只看连续失败；新代理没有使用时长加分
+	created := gorm.Model{CreatedAt: time.Now()}
+	recovered := &Proxy{Model: created, Failure: 50, ConsecutiveSuccess: 3}
+	failing := &Proxy{Model: created, Failure: 1, ConsecutiveFailure: 3}
+	if got := calculateStabilityScore(recovered); got != 100 {
+		t.Errorf("recovered proxy stability = %v, want 100", got)
+	}
+	if got := calculateStabilityScore(failing); got != 70 {
+		t.Errorf("failing proxy stability = %v, want 70", got)
+	}
+	if got := calculateStabilityScore(&Proxy{Model: created, ConsecutiveFailure: 20}); got != 50 {
+		t.Errorf("long failure streak stability = %v, want penalty capped at 50", got)
+	}
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFullTextUnsupported = errors.New("full-text search requires mysql")
+	ErrInvalidTag          = errors.New("invalid tag")
+)
+
+// MaxTagLength 标签最大长度
+const MaxTagLength = 64
+
+// ProxyTag 代理标签
+type ProxyTag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ProxyID   uint      `gorm:"not null;uniqueIndex:idx_proxy_tag" json:"proxy_id"`
+	Tag       string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_proxy_tag;index" json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagCount 标签及其出现次数
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// tagLikePattern 将 us-* 形式的模式转换为 LIKE 表达式，* 匹配任意字符，其余的 LIKE 通配符按字面匹配
+func tagLikePattern(pattern string) string {
+	like := strings.ReplaceAll(escapeLike(pattern), "*", "%")
+	if !strings.HasSuffix(like, "%") {
+		like += "%"
+	}
+	return like
+}
+
+// FindProxiesByTagPattern 根据标签前缀模式查找代理，等同于 ProxyFilter{TagPattern: pattern}
+func FindProxiesByTagPattern(db *gorm.DB, pattern string, limit int) ([]*Proxy, error) {
+	var proxies []*Proxy
+	if err := (ProxyFilter{TagPattern: pattern, Limit: limit}).Apply(db).Find(&proxies).Error; err != nil {
+		return nil, err
+	}
+	return proxies, nil
+}
+
+// FindProxiesByTagFullText 使用全文索引搜索标签，仅支持MySQL，等同于 ProxyFilter{TagSearch: query}
+func FindProxiesByTagFullText(db *gorm.DB, query string, limit int) ([]*Proxy, error) {
+	var proxies []*Proxy
+	if err := (ProxyFilter{TagSearch: query, Limit: limit}).Apply(db).Find(&proxies).Error; err != nil {
+		return nil, err
+	}
+	return proxies, nil
+}
+
+// ListTagCounts 获取所有标签及其出现次数
+func ListTagCounts(db *gorm.DB) ([]TagCount, error) {
+	var counts []TagCount
+	err := db.Model(&ProxyTag{}).
+		Select("tag, COUNT(*) as count").
+		Group("tag").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// NormalizeTags 去除空白并去重，标签为空或超长时返回错误
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > MaxTagLength || strings.Contains(tag, "*") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result, nil
+}
+
+// GetProxyTags 获取代理的所有标签
+func GetProxyTags(db *gorm.DB, proxyID uint) ([]string, error) {
+	var tags []string
+	err := db.Model(&ProxyTag{}).
+		Where("proxy_id = ?", proxyID).
+		Order("tag ASC").
+		Pluck("tag", &tags).Error
+	return tags, err
+}
+
+// AddProxyTags 为代理添加标签，已存在的标签忽略
+func AddProxyTags(db *gorm.DB, proxyID uint, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]ProxyTag, len(tags))
+	for i, tag := range tags {
+		rows[i] = ProxyTag{ProxyID: proxyID, Tag: tag}
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// SetProxyTags 替换代理的全部标签
+func SetProxyTags(db *gorm.DB, proxyID uint, tags []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("proxy_id = ?", proxyID).Delete(&ProxyTag{}).Error; err != nil {
+			return err
+		}
+		return AddProxyTags(tx, proxyID, tags)
+	})
+}
+
+// RemoveProxyTag 移除代理的指定标签
+func RemoveProxyTag(db *gorm.DB, proxyID uint, tag string) error {
+	return db.Where("proxy_id = ? AND tag = ?", proxyID, tag).Delete(&ProxyTag{}).Error
+}
+
+// DeleteTag 从所有代理上移除指定标签，返回移除数量
+func DeleteTag(db *gorm.DB, tag string) (int64, error) {
+	result := db.Where("tag = ?", tag).Delete(&ProxyTag{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProxyFilterTagPattern(t *testing.T) {
+	db := newTestDB(t)
+	east := newTestProxy(t, db, "1.1.1.1", 80)
+	west := newTestProxy(t, db, "2.2.2.2", 80)
+	down := newTestProxy(t, db, "3.3.3.3", 80)
+	db.Model(down).UpdateColumn("available", false)
+	literal := newTestProxy(t, db, "4.4.4.4", 80)
+	for id, tag := range map[uint]string{east.ID: "us-east", west.ID: "us-west", down.ID: "us-down", literal.ID: "us_1%"} {
+		if err := AddProxyTags(db, id, []string{tag}); err != nil {
+			t.Fatalf("add tag %q: %v", tag, err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		want    []uint
+	}{
+		{"us-*", []uint{east.ID, west.ID}},
+		{"us-e", []uint{east.ID}},
+		{"*west", []uint{west.ID}},
+		{"us_", []uint{literal.ID}},
+		{"us_1%", []uint{literal.ID}},
+		{"u%", nil},
+	}
+	for _, tt := range tests {
+		var found []*Proxy
+		filter := ProxyFilter{TagPattern: tt.pattern, Available: Bool(true)}
+		if err := filter.Apply(db).Find(&found).Error; err != nil {
+			t.Fatalf("pattern %q: %v", tt.pattern, err)
+		}
+		if got := proxyIDs(found); !equalIDs(got, tt.want) {
+			t.Errorf("pattern %q = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestProxyFilterTagSearchRequiresMySQL(t *testing.T) {
+	db := newTestDB(t)
+	var found []*Proxy
+	err := ProxyFilter{TagSearch: "us"}.Apply(db).Find(&found).Error
+	if !errors.Is(err, ErrFullTextUnsupported) {
+		t.Errorf("tag search on sqlite error = %v, want %v", err, ErrFullTextUnsupported)
+	}
+}
+
+func TestFindProxiesByTag(t *testing.T) {
+	db := newTestDB(t)
+	east := newTestProxy(t, db, "1.1.1.1", 80)
+	west := newTestProxy(t, db, "2.2.2.2", 80)
+	eu := newTestProxy(t, db, "3.3.3.3", 80)
+	for id, tag := range map[uint]string{east.ID: "us-east", west.ID: "us-west", eu.ID: "eu-west"} {
+		if err := AddProxyTags(db, id, []string{tag}); err != nil {
+			t.Fatalf("add tag %q: %v", tag, err)
+		}
+	}
+
+	found, err := FindProxiesByTagPattern(db, "us-*", 0)
+	if err != nil {
+		t.Fatalf("FindProxiesByTagPattern: %v", err)
+	}
+	if got := proxyIDs(found); !equalIDs(got, []uint{east.ID, west.ID}) {
+		t.Errorf("pattern us-* = %v, want [%d %d]", got, east.ID, west.ID)
+	}
+	if found, err = FindProxiesByTagPattern(db, "*west", 1); err != nil || len(found) != 1 {
+		t.Errorf("pattern *west limit 1 = %d proxies, err %v, want 1", len(found), err)
+	}
+
+	if _, err := FindProxiesByTagFullText(db, "us", 10); !errors.Is(err, ErrFullTextUnsupported) {
+		t.Errorf("full-text on sqlite error = %v, want %v", err, ErrFullTextUnsupported)
+	}
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"proxy_pool/metrics"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxConcurrent 默认最大并发数
+const defaultMaxConcurrent = 10
+
+// ProtocolAuto 自动检测协议，只用于入库前的指示，保存前必须替换为检测到的协议
+const ProtocolAuto = "auto"
+
+// SupportedProtocols 支持的代理协议
+var SupportedProtocols = []string{"http", "https", "socks4", "socks5", ProtocolAuto}
+
+// NeedsProtocolDetection 代理是否需要检测协议
+func (p *Proxy) NeedsProtocolDetection() bool {
+	return p.Protocol == "" || p.Protocol == ProtocolAuto
+}
+
+var ErrInvalidProxy = errors.New("invalid proxy")
+
+// 字段校验失败原因，用作指标标签
+const (
+	ViolationPort          = "port"
+	ViolationIP            = "ip"
+	ViolationProtocol      = "protocol"
+	ViolationMaxConcurrent = "max_concurrent"
+)
+
+// Violation 单个字段的校验失败
+type Violation struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// ProxyValidationError 代理字段校验失败，包含全部不合法的字段
+type ProxyValidationError struct {
+	Violations []Violation
+}
+
+func (e *ProxyValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidProxy, strings.Join(messages, "; "))
+}
+
+func (e *ProxyValidationError) Unwrap() error {
+	return ErrInvalidProxy
+}
+
+// IsSupportedProtocol 检查协议是否受支持
+func IsSupportedProtocol(protocol string) bool {
+	for _, p := range SupportedProtocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 检查代理字段是否合法，返回 *ProxyValidationError 列出所有不合法的字段
+func (p *Proxy) Validate() error {
+	var violations []Violation
+	add := func(reason, format string, args ...interface{}) {
+		violations = append(violations, Violation{Reason: reason, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if p.Port < 1 || p.Port > 65535 {
+		add(ViolationPort, "port %d out of range [1, 65535]", p.Port)
+	}
+	if parseIP(p.IP) == nil {
+		add(ViolationIP, "ip %q is not a valid address", p.IP)
+	}
+	switch {
+	case p.Protocol == ProtocolAuto:
+		add(ViolationProtocol, "protocol %q must be detected before saving", p.Protocol)
+	case !IsSupportedProtocol(p.Protocol):
+		add(ViolationProtocol, "protocol %q not in %v", p.Protocol, SupportedProtocols)
+	}
+	if p.MaxConcurrent < 1 {
+		add(ViolationMaxConcurrent, "max_concurrent %d must be at least 1", p.MaxConcurrent)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ProxyValidationError{Violations: violations}
+}
+
+// validateWithMetric 校验代理字段，失败时按原因计数
+func (p *Proxy) validateWithMetric() error {
+	err := p.Validate()
+	var validationErr *ProxyValidationError
+	if errors.As(err, &validationErr) {
+		for _, v := range validationErr.Violations {
+			metrics.ProxyValidationErrors.WithLabelValues(v.Reason).Inc()
+		}
+	}
+	return err
+}
+
+// logViolations 逐条记录代理的校验失败原因
+func logViolations(p *Proxy, err error) {
+	var validationErr *ProxyValidationError
+	if !errors.As(err, &validationErr) {
+		return
+	}
+	for _, v := range validationErr.Violations {
+		zap.L().Warn("代理字段不合法，已跳过",
+			zap.String("代理", fmt.Sprintf("%s:%d", p.IP, p.Port)),
+			zap.String("来源", p.Source),
+			zap.String("原因", v.Reason),
+			zap.String("详情", v.Message),
+		)
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"proxy_pool/metrics"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProxyValidate(t *testing.T) {
+	valid := func() Proxy {
+		return Proxy{IP: "1.2.3.4", Port: 8080, Protocol: "http", MaxConcurrent: 10}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Proxy)
+		reasons []string
+	}{
+		{"valid", func(p *Proxy) {}, nil},
+		{"valid ipv6", func(p *Proxy) { p.IP = "2001:db8::1" }, nil},
+		{"valid socks5 at max port", func(p *Proxy) { p.Protocol = "socks5"; p.Port = 65535 }, nil},
+		{"port zero", func(p *Proxy) { p.Port = 0 }, []string{ViolationPort}},
+		{"port negative", func(p *Proxy) { p.Port = -1 }, []string{ViolationPort}},
+		{"port too large", func(p *Proxy) { p.Port = 65536 }, []string{ViolationPort}},
+		{"empty ip", func(p *Proxy) { p.IP = "" }, []string{ViolationIP}},
+		{"malformed ip", func(p *Proxy) { p.IP = "1.2.3" }, []string{ViolationIP}},
+		{"hostname ip", func(p *Proxy) { p.IP = "proxy.example.com" }, []string{ViolationIP}},
+		{"empty protocol", func(p *Proxy) { p.Protocol = "" }, []string{ViolationProtocol}},
+		{"unknown protocol", func(p *Proxy) { p.Protocol = "ftp" }, []string{ViolationProtocol}},
+		{"undetected protocol", func(p *Proxy) { p.Protocol = ProtocolAuto }, []string{ViolationProtocol}},
+		{"max concurrent zero", func(p *Proxy) { p.MaxConcurrent = 0 }, []string{ViolationMaxConcurrent}},
+		{"port and ip", func(p *Proxy) { p.Port = 0; p.IP = "x" }, []string{ViolationPort, ViolationIP}},
+		{"everything", func(p *Proxy) { *p = Proxy{Port: 70000} },
+			[]string{ViolationPort, ViolationIP, ViolationProtocol, ViolationMaxConcurrent}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid()
+			tt.modify(&p)
+
+			err := p.Validate()
+			if tt.reasons == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ProxyValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() = %v, want *ProxyValidationError", err)
+			}
+			if !errors.Is(err, ErrInvalidProxy) {
+				t.Errorf("Validate() error does not wrap ErrInvalidProxy")
+			}
+			var reasons []string
+			for _, v := range validationErr.Violations {
+				reasons = append(reasons, v.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("violations = %v, want %v", reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestValidateWithMetricCountsReasons(t *testing.T) {
+	before := testutil.ToFloat64(metrics.ProxyValidationErrors.WithLabelValues(ViolationPort))
+
+	p := Proxy{IP: "1.2.3.4", Port: 0, Protocol: "http", MaxConcurrent: 1}
+	if err := p.validateWithMetric(); err == nil {
+		t.Fatal("validateWithMetric() = nil, want error")
+	}
+
+	after := testutil.ToFloat64(metrics.ProxyValidationErrors.WithLabelValues(ViolationPort))
+	if after-before != 1 {
+		t.Errorf("proxy_validation_error_total{reason=port} grew by %v, want 1", after-before)
+	}
+}
+
+func TestNormalizeIPWithMetricCountsPrivateSeparately(t *testing.T) {
+	const source = "metric-test"
+	private := metrics.ProxyRejectedPrivateIP.WithLabelValues(source)
+	rejected := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.ProxyRejectedIP.WithLabelValues(source, reason))
+	}
+	beforePrivate := testutil.ToFloat64(private)
+	beforeInvalid, beforeLoopback := rejected(ipRejectInvalid), rejected(ipRejectLoopback)
+
+	for _, ip := range []string{"10.1.2.3", "0.0.0.0", "not-an-ip", "127.0.0.1", "8.8.8.8"} {
+		p := Proxy{IP: ip, Source: source}
+		_ = p.normalizeIPWithMetric()
+	}
+
+	if got := testutil.ToFloat64(private) - beforePrivate; got != 1 {
+		t.Errorf("proxy_rejected_private_ip_total grew by %v, want 1", got)
+	}
+	if got := rejected(ipRejectInvalid) - beforeInvalid; got != 2 {
+		t.Errorf("proxy_rejected_ip_total{reason=invalid} grew by %v, want 2", got)
+	}
+	if got := rejected(ipRejectLoopback) - beforeLoopback; got != 1 {
+		t.Errorf("proxy_rejected_ip_total{reason=loopback} grew by %v, want 1", got)
+	}
+}
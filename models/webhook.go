@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProxyCallback 代理验证结果回调地址，首次验证完成后删除
+type ProxyCallback struct {
+	ProxyID   uint      `gorm:"primarykey" json:"proxy_id"`
+	URL       string    `gorm:"type:varchar(512);not null" json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDeadLetter 多次重试后仍投递失败的回调
+type WebhookDeadLetter struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ProxyID   uint      `gorm:"index" json:"proxy_id"`
+	URL       string    `gorm:"type:varchar(512);not null" json:"url"`
+	Payload   string    `gorm:"type:text" json:"payload"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `gorm:"type:varchar(512)" json:"last_error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetProxyCallback 设置代理的回调地址，已存在时覆盖
+func SetProxyCallback(db *gorm.DB, proxyID uint, url string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "proxy_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"url", "created_at"}),
+	}).Create(&ProxyCallback{ProxyID: proxyID, URL: url}).Error
+}
+
+// TakeProxyCallback 取出并删除代理的回调地址，没有回调时返回nil
+// 并发调用时只有一个调用方能取到回调
+func TakeProxyCallback(db *gorm.DB, proxyID uint) (*ProxyCallback, error) {
+	var callbacks []ProxyCallback
+	if err := db.Where("proxy_id = ?", proxyID).Limit(1).Find(&callbacks).Error; err != nil {
+		return nil, err
+	}
+	if len(callbacks) == 0 {
+		return nil, nil
+	}
+
+	result := db.Where("proxy_id = ?", proxyID).Delete(&ProxyCallback{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &callbacks[0], nil
+}
+
+// ListPendingCallbackIDs 获取所有等待回调的代理ID
+func ListPendingCallbackIDs(db *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&ProxyCallback{}).Pluck("proxy_id", &ids).Error
+	return ids, err
+}
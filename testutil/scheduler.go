@@ -0,0 +1,112 @@
+// Package testutil 提供单元测试使用的替身实现
+package testutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"proxy_pool/core"
+	"proxy_pool/models"
+)
+
+var _ core.Scheduler = (*MockScheduler)(nil)
+
+// MockScheduler 记录调用并返回预设结果的调度器，通过 core.WithScheduler 注入代理池
+// 无需数据库中有代理即可测试调用 GetProxyForTask 等方法的代码
+type MockScheduler struct {
+	mu sync.Mutex
+
+	Proxy      *models.Proxy            // ScheduleProxy 返回的代理，为空且 Err 为空时返回 core.ErrNoProxyAvailable
+	Err        error                    // ScheduleProxy 和 RankCandidates 返回的错误
+	Candidates []core.ScheduleCandidate // RankCandidates 返回的候选代理
+	Predicted  float64                  // PredictProxyScore 返回的评分
+	Concurrent map[uint]int             // LiveConcurrentUse 返回的并发数
+	Cooldowns  int                      // CooldownCount 返回的冷却代理数
+	tasks      []core.Task              // ScheduleProxy 收到的任务
+	reports    []core.ProxyStatusReport // ReportProxyStatus 和 ReportProxyStatuses 收到的上报
+}
+
+// ScheduleProxy 记录任务，返回预设的代理或错误
+func (m *MockScheduler) ScheduleProxy(ctx context.Context, task *core.Task) (*models.Proxy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tasks = append(m.tasks, *task)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Proxy == nil {
+		return nil, core.ErrNoProxyAvailable
+	}
+	return m.Proxy, nil
+}
+
+// ReportProxyStatus 记录上报
+func (m *MockScheduler) ReportProxyStatus(proxyID uint, report core.StatusReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reports = append(m.reports, core.ProxyStatusReport{ProxyID: proxyID, StatusReport: report})
+}
+
+// ReportProxyStatuses 记录上报，每条结果均为 core.ReportApplied
+func (m *MockScheduler) ReportProxyStatuses(reports []core.ProxyStatusReport) ([]core.StatusReportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]core.StatusReportResult, len(reports))
+	for i, r := range reports {
+		m.reports = append(m.reports, r)
+		results[i] = core.StatusReportResult{ProxyID: r.ProxyID, Status: core.ReportApplied}
+	}
+	return results, nil
+}
+
+// PredictProxyScore 返回预设的评分
+func (m *MockScheduler) PredictProxyScore(proxyID uint, horizon time.Duration) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Predicted
+}
+
+// LiveConcurrentUse 返回预设的并发数
+func (m *MockScheduler) LiveConcurrentUse(proxyID uint) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Concurrent[proxyID]
+}
+
+// CooldownCount 返回预设的冷却代理数
+func (m *MockScheduler) CooldownCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Cooldowns
+}
+
+// RankCandidates 返回预设候选代理的前 n 个
+func (m *MockScheduler) RankCandidates(task *core.Task, n int) ([]core.ScheduleCandidate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if n > 0 && n < len(m.Candidates) {
+		return m.Candidates[:n], nil
+	}
+	return m.Candidates, nil
+}
+
+// Tasks 获取 ScheduleProxy 收到的任务副本，按调用顺序
+func (m *MockScheduler) Tasks() []core.Task {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]core.Task(nil), m.tasks...)
+}
+
+// Reports 获取收到的上报，按调用顺序
+func (m *MockScheduler) Reports() []core.ProxyStatusReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]core.ProxyStatusReport(nil), m.reports...)
+}